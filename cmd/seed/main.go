@@ -0,0 +1,433 @@
+// Command seed fills a development database with a demo catalog: a category tree,
+// products with Slovak titles, EANs, attributes and placeholder images, and a few
+// inactive feeds. Re-running tops the demo data up to the requested counts.
+//
+//	go run ./cmd/seed -categories 40 -products 10000 -es
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+
+	"megabuy-go/internal/database"
+	"megabuy-go/internal/handlers"
+)
+
+// Demo rows are recognised by these markers, so real data is never touched
+const (
+	categorySlugPrefix = "demo-"
+	productSource      = "seed"
+	skuPrefix          = "DEMO-"
+	feedURLPrefix      = "https://example.com/demo-feed-"
+	batchSize          = 1000
+)
+
+type attrTemplate struct {
+	Name   string
+	Values []string
+}
+
+// catalogTemplate describes one top-level demo category and the products generated in it
+type catalogTemplate struct {
+	Name     string
+	Icon     string
+	Subs     []string
+	Nouns    []string
+	Brands   []string
+	PriceMin float64
+	PriceMax float64
+	Attrs    []attrTemplate
+}
+
+var catalog = []catalogTemplate{
+	{
+		Name: "Elektronika", Icon: "📱",
+		Subs:     []string{"Mobilné telefóny", "Tablety", "Smart hodinky", "Powerbanky"},
+		Nouns:    []string{"Mobilný telefón", "Tablet", "Smart hodinky", "Powerbanka"},
+		Brands:   []string{"Samsung", "Apple", "Xiaomi", "Motorola", "Huawei", "Nokia"},
+		PriceMin: 19, PriceMax: 1499,
+		Attrs: []attrTemplate{
+			{"Farba", []string{"čierna", "biela", "modrá", "strieborná", "zelená"}},
+			{"Kapacita", []string{"64 GB", "128 GB", "256 GB", "512 GB"}},
+			{"Uhlopriečka displeja", []string{"6,1\"", "6,5\"", "6,7\"", "10,9\"", "1,4\""}},
+		},
+	},
+	{
+		Name: "Počítače", Icon: "💻",
+		Subs:     []string{"Notebooky", "Monitory", "Klávesnice", "Myši"},
+		Nouns:    []string{"Notebook", "Monitor", "Klávesnica", "Herná myš"},
+		Brands:   []string{"Lenovo", "HP", "Dell", "Asus", "Acer", "Logitech"},
+		PriceMin: 15, PriceMax: 2499,
+		Attrs: []attrTemplate{
+			{"Procesor", []string{"Intel Core i5", "Intel Core i7", "AMD Ryzen 5", "AMD Ryzen 7"}},
+			{"Operačná pamäť", []string{"8 GB", "16 GB", "32 GB"}},
+			{"Pripojenie", []string{"USB-C", "Bluetooth", "Wi-Fi", "HDMI"}},
+		},
+	},
+	{
+		Name: "Domáce spotrebiče", Icon: "🏠",
+		Subs:     []string{"Vysávače", "Kávovary", "Práčky", "Chladničky"},
+		Nouns:    []string{"Robotický vysávač", "Automatický kávovar", "Práčka", "Chladnička"},
+		Brands:   []string{"Bosch", "Philips", "DeLonghi", "Electrolux", "Samsung", "Rowenta"},
+		PriceMin: 49, PriceMax: 1299,
+		Attrs: []attrTemplate{
+			{"Energetická trieda", []string{"A", "B", "C", "D"}},
+			{"Príkon", []string{"800 W", "1200 W", "1450 W", "2000 W"}},
+			{"Farba", []string{"biela", "čierna", "nerez"}},
+		},
+	},
+	{
+		Name: "Šport a outdoor", Icon: "⚽",
+		Subs:     []string{"Bicykle", "Stany", "Bežecká obuv", "Fitness"},
+		Nouns:    []string{"Horský bicykel", "Stan", "Bežecká obuv", "Činka"},
+		Brands:   []string{"Kellys", "Ctm", "Salomon", "Adidas", "Nike", "Husky"},
+		PriceMin: 9, PriceMax: 1899,
+		Attrs: []attrTemplate{
+			{"Veľkosť", []string{"S", "M", "L", "XL", "42", "44"}},
+			{"Materiál", []string{"hliník", "karbón", "polyester", "oceľ"}},
+			{"Hmotnosť", []string{"0,3 kg", "1,2 kg", "2,5 kg", "13,8 kg"}},
+		},
+	},
+	{
+		Name: "Dom a záhrada", Icon: "🏡",
+		Subs:     []string{"Kosačky", "Záhradný nábytok", "Grily", "Náradie"},
+		Nouns:    []string{"Kosačka", "Záhradná stolička", "Plynový gril", "Aku vŕtačka"},
+		Brands:   []string{"Makita", "Gardena", "Weber", "Fieldmann", "Stihl", "Black+Decker"},
+		PriceMin: 12, PriceMax: 999,
+		Attrs: []attrTemplate{
+			{"Napájanie", []string{"akumulátor", "elektrina", "benzín", "plyn"}},
+			{"Záruka", []string{"24 mesiacov", "36 mesiacov", "60 mesiacov"}},
+			{"Farba", []string{"zelená", "čierna", "šedá"}},
+		},
+	},
+}
+
+var (
+	modelWords   = []string{"Pro", "Max", "Lite", "Plus", "Ultra", "Neo", "Air", "Prime", "Go", "Edge"}
+	stockChoices = []string{"instock", "instock", "instock", "instock", "outofstock", "preorder"}
+)
+
+func main() {
+	numCategories := flag.Int("categories", 25, "target number of demo categories (top level included)")
+	numProducts := flag.Int("products", 1000, "target number of demo products")
+	numFeeds := flag.Int("feeds", 2, "target number of demo feeds")
+	indexES := flag.Bool("es", false, "reindex all products into Elasticsearch afterwards")
+	force := flag.Bool("force", false, "allow seeding when APP_ENV=production")
+	flag.Parse()
+
+	godotenv.Load()
+	if os.Getenv("APP_ENV") == "production" && !*force {
+		log.Fatal("Refusing to seed a production database (APP_ENV=production); pass -force to override")
+	}
+
+	db, err := database.New()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	start := time.Now()
+
+	categories, err := seedCategories(ctx, db.Pool, *numCategories)
+	if err != nil {
+		log.Fatalf("Seeding categories failed: %v", err)
+	}
+	feeds, err := seedFeeds(ctx, db.Pool, *numFeeds)
+	if err != nil {
+		log.Fatalf("Seeding feeds failed: %v", err)
+	}
+	added, err := seedProducts(ctx, db.Pool, *numProducts, categories, feeds)
+	if err != nil {
+		log.Fatalf("Seeding products failed: %v", err)
+	}
+	db.Pool.Exec(ctx, `
+		UPDATE categories SET (product_count, in_stock_count) = (
+			SELECT COUNT(*), COUNT(*) FILTER (WHERE COALESCE(stock_status,'instock') <> 'outofstock')
+			FROM products WHERE category_id = categories.id AND is_active = true
+		)`)
+	fmt.Printf("Seeded %d categories, %d feeds, %d new products in %s\n", len(categories), len(feeds), added, time.Since(start).Round(time.Millisecond))
+
+	if *indexES {
+		indexed, err := handlers.New(db).ReindexProducts(ctx)
+		if err != nil {
+			log.Fatalf("Elasticsearch indexing failed after %d products: %v", indexed, err)
+		}
+		fmt.Printf("Indexed %d products into Elasticsearch\n", indexed)
+	}
+}
+
+// demoCategory is a seeded category that products can be assigned to
+type demoCategory struct {
+	ID       string
+	Template int
+	Sub      int
+}
+
+// seedCategories creates the top-level template categories and then subcategories
+// round-robin under them until n demo categories exist. Slugs are derived from the
+// position, so reruns only add what is missing.
+func seedCategories(ctx context.Context, pool *pgxpool.Pool, n int) ([]demoCategory, error) {
+	n = max(n, len(catalog))
+	for i := 0; i < n; i++ {
+		t := i % len(catalog)
+		rootSlug := categorySlugPrefix + handlers.Slug(catalog[t].Name)
+		if i < len(catalog) {
+			_, err := pool.Exec(ctx, `
+				INSERT INTO categories (name, slug, icon, sort_order, is_active)
+				VALUES ($1, $2, $3, $4, true) ON CONFLICT (slug) DO NOTHING
+			`, catalog[t].Name, rootSlug, catalog[t].Icon, 100+i)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		name := subcategoryName(i)
+		_, err := pool.Exec(ctx, `
+			INSERT INTO categories (parent_id, name, slug, sort_order, is_active)
+			SELECT id, $2, $3, $4, true FROM categories WHERE slug = $1
+			ON CONFLICT (slug) DO NOTHING
+		`, rootSlug, name, categorySlugPrefix+handlers.Slug(name), i)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var categories []demoCategory
+	for i := 0; i < n; i++ {
+		t := i % len(catalog)
+		slug := categorySlugPrefix + handlers.Slug(catalog[t].Name)
+		if i >= len(catalog) {
+			slug = categorySlugPrefix + handlers.Slug(subcategoryName(i))
+		}
+		var id string
+		if err := pool.QueryRow(ctx, "SELECT id FROM categories WHERE slug = $1", slug).Scan(&id); err != nil {
+			return nil, fmt.Errorf("category %s: %w", slug, err)
+		}
+		categories = append(categories, demoCategory{ID: id, Template: t, Sub: i/len(catalog) - 1})
+	}
+	return categories, nil
+}
+
+// subcategoryName names the i-th category (i >= len(catalog)); names repeat with a numeric suffix
+func subcategoryName(i int) string {
+	t := catalog[i%len(catalog)]
+	k := i/len(catalog) - 1
+	name := t.Subs[k%len(t.Subs)]
+	if round := k / len(t.Subs); round > 0 {
+		name = fmt.Sprintf("%s %d", name, round+1)
+	}
+	return name
+}
+
+// seedFeeds adds inactive placeholder feeds so feed screens have something to show
+func seedFeeds(ctx context.Context, pool *pgxpool.Pool, n int) ([]string, error) {
+	var ids []string
+	for i := 1; i <= n; i++ {
+		url := fmt.Sprintf("%s%d.xml", feedURLPrefix, i)
+		var id string
+		err := pool.QueryRow(ctx, "SELECT id FROM feeds WHERE url = $1", url).Scan(&id)
+		if err == pgx.ErrNoRows {
+			err = pool.QueryRow(ctx, `
+				INSERT INTO feeds (name, url, type, is_active, last_status, created_at, updated_at)
+				VALUES ($1, $2, 'heureka', false, 'idle', NOW(), NOW()) RETURNING id
+			`, fmt.Sprintf("Demo feed %d", i), url).Scan(&id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// seedProducts fills gaps in the DEMO-000000.. SKU sequence until target demo products
+// exist. Every product is generated from its own sequence number, so output is stable.
+func seedProducts(ctx context.Context, pool *pgxpool.Pool, target int, categories []demoCategory, feeds []string) (int, error) {
+	existing := map[string]bool{}
+	rows, err := pool.Query(ctx, "SELECT sku FROM products WHERE source = $1", productSource)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var sku string
+		rows.Scan(&sku)
+		existing[sku] = true
+	}
+	rows.Close()
+
+	added := 0
+	var batch []demoProduct
+	for seq := 0; len(existing)+added+len(batch) < target; seq++ {
+		sku := fmt.Sprintf("%s%06d", skuPrefix, seq)
+		if existing[sku] {
+			continue
+		}
+		batch = append(batch, generateProduct(seq, sku, categories, feeds))
+		if len(batch) == batchSize {
+			if err := insertProducts(ctx, pool, batch); err != nil {
+				return added, err
+			}
+			added += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		if err := insertProducts(ctx, pool, batch); err != nil {
+			return added, err
+		}
+		added += len(batch)
+	}
+	return added, nil
+}
+
+type demoProduct struct {
+	ID         uuid.UUID
+	Title      string
+	Slug       string
+	ShortDesc  string
+	Desc       string
+	EAN        string
+	SKU        string
+	Brand      string
+	Image      string
+	Affiliate  string
+	CategoryID string
+	FeedID     *string
+	Price      float64
+	PriceNet   float64
+	Stock      string
+	Featured   bool
+	CreatedAt  time.Time
+	Attrs      [][2]string
+	Images     []string
+}
+
+func generateProduct(seq int, sku string, categories []demoCategory, feeds []string) demoProduct {
+	r := rand.New(rand.NewSource(int64(seq) + 1))
+	cat := categories[r.Intn(len(categories))]
+	t := catalog[cat.Template]
+
+	noun := t.Nouns[r.Intn(len(t.Nouns))]
+	if cat.Sub >= 0 {
+		noun = t.Nouns[cat.Sub%len(t.Nouns)]
+	}
+	brand := t.Brands[r.Intn(len(t.Brands))]
+	model := fmt.Sprintf("%s %d %s", string(rune('A'+r.Intn(26))), 1+r.Intn(99), modelWords[r.Intn(len(modelWords))])
+	title := fmt.Sprintf("%s %s %s", noun, brand, model)
+
+	// log-uniform prices look more like a real catalog than uniform ones
+	price := math.Exp(math.Log(t.PriceMin) + r.Float64()*(math.Log(t.PriceMax)-math.Log(t.PriceMin)))
+	price = math.Floor(price) + 0.99
+	net := math.Round(price/1.2*100) / 100
+
+	p := demoProduct{
+		ID:         uuid.New(),
+		Title:      title,
+		Slug:       handlers.Slug(title) + "-" + strings.ToLower(sku),
+		ShortDesc:  fmt.Sprintf("%s značky %s v modelovom rade %s.", noun, brand, model),
+		EAN:        demoEAN(seq),
+		SKU:        sku,
+		Brand:      brand,
+		Image:      fmt.Sprintf("https://picsum.photos/seed/%s/600/600", strings.ToLower(sku)),
+		Affiliate:  fmt.Sprintf("https://example.com/produkt/%s", strings.ToLower(sku)),
+		CategoryID: cat.ID,
+		Price:      price,
+		PriceNet:   net,
+		Stock:      stockChoices[r.Intn(len(stockChoices))],
+		Featured:   r.Intn(50) == 0,
+		CreatedAt:  time.Now().Add(-time.Duration(r.Intn(365*24)) * time.Hour),
+	}
+	if len(feeds) > 0 && r.Intn(3) > 0 {
+		p.FeedID = &feeds[seq%len(feeds)]
+	}
+
+	var desc strings.Builder
+	desc.WriteString(p.ShortDesc)
+	for _, a := range t.Attrs {
+		value := a.Values[r.Intn(len(a.Values))]
+		p.Attrs = append(p.Attrs, [2]string{a.Name, value})
+		fmt.Fprintf(&desc, " %s: %s.", a.Name, value)
+	}
+	p.Attrs = append(p.Attrs, [2]string{"Výrobca", brand})
+	p.Desc = desc.String()
+
+	for i := 0; i < 1+r.Intn(3); i++ {
+		p.Images = append(p.Images, fmt.Sprintf("https://picsum.photos/seed/%s-%d/600/600", strings.ToLower(sku), i))
+	}
+	return p
+}
+
+// demoEAN builds a valid EAN-13 in the 858 (Slovakia) prefix range from a sequence number
+func demoEAN(seq int) string {
+	digits := fmt.Sprintf("858%09d", seq)
+	sum := 0
+	for i, d := range digits {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(d-'0') * weight
+	}
+	return fmt.Sprintf("%s%d", digits, (10-sum%10)%10)
+}
+
+// insertProducts writes a batch with COPY, which is what keeps 10k products well under a minute
+func insertProducts(ctx context.Context, pool *pgxpool.Pool, batch []demoProduct) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	productRows := make([][]interface{}, len(batch))
+	var attrRows, imageRows [][]interface{}
+	for i, p := range batch {
+		productRows[i] = []interface{}{
+			p.ID, p.Title, p.Slug, p.Desc, p.ShortDesc, p.EAN, p.SKU, p.Brand, p.Image, p.Affiliate,
+			uuid.MustParse(p.CategoryID), feedUUID(p.FeedID), p.Price, p.Price, p.PriceNet, p.PriceNet, 20.0, "EUR",
+			p.Stock, true, p.Featured, productSource, p.CreatedAt, p.CreatedAt,
+		}
+		for pos, a := range p.Attrs {
+			attrRows = append(attrRows, []interface{}{p.ID, a[0], a[1], pos})
+		}
+		for pos, url := range p.Images {
+			imageRows = append(imageRows, []interface{}{p.ID, url, p.Title, pos, pos == 0})
+		}
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"products"}, []string{
+		"id", "title", "slug", "description", "short_description", "ean", "sku", "brand", "image_url", "affiliate_url",
+		"category_id", "feed_id", "price_min", "price_max", "price_min_net", "price_max_net", "vat_rate", "currency",
+		"stock_status", "is_active", "is_featured", "source", "created_at", "updated_at",
+	}, pgx.CopyFromRows(productRows)); err != nil {
+		return fmt.Errorf("products: %w", err)
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"product_attributes"}, []string{"product_id", "name", "value", "position"}, pgx.CopyFromRows(attrRows)); err != nil {
+		return fmt.Errorf("product_attributes: %w", err)
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"product_images"}, []string{"product_id", "url", "alt", "position", "is_main"}, pgx.CopyFromRows(imageRows)); err != nil {
+		return fmt.Errorf("product_images: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+func feedUUID(id *string) interface{} {
+	if id == nil {
+		return nil
+	}
+	return uuid.MustParse(*id)
+}
@@ -6,6 +6,7 @@ require (
 	github.com/elastic/go-elasticsearch/v8 v8.19.1
 	github.com/gofiber/fiber/v2 v2.52.0
 	github.com/google/uuid v1.5.0
+	github.com/graph-gophers/graphql-go v1.7.0
 	github.com/jackc/pgx/v5 v5.5.1
 	github.com/joho/godotenv v1.5.1
 	golang.org/x/text v0.14.0
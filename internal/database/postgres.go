@@ -11,6 +11,10 @@ import (
 
 type DB struct {
 	Pool *pgxpool.Pool
+	// ReadPool serves read-only queries from DATABASE_REPLICA_URLS, or from Pool when none are set
+	ReadPool *ReadPool
+	// Tracer is nil unless DB_QUERY_TRACING=true
+	Tracer *QueryTracer
 }
 
 func New() (*DB, error) {
@@ -30,6 +34,12 @@ func New() (*DB, error) {
 	config.MaxConnLifetime = time.Hour
 	config.MaxConnIdleTime = 30 * time.Minute
 
+	var tracer *QueryTracer
+	if os.Getenv("DB_QUERY_TRACING") == "true" {
+		tracer = NewQueryTracer()
+		config.ConnConfig.Tracer = tracer
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -45,10 +55,13 @@ func New() (*DB, error) {
 
 	fmt.Println("✅ Connected to PostgreSQL database")
 
-	return &DB{Pool: pool}, nil
+	return &DB{Pool: pool, ReadPool: connectReplicas(ctx, pool, tracer), Tracer: tracer}, nil
 }
 
 func (db *DB) Close() {
+	if db.ReadPool != nil {
+		db.ReadPool.close()
+	}
 	if db.Pool != nil {
 		db.Pool.Close()
 	}
@@ -0,0 +1,215 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// readTarget is a pool reads can go to; *pgxpool.Pool satisfies it
+type readTarget interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	Ping(ctx context.Context) error
+}
+
+type replica struct {
+	name    string
+	pool    readTarget
+	healthy atomic.Bool
+}
+
+// ReadPool spreads read-only queries over replicas round-robin. A replica that fails
+// to connect is marked down and the query is retried on the primary; the health
+// check brings it back once it answers pings again. Without healthy replicas every
+// read goes to the primary.
+type ReadPool struct {
+	primary  readTarget
+	replicas []*replica
+	next     atomic.Uint64
+	closers  []func()
+}
+
+// NewReadPool routes reads to replicas and falls back to primary
+func NewReadPool(primary readTarget, replicas ...readTarget) *ReadPool {
+	rp := &ReadPool{primary: primary}
+	for i, pool := range replicas {
+		r := &replica{name: fmt.Sprintf("replica-%d", i+1), pool: pool}
+		r.healthy.Store(true)
+		rp.replicas = append(rp.replicas, r)
+	}
+	return rp
+}
+
+// replicaURLs reads DATABASE_REPLICA_URLS (comma separated) or DATABASE_REPLICA_URL
+func replicaURLs() []string {
+	raw := os.Getenv("DATABASE_REPLICA_URLS")
+	if raw == "" {
+		raw = os.Getenv("DATABASE_REPLICA_URL")
+	}
+	var urls []string
+	for _, u := range strings.Split(raw, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// connectReplicas opens a pool per configured replica. A replica that cannot be
+// reached at startup starts marked down instead of failing the service.
+func connectReplicas(ctx context.Context, primary *pgxpool.Pool, tracer *QueryTracer) *ReadPool {
+	rp := NewReadPool(primary)
+	for i, url := range replicaURLs() {
+		name := fmt.Sprintf("replica-%d", i+1)
+		config, err := pgxpool.ParseConfig(url)
+		if err != nil {
+			log.Printf("Read replica %s ignored: %s", name, RedactSecrets(err.Error()))
+			continue
+		}
+		config.MaxConns = 25
+		config.MinConns = 0
+		config.MaxConnLifetime = time.Hour
+		config.MaxConnIdleTime = 30 * time.Minute
+		if tracer != nil {
+			config.ConnConfig.Tracer = tracer
+		}
+		pool, err := pgxpool.NewWithConfig(ctx, config)
+		if err != nil {
+			log.Printf("Read replica %s ignored: %s", name, RedactSecrets(err.Error()))
+			continue
+		}
+		r := &replica{name: name, pool: pool}
+		if err := pool.Ping(ctx); err != nil {
+			log.Printf("Read replica %s down at startup: %s", name, RedactSecrets(err.Error()))
+		} else {
+			r.healthy.Store(true)
+		}
+		rp.replicas = append(rp.replicas, r)
+		rp.closers = append(rp.closers, pool.Close)
+	}
+	if len(rp.replicas) > 0 {
+		fmt.Printf("✅ %d read replica(s) configured\n", len(rp.replicas))
+	}
+	return rp
+}
+
+// pick returns the next healthy replica, or nil when reads should go to the primary
+func (rp *ReadPool) pick() *replica {
+	n := len(rp.replicas)
+	if n == 0 {
+		return nil
+	}
+	start := rp.next.Add(1)
+	for i := 0; i < n; i++ {
+		r := rp.replicas[(start+uint64(i))%uint64(n)]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// unreachable tells connection failures, which justify a fallback, from query errors,
+// which the primary would return as well
+func unreachable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || pgconn.SafeToRetry(err) || pgconn.Timeout(err) ||
+		strings.Contains(err.Error(), "failed to connect")
+}
+
+// Query runs sql on a healthy replica, falling back to the primary when it is unreachable
+func (rp *ReadPool) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if r := rp.pick(); r != nil {
+		rows, err := r.pool.Query(ctx, sql, args...)
+		if !unreachable(ctx, err) {
+			return rows, err
+		}
+		if r.healthy.CompareAndSwap(true, false) {
+			log.Printf("Read replica %s marked down: %s", r.name, RedactSecrets(err.Error()))
+		}
+	}
+	return rp.primary.Query(ctx, sql, args...)
+}
+
+// QueryRow is Query for a single row; errors surface on Scan like with pgx
+func (rp *ReadPool) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	rows, err := rp.Query(ctx, sql, args...)
+	return &readRow{rows: rows, err: err}
+}
+
+type readRow struct {
+	rows pgx.Rows
+	err  error
+}
+
+func (r *readRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	r.rows.Close()
+	return r.rows.Err()
+}
+
+// RunHealthChecks pings every replica each interval and updates its state
+func (rp *ReadPool) RunHealthChecks(interval time.Duration) {
+	if len(rp.replicas) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		rp.checkHealth(context.Background(), interval)
+	}
+}
+
+func (rp *ReadPool) checkHealth(ctx context.Context, timeout time.Duration) {
+	for _, r := range rp.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := r.pool.Ping(pingCtx)
+		cancel()
+		switch {
+		case err == nil && r.healthy.CompareAndSwap(false, true):
+			log.Printf("Read replica %s is back", r.name)
+		case err != nil && r.healthy.CompareAndSwap(true, false):
+			log.Printf("Read replica %s marked down: %s", r.name, RedactSecrets(err.Error()))
+		}
+	}
+}
+
+// Status reports each replica's health for diagnostics
+func (rp *ReadPool) Status() map[string]bool {
+	status := make(map[string]bool, len(rp.replicas))
+	for _, r := range rp.replicas {
+		status[r.name] = r.healthy.Load()
+	}
+	return status
+}
+
+func (rp *ReadPool) close() {
+	for _, c := range rp.closers {
+		c()
+	}
+}
@@ -0,0 +1,226 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeTarget is a readTarget answering every query with rows of one int each, or
+// with err; pingErr is returned by Ping
+type fakeTarget struct {
+	mu      sync.Mutex
+	rows    []int
+	err     error
+	pingErr error
+	queries int
+}
+
+func (f *fakeTarget) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &fakeRows{values: f.rows, pos: -1}, nil
+}
+
+func (f *fakeTarget) Ping(context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pingErr
+}
+
+func (f *fakeTarget) set(err, pingErr error) {
+	f.mu.Lock()
+	f.err, f.pingErr = err, pingErr
+	f.mu.Unlock()
+}
+
+func (f *fakeTarget) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.queries
+}
+
+type fakeRows struct {
+	values []int
+	pos    int
+	closed bool
+}
+
+func (r *fakeRows) Close()                                       { r.closed = true }
+func (r *fakeRows) Err() error                                   { return nil }
+func (r *fakeRows) CommandTag() pgconn.CommandTag                { return pgconn.CommandTag{} }
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (r *fakeRows) Values() ([]any, error)                       { return []any{r.values[r.pos]}, nil }
+func (r *fakeRows) RawValues() [][]byte                          { return nil }
+func (r *fakeRows) Conn() *pgx.Conn                              { return nil }
+
+func (r *fakeRows) Next() bool {
+	if r.closed || r.pos+1 >= len(r.values) {
+		return false
+	}
+	r.pos++
+	return true
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	*dest[0].(*int) = r.values[r.pos]
+	return nil
+}
+
+var errRefused = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+func TestReadPoolRoundRobin(t *testing.T) {
+	primary, r1, r2 := &fakeTarget{}, &fakeTarget{}, &fakeTarget{}
+	rp := NewReadPool(primary, r1, r2)
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		rows, err := rp.Query(ctx, "SELECT 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows.Close()
+	}
+	if primary.count() != 0 || r1.count() != 5 || r2.count() != 5 {
+		t.Errorf("primary %d, replicas %d and %d queries; want 0, 5 and 5", primary.count(), r1.count(), r2.count())
+	}
+}
+
+func TestReadPoolWithoutReplicasUsesPrimary(t *testing.T) {
+	primary := &fakeTarget{}
+	rp := NewReadPool(primary)
+	if _, err := rp.Query(context.Background(), "SELECT 1"); err != nil || primary.count() != 1 {
+		t.Errorf("err %v, %d primary queries", err, primary.count())
+	}
+	if len(rp.Status()) != 0 {
+		t.Errorf("status %v", rp.Status())
+	}
+}
+
+func TestReadPoolFallsBackWhenReplicaUnreachable(t *testing.T) {
+	primary, down, up := &fakeTarget{}, &fakeTarget{err: errRefused}, &fakeTarget{}
+	rp := NewReadPool(primary, down, up)
+	ctx := context.Background()
+	for i := 0; i < 6; i++ {
+		if _, err := rp.Query(ctx, "SELECT 1"); err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+	}
+	// the failing replica is tried once, then skipped
+	if down.count() != 1 || primary.count() != 1 || up.count() != 5 {
+		t.Errorf("down %d, primary %d, up %d queries; want 1, 1 and 5", down.count(), primary.count(), up.count())
+	}
+	if status := rp.Status(); status["replica-1"] || !status["replica-2"] {
+		t.Errorf("status %v", status)
+	}
+
+	// every replica down: the primary takes the reads
+	up.set(errRefused, nil)
+	for i := 0; i < 3; i++ {
+		rp.Query(ctx, "SELECT 1")
+	}
+	if up.count() != 6 || primary.count() != 4 {
+		t.Errorf("up %d, primary %d queries; want 6 and 4", up.count(), primary.count())
+	}
+}
+
+func TestReadPoolReturnsQueryErrors(t *testing.T) {
+	syntax := &pgconn.PgError{Code: "42601", Message: "syntax error"}
+	primary, replica := &fakeTarget{}, &fakeTarget{err: syntax}
+	rp := NewReadPool(primary, replica)
+	if _, err := rp.Query(context.Background(), "SELEC 1"); err != syntax {
+		t.Errorf("err = %v, want the replica's error", err)
+	}
+	if primary.count() != 0 || !rp.Status()["replica-1"] {
+		t.Errorf("a query error failed over: %d primary queries, status %v", primary.count(), rp.Status())
+	}
+
+	// a cancelled request is not the replica's fault either
+	replica.set(context.Canceled, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rp.Query(ctx, "SELECT 1")
+	if primary.count() != 0 || !rp.Status()["replica-1"] {
+		t.Errorf("a cancelled query failed over: %d primary queries, status %v", primary.count(), rp.Status())
+	}
+}
+
+func TestReadPoolHealthCheck(t *testing.T) {
+	primary, replica := &fakeTarget{}, &fakeTarget{err: errRefused, pingErr: errRefused}
+	rp := NewReadPool(primary, replica)
+	ctx := context.Background()
+	rp.Query(ctx, "SELECT 1")
+	if rp.Status()["replica-1"] {
+		t.Fatal("unreachable replica still healthy")
+	}
+
+	rp.checkHealth(ctx, time.Second)
+	if rp.Status()["replica-1"] {
+		t.Fatal("replica failing pings came back")
+	}
+	replica.set(nil, nil)
+	rp.checkHealth(ctx, time.Second)
+	if !rp.Status()["replica-1"] {
+		t.Fatal("replica answering pings not brought back")
+	}
+	before := replica.count()
+	rp.Query(ctx, "SELECT 1")
+	if replica.count() != before+1 {
+		t.Error("recovered replica gets no reads")
+	}
+
+	replica.set(nil, errRefused)
+	rp.checkHealth(ctx, time.Second)
+	if rp.Status()["replica-1"] {
+		t.Error("replica failing pings kept healthy")
+	}
+}
+
+func TestReadPoolQueryRow(t *testing.T) {
+	ctx := context.Background()
+	rp := NewReadPool(&fakeTarget{}, &fakeTarget{rows: []int{42, 43}})
+	var n int
+	if err := rp.QueryRow(ctx, "SELECT n").Scan(&n); err != nil || n != 42 {
+		t.Errorf("Scan: %d, %v", n, err)
+	}
+	rp = NewReadPool(&fakeTarget{}, &fakeTarget{})
+	if err := rp.QueryRow(ctx, "SELECT n").Scan(&n); err != pgx.ErrNoRows {
+		t.Errorf("empty result: %v, want pgx.ErrNoRows", err)
+	}
+	failing := errors.New("boom")
+	rp = NewReadPool(&fakeTarget{err: failing})
+	if err := rp.QueryRow(ctx, "SELECT n").Scan(&n); err != failing {
+		t.Errorf("query error: %v", err)
+	}
+}
+
+func TestReplicaURLs(t *testing.T) {
+	tests := []struct {
+		urls, url string
+		want      int
+	}{
+		{"", "", 0},
+		{"", "postgres://replica/db", 1},
+		{"postgres://a/db, postgres://b/db ,", "postgres://ignored/db", 2},
+	}
+	for _, tt := range tests {
+		t.Setenv("DATABASE_REPLICA_URLS", tt.urls)
+		t.Setenv("DATABASE_REPLICA_URL", tt.url)
+		if got := replicaURLs(); len(got) != tt.want {
+			t.Errorf("DATABASE_REPLICA_URLS=%q DATABASE_REPLICA_URL=%q: %q", tt.urls, tt.url, got)
+		}
+	}
+	t.Setenv("DATABASE_REPLICA_URLS", " postgres://a/db ")
+	if got := replicaURLs(); got[0] != "postgres://a/db" {
+		t.Errorf("URL not trimmed: %q", got[0])
+	}
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// QueryStat aggregates executions of one query fingerprint
+type QueryStat struct {
+	Query  string  `json:"query"`
+	Count  int64   `json:"count"`
+	MeanMs float64 `json:"mean_ms"`
+	MaxMs  float64 `json:"max_ms"`
+	total  time.Duration
+	max    time.Duration
+}
+
+// QueryTracer records per-fingerprint timings. Enabled with DB_QUERY_TRACING=true
+// because every query pays for a mutex and a map lookup.
+type QueryTracer struct {
+	mu    sync.Mutex
+	stats map[string]*QueryStat
+}
+
+// maxTracedQueries bounds memory when queries are built with inlined values
+const maxTracedQueries = 1000
+
+type traceStartKey struct{}
+
+type traceStart struct {
+	sql     string
+	started time.Time
+}
+
+func NewQueryTracer() *QueryTracer {
+	return &QueryTracer{stats: make(map[string]*QueryStat)}
+}
+
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, traceStartKey{}, traceStart{sql: data.SQL, started: time.Now()})
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(traceStartKey{}).(traceStart)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(start.started)
+	fp := fingerprint(start.sql)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st, ok := t.stats[fp]
+	if !ok {
+		if len(t.stats) >= maxTracedQueries {
+			return
+		}
+		st = &QueryStat{Query: fp}
+		t.stats[fp] = st
+	}
+	st.Count++
+	st.total += elapsed
+	if elapsed > st.max {
+		st.max = elapsed
+	}
+}
+
+// Slowest returns the n fingerprints with the highest mean duration
+func (t *QueryTracer) Slowest(n int) []QueryStat {
+	t.mu.Lock()
+	list := make([]QueryStat, 0, len(t.stats))
+	for _, st := range t.stats {
+		s := *st
+		s.MeanMs = float64(st.total.Microseconds()) / 1000 / float64(st.Count)
+		s.MaxMs = float64(st.max.Microseconds()) / 1000
+		list = append(list, s)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].MeanMs > list[j].MeanMs })
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+var (
+	whitespaceRe = regexp.MustCompile(`\s+`)
+	stringLitRe  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numberLitRe  = regexp.MustCompile(`\b\d+(\.\d+)?\b`)
+	dsnSecretRe  = regexp.MustCompile(`(?i)(://[^:/@\s]+:)[^@\s]+@|(password\s*=\s*)\S+`)
+)
+
+// fingerprint normalizes whitespace and literals so the same statement groups together
+func fingerprint(sql string) string {
+	fp := stringLitRe.ReplaceAllString(sql, "?")
+	fp = numberLitRe.ReplaceAllString(fp, "?")
+	fp = strings.TrimSpace(whitespaceRe.ReplaceAllString(fp, " "))
+	if len(fp) > 500 {
+		fp = fp[:500]
+	}
+	return fp
+}
+
+// RedactSecrets masks passwords in connection strings embedded in text
+func RedactSecrets(s string) string {
+	return dsnSecretRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := dsnSecretRe.FindStringSubmatch(m)
+		if sub[1] != "" {
+			return sub[1] + "***@"
+		}
+		return sub[2] + "***"
+	})
+}
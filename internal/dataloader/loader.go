@@ -0,0 +1,113 @@
+// Package dataloader batches the per-object lookups of one GraphQL request. The
+// executor resolves the fields of list items concurrently, so a Loader collects
+// the keys requested within a short window and fetches them in one call.
+package dataloader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Wait is how long a Loader collects keys before fetching them; the items of a
+// list ask for their keys within microseconds of each other
+var Wait = 2 * time.Millisecond
+
+// FetchFunc loads the values of many keys at once; keys missing from the result
+// resolve to nil
+type FetchFunc func(ctx context.Context, keys []string) (map[string]any, error)
+
+// Loader batches lookups: the first Load of a batch starts the Wait window and
+// every key requested until it ends is fetched in one call. Results are cached
+// for the rest of the request.
+type Loader struct {
+	ctx   context.Context
+	fetch FetchFunc
+	mu    sync.Mutex
+	next  *batch
+	byKey map[string]*batch
+}
+
+type batch struct {
+	keys    []string
+	done    chan struct{}
+	results map[string]any
+	err     error
+}
+
+func newLoader(ctx context.Context, fetch FetchFunc) *Loader {
+	return &Loader{ctx: ctx, fetch: fetch, byKey: map[string]*batch{}}
+}
+
+type registryKey struct{}
+
+type registry struct {
+	mu      sync.Mutex
+	loaders map[string]*Loader
+}
+
+// WithLoaders returns a context whose loaders are shared by everything resolved
+// with it; the GraphQL handler installs one per request
+func WithLoaders(ctx context.Context) context.Context {
+	return context.WithValue(ctx, registryKey{}, &registry{loaders: map[string]*Loader{}})
+}
+
+// For returns the request's loader named name, creating it with fetch on first
+// use. Without WithLoaders every call gets a fresh loader.
+func For(ctx context.Context, name string, fetch FetchFunc) *Loader {
+	reg, ok := ctx.Value(registryKey{}).(*registry)
+	if !ok {
+		return newLoader(ctx, fetch)
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	l, ok := reg.loaders[name]
+	if !ok {
+		l = newLoader(ctx, fetch)
+		reg.loaders[name] = l
+	}
+	return l
+}
+
+// Load returns the value of key once its batch has been fetched
+func (l *Loader) Load(key string) (any, error) {
+	l.mu.Lock()
+	b, ok := l.byKey[key]
+	if !ok {
+		if l.next == nil {
+			l.next = &batch{done: make(chan struct{})}
+			time.AfterFunc(Wait, l.dispatch)
+		}
+		b = l.next
+		b.keys = append(b.keys, key)
+		l.byKey[key] = b
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-b.done:
+	case <-l.ctx.Done():
+		return nil, l.ctx.Err()
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.results[key], nil
+}
+
+// dispatch fetches the keys collected during the window that just ended. It runs
+// on its own goroutine, so a panicking fetch fails the batch instead of the process.
+func (l *Loader) dispatch() {
+	l.mu.Lock()
+	b := l.next
+	l.next = nil
+	l.mu.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			b.err = fmt.Errorf("loader fetch panicked: %v", r)
+		}
+		close(b.done)
+	}()
+	b.results, b.err = l.fetch(l.ctx, b.keys)
+}
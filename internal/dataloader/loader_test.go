@@ -0,0 +1,111 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// upperFetch resolves keys to their upper case, leaving "unknown" out
+func upperFetch(batches *[][]string, mu *sync.Mutex) FetchFunc {
+	return func(_ context.Context, keys []string) (map[string]any, error) {
+		mu.Lock()
+		*batches = append(*batches, append([]string(nil), keys...))
+		mu.Unlock()
+		out := map[string]any{}
+		for _, k := range keys {
+			if k != "unknown" {
+				out[k] = strings.ToUpper(k)
+			}
+		}
+		return out, nil
+	}
+}
+
+func TestLoaderBatchesConcurrentLoads(t *testing.T) {
+	// a wide window keeps the goroutines in one batch on a slow runner
+	defer func(wait time.Duration) { Wait = wait }(Wait)
+	Wait = 50 * time.Millisecond
+	var mu sync.Mutex
+	var batches [][]string
+	ctx := WithLoaders(context.Background())
+
+	keys := []string{"lg", "sony", "lg", "unknown", "samsung"}
+	got := make([]any, len(keys))
+	var wg sync.WaitGroup
+	for i, k := range keys {
+		wg.Add(1)
+		go func(i int, k string) {
+			defer wg.Done()
+			v, err := For(ctx, "brand", upperFetch(&batches, &mu)).Load(k)
+			if err != nil {
+				t.Error(err)
+			}
+			got[i] = v
+		}(i, k)
+	}
+	wg.Wait()
+
+	want := []any{"LG", "SONY", "LG", nil, "SAMSUNG"}
+	for i := range keys {
+		if got[i] != want[i] {
+			t.Errorf("Load(%q) = %v, want %v", keys[i], got[i], want[i])
+		}
+	}
+	if len(batches) != 1 || len(batches[0]) != 4 {
+		t.Fatalf("batches = %v, want one batch of the 4 distinct keys", batches)
+	}
+
+	// cached keys are not fetched again; new ones start the next batch
+	if v, _ := For(ctx, "brand", upperFetch(&batches, &mu)).Load("sony"); v != "SONY" || len(batches) != 1 {
+		t.Errorf("cached Load = %v after %d batches", v, len(batches))
+	}
+	if v, _ := For(ctx, "brand", upperFetch(&batches, &mu)).Load("philips"); v != "PHILIPS" || len(batches) != 2 {
+		t.Errorf("new key Load = %v after %d batches, want a second batch", v, len(batches))
+	}
+}
+
+func TestLoaderWithoutRegistryIsFresh(t *testing.T) {
+	var fetches int32
+	fetch := func(context.Context, []string) (map[string]any, error) {
+		atomic.AddInt32(&fetches, 1)
+		return nil, nil
+	}
+	For(context.Background(), "brand", fetch).Load("lg")
+	For(context.Background(), "brand", fetch).Load("lg")
+	if fetches != 2 {
+		t.Errorf("%d fetches, want 2: loaders outside WithLoaders must not share a cache", fetches)
+	}
+}
+
+func TestLoaderFetchFailures(t *testing.T) {
+	ctx := WithLoaders(context.Background())
+	_, err := For(ctx, "failing", func(context.Context, []string) (map[string]any, error) {
+		return nil, errors.New("database down")
+	}).Load("lg")
+	if err == nil || err.Error() != "database down" {
+		t.Errorf("failing fetch: err = %v", err)
+	}
+
+	_, err = For(ctx, "panicking", func(context.Context, []string) (map[string]any, error) {
+		panic("boom")
+	}).Load("lg")
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("panicking fetch: err = %v", err)
+	}
+}
+
+func TestLoaderCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(WithLoaders(context.Background()))
+	cancel()
+	_, err := For(ctx, "brand", func(context.Context, []string) (map[string]any, error) {
+		return map[string]any{"lg": "LG"}, nil
+	}).Load("lg")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
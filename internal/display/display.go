@@ -0,0 +1,172 @@
+// Package display formats prices, availability and shipping for storefront display, so
+// every endpoint that sends ?include_display=true strings words them the same way.
+// Slovak is the default locale; any other requested locale gets English.
+package display
+
+import (
+	"strconv"
+	"strings"
+
+	"megabuy-go/internal/money"
+)
+
+// Supported locales
+const (
+	LocaleSK      = "sk"
+	LocaleEN      = "en"
+	DefaultLocale = LocaleSK
+)
+
+// Product holds the display strings of a product
+type Product struct {
+	// Price is the lowest price; PriceMax is set when offers reach higher
+	Price    string `json:"price"`
+	PriceMax string `json:"price_max,omitempty"`
+	// WasPrice is the regular price while a promo lowers Price
+	WasPrice     string `json:"was_price,omitempty"`
+	Availability string `json:"availability"`
+}
+
+// Offer holds the display strings of a shop offer
+type Offer struct {
+	Price        string `json:"price"`
+	Shipping     string `json:"shipping"`
+	TotalPrice   string `json:"total_price"`
+	Availability string `json:"availability"`
+}
+
+// NormalizeLocale maps a ?locale= value such as "sk", "sk-SK" or "en_GB" to a supported
+// locale. Empty means DefaultLocale; Czech reads the Slovak strings and everything
+// else falls back to English.
+func NormalizeLocale(s string) string {
+	tag := strings.ToLower(strings.TrimSpace(s))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	switch tag {
+	case "":
+		return DefaultLocale
+	case LocaleSK, "cs":
+		return LocaleSK
+	}
+	return LocaleEN
+}
+
+// NewProduct formats a product; was is its regular price during a promo, else zero
+func NewProduct(locale string, priceMin, priceMax, was money.Price, stockStatus string) *Product {
+	p := &Product{Price: Price(priceMin, locale), Availability: Availability(stockStatus, "", locale)}
+	if priceMax > priceMin {
+		p.PriceMax = Price(priceMax, locale)
+	}
+	if was > priceMin {
+		p.WasPrice = Price(was, locale)
+	}
+	return p
+}
+
+// NewOffer formats an offer; deliveryDays is the shop's delivery time, e.g. "2-3"
+func NewOffer(locale string, price, shipping money.Price, stockStatus, deliveryDays string) *Offer {
+	return &Offer{
+		Price:        Price(price, locale),
+		Shipping:     Shipping(shipping, locale),
+		TotalPrice:   Price(price+shipping, locale),
+		Availability: Availability(stockStatus, deliveryDays, locale),
+	}
+}
+
+// Price formats an amount in euros: "1 299,90 €" in Slovak, "€1,299.90" in English
+func Price(p money.Price, locale string) string {
+	cents := p.Cents()
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	whole, frac := strconv.FormatInt(cents/100, 10), cents%100
+	if NormalizeLocale(locale) == LocaleSK {
+		return sign + groupThousands(whole, " ") + "," + twoDigits(frac) + " €"
+	}
+	return sign + "€" + groupThousands(whole, ",") + "." + twoDigits(frac)
+}
+
+func groupThousands(digits, sep string) string {
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+func twoDigits(n int64) string {
+	if n < 10 {
+		return "0" + strconv.FormatInt(n, 10)
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// Shipping formats a shipping price, naming free shipping
+func Shipping(p money.Price, locale string) string {
+	sk := NormalizeLocale(locale) == LocaleSK
+	switch {
+	case p.Cents() <= 0 && sk:
+		return "Doprava zdarma"
+	case p.Cents() <= 0:
+		return "Free shipping"
+	case sk:
+		return "Doprava " + Price(p, locale)
+	}
+	return "Shipping " + Price(p, locale)
+}
+
+// Availability words a stock status. In-stock goods with a delivery time of a day or
+// more read as delivered within its upper bound ("2-3" is "Do 3 dní"); without one,
+// or with "0", they are on stock.
+func Availability(stockStatus, deliveryDays, locale string) string {
+	sk := NormalizeLocale(locale) == LocaleSK
+	switch strings.ToLower(strings.TrimSpace(stockStatus)) {
+	case "outofstock", "out_of_stock":
+		if sk {
+			return "Vypredané"
+		}
+		return "Sold out"
+	case "preorder":
+		if sk {
+			return "Predobjednávka"
+		}
+		return "Pre-order"
+	}
+
+	days := maxDays(deliveryDays)
+	switch {
+	case days <= 0 && sk:
+		return "Skladom"
+	case days <= 0:
+		return "In stock"
+	case days == 1 && sk:
+		return "Do 1 dňa"
+	case days == 1:
+		return "Within 1 day"
+	case sk:
+		return "Do " + strconv.Itoa(days) + " dní"
+	}
+	return "Within " + strconv.Itoa(days) + " days"
+}
+
+// maxDays returns the largest number in a delivery time such as "3", "2-3" or "1 až 2
+// dni"; zero when there is none
+func maxDays(s string) int {
+	best, n, inNumber := 0, 0, false
+	for _, r := range s + " " {
+		if r >= '0' && r <= '9' {
+			n, inNumber = n*10+int(r-'0'), true
+			continue
+		}
+		if inNumber && n > best {
+			best = n
+		}
+		n, inNumber = 0, false
+	}
+	return best
+}
@@ -0,0 +1,125 @@
+package display
+
+import (
+	"testing"
+
+	"megabuy-go/internal/money"
+)
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := map[string]string{
+		"":      LocaleSK,
+		"sk":    LocaleSK,
+		" SK ":  LocaleSK,
+		"sk-SK": LocaleSK,
+		"cs_CZ": LocaleSK,
+		"en":    LocaleEN,
+		"en-GB": LocaleEN,
+		"de":    LocaleEN,
+		"xx-yy": LocaleEN,
+	}
+	for in, want := range tests {
+		if got := NormalizeLocale(in); got != want {
+			t.Errorf("NormalizeLocale(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPrice(t *testing.T) {
+	tests := []struct {
+		price  float64
+		sk, en string
+	}{
+		{1299.9, "1 299,90 €", "€1,299.90"},
+		{0, "0,00 €", "€0.00"},
+		{0.5, "0,50 €", "€0.50"},
+		{9.99, "9,99 €", "€9.99"},
+		{999.999, "1 000,00 €", "€1,000.00"},
+		{12345678.05, "12 345 678,05 €", "€12,345,678.05"},
+		{100000, "100 000,00 €", "€100,000.00"},
+		{-1299.9, "-1 299,90 €", "-€1,299.90"},
+		{-0.07, "-0,07 €", "-€0.07"},
+	}
+	for _, tt := range tests {
+		if got := Price(money.New(tt.price), "sk"); got != tt.sk {
+			t.Errorf("Price(%v, sk) = %q, want %q", tt.price, got, tt.sk)
+		}
+		if got := Price(money.New(tt.price), "en"); got != tt.en {
+			t.Errorf("Price(%v, en) = %q, want %q", tt.price, got, tt.en)
+		}
+	}
+}
+
+func TestShipping(t *testing.T) {
+	tests := []struct {
+		price  float64
+		sk, en string
+	}{
+		{0, "Doprava zdarma", "Free shipping"},
+		{0.001, "Doprava zdarma", "Free shipping"},
+		{3.9, "Doprava 3,90 €", "Shipping €3.90"},
+		{1250, "Doprava 1 250,00 €", "Shipping €1,250.00"},
+	}
+	for _, tt := range tests {
+		if got := Shipping(money.New(tt.price), "sk"); got != tt.sk {
+			t.Errorf("Shipping(%v, sk) = %q, want %q", tt.price, got, tt.sk)
+		}
+		if got := Shipping(money.New(tt.price), "en"); got != tt.en {
+			t.Errorf("Shipping(%v, en) = %q, want %q", tt.price, got, tt.en)
+		}
+	}
+}
+
+func TestAvailability(t *testing.T) {
+	tests := []struct {
+		status, days string
+		sk, en       string
+	}{
+		{"instock", "", "Skladom", "In stock"},
+		{"instock", "0", "Skladom", "In stock"},
+		{"", "", "Skladom", "In stock"},
+		{"instock", "1", "Do 1 dňa", "Within 1 day"},
+		{"instock", "2-3", "Do 3 dní", "Within 3 days"},
+		{"instock", "1 až 2 dni", "Do 2 dní", "Within 2 days"},
+		{"instock", "14", "Do 14 dní", "Within 14 days"},
+		{"instock", "na objednávku", "Skladom", "In stock"},
+		{"outofstock", "2", "Vypredané", "Sold out"},
+		{" OUT_OF_STOCK ", "", "Vypredané", "Sold out"},
+		{"preorder", "30", "Predobjednávka", "Pre-order"},
+	}
+	for _, tt := range tests {
+		if got := Availability(tt.status, tt.days, "sk"); got != tt.sk {
+			t.Errorf("Availability(%q, %q, sk) = %q, want %q", tt.status, tt.days, got, tt.sk)
+		}
+		if got := Availability(tt.status, tt.days, "en"); got != tt.en {
+			t.Errorf("Availability(%q, %q, en) = %q, want %q", tt.status, tt.days, got, tt.en)
+		}
+	}
+}
+
+func TestNewProduct(t *testing.T) {
+	p := NewProduct("sk", money.New(19.9), money.New(24.5), money.New(29.9), "instock")
+	want := Product{Price: "19,90 €", PriceMax: "24,50 €", WasPrice: "29,90 €", Availability: "Skladom"}
+	if *p != want {
+		t.Errorf("promo product %+v, want %+v", *p, want)
+	}
+
+	// a single price and no promo leave the optional strings empty
+	p = NewProduct("en", money.New(19.9), money.New(19.9), 0, "outofstock")
+	want = Product{Price: "€19.90", Availability: "Sold out"}
+	if *p != want {
+		t.Errorf("plain product %+v, want %+v", *p, want)
+	}
+}
+
+func TestNewOffer(t *testing.T) {
+	o := NewOffer("sk", money.New(1299.9), money.New(4.99), "instock", "2-3")
+	want := Offer{Price: "1 299,90 €", Shipping: "Doprava 4,99 €", TotalPrice: "1 304,89 €", Availability: "Do 3 dní"}
+	if *o != want {
+		t.Errorf("offer %+v, want %+v", *o, want)
+	}
+	o = NewOffer("en", money.New(0.1), money.New(0.2), "instock", "")
+	if o.TotalPrice != "€0.30" || o.Shipping != "Shipping €0.20" {
+		t.Errorf("offer %+v, want a total of €0.30", *o)
+	}
+}
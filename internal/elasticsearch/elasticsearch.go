@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"os"
 	"time"
+
+	"megabuy-go/internal/money"
 )
 
 type Client struct {
@@ -22,20 +24,54 @@ type Product struct {
 	Slug             string   `json:"slug"`
 	Description      string   `json:"description,omitempty"`
 	ShortDescription string   `json:"short_description,omitempty"`
+	// Excerpt is stored for result cards and not searched
+	Excerpt          string   `json:"excerpt,omitempty"`
 	EAN              string   `json:"ean,omitempty"`
 	SKU              string   `json:"sku,omitempty"`
+	MPN              string   `json:"mpn,omitempty"`
 	Brand            string   `json:"brand,omitempty"`
 	CategoryID       string   `json:"category_id,omitempty"`
 	CategoryName     string   `json:"category_name,omitempty"`
 	CategorySlug     string   `json:"category_slug,omitempty"`
 	ImageURL         string   `json:"image_url,omitempty"`
-	PriceMin         float64  `json:"price_min"`
-	PriceMax         float64  `json:"price_max"`
+	PriceMin         money.Price  `json:"price_min"`
+	PriceMax         money.Price  `json:"price_max"`
+	PriceMinNet      money.Price  `json:"price_min_net"`
+	PriceMaxNet      money.Price  `json:"price_max_net"`
+	VATRate          float64  `json:"vat_rate"`
 	StockStatus      string   `json:"stock_status"`
 	IsActive         bool     `json:"is_active"`
 	IsFeatured       bool     `json:"is_featured"`
 	Attributes       []Attr   `json:"attributes,omitempty"`
 	CreatedAt        string   `json:"created_at"`
+	// omitted when zero so the popularity, rating and discount sorts put them last
+	Popularity       int      `json:"popularity,omitempty"`
+	Rating           float64  `json:"rating,omitempty"`
+	DiscountPercent  int      `json:"discount_percent,omitempty"`
+	// Labels holds the slugs of the product's active badges
+	Labels           []string `json:"labels,omitempty"`
+	// PriceWas/PriceWasNet are the regular prices while a promo ending at PromoEndsAt runs
+	PriceWas         money.Price  `json:"price_was,omitempty"`
+	PriceWasNet      money.Price  `json:"price_was_net,omitempty"`
+	PromoEndsAt      string   `json:"promo_ends_at,omitempty"`
+	// ReleaseDate is the YYYY-MM-DD release of a preorder product
+	ReleaseDate      string   `json:"release_date,omitempty"`
+}
+
+// indexDocument encodes p for the index. The mapping types prices as floats, so they
+// are written as plain numbers even when money.JSONAsString has API responses quote them.
+func indexDocument(p Product) []byte {
+	type fields Product
+	doc, _ := json.Marshal(struct {
+		fields
+		PriceMin    float64 `json:"price_min"`
+		PriceMax    float64 `json:"price_max"`
+		PriceMinNet float64 `json:"price_min_net"`
+		PriceMaxNet float64 `json:"price_max_net"`
+		PriceWas    float64 `json:"price_was,omitempty"`
+		PriceWasNet float64 `json:"price_was_net,omitempty"`
+	}{fields(p), p.PriceMin.Float(), p.PriceMax.Float(), p.PriceMinNet.Float(), p.PriceMaxNet.Float(), p.PriceWas.Float(), p.PriceWasNet.Float()})
+	return doc
 }
 
 type Attr struct {
@@ -91,8 +127,10 @@ func (c *Client) CreateIndex() error {
 				"slug":              map[string]string{"type": "keyword"},
 				"description":       map[string]interface{}{"type": "text", "analyzer": "slovak_analyzer"},
 				"short_description": map[string]interface{}{"type": "text", "analyzer": "slovak_analyzer"},
+				"excerpt":           map[string]string{"type": "text", "index": "false"},
 				"ean":               map[string]string{"type": "keyword"},
 				"sku":               map[string]string{"type": "keyword"},
+				"mpn":               map[string]string{"type": "keyword"},
 				"brand":             map[string]interface{}{"type": "text", "fields": map[string]interface{}{"keyword": map[string]string{"type": "keyword"}}},
 				"category_id":       map[string]string{"type": "keyword"},
 				"category_name":     map[string]interface{}{"type": "text", "fields": map[string]interface{}{"keyword": map[string]string{"type": "keyword"}}},
@@ -100,6 +138,9 @@ func (c *Client) CreateIndex() error {
 				"image_url":         map[string]string{"type": "keyword", "index": "false"},
 				"price_min":         map[string]string{"type": "float"},
 				"price_max":         map[string]string{"type": "float"},
+				"price_min_net":     map[string]string{"type": "float"},
+				"price_max_net":     map[string]string{"type": "float"},
+				"vat_rate":          map[string]string{"type": "float"},
 				"stock_status":      map[string]string{"type": "keyword"},
 				"is_active":         map[string]string{"type": "boolean"},
 				"is_featured":       map[string]string{"type": "boolean"},
@@ -110,7 +151,15 @@ func (c *Client) CreateIndex() error {
 						"value": map[string]string{"type": "keyword"},
 					},
 				},
-				"created_at": map[string]string{"type": "date"},
+				"created_at":       map[string]string{"type": "date"},
+				"popularity":       map[string]string{"type": "integer"},
+				"rating":           map[string]string{"type": "float"},
+				"discount_percent": map[string]string{"type": "integer"},
+				"labels":           map[string]string{"type": "keyword"},
+				"price_was":        map[string]string{"type": "float", "index": "false"},
+				"price_was_net":    map[string]string{"type": "float", "index": "false"},
+				"promo_ends_at":    map[string]string{"type": "date"},
+				"release_date":     map[string]string{"type": "date"},
 			},
 		},
 	}
@@ -135,7 +184,7 @@ func (c *Client) CreateIndex() error {
 
 // IndexProduct indexes a single product
 func (c *Client) IndexProduct(product Product) error {
-	body, _ := json.Marshal(product)
+	body := indexDocument(product)
 	req, _ := http.NewRequest("PUT", fmt.Sprintf("%s/products/_doc/%s", c.baseURL, product.ID), bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 
@@ -158,8 +207,7 @@ func (c *Client) BulkIndex(products []Product) error {
 	for _, p := range products {
 		meta := fmt.Sprintf(`{"index":{"_index":"products","_id":"%s"}}`, p.ID)
 		buf.WriteString(meta + "\n")
-		doc, _ := json.Marshal(p)
-		buf.Write(doc)
+		buf.Write(indexDocument(p))
 		buf.WriteString("\n")
 	}
 
@@ -172,7 +220,68 @@ func (c *Client) BulkIndex(products []Product) error {
 	}
 	defer resp.Body.Close()
 
-	return nil
+	return bulkError(resp)
+}
+
+// bulkError reports a rejected bulk request, or the first item ES failed to write
+func bulkError(resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("bulk request failed: %s: %.200s", resp.Status, body)
+	}
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string          `json:"_id"`
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if json.Unmarshal(body, &result) != nil || !result.Errors {
+		return nil
+	}
+	failed := 0
+	var first string
+	for _, item := range result.Items {
+		for _, r := range item {
+			if len(r.Error) > 0 {
+				if failed == 0 {
+					first = fmt.Sprintf("%s: %.200s", r.ID, r.Error)
+				}
+				failed++
+			}
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("bulk request failed for %d of %d items, first %s", failed, len(result.Items), first)
+}
+
+// BulkDelete removes multiple products from the index in one request
+func (c *Client) BulkDelete(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, id := range ids {
+		buf.WriteString(fmt.Sprintf(`{"delete":{"_index":"products","_id":"%s"}}`, id) + "\n")
+	}
+
+	req, _ := http.NewRequest("POST", c.baseURL+"/_bulk", &buf)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return bulkError(resp)
 }
 
 // Search performs a search with filters and facets
@@ -227,14 +336,36 @@ func (c *Client) Search(ctx context.Context, params SearchParams) (*SearchResult
 
 type SearchParams struct {
 	Query      string   `json:"q"`
-	CategoryID string   `json:"category_id"`
-	Brand      string   `json:"brand"`
+	// Values within one dimension are ORed; the Exclude lists become must_not clauses
+	CategoryIDs        []string `json:"category_ids,omitempty"`
+	Brands             []string `json:"brands,omitempty"`
+	ExcludeCategoryIDs []string `json:"exclude_category_ids,omitempty"`
+	ExcludeBrands      []string `json:"exclude_brands,omitempty"`
+	Labels             []string `json:"labels,omitempty"`
 	PriceMin   float64  `json:"price_min"`
 	PriceMax   float64  `json:"price_max"`
 	InStock    bool     `json:"in_stock"`
-	Sort       string   `json:"sort"` // price_asc, price_desc, newest, relevance
+	Sort       string   `json:"sort"` // price_asc, price_desc, newest, relevance, popularity, discount, rating
 	Page       int      `json:"page"`
 	Limit      int      `json:"limit"`
+	// Ranking overrides DefaultRanking; Variant names it for A/B comparisons
+	Ranking *RankingConfig `json:"ranking,omitempty"`
+	Variant string         `json:"variant,omitempty"`
+	// Out-of-stock products of these categories are left out or ranked after the rest
+	HideOutOfStockCategoryIDs   []string `json:"hide_out_of_stock_category_ids,omitempty"`
+	DemoteOutOfStockCategoryIDs []string `json:"demote_out_of_stock_category_ids,omitempty"`
+}
+
+// outOfStockIn matches out-of-stock products of the categories
+func outOfStockIn(categoryIDs []string) map[string]interface{} {
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter": []map[string]interface{}{
+				{"term": map[string]string{"stock_status": "outofstock"}},
+				{"terms": map[string][]string{"category_id": categoryIDs}},
+			},
+		},
+	}
 }
 
 func (c *Client) buildQuery(params SearchParams) map[string]interface{} {
@@ -253,27 +384,49 @@ func (c *Client) buildQuery(params SearchParams) map[string]interface{} {
 		{"term": map[string]bool{"is_active": true}},
 	}
 
+	ranking := DefaultRanking()
+	if params.Ranking != nil {
+		ranking = *params.Ranking
+	}
+
 	// Full-text search
 	if params.Query != "" {
 		must = append(must, map[string]interface{}{
 			"multi_match": map[string]interface{}{
 				"query":  params.Query,
-				"fields": []string{"title^3", "description", "short_description", "brand^2", "ean^4", "sku^4"},
+				"fields": ranking.fields(),
 				"type":   "best_fields",
-				"fuzziness": "AUTO",
+				"fuzziness": ranking.Fuzziness,
+				"prefix_length": ranking.PrefixLength,
 			},
 		})
 	}
 
 	// Filters
-	if params.CategoryID != "" {
+	if len(params.CategoryIDs) > 0 {
+		filter = append(filter, map[string]interface{}{
+			"terms": map[string][]string{"category_id": params.CategoryIDs},
+		})
+	}
+	if len(params.Brands) > 0 {
 		filter = append(filter, map[string]interface{}{
-			"term": map[string]string{"category_id": params.CategoryID},
+			"terms": map[string][]string{"brand.keyword": params.Brands},
 		})
 	}
-	if params.Brand != "" {
+	if len(params.Labels) > 0 {
 		filter = append(filter, map[string]interface{}{
-			"term": map[string]string{"brand.keyword": params.Brand},
+			"terms": map[string][]string{"labels": params.Labels},
+		})
+	}
+	mustNot := []map[string]interface{}{}
+	if len(params.ExcludeCategoryIDs) > 0 {
+		mustNot = append(mustNot, map[string]interface{}{
+			"terms": map[string][]string{"category_id": params.ExcludeCategoryIDs},
+		})
+	}
+	if len(params.ExcludeBrands) > 0 {
+		mustNot = append(mustNot, map[string]interface{}{
+			"terms": map[string][]string{"brand.keyword": params.ExcludeBrands},
 		})
 	}
 	if params.PriceMin > 0 {
@@ -291,6 +444,9 @@ func (c *Client) buildQuery(params SearchParams) map[string]interface{} {
 			"term": map[string]string{"stock_status": "instock"},
 		})
 	}
+	if len(params.HideOutOfStockCategoryIDs) > 0 {
+		mustNot = append(mustNot, outOfStockIn(params.HideOutOfStockCategoryIDs))
+	}
 
 	// Sorting
 	sort := []map[string]interface{}{}
@@ -301,6 +457,11 @@ func (c *Client) buildQuery(params SearchParams) map[string]interface{} {
 		sort = append(sort, map[string]interface{}{"price_min": "desc"})
 	case "newest":
 		sort = append(sort, map[string]interface{}{"created_at": "desc"})
+	case "popularity", "discount", "rating":
+		field := map[string]string{"popularity": "popularity", "discount": "discount_percent", "rating": "rating"}[params.Sort]
+		sort = append(sort,
+			map[string]interface{}{field: map[string]string{"order": "desc", "missing": "_last"}},
+			map[string]interface{}{"created_at": "desc"})
 	default:
 		if params.Query != "" {
 			sort = append(sort, map[string]interface{}{"_score": "desc"})
@@ -309,15 +470,51 @@ func (c *Client) buildQuery(params SearchParams) map[string]interface{} {
 		}
 	}
 
+	boolQuery := map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must":     must,
+			"filter":   filter,
+			"must_not": mustNot,
+		},
+	}
+	// ranking functions add to the text score; without a query there is nothing to rank
+	if functions := ranking.functions(); params.Query != "" && len(functions) > 0 {
+		boolQuery = map[string]interface{}{
+			"function_score": map[string]interface{}{
+				"query":      boolQuery,
+				"functions":  functions,
+				"score_mode": "sum",
+				"boost_mode": "sum",
+			},
+		}
+	}
+	if demoted := params.DemoteOutOfStockCategoryIDs; len(demoted) > 0 {
+		if sort[0]["_score"] != nil {
+			// scores cannot go negative, so demoted products keep a sliver of theirs
+			boolQuery = map[string]interface{}{
+				"function_score": map[string]interface{}{
+					"query":      boolQuery,
+					"functions":  []map[string]interface{}{{"filter": outOfStockIn(demoted), "weight": 0.0001}},
+					"boost_mode": "multiply",
+				},
+			}
+		} else {
+			// field sorts ignore scores; a script key sorts demoted products last
+			sort = append([]map[string]interface{}{{"_script": map[string]interface{}{
+				"type":  "number",
+				"order": "asc",
+				"script": map[string]interface{}{
+					"source": "doc['stock_status'].size() > 0 && doc['stock_status'].value == 'outofstock' && doc['category_id'].size() > 0 && params.ids.contains(doc['category_id'].value) ? 1 : 0",
+					"params": map[string]interface{}{"ids": demoted},
+				},
+			}}}, sort...)
+		}
+	}
+
 	query := map[string]interface{}{
 		"from": from,
 		"size": params.Limit,
-		"query": map[string]interface{}{
-			"bool": map[string]interface{}{
-				"must":   must,
-				"filter": filter,
-			},
-		},
+		"query": boolQuery,
 		"sort": sort,
 		"aggs": map[string]interface{}{
 			"categories": map[string]interface{}{
@@ -0,0 +1,72 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"megabuy-go/internal/money"
+	"megabuy-go/internal/testutil"
+)
+
+func TestIndexedPricesAreNumbers(t *testing.T) {
+	es := testutil.NewFakeES()
+	defer es.Close()
+	c := &Client{baseURL: es.URL, httpClient: http.DefaultClient}
+
+	money.JSONAsString = true
+	defer func() { money.JSONAsString = false }()
+
+	promo := Product{ID: "p1", Title: "Kávovar", PriceMin: money.New(19.99), PriceMax: money.New(24.5), PriceMinNet: money.New(16.66), PriceMaxNet: money.New(20.42), PriceWas: money.New(29.99), PriceWasNet: money.New(24.99)}
+	plain := Product{ID: "p2", Title: "Mlynček", PriceMin: money.New(5), PriceMax: money.New(5)}
+	if err := c.BulkIndex([]Product{promo}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.IndexProduct(plain); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id   string
+		want map[string]float64
+	}{
+		{"p1", map[string]float64{"price_min": 19.99, "price_max": 24.5, "price_min_net": 16.66, "price_max_net": 20.42, "price_was": 29.99, "price_was_net": 24.99}},
+		{"p2", map[string]float64{"price_min": 5, "price_max": 5, "price_min_net": 0, "price_max_net": 0}},
+	}
+	for _, tt := range tests {
+		raw, ok := es.Doc(tt.id)
+		if !ok {
+			t.Fatalf("%s not indexed", tt.id)
+		}
+		var doc map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			t.Fatal(err)
+		}
+		for field, want := range tt.want {
+			n, ok := doc[field].(json.Number)
+			if !ok {
+				t.Errorf("%s: %s = %#v, want the number %v", tt.id, field, doc[field], want)
+				continue
+			}
+			if got, _ := n.Float64(); got != want {
+				t.Errorf("%s: %s = %v, want %v", tt.id, field, got, want)
+			}
+		}
+		if _, ok := doc["price_was"]; ok && tt.want["price_was"] == 0 {
+			t.Errorf("%s: price_was indexed without a promo", tt.id)
+		}
+		if doc["title"] == nil || doc["id"] != tt.id {
+			t.Errorf("%s: other fields lost: %s", tt.id, raw)
+		}
+	}
+
+	// documents read back from the index decode into Product whatever the format
+	var back Product
+	raw, _ := es.Doc("p1")
+	if err := json.Unmarshal(raw, &back); err != nil || back.PriceMin != promo.PriceMin || back.PriceWasNet != promo.PriceWasNet {
+		t.Errorf("decoded %+v (%v), want the prices of %+v", back, err, promo)
+	}
+}
@@ -0,0 +1,115 @@
+package elasticsearch
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// RankingConfig holds the tunable relevance parameters of full-text search
+type RankingConfig struct {
+	// FieldBoosts weighs the searched fields; a field missing here is not searched
+	FieldBoosts map[string]float64 `json:"field_boosts"`
+	// Fuzziness is "AUTO", "0", "1" or "2"
+	Fuzziness string `json:"fuzziness"`
+	// PrefixLength is the number of leading characters that must match exactly
+	PrefixLength int `json:"prefix_length"`
+	// Function score weights; zero disables a function
+	PopularityWeight float64 `json:"popularity_weight"`
+	RatingWeight     float64 `json:"rating_weight"`
+	InStockWeight    float64 `json:"in_stock_weight"`
+	FeaturedWeight   float64 `json:"featured_weight"`
+}
+
+// SearchableFields lists the fields a RankingConfig may boost
+var SearchableFields = []string{"title", "description", "short_description", "brand", "category_name", "ean", "sku", "mpn"}
+
+// DefaultRanking is the ranking used before any config is stored
+func DefaultRanking() RankingConfig {
+	return RankingConfig{
+		FieldBoosts: map[string]float64{
+			"title": 3, "description": 1, "short_description": 1, "brand": 2, "ean": 4, "sku": 4, "mpn": 4,
+		},
+		Fuzziness: "AUTO",
+	}
+}
+
+// Validate reports the first invalid parameter
+func (r RankingConfig) Validate() error {
+	if len(r.FieldBoosts) == 0 {
+		return fmt.Errorf("field_boosts must name at least one field")
+	}
+	for field, boost := range r.FieldBoosts {
+		known := false
+		for _, f := range SearchableFields {
+			known = known || f == field
+		}
+		if !known {
+			return fmt.Errorf("field_boosts: unknown field %q", field)
+		}
+		if boost <= 0 || boost > 100 || math.IsNaN(boost) {
+			return fmt.Errorf("field_boosts: %s must be between 0 and 100", field)
+		}
+	}
+	switch r.Fuzziness {
+	case "AUTO", "0", "1", "2":
+	default:
+		return fmt.Errorf("fuzziness must be AUTO, 0, 1 or 2")
+	}
+	if r.PrefixLength < 0 || r.PrefixLength > 10 {
+		return fmt.Errorf("prefix_length must be between 0 and 10")
+	}
+	for name, w := range map[string]float64{
+		"popularity_weight": r.PopularityWeight, "rating_weight": r.RatingWeight,
+		"in_stock_weight": r.InStockWeight, "featured_weight": r.FeaturedWeight,
+	} {
+		if w < 0 || w > 100 || math.IsNaN(w) {
+			return fmt.Errorf("%s must be between 0 and 100", name)
+		}
+	}
+	return nil
+}
+
+// fields returns the multi_match field list, e.g. title^3, in a stable order
+func (r RankingConfig) fields() []string {
+	fields := make([]string, 0, len(r.FieldBoosts))
+	for field, boost := range r.FieldBoosts {
+		if boost == 1 {
+			fields = append(fields, field)
+		} else {
+			fields = append(fields, fmt.Sprintf("%s^%g", field, boost))
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// functions returns the function_score functions of the enabled weights
+func (r RankingConfig) functions() []map[string]interface{} {
+	var functions []map[string]interface{}
+	if r.PopularityWeight > 0 {
+		functions = append(functions, map[string]interface{}{
+			"field_value_factor": map[string]interface{}{"field": "popularity", "modifier": "log1p", "missing": 0},
+			"weight":             r.PopularityWeight,
+		})
+	}
+	if r.RatingWeight > 0 {
+		functions = append(functions, map[string]interface{}{
+			"field_value_factor": map[string]interface{}{"field": "rating", "missing": 0},
+			"weight":             r.RatingWeight,
+		})
+	}
+	if r.InStockWeight > 0 {
+		functions = append(functions, map[string]interface{}{
+			"filter": map[string]interface{}{"term": map[string]string{"stock_status": "instock"}},
+			"weight": r.InStockWeight,
+		})
+	}
+	if r.FeaturedWeight > 0 {
+		functions = append(functions, map[string]interface{}{
+			"filter": map[string]interface{}{"term": map[string]bool{"is_featured": true}},
+			"weight": r.FeaturedWeight,
+		})
+	}
+	return functions
+}
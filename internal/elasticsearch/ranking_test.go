@@ -0,0 +1,98 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRankingValidate(t *testing.T) {
+	if err := DefaultRanking().Validate(); err != nil {
+		t.Fatalf("default ranking: %v", err)
+	}
+	tests := []struct {
+		name   string
+		modify func(*RankingConfig)
+		want   string
+	}{
+		{"no fields", func(r *RankingConfig) { r.FieldBoosts = nil }, "at least one field"},
+		{"unknown field", func(r *RankingConfig) { r.FieldBoosts["price"] = 2 }, `unknown field "price"`},
+		{"zero boost", func(r *RankingConfig) { r.FieldBoosts["title"] = 0 }, "title must be between 0 and 100"},
+		{"huge boost", func(r *RankingConfig) { r.FieldBoosts["brand"] = 101 }, "brand must be between"},
+		{"NaN boost", func(r *RankingConfig) { r.FieldBoosts["ean"] = math.NaN() }, "ean must be between"},
+		{"fuzziness", func(r *RankingConfig) { r.Fuzziness = "3" }, "fuzziness"},
+		{"empty fuzziness", func(r *RankingConfig) { r.Fuzziness = "" }, "fuzziness"},
+		{"prefix length", func(r *RankingConfig) { r.PrefixLength = 11 }, "prefix_length"},
+		{"negative prefix", func(r *RankingConfig) { r.PrefixLength = -1 }, "prefix_length"},
+		{"negative weight", func(r *RankingConfig) { r.RatingWeight = -1 }, "rating_weight"},
+		{"NaN weight", func(r *RankingConfig) { r.PopularityWeight = math.NaN() }, "popularity_weight"},
+		{"huge weight", func(r *RankingConfig) { r.FeaturedWeight = 1000 }, "featured_weight"},
+	}
+	for _, tt := range tests {
+		r := DefaultRanking()
+		tt.modify(&r)
+		if err := r.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRankingFields(t *testing.T) {
+	want := []string{"brand^2", "description", "ean^4", "mpn^4", "short_description", "sku^4", "title^3"}
+	// map order must not leak into the query
+	for i := 0; i < 20; i++ {
+		if got := DefaultRanking().fields(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("fields %v, want %v", got, want)
+		}
+	}
+	r := RankingConfig{FieldBoosts: map[string]float64{"title": 2.5, "category_name": 1}}
+	if got := r.fields(); !reflect.DeepEqual(got, []string{"category_name", "title^2.5"}) {
+		t.Errorf("fields %v", got)
+	}
+}
+
+func TestRankingFunctions(t *testing.T) {
+	if f := DefaultRanking().functions(); len(f) != 0 {
+		t.Errorf("default ranking has functions %v", f)
+	}
+	r := DefaultRanking()
+	r.PopularityWeight, r.InStockWeight = 1.5, 3
+	f := r.functions()
+	if len(f) != 2 || f[0]["weight"] != 1.5 || f[1]["weight"] != 3.0 {
+		t.Fatalf("functions %v", f)
+	}
+	if _, ok := f[0]["field_value_factor"]; !ok {
+		t.Errorf("popularity is not a field value factor: %v", f[0])
+	}
+}
+
+func TestSearchQueryUsesRanking(t *testing.T) {
+	ranking := RankingConfig{
+		FieldBoosts:   map[string]float64{"title": 5, "brand": 1},
+		Fuzziness:     "1",
+		PrefixLength:  2,
+		RatingWeight:  2,
+		InStockWeight: 4,
+	}
+	query := (&Client{}).buildQuery(SearchParams{Query: "kávovar", Ranking: &ranking})
+	raw, _ := json.Marshal(query)
+	body := string(raw)
+	for _, want := range []string{`"fields":["brand","title^5"]`, `"fuzziness":"1"`, `"prefix_length":2`, `"function_score"`, `"field":"rating"`, `"stock_status":"instock"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("query lacks %s: %s", want, body)
+		}
+	}
+
+	// without a query there is no text score to add the functions to
+	raw, _ = json.Marshal((&Client{}).buildQuery(SearchParams{Ranking: &ranking}))
+	if strings.Contains(string(raw), `"function_score"`) {
+		t.Errorf("browse query is function scored: %s", raw)
+	}
+	// no ranking uses the defaults
+	raw, _ = json.Marshal((&Client{}).buildQuery(SearchParams{Query: "kávovar"}))
+	if !strings.Contains(string(raw), `"title^3"`) || strings.Contains(string(raw), `"function_score"`) {
+		t.Errorf("default query %s", raw)
+	}
+}
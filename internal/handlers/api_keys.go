@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== API KEYS ==========
+
+// apiKeyScopes are the scopes a key can be granted
+var apiKeyScopes = map[string]bool{
+	"inventory:write": true,
+}
+
+// apiKeyPrefixLength is how much of a key is kept in clear to tell keys apart
+const apiKeyPrefixLength = 12
+
+// apiKeyLocal is the fiber.Ctx local holding the name of the key that authenticated
+// the request, read by audit as the actor
+const apiKeyLocal = "api_key_name"
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// requestAPIKey reads the key from "Authorization: Bearer ..." or X-API-Key
+func requestAPIKey(c *fiber.Ctx) string {
+	if auth := c.Get(fiber.HeaderAuthorization); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return strings.TrimSpace(c.Get("X-API-Key"))
+}
+
+// RequireAPIKey admits requests carrying an unrevoked key granted scope
+func (h *Handlers) RequireAPIKey(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := requestAPIKey(c)
+		if key == "" {
+			return c.Status(401).JSON(fiber.Map{"success": false, "error": "API key required"})
+		}
+		ctx := context.Background()
+		var id, name string
+		var scopes []string
+		err := h.db.Pool.QueryRow(ctx, `
+			SELECT id::text, name, scopes FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL
+		`, hashAPIKey(key)).Scan(&id, &name, &scopes)
+		if err != nil {
+			return c.Status(401).JSON(fiber.Map{"success": false, "error": "Invalid API key"})
+		}
+		granted := false
+		for _, s := range scopes {
+			if s == scope {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			return c.Status(403).JSON(fiber.Map{"success": false, "error": "API key lacks scope " + scope})
+		}
+		h.db.Pool.Exec(ctx, "UPDATE api_keys SET last_used_at = NOW() WHERE id = $1::uuid", id)
+		c.Locals(apiKeyLocal, name)
+		return c.Next()
+	}
+}
+
+// AdminListAPIKeys lists keys without their secrets
+func (h *Handlers) AdminListAPIKeys(c *fiber.Ctx) error {
+	ctx := context.Background()
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT id::text, name, key_prefix, scopes, created_at, last_used_at, revoked_at
+		FROM api_keys ORDER BY created_at DESC
+	`)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+	keys := []fiber.Map{}
+	for rows.Next() {
+		var id, name, prefix string
+		var scopes []string
+		var createdAt time.Time
+		var lastUsedAt, revokedAt *time.Time
+		rows.Scan(&id, &name, &prefix, &scopes, &createdAt, &lastUsedAt, &revokedAt)
+		keys = append(keys, fiber.Map{
+			"id": id, "name": name, "key_prefix": prefix, "scopes": nonNilStrings(scopes),
+			"created_at": createdAt, "last_used_at": lastUsedAt, "revoked_at": revokedAt,
+		})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": keys})
+}
+
+// AdminCreateAPIKey issues a key; the secret is returned only in this response
+func (h *Handlers) AdminCreateAPIKey(c *fiber.Ctx) error {
+	var input struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > 100 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "name must be 1 to 100 characters"})
+	}
+	if len(input.Scopes) == 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "scopes is required"})
+	}
+	for _, s := range input.Scopes {
+		if !apiKeyScopes[s] {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "Unknown scope: " + s})
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	key := "mb_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	ctx := context.Background()
+	var id string
+	err := h.db.Pool.QueryRow(ctx, `
+		INSERT INTO api_keys (name, key_prefix, key_hash, scopes, created_at) VALUES ($1, $2, $3, $4, NOW()) RETURNING id::text
+	`, input.Name, key[:apiKeyPrefixLength], hashAPIKey(key), input.Scopes).Scan(&id)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	h.audit(ctx, c, "api_key.create", "api_key", id, fiber.Map{"name": input.Name, "scopes": input.Scopes})
+	return c.Status(201).JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"id": id, "name": input.Name, "scopes": input.Scopes, "key": key,
+	}})
+}
+
+// AdminRevokeAPIKey disables a key immediately
+func (h *Handlers) AdminRevokeAPIKey(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx := context.Background()
+	tag, err := h.db.Pool.Exec(ctx, "UPDATE api_keys SET revoked_at = NOW() WHERE id = $1::uuid AND revoked_at IS NULL", id)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "API key not found"})
+	}
+	h.audit(ctx, c, "api_key.revoke", "api_key", id, nil)
+	return c.JSON(fiber.Map{"success": true, "message": "API key revoked"})
+}
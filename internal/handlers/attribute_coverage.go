@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== FEED ATTRIBUTE COVERAGE ==========
+
+// CategoryCoverage is how many of a feed's products in one category carry an attribute
+type CategoryCoverage struct {
+	CategoryID   string  `json:"category_id"`
+	CategoryName string  `json:"category_name"`
+	ProductCount int     `json:"product_count"`
+	Products     int     `json:"products"`
+	Coverage     float64 `json:"coverage"`
+}
+
+// AttributeCoverage is how many of a feed's products carry an attribute, overall and
+// per target category. Coverage is a percentage with one decimal.
+type AttributeCoverage struct {
+	Name         string             `json:"name"`
+	Slug         string             `json:"slug"`
+	ProductCount int                `json:"product_count"`
+	Coverage     float64            `json:"coverage"`
+	Categories   []CategoryCoverage `json:"categories"`
+}
+
+func coveragePercent(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(count)*1000/float64(total)) / 10
+}
+
+// feedAttributeCoverage counts, per attribute of the feed's active products, the
+// products carrying it in each category next to the feed's products in that category.
+// Attributes come most covered first; categories within one by products carrying it.
+func (h *Handlers) feedAttributeCoverage(ctx context.Context, feedID string) ([]AttributeCoverage, int, error) {
+	var total int
+	if err := h.db.ReadPool.QueryRow(ctx, "SELECT COUNT(*) FROM products WHERE feed_id = $1::uuid AND is_active = true", feedID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := h.db.ReadPool.Query(ctx, `
+		WITH feed_products AS (
+			SELECT id, category_id FROM products WHERE feed_id = $1::uuid AND is_active = true
+		), category_totals AS (
+			SELECT category_id, COUNT(*) AS products FROM feed_products GROUP BY category_id
+		), carried AS (
+			SELECT DISTINCT pa.name, fp.id, fp.category_id
+			FROM product_attributes pa JOIN feed_products fp ON fp.id = pa.product_id
+			WHERE COALESCE(pa.name,'') <> ''
+		)
+		SELECT a.name, COALESCE(a.category_id::text,''), COALESCE(c.name,''), COUNT(*), t.products,
+		       SUM(COUNT(*)) OVER (PARTITION BY a.name) AS attribute_products
+		FROM carried a
+		JOIN category_totals t ON t.category_id IS NOT DISTINCT FROM a.category_id
+		LEFT JOIN categories c ON c.id = a.category_id
+		GROUP BY a.name, a.category_id, c.name, t.products
+		ORDER BY attribute_products DESC, a.name, COUNT(*) DESC, c.name
+	`, feedID)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	attributes := []AttributeCoverage{}
+	for rows.Next() {
+		var name string
+		var cat CategoryCoverage
+		var attributeProducts int
+		if err := rows.Scan(&name, &cat.CategoryID, &cat.CategoryName, &cat.ProductCount, &cat.Products, &attributeProducts); err != nil {
+			return nil, 0, err
+		}
+		cat.Coverage = coveragePercent(cat.ProductCount, cat.Products)
+		if n := len(attributes); n == 0 || attributes[n-1].Name != name {
+			attributes = append(attributes, AttributeCoverage{
+				Name: name, Slug: makeSlug(name), ProductCount: attributeProducts,
+				Coverage: coveragePercent(attributeProducts, total), Categories: []CategoryCoverage{},
+			})
+		}
+		last := &attributes[len(attributes)-1]
+		last.Categories = append(last.Categories, cat)
+	}
+	return attributes, total, rows.Err()
+}
+
+// GetFeedAttributeCoverage lists the attributes of a feed's active products with the
+// share of the feed's products carrying each, overall and per target category, to judge
+// which filters a category can support. ?min_coverage= (percent) hides attributes below
+// it; ?format=csv exports one row per attribute and category.
+func (h *Handlers) GetFeedAttributeCoverage(c *fiber.Ctx) error {
+	feedID := c.Params("id")
+	minCoverage := c.QueryFloat("min_coverage", 0)
+	if minCoverage < 0 || minCoverage > 100 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "min_coverage must be between 0 and 100"})
+	}
+
+	ctx := context.Background()
+	var feedName string
+	if err := h.db.Pool.QueryRow(ctx, "SELECT name FROM feeds WHERE id = $1::uuid", feedID).Scan(&feedName); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Feed not found"})
+	}
+	coverage, total, err := h.feedAttributeCoverage(ctx, feedID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	attributes := []AttributeCoverage{}
+	hidden := 0
+	for _, a := range coverage {
+		if a.Coverage < minCoverage {
+			hidden++
+			continue
+		}
+		attributes = append(attributes, a)
+	}
+
+	if wantsCSV(c) {
+		var rows [][]string
+		for _, a := range attributes {
+			for _, cat := range a.Categories {
+				rows = append(rows, []string{
+					a.Name, cat.CategoryID, cat.CategoryName, strconv.Itoa(cat.ProductCount), strconv.Itoa(cat.Products),
+					strconv.FormatFloat(cat.Coverage, 'f', 1, 64), strconv.FormatFloat(a.Coverage, 'f', 1, 64),
+				})
+			}
+		}
+		return sendCSV(c, "attribute-coverage", []string{"attribute", "category_id", "category_name", "products_with_attribute", "category_products", "category_coverage", "feed_coverage"}, rows)
+	}
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"feed_id": feedID, "feed_name": feedName, "product_count": total,
+		"attributes": attributes, "hidden": hidden, "min_coverage": minCoverage,
+	}})
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== ATTRIBUTE CLEANUP ==========
+
+// attributeBlacklist matches PARAM names case-insensitively, by name or by slug
+type attributeBlacklist map[string]bool
+
+func newAttributeBlacklist(entries []string) attributeBlacklist {
+	bl := attributeBlacklist{}
+	for _, e := range entries {
+		if e = strings.TrimSpace(e); e != "" {
+			bl[strings.ToLower(e)] = true
+			bl[makeSlug(e)] = true
+		}
+	}
+	return bl
+}
+
+func (bl attributeBlacklist) has(name string) bool {
+	return bl[strings.ToLower(strings.TrimSpace(name))] || bl[makeSlug(name)]
+}
+
+func filterBlacklistedParams(params []map[string]string, bl attributeBlacklist) []map[string]string {
+	if len(bl) == 0 {
+		return params
+	}
+	kept := params[:0:0]
+	for _, p := range params {
+		if !bl.has(p["name"]) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// AdminDeleteAttribute removes an attribute from all products. The first call
+// returns the affected product count; repeat with ?confirm=<count> to delete.
+func (h *Handlers) AdminDeleteAttribute(c *fiber.Ctx) error {
+	slug := c.Params("slug")
+	ctx := context.Background()
+
+	rows, err := h.db.Pool.Query(ctx, "SELECT DISTINCT name FROM product_attributes")
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		if makeSlug(name) == slug {
+			names = append(names, name)
+		}
+	}
+	rows.Close()
+	if len(names) == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Attribute not found"})
+	}
+	return h.deleteAttributesConfirmed(c, names, c.Query("confirm"))
+}
+
+// AdminBulkDeleteAttributes removes all attributes whose name matches a pattern;
+// * matches any text and matching ignores case
+func (h *Handlers) AdminBulkDeleteAttributes(c *fiber.Ctx) error {
+	var input struct {
+		Pattern string `json:"pattern"`
+		Confirm *int   `json:"confirm"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	input.Pattern = strings.TrimSpace(input.Pattern)
+	if strings.Trim(input.Pattern, "*") == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "pattern required"})
+	}
+
+	ctx := context.Background()
+	rows, err := h.db.Pool.Query(ctx, `SELECT DISTINCT name FROM product_attributes WHERE name ILIKE $1 ESCAPE '\'`, globToLike(input.Pattern))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		names = append(names, name)
+	}
+	rows.Close()
+	if len(names) == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "No attributes match"})
+	}
+
+	confirm := ""
+	if input.Confirm != nil {
+		confirm = strconv.Itoa(*input.Confirm)
+	}
+	return h.deleteAttributesConfirmed(c, names, confirm)
+}
+
+func (h *Handlers) deleteAttributesConfirmed(c *fiber.Ctx, names []string, confirm string) error {
+	ctx := context.Background()
+	var productCount int
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(DISTINCT product_id) FROM product_attributes WHERE name = ANY($1)", names).Scan(&productCount)
+	if confirm != strconv.Itoa(productCount) {
+		return c.Status(409).JSON(fiber.Map{
+			"success": false,
+			"error":   "Confirmation required: repeat the request with confirm set to the product count",
+			"data":    fiber.Map{"names": names, "product_count": productCount},
+		})
+	}
+
+	rows, err := h.db.Pool.Query(ctx, "DELETE FROM product_attributes WHERE name = ANY($1) RETURNING product_id::text", names)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	seen := map[string]bool{}
+	var productIDs []string
+	deleted := 0
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		deleted++
+		if !seen[id] {
+			seen[id] = true
+			productIDs = append(productIDs, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	h.queueESSync(productIDs...)
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"names": names, "deleted": deleted, "product_count": len(productIDs),
+	}})
+}
+
+// globToLike turns a * wildcard pattern into a LIKE pattern with \ as escape
+func globToLike(pattern string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`)
+	return r.Replace(pattern)
+}
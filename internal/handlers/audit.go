@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== AUDIT LOG ==========
+
+// audit records an admin operation. The API has no user accounts, so the actor is
+// the client address of the request, or the API key name for key-authenticated calls.
+func (h *Handlers) audit(ctx context.Context, c *fiber.Ctx, action, entityType, entityID string, details fiber.Map) {
+	detailsJSON, _ := json.Marshal(details)
+	var id interface{} = nil
+	if entityID != "" {
+		id = entityID
+	}
+	h.db.Pool.Exec(ctx, `
+		INSERT INTO audit_log (action, entity_type, entity_id, actor, details, created_at)
+		VALUES ($1, NULLIF($2,''), $3::uuid, $4, $5::jsonb, NOW())
+	`, action, entityType, id, auditActor(c), string(detailsJSON))
+}
+
+// auditActor names who made the request: the API key when one was used, else the client IP
+func auditActor(c *fiber.Ctx) string {
+	if name, ok := c.Locals(apiKeyLocal).(string); ok {
+		return "api_key:" + name
+	}
+	return c.IP()
+}
+
+// AdminAuditLog lists recorded operations, newest first, filtered by ?action= and ?entity_id=
+func (h *Handlers) AdminAuditLog(c *fiber.Ctx) error {
+	page, limit, offset := adminPageParams(c, 50)
+	action := c.Query("action")
+	entityID := c.Query("entity_id")
+	if entityID != "" && !isUUID(entityID) {
+		return invalidUUIDField(c, "entity_id")
+	}
+	ctx := context.Background()
+
+	where := "WHERE ($1 = '' OR action = $1) AND ($2 = '' OR entity_id::text = $2)"
+	var total int64
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM audit_log "+where, action, entityID).Scan(&total)
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT id, action, COALESCE(entity_type,''), COALESCE(entity_id::text,''), COALESCE(actor,''), COALESCE(details::text,'{}'), created_at
+		FROM audit_log `+where+`
+		ORDER BY created_at DESC LIMIT $3 OFFSET $4
+	`, action, entityID, limit, offset)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+
+	entries := []fiber.Map{}
+	for rows.Next() {
+		var id, entryAction, entityType, entryEntityID, actor, detailsStr string
+		var createdAt time.Time
+		rows.Scan(&id, &entryAction, &entityType, &entryEntityID, &actor, &detailsStr, &createdAt)
+		var details map[string]interface{}
+		json.Unmarshal([]byte(detailsStr), &details)
+		entries = append(entries, fiber.Map{
+			"id": id, "action": entryAction, "entity_type": entityType, "entity_id": entryEntityID,
+			"actor": actor, "details": details, "created_at": createdAt,
+		})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{"items": entries}, page, limit, total)})
+}
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/elasticsearch"
+	"megabuy-go/internal/models"
+	"megabuy-go/internal/money"
+)
+
+// ========== BRAND PAGES ==========
+
+// brandsCacheTTL bounds how stale brand counts on /brands may be
+const brandsCacheTTL = time.Minute
+
+// brandEntry is one brand as derived from products.brand. Spellings that share a
+// slug, like Samsung and SAMSUNG, are one brand; Name is the most used spelling.
+type brandEntry struct {
+	Slug         string   `json:"slug"`
+	Name         string   `json:"name"`
+	ProductCount int64    `json:"product_count"`
+	LogoURL      string   `json:"logo_url"`
+	spellings    []string // every products.brand value of the brand
+}
+
+var (
+	brandsMutex    sync.Mutex
+	brandsCache    []brandEntry
+	brandsLoadedAt time.Time
+)
+
+// brandIndex returns every brand with active products, most products first
+func (h *Handlers) brandIndex(ctx context.Context) ([]brandEntry, error) {
+	brandsMutex.Lock()
+	defer brandsMutex.Unlock()
+	if brandsCache != nil && time.Since(brandsLoadedAt) < brandsCacheTTL {
+		return brandsCache, nil
+	}
+
+	rows, err := h.db.ReadPool.Query(ctx, `
+		SELECT p.brand, COUNT(*) FROM products p
+		WHERE p.is_active = true AND COALESCE(p.brand,'') <> ''
+		GROUP BY p.brand ORDER BY COUNT(*) DESC, p.brand
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	bySlug := map[string]*brandEntry{}
+	var order []string
+	for rows.Next() {
+		var name string
+		var count int64
+		rows.Scan(&name, &count)
+		slug := makeSlug(name)
+		if slug == "" {
+			continue
+		}
+		b, ok := bySlug[slug]
+		if !ok {
+			// rows come most used first, so the first spelling names the brand
+			b = &brandEntry{Slug: slug, Name: name}
+			bySlug[slug] = b
+			order = append(order, slug)
+		}
+		b.ProductCount += count
+		b.spellings = append(b.spellings, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	logos, _ := h.db.ReadPool.Query(ctx, "SELECT slug, COALESCE(name,''), COALESCE(logo_url,'') FROM brands")
+	if logos != nil {
+		for logos.Next() {
+			var slug, name, logo string
+			logos.Scan(&slug, &name, &logo)
+			if b := bySlug[slug]; b != nil {
+				b.LogoURL = logo
+				if name != "" {
+					b.Name = name
+				}
+			}
+		}
+		logos.Close()
+	}
+
+	brands := make([]brandEntry, 0, len(order))
+	for _, slug := range order {
+		brands = append(brands, *bySlug[slug])
+	}
+	sort.SliceStable(brands, func(i, j int) bool { return brands[i].ProductCount > brands[j].ProductCount })
+	brandsCache, brandsLoadedAt = brands, time.Now()
+	return brands, nil
+}
+
+func invalidateBrands() {
+	brandsMutex.Lock()
+	brandsLoadedAt = time.Time{}
+	brandsMutex.Unlock()
+}
+
+// GetBrands lists brands with product counts and logos; ?sort=name orders alphabetically
+func (h *Handlers) GetBrands(c *fiber.Ctx) error {
+	sortBy := c.Query("sort", "count")
+	if sortBy != "count" && sortBy != "name" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "sort must be count or name"})
+	}
+	page, limit, offset := pageParams(c, 50)
+	brands, err := h.brandIndex(context.Background())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if sortBy == "name" {
+		brands = append([]brandEntry(nil), brands...)
+		sort.SliceStable(brands, func(i, j int) bool { return brands[i].Slug < brands[j].Slug })
+	}
+	items := brands[min(offset, len(brands)):min(offset+limit, len(brands))]
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{"items": items}, page, limit, int64(len(brands)))})
+}
+
+// GetBrandProducts serves a brand landing page: brand data, products of every spelling
+// of the brand and category and price facets. Elasticsearch picks the products when it
+// is available; the database serves name_asc and any ES failure.
+func (h *Handlers) GetBrandProducts(c *fiber.Ctx) error {
+	page, limit, offset := pageParams(c, 20)
+	priceMode, ok := priceModeParam(c)
+	if !ok {
+		return invalidPriceMode(c)
+	}
+	fields, msg := fieldsParam(c, models.ProductCard{})
+	if msg != "" {
+		return invalidFields(c, msg)
+	}
+	sortBy := c.Query("sort")
+	if sortBy != "" && !containsString(listingSorts, sortBy) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "sort must be one of " + strings.Join(listingSorts, ", ")})
+	}
+	ctx := context.Background()
+
+	brands, err := h.brandIndex(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	var brand *brandEntry
+	for i := range brands {
+		if brands[i].Slug == c.Params("slug") {
+			brand = &brands[i]
+		}
+	}
+	if brand == nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Brand not found"})
+	}
+
+	priceMinCol, _ := priceColumns(priceMode)
+	where := "WHERE p.is_active = true AND p.brand = ANY($1)"
+	args := []interface{}{brand.spellings}
+	var warnings []string
+	var categoryIDs []string
+	if cats := splitList(c.Query("category")); len(cats) > 0 {
+		var w []string
+		categoryIDs, w = h.resolveCategorySubtrees(ctx, cats)
+		warnings = append(warnings, w...)
+		if categoryIDs == nil {
+			categoryIDs = []string{}
+		}
+		args = append(args, categoryIDs)
+		where += fmt.Sprintf(" AND p.category_id = ANY($%d::uuid[])", len(args))
+	}
+	minPrice, maxPrice := c.QueryInt("min_price", 0), c.QueryInt("max_price", 0)
+	if minPrice > 0 {
+		args = append(args, minPrice)
+		where += fmt.Sprintf(" AND %s >= $%d", priceMinCol, len(args))
+	}
+	if maxPrice > 0 {
+		args = append(args, maxPrice)
+		where += fmt.Sprintf(" AND %s <= $%d", priceMinCol, len(args))
+	}
+	inStock := c.Query("in_stock") == "true"
+	if inStock {
+		where += " AND p.stock_status = 'instock'"
+	}
+	if cond := h.outOfStockPolicies(ctx).hideCondition(&args); cond != "" {
+		where += " AND " + cond
+	}
+
+	var products []models.ProductCard
+	var total int64
+	source := "database"
+	if h.es != nil && sortBy != "name_asc" && priceMode == "gross" && (categoryIDs == nil || len(categoryIDs) > 0) {
+		params := elasticsearch.SearchParams{
+			CategoryIDs: categoryIDs,
+			Brands:      brand.spellings,
+			PriceMin:    float64(minPrice),
+			PriceMax:    float64(maxPrice),
+			InStock:     inStock,
+			Sort:        sortBy,
+			Page:        page,
+			Limit:       limit,
+		}
+		if result, err := h.cachedSearch(c, params); err == nil {
+			ids := make([]string, len(result.Products))
+			for i, p := range result.Products {
+				ids[i] = p.ID
+			}
+			products, total, source = []models.ProductCard{}, result.Total, "elasticsearch"
+			if len(ids) > 0 {
+				products = h.productCards(ctx, productListConfig{ProductIDs: ids, Limit: len(ids)}, priceMode)
+			}
+		}
+	}
+	if source == "database" {
+		products, total = h.brandListing(ctx, where, args, priceMode, sortBy, limit, offset)
+	}
+	addListDisplay(c, products)
+
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{
+		"brand":      h.brandLanding(ctx, *brand),
+		"items":      project(products, fields),
+		"facets":     h.brandFacets(ctx, where, args, priceMinCol),
+		"price_mode": priceMode,
+		"source":     source,
+		"warnings":   nonNilStrings(warnings),
+	}, page, limit, total)})
+}
+
+func (h *Handlers) brandListing(ctx context.Context, where string, args []interface{}, priceMode, sortBy string, limit, offset int) ([]models.ProductCard, int64) {
+	priceMinCol, _ := priceColumns(priceMode)
+	var total int64
+	h.db.ReadPool.QueryRow(ctx, "SELECT COUNT(*) FROM products p "+where, args...).Scan(&total)
+
+	orderBy := h.outOfStockPolicies(ctx).demoteOrder(&args) + listingOrder(sortBy, priceMinCol)
+	args = append(args, limit, offset)
+	rows, err := h.db.ReadPool.Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM products p LEFT JOIN categories c ON p.category_id = c.id
+		%s ORDER BY %s LIMIT $%d OFFSET $%d
+	`, productCardColumns(priceMode), where, orderBy, len(args)-1, len(args)), args...)
+	if err != nil {
+		return []models.ProductCard{}, total
+	}
+	products := scanProductCards(rows)
+	h.attachLabels(ctx, products)
+	return products, total
+}
+
+// brandFacets counts the brand's products per public category and reports the price range
+func (h *Handlers) brandFacets(ctx context.Context, where string, args []interface{}, priceCol string) fiber.Map {
+	categories := []fiber.Map{}
+	rows, err := h.db.ReadPool.Query(ctx, `
+		SELECT COALESCE(p.category_id::text,''), COALESCE(c.name,''), COALESCE(c.slug,''), COUNT(*)
+		FROM products p LEFT JOIN categories c ON p.category_id = c.id
+		`+where+` GROUP BY 1, 2, 3 ORDER BY COUNT(*) DESC LIMIT 50
+	`, args...)
+	if err == nil {
+		publicCategory := h.publicCategoryResolver(ctx)
+		counts := map[string]int64{}
+		var order []fiber.Map
+		for rows.Next() {
+			var id, name, slug string
+			var count int64
+			rows.Scan(&id, &name, &slug, &count)
+			if id == "" {
+				continue
+			}
+			// pending categories count towards their public ancestor
+			id, name, slug = publicCategory(id, name, slug)
+			if _, seen := counts[id]; !seen {
+				order = append(order, fiber.Map{"id": id, "name": name, "slug": slug})
+			}
+			counts[id] += count
+		}
+		rows.Close()
+		for _, cat := range order {
+			cat["count"] = counts[cat["id"].(string)]
+			categories = append(categories, cat)
+		}
+		sort.SliceStable(categories, func(i, j int) bool { return categories[i]["count"].(int64) > categories[j]["count"].(int64) })
+	}
+
+	var minPrice, maxPrice money.Price
+	h.db.ReadPool.QueryRow(ctx, fmt.Sprintf("SELECT COALESCE(MIN(%s),0), COALESCE(MAX(%s),0) FROM products p %s", priceCol, priceCol, where), args...).Scan(&minPrice, &maxPrice)
+	return fiber.Map{
+		"categories":  categories,
+		"price_range": fiber.Map{"min": minPrice, "max": maxPrice},
+	}
+}
+
+// brandLanding adds the description and SEO fields stored in brands, if any
+func (h *Handlers) brandLanding(ctx context.Context, b brandEntry) fiber.Map {
+	var description, seoTitle, seoDescription string
+	h.db.ReadPool.QueryRow(ctx, `
+		SELECT COALESCE(description,''), COALESCE(seo_title,''), COALESCE(seo_description,'') FROM brands WHERE slug = $1
+	`, b.Slug).Scan(&description, &seoTitle, &seoDescription)
+	if seoTitle == "" {
+		seoTitle = b.Name
+	}
+	return fiber.Map{
+		"slug": b.Slug, "name": b.Name, "logo_url": b.LogoURL, "product_count": b.ProductCount,
+		"description": description, "seo_title": seoTitle, "seo_description": seoDescription,
+	}
+}
+
+// AdminSetBrand stores the logo, description and SEO fields of a brand landing page
+func (h *Handlers) AdminSetBrand(c *fiber.Ctx) error {
+	slug := makeSlug(c.Params("slug"))
+	if slug == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid brand slug"})
+	}
+	var input struct {
+		Name           string `json:"name"`
+		LogoURL        string `json:"logo_url"`
+		Description    string `json:"description"`
+		SEOTitle       string `json:"seo_title"`
+		SEODescription string `json:"seo_description"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	ctx := context.Background()
+	_, err := h.db.Pool.Exec(ctx, `
+		INSERT INTO brands (slug, name, logo_url, description, seo_title, seo_description, updated_at)
+		VALUES ($1, NULLIF($2,''), NULLIF($3,''), NULLIF($4,''), NULLIF($5,''), NULLIF($6,''), NOW())
+		ON CONFLICT (slug) DO UPDATE SET name = EXCLUDED.name, logo_url = EXCLUDED.logo_url, description = EXCLUDED.description,
+		       seo_title = EXCLUDED.seo_title, seo_description = EXCLUDED.seo_description, updated_at = NOW()
+	`, slug, strings.TrimSpace(input.Name), strings.TrimSpace(input.LogoURL), input.Description, strings.TrimSpace(input.SEOTitle), input.SEODescription)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	invalidateBrands()
+	// the canonical spelling may have changed, which the completeness brand check uses
+	if brands, err := h.brandIndex(ctx); err == nil {
+		for _, b := range brands {
+			if b.Slug == slug {
+				h.refreshCompleteness(ctx, "p.brand = ANY($1)", b.spellings)
+				break
+			}
+		}
+	}
+	h.audit(ctx, c, "brand.update", "brand", "", fiber.Map{"slug": slug})
+	return c.JSON(fiber.Map{"success": true, "message": "Brand saved"})
+}
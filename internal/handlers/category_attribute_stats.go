@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== CATEGORY ATTRIBUTE STATS ==========
+
+const (
+	categoryAttributeStatsTTL = 60 * time.Second
+	// maxAttributeStatValues caps the most common values listed per attribute
+	maxAttributeStatValues = 20
+)
+
+// AttributeValueCount is one attribute value with the number of products carrying it
+type AttributeValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// CategoryAttributeStat describes one attribute within a category subtree
+type CategoryAttributeStat struct {
+	Name         string                `json:"name"`
+	Slug         string                `json:"slug"`
+	ProductCount int                   `json:"product_count"`
+	ValueCount   int                   `json:"value_count"`
+	TopValues    []AttributeValueCount `json:"top_values"`
+}
+
+type categoryAttributeStatsEntry struct {
+	stats      []CategoryAttributeStat
+	computedAt time.Time
+}
+
+var (
+	categoryAttributeStatsMutex sync.Mutex
+	categoryAttributeStatsCache = map[string]categoryAttributeStatsEntry{}
+)
+
+// categoryAttributeStats aggregates the attributes of active products in the category
+// subtree, cached per category for categoryAttributeStatsTTL
+func (h *Handlers) categoryAttributeStats(ctx context.Context, categoryID string) ([]CategoryAttributeStat, time.Time, error) {
+	categoryAttributeStatsMutex.Lock()
+	defer categoryAttributeStatsMutex.Unlock()
+	if e, ok := categoryAttributeStatsCache[categoryID]; ok && time.Since(e.computedAt) < categoryAttributeStatsTTL {
+		return e.stats, e.computedAt, nil
+	}
+
+	where := newWhere("p.is_active = true").add(subtreeCategoryCondition, categoryID)
+	limit := where.arg(maxAttributeStatValues)
+	rows, err := h.db.ReadPool.Query(ctx, `
+		WITH scoped AS (
+			SELECT pa.name, pa.value, pa.product_id
+			FROM product_attributes pa JOIN products p ON p.id = pa.product_id
+			`+where.clause()+`
+		), names AS (
+			SELECT name, COUNT(DISTINCT product_id) AS products FROM scoped GROUP BY name
+		), vals AS (
+			SELECT name, value, COUNT(DISTINCT product_id) AS n,
+			       ROW_NUMBER() OVER (PARTITION BY name ORDER BY COUNT(DISTINCT product_id) DESC, value) AS rank
+			FROM scoped GROUP BY name, value
+		)
+		SELECT n.name, n.products, COUNT(v.value),
+		       COALESCE(json_agg(json_build_object('value', v.value, 'count', v.n) ORDER BY v.rank)
+		                FILTER (WHERE v.rank <= `+limit+`), '[]')::text
+		FROM names n JOIN vals v ON v.name = n.name
+		GROUP BY n.name, n.products
+		ORDER BY n.products DESC, n.name
+	`, where.params()...)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer rows.Close()
+
+	stats := []CategoryAttributeStat{}
+	for rows.Next() {
+		var s CategoryAttributeStat
+		var values string
+		if err := rows.Scan(&s.Name, &s.ProductCount, &s.ValueCount, &values); err != nil {
+			return nil, time.Time{}, err
+		}
+		s.Slug = makeSlug(s.Name)
+		s.TopValues = []AttributeValueCount{}
+		json.Unmarshal([]byte(values), &s.TopValues)
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	now := time.Now()
+	categoryAttributeStatsCache[categoryID] = categoryAttributeStatsEntry{stats: stats, computedAt: now}
+	return stats, now, nil
+}
+
+// AdminCategoryAttributeStats lists the attributes of products in a category subtree
+// with product counts, distinct value counts and the most common values, to help
+// pick the filters for that category. ?min_count= hides attributes carried by fewer
+// products.
+func (h *Handlers) AdminCategoryAttributeStats(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx := context.Background()
+
+	minCount := c.QueryInt("min_count", 1)
+	if minCount < 1 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "min_count must be a positive integer"})
+	}
+
+	var name string
+	if err := h.db.Pool.QueryRow(ctx, "SELECT name FROM categories WHERE id = $1::uuid", id).Scan(&name); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Category not found"})
+	}
+
+	stats, computedAt, err := h.categoryAttributeStats(ctx, id)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	attributes := []CategoryAttributeStat{}
+	hidden := 0
+	for _, s := range stats {
+		if s.ProductCount < minCount {
+			hidden++
+			continue
+		}
+		attributes = append(attributes, s)
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"category_id": id, "category_name": name, "attributes": attributes,
+		"hidden": hidden, "min_count": minCount, "computed_at": computedAt,
+	}})
+}
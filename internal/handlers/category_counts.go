@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== ADMIN CATEGORY COUNTS ==========
+
+// subtreeCategoryCondition matches products in the category with the placeholder ID or
+// any of its descendants, expanded by the database in the same query
+const subtreeCategoryCondition = `p.category_id IN (WITH RECURSIVE subcats AS (
+	SELECT id FROM categories WHERE id = ?::uuid
+	UNION ALL
+	SELECT c.id FROM categories c JOIN subcats s ON c.parent_id = s.id
+) SELECT id FROM subcats)`
+
+type categoryCounts struct {
+	active, inactive int64
+}
+
+// attachCategoryCounts adds active and inactive product counts to each admin category,
+// both for the category itself and summed over its subtree. The direct counts come from
+// one grouped query; subtree totals are rolled up from the parent links in memory.
+func (h *Handlers) attachCategoryCounts(ctx context.Context, cats []fiber.Map) {
+	direct := map[string]categoryCounts{}
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT category_id::text, COUNT(*) FILTER (WHERE is_active), COUNT(*) FILTER (WHERE NOT is_active)
+		FROM products WHERE category_id IS NOT NULL GROUP BY category_id
+	`)
+	if err == nil {
+		for rows.Next() {
+			var id string
+			var n categoryCounts
+			rows.Scan(&id, &n.active, &n.inactive)
+			direct[id] = n
+		}
+		rows.Close()
+	}
+
+	parents := make(map[string]string, len(cats))
+	for _, cat := range cats {
+		parents[cat["id"].(string)] = cat["parent_id"].(string)
+	}
+	subtree := make(map[string]categoryCounts, len(cats))
+	for id, n := range direct {
+		// walk up to the root; the seen set guards against cycles in bad data
+		seen := map[string]bool{}
+		for node := id; node != "" && !seen[node]; node = parents[node] {
+			seen[node] = true
+			total := subtree[node]
+			total.active += n.active
+			total.inactive += n.inactive
+			subtree[node] = total
+		}
+	}
+
+	for _, cat := range cats {
+		id := cat["id"].(string)
+		cat["active_products"] = direct[id].active
+		cat["inactive_products"] = direct[id].inactive
+		cat["subtree_active_products"] = subtree[id].active
+		cat["subtree_inactive_products"] = subtree[id].inactive
+	}
+}
+
+// ========== PUBLIC CATEGORY COUNTS ==========
+
+// recountCategoriesSQL refreshes product_count (active products) and in_stock_count
+// (those not out of stock) in one statement
+const recountCategoriesSQL = `
+	UPDATE categories SET (product_count, in_stock_count) = (
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE COALESCE(stock_status,'instock') <> 'outofstock')
+		FROM products WHERE category_id = categories.id AND is_active = true
+	)`
+
+// recountCategories refreshes the product counts of the given categories, or of all
+// categories when none are given
+func (h *Handlers) recountCategories(ctx context.Context, categoryIDs ...string) {
+	if len(categoryIDs) == 0 {
+		h.db.Pool.Exec(ctx, recountCategoriesSQL)
+		return
+	}
+	h.db.Pool.Exec(ctx, recountCategoriesSQL+" WHERE id = ANY($1::uuid[])", categoryIDs)
+}
+
+// publicCategories loads the active categories for the public category endpoints,
+// ordered by orderBy. With hideEmpty, categories without an active in-stock product in
+// their subtree are left out; their ancestors stay when a descendant has one.
+func (h *Handlers) publicCategories(ctx context.Context, orderBy string, hideEmpty bool) []*models.Category {
+	rows, err := h.db.ReadPool.Query(ctx, `SELECT id, COALESCE(parent_id::text,''), name, slug, COALESCE(icon,''), COALESCE(icon_url,''), product_count, in_stock_count FROM categories WHERE is_active=true ORDER BY `+orderBy)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var cats []*models.Category
+	for rows.Next() {
+		cat := &models.Category{}
+		rows.Scan(&cat.ID, &cat.ParentID, &cat.Name, &cat.Slug, &cat.Icon, &cat.IconURL, &cat.ProductCount, &cat.InStockCount)
+		cats = append(cats, cat)
+	}
+	if hideEmpty {
+		cats = models.WithoutEmptyCategories(cats)
+	}
+	return cats
+}
+
+func categoryMaps(cats []*models.Category) []fiber.Map {
+	out := make([]fiber.Map, len(cats))
+	for i, cat := range cats {
+		out[i] = fiber.Map{"id": cat.ID, "parent_id": cat.ParentID, "name": cat.Name, "slug": cat.Slug, "icon": cat.Icon, "icon_url": cat.IconURL, "product_count": cat.ProductCount, "in_stock_count": cat.InStockCount}
+	}
+	return out
+}
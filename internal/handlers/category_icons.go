@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ========== CATEGORY ICONS ==========
+
+const (
+	categoryIconDir     = "./uploads/category-icons"
+	maxCategoryIconSize = 64 * 1024
+)
+
+// categoryIcons is the icon set the frontend knows how to render; categories.icon
+// must be empty or one of these identifiers
+var categoryIcons = []string{
+	"appliances", "auto", "baby", "beauty", "books", "camera", "clothing", "computer",
+	"drugstore", "electronics", "food", "furniture", "gaming", "garden", "gifts", "health",
+	"home", "jewelry", "kitchen", "laptop", "lighting", "music", "office", "pets",
+	"phone", "shoes", "sports", "tablet", "tools", "toys", "travel", "tv", "watch",
+}
+
+var categoryIconSet = func() map[string]bool {
+	set := make(map[string]bool, len(categoryIcons))
+	for _, icon := range categoryIcons {
+		set[icon] = true
+	}
+	return set
+}()
+
+func validCategoryIcon(icon string) bool {
+	return icon == "" || categoryIconSet[icon]
+}
+
+func invalidCategoryIcon(c *fiber.Ctx) error {
+	return c.Status(400).JSON(fiber.Map{"success": false, "error": "Unknown icon, see /admin/categories/icons", "field": "icon"})
+}
+
+// AdminCategoryIcons lists the allowed icon identifiers
+func (h *Handlers) AdminCategoryIcons(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"success": true, "data": categoryIcons})
+}
+
+// AdminInvalidCategoryIcons reports categories whose icon predates the allowed set
+// and is not part of it, so they can be fixed before the next edit
+func (h *Handlers) AdminInvalidCategoryIcons(c *fiber.Ctx) error {
+	ctx := context.Background()
+	rows, err := h.db.Pool.Query(ctx, `SELECT id::text, name, slug, icon FROM categories WHERE COALESCE(icon,'') <> '' AND NOT (icon = ANY($1)) ORDER BY name`, categoryIcons)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+	items := []fiber.Map{}
+	for rows.Next() {
+		var id, name, slug, icon string
+		rows.Scan(&id, &name, &slug, &icon)
+		items = append(items, fiber.Map{"id": id, "name": name, "slug": slug, "icon": icon})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": items, "total": len(items)})
+}
+
+// svgElements are the elements an icon may use: shapes, grouping, gradients and
+// clipping. Everything else, including scripts, foreign objects, links, images,
+// <style> and the animation elements that can rewrite attributes, is dropped
+// together with its content. SVG names are case-sensitive.
+var svgElements = map[string]bool{
+	"svg": true, "g": true, "defs": true, "symbol": true, "use": true, "title": true, "desc": true,
+	"path": true, "rect": true, "circle": true, "ellipse": true, "line": true, "polyline": true, "polygon": true,
+	"text": true, "tspan": true,
+	"linearGradient": true, "radialGradient": true, "stop": true, "clipPath": true, "mask": true,
+}
+
+// svgAttributes are the geometry and presentation attributes an icon may carry.
+// None of them takes a URL apart from local url(#id) paint and clip references,
+// which svgAttrValue enforces; style is left out as it can load resources.
+var svgAttributes = map[string]bool{
+	"id": true, "class": true, "version": true, "viewBox": true, "preserveAspectRatio": true,
+	"width": true, "height": true, "x": true, "y": true, "x1": true, "y1": true, "x2": true, "y2": true,
+	"cx": true, "cy": true, "r": true, "rx": true, "ry": true, "fx": true, "fy": true,
+	"d": true, "points": true, "transform": true, "pathLength": true,
+	"fill": true, "fill-opacity": true, "fill-rule": true, "clip-rule": true, "opacity": true,
+	"stroke": true, "stroke-width": true, "stroke-linecap": true, "stroke-linejoin": true,
+	"stroke-miterlimit": true, "stroke-dasharray": true, "stroke-dashoffset": true, "stroke-opacity": true,
+	"clip-path": true, "mask": true, "visibility": true, "display": true,
+	"offset": true, "stop-color": true, "stop-opacity": true, "gradientUnits": true, "gradientTransform": true,
+	"spreadMethod": true, "clipPathUnits": true, "maskUnits": true, "maskContentUnits": true,
+	"font-family": true, "font-size": true, "font-weight": true, "text-anchor": true, "dominant-baseline": true,
+}
+
+// svgNamespaces are the namespace declarations kept on the root element
+var svgNamespaces = map[string]string{
+	"xmlns":       "http://www.w3.org/2000/svg",
+	"xmlns:xlink": "http://www.w3.org/1999/xlink",
+}
+
+var (
+	svgLocalRef = regexp.MustCompile(`^#[A-Za-z][A-Za-z0-9_.:-]*$`)
+	svgLocalURL = regexp.MustCompile(`^url\(\s*#[A-Za-z][A-Za-z0-9_.:-]*\s*\)$`)
+)
+
+// sanitizeSVG rewrites an SVG document keeping only the elements and attributes of
+// the allowlists above; comments, processing instructions and DOCTYPE declarations
+// are dropped too. Uploaded icons are additionally served under
+// CategoryIconHeaders, so a gap here still cannot run script.
+func sanitizeSVG(data []byte) ([]byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var out bytes.Buffer
+	depth, skip := 0, 0
+	sawRoot := false
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("not a well-formed SVG document")
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if skip > 0 {
+				skip++
+				continue
+			}
+			if depth == 1 {
+				if sawRoot || t.Name.Local != "svg" || t.Name.Space != "" {
+					return nil, errors.New("root element must be <svg>")
+				}
+				sawRoot = true
+			}
+			// prefixed elements may belong to another namespace, such as XHTML
+			if t.Name.Space != "" || !svgElements[t.Name.Local] {
+				skip = 1
+				continue
+			}
+			out.WriteString("<" + t.Name.Local)
+			for _, a := range t.Attr {
+				name, ok := svgAttrName(a.Name, t.Name.Local, depth == 1)
+				if !ok || !svgAttrValue(name, a.Value) {
+					continue
+				}
+				out.WriteString(" " + name + `="`)
+				xml.EscapeText(&out, []byte(a.Value))
+				out.WriteString(`"`)
+			}
+			out.WriteString(">")
+		case xml.EndElement:
+			depth--
+			if skip > 0 {
+				skip--
+				continue
+			}
+			out.WriteString("</" + t.Name.Local + ">")
+		case xml.CharData:
+			if skip == 0 && depth > 0 {
+				xml.EscapeText(&out, t)
+			}
+		}
+	}
+	if !sawRoot || depth != 0 {
+		return nil, errors.New("not a well-formed SVG document")
+	}
+	return out.Bytes(), nil
+}
+
+// svgAttrName returns the name an allowed attribute of element is written with;
+// namespace declarations are only kept on the root and href only on <use>
+func svgAttrName(n xml.Name, element string, root bool) (string, bool) {
+	name := n.Local
+	if n.Space != "" {
+		name = n.Space + ":" + n.Local
+	}
+	switch {
+	case name == "xmlns" || n.Space == "xmlns":
+		return name, root
+	case name == "href" || name == "xlink:href":
+		return name, element == "use"
+	case n.Space != "":
+		return "", false
+	}
+	return name, svgAttributes[name]
+}
+
+// svgAttrValue checks the value of an allowed attribute: namespace declarations must
+// name the SVG namespaces, href must point into the document, and a url() value must
+// be a local reference
+func svgAttrValue(name, value string) bool {
+	value = strings.TrimSpace(value)
+	if ns, ok := svgNamespaces[name]; ok {
+		return value == ns
+	}
+	if strings.HasPrefix(name, "xmlns") {
+		return false
+	}
+	if name == "href" || name == "xlink:href" {
+		return svgLocalRef.MatchString(value)
+	}
+	if strings.Contains(strings.ToLower(value), "url(") {
+		return svgLocalURL.MatchString(value)
+	}
+	return true
+}
+
+// CategoryIconHeaders is the middleware of the uploaded icon files: they are
+// served as images that can neither run script nor load anything
+func CategoryIconHeaders(c *fiber.Ctx) error {
+	c.Set("Content-Security-Policy", "default-src 'none'; script-src 'none'; sandbox")
+	c.Set("X-Content-Type-Options", "nosniff")
+	return c.Next()
+}
+
+// removeCategoryIconFile deletes a previously uploaded icon; URLs outside the icon
+// directory are ignored
+func removeCategoryIconFile(iconURL string) {
+	if iconURL == "" {
+		return
+	}
+	name := filepath.Base(iconURL)
+	if !strings.HasSuffix(name, ".svg") || !strings.Contains(iconURL, "/uploads/category-icons/") {
+		return
+	}
+	os.Remove(filepath.Join(categoryIconDir, name))
+}
+
+// AdminUploadCategoryIcon stores a sanitized SVG as the icon of a category and
+// replaces the previous upload
+func (h *Handlers) AdminUploadCategoryIcon(c *fiber.Ctx) error {
+	categoryID := c.Params("id")
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "No file uploaded"})
+	}
+	if file.Size > maxCategoryIconSize {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": fmt.Sprintf("Icon exceeds %d KB", maxCategoryIconSize/1024)})
+	}
+	f, err := file.Open()
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Failed to read file"})
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxCategoryIconSize+1))
+	f.Close()
+	if err != nil || len(data) > maxCategoryIconSize {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": fmt.Sprintf("Icon exceeds %d KB", maxCategoryIconSize/1024)})
+	}
+	clean, err := sanitizeSVG(data)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	ctx := context.Background()
+	var oldURL string
+	if err := h.db.Pool.QueryRow(ctx, "SELECT COALESCE(icon_url,'') FROM categories WHERE id = $1::uuid", categoryID).Scan(&oldURL); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Category not found"})
+	}
+	os.MkdirAll(categoryIconDir, 0755)
+	// a fresh name per upload so caches never serve the replaced icon
+	filename := fmt.Sprintf("%s-%s.svg", categoryID, uuid.New().String()[:8])
+	if err := os.WriteFile(filepath.Join(categoryIconDir, filename), clean, 0644); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": "Failed to save file"})
+	}
+	url := fmt.Sprintf("%s/uploads/category-icons/%s", c.BaseURL(), filename)
+	if _, err := h.db.Pool.Exec(ctx, "UPDATE categories SET icon_url = $2, updated_at = NOW() WHERE id = $1::uuid", categoryID, url); err != nil {
+		os.Remove(filepath.Join(categoryIconDir, filename))
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	removeCategoryIconFile(oldURL)
+	invalidateHomepage()
+	h.audit(ctx, c, "category.icon_upload", "category", categoryID, fiber.Map{"icon_url": url, "size": len(clean)})
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"icon_url": url}})
+}
+
+// AdminDeleteCategoryIcon removes the uploaded icon; the icon identifier is kept
+func (h *Handlers) AdminDeleteCategoryIcon(c *fiber.Ctx) error {
+	categoryID := c.Params("id")
+	ctx := context.Background()
+	var oldURL string
+	if err := h.db.Pool.QueryRow(ctx, "SELECT COALESCE(icon_url,'') FROM categories WHERE id = $1::uuid", categoryID).Scan(&oldURL); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Category not found"})
+	}
+	if _, err := h.db.Pool.Exec(ctx, "UPDATE categories SET icon_url = NULL, updated_at = NOW() WHERE id = $1::uuid", categoryID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	removeCategoryIconFile(oldURL)
+	invalidateHomepage()
+	h.audit(ctx, c, "category.icon_delete", "category", categoryID, nil)
+	return c.JSON(fiber.Map{"success": true, "message": "Icon removed"})
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestSanitizeSVGKeepsIconMarkup(t *testing.T) {
+	in := `<?xml version="1.0"?>
+<!DOCTYPE svg>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 24 24" width="24">
+<!-- exported by an editor -->
+<defs><linearGradient id="g1"><stop offset="0" stop-color="#f00"/></linearGradient></defs>
+<g fill="url(#g1)" stroke-width="2"><path d="M0 0L24 24"/><circle cx="12" cy="12" r="4"/></g>
+<use xlink:href="#g1"/>
+</svg>`
+	out, err := sanitizeSVG([]byte(in))
+	if err != nil {
+		t.Fatalf("sanitizeSVG: %v", err)
+	}
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 24 24" width="24">`,
+		`<linearGradient id="g1"><stop offset="0" stop-color="#f00"></stop></linearGradient>`,
+		`<g fill="url(#g1)" stroke-width="2"><path d="M0 0L24 24"></path>`,
+		`<use xlink:href="#g1"></use>`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output lacks %s:\n%s", want, out)
+		}
+	}
+	for _, gone := range []string{"<!--", "DOCTYPE", "<?xml"} {
+		if strings.Contains(string(out), gone) {
+			t.Errorf("output keeps %s:\n%s", gone, out)
+		}
+	}
+}
+
+func TestSanitizeSVGStripsActiveContent(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		bad  []string
+	}{
+		{"script", `<svg><script>alert(1)</script><path d="M0"/></svg>`, []string{"script", "alert"}},
+		{"event handler", `<svg onload="alert(1)"><rect onclick="x()" width="1"/></svg>`, []string{"onload", "onclick"}},
+		{"style import", `<svg><style>@import url(https://evil.example/x.css);</style></svg>`, []string{"style", "import", "evil"}},
+		{"style attribute", `<svg><rect style="background:url(https://evil.example/x)"/></svg>`, []string{"style", "evil"}},
+		{"set on link", `<svg><a><set attributeName="href" to="java&#9;script:alert(1)"/></a></svg>`, []string{"<a", "set", "script"}},
+		{"animate", `<svg><rect><animate attributeName="fill" values="red"/></rect><animateTransform/></svg>`, []string{"animate"}},
+		{"foreign object", `<svg><foreignObject><iframe src="https://evil.example"/></foreignObject></svg>`, []string{"foreignObject", "iframe", "evil"}},
+		{"xhtml element", `<svg xmlns:h="http://www.w3.org/1999/xhtml"><h:script>alert(1)</h:script></svg>`, []string{"script", "alert", "xhtml"}},
+		{"external use", `<svg><use href="https://evil.example/sprite.svg#a"/><use xlink:href="data:image/svg+xml,x"/></svg>`, []string{"evil", "data:"}},
+		{"href off use", `<svg><path href="#a"/></svg>`, []string{"href"}},
+		{"external paint", `<svg><rect fill="url(https://evil.example/#g)" mask="url( //evil.example/m)"/></svg>`, []string{"evil"}},
+		{"image", `<svg><image href="https://evil.example/pixel.png"/></svg>`, []string{"image", "evil"}},
+		{"unknown namespace", `<svg xmlns="http://evil.example/ns"><rect/></svg>`, []string{"evil"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := sanitizeSVG([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("sanitizeSVG: %v", err)
+			}
+			for _, bad := range tt.bad {
+				if strings.Contains(strings.ToLower(string(out)), strings.ToLower(bad)) {
+					t.Errorf("output keeps %q: %s", bad, out)
+				}
+			}
+		})
+	}
+}
+
+func TestSanitizeSVGRejects(t *testing.T) {
+	for name, in := range map[string]string{
+		"not svg":       `<html><body/></html>`,
+		"prefixed root": `<x:svg xmlns:x="http://www.w3.org/2000/svg"/>`,
+		"two roots":     `<svg/><svg/>`,
+		"truncated":     `<svg><path d="M0">`,
+		"not xml":       `GIF89a`,
+	} {
+		if _, err := sanitizeSVG([]byte(in)); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestValidCategoryIcon(t *testing.T) {
+	for icon, want := range map[string]bool{"": true, "phone": true, "Phone": false, "fa-phone": false, "<svg>": false} {
+		if got := validCategoryIcon(icon); got != want {
+			t.Errorf("validCategoryIcon(%q) = %v, want %v", icon, got, want)
+		}
+	}
+}
+
+func TestCategoryIconHeaders(t *testing.T) {
+	app := fiber.New()
+	app.Use("/uploads/category-icons", CategoryIconHeaders)
+	app.Get("/uploads/category-icons/x.svg", func(c *fiber.Ctx) error { return c.SendString("<svg/>") })
+	resp, err := app.Test(httptest.NewRequest("GET", "/uploads/category-icons/x.svg", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if csp := resp.Header.Get("Content-Security-Policy"); !strings.Contains(csp, "script-src 'none'") {
+		t.Errorf("Content-Security-Policy = %q", csp)
+	}
+	if resp.Header.Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("X-Content-Type-Options not set")
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== CATEGORY PATHS ==========
+
+// categoryPathSeparator joins ancestor names in displayed paths
+const categoryPathSeparator = " > "
+
+// categoryPathNode is one category on the way from the root to a category
+type categoryPathNode struct {
+	ID       string
+	Name     string
+	Slug     string
+	IsActive bool
+}
+
+// categoryPaths returns the ancestor chain of each category, root first and ending
+// with the category itself, walking all of them up the tree in one query
+func (h *Handlers) categoryPaths(ctx context.Context, ids []string) (map[string][]categoryPathNode, error) {
+	paths := make(map[string][]categoryPathNode, len(ids))
+	if len(ids) == 0 {
+		return paths, nil
+	}
+	rows, err := h.db.ReadPool.Query(ctx, `
+		WITH RECURSIVE chain AS (
+			SELECT id AS leaf, id, parent_id, name, slug, is_active, 0 AS depth FROM categories WHERE id = ANY($1::uuid[])
+			UNION ALL
+			SELECT chain.leaf, c.id, c.parent_id, c.name, c.slug, c.is_active, chain.depth + 1
+			FROM categories c JOIN chain ON c.id = chain.parent_id
+			WHERE chain.depth < 20
+		)
+		SELECT leaf::text, id::text, name, slug, is_active FROM chain ORDER BY leaf, depth DESC
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var leaf string
+		var n categoryPathNode
+		if err := rows.Scan(&leaf, &n.ID, &n.Name, &n.Slug, &n.IsActive); err != nil {
+			return nil, err
+		}
+		paths[leaf] = append(paths[leaf], n)
+	}
+	return paths, rows.Err()
+}
+
+// formatCategoryPath renders a chain as "Elektro > Príslušenstvo > Kabeláž"
+func formatCategoryPath(path []categoryPathNode) string {
+	names := make([]string, len(path))
+	for i, n := range path {
+		names[i] = n.Name
+	}
+	return strings.Join(names, categoryPathSeparator)
+}
+
+// optionalBoolQuery reads a true/false query parameter; set is false when it is absent
+func optionalBoolQuery(c *fiber.Ctx, key string) (value, set, ok bool) {
+	raw := c.Query(key)
+	if raw == "" {
+		return false, false, true
+	}
+	v, err := strconv.ParseBool(raw)
+	return v, true, err == nil
+}
+
+// adminSearchCategories serves AdminCategories when ?search=, ?is_active= or
+// ?created_from_feed= is given: matching ignores case and diacritics, and each hit
+// carries its full ancestor path. Results are paginated.
+func (h *Handlers) adminSearchCategories(c *fiber.Ctx) error {
+	ctx := context.Background()
+	page, limit, offset := adminPageParams(c, 50)
+	search := foldText(strings.TrimSpace(c.Query("search")))
+
+	where := newWhere("review_status IS DISTINCT FROM 'merged'")
+	for _, key := range []string{"is_active", "created_from_feed"} {
+		v, set, ok := optionalBoolQuery(c, key)
+		if !ok {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": key + " must be true or false"})
+		}
+		if set {
+			where.add("COALESCE("+key+", false) = ?", v)
+		}
+	}
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT id::text, COALESCE(parent_id::text,''), name, slug, COALESCE(icon,''), COALESCE(icon_url,''), product_count,
+		       is_active, COALESCE(created_from_feed,false), COALESCE(review_status,'')
+		FROM categories `+where.clause(), where.params()...)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	type hit struct {
+		id, name string
+		rank     int
+		data     fiber.Map
+	}
+	var hits []hit
+	for rows.Next() {
+		var id, parentID, name, slug, icon, iconURL, reviewStatus string
+		var productCount int
+		var isActive, fromFeed bool
+		rows.Scan(&id, &parentID, &name, &slug, &icon, &iconURL, &productCount, &isActive, &fromFeed, &reviewStatus)
+		folded := foldText(name)
+		pos := strings.Index(folded, search)
+		if pos < 0 {
+			continue
+		}
+		// names starting with the query first, then those containing it
+		rank := 1
+		if pos == 0 {
+			rank = 0
+		}
+		hits = append(hits, hit{id: id, name: folded, rank: rank, data: fiber.Map{
+			"id": id, "parent_id": parentID, "name": name, "slug": slug, "icon": icon, "icon_url": iconURL,
+			"product_count": productCount, "is_active": isActive, "created_from_feed": fromFeed, "review_status": reviewStatus,
+		}})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].rank != hits[j].rank {
+			return hits[i].rank < hits[j].rank
+		}
+		return hits[i].name < hits[j].name
+	})
+
+	total := int64(len(hits))
+	if offset > len(hits) {
+		offset = len(hits)
+	}
+	hits = hits[offset:]
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	ids := make([]string, len(hits))
+	for i, ht := range hits {
+		ids[i] = ht.id
+	}
+	paths, err := h.categoryPaths(ctx, ids)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	items := make([]fiber.Map, len(hits))
+	for i, ht := range hits {
+		ht.data["path"] = formatCategoryPath(paths[ht.id])
+		items[i] = ht.data
+	}
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{"items": items}, page, limit, total)})
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== BULK CATEGORY REASSIGNMENT ==========
+
+const (
+	reassignBatchSize         = 5000
+	defaultReassignSampleSize = 20
+	maxReassignSampleSize     = 100
+)
+
+// reassignFilter narrows the products of a category that are moved
+type reassignFilter struct {
+	Brand string `json:"brand,omitempty"`
+	// TitlePattern matches case-insensitively; * is a wildcard, otherwise it is a substring
+	TitlePattern string `json:"title_pattern,omitempty"`
+	FeedID       string `json:"feed_id,omitempty"`
+}
+
+// likePattern turns a title pattern into an ILIKE pattern
+func likePattern(pattern string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(pattern)
+	if !strings.Contains(escaped, "*") {
+		return "%" + escaped + "%"
+	}
+	return strings.ReplaceAll(escaped, "*", "%")
+}
+
+// where builds the product condition for sourceID; args continue from $1
+func (f reassignFilter) where(sourceID string) (string, []interface{}) {
+	conds := []string{"p.category_id = $1::uuid"}
+	args := []interface{}{sourceID}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Brand != "" {
+		add("LOWER(p.brand) = LOWER($%d)", f.Brand)
+	}
+	if f.TitlePattern != "" {
+		add("p.title ILIKE $%d", likePattern(f.TitlePattern))
+	}
+	if f.FeedID != "" {
+		add("p.feed_id = $%d::uuid", f.FeedID)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// AdminReassignCategoryProducts moves products of a category, optionally narrowed by
+// brand, title pattern or feed, to target_id. With dry_run it only counts and samples.
+// Products move in batches; each batch commits on its own, and since moved products
+// no longer match, repeating an interrupted request finishes the remainder.
+func (h *Handlers) AdminReassignCategoryProducts(c *fiber.Ctx) error {
+	sourceID := c.Params("id")
+	var input struct {
+		TargetID   string `json:"target_id"`
+		DryRun     bool   `json:"dry_run"`
+		SampleSize int    `json:"sample_size"`
+		reassignFilter
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if !isUUID(input.TargetID) {
+		return invalidUUIDField(c, "target_id")
+	}
+	if input.TargetID == sourceID {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "target_id must differ from the source category"})
+	}
+	if input.FeedID != "" && !isUUID(input.FeedID) {
+		return invalidUUIDField(c, "feed_id")
+	}
+	if input.SampleSize <= 0 {
+		input.SampleSize = defaultReassignSampleSize
+	}
+	if input.SampleSize > maxReassignSampleSize {
+		input.SampleSize = maxReassignSampleSize
+	}
+
+	ctx := context.Background()
+	var sourceName, targetName string
+	if err := h.db.Pool.QueryRow(ctx, "SELECT name FROM categories WHERE id = $1::uuid", sourceID).Scan(&sourceName); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Category not found"})
+	}
+	if err := h.db.Pool.QueryRow(ctx, "SELECT name FROM categories WHERE id = $1::uuid AND review_status IS DISTINCT FROM 'merged'", input.TargetID).Scan(&targetName); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Target category not found"})
+	}
+
+	where, args := input.reassignFilter.where(sourceID)
+
+	if input.DryRun {
+		var count int64
+		if err := h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM products p WHERE "+where, args...).Scan(&count); err != nil {
+			return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+		}
+		rows, err := h.db.Pool.Query(ctx, fmt.Sprintf(`
+			SELECT p.id, p.title, p.slug, COALESCE(p.brand,'') FROM products p WHERE %s ORDER BY p.title LIMIT %d
+		`, where, input.SampleSize), args...)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+		}
+		defer rows.Close()
+		sample := []fiber.Map{}
+		for rows.Next() {
+			var id, title, slug, brand string
+			rows.Scan(&id, &title, &slug, &brand)
+			sample = append(sample, fiber.Map{"id": id, "title": title, "slug": slug, "brand": brand})
+		}
+		return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+			"dry_run": true, "count": count, "sample": sample,
+			"source": fiber.Map{"id": sourceID, "name": sourceName},
+			"target": fiber.Map{"id": input.TargetID, "name": targetName},
+		}})
+	}
+
+	targetArg := len(args) + 1
+	update := fmt.Sprintf(`
+		UPDATE products SET category_id = $%d::uuid, updated_at = NOW(), version = COALESCE(version,1) + 1
+		WHERE id IN (SELECT p.id FROM products p WHERE %s LIMIT %d)
+		RETURNING id::text
+	`, targetArg, where, reassignBatchSize)
+	updateArgs := append(args, input.TargetID)
+
+	moved := 0
+	var moveErr error
+	for {
+		rows, err := h.db.Pool.Query(ctx, update, updateArgs...)
+		if err != nil {
+			moveErr = err
+			break
+		}
+		var ids []string
+		for rows.Next() {
+			var id string
+			rows.Scan(&id)
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			moveErr = err
+			break
+		}
+		moved += len(ids)
+		h.queueESSync(ids...)
+		if len(ids) < reassignBatchSize {
+			break
+		}
+	}
+
+	h.recountCategories(ctx, sourceID, input.TargetID)
+
+	details := fiber.Map{"target_id": input.TargetID, "filters": input.reassignFilter, "moved": moved}
+	if moveErr != nil {
+		details["error"] = moveErr.Error()
+	}
+	h.audit(ctx, c, "category.reassign_products", "category", sourceID, details)
+
+	if moveErr != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": fmt.Sprintf("Moved %d products before failing: %v; repeat the request to continue", moved, moveErr)})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"moved": moved}})
+}
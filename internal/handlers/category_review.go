@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== CATEGORY REVIEW QUEUE ==========
+
+// Feed imports create categories with review_status "pending" and is_active=false.
+// Approving makes them public, merging turns them into pointers to another category.
+
+const maxCategorySuggestions = 3
+
+// categoryNode is the part of a category row needed to walk the tree
+type categoryNode struct {
+	ID       string
+	ParentID string
+	Name     string
+	Slug     string
+	IsActive bool
+	Status   string
+}
+
+// loadCategoryNodes reads the whole category tree keyed by ID
+func (h *Handlers) loadCategoryNodes(ctx context.Context) (map[string]categoryNode, error) {
+	rows, err := h.db.Pool.Query(ctx, `SELECT id, COALESCE(parent_id::text,''), name, slug, is_active, COALESCE(review_status,'') FROM categories`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	nodes := map[string]categoryNode{}
+	for rows.Next() {
+		var n categoryNode
+		if err := rows.Scan(&n.ID, &n.ParentID, &n.Name, &n.Slug, &n.IsActive, &n.Status); err != nil {
+			return nil, err
+		}
+		nodes[n.ID] = n
+	}
+	return nodes, rows.Err()
+}
+
+// nearestActiveCategory returns id itself when it is active, otherwise its closest
+// active ancestor; ok is false when the whole path is hidden
+func nearestActiveCategory(nodes map[string]categoryNode, id string) (categoryNode, bool) {
+	for depth := 0; id != "" && depth < 50; depth++ {
+		n, exists := nodes[id]
+		if !exists {
+			break
+		}
+		if n.IsActive {
+			return n, true
+		}
+		id = n.ParentID
+	}
+	return categoryNode{}, false
+}
+
+// publicCategoryResolver maps a product's category to the one shown in public facets.
+// Products in pending categories stay searchable under the nearest active ancestor.
+func (h *Handlers) publicCategoryResolver(ctx context.Context) func(id, name, slug string) (string, string, string) {
+	nodes, err := h.loadCategoryNodes(ctx)
+	if err != nil {
+		return func(id, name, slug string) (string, string, string) { return id, name, slug }
+	}
+	return func(id, name, slug string) (string, string, string) {
+		if id == "" {
+			return id, name, slug
+		}
+		if n, ok := nearestActiveCategory(nodes, id); ok {
+			return n.ID, n.Name, n.Slug
+		}
+		return "", "", ""
+	}
+}
+
+// categoryRefs lists categories as id/name/slug for suggestions
+func categoryRefs(nodes []categoryNode) []fiber.Map {
+	refs := make([]fiber.Map, 0, len(nodes))
+	for _, n := range nodes {
+		refs = append(refs, fiber.Map{"id": n.ID, "name": n.Name, "slug": n.Slug})
+	}
+	return refs
+}
+
+// AdminPendingCategories lists categories waiting for review with their product
+// counts, suggested parents and suggested merge targets
+func (h *Handlers) AdminPendingCategories(c *fiber.Ctx) error {
+	ctx := context.Background()
+	nodes, err := h.loadCategoryNodes(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT c.id, COALESCE(c.source_path,''), COALESCE(c.created_by_feed_id::text,''), COALESCE(f.name,''), c.created_at,
+		       (SELECT COUNT(*) FROM products p WHERE p.category_id = c.id)
+		FROM categories c LEFT JOIN feeds f ON f.id = c.created_by_feed_id
+		WHERE c.review_status = 'pending'
+		ORDER BY c.created_at
+	`)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+
+	pending := []fiber.Map{}
+	for rows.Next() {
+		var id, sourcePath, feedID, feedName string
+		var createdAt time.Time
+		var productCount int64
+		rows.Scan(&id, &sourcePath, &feedID, &feedName, &createdAt, &productCount)
+		n := nodes[id]
+
+		var parents, merges []categoryNode
+		if ancestor, ok := nearestActiveCategory(nodes, n.ParentID); ok {
+			parents = append(parents, ancestor)
+		}
+		parentName := ""
+		if p, ok := nodes[n.ParentID]; ok {
+			parentName = p.Name
+		}
+		for _, other := range nodes {
+			if !other.IsActive || other.ID == id {
+				continue
+			}
+			if parentName != "" && len(parents) < maxCategorySuggestions && strings.EqualFold(other.Name, parentName) && (len(parents) == 0 || parents[0].ID != other.ID) {
+				parents = append(parents, other)
+			}
+			if len(merges) < maxCategorySuggestions && strings.EqualFold(other.Name, n.Name) {
+				merges = append(merges, other)
+			}
+		}
+
+		pending = append(pending, fiber.Map{
+			"id": id, "name": n.Name, "slug": n.Slug, "parent_id": n.ParentID, "parent_name": parentName,
+			"source_path": sourcePath, "feed_id": feedID, "feed_name": feedName, "created_at": createdAt,
+			"product_count":     productCount,
+			"suggested_parents": categoryRefs(parents),
+			"suggested_merges":  categoryRefs(merges),
+		})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": pending})
+}
+
+// syncCategoryProducts refreshes counts and re-indexes the products of the given categories
+func (h *Handlers) syncCategoryProducts(ctx context.Context, categoryIDs []string) {
+	h.recountCategories(ctx)
+	rows, err := h.db.Pool.Query(ctx, "SELECT id::text FROM products WHERE category_id = ANY($1::uuid[])", categoryIDs)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	h.queueESSync(ids...)
+}
+
+func validUUIDs(ids []string) bool {
+	for _, id := range ids {
+		if !isUUID(id) {
+			return false
+		}
+	}
+	return len(ids) > 0
+}
+
+// AdminApprovePendingCategories makes pending categories public together with any
+// pending ancestors, so an approved node is always reachable in the tree
+func (h *Handlers) AdminApprovePendingCategories(c *fiber.Ctx) error {
+	var input struct {
+		IDs []string `json:"ids"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if !validUUIDs(input.IDs) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "ids must be a non-empty list of UUIDs"})
+	}
+
+	ctx := context.Background()
+	rows, err := h.db.Pool.Query(ctx, `
+		WITH RECURSIVE up AS (
+			SELECT id, parent_id FROM categories WHERE id = ANY($1::uuid[])
+			UNION
+			SELECT c.id, c.parent_id FROM categories c JOIN up ON c.id = up.parent_id
+		)
+		UPDATE categories SET is_active = true, review_status = 'approved', curated_at = NOW(), updated_at = NOW()
+		WHERE id IN (SELECT id FROM up) AND review_status = 'pending'
+		RETURNING id::text
+	`, input.IDs)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	var approved []string
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		approved = append(approved, id)
+	}
+	rows.Close()
+
+	if len(approved) > 0 {
+		h.syncCategoryProducts(ctx, approved)
+	}
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"approved": nonNilStrings(approved)}})
+}
+
+// AdminRenamePendingCategories fixes supplier names before approval; the slug follows
+// the name unless given
+func (h *Handlers) AdminRenamePendingCategories(c *fiber.Ctx) error {
+	var input struct {
+		Categories []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			Slug string `json:"slug"`
+		} `json:"categories"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if len(input.Categories) == 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "categories required"})
+	}
+
+	ctx := context.Background()
+	renamed := 0
+	failed := []fiber.Map{}
+	for _, cat := range input.Categories {
+		name := strings.TrimSpace(cat.Name)
+		if !isUUID(cat.ID) || name == "" || len(name) > 255 {
+			failed = append(failed, fiber.Map{"id": cat.ID, "error": "id must be a UUID and name 1-255 characters"})
+			continue
+		}
+		slug := makeSlug(cat.Slug)
+		if strings.TrimSpace(cat.Slug) == "" {
+			slug = makeSlug(name)
+		}
+		tag, err := h.db.Pool.Exec(ctx, `
+			UPDATE categories SET name = $2, slug = $3, curated_at = NOW(), updated_at = NOW()
+			WHERE id = $1::uuid AND review_status = 'pending'
+		`, cat.ID, name, slug)
+		switch {
+		case err != nil:
+			failed = append(failed, fiber.Map{"id": cat.ID, "error": "slug " + slug + " is already taken"})
+		case tag.RowsAffected() == 0:
+			failed = append(failed, fiber.Map{"id": cat.ID, "error": "not a pending category"})
+		default:
+			renamed++
+		}
+	}
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"renamed": renamed, "failed": failed}})
+}
+
+// AdminMergePendingCategories moves the products and subcategories of pending
+// categories into target_id. The merged rows remain as hidden pointers so the next
+// import of the same supplier path resolves to the target.
+func (h *Handlers) AdminMergePendingCategories(c *fiber.Ctx) error {
+	var input struct {
+		IDs      []string `json:"ids"`
+		TargetID string   `json:"target_id"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if !validUUIDs(input.IDs) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "ids must be a non-empty list of UUIDs"})
+	}
+	if !isUUID(input.TargetID) {
+		return invalidUUIDField(c, "target_id")
+	}
+	if containsString(input.IDs, input.TargetID) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "target_id cannot be one of the merged categories"})
+	}
+
+	ctx := context.Background()
+	var targetStatus string
+	if err := h.db.Pool.QueryRow(ctx, "SELECT COALESCE(review_status,'') FROM categories WHERE id = $1::uuid", input.TargetID).Scan(&targetStatus); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Target category not found"})
+	}
+	if targetStatus == "merged" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Target category was itself merged"})
+	}
+
+	tx, err := h.db.Pool.Begin(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE categories SET review_status = 'merged', merged_into_id = $2::uuid, is_active = false, curated_at = NOW(), updated_at = NOW()
+		WHERE id = ANY($1::uuid[]) AND review_status = 'pending'
+	`, input.IDs, input.TargetID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if int(tag.RowsAffected()) != len(input.IDs) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Only pending categories can be merged"})
+	}
+	moved, err := tx.Exec(ctx, "UPDATE products SET category_id = $2::uuid, updated_at = NOW(), version = COALESCE(version,1) + 1 WHERE category_id = ANY($1::uuid[])", input.IDs, input.TargetID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if _, err := tx.Exec(ctx, "UPDATE categories SET parent_id = $2::uuid, updated_at = NOW() WHERE parent_id = ANY($1::uuid[])", input.IDs, input.TargetID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	// earlier merges into the merged categories now point at the target too
+	if _, err := tx.Exec(ctx, "UPDATE categories SET merged_into_id = $2::uuid WHERE merged_into_id = ANY($1::uuid[])", input.IDs, input.TargetID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	h.syncCategoryProducts(ctx, []string{input.TargetID})
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"merged": tag.RowsAffected(), "products_moved": moved.RowsAffected()}})
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/settings"
+)
+
+// ========== PRODUCT COMPLETENESS ==========
+
+const (
+	completenessGalleryImages    = 3
+	completenessDescriptionChars = 300
+	completenessAttributes       = 5
+	completenessBatch            = 1000
+)
+
+// CompletenessWeights weighs the checks behind the completeness score; the score is
+// the share of the total weight a product passes. Stored in the settings table.
+type CompletenessWeights struct {
+	Image       int `json:"image"`
+	Gallery     int `json:"gallery"`
+	Description int `json:"description"`
+	EAN         int `json:"ean"`
+	Attributes  int `json:"attributes"`
+	Category    int `json:"category"`
+	Brand       int `json:"brand"`
+}
+
+func defaultCompletenessWeights() CompletenessWeights {
+	return CompletenessWeights{Image: 20, Gallery: 10, Description: 20, EAN: 15, Attributes: 15, Category: 10, Brand: 10}
+}
+
+func (w CompletenessWeights) total() int {
+	return w.Image + w.Gallery + w.Description + w.EAN + w.Attributes + w.Category + w.Brand
+}
+
+func (w CompletenessWeights) validate() string {
+	for _, v := range []int{w.Image, w.Gallery, w.Description, w.EAN, w.Attributes, w.Category, w.Brand} {
+		if v < 0 || v > 100 {
+			return "weights must be between 0 and 100"
+		}
+	}
+	if w.total() == 0 {
+		return "at least one weight must be positive"
+	}
+	return ""
+}
+
+// completenessWeights returns the configured weights, falling back to the defaults
+// for a missing setting or missing keys
+func (h *Handlers) completenessWeights() CompletenessWeights {
+	weights := defaultCompletenessWeights()
+	if err := h.settings.Decode(context.Background(), settings.KeyCompletenessWeights, &weights); err != nil || weights.validate() != "" {
+		if err != nil {
+			log.Printf("Completeness weights unreadable, using defaults: %v", err)
+		}
+		weights = defaultCompletenessWeights()
+	}
+	return weights
+}
+
+// completenessInput is what the checks look at for one product
+type completenessInput struct {
+	hasImage       bool
+	images         int
+	descriptionLen int
+	hasEAN         bool
+	attributes     int
+	hasCategory    bool
+	brand          string
+}
+
+// score returns the weighted completeness percentage. A brand counts as normalized
+// when it is spelled like the brand's canonical name; canonical maps brand slugs
+// to that name and brands without an entry count as normalized.
+func (in completenessInput) score(w CompletenessWeights, canonical map[string]string) int {
+	passed := 0
+	if in.hasImage {
+		passed += w.Image
+	}
+	if in.images >= completenessGalleryImages {
+		passed += w.Gallery
+	}
+	if in.descriptionLen >= completenessDescriptionChars {
+		passed += w.Description
+	}
+	if in.hasEAN {
+		passed += w.EAN
+	}
+	if in.attributes >= completenessAttributes {
+		passed += w.Attributes
+	}
+	if in.hasCategory {
+		passed += w.Category
+	}
+	if in.brand != "" {
+		if name, ok := canonical[makeSlug(in.brand)]; !ok || name == in.brand {
+			passed += w.Brand
+		}
+	}
+	return int(math.Round(float64(passed) * 100 / float64(w.total())))
+}
+
+// canonicalBrands maps brand slugs to the canonical spelling used by brand pages
+func (h *Handlers) canonicalBrands(ctx context.Context) map[string]string {
+	brands, _ := h.brandIndex(ctx)
+	canonical := make(map[string]string, len(brands))
+	for _, b := range brands {
+		canonical[b.Slug] = b.Name
+	}
+	return canonical
+}
+
+// refreshCompleteness recomputes the score of the products matching cond, a condition
+// on products p that may reference $1...; it returns how many scores changed
+func (h *Handlers) refreshCompleteness(ctx context.Context, cond string, args ...interface{}) (int64, error) {
+	weights := h.completenessWeights()
+	canonical := h.canonicalBrands(ctx)
+	after := "00000000-0000-0000-0000-000000000000"
+	n := len(args)
+	query := `
+		SELECT p.id::text, COALESCE(p.image_url,'') <> '',
+		       (SELECT COUNT(*) FROM product_images i WHERE i.product_id = p.id) +
+		       (SELECT COUNT(*) FROM product_media m WHERE m.product_id = p.id AND m.type = 'image'),
+		       char_length(COALESCE(NULLIF(p.description_plain,''), p.description, '')),
+		       COALESCE(p.ean,'') <> '',
+		       (SELECT COUNT(*) FROM product_attributes a WHERE a.product_id = p.id),
+		       p.category_id IS NOT NULL, COALESCE(p.brand,'')
+		FROM products p
+		WHERE (` + cond + `) AND p.id > $` + strconv.Itoa(n+1) + `::uuid
+		ORDER BY p.id LIMIT ` + strconv.Itoa(completenessBatch)
+
+	var changed int64
+	for {
+		rows, err := h.db.Pool.Query(ctx, query, append(args, after)...)
+		if err != nil {
+			return changed, err
+		}
+		var ids []string
+		var scores []int32
+		for rows.Next() {
+			var id string
+			var in completenessInput
+			rows.Scan(&id, &in.hasImage, &in.images, &in.descriptionLen, &in.hasEAN, &in.attributes, &in.hasCategory, &in.brand)
+			ids = append(ids, id)
+			scores = append(scores, int32(in.score(weights, canonical)))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return changed, err
+		}
+		if len(ids) == 0 {
+			return changed, nil
+		}
+		tag, err := h.db.Pool.Exec(ctx, `
+			UPDATE products p SET completeness = v.score
+			FROM unnest($1::uuid[], $2::int[]) AS v(id, score)
+			WHERE p.id = v.id AND p.completeness IS DISTINCT FROM v.score
+		`, ids, scores)
+		if err != nil {
+			return changed, err
+		}
+		changed += tag.RowsAffected()
+		if len(ids) < completenessBatch {
+			return changed, nil
+		}
+		after = ids[len(ids)-1]
+	}
+}
+
+// refreshProductCompleteness rescores products after an admin write; failures only
+// leave the score stale until the next write or recompute
+func (h *Handlers) refreshProductCompleteness(ctx context.Context, ids ...string) {
+	if len(ids) == 0 {
+		return
+	}
+	if _, err := h.refreshCompleteness(ctx, "p.id = ANY($1::uuid[])", ids); err != nil {
+		log.Printf("Completeness of %d products not refreshed: %v", len(ids), err)
+	}
+}
+
+// CompletenessBucket is one bar of the dashboard distribution, ten points wide;
+// the last bucket includes 100
+type CompletenessBucket struct {
+	From  int   `json:"from"`
+	To    int   `json:"to"`
+	Count int64 `json:"count"`
+}
+
+// completenessDistribution counts scored products per bucket and returns the average
+func (h *Handlers) completenessDistribution(ctx context.Context) ([]CompletenessBucket, float64, error) {
+	buckets := make([]CompletenessBucket, 10)
+	for i := range buckets {
+		buckets[i] = CompletenessBucket{From: i * 10, To: i*10 + 9}
+	}
+	buckets[9].To = 100
+
+	rows, err := h.db.Pool.Query(ctx, "SELECT LEAST(completeness / 10, 9), COUNT(*) FROM products WHERE completeness IS NOT NULL GROUP BY 1")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bucket int
+		var count int64
+		rows.Scan(&bucket, &count)
+		if bucket >= 0 && bucket < len(buckets) {
+			buckets[bucket].Count = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	var avg float64
+	h.db.Pool.QueryRow(ctx, "SELECT COALESCE(AVG(completeness),0) FROM products WHERE completeness IS NOT NULL").Scan(&avg)
+	return buckets, math.Round(avg*10) / 10, nil
+}
+
+func completenessChecks() fiber.Map {
+	return fiber.Map{
+		"gallery_images": completenessGalleryImages, "description_chars": completenessDescriptionChars, "attributes": completenessAttributes,
+	}
+}
+
+func (h *Handlers) AdminGetCompleteness(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"weights": h.completenessWeights(), "thresholds": completenessChecks()}})
+}
+
+// AdminSetCompleteness stores new weights and rescores every product in the background
+func (h *Handlers) AdminSetCompleteness(c *fiber.Ctx) error {
+	weights := defaultCompletenessWeights()
+	if err := json.Unmarshal(c.Body(), &weights); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if msg := weights.validate(); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+
+	ctx := context.Background()
+	if _, err := h.settings.SetValue(ctx, settings.KeyCompletenessWeights, weights, auditActor(c)); err != nil {
+		return settingsError(c, h.settings.Keys(), err)
+	}
+	h.audit(ctx, c, "completeness.weights", "settings", "", fiber.Map{"weights": weights})
+
+	go func() {
+		start := time.Now()
+		changed, err := h.refreshCompleteness(context.Background(), "TRUE")
+		if err != nil {
+			log.Printf("Completeness recompute failed after %d products: %v", changed, err)
+			return
+		}
+		log.Printf("Completeness recomputed: %d scores changed in %s", changed, time.Since(start).Round(time.Millisecond))
+	}()
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"weights": weights, "recompute": "started"}})
+}
+
+// AdminRecomputeCompleteness rescores every product with the current weights
+func (h *Handlers) AdminRecomputeCompleteness(c *fiber.Ctx) error {
+	ctx := context.Background()
+	start := time.Now()
+	changed, err := h.refreshCompleteness(ctx, "TRUE")
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"changed": changed, "duration_ms": time.Since(start).Milliseconds()}})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/database"
+)
+
+// ========== DB DIAGNOSTICS ==========
+
+// DebugDB reports pool usage, traced slow queries and statements running longer than 5 seconds
+func (h *Handlers) DebugDB(c *fiber.Ctx) error {
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	ctx := context.Background()
+
+	st := h.db.Pool.Stat()
+	pool := fiber.Map{
+		"total_conns":                st.TotalConns(),
+		"idle_conns":                 st.IdleConns(),
+		"acquired_conns":             st.AcquiredConns(),
+		"constructing_conns":         st.ConstructingConns(),
+		"max_conns":                  st.MaxConns(),
+		"acquire_count":              st.AcquireCount(),
+		"acquire_duration_ms":        st.AcquireDuration().Milliseconds(),
+		"empty_acquire_count":        st.EmptyAcquireCount(),
+		"canceled_acquire_count":     st.CanceledAcquireCount(),
+		"new_conns_count":            st.NewConnsCount(),
+		"max_lifetime_destroy_count": st.MaxLifetimeDestroyCount(),
+		"max_idle_destroy_count":     st.MaxIdleDestroyCount(),
+	}
+
+	var slowQueries interface{} = nil
+	if h.db.Tracer != nil {
+		slow := h.db.Tracer.Slowest(limit)
+		for i := range slow {
+			slow[i].Query = database.RedactSecrets(slow[i].Query)
+		}
+		slowQueries = slow
+	}
+
+	longRunning := []fiber.Map{}
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT pid, COALESCE(state,''), COALESCE(wait_event_type,''), EXTRACT(EPOCH FROM NOW() - query_start) * 1000, LEFT(query, 500)
+		FROM pg_stat_activity
+		WHERE datname = current_database() AND pid <> pg_backend_pid()
+		  AND state <> 'idle' AND query_start < NOW() - INTERVAL '5 seconds'
+		ORDER BY query_start LIMIT $1
+	`, limit)
+	if err == nil {
+		for rows.Next() {
+			var pid int
+			var state, waitEvent, query string
+			var durationMs float64
+			rows.Scan(&pid, &state, &waitEvent, &durationMs, &query)
+			longRunning = append(longRunning, fiber.Map{
+				"pid": pid, "state": state, "wait_event_type": waitEvent,
+				"duration_ms": durationMs, "query": database.RedactSecrets(query),
+			})
+		}
+		rows.Close()
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"pool":            pool,
+		"tracing_enabled": h.db.Tracer != nil,
+		"slow_queries":    slowQueries,
+		"long_running":    longRunning,
+	}})
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/settings"
+)
+
+// ========== DEFAULT LISTING SORT ==========
+
+const (
+	fallbackListingSort = "newest"
+	relevanceSort       = "relevance"
+	listingSortTTL      = 30 * time.Second
+)
+
+func invalidListingSort(c *fiber.Ctx) error {
+	return c.Status(400).JSON(fiber.Map{"success": false, "error": "default_sort must be one of " + strings.Join(listingSorts, ", ")})
+}
+
+// ListingSortSettings is the "listing_sort" setting: the sort of listings whose
+// category (and its ancestors) sets none
+type ListingSortSettings struct {
+	DefaultSort string `json:"default_sort"`
+}
+
+// listingSortDefaults holds the global default sort and the category tree's own ones
+type listingSortDefaults struct {
+	global       string
+	own, parents map[string]string
+	// slugs maps category slugs to IDs for listings filtered by slug
+	slugs map[string]string
+}
+
+var (
+	listingSortMutex    sync.RWMutex
+	listingSortCache    *listingSortDefaults
+	listingSortLoadedAt time.Time
+)
+
+func invalidateListingSorts() {
+	listingSortMutex.Lock()
+	listingSortCache = nil
+	listingSortMutex.Unlock()
+}
+
+func (h *Handlers) listingSortDefaults(ctx context.Context) *listingSortDefaults {
+	listingSortMutex.RLock()
+	cached, fresh := listingSortCache, time.Since(listingSortLoadedAt) < listingSortTTL
+	listingSortMutex.RUnlock()
+	if cached != nil && fresh {
+		return cached
+	}
+
+	d := &listingSortDefaults{global: fallbackListingSort, own: map[string]string{}, parents: map[string]string{}, slugs: map[string]string{}}
+	if stored, err := settings.Get[ListingSortSettings](ctx, h.settings, settings.KeyListingSort); err == nil && containsString(listingSorts, stored.DefaultSort) {
+		d.global = stored.DefaultSort
+	}
+	rows, err := h.db.ReadPool.Query(ctx, "SELECT id::text, COALESCE(parent_id::text,''), slug, COALESCE(default_sort,'') FROM categories")
+	if err != nil {
+		if cached != nil {
+			return cached
+		}
+		return d
+	}
+	for rows.Next() {
+		var id, parentID, slug, sort string
+		rows.Scan(&id, &parentID, &slug, &sort)
+		d.parents[id], d.slugs[slug] = parentID, id
+		if sort != "" {
+			d.own[id] = sort
+		}
+	}
+	rows.Close()
+
+	listingSortMutex.Lock()
+	listingSortCache, listingSortLoadedAt = d, time.Now()
+	listingSortMutex.Unlock()
+	return d
+}
+
+// forCategory returns the sort of the category or its nearest ancestor with one, and
+// the category it comes from; without one it is the global default from no category
+func (d *listingSortDefaults) forCategory(categoryID string) (string, string) {
+	seen := map[string]bool{}
+	for id := categoryID; id != "" && !seen[id]; id = d.parents[id] {
+		if sort := d.own[id]; sort != "" {
+			return sort, id
+		}
+		seen[id] = true
+	}
+	return d.global, ""
+}
+
+// forSlugs is the default sort of a listing filtered by category slugs: that of the
+// category when exactly one is given, else the global one
+func (d *listingSortDefaults) forSlugs(slugs []string) string {
+	if len(slugs) == 1 {
+		if id, ok := d.slugs[slugs[0]]; ok {
+			sort, _ := d.forCategory(id)
+			return sort
+		}
+	}
+	return d.global
+}
+
+// effectiveListingSort is the sort a listing runs: the requested one, the default when
+// none was sent, or newest for an unknown one (as listingOrder does)
+func effectiveListingSort(requested string, defaultSort func() string) string {
+	switch {
+	case requested == "":
+		return defaultSort()
+	case containsString(listingSorts, requested):
+		return requested
+	}
+	return fallbackListingSort
+}
+
+// effectiveSearchSort is effectiveListingSort for search. A text query sorts by
+// relevance unless another sort is requested; browsing without one uses the listing
+// default, and relevance means newest there (as the search index does).
+func effectiveSearchSort(requested, query string, defaultSort func() string) string {
+	switch {
+	case containsString(listingSorts, requested):
+		return requested
+	case query != "":
+		return relevanceSort
+	case requested == "":
+		return defaultSort()
+	}
+	return fallbackListingSort
+}
+
+func (h *Handlers) GetListingSortSettings(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"success": true, "data": ListingSortSettings{DefaultSort: h.listingSortDefaults(context.Background()).global}})
+}
+
+// SetListingSortSettings changes the sort of listings whose category sets none
+func (h *Handlers) SetListingSortSettings(c *fiber.Ctx) error {
+	var input ListingSortSettings
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if !containsString(listingSorts, input.DefaultSort) {
+		return invalidListingSort(c)
+	}
+
+	ctx := context.Background()
+	if _, err := h.settings.SetValue(ctx, settings.KeyListingSort, input, auditActor(c)); err != nil {
+		return settingsError(c, h.settings.Keys(), err)
+	}
+	h.audit(ctx, c, "listing_sort.update", "settings", "", fiber.Map{"default_sort": input.DefaultSort})
+	return c.JSON(fiber.Map{"success": true, "data": input})
+}
+
+func (h *Handlers) GetCategoryDefaultSort(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx := context.Background()
+	var sort string
+	if err := h.db.Pool.QueryRow(ctx, "SELECT COALESCE(default_sort,'') FROM categories WHERE id = $1::uuid", id).Scan(&sort); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Category not found"})
+	}
+	effective, from := h.listingSortDefaults(ctx).forCategory(id)
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"default_sort": sort, "effective_sort": effective, "inherited_from": inheritedFrom(id, from)}})
+}
+
+// SetCategoryDefaultSort changes the default sort of a category's listings, its
+// subcategories included unless they set their own; an empty sort inherits
+func (h *Handlers) SetCategoryDefaultSort(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var input struct {
+		DefaultSort string `json:"default_sort"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if input.DefaultSort != "" && !containsString(listingSorts, input.DefaultSort) {
+		return invalidListingSort(c)
+	}
+
+	ctx := context.Background()
+	tag, err := h.db.Pool.Exec(ctx, "UPDATE categories SET default_sort = NULLIF($2,''), updated_at = NOW() WHERE id = $1::uuid", id, input.DefaultSort)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Category not found"})
+	}
+	h.audit(ctx, c, "category.default_sort", "category", id, fiber.Map{"default_sort": input.DefaultSort})
+	invalidateListingSorts()
+
+	effective, from := h.listingSortDefaults(ctx).forCategory(id)
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"default_sort": input.DefaultSort, "effective_sort": effective, "inherited_from": inheritedFrom(id, from)}})
+}
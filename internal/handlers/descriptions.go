@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== DESCRIPTION PLAIN TEXT ==========
+
+const descriptionBackfillBatch = 500
+
+// descriptionVariants derives the stored description_plain and excerpt from an HTML description
+func descriptionVariants(description string) (plain, excerpt string) {
+	plain = models.PlainText(description)
+	return plain, models.Excerpt(plain)
+}
+
+type DescriptionBackfillProgress struct {
+	Status     string     `json:"status"`
+	Total      int64      `json:"total"`
+	Processed  int64      `json:"processed"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+var (
+	descriptionBackfill      *DescriptionBackfillProgress
+	descriptionBackfillMutex sync.Mutex
+)
+
+// StartDescriptionBackfill fills description_plain and excerpt of existing products in
+// the background; ?all=true regenerates rows that already have them
+func (h *Handlers) StartDescriptionBackfill(c *fiber.Ctx) error {
+	all := c.QueryBool("all")
+	where := "WHERE description_plain IS NULL"
+	if all {
+		where = ""
+	}
+
+	descriptionBackfillMutex.Lock()
+	if descriptionBackfill != nil && descriptionBackfill.Status == "running" {
+		descriptionBackfillMutex.Unlock()
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "Backfill already running"})
+	}
+	progress := &DescriptionBackfillProgress{Status: "running", StartedAt: time.Now()}
+	descriptionBackfill = progress
+	descriptionBackfillMutex.Unlock()
+
+	ctx := context.Background()
+	var total int64
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM products "+where).Scan(&total)
+	descriptionBackfillMutex.Lock()
+	progress.Total = total
+	descriptionBackfillMutex.Unlock()
+
+	h.audit(ctx, c, "descriptions.backfill", "product", "", fiber.Map{"all": all, "total": total})
+	go h.backfillDescriptions(progress, all)
+	return c.Status(202).JSON(fiber.Map{"success": true, "data": progress.snapshot()})
+}
+
+// backfillDescriptions walks products by ID in batches so it never holds long locks
+func (h *Handlers) backfillDescriptions(progress *DescriptionBackfillProgress, all bool) {
+	ctx := context.Background()
+	status, errMsg := "completed", ""
+	after := "00000000-0000-0000-0000-000000000000"
+	for {
+		rows, err := h.db.Pool.Query(ctx, `
+			SELECT id::text, COALESCE(description,'') FROM products
+			WHERE id > $1::uuid AND ($2 OR description_plain IS NULL)
+			ORDER BY id LIMIT $3
+		`, after, all, descriptionBackfillBatch)
+		if err != nil {
+			status, errMsg = "failed", err.Error()
+			break
+		}
+		var ids, plains, excerpts []string
+		for rows.Next() {
+			var id, description string
+			rows.Scan(&id, &description)
+			plain, excerpt := descriptionVariants(description)
+			ids, plains, excerpts = append(ids, id), append(plains, plain), append(excerpts, excerpt)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			status, errMsg = "failed", err.Error()
+			break
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		if _, err := h.db.Pool.Exec(ctx, `
+			UPDATE products p SET description_plain = v.plain, excerpt = v.excerpt
+			FROM unnest($1::uuid[], $2::text[], $3::text[]) AS v(id, plain, excerpt)
+			WHERE p.id = v.id
+		`, ids, plains, excerpts); err != nil {
+			status, errMsg = "failed", err.Error()
+			break
+		}
+		h.queueESSync(ids...)
+		after = ids[len(ids)-1]
+
+		descriptionBackfillMutex.Lock()
+		progress.Processed += int64(len(ids))
+		descriptionBackfillMutex.Unlock()
+	}
+
+	now := time.Now()
+	descriptionBackfillMutex.Lock()
+	progress.Status, progress.Error, progress.FinishedAt = status, errMsg, &now
+	processed := progress.Processed
+	descriptionBackfillMutex.Unlock()
+	log.Printf("Description backfill %s: %d products", status, processed)
+}
+
+func (p *DescriptionBackfillProgress) snapshot() DescriptionBackfillProgress {
+	descriptionBackfillMutex.Lock()
+	defer descriptionBackfillMutex.Unlock()
+	return *p
+}
+
+func (h *Handlers) GetDescriptionBackfill(c *fiber.Ctx) error {
+	descriptionBackfillMutex.Lock()
+	progress := descriptionBackfill
+	descriptionBackfillMutex.Unlock()
+	if progress == nil {
+		return c.JSON(fiber.Map{"success": true, "data": nil})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": progress.snapshot()})
+}
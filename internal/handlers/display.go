@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/display"
+	"megabuy-go/internal/models"
+	"megabuy-go/internal/money"
+)
+
+// ========== DISPLAY STRINGS ==========
+
+// displayLocale reports whether ?include_display=true asks for display strings, and
+// the ?locale= to word them in
+func displayLocale(c *fiber.Ctx) (string, bool) {
+	if !c.QueryBool("include_display") {
+		return "", false
+	}
+	return display.NormalizeLocale(c.Query("locale")), true
+}
+
+func listItemDisplay(p models.ProductCard, locale string) *display.Product {
+	var was money.Price
+	if p.Promo != nil {
+		was = p.Promo.Was
+	}
+	return display.NewProduct(locale, p.PriceMin, p.PriceMax, was, p.StockStatus)
+}
+
+// addListDisplay sets the display strings of listed products when the request asks for them
+func addListDisplay(c *fiber.Ctx, items []models.ProductCard) {
+	locale, ok := displayLocale(c)
+	if !ok {
+		return
+	}
+	for i := range items {
+		items[i].Display = listItemDisplay(items[i], locale)
+	}
+}
+
+func addRelatedDisplay(c *fiber.Ctx, related []relatedProduct) {
+	locale, ok := displayLocale(c)
+	if !ok {
+		return
+	}
+	for i := range related {
+		related[i].Display = listItemDisplay(related[i].ProductCard, locale)
+	}
+}
+
+func addDetailDisplay(c *fiber.Ctx, p *models.ProductDetail) {
+	locale, ok := displayLocale(c)
+	if !ok {
+		return
+	}
+	var was money.Price
+	if p.Promo != nil {
+		was = p.Promo.Was
+	}
+	p.Display = display.NewProduct(locale, p.PriceMin, p.PriceMax, was, p.StockStatus)
+}
+
+// addOfferDisplay sets the display strings of offers as built by productOffers
+func addOfferDisplay(c *fiber.Ctx, offers []fiber.Map) {
+	locale, ok := displayLocale(c)
+	if !ok {
+		return
+	}
+	for _, o := range offers {
+		price, _ := o["price"].(money.Price)
+		shipping, _ := o["shipping_price"].(money.Price)
+		stockStatus, _ := o["stock_status"].(string)
+		deliveryDays, _ := o["delivery_days"].(string)
+		o["display"] = display.NewOffer(locale, price, shipping, stockStatus, deliveryDays)
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/display"
+	"megabuy-go/internal/models"
+	"megabuy-go/internal/money"
+)
+
+func TestIncludeDisplay(t *testing.T) {
+	app := fiber.New()
+	app.Get("/products", func(c *fiber.Ctx) error {
+		products := []models.ProductCard{
+			{ID: "a", PriceMin: money.New(1299.9), PriceMax: money.New(1299.9), StockStatus: "instock",
+				Promo: &models.PromoPrice{Was: money.New(1499), Now: money.New(1299.9)}},
+			{ID: "b", PriceMin: money.New(5), PriceMax: money.New(7.5), StockStatus: "outofstock"},
+		}
+		addListDisplay(c, products)
+		return c.JSON(fiber.Map{"success": true, "data": products})
+	})
+	app.Get("/offers", func(c *fiber.Ctx) error {
+		offers := []fiber.Map{
+			{"price": money.New(24.9), "shipping_price": money.New(0), "stock_status": "instock", "delivery_days": "2-3"},
+			{"price": money.New(22.5), "shipping_price": money.New(3.5), "stock_status": "instock"},
+		}
+		addOfferDisplay(c, offers)
+		return c.JSON(fiber.Map{"success": true, "data": offers})
+	})
+
+	var products []models.ProductCard
+	decode := func(path string, v any) {
+		t.Helper()
+		status, resp := callJSON(t, app, "GET", path, nil)
+		if status != 200 {
+			t.Fatalf("GET %s: %d %s", path, status, resp.Error)
+		}
+		if err := json.Unmarshal(resp.Data, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	decode("/products", &products)
+	if products[0].Display != nil || products[1].Display != nil {
+		t.Error("display strings sent without include_display")
+	}
+
+	decode("/products?include_display=true", &products)
+	want := []display.Product{
+		{Price: "1 299,90 €", WasPrice: "1 499,00 €", Availability: "Skladom"},
+		{Price: "5,00 €", PriceMax: "7,50 €", Availability: "Vypredané"},
+	}
+	for i, w := range want {
+		if products[i].Display == nil || *products[i].Display != w {
+			t.Errorf("product %s display %+v, want %+v", products[i].ID, products[i].Display, w)
+		}
+	}
+
+	decode("/products?include_display=true&locale=en-GB", &products)
+	if d := products[0].Display; d == nil || d.Price != "€1,299.90" || d.Availability != "In stock" {
+		t.Errorf("English display %+v", d)
+	}
+
+	var offers []struct {
+		Display *display.Offer `json:"display"`
+	}
+	decode("/offers?include_display=1&locale=sk", &offers)
+	wantOffers := []display.Offer{
+		{Price: "24,90 €", Shipping: "Doprava zdarma", TotalPrice: "24,90 €", Availability: "Do 3 dní"},
+		{Price: "22,50 €", Shipping: "Doprava 3,50 €", TotalPrice: "26,00 €", Availability: "Skladom"},
+	}
+	for i, w := range wantOffers {
+		if offers[i].Display == nil || *offers[i].Display != w {
+			t.Errorf("offer %d display %+v, want %+v", i, offers[i].Display, w)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== EAN NORMALIZATION ==========
+
+const (
+	eanBackfillBatch = 1000
+	// maxEANDuplicates caps the duplicate groups listed by the backfill report
+	maxEANDuplicates = 200
+)
+
+// splitEAN returns the normalized EAN for matching, or, when the code is invalid,
+// an empty EAN and the trimmed input to keep as ean_raw
+func splitEAN(raw string) (ean, eanRaw string) {
+	normalized, ok := models.NormalizeEAN(raw)
+	if !ok {
+		return "", strings.TrimSpace(raw)
+	}
+	return normalized, ""
+}
+
+// isDuplicateEAN reports a write rejected because another product holds the EAN
+func isDuplicateEAN(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_products_ean_unique"
+}
+
+func duplicateEAN(c *fiber.Ctx) error {
+	return c.Status(409).JSON(fiber.Map{"success": false, "error": "Another product already has this EAN"})
+}
+
+type eanOutcome int
+
+const (
+	eanUnchanged eanOutcome = iota
+	eanNormalized
+	eanInvalid
+)
+
+// normalizeItemEAN rewrites the mapped "ean" of an import item in place and moves an
+// invalid code to "ean_raw", so matching and duplicate detection only see valid EANs
+func normalizeItemEAN(data map[string]interface{}) eanOutcome {
+	raw := getStr(data, "ean")
+	if raw == "" {
+		return eanUnchanged
+	}
+	ean, eanRaw := splitEAN(raw)
+	data["ean"], data["ean_raw"] = ean, eanRaw
+	switch {
+	case eanRaw != "":
+		return eanInvalid
+	case ean != raw:
+		return eanNormalized
+	}
+	return eanUnchanged
+}
+
+type eanDuplicate struct {
+	EAN        string   `json:"ean"`
+	ProductIDs []string `json:"product_ids"`
+}
+
+// AdminNormalizeEANs renormalizes the stored EAN of every product: valid codes are
+// rewritten to canonical form and invalid ones move to ean_raw. EANs are unique, so
+// when normalization makes a code collide with one already held, the newer product
+// keeps it only in ean_raw. The report lists the EANs that several products share
+// after normalization, which usually are the same item imported from different
+// feeds. ?dry_run=true reports without writing.
+func (h *Handlers) AdminNormalizeEANs(c *fiber.Ctx) error {
+	dryRun := c.QueryBool("dry_run")
+	ctx := context.Background()
+
+	rows, err := h.db.Pool.Query(ctx, "SELECT id::text, ean FROM products WHERE COALESCE(ean,'') <> '' ORDER BY created_at, id")
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	type storedEAN struct{ id, stored, ean, raw string }
+	var all []storedEAN
+	byEAN := map[string][]string{}
+	// codes already stored in canonical form are held; rewrites must not collide with them
+	held := map[string]bool{}
+	for rows.Next() {
+		var s storedEAN
+		rows.Scan(&s.id, &s.stored)
+		s.ean, s.raw = splitEAN(s.stored)
+		if s.ean != "" {
+			byEAN[s.ean] = append(byEAN[s.ean], s.id)
+		}
+		if s.ean == s.stored {
+			held[s.ean] = true
+		}
+		all = append(all, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	checked := len(all)
+
+	var ids, eans, raws []string
+	invalid, conflicts := 0, 0
+	for _, s := range all {
+		if s.ean == s.stored {
+			continue
+		}
+		switch {
+		case s.raw != "":
+			invalid++
+		case held[s.ean]:
+			conflicts++
+			s.ean, s.raw = "", s.stored
+		default:
+			held[s.ean] = true
+		}
+		ids, eans, raws = append(ids, s.id), append(eans, s.ean), append(raws, s.raw)
+	}
+	duplicates := []eanDuplicate{}
+	duplicateGroups := 0
+	for ean, productIDs := range byEAN {
+		if len(productIDs) < 2 {
+			continue
+		}
+		duplicateGroups++
+		if len(duplicates) < maxEANDuplicates {
+			duplicates = append(duplicates, eanDuplicate{EAN: ean, ProductIDs: productIDs})
+		}
+	}
+	report := fiber.Map{
+		"dry_run": dryRun, "checked": checked, "normalized": len(ids) - invalid - conflicts, "invalid": invalid, "conflicts": conflicts,
+		"duplicate_groups": duplicateGroups, "duplicates": duplicates,
+	}
+	if dryRun {
+		return c.JSON(fiber.Map{"success": true, "data": report})
+	}
+
+	for start := 0; start < len(ids); start += eanBackfillBatch {
+		end := start + eanBackfillBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if _, err := h.db.Pool.Exec(ctx, `
+			UPDATE products p SET ean = NULLIF(v.ean,''), ean_raw = COALESCE(NULLIF(v.raw,''), p.ean_raw), updated_at = NOW()
+			FROM unnest($1::uuid[], $2::text[], $3::text[]) AS v(id, ean, raw)
+			WHERE p.id = v.id
+		`, ids[start:end], eans[start:end], raws[start:end]); err != nil {
+			return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+		}
+		h.queueESSync(ids[start:end]...)
+	}
+	h.audit(ctx, c, "ean.normalize", "product", "", fiber.Map{"checked": checked, "normalized": len(ids) - invalid - conflicts, "invalid": invalid, "conflicts": conflicts, "duplicate_groups": duplicateGroups})
+	return c.JSON(fiber.Map{"success": true, "data": report})
+}
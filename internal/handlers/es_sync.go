@@ -0,0 +1,544 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/elasticsearch"
+	"megabuy-go/internal/models"
+)
+
+// ========== ELASTICSEARCH SYNC QUEUE ==========
+
+const (
+	esSyncBatchSize = 500
+	// esSyncMaxAttempts failed writes dead-letter an entry; retries wait
+	// esSyncRetryBase doubled per attempt, at most esSyncRetryMax
+	esSyncMaxAttempts = 8
+	esSyncRetryBase   = 30 * time.Second
+	esSyncRetryMax    = time.Hour
+	// esSyncLease keeps a claimed entry from other workers until its write finishes
+	esSyncLease = 5 * time.Minute
+	// esSyncPollInterval is how often the worker looks for retries that became due
+	esSyncPollInterval = 15 * time.Second
+)
+
+// esSyncQueue wakes the worker for product changes persisted in es_sync_queue and
+// counts its results. pending only holds ids the database did not take; they are
+// sent best-effort on the next flush.
+type esSyncQueue struct {
+	mu      sync.Mutex
+	pending map[string]struct{}
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+
+	indexed   atomic.Int64
+	deleted   atomic.Int64
+	failed    atomic.Int64
+	lastFlush atomic.Int64
+}
+
+func newESSyncQueue() *esSyncQueue {
+	return &esSyncQueue{
+		pending: make(map[string]struct{}),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (q *esSyncQueue) depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// take removes up to n ids from the queue
+func (q *esSyncQueue) take(n int) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ids := make([]string, 0, n)
+	for id := range q.pending {
+		if len(ids) == n {
+			break
+		}
+		ids = append(ids, id)
+		delete(q.pending, id)
+	}
+	return ids
+}
+
+// queueESSync schedules products for (re)indexing; ids that no longer exist are removed from the index
+func (h *Handlers) queueESSync(ids ...string) {
+	h.queueESOp("index", ids)
+}
+
+// queueESDelete schedules deleted products for removal from the index
+func (h *Handlers) queueESDelete(ids ...string) {
+	h.queueESOp("delete", ids)
+}
+
+func (h *Handlers) queueESOp(op string, ids []string) {
+	if h.es == nil || len(ids) == 0 {
+		return
+	}
+	q := h.esQueue
+	if err := h.enqueueESSync(context.Background(), op, "SELECT unnest($2::uuid[])", ids); err != nil {
+		log.Printf("ES sync: queueing %d products failed, kept in memory: %v", len(ids), err)
+		q.mu.Lock()
+		for _, id := range ids {
+			q.pending[id] = struct{}{}
+		}
+		q.mu.Unlock()
+	}
+	q.notify()
+}
+
+func (q *esSyncQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// enqueueESSync persists op for the product ids selected by query, which sees op as
+// $1 and args from $2. A product queued again gets a fresh set of attempts.
+func (h *Handlers) enqueueESSync(ctx context.Context, op, query string, args ...interface{}) error {
+	_, err := h.db.Pool.Exec(ctx, `
+		INSERT INTO es_sync_queue (product_id, op)
+		SELECT DISTINCT id, $1 FROM (`+query+`) AS s(id) WHERE id IS NOT NULL
+		ON CONFLICT (product_id) DO UPDATE SET op = EXCLUDED.op, attempts = 0, version = es_sync_queue.version + 1,
+		       next_attempt_at = NOW(), last_error = NULL, dead_at = NULL, updated_at = NOW()
+	`, append([]interface{}{op}, args...)...)
+	return err
+}
+
+// RunESSyncWorker flushes queued products in bulk, waiting window after the first
+// change so bursts are deduplicated, and picks up due retries every
+// esSyncPollInterval. It returns after DrainESSync.
+func (h *Handlers) RunESSyncWorker(window time.Duration) {
+	q := h.esQueue
+	defer close(q.done)
+	poll := time.NewTicker(esSyncPollInterval)
+	defer poll.Stop()
+	for {
+		select {
+		case <-q.wake:
+			select {
+			case <-time.After(window):
+			case <-q.stop:
+			}
+			h.flushESSync(context.Background())
+		case <-poll.C:
+			h.flushESSync(context.Background())
+		case <-q.stop:
+			h.flushESSync(context.Background())
+			return
+		}
+	}
+}
+
+// DrainESSync stops the worker after everything queued has been sent
+func (h *Handlers) DrainESSync(timeout time.Duration) {
+	q := h.esQueue
+	close(q.stop)
+	select {
+	case <-q.done:
+	case <-time.After(timeout):
+		log.Printf("ES sync queue not drained, %d products left", q.depth())
+	}
+}
+
+// flushESSync writes every due queue entry, then the ids only held in memory
+func (h *Handlers) flushESSync(ctx context.Context) {
+	if h.es == nil {
+		return
+	}
+	q := h.esQueue
+	if ids := q.take(q.depth()); len(ids) > 0 {
+		// the database is back: ids held in memory get retries like any other
+		if err := h.enqueueESSync(ctx, "index", "SELECT unnest($2::uuid[])", ids); err != nil {
+			q.mu.Lock()
+			for _, id := range ids {
+				q.pending[id] = struct{}{}
+			}
+			q.mu.Unlock()
+		}
+	}
+	if _, _, err := h.processESSync(ctx, newWhere()); err != nil {
+		log.Printf("ES sync: %v", err)
+	}
+	h.flushMemoryESSync(ctx)
+	q.lastFlush.Store(time.Now().Unix())
+}
+
+// esSyncEntry is a claimed es_sync_queue row
+type esSyncEntry struct {
+	ID       string
+	Op       string
+	Attempts int
+	Version  int
+}
+
+// processESSync claims due entries matching scope (conditions on q.product_id) in
+// batches until none are left, writes them to ES and records failures for retry. It
+// returns how many were written and how many failed; err means the queue could not be
+// read.
+func (h *Handlers) processESSync(ctx context.Context, scope *sqlWhere) (written, failed int, err error) {
+	for {
+		entries, err := h.claimESSync(ctx, scope)
+		if err != nil {
+			return written, failed, err
+		}
+		if len(entries) == 0 {
+			return written, failed, nil
+		}
+		w, f := h.writeESSync(ctx, entries)
+		written, failed = written+w, failed+f
+	}
+}
+
+func (h *Handlers) claimESSync(ctx context.Context, scope *sqlWhere) ([]esSyncEntry, error) {
+	where := scope.with("q.dead_at IS NULL").add("q.next_attempt_at <= NOW()")
+	lease := where.arg(esSyncLease.Seconds())
+	limit := where.arg(esSyncBatchSize)
+	rows, err := h.db.Pool.Query(ctx, `
+		UPDATE es_sync_queue SET next_attempt_at = NOW() + make_interval(secs => `+lease+`)
+		WHERE product_id IN (
+			SELECT q.product_id FROM es_sync_queue q `+where.clause()+`
+			ORDER BY q.next_attempt_at LIMIT `+limit+` FOR UPDATE SKIP LOCKED
+		)
+		RETURNING product_id::text, op, attempts, version
+	`, where.params()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []esSyncEntry
+	for rows.Next() {
+		var e esSyncEntry
+		if err := rows.Scan(&e.ID, &e.Op, &e.Attempts, &e.Version); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// writeESSync indexes the entries' products, removes deleted ones and settles the
+// queue: written entries leave it, failed ones wait for their next attempt
+func (h *Handlers) writeESSync(ctx context.Context, entries []esSyncEntry) (written, failed int) {
+	q := h.esQueue
+	var indexIDs, deleteIDs []string
+	for _, e := range entries {
+		if e.Op == "delete" {
+			deleteIDs = append(deleteIDs, e.ID)
+		} else {
+			indexIDs = append(indexIDs, e.ID)
+		}
+	}
+
+	failedErr := map[string]string{}
+	var products []elasticsearch.Product
+	if len(indexIDs) > 0 {
+		var err error
+		products, err = h.loadESProducts(ctx, "WHERE p.id = ANY($1::uuid[])", indexIDs)
+		if err != nil {
+			log.Printf("ES sync: loading %d products failed: %v", len(indexIDs), err)
+			q.failed.Add(int64(len(indexIDs)))
+			for _, id := range indexIDs {
+				failedErr[id] = "loading product: " + err.Error()
+			}
+			indexIDs = nil
+		}
+		found := make(map[string]bool, len(products))
+		for _, p := range products {
+			found[p.ID] = true
+		}
+		for _, id := range indexIDs {
+			if !found[id] {
+				deleteIDs = append(deleteIDs, id)
+			}
+		}
+	}
+
+	if err := h.es.BulkIndex(products); err != nil {
+		log.Printf("ES sync: bulk index failed: %v", err)
+		q.failed.Add(int64(len(products)))
+		for _, p := range products {
+			failedErr[p.ID] = err.Error()
+		}
+	} else {
+		q.indexed.Add(int64(len(products)))
+	}
+	if err := h.es.BulkDelete(deleteIDs); err != nil {
+		log.Printf("ES sync: bulk delete failed: %v", err)
+		q.failed.Add(int64(len(deleteIDs)))
+		for _, id := range deleteIDs {
+			failedErr[id] = err.Error()
+		}
+	} else {
+		q.deleted.Add(int64(len(deleteIDs)))
+	}
+
+	var doneIDs, failIDs, failErrs []string
+	var doneVersions, failVersions []int32
+	for _, e := range entries {
+		if msg, ok := failedErr[e.ID]; ok {
+			failIDs, failVersions, failErrs = append(failIDs, e.ID), append(failVersions, int32(e.Version)), append(failErrs, msg)
+		} else {
+			doneIDs, doneVersions = append(doneIDs, e.ID), append(doneVersions, int32(e.Version))
+		}
+	}
+	if len(doneIDs) > 0 {
+		h.db.Pool.Exec(ctx, `
+			DELETE FROM es_sync_queue q USING unnest($1::uuid[], $2::int[]) AS v(id, version)
+			WHERE q.product_id = v.id AND q.version = v.version
+		`, doneIDs, doneVersions)
+		h.searchCache.invalidateProducts(doneIDs, products)
+	}
+	if len(failIDs) > 0 {
+		h.db.Pool.Exec(ctx, `
+			UPDATE es_sync_queue q SET attempts = q.attempts + 1, last_error = LEFT(v.error, 1000), updated_at = NOW(),
+			       next_attempt_at = NOW() + LEAST(make_interval(secs => $4 * power(2, q.attempts)), make_interval(secs => $5)),
+			       dead_at = CASE WHEN q.attempts + 1 >= $6 THEN NOW() END
+			FROM unnest($1::uuid[], $2::int[], $3::text[]) AS v(id, version, error)
+			WHERE q.product_id = v.id AND q.version = v.version
+		`, failIDs, failVersions, failErrs, esSyncRetryBase.Seconds(), esSyncRetryMax.Seconds(), esSyncMaxAttempts)
+	}
+	return len(doneIDs), len(failIDs)
+}
+
+// flushMemoryESSync sends the ids the database did not take, without retries
+func (h *Handlers) flushMemoryESSync(ctx context.Context) {
+	q := h.esQueue
+	for {
+		ids := q.take(esSyncBatchSize)
+		if len(ids) == 0 {
+			break
+		}
+		products, err := h.loadESProducts(ctx, "WHERE p.id = ANY($1::uuid[])", ids)
+		if err != nil {
+			log.Printf("ES sync: loading %d products failed: %v", len(ids), err)
+			q.failed.Add(int64(len(ids)))
+			continue
+		}
+		found := make(map[string]bool, len(products))
+		for _, p := range products {
+			found[p.ID] = true
+		}
+		var missing []string
+		for _, id := range ids {
+			if !found[id] {
+				missing = append(missing, id)
+			}
+		}
+
+		if err := h.es.BulkIndex(products); err != nil {
+			log.Printf("ES sync: bulk index failed: %v", err)
+			q.failed.Add(int64(len(products)))
+		} else {
+			q.indexed.Add(int64(len(products)))
+		}
+		if err := h.es.BulkDelete(missing); err != nil {
+			log.Printf("ES sync: bulk delete failed: %v", err)
+			q.failed.Add(int64(len(missing)))
+		} else {
+			q.deleted.Add(int64(len(missing)))
+		}
+		h.searchCache.invalidateProducts(ids, products)
+	}
+}
+
+// loadESProducts reads products in index form; where may reference $1...
+func (h *Handlers) loadESProducts(ctx context.Context, where string, args ...interface{}) ([]elasticsearch.Product, error) {
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT p.id, p.title, p.slug, COALESCE(p.description,''), COALESCE(p.description_plain,''), COALESCE(p.short_description,''),
+		       COALESCE(p.excerpt,''), COALESCE(p.ean,''), COALESCE(p.sku,''), COALESCE(p.mpn,''), COALESCE(p.brand,''),
+		       COALESCE(p.category_id::text,''), COALESCE(c.name,''), COALESCE(c.slug,''),
+		       COALESCE(p.image_url,''), `+effectivePriceMin+`, `+effectivePriceMax+`,
+		       `+effectivePriceMinNet+`, `+effectivePriceMaxNet+`, COALESCE(p.vat_rate,20),
+		       COALESCE(p.stock_status,'instock'), p.is_active, COALESCE(p.is_featured,false), p.created_at,
+		       COALESCE(`+popularityExpr+`, 0), COALESCE(p.rating,0), `+discountColumn+`,
+		       p.price_min, COALESCE(p.price_min_net, p.price_min), `+promoEndsColumn+`, p.release_date
+		FROM products p LEFT JOIN categories c ON p.category_id = c.id
+		`+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	publicCategory := h.publicCategoryResolver(ctx)
+	var loaded []models.Product
+	var ids []string
+	for rows.Next() {
+		var p models.Product
+		rows.Scan(&p.ID, &p.Title, &p.Slug, &p.Description, &p.DescriptionPlain, &p.ShortDescription,
+			&p.Excerpt, &p.EAN, &p.SKU, &p.MPN, &p.Brand, &p.CategoryID, &p.CategoryName, &p.CategorySlug,
+			&p.ImageURL, &p.PriceMin, &p.PriceMax, &p.PriceMinNet, &p.PriceMaxNet, &p.VATRate,
+			&p.StockStatus, &p.IsActive, &p.IsFeatured, &p.CreatedAt,
+			&p.Popularity, &p.Rating, &p.DiscountPercent,
+			&p.RegularPriceMin, &p.RegularPriceMinNet, &p.PromoEndsAt, &p.ReleaseDate)
+		p.CategoryID, p.CategoryName, p.CategorySlug = publicCategory(p.CategoryID, p.CategoryName, p.CategorySlug)
+		loaded = append(loaded, p)
+		ids = append(ids, p.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	labels := h.productLabels(ctx, ids)
+	attributes, err := h.esAttributes(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	products := make([]elasticsearch.Product, 0, len(loaded))
+	for _, p := range loaded {
+		p.Labels = labels[p.ID]
+		p.Attributes = attributes[p.ID]
+		products = append(products, p.ToESDocument())
+	}
+	return products, nil
+}
+
+// esAttributes loads attribute rows keyed by product ID. Each value of a repeated name
+// becomes its own nested document, so a filter on any of the values matches.
+func (h *Handlers) esAttributes(ctx context.Context, ids []string) (map[string][]models.Attribute, error) {
+	attributes := map[string][]models.Attribute{}
+	if len(ids) == 0 {
+		return attributes, nil
+	}
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT product_id::text, name, value FROM product_attributes
+		WHERE product_id = ANY($1::uuid[]) ORDER BY product_id, position, name
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var a models.Attribute
+		rows.Scan(&id, &a.Name, &a.Value)
+		attributes[id] = append(attributes[id], a)
+	}
+	return attributes, rows.Err()
+}
+
+// esSyncStats summarizes the persisted queue and the worker's counters
+func (h *Handlers) esSyncStats(ctx context.Context) fiber.Map {
+	q := h.esQueue
+	var pending, due, retrying, dead int64
+	var oldest *time.Time
+	h.db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*) FILTER (WHERE dead_at IS NULL),
+		       COUNT(*) FILTER (WHERE dead_at IS NULL AND next_attempt_at <= NOW()),
+		       COUNT(*) FILTER (WHERE dead_at IS NULL AND attempts > 0),
+		       COUNT(*) FILTER (WHERE dead_at IS NOT NULL),
+		       MIN(created_at) FILTER (WHERE dead_at IS NULL)
+		FROM es_sync_queue
+	`).Scan(&pending, &due, &retrying, &dead, &oldest)
+	var lastFlush interface{} = nil
+	if ts := q.lastFlush.Load(); ts > 0 {
+		lastFlush = time.Unix(ts, 0)
+	}
+	return fiber.Map{
+		"enabled":           h.es != nil,
+		"depth":             pending,
+		"due":               due,
+		"retrying":          retrying,
+		"dead":              dead,
+		"oldest_pending_at": oldest,
+		"memory_depth":      q.depth(),
+		"indexed":           q.indexed.Load(),
+		"deleted":           q.deleted.Load(),
+		"failed":            q.failed.Load(),
+		"last_flush":        lastFlush,
+	}
+}
+
+// GetESSyncQueue shows the queue depth and the dead-lettered entries, newest first.
+// ?status=pending lists the entries still waiting for a write instead.
+func (h *Handlers) GetESSyncQueue(c *fiber.Ctx) error {
+	status := c.Query("status", "dead")
+	if status != "dead" && status != "pending" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "status must be dead or pending"})
+	}
+	page, limit, offset := adminPageParams(c, 50)
+	ctx := context.Background()
+
+	cond, order := "q.dead_at IS NOT NULL", "q.dead_at DESC"
+	if status == "pending" {
+		cond, order = "q.dead_at IS NULL", "q.next_attempt_at"
+	}
+	var total int64
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM es_sync_queue q WHERE "+cond).Scan(&total)
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT q.product_id::text, COALESCE(p.title,''), q.op, q.attempts, COALESCE(q.last_error,''),
+		       q.next_attempt_at, q.dead_at, q.created_at, q.updated_at
+		FROM es_sync_queue q LEFT JOIN products p ON p.id = q.product_id
+		WHERE `+cond+` ORDER BY `+order+` LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+	entries := []fiber.Map{}
+	for rows.Next() {
+		var id, title, op, lastError string
+		var attempts int
+		var nextAttemptAt, createdAt, updatedAt time.Time
+		var deadAt *time.Time
+		rows.Scan(&id, &title, &op, &attempts, &lastError, &nextAttemptAt, &deadAt, &createdAt, &updatedAt)
+		entries = append(entries, fiber.Map{
+			"product_id": id, "title": title, "op": op, "attempts": attempts, "last_error": lastError,
+			"next_attempt_at": nextAttemptAt, "dead_at": deadAt, "created_at": createdAt, "updated_at": updatedAt,
+		})
+	}
+
+	data := paginate(c, fiber.Map{"entries": entries, "status": status}, page, limit, total)
+	data["queue"] = h.esSyncStats(ctx)
+	return c.JSON(fiber.Map{"success": true, "data": data})
+}
+
+// RequeueESSync gives dead-lettered entries a fresh set of attempts: the listed
+// product_ids, or every dead entry when the list is empty
+func (h *Handlers) RequeueESSync(c *fiber.Ctx) error {
+	var input struct {
+		ProductIDs []string `json:"product_ids"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+		}
+	}
+	for _, id := range input.ProductIDs {
+		if !isUUID(id) {
+			return invalidUUIDField(c, "product_ids")
+		}
+	}
+
+	ctx := context.Background()
+	tag, err := h.db.Pool.Exec(ctx, `
+		UPDATE es_sync_queue SET attempts = 0, version = version + 1, next_attempt_at = NOW(),
+		       dead_at = NULL, last_error = NULL, updated_at = NOW()
+		WHERE dead_at IS NOT NULL AND (cardinality($1::uuid[]) = 0 OR product_id = ANY($1::uuid[]))
+	`, nonNilStrings(input.ProductIDs))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	h.audit(ctx, c, "es_sync.requeue", "product", "", fiber.Map{"product_ids": input.ProductIDs, "requeued": tag.RowsAffected()})
+	h.esQueue.notify()
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"requeued": tag.RowsAffected()}})
+}
+
+func (h *Handlers) DebugESSync(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"success": true, "data": h.esSyncStats(context.Background())})
+}
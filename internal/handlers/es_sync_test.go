@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/money"
+	"megabuy-go/internal/testutil"
+)
+
+// TestImportPricesRoundTrip follows prices from a feed through the database and the
+// index to the search API while the API quotes prices: the index must keep numbers,
+// the API must quote the stored amount
+func TestImportPricesRoundTrip(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	money.JSONAsString = true
+	defer func() { money.JSONAsString = false }()
+
+	feedID := env.createTestFeed(t, "Price round trip", testutil.FeedSpec{Format: testutil.FormatCSV, Items: 8, Categories: 2, Seed: 1734})
+	progress, err := env.h.importFeed(ctx, feedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Status != "completed" || progress.Created != 8 {
+		t.Fatalf("import %s with %d created: %s", progress.Status, progress.Created, progress.Message)
+	}
+
+	stored := map[string][2]string{}
+	rows, err := env.db.Pool.Query(ctx, "SELECT id::text, price_min::text, price_min_net::text FROM products WHERE feed_id = $1::uuid", feedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id, gross, net string
+		if err := rows.Scan(&id, &gross, &net); err != nil {
+			t.Fatal(err)
+		}
+		stored[id] = [2]string{gross, net}
+	}
+	rows.Close()
+	if len(stored) != 8 {
+		t.Fatalf("%d products stored, want 8", len(stored))
+	}
+
+	for id, prices := range stored {
+		raw, ok := env.es.Doc(id)
+		if !ok {
+			t.Errorf("%s not indexed", id)
+			continue
+		}
+		var doc map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			t.Fatal(err)
+		}
+		for i, field := range []string{"price_min", "price_min_net"} {
+			n, ok := doc[field].(json.Number)
+			want, _ := strconv.ParseFloat(prices[i], 64)
+			if got, _ := n.Float64(); !ok || got != want {
+				t.Errorf("%s: indexed %s = %#v, want the number %s", id, field, doc[field], prices[i])
+			}
+		}
+	}
+
+	app := fiber.New()
+	app.Get("/search", env.h.Search)
+	status, out := callJSON(t, app, "GET", "/search?limit=100", nil)
+	var data struct {
+		Items []struct {
+			ID       string          `json:"id"`
+			PriceMin json.RawMessage `json:"price_min"`
+		} `json:"items"`
+	}
+	if status != 200 || json.Unmarshal(out.Data, &data) != nil {
+		t.Fatalf("search: %d %s", status, out.Error)
+	}
+	found := 0
+	for _, item := range data.Items {
+		prices, ok := stored[item.ID]
+		if !ok {
+			continue
+		}
+		found++
+		if want := strconv.Quote(prices[0]); string(item.PriceMin) != want {
+			t.Errorf("%s: search price_min %s, want %s", item.ID, item.PriceMin, want)
+		}
+	}
+	if found != len(stored) {
+		t.Errorf("search returned %d of the %d imported products", found, len(stored))
+	}
+}
+
+// TestPriceRoundTrip imports prices that floats get wrong and follows each one
+// through the database and the index to the search and product APIs, which must
+// all carry exactly the amount in cents
+func TestPriceRoundTrip(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	// feed price and the gross amount it must become
+	prices := map[string]string{"RT-1": "19.99", "RT-2": "0.10", "RT-3": "2.675", "RT-4": "1299,90", "RT-5": "4999"}
+	want := map[string]string{"RT-1": "19.99", "RT-2": "0.10", "RT-3": "2.68", "RT-4": "1299.90", "RT-5": "4999.00"}
+	var feed bytes.Buffer
+	feed.WriteString("ITEM_ID;PRODUCTNAME;PRICE_VAT;CATEGORYTEXT\n")
+	for _, sku := range []string{"RT-1", "RT-2", "RT-3", "RT-4", "RT-5"} {
+		fmt.Fprintf(&feed, "%s;Cenový test %s;%s;Test > Ceny\n", sku, sku, prices[sku])
+	}
+	feedID := env.createTestFeedFile(t, "Price round trip in cents", "csv", feed.Bytes())
+	progress, err := env.h.importFeed(ctx, feedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Status != "completed" || progress.Created != len(prices) {
+		t.Fatalf("import %s with %d created: %s", progress.Status, progress.Created, progress.Message)
+	}
+
+	type stored struct{ id, slug, gross string }
+	products := map[string]stored{}
+	rows, err := env.db.Pool.Query(ctx, "SELECT sku, id::text, slug, price_min::text FROM products WHERE feed_id = $1::uuid", feedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var sku string
+		var p stored
+		rows.Scan(&sku, &p.id, &p.slug, &p.gross)
+		products[sku] = p
+	}
+	rows.Close()
+
+	app := newIntegrationApp(env.h)
+	_, search := callJSON(t, app, "GET", "/api/v1/search?limit=100", nil)
+	var found struct {
+		Items []struct {
+			ID       string          `json:"id"`
+			PriceMin json.RawMessage `json:"price_min"`
+		} `json:"items"`
+	}
+	json.Unmarshal(search.Data, &found)
+	searched := map[string]string{}
+	for _, item := range found.Items {
+		searched[item.ID] = string(item.PriceMin)
+	}
+
+	for sku, w := range want {
+		p, ok := products[sku]
+		if !ok {
+			t.Errorf("%s not imported", sku)
+			continue
+		}
+		if p.gross != w {
+			t.Errorf("%s: feed price %s stored as %s, want %s", sku, prices[sku], p.gross, w)
+		}
+
+		raw, ok := env.es.Doc(p.id)
+		var doc struct {
+			PriceMin money.Price `json:"price_min"`
+		}
+		if !ok || json.Unmarshal(raw, &doc) != nil || doc.PriceMin.String() != w {
+			t.Errorf("%s: indexed price_min %s, want %s", sku, doc.PriceMin, w)
+		}
+		if got := searched[p.id]; got != w {
+			t.Errorf("%s: search price_min %s, want %s", sku, got, w)
+		}
+
+		status, detail := callJSON(t, app, "GET", "/api/v1/products/slug/"+p.slug, nil)
+		var product struct {
+			PriceMin json.RawMessage `json:"price_min"`
+		}
+		json.Unmarshal(detail.Data, &product)
+		if status != 200 || string(product.PriceMin) != w {
+			t.Errorf("%s: product price_min %s (status %d), want %s", sku, product.PriceMin, status, w)
+		}
+	}
+}
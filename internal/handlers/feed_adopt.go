@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== FEED ADOPTION ==========
+
+// maxAdoptSampleItems bounds the target feed items checked for EAN matches after adoption
+const maxAdoptSampleItems = 5000
+
+// AdoptFeedProducts moves the products of a source feed to the feed in the URL, for a
+// supplier that moved to a new feed: products.feed_id is reassigned in one UPDATE, the
+// source's import history and product count move along and, with deactivate_source,
+// the source feed stops importing, all in one transaction. The response reports how
+// many items at the head of the new feed match an adopted product by EAN, i.e. will be
+// updated rather than duplicated by the next import.
+func (h *Handlers) AdoptFeedProducts(c *fiber.Ctx) error {
+	targetID := c.Params("id")
+	var input struct {
+		SourceFeedID     string `json:"source_feed_id"`
+		DeactivateSource bool   `json:"deactivate_source"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if !isUUID(input.SourceFeedID) {
+		return invalidUUIDField(c, "source_feed_id")
+	}
+	if input.SourceFeedID == targetID {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "A feed cannot adopt its own products"})
+	}
+
+	ctx := context.Background()
+	tx, err := h.db.Pool.Begin(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer tx.Rollback(ctx)
+
+	// lock both feeds so an import of either cannot start halfway through
+	rows, err := tx.Query(ctx, "SELECT id::text FROM feeds WHERE id = ANY($1::uuid[]) FOR UPDATE", []string{targetID, input.SourceFeedID})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	found := map[string]bool{}
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		found[id] = true
+	}
+	rows.Close()
+	if !found[targetID] {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Feed not found"})
+	}
+	if !found[input.SourceFeedID] {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Source feed not found"})
+	}
+	for _, id := range []string{targetID, input.SourceFeedID} {
+		if h.imports.active(id) {
+			return c.Status(409).JSON(fiber.Map{"success": false, "error": "An import of feed " + id + " is running"})
+		}
+	}
+
+	moved, err := tx.Exec(ctx, "UPDATE products SET feed_id = $1::uuid, updated_at = NOW() WHERE feed_id = $2::uuid", targetID, input.SourceFeedID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	history, err := tx.Exec(ctx, "UPDATE feed_history SET feed_id = $1::uuid WHERE feed_id = $2::uuid", targetID, input.SourceFeedID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE feeds SET product_count = (SELECT COUNT(*) FROM products WHERE products.feed_id = feeds.id),
+		       is_active = CASE WHEN id = $2::uuid AND $3 THEN false ELSE is_active END, updated_at = NOW()
+		WHERE id IN ($1::uuid, $2::uuid)
+	`, targetID, input.SourceFeedID, input.DeactivateSource); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	h.audit(ctx, c, "feed.adopt", "feed", targetID, fiber.Map{
+		"source_feed_id": input.SourceFeedID, "products_moved": moved.RowsAffected(),
+		"history_moved": history.RowsAffected(), "source_deactivated": input.DeactivateSource,
+	})
+
+	result := fiber.Map{
+		"feed_id": targetID, "source_feed_id": input.SourceFeedID, "products_moved": moved.RowsAffected(),
+		"history_moved": history.RowsAffected(), "source_deactivated": input.DeactivateSource,
+	}
+	match, err := h.adoptedEANMatches(ctx, targetID)
+	if err != nil {
+		result["match_error"] = err.Error()
+	} else {
+		result["matches"] = match
+	}
+	return c.JSON(fiber.Map{"success": true, "message": "Products adopted", "data": result})
+}
+
+// adoptedEANMatches reads the head of the feed and counts the distinct item EANs that
+// belong to one of the feed's products
+func (h *Handlers) adoptedEANMatches(ctx context.Context, feedID string) (fiber.Map, error) {
+	feed, err := h.loadFeed(ctx, feedID)
+	if err != nil {
+		return nil, err
+	}
+	parser, ok := feedParserFor(feed.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown feed type: %s", feed.Type)
+	}
+	body, _, err := openFeed(feed.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	// Items stops reading once the sample is complete
+	sample := &io.LimitedReader{R: body, N: previewByteBudget}
+
+	transforms := newFeedTransformer(feed.TransformRules)
+	sampled, withoutEAN := 0, 0
+	seen := map[string]bool{}
+	var eans []string
+	parseErr := parser.Items(sample, ParseOptions{ItemPath: feed.XMLItemPath, Limit: maxAdoptSampleItems}, func(item map[string]interface{}) bool {
+		sampled++
+		productData := mapFields(item, feed.FieldMapping)
+		transforms.applyFields(productData)
+		if ean, _ := splitEAN(getStr(productData, "ean")); ean != "" {
+			if !seen[ean] {
+				seen[ean] = true
+				eans = append(eans, ean)
+			}
+		} else {
+			withoutEAN++
+		}
+		return sampled < maxAdoptSampleItems
+	})
+	if parseErr != nil && sampled == 0 {
+		return nil, parseErr
+	}
+
+	var matched int
+	if len(eans) > 0 {
+		if err := h.db.Pool.QueryRow(ctx, "SELECT COUNT(DISTINCT ean) FROM products WHERE feed_id = $1::uuid AND ean = ANY($2)", feedID, eans).Scan(&matched); err != nil {
+			return nil, err
+		}
+	}
+	return fiber.Map{
+		"sampled": sampled, "truncated": sample.N == 0 || sampled >= maxAdoptSampleItems,
+		"matched": matched, "unmatched": len(eans) - matched, "without_ean": withoutEAN,
+	}, nil
+}
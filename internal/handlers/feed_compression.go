@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ========== COMPRESSED FEEDS ==========
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// feedArchiveExtensions are the zip entries taken as the feed document
+var feedArchiveExtensions = []string{".xml", ".csv", ".json"}
+
+// defaultMaxFeedBytes bounds a feed document, and a zip archive holding one, unless
+// MAX_FEED_BYTES says otherwise
+const defaultMaxFeedBytes = 1 << 30
+
+// maxFeedBytes reads the largest feed document accepted from MAX_FEED_BYTES
+// (default 1 GiB). A compressed feed is checked after decompression, so a small
+// gzip bomb fails instead of filling memory.
+func maxFeedBytes() int64 {
+	n, err := strconv.ParseInt(os.Getenv("MAX_FEED_BYTES"), 10, 64)
+	if err != nil || n <= 0 {
+		return defaultMaxFeedBytes
+	}
+	return n
+}
+
+// feedTooLargeError reports a feed document, or archive, over maxFeedBytes
+type feedTooLargeError struct{ limit int64 }
+
+func (e feedTooLargeError) Error() string {
+	return fmt.Sprintf("feed exceeds the %d byte limit (MAX_FEED_BYTES)", e.limit)
+}
+
+// decompressFeed returns the feed document of a downloaded body: gzip is decompressed
+// as it is read, a zip archive yields its first .xml, .csv or .json entry, and other
+// bodies are returned as they are. The magic bytes decide, not Content-Type or
+// Content-Encoding: the transport already undoes an encoding it negotiated, and
+// suppliers serve feed.xml.gz as application/gzip and application/octet-stream alike.
+// size is the compressed size or -1; the returned size is the document's when known,
+// else -1. Reading past maxFeedBytes of the document fails with feedTooLargeError.
+// body is closed on error.
+func decompressFeed(body io.ReadCloser, size int64) (io.ReadCloser, int64, error) {
+	limit := maxFeedBytes()
+	br := bufio.NewReader(body)
+	head, _ := br.Peek(len(zipMagic))
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		raw := &countingReader{r: br}
+		gz, err := gzip.NewReader(raw)
+		if err != nil {
+			body.Close()
+			return nil, 0, fmt.Errorf("invalid gzip feed: %w", err)
+		}
+		return &gzipFeed{Reader: gz, body: body, raw: raw, rawSize: size, limit: limit}, -1, nil
+	case bytes.HasPrefix(head, zipMagic):
+		// the zip directory is at the end, so the archive is read whole, which
+		// the limit bounds as well
+		if size > limit {
+			body.Close()
+			return nil, 0, feedTooLargeError{limit}
+		}
+		data, err := io.ReadAll(io.LimitReader(br, limit+1))
+		body.Close()
+		if err != nil {
+			return nil, 0, err
+		}
+		if int64(len(data)) > limit {
+			return nil, 0, feedTooLargeError{limit}
+		}
+		return openFeedArchive(data, limit)
+	}
+	if size > limit {
+		body.Close()
+		return nil, 0, feedTooLargeError{limit}
+	}
+	return readCloser{&limitedReader{r: br, left: limit, limit: limit}, body}, size, nil
+}
+
+// openFeedArchive opens the first feed document of a zip archive, refusing one
+// whose declared size is over limit
+func openFeedArchive(data []byte, limit int64) (io.ReadCloser, int64, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid zip feed: %w", err)
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !containsString(feedArchiveExtensions, strings.ToLower(path.Ext(f.Name))) {
+			continue
+		}
+		if f.UncompressedSize64 > uint64(limit) {
+			return nil, 0, feedTooLargeError{limit}
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, 0, fmt.Errorf("zip entry %s: %w", f.Name, err)
+		}
+		// the declared size is not trusted while reading either
+		return readCloser{&limitedReader{r: rc, left: limit, limit: limit}, rc}, int64(f.UncompressedSize64), nil
+	}
+	return nil, 0, fmt.Errorf("zip archive contains no %s file", strings.Join(feedArchiveExtensions, ", "))
+}
+
+// gzipFeed is a gzip body being decompressed
+type gzipFeed struct {
+	*gzip.Reader
+	body    io.Closer
+	raw     *countingReader
+	rawSize int64
+	out     int64
+	limit   int64
+}
+
+func (f *gzipFeed) Read(p []byte) (int, error) {
+	if f.out >= f.limit {
+		// a byte more tells a document of exactly limit bytes from a longer one
+		var one [1]byte
+		if n, _ := f.Reader.Read(one[:]); n > 0 {
+			return 0, feedTooLargeError{f.limit}
+		}
+	}
+	if left := f.limit - f.out; int64(len(p)) > left && left > 0 {
+		p = p[:left]
+	}
+	n, err := f.Reader.Read(p)
+	f.out += int64(n)
+	return n, err
+}
+
+func (f *gzipFeed) Close() error {
+	f.Reader.Close()
+	return f.body.Close()
+}
+
+// estimatedSize extrapolates the document size from the compression ratio so far,
+// or returns -1 when the compressed size is unknown
+func (f *gzipFeed) estimatedSize() int64 {
+	if f.rawSize <= 0 || f.raw.n == 0 {
+		return -1
+	}
+	return int64(float64(f.out) * float64(f.rawSize) / float64(f.raw.n))
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// limitedReader is io.LimitReader failing with feedTooLargeError, instead of EOF,
+// when the reader holds more than limit bytes
+type limitedReader struct {
+	r     io.Reader
+	left  int64
+	limit int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.left <= 0 {
+		var one [1]byte
+		if n, _ := l.r.Read(one[:]); n > 0 {
+			return 0, feedTooLargeError{l.limit}
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.left {
+		p = p[:l.left]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	return n, err
+}
+
+// feedStream counts what an import reads from a feed body and keeps its first
+// xmlScanBudget bytes for diagnostics. A failed read is remembered in err, since it
+// is a download failure rather than a malformed feed.
+type feedStream struct {
+	r    io.Reader
+	n    int64
+	head []byte
+	err  error
+}
+
+func (s *feedStream) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.n += int64(n)
+	if room := xmlScanBudget - len(s.head); room > 0 {
+		s.head = append(s.head, p[:min(n, room)]...)
+	}
+	if err != nil && err != io.EOF {
+		s.err = err
+	}
+	return n, err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"megabuy-go/internal/testutil"
+)
+
+const fixtureFeed = `<?xml version="1.0" encoding="utf-8"?>
+<SHOP>
+<SHOPITEM><ITEM_ID>A1</ITEM_ID><PRODUCTNAME>Kávovar Tefal</PRODUCTNAME><PRICE_VAT>89.90</PRICE_VAT></SHOPITEM>
+<SHOPITEM><ITEM_ID>A2</ITEM_ID><PRODUCTNAME>Vysávač Bosch</PRODUCTNAME><PRICE_VAT>149.00</PRICE_VAT></SHOPITEM>
+</SHOP>
+`
+
+func readFeed(t *testing.T, body []byte, size int64) ([]byte, int64, error) {
+	t.Helper()
+	rc, docSize, err := decompressFeed(io.NopCloser(bytes.NewReader(body)), size)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	return data, docSize, err
+}
+
+func TestDecompressFeed(t *testing.T) {
+	doc := []byte(fixtureFeed)
+	tests := []struct {
+		name     string
+		body     []byte
+		wantSize int64
+	}{
+		{"plain", doc, int64(len(doc))},
+		{"gzip", testutil.Gzip(doc), -1},
+		{"zip", testutil.Zip("export/feed.xml", doc), int64(len(doc))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, size, err := readFeed(t, tt.body, int64(len(tt.body)))
+			if err != nil {
+				t.Fatalf("decompressFeed: %v", err)
+			}
+			if !bytes.Equal(data, doc) {
+				t.Errorf("document = %q, want the fixture", data)
+			}
+			if size != tt.wantSize {
+				t.Errorf("size = %d, want %d", size, tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestDecompressFeedErrors(t *testing.T) {
+	if _, _, err := readFeed(t, append([]byte{0x1f, 0x8b}, "not gzip"...), -1); err == nil || !strings.Contains(err.Error(), "gzip") {
+		t.Errorf("corrupt gzip: err = %v", err)
+	}
+	if _, _, err := readFeed(t, testutil.Zip("notes.txt", []byte("hello")), -1); err == nil || !strings.Contains(err.Error(), "no .xml") {
+		t.Errorf("zip without a feed entry: err = %v", err)
+	}
+}
+
+func TestDecompressFeedLimit(t *testing.T) {
+	t.Setenv("MAX_FEED_BYTES", "1000")
+	big := bytes.Repeat([]byte("<SHOPITEM></SHOPITEM>\n"), 1000)
+
+	var tooLarge feedTooLargeError
+	for name, body := range map[string][]byte{
+		"plain": big,
+		"gzip":  testutil.Gzip(big),
+		"zip":   testutil.Zip("feed.xml", big),
+	} {
+		// the gzip bomb is well under the limit compressed
+		if _, _, err := readFeed(t, body, -1); !errors.As(err, &tooLarge) {
+			t.Errorf("%s over the limit: err = %v, want feedTooLargeError", name, err)
+		}
+	}
+	if _, _, err := readFeed(t, big, int64(len(big))); !errors.As(err, &tooLarge) {
+		t.Errorf("declared size over the limit: err = %v", err)
+	}
+
+	exact := bytes.Repeat([]byte("x"), 1000)
+	for name, body := range map[string][]byte{"plain": exact, "gzip": testutil.Gzip(exact)} {
+		data, _, err := readFeed(t, body, -1)
+		if err != nil || len(data) != 1000 {
+			t.Errorf("%s at the limit: %d bytes, err = %v", name, len(data), err)
+		}
+	}
+}
+
+func TestDownloadFeedHeadGzip(t *testing.T) {
+	srv := testutil.ServeFeed(testutil.FeedSpec{Items: 2000, Compression: "gzip", Seed: 7})
+	defer srv.Close()
+
+	data, truncated, size, err := downloadFeedHead(testutil.FeedURL(srv), 256*1024)
+	if err != nil {
+		t.Fatalf("downloadFeedHead: %v", err)
+	}
+	if !truncated || len(data) != 256*1024 {
+		t.Fatalf("read %d bytes, truncated=%v; want the 256 KiB head", len(data), truncated)
+	}
+	if !bytes.HasPrefix(data, []byte("<?xml")) {
+		t.Errorf("head is not the decompressed document: %q", data[:20])
+	}
+	full := len(testutil.GenerateFeed(testutil.FeedSpec{Items: 2000, Seed: 7}))
+	if size < int64(full)/2 || size > int64(full)*2 {
+		t.Errorf("estimated size %d, document is %d bytes", size, full)
+	}
+}
+
+func TestOpenFeedLocalGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.xml.gz")
+	if err := os.WriteFile(path, testutil.Gzip([]byte(fixtureFeed)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := downloadFeedData(path, 0)
+	if err != nil || string(data) != fixtureFeed {
+		t.Errorf("local gzip feed = %q, err = %v", data, err)
+	}
+}
+
+func TestOpenFeedNotModified(t *testing.T) {
+	srv := testutil.ServeFeed(testutil.FeedSpec{Items: 5, Compression: "zip"})
+	defer srv.Close()
+
+	body, _, validators, err := openFeedIfModified(testutil.FeedURL(srv), feedValidators{})
+	if err != nil || validators.ETag == "" {
+		t.Fatalf("first download: etag %q, err = %v", validators.ETag, err)
+	}
+	body.Close()
+	if _, _, _, err := openFeedIfModified(testutil.FeedURL(srv), validators); err != errFeedNotModified {
+		t.Errorf("second download: err = %v, want errFeedNotModified", err)
+	}
+}
+
+func TestOpenFeedHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	var httpErr feedHTTPError
+	if _, err := downloadFeedData(srv.URL, 0); !errors.As(err, &httpErr) {
+		t.Errorf("err = %v, want feedHTTPError", err)
+	}
+}
@@ -0,0 +1,503 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== FEED CONFIG EXPORT / IMPORT ==========
+
+// redactedSecret replaces credentials in exported feed URLs. Importing a URL that
+// still carries it keeps the secret stored for the matched feed.
+const redactedSecret = "REDACTED"
+
+// Query parameters whose names contain one of these are treated as credentials
+var secretParamHints = []string{"key", "token", "secret", "pass", "auth", "sig", "hash"}
+
+// feedConfig is the portable part of a feed definition: no IDs, run state or counters.
+// The vendor is referenced by slug so files move between environments.
+type feedConfig struct {
+	Name               string                 `json:"name"`
+	URL                string                 `json:"url"`
+	Type               string                 `json:"type"`
+	Vendor             string                 `json:"vendor,omitempty"`
+	VendorID           string                 `json:"vendor_id,omitempty"`
+	Schedule           string                 `json:"schedule"`
+	IsActive           bool                   `json:"is_active"`
+	XMLItemPath        string                 `json:"xml_item_path"`
+	FieldMapping       map[string]string      `json:"field_mapping"`
+	PricesIncludeVAT   *bool                  `json:"prices_include_vat"`
+	VATRate            *float64               `json:"vat_rate"`
+	AttributeBlacklist []string               `json:"attribute_blacklist"`
+	ImportMode         string                 `json:"import_mode"`
+	CategoryMode       string                 `json:"category_mode"`
+	ForceHTTPS         bool                   `json:"force_https"`
+	TransformRules     []models.FeedTransform `json:"transform_rules"`
+}
+
+// storedFeedConfig is a feedConfig as saved, with the row it came from
+type storedFeedConfig struct {
+	ID string
+	feedConfig
+}
+
+func (cfg *feedConfig) applyDefaults() {
+	cfg.Name = strings.TrimSpace(cfg.Name)
+	cfg.URL = strings.TrimSpace(cfg.URL)
+	if cfg.Type == "" {
+		cfg.Type = "xml"
+	}
+	if cfg.Schedule == "" {
+		cfg.Schedule = "daily"
+	}
+	if cfg.XMLItemPath == "" {
+		cfg.XMLItemPath = "SHOPITEM"
+	}
+	if cfg.FieldMapping == nil {
+		cfg.FieldMapping = map[string]string{}
+	}
+	if cfg.PricesIncludeVAT == nil {
+		v := true
+		cfg.PricesIncludeVAT = &v
+	}
+	if cfg.VATRate == nil {
+		v := defaultVATRate
+		cfg.VATRate = &v
+	}
+	cfg.AttributeBlacklist = nonNilStrings(cfg.AttributeBlacklist)
+	if cfg.ImportMode == "" {
+		cfg.ImportMode = "live"
+	}
+	if cfg.CategoryMode == "" {
+		cfg.CategoryMode = "create"
+	}
+	cfg.TransformRules = nonNilTransforms(cfg.TransformRules)
+}
+
+// validate checks one imported definition; vendor slugs are resolved into VendorID
+func (cfg *feedConfig) validate(vendors map[string]string) []string {
+	var errs []string
+	if cfg.Name == "" || cfg.URL == "" {
+		errs = append(errs, "name and url are required")
+	}
+	if _, ok := feedParserFor(cfg.Type); !ok {
+		errs = append(errs, "unknown feed type: "+cfg.Type)
+	}
+	if !validImportMode(cfg.ImportMode) {
+		errs = append(errs, "import_mode must be live or staged")
+	}
+	if !validCategoryMode(cfg.CategoryMode) {
+		errs = append(errs, "category_mode must be create, skip or fail")
+	}
+	if !validFeedSchedule(cfg.Schedule) {
+		errs = append(errs, "unknown schedule: "+cfg.Schedule)
+	}
+	if *cfg.VATRate < 0 || *cfg.VATRate > 100 {
+		errs = append(errs, "vat_rate must be between 0 and 100")
+	}
+	for source, target := range cfg.FieldMapping {
+		if !validMappingTarget(target) {
+			errs = append(errs, fmt.Sprintf("field_mapping %q: unknown target %q", source, target))
+		}
+	}
+	errs = append(errs, validateFeedTransforms(cfg.TransformRules)...)
+	switch {
+	case cfg.Vendor != "":
+		id, ok := vendors[cfg.Vendor]
+		if !ok {
+			errs = append(errs, "unknown vendor: "+cfg.Vendor)
+		}
+		cfg.VendorID = id
+	case cfg.VendorID != "":
+		if !isUUID(cfg.VendorID) {
+			errs = append(errs, "vendor_id must be a valid UUID")
+		} else if _, ok := vendors[cfg.VendorID]; !ok {
+			errs = append(errs, "unknown vendor_id: "+cfg.VendorID)
+		}
+	}
+	return errs
+}
+
+// validMappingTarget accepts the import fields, media targets and the "ignore" markers
+func validMappingTarget(target string) bool {
+	switch target {
+	case "", "--", "-- Ignorovat --":
+		return true
+	}
+	if mediaType := strings.TrimPrefix(target, "media:"); mediaType != target {
+		return containsString(mediaTypes, mediaType)
+	}
+	_, ok := feedFieldSources[target]
+	return ok
+}
+
+// changedFields lists the settings that differ between a stored feed and an imported definition
+func (cfg feedConfig) changedFields(stored feedConfig) []string {
+	var changes []string
+	add := func(field string, differs bool) {
+		if differs {
+			changes = append(changes, field)
+		}
+	}
+	add("name", cfg.Name != stored.Name)
+	add("url", cfg.URL != stored.URL)
+	add("type", cfg.Type != stored.Type)
+	add("vendor", cfg.VendorID != stored.VendorID)
+	add("schedule", cfg.Schedule != stored.Schedule)
+	add("is_active", cfg.IsActive != stored.IsActive)
+	add("xml_item_path", cfg.XMLItemPath != stored.XMLItemPath)
+	add("field_mapping", !reflect.DeepEqual(cfg.FieldMapping, stored.FieldMapping))
+	add("prices_include_vat", *cfg.PricesIncludeVAT != *stored.PricesIncludeVAT)
+	add("vat_rate", *cfg.VATRate != *stored.VATRate)
+	add("attribute_blacklist", !reflect.DeepEqual(cfg.AttributeBlacklist, stored.AttributeBlacklist))
+	add("import_mode", cfg.ImportMode != stored.ImportMode)
+	add("category_mode", cfg.CategoryMode != stored.CategoryMode)
+	add("force_https", cfg.ForceHTTPS != stored.ForceHTTPS)
+	add("transform_rules", !reflect.DeepEqual(cfg.TransformRules, stored.TransformRules))
+	return changes
+}
+
+// isSecretParam reports whether a query parameter name looks like a credential
+func isSecretParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, hint := range secretParamHints {
+		if strings.Contains(name, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactFeedURL replaces the userinfo password and credential-like query values,
+// keeping parameter order so the redacted URL stays comparable
+func redactFeedURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), redactedSecret)
+		}
+	}
+	if u.RawQuery != "" {
+		parts := strings.Split(u.RawQuery, "&")
+		for i, part := range parts {
+			name, _, hasValue := strings.Cut(part, "=")
+			if key, err := url.QueryUnescape(name); err == nil && hasValue && isSecretParam(key) {
+				parts[i] = name + "=" + redactedSecret
+			}
+		}
+		u.RawQuery = strings.Join(parts, "&")
+	}
+	return u.String()
+}
+
+// restoreFeedURL puts the secrets of stored back into an imported URL that carries
+// redactedSecret placeholders. ok is false when a placeholder has nothing to restore.
+func restoreFeedURL(incoming, stored string) (string, bool) {
+	if !strings.Contains(incoming, redactedSecret) {
+		return incoming, true
+	}
+	in, err := url.Parse(incoming)
+	if err != nil {
+		return incoming, false
+	}
+	old, err := url.Parse(stored)
+	if err != nil {
+		return incoming, false
+	}
+	if in.User != nil {
+		if password, _ := in.User.Password(); password == redactedSecret {
+			if old.User == nil {
+				return incoming, false
+			}
+			oldPassword, ok := old.User.Password()
+			if !ok {
+				return incoming, false
+			}
+			in.User = url.UserPassword(in.User.Username(), oldPassword)
+		}
+	}
+	if in.RawQuery != "" {
+		oldValues := map[string]string{}
+		for _, part := range strings.Split(old.RawQuery, "&") {
+			if name, value, ok := strings.Cut(part, "="); ok {
+				oldValues[name] = value
+			}
+		}
+		parts := strings.Split(in.RawQuery, "&")
+		for i, part := range parts {
+			name, value, _ := strings.Cut(part, "=")
+			if value != redactedSecret {
+				continue
+			}
+			oldValue, ok := oldValues[name]
+			if !ok {
+				return incoming, false
+			}
+			parts[i] = name + "=" + oldValue
+		}
+		in.RawQuery = strings.Join(parts, "&")
+	}
+	return in.String(), true
+}
+
+// loadFeedConfigs reads every stored feed definition
+func (h *Handlers) loadFeedConfigs(ctx context.Context) ([]storedFeedConfig, error) {
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT f.id, f.name, f.url, COALESCE(f.type,'xml'), COALESCE(f.vendor_id::text,''), COALESCE(v.slug,''),
+		       COALESCE(f.schedule,'daily'), COALESCE(f.is_active,true), COALESCE(f.xml_item_path,'SHOPITEM'),
+		       COALESCE(f.field_mapping::text,'{}'), COALESCE(f.prices_include_vat,true), COALESCE(f.vat_rate,20),
+		       COALESCE(f.attribute_blacklist::text,'[]'), COALESCE(f.import_mode,'live'), COALESCE(f.category_mode,'create'),
+		       COALESCE(f.force_https,false), COALESCE(f.transform_rules::text,'[]')
+		FROM feeds f LEFT JOIN vendors v ON v.id = f.vendor_id
+		ORDER BY f.created_at, f.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var configs []storedFeedConfig
+	for rows.Next() {
+		var s storedFeedConfig
+		var fieldMappingStr, blacklistStr, transformsStr string
+		var pricesIncludeVAT bool
+		var vatRate float64
+		if err := rows.Scan(&s.ID, &s.Name, &s.URL, &s.Type, &s.VendorID, &s.Vendor, &s.Schedule, &s.IsActive, &s.XMLItemPath,
+			&fieldMappingStr, &pricesIncludeVAT, &vatRate, &blacklistStr, &s.ImportMode, &s.CategoryMode, &s.ForceHTTPS, &transformsStr); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(fieldMappingStr), &s.FieldMapping)
+		json.Unmarshal([]byte(blacklistStr), &s.AttributeBlacklist)
+		s.TransformRules = parseFeedTransforms(transformsStr)
+		s.PricesIncludeVAT, s.VATRate = &pricesIncludeVAT, &vatRate
+		s.applyDefaults()
+		configs = append(configs, s)
+	}
+	return configs, rows.Err()
+}
+
+// vendorRefs maps both vendor slugs and vendor IDs to the vendor ID
+func (h *Handlers) vendorRefs(ctx context.Context) (map[string]string, error) {
+	rows, err := h.db.Pool.Query(ctx, "SELECT id::text, COALESCE(slug,'') FROM vendors")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	refs := map[string]string{}
+	for rows.Next() {
+		var id, slug string
+		rows.Scan(&id, &slug)
+		refs[id] = id
+		if slug != "" {
+			refs[slug] = id
+		}
+	}
+	return refs, rows.Err()
+}
+
+// allowFeedSecrets checks the X-Feed-Secrets-Token header against FEED_SECRETS_TOKEN.
+// Without the variable set, secrets are never exported.
+func allowFeedSecrets(c *fiber.Ctx) bool {
+	token := os.Getenv("FEED_SECRETS_TOKEN")
+	if token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.Get("X-Feed-Secrets-Token")), []byte(token)) == 1
+}
+
+// ExportFeeds returns every feed definition. Credentials in feed URLs are replaced by
+// redactedSecret unless ?include_secrets=true comes with a valid X-Feed-Secrets-Token.
+func (h *Handlers) ExportFeeds(c *fiber.Ctx) error {
+	includeSecrets := c.QueryBool("include_secrets")
+	if includeSecrets && !allowFeedSecrets(c) {
+		return c.Status(403).JSON(fiber.Map{"success": false, "error": "include_secrets requires a valid X-Feed-Secrets-Token"})
+	}
+
+	ctx := context.Background()
+	stored, err := h.loadFeedConfigs(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	feeds := make([]feedConfig, 0, len(stored))
+	for _, s := range stored {
+		cfg := s.feedConfig
+		if !includeSecrets {
+			cfg.URL = redactFeedURL(cfg.URL)
+		}
+		if cfg.Vendor != "" {
+			cfg.VendorID = ""
+		}
+		feeds = append(feeds, cfg)
+	}
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"exported_at":     time.Now().UTC(),
+		"secrets_omitted": !includeSecrets,
+		"feeds":           feeds,
+	}})
+}
+
+// ImportFeeds upserts feed definitions matched by URL, then by name. Everything is
+// validated before anything is written; ?dry_run=true only reports the outcome.
+func (h *Handlers) ImportFeeds(c *fiber.Ctx) error {
+	var input struct {
+		Feeds  []feedConfig `json:"feeds"`
+		DryRun bool         `json:"dry_run"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	dryRun := input.DryRun || c.QueryBool("dry_run")
+
+	ctx := context.Background()
+	stored, err := h.loadFeedConfigs(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	vendors, err := h.vendorRefs(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	type plannedFeed struct {
+		cfg     feedConfig
+		feedID  string
+		action  string
+		changes []string
+	}
+	var planned []plannedFeed
+	var invalid []fiber.Map
+	claimed := map[string]int{}
+
+	for i, cfg := range input.Feeds {
+		cfg.applyDefaults()
+		errs := cfg.validate(vendors)
+
+		var match *storedFeedConfig
+		for j := range stored {
+			if stored[j].URL == cfg.URL || redactFeedURL(stored[j].URL) == cfg.URL {
+				match = &stored[j]
+				break
+			}
+		}
+		if match == nil {
+			for j := range stored {
+				if strings.EqualFold(stored[j].Name, cfg.Name) {
+					match = &stored[j]
+					break
+				}
+			}
+		}
+
+		p := plannedFeed{action: "created"}
+		if match != nil {
+			if prev, dup := claimed[match.ID]; dup {
+				errs = append(errs, fmt.Sprintf("matches the same feed as entry %d", prev))
+			}
+			claimed[match.ID] = i
+			p.feedID = match.ID
+			restored, ok := restoreFeedURL(cfg.URL, match.URL)
+			if !ok {
+				errs = append(errs, "url contains "+redactedSecret+" placeholders that do not match the stored feed")
+			}
+			cfg.URL = restored
+			p.changes = cfg.changedFields(match.feedConfig)
+			p.action = "updated"
+			if len(p.changes) == 0 {
+				p.action = "unchanged"
+			}
+		} else if strings.Contains(cfg.URL, redactedSecret) {
+			errs = append(errs, "url contains "+redactedSecret+" placeholders but no stored feed matches")
+		}
+
+		if len(errs) > 0 {
+			invalid = append(invalid, fiber.Map{"index": i, "name": cfg.Name, "errors": errs})
+			continue
+		}
+		p.cfg = cfg
+		planned = append(planned, p)
+	}
+
+	if len(invalid) > 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Feed definitions are invalid; nothing was imported", "data": fiber.Map{"errors": invalid}})
+	}
+
+	counts := map[string]int{"created": 0, "updated": 0, "unchanged": 0}
+	results := make([]fiber.Map, 0, len(planned))
+	for _, p := range planned {
+		counts[p.action]++
+		entry := fiber.Map{"name": p.cfg.Name, "action": p.action}
+		if p.feedID != "" {
+			entry["feed_id"] = p.feedID
+		}
+		if len(p.changes) > 0 {
+			entry["changes"] = p.changes
+		}
+		results = append(results, entry)
+	}
+
+	if !dryRun {
+		tx, err := h.db.Pool.Begin(ctx)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+		}
+		defer tx.Rollback(ctx)
+		for i, p := range planned {
+			if p.action == "unchanged" {
+				continue
+			}
+			cfg := p.cfg
+			fieldMappingJSON, _ := json.Marshal(cfg.FieldMapping)
+			blacklistJSON, _ := json.Marshal(cfg.AttributeBlacklist)
+			transformsJSON, _ := json.Marshal(cfg.TransformRules)
+			var vendorID interface{} = nil
+			if cfg.VendorID != "" {
+				vendorID = cfg.VendorID
+			}
+			if p.action == "created" {
+				feedID := uuid.New().String()
+				_, err = tx.Exec(ctx, `
+					INSERT INTO feeds (id, name, url, type, vendor_id, schedule, is_active, xml_item_path, field_mapping, prices_include_vat, vat_rate, attribute_blacklist, import_mode, category_mode, force_https, transform_rules, created_at, updated_at)
+					VALUES ($1::uuid, $2, $3, $4, $5::uuid, $6, $7, $8, $9::jsonb, $10, $11, $12::jsonb, $13, $14, $15, $16::jsonb, NOW(), NOW())
+				`, feedID, cfg.Name, cfg.URL, cfg.Type, vendorID, cfg.Schedule, cfg.IsActive, cfg.XMLItemPath, string(fieldMappingJSON), *cfg.PricesIncludeVAT, *cfg.VATRate, string(blacklistJSON), cfg.ImportMode, cfg.CategoryMode, cfg.ForceHTTPS, string(transformsJSON))
+				results[i]["feed_id"] = feedID
+			} else {
+				_, err = tx.Exec(ctx, `
+					UPDATE feeds SET name=$2, url=$3, type=$4, vendor_id=$5::uuid, schedule=$6, is_active=$7, xml_item_path=$8,
+					       field_mapping=$9::jsonb, prices_include_vat=$10, vat_rate=$11, attribute_blacklist=$12::jsonb,
+					       import_mode=$13, category_mode=$14, force_https=$15, transform_rules=$16::jsonb, updated_at=NOW(),
+					       source_etag=CASE WHEN url=$3 THEN source_etag END, source_last_modified=CASE WHEN url=$3 THEN source_last_modified END
+					WHERE id=$1::uuid
+				`, p.feedID, cfg.Name, cfg.URL, cfg.Type, vendorID, cfg.Schedule, cfg.IsActive, cfg.XMLItemPath, string(fieldMappingJSON), *cfg.PricesIncludeVAT, *cfg.VATRate, string(blacklistJSON), cfg.ImportMode, cfg.CategoryMode, cfg.ForceHTTPS, string(transformsJSON))
+			}
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{"success": false, "error": fmt.Sprintf("%s: %v", cfg.Name, err)})
+			}
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+		}
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"dry_run":   dryRun,
+		"created":   counts["created"],
+		"updated":   counts["updated"],
+		"unchanged": counts["unchanged"],
+		"feeds":     results,
+	}})
+}
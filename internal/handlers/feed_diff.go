@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== FEED MAPPING DIFF ==========
+
+const (
+	defaultDiffSample = 200
+	// maxDiffMatches caps the per-item diffs returned; stats cover the whole sample
+	maxDiffMatches = 50
+)
+
+// diffMatch loads the catalog product an import would update, matched like saveFeedProduct:
+// by EAN when the item has one, otherwise by SKU
+func (h *Handlers) diffMatch(ctx context.Context, data map[string]interface{}) (stagedProduct, string) {
+	s := stagedProduct{Data: data}
+	var categoryName string
+	ean, _ := splitEAN(getStr(data, "ean"))
+	sku := getStr(data, "sku")
+	if ean == "" && sku == "" {
+		return s, ""
+	}
+	h.db.Pool.QueryRow(ctx, `
+		SELECT p.id, p.title, p.price_min, COALESCE(p.brand,''), COALESCE(p.image_url,''), COALESCE(p.stock_status,''), COALESCE(c.name,'')
+		FROM products p LEFT JOIN categories c ON c.id = p.category_id
+		WHERE ($1 <> '' AND p.ean = $1) OR ($1 = '' AND $2 <> '' AND p.sku = $2)
+		LIMIT 1
+	`, ean, sku).Scan(&s.MatchID, &s.MatchTitle, &s.MatchPrice, &s.MatchBrand, &s.MatchImage, &s.MatchStock, &categoryName)
+	return s, categoryName
+}
+
+// PreviewFeedDiff runs a field mapping and transform rules (the saved ones unless
+// field_mapping or transform_rules are sent) over the first items of the feed and
+// reports what an import would change on matched products.
+// Nothing is written. Live imports do not move existing products between categories,
+// so the category entries only show where the feed disagrees with the catalog.
+func (h *Handlers) PreviewFeedDiff(c *fiber.Ctx) error {
+	feedID := c.Params("id")
+	var input struct {
+		FieldMapping   map[string]string       `json:"field_mapping"`
+		TransformRules *[]models.FeedTransform `json:"transform_rules"`
+		Limit          int                     `json:"limit"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+		}
+	}
+	if input.Limit <= 0 {
+		input.Limit = defaultDiffSample
+	}
+	if input.Limit > maxPreviewItems {
+		input.Limit = maxPreviewItems
+	}
+
+	ctx := context.Background()
+	feed, err := h.loadFeed(ctx, feedID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Feed not found"})
+	}
+	mapping := feed.FieldMapping
+	if input.FieldMapping != nil {
+		mapping = input.FieldMapping
+	}
+	rules := feed.TransformRules
+	if input.TransformRules != nil {
+		if errs := validateFeedTransforms(*input.TransformRules); len(errs) > 0 {
+			return invalidFeedTransforms(c, errs)
+		}
+		rules = *input.TransformRules
+	}
+	transforms := newFeedTransformer(rules)
+	parser, ok := feedParserFor(feed.Type)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Unknown feed type: " + feed.Type})
+	}
+
+	body, _, err := openFeed(feed.URL)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Cannot download feed: " + err.Error()})
+	}
+	defer body.Close()
+	// Items stops reading once the sample is complete
+	sample := &io.LimitedReader{R: body, N: previewByteBudget}
+
+	var items []map[string]interface{}
+	parseErr := parser.Items(sample, ParseOptions{ItemPath: feed.XMLItemPath, Limit: input.Limit}, func(item map[string]interface{}) bool {
+		items = append(items, item)
+		return len(items) < input.Limit
+	})
+	if parseErr != nil && len(items) == 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Cannot parse feed: " + parseErr.Error()})
+	}
+
+	urlNorm := newFeedURLNormalizer(feed)
+	changed := map[string]int{}
+	var matched, unmatched, skipped, unchanged, transformErrors int
+	diffs := []fiber.Map{}
+	for i, item := range items {
+		productData := mapFields(item, mapping)
+		transformErrors += len(transforms.applyFields(productData))
+		urlNorm.normalizeFields(productData)
+		if getStr(productData, "title") == "" || getFloat(productData, "price") <= 0 {
+			skipped++
+			continue
+		}
+		match, categoryName := h.diffMatch(ctx, productData)
+		if match.MatchID == "" {
+			unmatched++
+			continue
+		}
+		matched++
+
+		diff := stagedDiff(feed, match)
+		if category := getStr(productData, "category"); category != "" {
+			parts := splitCategoryPath(category)
+			if last := strings.TrimSpace(parts[len(parts)-1]); !strings.EqualFold(last, categoryName) {
+				diff["category"] = fiber.Map{"current": categoryName, "staged": category}
+			}
+		}
+		if len(diff) == 0 {
+			unchanged++
+			continue
+		}
+
+		fields := make([]string, 0, len(diff))
+		for field := range diff {
+			fields = append(fields, field)
+			changed[field]++
+		}
+		if len(diffs) >= maxDiffMatches {
+			continue
+		}
+		sort.Strings(fields)
+		changes := make([]fiber.Map, 0, len(fields))
+		for _, field := range fields {
+			values := diff[field].(fiber.Map)
+			changes = append(changes, fiber.Map{"field": field, "current": values["current"], "incoming": values["staged"]})
+		}
+		diffs = append(diffs, fiber.Map{
+			"identifier": itemIdentifier(productData, i),
+			"product_id": match.MatchID,
+			"title":      match.MatchTitle,
+			"changes":    changes,
+		})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"sampled":   len(items),
+		"truncated": sample.N == 0 || len(items) >= input.Limit,
+		"matched":   matched,
+		"new":       unmatched,
+		"skipped":   skipped,
+		"unchanged": unchanged,
+		"changed":   changed,
+		"diffs":     diffs,
+		// rules that could not apply to a sampled item's value
+		"transform_errors": transformErrors,
+	}})
+}
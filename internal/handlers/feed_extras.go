@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ========== FEED PROMO EXTRAS ==========
+
+const (
+	maxWarrantyMonths = 600
+	maxExtraTextLen   = 500
+)
+
+// heurekaExtraMessages translates the EXTRA_MESSAGE codes of the Heureka spec; other
+// values are shown as sent
+var heurekaExtraMessages = map[string]string{
+	"free_delivery":          "Doprava zdarma",
+	"free_gift":              "Darček zdarma",
+	"free_accessories":       "Príslušenstvo zdarma",
+	"free_case":              "Puzdro zdarma",
+	"free_installation":      "Inštalácia zdarma",
+	"free_store_pickup":      "Osobný odber zdarma",
+	"extended_warranty":      "Predĺžená záruka",
+	"voucher":                "Zľavový kupón",
+	"split_payment":          "Platba na splátky",
+	"free_return":            "Vrátenie zdarma",
+	"free_cleaning_services": "Čistenie zdarma",
+}
+
+// warrantyPattern reads "36", "36 mesiacov", "3 roky", "5 let" or "2 years"; years
+// are recognised by the unit, anything else counts as months
+var warrantyPattern = regexp.MustCompile(`^(\d{1,3})\s*([\p{L}.]*)`)
+
+// parseWarrantyMonths returns the warranty length in months and false when the value
+// is not a recognisable duration
+func parseWarrantyMonths(raw string) (int, bool) {
+	m := warrantyPattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(raw)))
+	if m == nil {
+		return 0, false
+	}
+	n, _ := strconv.Atoi(m[1])
+	if strings.HasPrefix(m[2], "r") || strings.HasPrefix(m[2], "y") || strings.HasPrefix(m[2], "let") {
+		n *= 12
+	}
+	if n <= 0 || n > maxWarrantyMonths {
+		return 0, false
+	}
+	return n, true
+}
+
+// feedExtras are the promotional fields of a mapped feed item
+type feedExtras struct {
+	GiftText       string
+	WarrantyMonths *int
+	ExtraMessage   string
+}
+
+// mapFeedExtras reads gift_text, warranty and extra_message from the mapped item
+func mapFeedExtras(data map[string]interface{}) feedExtras {
+	extras := feedExtras{
+		GiftText:     truncateRunes(getStr(data, "gift_text"), maxExtraTextLen),
+		ExtraMessage: getStr(data, "extra_message"),
+	}
+	if text, ok := heurekaExtraMessages[strings.ToLower(extras.ExtraMessage)]; ok {
+		extras.ExtraMessage = text
+	}
+	extras.ExtraMessage = truncateRunes(extras.ExtraMessage, maxExtraTextLen)
+	if months, ok := parseWarrantyMonths(getStr(data, "warranty")); ok {
+		extras.WarrantyMonths = &months
+	}
+	return extras
+}
+
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
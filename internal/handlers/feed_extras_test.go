@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"megabuy-go/internal/models"
+	"megabuy-go/internal/testutil"
+)
+
+func TestParseWarrantyMonths(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want int
+		ok   bool
+	}{
+		{"36", 36, true},
+		{"36 mesiacov", 36, true},
+		{" 24 M. ", 24, true},
+		{"24m", 24, true},
+		{"3 roky", 36, true},
+		{"1 rok", 12, true},
+		{"5 let", 60, true},
+		{"2 Years", 24, true},
+		{"50 rokov", 600, true},
+		{"600", 600, true},
+		{"51 rokov", 0, false},
+		{"601 mesiacov", 0, false},
+		{"0", 0, false},
+		{"", 0, false},
+		{"abc", 0, false},
+		{"doživotná", 0, false},
+		{"-12", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseWarrantyMonths(tt.raw)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseWarrantyMonths(%q) = %d, %v; want %d, %v", tt.raw, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMapFeedExtras(t *testing.T) {
+	extras := mapFeedExtras(map[string]interface{}{
+		"gift_text":     " Taška na notebook ",
+		"warranty":      "3 roky",
+		"extra_message": "FREE_DELIVERY",
+	})
+	if extras.GiftText != "Taška na notebook" || extras.ExtraMessage != "Doprava zdarma" {
+		t.Errorf("extras %+v", extras)
+	}
+	if extras.WarrantyMonths == nil || *extras.WarrantyMonths != 36 {
+		t.Errorf("warranty %v, want 36 months", extras.WarrantyMonths)
+	}
+
+	// a message that is not a Heureka code is shown as sent, within the length limit
+	long := strings.Repeat("ž", maxExtraTextLen+20)
+	extras = mapFeedExtras(map[string]interface{}{
+		"gift_text":     long,
+		"warranty":      "doživotná",
+		"extra_message": "Kúpte 2, zaplaťte 1",
+	})
+	if extras.ExtraMessage != "Kúpte 2, zaplaťte 1" {
+		t.Errorf("custom message %q", extras.ExtraMessage)
+	}
+	if extras.GiftText != long[:2*maxExtraTextLen] {
+		t.Errorf("gift text of %d runes, want %d", len([]rune(extras.GiftText)), maxExtraTextLen)
+	}
+	if extras.WarrantyMonths != nil {
+		t.Errorf("unparseable warranty mapped to %d months", *extras.WarrantyMonths)
+	}
+
+	if extras := mapFeedExtras(map[string]interface{}{}); extras != (feedExtras{}) {
+		t.Errorf("item without extras: %+v", extras)
+	}
+}
+
+func TestFeedExtrasFromHeurekaXML(t *testing.T) {
+	doc := []byte(`<?xml version="1.0" encoding="utf-8"?>
+<SHOP>
+  <SHOPITEM>
+    <ITEM_ID>N1</ITEM_ID>
+    <PRODUCTNAME>Notebook Lenovo</PRODUCTNAME>
+    <PRICE_VAT>799</PRICE_VAT>
+    <GIFT>Myš zdarma</GIFT>
+    <EXTENDED_WARRANTY><VAL>36</VAL><DESC>Predĺžená záruka</DESC></EXTENDED_WARRANTY>
+    <EXTRA_MESSAGE>free_gift</EXTRA_MESSAGE>
+  </SHOPITEM>
+  <SHOPITEM>
+    <ITEM_ID>N2</ITEM_ID>
+    <PRODUCTNAME>Notebook Asus</PRODUCTNAME>
+    <PRICE_VAT>699</PRICE_VAT>
+    <ZARUKA>2 roky</ZARUKA>
+  </SHOPITEM>
+</SHOP>`)
+	items, err := parseFeed("heureka-xml", doc, "SHOPITEM")
+	if err != nil || len(items) != 2 {
+		t.Fatalf("parseFeed = %d items, err = %v", len(items), err)
+	}
+
+	extras := mapFeedExtras(mapFields(items[0], nil))
+	if extras.GiftText != "Myš zdarma" || extras.ExtraMessage != "Darček zdarma" || extras.WarrantyMonths == nil || *extras.WarrantyMonths != 36 {
+		t.Errorf("first item: %+v", extras)
+	}
+	extras = mapFeedExtras(mapFields(items[1], nil))
+	if extras.GiftText != "" || extras.ExtraMessage != "" || extras.WarrantyMonths == nil || *extras.WarrantyMonths != 24 {
+		t.Errorf("second item: %+v", extras)
+	}
+}
+
+func TestFeedExtrasOnProductDetail(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	feed := models.Feed{
+		ID:               env.createTestFeed(t, "Extras", testutil.FeedSpec{Items: 1}),
+		Type:             "heureka-xml",
+		VATRate:          20,
+		PricesIncludeVAT: true,
+		CategoryMode:     "create",
+	}
+	data := map[string]interface{}{
+		"title":         fmt.Sprintf("Notebook %d", time.Now().UnixNano()),
+		"sku":           "N1",
+		"price":         799.0,
+		"gift_text":     "Myš zdarma",
+		"warranty":      "36 mesiacov",
+		"extra_message": "free_delivery",
+	}
+	id, _, err := env.h.saveFeedProduct(ctx, feed, data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var slug string
+	if err := env.db.Pool.QueryRow(ctx, "SELECT slug FROM products WHERE id = $1::uuid", id).Scan(&slug); err != nil {
+		t.Fatal(err)
+	}
+	p, _, err := env.h.productBySlug(ctx, slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	detail := p.ToDetail("gross")
+	if detail.GiftText != "Myš zdarma" || detail.WarrantyMonths != 36 || detail.ExtraMessage != "Doprava zdarma" {
+		t.Errorf("detail extras %q, %d, %q", detail.GiftText, detail.WarrantyMonths, detail.ExtraMessage)
+	}
+
+	// the next import drops the gift and sends an unreadable warranty: the known
+	// warranty is kept, the gift is cleared
+	data["gift_text"], data["warranty"], data["extra_message"] = "", "doživotná", ""
+	if err := env.h.updateProductFromFeed(ctx, feed, id, data, nil); err != nil {
+		t.Fatal(err)
+	}
+	if p, _, err = env.h.productBySlug(ctx, slug); err != nil {
+		t.Fatal(err)
+	}
+	if p.GiftText != "" || p.WarrantyMonths != 36 || p.ExtraMessage != "" {
+		t.Errorf("after the update: %q, %d, %q", p.GiftText, p.WarrantyMonths, p.ExtraMessage)
+	}
+}
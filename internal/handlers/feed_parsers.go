@@ -0,0 +1,749 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// ========== FEED PARSERS ==========
+
+// ParseOptions carries the per-feed settings a parser may need
+type ParseOptions struct {
+	ItemPath string
+	// Limit stops Preview after this many items; 0 means all
+	Limit int
+}
+
+// FeedParser turns a feed document of one supplier format into item maps.
+// Items use the source field names as keys; PARAM-style attributes go under "_params".
+// Preview and Items read the document as a stream, so the raw feed is never held in
+// memory whole. The decoded items are: runImport collects them with collectItems,
+// because progress reports the item total before the first write.
+type FeedParser interface {
+	// Type is the feeds.type value the parser is registered under
+	Type() string
+	// DetectType reports whether data looks like this format
+	DetectType(data []byte) bool
+	// Preview decodes at most opts.Limit items; the document may be cut off mid-item
+	Preview(r io.Reader, opts ParseOptions) FeedPreview
+	// Items calls fn for every item in document order until fn returns false,
+	// reading no further than that item
+	Items(r io.Reader, opts ParseOptions, fn func(item map[string]interface{}) bool) error
+}
+
+var (
+	feedParsersMu   sync.RWMutex
+	feedParsers     = map[string]FeedParser{}
+	feedParserOrder []string
+
+	// Legacy feeds.type values
+	feedTypeAliases = map[string]string{"xml": "heureka-xml"}
+)
+
+// RegisterFeedParser makes a format available to preview and import. Detection
+// tries parsers in registration order, so more specific formats register first.
+func RegisterFeedParser(p FeedParser) {
+	feedParsersMu.Lock()
+	defer feedParsersMu.Unlock()
+	if _, exists := feedParsers[p.Type()]; !exists {
+		feedParserOrder = append(feedParserOrder, p.Type())
+	}
+	feedParsers[p.Type()] = p
+}
+
+func feedParserFor(feedType string) (FeedParser, bool) {
+	if alias, ok := feedTypeAliases[feedType]; ok {
+		feedType = alias
+	}
+	feedParsersMu.RLock()
+	defer feedParsersMu.RUnlock()
+	p, ok := feedParsers[feedType]
+	return p, ok
+}
+
+// detectFeedParser returns the first registered parser recognising data. CSV accepts
+// anything, so it is tried after every other parser, those registered later included.
+func detectFeedParser(data []byte) FeedParser {
+	feedParsersMu.RLock()
+	defer feedParsersMu.RUnlock()
+	for _, t := range feedParserOrder {
+		if t != "csv" && feedParsers[t].DetectType(data) {
+			return feedParsers[t]
+		}
+	}
+	return feedParsers["csv"]
+}
+
+func init() {
+	RegisterFeedParser(googleFeedParser{})
+	RegisterFeedParser(heurekaFeedParser{})
+	RegisterFeedParser(genericXMLFeedParser{})
+	RegisterFeedParser(jsonFeedParser{})
+	RegisterFeedParser(csvFeedParser{})
+}
+
+// collectItems drains a parser into a slice; memory grows with the item count
+func collectItems(p FeedParser, r io.Reader, opts ParseOptions) ([]map[string]interface{}, error) {
+	var items []map[string]interface{}
+	err := p.Items(r, opts, func(item map[string]interface{}) bool {
+		items = append(items, item)
+		return true
+	})
+	return items, err
+}
+
+// previewFirstItems builds a preview from the first opts.Limit complete items.
+// TotalItems is left to the caller.
+func previewFirstItems(p FeedParser, r io.Reader, opts ParseOptions) FeedPreview {
+	var items []map[string]interface{}
+	p.Items(r, opts, func(item map[string]interface{}) bool {
+		items = append(items, item)
+		return opts.Limit <= 0 || len(items) < opts.Limit
+	})
+	return previewFromItems(items)
+}
+
+// itemCounter is implemented by parsers that can count items without decoding them
+type itemCounter interface {
+	CountItems(data []byte, opts ParseOptions) int
+}
+
+// countFeedItems counts complete items in data, cheaply when the parser supports it
+func countFeedItems(p FeedParser, data []byte, opts ParseOptions) int {
+	if c, ok := p.(itemCounter); ok {
+		return c.CountItems(data, opts)
+	}
+	n := 0
+	p.Items(bytes.NewReader(data), opts, func(map[string]interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// countXMLOpenTags counts start tags of the given element names, with or without a namespace prefix
+func countXMLOpenTags(data []byte, names []string) int {
+	n := 0
+	rest := data
+	for {
+		i := bytes.IndexByte(rest, '<')
+		if i < 0 {
+			return n
+		}
+		rest = rest[i+1:]
+		end := bytes.IndexAny(rest, " \t\n\r/>")
+		if end <= 0 {
+			continue
+		}
+		name := rest[:end]
+		if colon := bytes.IndexByte(name, ':'); colon >= 0 {
+			name = name[colon+1:]
+		}
+		if containsString(names, string(name)) {
+			n++
+		}
+	}
+}
+
+// hasXMLStartTag reports whether data contains a start tag of name, prefixed or not
+func hasXMLStartTag(data []byte, name string) bool {
+	return countXMLOpenTags(data, []string{name}) > 0
+}
+
+func feedHead(data []byte) []byte {
+	if len(data) > 4096 {
+		return data[:4096]
+	}
+	return data
+}
+
+// heureka-xml: SHOP/SHOPITEM feeds with PARAM blocks
+
+type heurekaFeedParser struct{}
+
+func (heurekaFeedParser) Type() string { return "heureka-xml" }
+
+func (heurekaFeedParser) DetectType(data []byte) bool {
+	head := feedHead(data)
+	return hasXMLStartTag(head, "SHOPITEM") || hasXMLStartTag(head, "SHOP")
+}
+
+func (p heurekaFeedParser) Preview(r io.Reader, opts ParseOptions) FeedPreview {
+	return previewFirstItems(p, r, opts)
+}
+
+func (heurekaFeedParser) CountItems(data []byte, opts ParseOptions) int {
+	itemPath := opts.ItemPath
+	if itemPath == "" {
+		itemPath = "SHOPITEM"
+	}
+	return countXMLOpenTags(data, []string{itemPath})
+}
+
+// Items decodes one SHOPITEM at a time and stops reading once fn returns false; a
+// document cut off mid-item yields the complete items before it and the decode error
+func (heurekaFeedParser) Items(r io.Reader, opts ParseOptions, fn func(item map[string]interface{}) bool) error {
+	itemPath := opts.ItemPath
+	if itemPath == "" {
+		itemPath = "SHOPITEM"
+	}
+	dec := newXMLDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if start, ok := tok.(xml.StartElement); !ok || start.Name.Local != itemPath {
+			continue
+		}
+		item, err := decodeHeurekaItem(dec)
+		if err != nil {
+			return err
+		}
+		if len(item) > 0 && !fn(item) {
+			return nil
+		}
+	}
+}
+
+// heurekaElement is an open element inside a SHOPITEM
+type heurekaElement struct {
+	name     string
+	text     strings.Builder
+	children bool
+}
+
+// decodeHeurekaItem reads the rest of an item whose start tag was just read. Leaf
+// elements become fields by local name (first occurrence wins, CDATA included),
+// nested leaves are also available as PARENT_CHILD, repeated leaves keep every
+// value in _multi and each VAL of a PARAM becomes a name/value pair in _params.
+func decodeHeurekaItem(dec *xml.Decoder) (map[string]interface{}, error) {
+	item := make(map[string]interface{})
+	multi := make(map[string][]string)
+	var params []map[string]string
+	var paramName string
+	var paramVals, paramValues []string
+	var stack []*heurekaElement
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) > 0 {
+				stack[len(stack)-1].children = true
+			}
+			if t.Name.Local == "PARAM" {
+				paramName, paramVals, paramValues = "", nil, nil
+			}
+			stack = append(stack, &heurekaElement{name: t.Name.Local})
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		case xml.EndElement:
+			if len(stack) == 0 {
+				// the item's own end tag
+				if len(params) > 0 {
+					item["_params"] = params
+				}
+				for name, values := range multi {
+					if len(values) < 2 {
+						delete(multi, name)
+					}
+				}
+				if len(multi) > 0 {
+					item["_multi"] = multi
+				}
+				return item, nil
+			}
+			el := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			parent := ""
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1].name
+			}
+
+			if el.name == "PARAM" {
+				values := paramVals
+				if len(values) == 0 {
+					values = paramValues
+				}
+				for _, v := range values {
+					if paramName != "" {
+						params = append(params, map[string]string{"name": paramName, "value": v})
+					}
+				}
+				continue
+			}
+			if el.children {
+				continue
+			}
+			value := strings.TrimSpace(el.text.String())
+			if value == "" {
+				continue
+			}
+			if parent == "PARAM" {
+				switch el.name {
+				case "PARAM_NAME":
+					paramName = value
+				case "NAME":
+					if paramName == "" {
+						paramName = value
+					}
+				case "VAL":
+					paramVals = append(paramVals, value)
+				case "VALUE":
+					paramValues = append(paramValues, value)
+				}
+				continue
+			}
+			if _, exists := item[el.name]; !exists {
+				item[el.name] = value
+			}
+			if parent != "" {
+				if _, exists := item[parent+"_"+el.name]; !exists {
+					item[parent+"_"+el.name] = value
+				}
+			}
+			multi[el.name] = append(multi[el.name], value)
+		}
+	}
+}
+
+// generic-xml: any repeated element, every child element becomes a field
+
+type genericXMLFeedParser struct{}
+
+func (genericXMLFeedParser) Type() string { return "generic-xml" }
+
+func (genericXMLFeedParser) DetectType(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(feedHead(data)), []byte("<"))
+}
+
+func (p genericXMLFeedParser) Preview(r io.Reader, opts ParseOptions) FeedPreview {
+	return previewFirstItems(p, r, opts)
+}
+
+func (genericXMLFeedParser) CountItems(data []byte, opts ParseOptions) int {
+	itemPath := opts.ItemPath
+	if itemPath == "" {
+		itemPath = "SHOPITEM"
+	}
+	return countXMLOpenTags(data, []string{itemPath})
+}
+
+func (genericXMLFeedParser) Items(r io.Reader, opts ParseOptions, fn func(item map[string]interface{}) bool) error {
+	itemPath := opts.ItemPath
+	if itemPath == "" {
+		itemPath = "SHOPITEM"
+	}
+	return decodeXMLItems(r, []string{itemPath}, fn)
+}
+
+// google: Google Merchant RSS/Atom feeds with g: prefixed fields
+
+type googleFeedParser struct{}
+
+func (googleFeedParser) Type() string { return "google" }
+
+func (googleFeedParser) DetectType(data []byte) bool {
+	return bytes.Contains(feedHead(data), []byte("base.google.com/ns/1.0"))
+}
+
+func (p googleFeedParser) Preview(r io.Reader, opts ParseOptions) FeedPreview {
+	return previewFirstItems(p, r, opts)
+}
+
+func (googleFeedParser) Items(r io.Reader, opts ParseOptions, fn func(item map[string]interface{}) bool) error {
+	return decodeXMLItems(r, googleItemPaths(opts), fn)
+}
+
+func (googleFeedParser) CountItems(data []byte, opts ParseOptions) int {
+	return countXMLOpenTags(data, googleItemPaths(opts))
+}
+
+func googleItemPaths(opts ParseOptions) []string {
+	if opts.ItemPath != "" && opts.ItemPath != "SHOPITEM" {
+		return []string{opts.ItemPath}
+	}
+	return []string{"item", "entry"}
+}
+
+// json: array of objects or an object wrapping one
+
+type jsonFeedParser struct{}
+
+func (jsonFeedParser) Type() string { return "json" }
+
+func (jsonFeedParser) DetectType(data []byte) bool {
+	trimmed := bytes.TrimSpace(feedHead(data))
+	return bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{"))
+}
+
+func (p jsonFeedParser) Preview(r io.Reader, opts ParseOptions) FeedPreview {
+	return previewFirstItems(p, r, opts)
+}
+
+func (jsonFeedParser) Items(r io.Reader, opts ParseOptions, fn func(item map[string]interface{}) bool) error {
+	return decodeJSONItems(r, fn)
+}
+
+// jsonItemKeys are the wrapper keys holding the item array in object documents
+var jsonItemKeys = []string{"products", "items", "data", "results", "offers"}
+
+// decodeJSONItems streams objects from a top-level array or from the first array
+// under a jsonItemKeys key, so a document cut off mid-way still yields its complete items
+func decodeJSONItems(r io.Reader, fn func(item map[string]interface{}) bool) error {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('['):
+		return decodeJSONArray(dec, fn)
+	case json.Delim('{'):
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			valTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if key, _ := keyTok.(string); containsString(jsonItemKeys, key) && valTok == json.Delim('[') {
+				return decodeJSONArray(dec, fn)
+			}
+			if err := skipJSONValue(dec, valTok); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func decodeJSONArray(dec *json.Decoder, fn func(item map[string]interface{}) bool) error {
+	for dec.More() {
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		if m, ok := v.(map[string]interface{}); ok && !fn(m) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// skipJSONValue consumes the rest of a value whose first token was already read
+func skipJSONValue(dec *json.Decoder, first json.Token) error {
+	if d, ok := first.(json.Delim); !ok || (d != '{' && d != '[') {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if d, ok := tok.(json.Delim); ok {
+			if d == '{' || d == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		}
+	}
+	return nil
+}
+
+// csv: delimited text with a header row
+
+type csvFeedParser struct{}
+
+func (csvFeedParser) Type() string { return "csv" }
+
+func (csvFeedParser) DetectType(data []byte) bool { return true }
+
+func (p csvFeedParser) Preview(r io.Reader, opts ParseOptions) FeedPreview {
+	return previewFirstItems(p, r, opts)
+}
+
+// CountItems counts data rows; quoted fields spanning lines are counted per line
+func (csvFeedParser) CountItems(data []byte, opts ParseOptions) int {
+	n := bytes.Count(data, []byte("\n"))
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		n++
+	}
+	if n > 0 {
+		n-- // header
+	}
+	return n
+}
+
+func (csvFeedParser) Items(r io.Reader, opts ParseOptions, fn func(item map[string]interface{}) bool) error {
+	br := bufio.NewReaderSize(r, csvHeaderPeek)
+	head, _ := br.Peek(csvHeaderPeek)
+	if i := bytes.IndexByte(head, '\n'); i >= 0 {
+		head = head[:i]
+	}
+
+	reader := csv.NewReader(br)
+	reader.Comma = csvDelimiter(string(head))
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		item := make(map[string]interface{})
+		for j, val := range row {
+			if j < len(header) {
+				item[header[j]] = strings.TrimSpace(val)
+			}
+		}
+		if !fn(item) {
+			return nil
+		}
+	}
+}
+
+// csvHeaderPeek is how far the CSV parser looks for the end of the header row
+const csvHeaderPeek = 64 * 1024
+
+// csvDelimiter picks ';', ',' or tab, whichever the header row uses most, ';' on a tie
+func csvDelimiter(header string) rune {
+	delimiter := ';'
+	if strings.Count(header, ",") > strings.Count(header, ";") {
+		delimiter = ','
+	}
+	if strings.Count(header, "\t") > strings.Count(header, string(delimiter)) {
+		delimiter = '\t'
+	}
+	return delimiter
+}
+
+// xmlNode captures an arbitrary element subtree
+type xmlNode struct {
+	XMLName  xml.Name
+	Content  string    `xml:",chardata"`
+	Children []xmlNode `xml:",any"`
+}
+
+// newXMLDecoder returns a lenient decoder that understands the charsets suppliers use
+func newXMLDecoder(r io.Reader) *xml.Decoder {
+	dec := xml.NewDecoder(r)
+	dec.Strict = false
+	dec.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		enc, err := htmlindex.Get(label)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported charset %q", label)
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+	return dec
+}
+
+// decodeXMLItems streams r and calls fn for each element whose local name is in itemNames
+func decodeXMLItems(r io.Reader, itemNames []string, fn func(item map[string]interface{}) bool) error {
+	dec := newXMLDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || !containsString(itemNames, start.Name.Local) {
+			continue
+		}
+		var node xmlNode
+		if err := dec.DecodeElement(&node, &start); err != nil {
+			return err
+		}
+		item := flattenXMLNode(node)
+		if len(item) > 0 && !fn(item) {
+			return nil
+		}
+	}
+}
+
+// flattenXMLNode maps leaf elements to fields by local name (first occurrence wins),
+// keeps all values of repeated elements in _multi and collects PARAM name/value pairs into _params.
+// Nested leaves are also available as PARENT_CHILD, e.g. EXTENDED_WARRANTY_VAL.
+func flattenXMLNode(node xmlNode) map[string]interface{} {
+	item := make(map[string]interface{})
+	var params []map[string]string
+	multi := make(map[string][]string)
+
+	var walk func(n xmlNode, parent string)
+	walk = func(n xmlNode, parent string) {
+		for _, child := range n.Children {
+			name := child.XMLName.Local
+			if name == "PARAM" {
+				var pname, pval string
+				for _, pc := range child.Children {
+					switch pc.XMLName.Local {
+					case "PARAM_NAME", "NAME":
+						pname = strings.TrimSpace(pc.Content)
+					case "VAL", "VALUE":
+						pval = strings.TrimSpace(pc.Content)
+					}
+				}
+				if pname != "" && pval != "" {
+					params = append(params, map[string]string{"name": pname, "value": pval})
+				}
+				continue
+			}
+			if len(child.Children) > 0 {
+				walk(child, name)
+				continue
+			}
+			value := strings.TrimSpace(child.Content)
+			if value == "" {
+				continue
+			}
+			if _, exists := item[name]; !exists {
+				item[name] = value
+			}
+			if parent != "" {
+				if _, exists := item[parent+"_"+name]; !exists {
+					item[parent+"_"+name] = value
+				}
+			}
+			multi[name] = append(multi[name], value)
+		}
+	}
+	walk(node, "")
+
+	if len(params) > 0 {
+		item["_params"] = params
+	}
+	for name, values := range multi {
+		if len(values) < 2 {
+			delete(multi, name)
+		}
+	}
+	if len(multi) > 0 {
+		item["_multi"] = multi
+	}
+	return item
+}
+
+// XMLElementCount is a repeated element that could be the feed's item element
+type XMLElementCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+const (
+	xmlScanBudget     = 1 << 20
+	maxItemCandidates = 5
+)
+
+// scanXMLStructure reads the first MB of data and returns the namespace declarations
+// seen (prefix to URI, "" for the default namespace) and the local names of repeated
+// elements that have child elements, most frequent first
+func scanXMLStructure(data []byte) (map[string]string, []XMLElementCount) {
+	if len(data) > xmlScanBudget {
+		data = data[:xmlScanBudget]
+	}
+	namespaces := map[string]string{}
+	counts := map[string]int{}
+	hasChildren := map[string]bool{}
+	var stack []string
+
+	dec := newXMLDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" {
+					namespaces[attr.Name.Local] = attr.Value
+				} else if attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+					namespaces[""] = attr.Value
+				}
+			}
+			if len(stack) > 0 {
+				hasChildren[stack[len(stack)-1]] = true
+			}
+			counts[t.Name.Local]++
+			stack = append(stack, t.Name.Local)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	var candidates []XMLElementCount
+	for name, n := range counts {
+		if n > 1 && hasChildren[name] {
+			candidates = append(candidates, XMLElementCount{Name: name, Count: n})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Count != candidates[j].Count {
+			return candidates[i].Count > candidates[j].Count
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+	if len(candidates) > maxItemCandidates {
+		candidates = candidates[:maxItemCandidates]
+	}
+	return namespaces, candidates
+}
+
+func formatXMLElementCounts(list []XMLElementCount) string {
+	parts := make([]string, len(list))
+	for i, e := range list {
+		parts[i] = fmt.Sprintf("%s (%d)", e.Name, e.Count)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
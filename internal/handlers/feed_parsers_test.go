@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/testutil"
+)
+
+// lineFeedParser is a minimal third-party format: one item per line of
+// "key=value" pairs separated by "|", after a "#lines" header
+type lineFeedParser struct{}
+
+func (lineFeedParser) Type() string { return "test-lines" }
+
+func (lineFeedParser) DetectType(data []byte) bool { return bytes.HasPrefix(data, []byte("#lines")) }
+
+func (p lineFeedParser) Preview(r io.Reader, opts ParseOptions) FeedPreview {
+	return previewFirstItems(p, r, opts)
+}
+
+func (lineFeedParser) Items(r io.Reader, opts ParseOptions, fn func(item map[string]interface{}) bool) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		item := map[string]interface{}{}
+		for _, pair := range strings.Split(line, "|") {
+			if k, v, ok := strings.Cut(pair, "="); ok {
+				item[k] = v
+			}
+		}
+		if !fn(item) {
+			return nil
+		}
+	}
+	return sc.Err()
+}
+
+func init() {
+	RegisterFeedParser(lineFeedParser{})
+}
+
+func TestRegisteredFeedParser(t *testing.T) {
+	doc := []byte("#lines\nITEM_ID=L1|PRODUCTNAME=Mixér|PRICE_VAT=39.90\nITEM_ID=L2|PRODUCTNAME=Toaster|PRICE_VAT=24.50\n")
+	items, err := parseFeed("test-lines", doc, "")
+	if err != nil || len(items) != 2 {
+		t.Fatalf("parseFeed = %d items, err = %v", len(items), err)
+	}
+	if items[1]["PRODUCTNAME"] != "Toaster" {
+		t.Errorf("second item = %v", items[1])
+	}
+
+	p, _ := feedParserFor("test-lines")
+	preview := p.Preview(bytes.NewReader(doc), ParseOptions{Limit: 1})
+	if len(preview.Sample) != 1 {
+		t.Errorf("preview sampled %d items, want 1", len(preview.Sample))
+	}
+	if got := countFeedItems(p, doc, ParseOptions{}); got != 2 {
+		t.Errorf("countFeedItems = %d, want 2 via Items", got)
+	}
+}
+
+// lineFeed is a test-lines document with three items, the last one without a price
+const lineFeed = "#lines\nITEM_ID=L1|PRODUCTNAME=Mixér|PRICE_VAT=39.90\nITEM_ID=L2|PRODUCTNAME=Toaster|PRICE_VAT=24.50\nITEM_ID=L3|PRODUCTNAME=Kanvica\n"
+
+// TestRegisteredFeedParserPreview previews a test-lines feed over HTTP, detected and
+// with the type given
+func TestRegisteredFeedParserPreview(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, lineFeed)
+	}))
+	defer srv.Close()
+
+	app := fiber.New()
+	app.Post("/preview", (&Handlers{}).PreviewFeed)
+	for _, feedType := range []string{"", "test-lines"} {
+		status, resp := callJSON(t, app, "POST", "/preview", fiber.Map{"url": srv.URL, "type": feedType, "limit": 2})
+		if status != 200 {
+			t.Fatalf("type %q: status %d %s", feedType, status, resp.Error)
+		}
+		var p FeedPreview
+		json.Unmarshal(resp.Data, &p)
+		if p.DetectedType != "test-lines" || p.TotalItems != 3 || p.SampleSize != 2 || len(p.Sample) != 2 {
+			t.Errorf("type %q: detected %q, %d items, sample of %d (%d sent); want test-lines, 3, 2", feedType, p.DetectedType, p.TotalItems, p.SampleSize, len(p.Sample))
+			continue
+		}
+		sort.Strings(p.Fields)
+		if p.Sample[1]["PRODUCTNAME"] != "Toaster" || !reflect.DeepEqual(p.Fields, []string{"ITEM_ID", "PRICE_VAT", "PRODUCTNAME"}) {
+			t.Errorf("type %q: fields %v, sample %v", feedType, p.Fields, p.Sample)
+		}
+	}
+}
+
+// TestRegisteredFeedParserImport imports a test-lines feed through runImport
+func TestRegisteredFeedParserImport(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	feedID := env.createTestFeedFile(t, "Line feed", "test-lines", []byte(lineFeed))
+
+	progress, err := env.h.importFeed(ctx, feedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Status != "completed" || progress.Total != 3 || progress.Created != 2 || progress.Skipped != 1 {
+		t.Fatalf("import %s: %d items, %d created, %d skipped; want 3, 2 and the price-less one skipped", progress.Status, progress.Total, progress.Created, progress.Skipped)
+	}
+	var title string
+	var price float64
+	if err := env.db.Pool.QueryRow(ctx, "SELECT title, price_min FROM products WHERE feed_id = $1::uuid AND sku = 'L2'", feedID).Scan(&title, &price); err != nil {
+		t.Fatal(err)
+	}
+	if title != "Toaster" || price != 24.5 {
+		t.Errorf("imported L2 as %q at %v", title, price)
+	}
+}
+
+func TestFeedParserFormats(t *testing.T) {
+	spec := testutil.FeedSpec{Items: 3, Params: 2, Images: 2, Seed: 3}
+	tests := []struct {
+		format    testutil.FeedFormat
+		parser    string
+		idField   string
+		wantExtra func(t *testing.T, item map[string]interface{})
+	}{
+		{testutil.FormatHeureka, "heureka-xml", "ITEM_ID", func(t *testing.T, item map[string]interface{}) {
+			if params, _ := item["_params"].([]map[string]string); len(params) != 2 || params[0]["name"] != "Farba" {
+				t.Errorf("_params = %v", item["_params"])
+			}
+			if multi, _ := item["_multi"].(map[string][]string); len(multi["IMGURL_ALTERNATIVE"]) != 2 {
+				t.Errorf("_multi = %v", item["_multi"])
+			}
+		}},
+		{testutil.FormatHeureka, "generic-xml", "ITEM_ID", nil},
+		{testutil.FormatCSV, "csv", "ITEM_ID", nil},
+		{testutil.FormatJSON, "json", "id", func(t *testing.T, item map[string]interface{}) {
+			if images, _ := item["images"].([]interface{}); len(images) != 2 {
+				t.Errorf("images = %v", item["images"])
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.parser, func(t *testing.T) {
+			spec.Format = tt.format
+			doc := testutil.GenerateFeed(spec)
+			items, err := parseFeed(tt.parser, doc, "")
+			if err != nil || len(items) != 3 {
+				t.Fatalf("parseFeed = %d items, err = %v", len(items), err)
+			}
+			for i, item := range items {
+				if want := []string{"GEN-0000001", "GEN-0000002", "GEN-0000003"}[i]; item[tt.idField] != want {
+					t.Errorf("item %d %s = %v, want %s", i, tt.idField, item[tt.idField], want)
+				}
+			}
+			if tt.wantExtra != nil {
+				tt.wantExtra(t, items[0])
+			}
+			if p, _ := feedParserFor(tt.parser); p.Type() != "generic-xml" && detectFeedParser(doc) != p {
+				t.Errorf("detected %s, want %s", detectFeedParser(doc).Type(), tt.parser)
+			}
+		})
+	}
+}
+
+func TestHeurekaFeedParser(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="utf-8"?>
+<s:SHOP xmlns:s="http://www.zbozi.cz/ns/offer/1.0">
+<s:SHOPITEM>
+  <s:ITEM_ID>H1</s:ITEM_ID>
+  <s:PRODUCTNAME><![CDATA[Kávovar <Tefal> & mlynček]]></s:PRODUCTNAME>
+  <s:PRICE_VAT>89.90</s:PRICE_VAT>
+  <s:PRICE_BEFORE>99.90</s:PRICE_BEFORE>
+  <s:ITEMGROUP_ID>G7</s:ITEMGROUP_ID>
+  <s:IMGURL_ALTERNATIVE>https://cdn.example.com/1.jpg</s:IMGURL_ALTERNATIVE>
+  <s:IMGURL_ALTERNATIVE>https://cdn.example.com/2.jpg</s:IMGURL_ALTERNATIVE>
+  <s:EXTENDED_WARRANTY><s:VAL>36</s:VAL><s:DESC>mesiacov</s:DESC></s:EXTENDED_WARRANTY>
+  <s:PARAM><s:PARAM_NAME>Farba</s:PARAM_NAME><s:VAL>čierna</s:VAL><s:VAL>biela</s:VAL></s:PARAM>
+  <s:PARAM><s:NAME>Objem</s:NAME><s:VALUE> 1.7 l </s:VALUE></s:PARAM>
+  <s:PARAM><s:PARAM_NAME>Prázdny</s:PARAM_NAME><s:VAL></s:VAL></s:PARAM>
+</s:SHOPITEM>
+<s:SHOPITEM><s:ITEM_ID>H2</s:ITEM_ID><s:PRODUCTNAME>Mlynček</s:PRODUCTNAME></s:SHOPITEM>
+<s:SHOPITEM><s:ITEM_ID>H3</s:ITEM_ID><s:PRODUCTNAME>Rozrezaný`
+	var items []map[string]interface{}
+	err := heurekaFeedParser{}.Items(strings.NewReader(doc), ParseOptions{}, func(item map[string]interface{}) bool {
+		items = append(items, item)
+		return true
+	})
+	if err == nil {
+		t.Error("item cut off mid-way parsed without an error")
+	}
+	if len(items) != 2 {
+		t.Fatalf("%d items, want the 2 complete ones", len(items))
+	}
+
+	item := items[0]
+	for field, want := range map[string]string{
+		"ITEM_ID":               "H1",
+		"PRODUCTNAME":           "Kávovar <Tefal> & mlynček",
+		"PRICE_BEFORE":          "99.90",
+		"ITEMGROUP_ID":          "G7",
+		"IMGURL_ALTERNATIVE":    "https://cdn.example.com/1.jpg",
+		"EXTENDED_WARRANTY_VAL": "36",
+	} {
+		if item[field] != want {
+			t.Errorf("%s = %q, want %q", field, item[field], want)
+		}
+	}
+	for _, field := range []string{"NAME", "PARAM_NAME", "VALUE"} {
+		if v, ok := item[field]; ok {
+			t.Errorf("PARAM child %s leaked into the item as %q", field, v)
+		}
+	}
+	wantParams := []map[string]string{
+		{"name": "Farba", "value": "čierna"},
+		{"name": "Farba", "value": "biela"},
+		{"name": "Objem", "value": "1.7 l"},
+	}
+	if params, _ := item["_params"].([]map[string]string); !reflect.DeepEqual(params, wantParams) {
+		t.Errorf("_params = %v, want %v", item["_params"], wantParams)
+	}
+	if got := getAllStr(item, "IMGURL_ALTERNATIVE"); len(got) != 2 {
+		t.Errorf("alternative images = %v", got)
+	}
+	if _, ok := items[1]["_multi"]; ok {
+		t.Errorf("item without repeated fields has _multi: %v", items[1]["_multi"])
+	}
+}
+
+func TestGoogleFeedParser(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<rss xmlns:g="http://base.google.com/ns/1.0" version="2.0"><channel>
+<item><g:id>G1</g:id><title>Slúchadlá</title><g:price>59.00 EUR</g:price></item>
+<item><g:id>G2</g:id><title>Reproduktor</title><g:price>89.00 EUR</g:price></item>
+</channel></rss>`
+	p := detectFeedParser([]byte(doc))
+	if p.Type() != "google" {
+		t.Fatalf("detected %s, want google", p.Type())
+	}
+	items, err := collectItems(p, strings.NewReader(doc), ParseOptions{ItemPath: "SHOPITEM"})
+	if err != nil || len(items) != 2 || items[1]["id"] != "G2" || items[0]["price"] != "59.00 EUR" {
+		t.Errorf("items = %v, err = %v", items, err)
+	}
+}
+
+// TestFeedParsersStopReading checks that a parser reads no further than the item
+// fn stopped at, so an import or preview never needs the whole document
+func TestFeedParsersStopReading(t *testing.T) {
+	for _, tt := range []struct {
+		format testutil.FeedFormat
+		parser string
+	}{
+		{testutil.FormatHeureka, "heureka-xml"},
+		{testutil.FormatHeureka, "generic-xml"},
+		{testutil.FormatCSV, "csv"},
+		{testutil.FormatJSON, "json"},
+	} {
+		doc := testutil.GenerateFeed(testutil.FeedSpec{Format: tt.format, Items: 2000, Seed: 5})
+		p, _ := feedParserFor(tt.parser)
+		stream := &feedStream{r: bytes.NewReader(doc)}
+		seen := 0
+		err := p.Items(stream, ParseOptions{}, func(map[string]interface{}) bool {
+			seen++
+			return seen < 10
+		})
+		if err != nil || seen != 10 {
+			t.Errorf("%s: %d items, err = %v", tt.parser, seen, err)
+		}
+		if stream.n > int64(len(doc))/10 {
+			t.Errorf("%s read %d of %d bytes for 10 of 2000 items", tt.parser, stream.n, len(doc))
+		}
+	}
+}
+
+func TestCSVFeedParser(t *testing.T) {
+	tests := map[string]struct {
+		doc  string
+		want []map[string]interface{}
+	}{
+		"semicolon": {"ID;NAME\n1; Kanvica \n", []map[string]interface{}{{"ID": "1", "NAME": "Kanvica"}}},
+		"comma with quoted newline": {
+			"ID,NAME,DESC\n1,\"Kanvica, 1.7 l\",\"riadok 1\nriadok 2\"\n2,Hriankovač,\n",
+			[]map[string]interface{}{
+				{"ID": "1", "NAME": "Kanvica, 1.7 l", "DESC": "riadok 1\nriadok 2"},
+				{"ID": "2", "NAME": "Hriankovač", "DESC": ""},
+			},
+		},
+		"tab":         {"ID\tNAME\n7\tLampa", []map[string]interface{}{{"ID": "7", "NAME": "Lampa"}}},
+		"short row":   {"ID;NAME;PRICE\n1;Lampa\n", []map[string]interface{}{{"ID": "1", "NAME": "Lampa"}}},
+		"header only": {"ID;NAME\n", nil},
+		"empty":       {"", nil},
+	}
+	for name, tt := range tests {
+		items, err := collectItems(csvFeedParser{}, strings.NewReader(tt.doc), ParseOptions{})
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(items) != len(tt.want) {
+			t.Errorf("%s: %d items, want %d", name, len(items), len(tt.want))
+			continue
+		}
+		for i := range items {
+			for k, v := range tt.want[i] {
+				if items[i][k] != v {
+					t.Errorf("%s: item %d %s = %q, want %q", name, i, k, items[i][k], v)
+				}
+			}
+		}
+	}
+}
+
+func TestCSVDelimiter(t *testing.T) {
+	for header, want := range map[string]rune{
+		"a;b;c":     ';',
+		"a,b,c":     ',',
+		"a\tb\tc":   '\t',
+		"a":         ';',
+		"a,b;c,d":   ',',
+		"a;b,c;d":   ';',
+		"a,b\tc\td": '\t',
+	} {
+		if got := csvDelimiter(header); got != want {
+			t.Errorf("csvDelimiter(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestFeedStream(t *testing.T) {
+	doc := strings.Repeat("x", xmlScanBudget+100)
+	stream := &feedStream{r: strings.NewReader(doc)}
+	if _, err := io.Copy(io.Discard, stream); err != nil {
+		t.Fatal(err)
+	}
+	if stream.n != int64(len(doc)) || len(stream.head) != xmlScanBudget || stream.err != nil {
+		t.Errorf("read %d bytes, head %d, err %v", stream.n, len(stream.head), stream.err)
+	}
+
+	// a connection dropped mid-feed is a download failure, not a parse error
+	dropped := errors.New("connection reset")
+	stream = &feedStream{r: io.MultiReader(strings.NewReader("<SHOP><SHOPITEM>"), iotest.ErrReader(dropped))}
+	if _, err := collectItems(genericXMLFeedParser{}, stream, ParseOptions{}); err == nil {
+		t.Error("truncated feed parsed without an error")
+	}
+	if stream.err != dropped {
+		t.Errorf("stream err = %v, want the read error", stream.err)
+	}
+}
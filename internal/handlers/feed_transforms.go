@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== FEED TRANSFORM RULES ==========
+
+const (
+	maxFeedTransforms = 50
+	// paramFieldPrefix makes a rule target the value of the named PARAM attribute
+	paramFieldPrefix = "param:"
+	// maxTransformErrorLogs caps the per-item transform errors written to the import log
+	maxTransformErrorLogs = 20
+)
+
+// transformArgs is how many args each operation takes; trim takes an optional cutset
+var transformArgs = map[string][2]int{
+	"replace":       {2, 2},
+	"regex_replace": {2, 2},
+	"prepend":       {1, 1},
+	"append":        {1, 1},
+	"trim":          {0, 1},
+	"lowercase":     {0, 0},
+	"uppercase":     {0, 0},
+	"remove":        {0, 0},
+}
+
+// validateFeedTransforms checks rules before they are saved: known fields and
+// operations, argument counts and compiling patterns
+func validateFeedTransforms(rules []models.FeedTransform) []string {
+	var errs []string
+	if len(rules) > maxFeedTransforms {
+		errs = append(errs, fmt.Sprintf("transform_rules: at most %d rules", maxFeedTransforms))
+	}
+	for i, r := range rules {
+		prefix := fmt.Sprintf("transform_rules[%d]: ", i)
+		if param := strings.TrimPrefix(r.Field, paramFieldPrefix); param != r.Field {
+			if strings.TrimSpace(param) == "" {
+				errs = append(errs, prefix+"param: needs an attribute name")
+			}
+		} else if _, ok := feedFieldSources[r.Field]; !ok {
+			errs = append(errs, fmt.Sprintf("%sunknown field %q", prefix, r.Field))
+		}
+		n, ok := transformArgs[r.Operation]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%sunknown operation %q", prefix, r.Operation))
+			continue
+		}
+		if len(r.Args) < n[0] || len(r.Args) > n[1] {
+			errs = append(errs, fmt.Sprintf("%s%s takes %s", prefix, r.Operation, argCount(n)))
+			continue
+		}
+		switch r.Operation {
+		case "replace":
+			if r.Args[0] == "" {
+				errs = append(errs, prefix+"replace needs a non-empty text to replace")
+			}
+		case "regex_replace":
+			if _, err := regexp.Compile(r.Args[0]); err != nil {
+				errs = append(errs, prefix+"invalid pattern: "+err.Error())
+			}
+		}
+	}
+	return errs
+}
+
+func invalidFeedTransforms(c *fiber.Ctx, errs []string) error {
+	return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid transform rules", "data": fiber.Map{"errors": errs}})
+}
+
+func nonNilTransforms(rules []models.FeedTransform) []models.FeedTransform {
+	if rules == nil {
+		return []models.FeedTransform{}
+	}
+	return rules
+}
+
+func argCount(n [2]int) string {
+	switch {
+	case n[1] == 0:
+		return "no args"
+	case n[0] != n[1]:
+		return fmt.Sprintf("at most %d arg", n[1])
+	case n[0] == 1:
+		return "1 arg"
+	}
+	return fmt.Sprintf("%d args", n[0])
+}
+
+// parseFeedTransforms reads stored rules; a broken column yields no rules
+func parseFeedTransforms(raw string) []models.FeedTransform {
+	var rules []models.FeedTransform
+	json.Unmarshal([]byte(raw), &rules)
+	return nonNilTransforms(rules)
+}
+
+// feedTransformer applies a feed's rules to mapped items
+type feedTransformer struct {
+	rules []compiledTransform
+}
+
+type compiledTransform struct {
+	models.FeedTransform
+	param string
+	re    *regexp.Regexp
+}
+
+// newFeedTransformer compiles rules; rules that no longer validate are left out
+func newFeedTransformer(rules []models.FeedTransform) feedTransformer {
+	var t feedTransformer
+	for _, r := range rules {
+		if len(validateFeedTransforms([]models.FeedTransform{r})) > 0 {
+			continue
+		}
+		c := compiledTransform{FeedTransform: r, param: strings.TrimPrefix(r.Field, paramFieldPrefix)}
+		if c.param == r.Field {
+			c.param = ""
+		}
+		if r.Operation == "regex_replace" {
+			c.re = regexp.MustCompile(r.Args[0])
+		}
+		t.rules = append(t.rules, c)
+	}
+	return t
+}
+
+// applyFields runs the field rules over mapped item data in place. A rule that cannot
+// apply to a value leaves it as it was and is reported; a field left empty is dropped.
+func (t feedTransformer) applyFields(data map[string]interface{}) []error {
+	var errs []error
+	for _, r := range t.rules {
+		if r.param != "" {
+			continue
+		}
+		v, ok := data[r.Field]
+		if !ok || v == nil {
+			continue
+		}
+		if r.Operation == "remove" {
+			delete(data, r.Field)
+			continue
+		}
+		s, ok := transformableString(v)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s %s: value is a %T, not text", r.Field, r.Operation, v))
+			continue
+		}
+		if s = r.apply(s); s == "" {
+			delete(data, r.Field)
+		} else {
+			data[r.Field] = s
+		}
+	}
+	return errs
+}
+
+// applyParams runs the param: rules over an item's attributes, returning new entries
+func (t feedTransformer) applyParams(params []map[string]string) []map[string]string {
+	hasParamRules := false
+	for _, r := range t.rules {
+		hasParamRules = hasParamRules || r.param != ""
+	}
+	if !hasParamRules || len(params) == 0 {
+		return params
+	}
+	out := make([]map[string]string, 0, len(params))
+	for _, p := range params {
+		value, removed := p["value"], false
+		for _, r := range t.rules {
+			if r.param == "" || !strings.EqualFold(r.param, p["name"]) {
+				continue
+			}
+			if r.Operation == "remove" {
+				removed = true
+				break
+			}
+			value = r.apply(value)
+		}
+		if removed || (value == "" && p["value"] != "") {
+			continue
+		}
+		entry := make(map[string]string, len(p))
+		for k, v := range p {
+			entry[k] = v
+		}
+		entry["value"] = value
+		out = append(out, entry)
+	}
+	return out
+}
+
+func (r compiledTransform) apply(s string) string {
+	switch r.Operation {
+	case "replace":
+		return strings.ReplaceAll(s, r.Args[0], r.Args[1])
+	case "regex_replace":
+		return r.re.ReplaceAllString(s, r.Args[1])
+	case "prepend":
+		return r.Args[0] + s
+	case "append":
+		return s + r.Args[0]
+	case "trim":
+		if len(r.Args) == 1 {
+			return strings.Trim(s, r.Args[0])
+		}
+		return strings.TrimSpace(s)
+	case "lowercase":
+		return strings.ToLower(s)
+	case "uppercase":
+		return strings.ToUpper(s)
+	}
+	return s
+}
+
+// transformableString returns text and scalar values as text; lists and objects are not
+func transformableString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64, int, int64, bool, json.Number:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
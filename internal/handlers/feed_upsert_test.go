@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"megabuy-go/internal/models"
+	"megabuy-go/internal/testutil"
+)
+
+// validEAN completes a 12 digit payload with its check digit
+func validEAN(payload string) string {
+	for d := '0'; d <= '9'; d++ {
+		if ean, ok := models.NormalizeEAN(payload + string(d)); ok && len(ean) == 13 {
+			return ean
+		}
+	}
+	panic("no check digit for " + payload)
+}
+
+func TestIsDuplicateEAN(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&pgconn.PgError{Code: "23505", ConstraintName: "idx_products_ean_unique"}, true},
+		{fmt.Errorf("saving: %w", &pgconn.PgError{Code: "23505", ConstraintName: "idx_products_ean_unique"}), true},
+		{&pgconn.PgError{Code: "23505", ConstraintName: "products_slug_key"}, false},
+		{&pgconn.PgError{Code: "23503", ConstraintName: "idx_products_ean_unique"}, false},
+		{errors.New("duplicate key value violates unique constraint \"idx_products_ean_unique\""), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isDuplicateEAN(tt.err); got != tt.want {
+			t.Errorf("isDuplicateEAN(%v) = %v", tt.err, got)
+		}
+	}
+}
+
+// TestConcurrentOverlappingEANUpserts imports the same EANs from several workers at
+// once, each in its own order: every EAN must end up as exactly one product
+func TestConcurrentOverlappingEANUpserts(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	feed := models.Feed{
+		ID:               env.createTestFeed(t, "Upsert", testutil.FeedSpec{Items: 1}),
+		Type:             "heureka-xml",
+		VATRate:          20,
+		PricesIncludeVAT: true,
+		CategoryMode:     "create",
+	}
+	const eans, workers = 25, 8
+	codes := make([]string, eans)
+	for i := range codes {
+		codes[i] = validEAN(fmt.Sprintf("29%06d%04d", time.Now().UnixNano()%1000000, i))
+	}
+	t.Cleanup(func() { env.db.Pool.Exec(ctx, "DELETE FROM products WHERE ean = ANY($1)", codes) })
+
+	type saved struct {
+		id    string
+		isNew bool
+	}
+	results := make([][]saved, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			results[w] = make([]saved, eans)
+			for _, i := range rand.New(rand.NewSource(int64(w))).Perm(eans) {
+				data := map[string]interface{}{
+					"title": fmt.Sprintf("Produkt %d", i),
+					"ean":   codes[i],
+					"price": 10 + float64(w),
+				}
+				id, isNew, err := env.h.saveFeedProduct(ctx, feed, data, nil)
+				if err != nil {
+					t.Errorf("worker %d, EAN %s: %v", w, codes[i], err)
+				}
+				results[w][i] = saved{id, isNew}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for i, code := range codes {
+		var ids []string
+		rows, err := env.db.Pool.Query(ctx, "SELECT id::text FROM products WHERE ean = $1", code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var id string
+			rows.Scan(&id)
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if len(ids) != 1 {
+			t.Errorf("EAN %s stored as %d products", code, len(ids))
+			continue
+		}
+		created := 0
+		for w := 0; w < workers; w++ {
+			if results[w][i].id != ids[0] {
+				t.Errorf("worker %d saved EAN %s as %s, stored as %s", w, code, results[w][i].id, ids[0])
+			}
+			if results[w][i].isNew {
+				created++
+			}
+		}
+		if created != 1 {
+			t.Errorf("EAN %s reported created %d times", code, created)
+		}
+	}
+}
+
+func TestAdminProductDuplicateEAN(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	ean := validEAN(fmt.Sprintf("28%010d", time.Now().UnixNano()%10000000000))
+	t.Cleanup(func() { env.db.Pool.Exec(ctx, "DELETE FROM products WHERE ean = $1", ean) })
+	app := fiber.New()
+	app.Post("/admin/products", env.h.AdminCreateProduct)
+
+	product := map[string]any{"title": "Kanvica", "price_min": 20, "price_max": 20, "ean": ean, "is_active": true}
+	if status, resp := callJSON(t, app, "POST", "/admin/products", product); status != 201 {
+		t.Fatalf("first product: %d %s", status, resp.Error)
+	}
+	product["title"] = "Kanvica kópia"
+	status, resp := callJSON(t, app, "POST", "/admin/products", product)
+	if status != 409 || resp.Error != "Another product already has this EAN" {
+		t.Errorf("second product with the EAN: %d %s", status, resp.Error)
+	}
+}
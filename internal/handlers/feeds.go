@@ -4,13 +4,14 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -19,33 +20,35 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 
-	"megabuy-go/internal/elasticsearch"
+	"megabuy-go/internal/models"
 )
 
-type Feed struct {
-	ID           string            `json:"id"`
-	Name         string            `json:"name"`
-	URL          string            `json:"url"`
-	Type         string            `json:"type"`
-	VendorID     string            `json:"vendor_id,omitempty"`
-	Schedule     string            `json:"schedule"`
-	IsActive     bool              `json:"is_active"`
-	XMLItemPath  string            `json:"xml_item_path,omitempty"`
-	FieldMapping map[string]string `json:"field_mapping,omitempty"`
-	LastRun      *time.Time        `json:"last_run,omitempty"`
-	LastStatus   string            `json:"last_status,omitempty"`
-	ProductCount int               `json:"product_count"`
-	CreatedAt    time.Time         `json:"created_at"`
-	UpdatedAt    time.Time         `json:"updated_at"`
-}
-
 type FeedPreview struct {
-	Fields       []string                 `json:"fields"`
-	Sample       []map[string]interface{} `json:"sample"`
-	TotalItems   int                      `json:"total_items"`
-	DetectedType string                   `json:"detected_type,omitempty"`
-	Attributes   []AttributePreview       `json:"attributes,omitempty"`
-	Categories   []CategoryPreview        `json:"categories,omitempty"`
+	Fields     []string                 `json:"fields"`
+	Sample     []map[string]interface{} `json:"sample"`
+	TotalItems int                      `json:"total_items"`
+	// TotalAccuracy is "exact" when the whole feed was read, otherwise "estimated"
+	TotalAccuracy string             `json:"total_accuracy"`
+	SampleSize    int                `json:"sample_size"`
+	DetectedType  string             `json:"detected_type,omitempty"`
+	Attributes    []AttributePreview `json:"attributes,omitempty"`
+	Categories    []CategoryPreview  `json:"categories,omitempty"`
+	// Namespaces maps declared prefixes ("" for the default) to URIs; XML feeds only
+	Namespaces map[string]string `json:"namespaces,omitempty"`
+	// CandidateItemElements suggests xml_item_path values when the configured one matched nothing
+	CandidateItemElements []XMLElementCount `json:"candidate_item_elements,omitempty"`
+	// MoreAttributes and MoreCategories count the entries left out by the preview limits
+	MoreAttributes int `json:"more_attributes,omitempty"`
+	MoreCategories int `json:"more_categories,omitempty"`
+	// SampleTruncated is set when sample values were cut; TruncatedFields names them
+	SampleTruncated bool     `json:"sample_truncated"`
+	TruncatedFields []string `json:"truncated_fields,omitempty"`
+	// SampleOmitted counts sample items dropped to keep the response under its size limit
+	SampleOmitted int `json:"sample_omitted,omitempty"`
+	// DownloadedBytes is how much of the feed was read for the preview; FeedBytes is
+	// its full size when the server reported it
+	DownloadedBytes int64 `json:"downloaded_bytes"`
+	FeedBytes       int64 `json:"feed_bytes,omitempty"`
 }
 
 type AttributePreview struct {
@@ -60,6 +63,7 @@ type CategoryPreview struct {
 
 type ImportProgress struct {
 	FeedID    string   `json:"feed_id"`
+	RunID     string   `json:"run_id,omitempty"`
 	Status    string   `json:"status"`
 	Message   string   `json:"message"`
 	Total     int      `json:"total"`
@@ -70,6 +74,26 @@ type ImportProgress struct {
 	Errors    int      `json:"errors"`
 	Percent   int      `json:"percent"`
 	Logs      []string `json:"logs"`
+	// QueuePosition is set while the import waits for a free slot
+	QueuePosition int `json:"queue_position,omitempty"`
+	// Trigger is what started the run: manual, scheduled or retry
+	Trigger string `json:"trigger,omitempty"`
+	// CreatedCategories lists categories this run added to the tree
+	CreatedCategories []ImportedCategory `json:"created_categories,omitempty"`
+	// SkipReasons breaks Skipped down by reason
+	SkipReasons map[string]SkipReason `json:"skip_reasons,omitempty"`
+	// InvalidURLs counts image and product URLs dropped because they could not be normalized
+	InvalidURLs int `json:"invalid_urls,omitempty"`
+	// NormalizedEANs counts EANs rewritten to their canonical form, InvalidEANs those
+	// that failed validation and were kept only as ean_raw
+	NormalizedEANs int `json:"normalized_eans,omitempty"`
+	InvalidEANs    int `json:"invalid_eans,omitempty"`
+	// Error classifies the failure that stopped the run
+	Error *ImportError `json:"error,omitempty"`
+	// ErrorSummary groups per-item errors by code
+	ErrorSummary []ImportError `json:"error_summary,omitempty"`
+	// Outcomes classifies skipped and failed items
+	Outcomes ImportOutcomes `json:"outcomes"`
 }
 
 var (
@@ -80,9 +104,13 @@ var (
 func (h *Handlers) GetFeeds(c *fiber.Ctx) error {
 	ctx := context.Background()
 	rows, err := h.db.Pool.Query(ctx, `
-		SELECT id, name, url, type, COALESCE(vendor_id::text,''), schedule, is_active,
+		SELECT id, name, url, COALESCE(type,'xml'), COALESCE(vendor_id::text,''), COALESCE(schedule,'daily'), COALESCE(is_active,true),
 		       COALESCE(xml_item_path,'SHOPITEM'), COALESCE(field_mapping::text,'{}'),
-		       last_run, COALESCE(last_status,'idle'), product_count, created_at, updated_at
+		       COALESCE(prices_include_vat,true), COALESCE(vat_rate,20), COALESCE(attribute_blacklist::text,'[]'),
+		       COALESCE(import_mode,'live'), COALESCE(category_mode,'create'), COALESCE(force_https,false), last_run, COALESCE(last_status,'idle'), COALESCE(product_count,0), created_at, updated_at,
+		       es_sync_deferred_at, COALESCE(transform_rules::text,'[]'),
+		       COALESCE(consecutive_failures,0), COALESCE(needs_attention,false), retry_at,
+		       source_modified_at, source_checked_at
 		FROM feeds ORDER BY created_at DESC
 	`)
 	if err != nil {
@@ -90,21 +118,35 @@ func (h *Handlers) GetFeeds(c *fiber.Ctx) error {
 	}
 	defer rows.Close()
 
-	var feeds []Feed
+	blackout, now := h.importBlackout(), time.Now()
+	var feeds []models.Feed
 	for rows.Next() {
-		var f Feed
-		var fieldMappingStr, vendorID string
-		rows.Scan(&f.ID, &f.Name, &f.URL, &f.Type, &vendorID, &f.Schedule, &f.IsActive,
-			&f.XMLItemPath, &fieldMappingStr, &f.LastRun, &f.LastStatus, &f.ProductCount,
-			&f.CreatedAt, &f.UpdatedAt)
+		var f models.Feed
+		var fieldMappingStr, blacklistStr, transformsStr, vendorID string
+		// a failing scan means the schema is out of date; report it instead of listing nothing
+		if err := rows.Scan(&f.ID, &f.Name, &f.URL, &f.Type, &vendorID, &f.Schedule, &f.IsActive,
+			&f.XMLItemPath, &fieldMappingStr, &f.PricesIncludeVAT, &f.VATRate, &blacklistStr, &f.ImportMode, &f.CategoryMode, &f.ForceHTTPS, &f.LastRun, &f.LastStatus, &f.ProductCount,
+			&f.CreatedAt, &f.UpdatedAt, &f.ESSyncDeferredAt, &transformsStr,
+			&f.ConsecutiveFailures, &f.NeedsAttention, &f.RetryAt, &f.SourceModifiedAt, &f.SourceCheckedAt); err != nil {
+			return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+		}
 		if vendorID != "" {
 			f.VendorID = vendorID
 		}
 		json.Unmarshal([]byte(fieldMappingStr), &f.FieldMapping)
+		json.Unmarshal([]byte(blacklistStr), &f.AttributeBlacklist)
+		if f.AttributeBlacklist == nil {
+			f.AttributeBlacklist = []string{}
+		}
+		f.TransformRules = parseFeedTransforms(transformsStr)
+		f.NextRunAt = nextScheduledRun(blackout, f.Schedule, f.IsActive, f.LastRun, now)
 		feeds = append(feeds, f)
 	}
+	if err := rows.Err(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
 	if feeds == nil {
-		feeds = []Feed{}
+		feeds = []models.Feed{}
 	}
 	return c.JSON(fiber.Map{"success": true, "data": feeds})
 }
@@ -119,6 +161,14 @@ func (h *Handlers) CreateFeed(c *fiber.Ctx) error {
 		IsActive     bool              `json:"is_active"`
 		XMLItemPath  string            `json:"xml_item_path"`
 		FieldMapping map[string]string `json:"field_mapping"`
+		// nil keeps the defaults (VAT-inclusive prices at 20 %)
+		PricesIncludeVAT   *bool                  `json:"prices_include_vat"`
+		VATRate            *float64               `json:"vat_rate"`
+		AttributeBlacklist []string               `json:"attribute_blacklist"`
+		ImportMode         string                 `json:"import_mode"`
+		CategoryMode       string                 `json:"category_mode"`
+		ForceHTTPS         bool                   `json:"force_https"`
+		TransformRules     []models.FeedTransform `json:"transform_rules"`
 	}
 	if err := c.BodyParser(&input); err != nil {
 		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
@@ -129,26 +179,63 @@ func (h *Handlers) CreateFeed(c *fiber.Ctx) error {
 	if input.Type == "" {
 		input.Type = "xml"
 	}
+	if _, ok := feedParserFor(input.Type); !ok {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Unknown feed type: " + input.Type})
+	}
 	if input.Schedule == "" {
 		input.Schedule = "daily"
 	}
+	if !validFeedSchedule(input.Schedule) {
+		return invalidFeedSchedule(c)
+	}
 	if input.XMLItemPath == "" {
 		input.XMLItemPath = "SHOPITEM"
 	}
+	if input.VendorID != "" && !isUUID(input.VendorID) {
+		return invalidUUIDField(c, "vendor_id")
+	}
+	if input.ImportMode == "" {
+		input.ImportMode = "live"
+	}
+	if !validImportMode(input.ImportMode) {
+		return invalidImportMode(c)
+	}
+	if input.CategoryMode == "" {
+		input.CategoryMode = "create"
+	}
+	if !validCategoryMode(input.CategoryMode) {
+		return invalidCategoryMode(c)
+	}
+	if errs := validateFeedTransforms(input.TransformRules); len(errs) > 0 {
+		return invalidFeedTransforms(c, errs)
+	}
 
 	ctx := context.Background()
 	feedID := uuid.New()
 	fieldMappingJSON, _ := json.Marshal(input.FieldMapping)
+	blacklistJSON, _ := json.Marshal(nonNilStrings(input.AttributeBlacklist))
+	transformsJSON, _ := json.Marshal(nonNilTransforms(input.TransformRules))
 
 	var vendorID interface{} = nil
 	if input.VendorID != "" {
 		vendorID = input.VendorID
 	}
 
+	pricesIncludeVAT, vatRate := true, defaultVATRate
+	if input.PricesIncludeVAT != nil {
+		pricesIncludeVAT = *input.PricesIncludeVAT
+	}
+	if input.VATRate != nil {
+		vatRate = *input.VATRate
+	}
+	if vatRate < 0 || vatRate > 100 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "vat_rate must be between 0 and 100"})
+	}
+
 	_, err := h.db.Pool.Exec(ctx, `
-		INSERT INTO feeds (id, name, url, type, vendor_id, schedule, is_active, xml_item_path, field_mapping, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5::uuid, $6, $7, $8, $9::jsonb, NOW(), NOW())
-	`, feedID, input.Name, input.URL, input.Type, vendorID, input.Schedule, input.IsActive, input.XMLItemPath, string(fieldMappingJSON))
+		INSERT INTO feeds (id, name, url, type, vendor_id, schedule, is_active, xml_item_path, field_mapping, prices_include_vat, vat_rate, attribute_blacklist, import_mode, category_mode, force_https, transform_rules, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5::uuid, $6, $7, $8, $9::jsonb, $10, $11, $12::jsonb, $13, $14, $15, $16::jsonb, NOW(), NOW())
+	`, feedID, input.Name, input.URL, input.Type, vendorID, input.Schedule, input.IsActive, input.XMLItemPath, string(fieldMappingJSON), pricesIncludeVAT, vatRate, string(blacklistJSON), input.ImportMode, input.CategoryMode, input.ForceHTTPS, string(transformsJSON))
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
 	}
@@ -166,10 +253,45 @@ func (h *Handlers) UpdateFeed(c *fiber.Ctx) error {
 		IsActive     bool              `json:"is_active"`
 		XMLItemPath  string            `json:"xml_item_path"`
 		FieldMapping map[string]string `json:"field_mapping"`
+		// nil keeps the defaults (VAT-inclusive prices at 20 %)
+		PricesIncludeVAT *bool    `json:"prices_include_vat"`
+		VATRate          *float64 `json:"vat_rate"`
+		// nil keeps the current blacklist
+		AttributeBlacklist *[]string `json:"attribute_blacklist"`
+		// empty keeps the current mode
+		ImportMode   string `json:"import_mode"`
+		CategoryMode string `json:"category_mode"`
+		// nil keeps the current setting
+		ForceHTTPS *bool `json:"force_https"`
+		// nil keeps the current rules
+		TransformRules *[]models.FeedTransform `json:"transform_rules"`
 	}
 	if err := c.BodyParser(&input); err != nil {
 		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
 	}
+	if input.ImportMode != "" && !validImportMode(input.ImportMode) {
+		return invalidImportMode(c)
+	}
+	if input.CategoryMode != "" && !validCategoryMode(input.CategoryMode) {
+		return invalidCategoryMode(c)
+	}
+	if input.VendorID != "" && !isUUID(input.VendorID) {
+		return invalidUUIDField(c, "vendor_id")
+	}
+	if input.Schedule != "" && !validFeedSchedule(input.Schedule) {
+		return invalidFeedSchedule(c)
+	}
+	var transformsJSON interface{} = nil
+	if input.TransformRules != nil {
+		if errs := validateFeedTransforms(*input.TransformRules); len(errs) > 0 {
+			return invalidFeedTransforms(c, errs)
+		}
+		b, _ := json.Marshal(nonNilTransforms(*input.TransformRules))
+		transformsJSON = string(b)
+	}
+	if _, ok := feedParserFor(input.Type); !ok {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Unknown feed type: " + input.Type})
+	}
 
 	ctx := context.Background()
 	fieldMappingJSON, _ := json.Marshal(input.FieldMapping)
@@ -178,11 +300,25 @@ func (h *Handlers) UpdateFeed(c *fiber.Ctx) error {
 		vendorID = input.VendorID
 	}
 
+	if input.VATRate != nil && (*input.VATRate < 0 || *input.VATRate > 100) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "vat_rate must be between 0 and 100"})
+	}
+	var blacklistJSON interface{} = nil
+	if input.AttributeBlacklist != nil {
+		b, _ := json.Marshal(nonNilStrings(*input.AttributeBlacklist))
+		blacklistJSON = string(b)
+	}
+
 	_, err := h.db.Pool.Exec(ctx, `
 		UPDATE feeds SET name=$2, url=$3, type=$4, vendor_id=$5::uuid, schedule=$6, 
-		       is_active=$7, xml_item_path=$8, field_mapping=$9::jsonb, updated_at=NOW()
+		       is_active=$7, xml_item_path=$8, field_mapping=$9::jsonb,
+		       prices_include_vat=COALESCE($10, prices_include_vat), vat_rate=COALESCE($11, vat_rate),
+		       attribute_blacklist=COALESCE($12::jsonb, attribute_blacklist),
+		       import_mode=COALESCE(NULLIF($13,''), import_mode), category_mode=COALESCE(NULLIF($14,''), category_mode),
+		       force_https=COALESCE($15, force_https), transform_rules=COALESCE($16::jsonb, transform_rules), updated_at=NOW(),
+		       source_etag=CASE WHEN url=$3 THEN source_etag END, source_last_modified=CASE WHEN url=$3 THEN source_last_modified END
 		WHERE id=$1::uuid
-	`, feedID, input.Name, input.URL, input.Type, vendorID, input.Schedule, input.IsActive, input.XMLItemPath, string(fieldMappingJSON))
+	`, feedID, input.Name, input.URL, input.Type, vendorID, input.Schedule, input.IsActive, input.XMLItemPath, string(fieldMappingJSON), input.PricesIncludeVAT, input.VATRate, blacklistJSON, input.ImportMode, input.CategoryMode, input.ForceHTTPS, transformsJSON)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
 	}
@@ -204,6 +340,7 @@ func (h *Handlers) PreviewFeed(c *fiber.Ctx) error {
 		URL         string `json:"url"`
 		Type        string `json:"type"`
 		XMLItemPath string `json:"xml_item_path"`
+		Limit       int    `json:"limit"`
 	}
 	if err := c.BodyParser(&input); err != nil {
 		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
@@ -211,22 +348,43 @@ func (h *Handlers) PreviewFeed(c *fiber.Ctx) error {
 	if input.URL == "" {
 		return c.Status(400).JSON(fiber.Map{"success": false, "error": "URL required"})
 	}
+	if input.Limit <= 0 {
+		input.Limit = defaultPreviewItems
+	}
+	if input.Limit > maxPreviewItems {
+		input.Limit = maxPreviewItems
+	}
 
-	data, err := downloadFeedData(input.URL, 2*1024*1024) // 2MB for preview
+	body, size, err := openFeed(input.URL)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Cannot download feed: " + err.Error()})
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, previewCountBudget+1))
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Cannot download feed: " + err.Error()})
 	}
+	// The sample is parsed from the start of the feed and reads on past the head
+	// only while it has fewer than Limit complete items
+	sampleStream := &countingReader{r: io.LimitReader(io.MultiReader(bytes.NewReader(data), body), previewByteBudget)}
+	truncated := len(data) > previewCountBudget
+	if truncated {
+		data = data[:previewCountBudget]
+		// a gzip feed's size is only known from how well the part read compressed
+		if e, ok := body.(interface{ estimatedSize() int64 }); ok {
+			size = e.estimatedSize()
+		}
+	}
 
-	detectedType := input.Type
-	if detectedType == "" {
-		trimmed := bytes.TrimSpace(data)
-		if bytes.HasPrefix(trimmed, []byte("<?xml")) || bytes.HasPrefix(trimmed, []byte("<")) {
-			detectedType = "xml"
-		} else if bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{")) {
-			detectedType = "json"
-		} else {
-			detectedType = "csv"
+	var parser FeedParser
+	if input.Type != "" {
+		p, ok := feedParserFor(input.Type)
+		if !ok {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "Unknown feed type: " + input.Type})
 		}
+		parser = p
+	} else {
+		parser = detectFeedParser(data)
 	}
 
 	itemPath := input.XMLItemPath
@@ -234,61 +392,231 @@ func (h *Handlers) PreviewFeed(c *fiber.Ctx) error {
 		itemPath = "SHOPITEM"
 	}
 
-	var preview FeedPreview
-	switch detectedType {
-	case "xml":
-		preview = parseXMLPreviewWithAttributes(data, itemPath)
-	case "json":
-		preview = parseJSONPreview(data)
-	case "csv":
-		preview = parseCSVPreview(data)
+	opts := ParseOptions{ItemPath: itemPath, Limit: input.Limit}
+	preview := parser.Preview(sampleStream, opts)
+	preview.DetectedType = parser.Type()
+	preview.SampleSize = preview.TotalItems
+	downloaded := max(sampleStream.n, int64(len(data)))
+
+	if truncated && parser.Type() == "csv" {
+		// Drop the partial last row
+		if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+			data = data[:i+1]
+		}
 	}
-	preview.DetectedType = detectedType
 
+	// Count items in the head without decoding them; past it extrapolate by size
+	preview.TotalItems = countFeedItems(parser, data, opts)
+	preview.TotalAccuracy = "exact"
+	if truncated {
+		preview.TotalAccuracy = "estimated"
+		if size > int64(len(data)) && len(data) > 0 {
+			preview.TotalItems = int(float64(preview.TotalItems) * float64(size) / float64(len(data)))
+		}
+	}
+
+	if parser.Type() != "csv" && parser.Type() != "json" {
+		namespaces, candidates := scanXMLStructure(data)
+		if len(namespaces) > 0 {
+			preview.Namespaces = namespaces
+		}
+		if preview.SampleSize == 0 {
+			preview.CandidateItemElements = candidates
+		}
+	}
+
+	preview.DownloadedBytes = downloaded
+	if size > 0 {
+		preview.FeedBytes = size
+	}
+	previewLimitsFromEnv().apply(&preview)
 	return c.JSON(fiber.Map{"success": true, "data": preview})
 }
 
+// loadFeed reads the settings an import needs
+func (h *Handlers) loadFeed(ctx context.Context, feedID string) (models.Feed, error) {
+	var feed models.Feed
+	var fieldMappingStr, blacklistStr, transformsStr string
+	err := h.db.Pool.QueryRow(ctx, `
+		SELECT id, name, url, COALESCE(type,'xml'), COALESCE(xml_item_path,'SHOPITEM'), COALESCE(field_mapping::text,'{}'),
+		       COALESCE(prices_include_vat,true), COALESCE(vat_rate,20), COALESCE(attribute_blacklist::text,'[]'),
+		       COALESCE(import_mode,'live'), COALESCE(category_mode,'create'), COALESCE(force_https,false), COALESCE(transform_rules::text,'[]'),
+		       COALESCE(source_etag,''), COALESCE(source_last_modified,'')
+		FROM feeds WHERE id=$1::uuid
+	`, feedID).Scan(&feed.ID, &feed.Name, &feed.URL, &feed.Type, &feed.XMLItemPath, &fieldMappingStr, &feed.PricesIncludeVAT, &feed.VATRate, &blacklistStr, &feed.ImportMode, &feed.CategoryMode, &feed.ForceHTTPS, &transformsStr,
+		&feed.SourceETag, &feed.SourceLastModified)
+	if err != nil {
+		return feed, err
+	}
+	json.Unmarshal([]byte(fieldMappingStr), &feed.FieldMapping)
+	json.Unmarshal([]byte(blacklistStr), &feed.AttributeBlacklist)
+	feed.TransformRules = parseFeedTransforms(transformsStr)
+	return feed, nil
+}
+
 func (h *Handlers) StartImport(c *fiber.Ctx) error {
 	feedID := c.Params("id")
 	ctx := context.Background()
 
-	var feed Feed
-	var fieldMappingStr string
-	err := h.db.Pool.QueryRow(ctx, `
-		SELECT id, name, url, type, COALESCE(xml_item_path,'SHOPITEM'), COALESCE(field_mapping::text,'{}')
-		FROM feeds WHERE id=$1::uuid
-	`, feedID).Scan(&feed.ID, &feed.Name, &feed.URL, &feed.Type, &feed.XMLItemPath, &fieldMappingStr)
+	feed, err := h.loadFeed(ctx, feedID)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Feed not found"})
 	}
-	json.Unmarshal([]byte(fieldMappingStr), &feed.FieldMapping)
 
-	progressMutex.Lock()
-	importProgress[feedID] = &ImportProgress{
-		FeedID:  feedID,
-		Status:  "downloading",
-		Message: "Stahujem feed...",
-		Logs:    []string{"Import started for: " + feed.Name},
-	}
-	progressMutex.Unlock()
+	// ?force=true downloads the feed even when the source reports it unchanged
+	feed.ForceDownload = c.QueryBool("force")
 
-	h.db.Pool.Exec(ctx, "UPDATE feeds SET last_status='running', last_run=NOW() WHERE id=$1::uuid", feedID)
+	// manual imports may run inside a blackout window, but the caller is warned
+	data := fiber.Map{"status": "started"}
+	if feed.ForceDownload {
+		data["force"] = true
+	}
+	logLine := "Import requested for: " + feed.Name
+	if b := h.importBlackout(); b.activeAt(time.Now()) {
+		data["blackout"] = true
+		data["warning"] = "Import runs inside a blackout window and may slow down search"
+		data["next_allowed_at"] = b.nextAllowed(time.Now())
+		logLine += " (inside blackout window)"
+	}
 
-	go h.runImport(feed)
+	position, err := h.enqueueImport(ctx, feed, importTriggerManual, logLine)
+	if err == errImportActive {
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "Import already running or queued for this feed"})
+	}
+	if position > 0 {
+		data["status"], data["queue_position"] = "queued", position
+		return c.JSON(fiber.Map{"success": true, "message": "Import queued", "data": data})
+	}
+	return c.JSON(fiber.Map{"success": true, "message": "Import started", "data": data})
+}
 
-	return c.JSON(fiber.Map{"success": true, "message": "Import started"})
+// enqueueImport starts the feed's import when a slot is free and queues it otherwise,
+// returning the queue position (0 when started), or errImportActive when the feed
+// is already running or queued
+func (h *Handlers) enqueueImport(ctx context.Context, feed models.Feed, trigger, logLine string) (int, error) {
+	position, err := h.imports.submit(feed, &ImportProgress{
+		FeedID:  feed.ID,
+		Status:  "queued",
+		Message: "Caka v rade na import",
+		Logs:    []string{logLine},
+		Trigger: trigger,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if position > 0 {
+		h.db.Pool.Exec(ctx, "UPDATE feeds SET last_status='queued' WHERE id=$1::uuid", feed.ID)
+		return position, nil
+	}
+	go h.runImportSlot(feed)
+	return 0, nil
 }
 
+const (
+	defaultPreviewItems = 50
+	maxPreviewItems     = 500
+	// previewCountBudget is the head of a feed a preview reads to detect the format
+	// and count items; the total of a longer feed is extrapolated from it
+	previewCountBudget = 2 * 1024 * 1024
+	// previewByteBudget bounds how far past the head a preview streams for its sample
+	// items, and what feed diffs and EAN adoption sample
+	previewByteBudget = 16 * 1024 * 1024
+)
+
 func downloadFeedData(url string, maxBytes int) ([]byte, error) {
+	data, _, _, err := downloadFeedHead(url, maxBytes)
+	return data, err
+}
+
+// feedValidators are the cache validators of a feed download: the ETag and
+// Last-Modified headers, or the modification time of a local file
+type feedValidators struct {
+	ETag         string
+	LastModified string
+}
+
+func (v feedValidators) empty() bool { return v.ETag == "" && v.LastModified == "" }
+
+// modifiedAt parses LastModified; ok is false when it is missing or malformed
+func (v feedValidators) modifiedAt() (time.Time, bool) {
+	t, err := http.ParseTime(v.LastModified)
+	return t, err == nil
+}
+
+// errFeedNotModified reports a source unchanged since the validators were taken
+var errFeedNotModified = fmt.Errorf("feed not modified")
+
+// rememberFeedSource stores the validators of a completed import for the next
+// conditional download; source_modified_at is Last-Modified, or now when the source
+// does not send it
+func (h *Handlers) rememberFeedSource(ctx context.Context, feedID string, v feedValidators) {
+	var modifiedAt *time.Time
+	if at, ok := v.modifiedAt(); ok {
+		modifiedAt = &at
+	}
+	if _, err := h.db.Pool.Exec(ctx, `
+		UPDATE feeds SET source_etag=NULLIF($2,''), source_last_modified=NULLIF($3,''), source_modified_at=COALESCE($4, NOW())
+		WHERE id=$1::uuid
+	`, feedID, v.ETag, v.LastModified, modifiedAt); err != nil {
+		log.Printf("Source validators of feed %s not stored: %v", feedID, err)
+	}
+}
+
+// downloadFeedHead reads at most maxBytes (0 = all) and reports whether the feed
+// was cut off and its full size when known (-1 otherwise)
+func downloadFeedHead(url string, maxBytes int) ([]byte, bool, int64, error) {
+	body, size, err := openFeed(url)
+	if err != nil {
+		return nil, false, 0, err
+	}
+	defer body.Close()
+
+	if maxBytes <= 0 {
+		data, err := io.ReadAll(body)
+		return data, false, size, err
+	}
+	data, err := io.ReadAll(io.LimitReader(body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, false, 0, err
+	}
+	if len(data) > maxBytes {
+		// a gzip feed's size is only known from how well the part read compressed
+		if e, ok := body.(interface{ estimatedSize() int64 }); ok {
+			size = e.estimatedSize()
+		}
+		return data[:maxBytes], true, size, nil
+	}
+	return data, false, size, nil
+}
+
+// openFeed opens a local path or downloads a URL, decompressing gzip and zip feeds;
+// size is the document size, -1 when unknown
+func openFeed(url string) (io.ReadCloser, int64, error) {
+	body, size, _, err := openFeedIfModified(url, feedValidators{})
+	return body, size, err
+}
+
+// openFeedIfModified is openFeed sending prev as If-None-Match / If-Modified-Since.
+// A 304, or a local file whose modification time equals prev.LastModified, returns
+// errFeedNotModified.
+func openFeedIfModified(url string, prev feedValidators) (io.ReadCloser, int64, feedValidators, error) {
 	if strings.HasPrefix(url, "/") {
-		data, err := os.ReadFile(url)
+		f, err := os.Open(url)
 		if err != nil {
-			return nil, err
+			return nil, 0, feedValidators{}, err
+		}
+		size := int64(-1)
+		var validators feedValidators
+		if st, err := f.Stat(); err == nil {
+			size = st.Size()
+			validators.LastModified = st.ModTime().UTC().Format(http.TimeFormat)
 		}
-		if maxBytes > 0 && len(data) > maxBytes {
-			return data[:maxBytes], nil
+		if prev.LastModified != "" && prev.LastModified == validators.LastModified {
+			f.Close()
+			return nil, 0, validators, errFeedNotModified
 		}
-		return data, nil
+		body, size, err := decompressFeed(f, size)
+		return body, size, validators, err
 	}
 
 	tr := &http.Transport{
@@ -306,33 +634,87 @@ func downloadFeedData(url string, maxBytes int) ([]byte, error) {
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, err
+		return nil, 0, feedValidators{}, err
 	}
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
 	req.Header.Set("Accept", "*/*")
+	if prev.ETag != "" {
+		req.Header.Set("If-None-Match", prev.ETag)
+	}
+	if prev.LastModified != "" {
+		req.Header.Set("If-Modified-Since", prev.LastModified)
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, 0, feedValidators{}, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	if resp.StatusCode == http.StatusNotModified && !prev.empty() {
+		resp.Body.Close()
+		return nil, 0, prev, errFeedNotModified
 	}
-
-	if maxBytes > 0 {
-		data := make([]byte, maxBytes)
-		n, _ := io.ReadFull(resp.Body, data)
-		return data[:n], nil
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, 0, feedValidators{}, feedHTTPError{resp.StatusCode}
 	}
 
-	return io.ReadAll(resp.Body)
+	validators := feedValidators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	body, size, err := decompressFeed(resp.Body, resp.ContentLength)
+	return body, size, validators, err
 }
 
-func (h *Handlers) runImport(feed Feed) {
+func (h *Handlers) runImport(feed models.Feed) {
 	ctx := context.Background()
 	feedID := feed.ID
+	startedAt := time.Now()
+	var metrics ImportMetrics
+
+	// Imports not started over HTTP bypass MaintenanceGuard; skip them here so the
+	// next scheduled run picks the feed up after the window
+	if state := h.maintenanceState(); state.Enabled {
+		log.Printf("Import of feed %s skipped: maintenance mode is on", feedID)
+		progressMutex.Lock()
+		if p, ok := importProgress[feedID]; ok {
+			p.Status = "skipped"
+			p.Message = state.message()
+			p.Logs = append(p.Logs, "Skipped: maintenance mode is on")
+		}
+		progressMutex.Unlock()
+		h.db.Pool.Exec(ctx, "UPDATE feeds SET last_status='skipped' WHERE id=$1::uuid", feedID)
+		return
+	}
+
+	runID := h.startImportRun(ctx, feedID)
+	progressMutex.Lock()
+	if p, ok := importProgress[feedID]; ok {
+		p.RunID = runID
+	}
+	progressMutex.Unlock()
+
+	// source holds the validators of the downloaded feed; they are stored only once the
+	// run completes with every item written, so a failed run or one whose writes failed
+	// downloads the feed again next time instead of getting a 304
+	var source feedValidators
+
+	// finishRun records the outcome on the feed_history row and the feed's failure streak
+	finishRun := func(status, errMsg string) {
+		metrics.TotalMs = time.Since(startedAt).Milliseconds()
+		progressMutex.RLock()
+		var snapshot ImportProgress
+		if p, ok := importProgress[feedID]; ok {
+			snapshot = p.snapshot()
+		}
+		progressMutex.RUnlock()
+		h.finishImportRun(ctx, runID, status, errMsg, snapshot, metrics)
+		if status == "completed" && snapshot.Outcomes.DBError == 0 && snapshot.Outcomes.ESError == 0 {
+			h.rememberFeedSource(ctx, feedID, source)
+		}
+		if status == "failed" {
+			h.notifyImportFailed(ctx, feed.Name, runID, errMsg, snapshot)
+		}
+		h.recordImportOutcome(ctx, feed, runID, snapshot.Trigger, status, errMsg)
+	}
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -343,7 +725,9 @@ func (h *Handlers) runImport(feed Feed) {
 				p.Logs = append(p.Logs, fmt.Sprintf("Error: %v", r))
 			}
 			progressMutex.Unlock()
+			setImportError(feedID, newImportError("internal_error", fmt.Errorf("%v", r)))
 			h.db.Pool.Exec(ctx, "UPDATE feeds SET last_status='failed' WHERE id=$1::uuid", feedID)
+			finishRun("failed", fmt.Sprintf("Panic: %v", r))
 		}
 	}()
 
@@ -358,6 +742,29 @@ func (h *Handlers) runImport(feed Feed) {
 		progressMutex.Unlock()
 	}
 
+	// persistProgress writes progress to the run record, at most once per
+	// importProgressFlushInterval unless forced
+	var lastPersist time.Time
+	var persistMu sync.Mutex
+	persistProgress := func(force bool) {
+		persistMu.Lock()
+		defer persistMu.Unlock()
+		if !force && time.Since(lastPersist) < importProgressFlushInterval {
+			return
+		}
+		progressMutex.RLock()
+		p, ok := importProgress[feedID]
+		var snapshot ImportProgress
+		if ok {
+			snapshot = p.snapshot()
+		}
+		progressMutex.RUnlock()
+		if ok {
+			lastPersist = time.Now()
+			h.saveImportProgress(ctx, runID, snapshot)
+		}
+	}
+
 	updateStatus := func(status, message string) {
 		progressMutex.Lock()
 		if p, ok := importProgress[feedID]; ok {
@@ -365,36 +772,91 @@ func (h *Handlers) runImport(feed Feed) {
 			p.Message = message
 		}
 		progressMutex.Unlock()
+		persistProgress(true)
 	}
 
 	addLog("Downloading from: " + feed.URL)
-	data, err := downloadFeedData(feed.URL, 0)
-	if err != nil {
+	prev := feedValidators{ETag: feed.SourceETag, LastModified: feed.SourceLastModified}
+	if feed.ForceDownload {
+		addLog("Forced download, cached ETag / Last-Modified ignored")
+		prev = feedValidators{}
+	}
+	parser, ok := feedParserFor(feed.Type)
+	if !ok {
+		addLog("Unknown feed type: " + feed.Type)
+		updateStatus("failed", "Neznamy typ feedu: "+feed.Type)
+		setImportError(feedID, newImportError("unknown_feed_type", fmt.Errorf("unknown feed type %q", feed.Type)))
+		h.db.Pool.Exec(ctx, "UPDATE feeds SET last_status='failed' WHERE id=$1::uuid", feedID)
+		finishRun("failed", "Unknown feed type: "+feed.Type)
+		return
+	}
+
+	downloadFailed := func(err error) {
 		addLog("Download failed: " + err.Error())
 		updateStatus("failed", "Download failed: "+err.Error())
+		setImportError(feedID, classifyImportError(phaseDownload, err))
 		h.db.Pool.Exec(ctx, "UPDATE feeds SET last_status='failed' WHERE id=$1::uuid", feedID)
+		finishRun("failed", "Download failed: "+err.Error())
+	}
+
+	phaseStart := time.Now()
+	body, _, source, err := openFeedIfModified(feed.URL, prev)
+	metrics.DownloadMs = time.Since(phaseStart).Milliseconds()
+	h.db.Pool.Exec(ctx, "UPDATE feeds SET source_checked_at=NOW() WHERE id=$1::uuid", feedID)
+	if err == errFeedNotModified {
+		msg := "Feed not modified since last import"
+		if at, ok := prev.modifiedAt(); ok {
+			msg += " (" + at.Local().Format("2006-01-02 15:04") + ")"
+		}
+		addLog(msg)
+		updateStatus("not_modified", "Feed sa od posledneho importu nezmenil")
+		h.db.Pool.Exec(ctx, "UPDATE feeds SET last_status='not_modified' WHERE id=$1::uuid", feedID)
+		finishRun("not_modified", "")
+		return
+	}
+	if err != nil {
+		downloadFailed(err)
 		return
 	}
-	addLog(fmt.Sprintf("Downloaded %d KB", len(data)/1024))
 
 	updateStatus("parsing", "Parsujem feed...")
 
-	var items []map[string]interface{}
-	switch feed.Type {
-	case "xml":
-		items = parseFullXMLWithParams(data, feed.XMLItemPath)
-	case "json":
-		items = parseFullJSON(data)
-	case "csv":
-		items = parseFullCSV(data)
+	// The body is parsed as it arrives; ParseMs covers reading it after the headers
+	phaseStart = time.Now()
+	stream := &feedStream{r: body}
+	items, err := collectItems(parser, stream, ParseOptions{ItemPath: feed.XMLItemPath})
+	body.Close()
+	metrics.ParseMs = time.Since(phaseStart).Milliseconds()
+	metrics.DownloadBytes = int(stream.n)
+	if stream.err != nil {
+		downloadFailed(stream.err)
+		return
+	}
+	addLog(fmt.Sprintf("Downloaded %d KB", stream.n/1024))
+	if err != nil {
+		addLog("Parse error: " + err.Error())
+		if len(items) > 0 {
+			recordItemError(feedID, classifyImportError(phaseParse, err))
+		}
 	}
 
 	addLog(fmt.Sprintf("Parsed %d items", len(items)))
 
 	if len(items) == 0 {
 		addLog("No items found in feed")
+		if parser.Type() != "csv" && parser.Type() != "json" {
+			if _, candidates := scanXMLStructure(stream.head); len(candidates) > 0 {
+				addLog(fmt.Sprintf("No <%s> elements; repeated elements in feed: %s", feed.XMLItemPath, formatXMLElementCounts(candidates)))
+			}
+		}
 		updateStatus("failed", "Feed neobsahuje produkty")
+		if err != nil {
+			setImportError(feedID, classifyImportError(phaseParse, err))
+		} else {
+			setImportError(feedID, newImportError("no_items", nil))
+		}
 		h.db.Pool.Exec(ctx, "UPDATE feeds SET last_status='failed' WHERE id=$1::uuid", feedID)
+		finishRun("failed", "No items found in feed")
 		return
 	}
 
@@ -404,74 +866,286 @@ func (h *Handlers) runImport(feed Feed) {
 
 	updateStatus("importing", fmt.Sprintf("Importujem %d produktov...", len(items)))
 
-	created, updated, skipped, errors := 0, 0, 0, 0
-
-	for i, item := range items {
-		productData := mapFields(item, feed.FieldMapping)
-
-		title := getStr(productData, "title")
-		if title == "" {
-			skipped++
-			continue
+	mapper := newFeedItemMapper(feed)
+	invalidURLs, normalizedEANs, invalidEANs, transformErrors := 0, 0, 0, 0
+	created, updated, skipped, errors, processed := 0, 0, 0, 0, 0
+	var outcomes ImportOutcomes
+	var dbWrite time.Duration
+	importStart := time.Now()
+	// countMu guards the counters above, which the import workers update concurrently
+	var countMu sync.Mutex
+
+	// pendingItem is an item whose write hit a transient database error
+	type pendingItem struct {
+		importJob
+		err error
+	}
+	var retries []pendingItem
+
+	// currentOutcomes adds the skipped items, all rejected for their content, to the breakdown
+	currentOutcomes := func() ImportOutcomes {
+		o := outcomes
+		o.ValidationSkip += skipped
+		return o
+	}
+
+	// countSaved counts the outcome of a written item. Unless lastAttempt is set, a
+	// transient database error is returned uncounted so the item can be retried.
+	countSaved := func(job importJob, productID string, isNew bool, err error, lastAttempt bool) error {
+		i, productData := job.index, job.data
+		countMu.Lock()
+		defer countMu.Unlock()
+		if productID == "" && !lastAttempt && isTransientDBError(err) {
+			return err
 		}
-
-		price := getFloat(productData, "price")
-		if price <= 0 {
+		_, unmapped := err.(unmappedCategoryError)
+		_, attrFailed := err.(attributeError)
+		switch {
+		case productID != "" && isNew:
+			created++
+		case productID != "" && (err == nil || attrFailed):
+			updated++
+		case unmapped && feed.CategoryMode == "skip":
 			skipped++
-			continue
+		default:
+			errors++
+			if unmapped || err == errInvalidFeedPrice {
+				outcomes.ValidationSkip++
+			} else {
+				outcomes.DBError++
+			}
 		}
-
-		var existingID string
-		ean := getStr(productData, "ean")
-		sku := getStr(productData, "sku")
-
-		if ean != "" {
-			h.db.Pool.QueryRow(ctx, "SELECT id FROM products WHERE ean=$1", ean).Scan(&existingID)
+		switch {
+		case unmapped && feed.CategoryMode == "skip":
+			recordSkip(feedID, skipUnmappedCategory, itemIdentifier(productData, i))
+		case err != nil && productID != "" && !isNew:
+			addLog(fmt.Sprintf("Update error (%s): %v", productID, err))
+			recordItemError(feedID, classifyImportError(phaseDatabase, err))
+		case err != nil:
+			addLog(fmt.Sprintf("Create error (%s): %v", getStr(productData, "title"), err))
+			recordItemError(feedID, classifyImportError(phaseDatabase, err))
 		}
-		if existingID == "" && sku != "" {
-			h.db.Pool.QueryRow(ctx, "SELECT id FROM products WHERE sku=$1", sku).Scan(&existingID)
+		return nil
+	}
+
+	// countStaged is countSaved for staged imports
+	countStaged := func(isNew bool, err error, lastAttempt bool) error {
+		countMu.Lock()
+		defer countMu.Unlock()
+		switch {
+		case err != nil && !lastAttempt && isTransientDBError(err):
+			return err
+		case err != nil:
+			errors++
+			outcomes.DBError++
+			addLog(fmt.Sprintf("Staging error: %v", err))
+			recordItemError(feedID, classifyImportError(phaseDatabase, err))
+		case isNew:
+			created++
+		default:
+			updated++
 		}
-
-		// Get PARAM attributes from item
-		params := getParams(item)
-
-		if existingID != "" {
-			err := h.updateProductFromFeed(ctx, existingID, productData, params)
-			if err == nil {
-				updated++
-			} else {
-				errors++
-				addLog(fmt.Sprintf("Update error: %v", err))
+		return nil
+	}
+
+	// saveItems writes a batch of items and counts their outcomes, returning the items
+	// that hit a transient database error
+	categories := newImportCategoryCache()
+	saveItems := func(batch []importJob, lastAttempt bool) []pendingItem {
+		var pending []pendingItem
+		writeStart := time.Now()
+		if feed.ImportMode == "staged" {
+			for _, job := range batch {
+				isNew, err := h.stageProduct(ctx, feed.ID, job.data, job.params)
+				if err := countStaged(isNew, err, lastAttempt); err != nil {
+					pending = append(pending, pendingItem{importJob: job, err: err})
+				}
 			}
 		} else {
-			newID := h.createProductFromFeed(ctx, productData, feedID, params)
-			if newID != "" {
-				created++
-			} else {
-				errors++
+			for i, r := range h.saveFeedBatch(ctx, feed, batch, categories) {
+				if err := countSaved(batch[i], r.productID, r.isNew, r.err, lastAttempt); err != nil {
+					pending = append(pending, pendingItem{importJob: batch[i], err: err})
+				}
 			}
 		}
-
-		if (i+1)%50 == 0 || i == len(items)-1 {
+		countMu.Lock()
+		dbWrite += time.Since(writeStart)
+		metrics.DBBatches++
+		countMu.Unlock()
+		return pending
+	}
+
+	// itemDone counts a finished item, skipped or written, and publishes progress
+	// every 50 items; items finish out of feed order once workers are involved
+	itemDone := func() {
+		countMu.Lock()
+		processed++
+		done := processed
+		publish := done%50 == 0 || done == len(items)
+		if publish {
 			progressMutex.Lock()
 			if p, ok := importProgress[feedID]; ok {
-				p.Processed = i + 1
+				p.Processed = done
 				p.Created = created
 				p.Updated = updated
 				p.Skipped = skipped
 				p.Errors = errors
-				p.Percent = ((i + 1) * 100) / len(items)
-				p.Message = fmt.Sprintf("Spracovane %d/%d", i+1, len(items))
+				p.InvalidURLs = invalidURLs
+				p.NormalizedEANs = normalizedEANs
+				p.InvalidEANs = invalidEANs
+				p.Outcomes = currentOutcomes()
+				p.Percent = (done * 100) / len(items)
+				p.Message = fmt.Sprintf("Spracovane %d/%d", done, len(items))
 			}
 			progressMutex.Unlock()
 		}
+		logLine := ""
+		if done%500 == 0 {
+			logLine = fmt.Sprintf("Progress: %d/%d (created: %d, updated: %d)", done, len(items), created, updated)
+		}
+		countMu.Unlock()
+
+		if publish {
+			persistProgress(false)
+		}
+		if logLine != "" {
+			addLog(logLine)
+		}
+	}
+
+	// Mapping stays on this goroutine because the mapper tracks duplicates; the
+	// database writes, which dominate an import, are spread over the workers, each
+	// writing importBatchSize items per round trip
+	workers, batchSize := importWorkerCount(), importBatchSize()
+	metrics.Workers = workers
+	jobs := make(chan importJob, workers*batchSize)
+	// work writes one batch on a worker; a panic fails only that batch because the
+	// recover of runImport does not reach the worker goroutines
+	work := func(batch []importJob) {
+		defer func() {
+			if r := recover(); r != nil {
+				countMu.Lock()
+				errors += len(batch)
+				outcomes.DBError += len(batch)
+				countMu.Unlock()
+				addLog(fmt.Sprintf("Error (%s and %d more): %v", itemIdentifier(batch[0].data, batch[0].index), len(batch)-1, r))
+				recordItemError(feedID, newImportError("internal_error", fmt.Errorf("%v", r)))
+			}
+		}()
+		if pending := saveItems(batch, false); len(pending) > 0 {
+			countMu.Lock()
+			retries = append(retries, pending...)
+			countMu.Unlock()
+		}
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			batch := make([]importJob, 0, batchSize)
+			flush := func() {
+				work(batch)
+				for range batch {
+					itemDone()
+				}
+				batch = batch[:0]
+			}
+			for job := range jobs {
+				batch = append(batch, job)
+				if len(batch) == batchSize {
+					flush()
+				}
+			}
+			if len(batch) > 0 {
+				flush()
+			}
+		}()
+	}
+	addLog(fmt.Sprintf("Writing items with %d workers in batches of %d", workers, batchSize))
+
+	for i, item := range items {
+		mapped := mapper.mapItem(item)
+		productData := mapped.data
+		countMu.Lock()
+		for _, err := range mapped.transformErrors {
+			transformErrors++
+			if transformErrors <= maxTransformErrorLogs {
+				addLog(fmt.Sprintf("Transform rule skipped (%s): %v", itemIdentifier(productData, i), err))
+			}
+			recordItemError(feedID, newImportError("transform_failed", err))
+		}
 
-		if (i+1)%500 == 0 {
-			addLog(fmt.Sprintf("Progress: %d/%d (created: %d, updated: %d)", i+1, len(items), created, updated))
+		switch mapped.ean {
+		case eanNormalized:
+			normalizedEANs++
+		case eanInvalid:
+			invalidEANs++
+		}
+		if mapped.skip != "" {
+			skipped++
+			countMu.Unlock()
+			recordSkip(feedID, mapped.skip, itemIdentifier(productData, i))
+			itemDone()
+			continue
+		}
+		for _, bad := range mapped.invalidURLs {
+			invalidURLs++
+			if invalidURLs <= maxInvalidURLLogs {
+				addLog(fmt.Sprintf("Invalid URL dropped (%s) %s", itemIdentifier(productData, i), bad))
+			}
 		}
+		countMu.Unlock()
+
+		jobs <- importJob{index: i, data: productData, params: mapped.params}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Items that hit a transient database error get up to importDBRetries more
+	// attempts once the feed has been walked, and only count as failed after that
+	if len(retries) > 0 {
+		sort.Slice(retries, func(a, b int) bool { return retries[a].index < retries[b].index })
+		addLog(fmt.Sprintf("Retrying %d items after transient database errors", len(retries)))
+		outcomes.Retried = len(retries)
+		for attempt := 1; attempt <= importDBRetries && len(retries) > 0; attempt++ {
+			time.Sleep(time.Duration(attempt) * importDBRetryDelay)
+			var failed []pendingItem
+			for _, r := range retries {
+				metrics.Retries++
+				before := created + updated
+				if pending := saveItems([]importJob{r.importJob}, attempt == importDBRetries); len(pending) > 0 {
+					failed = append(failed, pending[0])
+				} else if created+updated > before {
+					outcomes.Recovered++
+				}
+			}
+			retries = failed
+		}
+		addLog(fmt.Sprintf("Retries recovered %d of %d items", outcomes.Recovered, outcomes.Retried))
+	}
+
+	metrics.DBWriteMs = dbWrite.Milliseconds()
+	if metrics.DBBatches > 0 {
+		metrics.DBBatchSize = float64(created+updated+errors) / float64(metrics.DBBatches)
+	}
+	if elapsed := time.Since(importStart).Seconds(); elapsed > 0 {
+		metrics.ItemsPerSecond = float64(len(items)) / elapsed
 	}
 
+	if invalidURLs > 0 {
+		addLog(fmt.Sprintf("Dropped %d invalid image/product URLs", invalidURLs))
+	}
+	if transformErrors > 0 {
+		addLog(fmt.Sprintf("Transform rules could not apply %d times", transformErrors))
+	}
+	if normalizedEANs > 0 || invalidEANs > 0 {
+		addLog(fmt.Sprintf("EANs: %d normalized, %d invalid kept out of matching", normalizedEANs, invalidEANs))
+	}
 	addLog(fmt.Sprintf("Completed: %d created, %d updated, %d skipped, %d errors", created, updated, skipped, errors))
+	if o := currentOutcomes(); o.ValidationSkip > 0 || o.DBError > 0 {
+		addLog(fmt.Sprintf("Outcomes: %d invalid items, %d database errors", o.ValidationSkip, o.DBError))
+	}
 	updateStatus("completed", fmt.Sprintf("Hotovo: %d vytvorenych, %d aktualizovanych", created, updated))
 
 	progressMutex.Lock()
@@ -482,18 +1156,125 @@ func (h *Handlers) runImport(feed Feed) {
 		p.Updated = updated
 		p.Skipped = skipped
 		p.Errors = errors
+		p.InvalidURLs = invalidURLs
+		p.NormalizedEANs = normalizedEANs
+		p.InvalidEANs = invalidEANs
+		p.Outcomes = currentOutcomes()
 	}
 	progressMutex.Unlock()
 
+	if feed.ImportMode == "staged" {
+		addLog(fmt.Sprintf("Staged %d new and %d changed items for approval", created, updated))
+		h.db.Pool.Exec(ctx, "UPDATE feeds SET last_status='completed' WHERE id=$1::uuid", feedID)
+		finishRun("completed", "")
+		return
+	}
+
 	h.db.Pool.Exec(ctx, "UPDATE feeds SET last_status='completed', product_count=$2 WHERE id=$1::uuid", feedID, created+updated)
 
+	if resolved, pending := h.resolveFeedRelations(ctx, feedID); resolved > 0 || pending > 0 {
+		addLog(fmt.Sprintf("Related products: %d resolved, %d still unresolved", resolved, pending))
+	}
+
 	// Update category counts
-	h.db.Pool.Exec(ctx, `UPDATE categories SET product_count = (SELECT COUNT(*) FROM products WHERE category_id = categories.id AND is_active = true)`)
+	h.recountCategories(ctx)
 
-	// Sync to Elasticsearch
+	if changed, err := h.refreshCompleteness(ctx, "p.feed_id = $1::uuid", feedID); err != nil {
+		addLog("Completeness scores not refreshed: " + err.Error())
+	} else {
+		addLog(fmt.Sprintf("Completeness scores refreshed: %d changed", changed))
+	}
+
+	// Sync to Elasticsearch, unless a blackout window holds it back; the products are
+	// already live in the database and RunImportScheduler indexes them afterwards
+	if until, deferred := h.deferESSync(ctx, feedID); deferred {
+		addLog("Elasticsearch sync deferred until " + until.Format("2006-01-02 15:04") + " (blackout window)")
+		h.checkPriceAlerts(ctx)
+		finishRun("completed", "")
+		return
+	}
 	addLog("Syncing to Elasticsearch...")
-	h.syncFeedProductsToES(ctx, feedID)
-	addLog("Elasticsearch sync completed")
+	phaseStart = time.Now()
+	err = h.syncFeedProductsToES(ctx, feedID)
+	metrics.ESSyncMs = time.Since(phaseStart).Milliseconds()
+	if err != nil {
+		addLog("Elasticsearch sync failed: " + err.Error())
+		recordItemError(feedID, classifyImportError(phaseSearch, err))
+		progressMutex.Lock()
+		if p, ok := importProgress[feedID]; ok {
+			p.Outcomes.ESError = created + updated
+		}
+		progressMutex.Unlock()
+	} else {
+		h.db.Pool.Exec(ctx, "UPDATE feeds SET es_sync_deferred_at = NULL WHERE id=$1::uuid", feedID)
+		addLog("Elasticsearch sync completed")
+	}
+
+	h.checkPriceAlerts(ctx)
+
+	finishRun("completed", "")
+}
+
+// feedItemMapper is the mapping stage of an import: it turns parsed items into the
+// product data saveFeedProduct writes and rejects items that cannot be imported.
+// Duplicate detection makes it stateful, so use one mapper per import run.
+type feedItemMapper struct {
+	feed       models.Feed
+	blacklist  attributeBlacklist
+	urlNorm    feedURLNormalizer
+	transforms feedTransformer
+	seenKeys   map[string]bool
+}
+
+func newFeedItemMapper(feed models.Feed) *feedItemMapper {
+	return &feedItemMapper{
+		feed:       feed,
+		blacklist:  newAttributeBlacklist(feed.AttributeBlacklist),
+		urlNorm:    newFeedURLNormalizer(feed),
+		transforms: newFeedTransformer(feed.TransformRules),
+		seenKeys:   map[string]bool{},
+	}
+}
+
+// mappedItem is one item after mapping; skip names the reason it is not imported
+type mappedItem struct {
+	data        map[string]interface{}
+	params      []map[string]string
+	skip        string
+	ean         eanOutcome
+	invalidURLs []string
+	// transformErrors are rules that could not apply; the item is imported without them
+	transformErrors []error
+}
+
+func (m *feedItemMapper) mapItem(item map[string]interface{}) mappedItem {
+	mapped := mappedItem{data: mapFields(item, m.feed.FieldMapping)}
+	mapped.transformErrors = m.transforms.applyFields(mapped.data)
+	if getStr(mapped.data, "title") == "" {
+		mapped.skip = skipMissingTitle
+		return mapped
+	}
+	if getFloat(mapped.data, "price") <= 0 {
+		mapped.skip = skipInvalidPrice
+		return mapped
+	}
+
+	mapped.ean = normalizeItemEAN(mapped.data)
+	if key := duplicateKey(mapped.data); key != "" {
+		if m.seenKeys[key] {
+			mapped.skip = skipDuplicateInFeed
+			return mapped
+		}
+		m.seenKeys[key] = true
+	}
+
+	// Get PARAM attributes from item
+	mapped.params = m.transforms.applyParams(filterBlacklistedParams(getParams(item), m.blacklist))
+	media, invalidMedia := mapMedia(item, m.feed.FieldMapping, m.urlNorm)
+	mapped.data["_media"] = media
+	mapped.data["_relations"] = mapRelations(item)
+	mapped.invalidURLs = append(m.urlNorm.normalizeFields(mapped.data), invalidMedia...)
+	return mapped
 }
 
 // getParams extracts PARAM attributes from parsed item
@@ -507,196 +1288,380 @@ func getParams(item map[string]interface{}) []map[string]string {
 	return params
 }
 
-func (h *Handlers) createProductFromFeed(ctx context.Context, data map[string]interface{}, feedID string, params []map[string]string) string {
-	productID := uuid.New()
-	title := getStr(data, "title")
-	slug := makeSlug(title)
-	description := getStr(data, "description")
-	shortDesc := getStr(data, "short_description")
+// importSource is the products.source value recorded for items created by a feed of the given type
+func importSource(feedType string) string {
+	if feedType == "csv" {
+		return "csv"
+	}
+	return "import"
+}
+
+// attributeError reports a product that was saved while its attributes were not
+type attributeError struct{ err error }
+
+func (e attributeError) Error() string { return "saving attributes: " + e.err.Error() }
+
+// errInvalidFeedPrice rejects items that would break price_max >= price_min > 0. Feed
+// items carry one price, stored as both price_min and price_max.
+var errInvalidFeedPrice = fmt.Errorf("price must be greater than 0")
+
+// saveFeedProduct writes an import item to the catalog and reports the product ID and
+// whether it was created. Items with an EAN are upserted atomically on it; items
+// without one are matched by SKU and then updated or inserted.
+func (h *Handlers) saveFeedProduct(ctx context.Context, feed models.Feed, data map[string]interface{}, params []map[string]string) (string, bool, error) {
+	if getStr(data, "ean") == "" {
+		if existingID := h.findExistingProduct(ctx, "", getStr(data, "sku")); existingID != "" {
+			return existingID, false, h.updateProductFromFeed(ctx, feed, existingID, data, params)
+		}
+	}
+	return h.upsertProductFromFeed(ctx, feed, data, params)
+}
+
+// upsertProductFromFeed inserts the item, or, when a product with the same EAN exists,
+// updates it in the same statement under the rules of updateProductFromFeed, so
+// concurrent imports of one EAN cannot create duplicates. It returns the product ID
+// ("" when nothing was saved) and whether the product is new. An attributeError
+// comes with a valid ID.
+func (h *Handlers) upsertProductFromFeed(ctx context.Context, feed models.Feed, data map[string]interface{}, params []map[string]string) (string, bool, error) {
+	if !validFeedPrice(feed, data) {
+		return "", false, errInvalidFeedPrice
+	}
 	ean := getStr(data, "ean")
-	sku := getStr(data, "sku")
-	brand := getStr(data, "brand")
-	imageURL := getStr(data, "image_url")
-	affiliateURL := getStr(data, "affiliate_url")
-	category := getStr(data, "category")
-	price := getFloat(data, "price")
 
 	var categoryID *string
-	if category != "" {
-		catID := h.findOrCreateCategoryFeed(ctx, category)
+	if category := getStr(data, "category"); category != "" {
+		catID, err := h.findOrCreateCategoryFeed(ctx, feed, category)
+		if _, unmapped := err.(unmappedCategoryError); unmapped && ean != "" {
+			// existing products are never moved between categories, so an unmapped
+			// path only prevents creating the product
+			if existingID := h.findExistingProduct(ctx, ean, ""); existingID != "" {
+				return existingID, false, h.updateProductFromFeed(ctx, feed, existingID, data, params)
+			}
+		}
+		if err != nil {
+			return "", false, err
+		}
 		if catID != "" {
 			categoryID = &catID
 		}
 	}
 
-	_, err := h.db.Pool.Exec(ctx, `
-		INSERT INTO products (id, title, slug, description, short_description, ean, sku, brand, 
-		                      image_url, affiliate_url, category_id, price_min, price_max, stock_status, is_active, feed_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $12, 'instock', true, $13::uuid, NOW(), NOW())
-	`, productID, title, slug, description, shortDesc, ean, sku, brand, imageURL, affiliateURL, categoryID, price, feedID)
-
+	var productID, oldStatus, newStatus string
+	var inserted bool
+	err := h.db.Pool.QueryRow(ctx, upsertFeedProductSQL, upsertFeedProductArgs(feed, data, categoryID)...).Scan(&productID, &inserted, &oldStatus, &newStatus)
 	if err != nil {
-		return ""
+		return "", false, err
 	}
 
 	// Save PARAM attributes
-	h.saveProductAttributes(ctx, productID.String(), params)
+	attrErr := h.saveProductAttributes(ctx, productID, params)
+	if attrErr == nil {
+		h.generateShortDescription(ctx, productID)
+	}
+	if media, ok := data["_media"].([]feedMedia); ok {
+		h.saveFeedMedia(ctx, productID, media)
+	}
+	if relations, ok := data["_relations"].([]feedRelation); ok {
+		h.saveFeedRelations(ctx, feed.ID, productID, relations)
+	}
 
-	if categoryID != nil {
-		h.db.Pool.Exec(ctx, "UPDATE categories SET product_count = product_count + 1 WHERE id = $1::uuid", *categoryID)
+	if inserted && categoryID != nil {
+		h.db.Pool.Exec(ctx, `
+			UPDATE categories SET product_count = product_count + 1,
+			       in_stock_count = in_stock_count + CASE WHEN $2 <> 'outofstock' THEN 1 ELSE 0 END
+			WHERE id = $1::uuid
+		`, *categoryID, newStatus)
+	}
+	if !inserted && isBackInStock(oldStatus, newStatus) {
+		h.fireStockAlerts(ctx, []string{productID})
 	}
 
-	return productID.String()
+	if attrErr != nil {
+		return productID, inserted, attributeError{attrErr}
+	}
+	return productID, inserted, nil
 }
 
-func (h *Handlers) updateProductFromFeed(ctx context.Context, productID string, data map[string]interface{}, params []map[string]string) error {
-	title := getStr(data, "title")
-	description := getStr(data, "description")
-	imageURL := getStr(data, "image_url")
-	price := getFloat(data, "price")
+func (h *Handlers) updateProductFromFeed(ctx context.Context, feed models.Feed, productID string, data map[string]interface{}, params []map[string]string) error {
+	if !validFeedPrice(feed, data) {
+		return errInvalidFeedPrice
+	}
 
-	_, err := h.db.Pool.Exec(ctx, `
-		UPDATE products SET title=COALESCE(NULLIF($2,''),title), description=COALESCE(NULLIF($3,''),description),
-		       image_url=COALESCE(NULLIF($4,''),image_url), price_min=$5, price_max=$5, updated_at=NOW()
-		WHERE id=$1::uuid
-	`, productID, title, description, imageURL, price)
+	var oldStatus, newStatus string
+	err := h.db.Pool.QueryRow(ctx, updateFeedProductSQL, updateFeedProductArgs(feed, productID, data)...).Scan(&oldStatus, &newStatus)
 
+	var attrErr error
 	if err == nil {
 		// Update PARAM attributes
-		h.saveProductAttributes(ctx, productID, params)
+		attrErr = h.saveProductAttributes(ctx, productID, params)
+		if attrErr == nil {
+			h.generateShortDescription(ctx, productID)
+		}
+		if media, ok := data["_media"].([]feedMedia); ok {
+			h.saveFeedMedia(ctx, productID, media)
+		}
+		if relations, ok := data["_relations"].([]feedRelation); ok {
+			h.saveFeedRelations(ctx, feed.ID, productID, relations)
+		}
+
+		if isBackInStock(oldStatus, newStatus) {
+			h.fireStockAlerts(ctx, []string{productID})
+		}
 	}
 
+	if err == nil && attrErr != nil {
+		return attributeError{attrErr}
+	}
 	return err
 }
 
-// saveProductAttributes saves PARAM tags to product_attributes table
-func (h *Handlers) saveProductAttributes(ctx context.Context, productID string, params []map[string]string) {
-	if len(params) == 0 {
-		return
-	}
+// validFeedPrice reports whether the item's price, after VAT handling, is positive
+func validFeedPrice(feed models.Feed, data map[string]interface{}) bool {
+	gross, _ := splitPrice(getFloat(data, "price"), feed.VATRate, feed.PricesIncludeVAT)
+	return gross > 0
+}
 
-	// Delete existing attributes for this product
-	h.db.Pool.Exec(ctx, "DELETE FROM product_attributes WHERE product_id = $1::uuid", productID)
+// upsertFeedProductSQL inserts an import item or updates the product with its EAN, and
+// returns the product ID, whether it was inserted and the stock status before and after
+const upsertFeedProductSQL = `
+		WITH old AS (SELECT COALESCE(stock_status,'instock') AS status FROM products WHERE ean = NULLIF($6,'') FOR UPDATE)
+		INSERT INTO products (id, title, slug, description, short_description, ean, sku, brand, 
+		                      image_url, affiliate_url, category_id, price_min, price_max, price_min_net, price_max_net,
+		                      vat_rate, price_is_gross, stock_status, is_active, feed_id, source, original_price, price_high,
+		                      description_plain, excerpt, ean_raw, release_date, gift_text, warranty_months, extra_message, item_group_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6,''), $7, $8, $9, $10, $11, $12, $12, $16, $16, $17, $18, $14, true, $13::uuid, $15, $19, $12, $20, $21, NULLIF($22,''), $23, NULLIF($25,''), $26, NULLIF($27,''), NULLIF($28,''), NOW(), NOW())
+		ON CONFLICT (ean) WHERE ean IS NOT NULL AND ean <> '' DO UPDATE SET
+		       title=COALESCE(NULLIF(EXCLUDED.title,''),products.title), description=COALESCE(NULLIF(EXCLUDED.description,''),products.description),
+		       description_plain=CASE WHEN EXCLUDED.description = '' THEN products.description_plain ELSE EXCLUDED.description_plain END,
+		       excerpt=CASE WHEN EXCLUDED.description = '' THEN products.excerpt ELSE EXCLUDED.excerpt END,
+		       image_url=COALESCE(NULLIF(EXCLUDED.image_url,''),products.image_url), price_min=EXCLUDED.price_min, price_max=EXCLUDED.price_max,
+		       price_min_net=EXCLUDED.price_min_net, price_max_net=EXCLUDED.price_max_net, vat_rate=EXCLUDED.vat_rate, price_is_gross=EXCLUDED.price_is_gross,
+		       original_price=EXCLUDED.original_price, price_high=GREATEST(COALESCE(products.price_high,0), EXCLUDED.price_min),
+		       stock_status=COALESCE(NULLIF($24,''),products.stock_status), release_date=COALESCE(EXCLUDED.release_date, products.release_date),
+		       gift_text=EXCLUDED.gift_text, extra_message=EXCLUDED.extra_message, warranty_months=COALESCE(EXCLUDED.warranty_months, products.warranty_months),
+		       item_group_id=COALESCE(EXCLUDED.item_group_id, products.item_group_id),
+		       updated_at=NOW(), version=COALESCE(products.version,1)+1
+		RETURNING id::text, xmax = 0, COALESCE((SELECT status FROM old), ''), COALESCE(stock_status,'instock')
+	`
+
+func upsertFeedProductArgs(feed models.Feed, data map[string]interface{}, categoryID *string) []interface{} {
+	title := getStr(data, "title")
+	description := getStr(data, "description")
+	plain, excerpt := descriptionVariants(description)
+	gross, net := splitPrice(getFloat(data, "price"), feed.VATRate, feed.PricesIncludeVAT)
+	feedStatus := normalizeStockStatus(getStr(data, "stock_status"))
+	stockStatus := feedStatus
+	if stockStatus == "" {
+		stockStatus = "instock"
+	}
+	releaseDate, _ := parseReleaseDate(getStr(data, "release_date"))
+	extras := mapFeedExtras(data)
+	return []interface{}{uuid.New(), title, makeSlug(title), description, getStr(data, "short_description"), getStr(data, "ean"), getStr(data, "sku"), getStr(data, "brand"),
+		getStr(data, "image_url"), getStr(data, "affiliate_url"), categoryID, gross, feed.ID, stockStatus, importSource(feed.Type), net, feed.VATRate, feed.PricesIncludeVAT,
+		feedOriginalPrice(feed, data), plain, excerpt, getStr(data, "ean_raw"), releaseDate, feedStatus,
+		extras.GiftText, extras.WarrantyMonths, extras.ExtraMessage, getStr(data, "item_group_id")}
+}
 
-	// Insert new attributes - using existing table structure (name, value, position)
-	for i, param := range params {
-		name := param["name"]
-		value := param["value"]
-		if name != "" && value != "" {
-			h.db.Pool.Exec(ctx, `
-				INSERT INTO product_attributes (id, product_id, name, value, position, created_at)
-				VALUES ($1::uuid, $2::uuid, $3, $4, $5, NOW())
-			`, uuid.New().String(), productID, name, value, i)
+// updateFeedProductSQL applies an import item to a matched product and returns its
+// stock status before and after
+const updateFeedProductSQL = `
+		UPDATE products p SET title=COALESCE(NULLIF($2,''),title), description=COALESCE(NULLIF($3,''),description),
+		       description_plain=CASE WHEN $3 = '' THEN description_plain ELSE $11 END,
+		       excerpt=CASE WHEN $3 = '' THEN excerpt ELSE $12 END,
+		       image_url=COALESCE(NULLIF($4,''),image_url), price_min=$5, price_max=$5,
+		       price_min_net=$7, price_max_net=$7, vat_rate=$8, price_is_gross=$9,
+		       original_price=$10, price_high=GREATEST(COALESCE(p.price_high,0), $5),
+		       stock_status=COALESCE(NULLIF($6,''),stock_status), release_date=COALESCE($13, release_date),
+		       gift_text=NULLIF($14,''), extra_message=NULLIF($16,''), warranty_months=COALESCE($15, warranty_months),
+		       item_group_id=COALESCE(NULLIF($17,''), item_group_id),
+		       updated_at=NOW(), version=COALESCE(p.version,1)+1
+		FROM (SELECT id, COALESCE(stock_status,'instock') AS old_status FROM products WHERE id=$1::uuid FOR UPDATE) o
+		WHERE p.id=o.id
+		RETURNING o.old_status, COALESCE(p.stock_status,'instock')
+	`
+
+func updateFeedProductArgs(feed models.Feed, productID string, data map[string]interface{}) []interface{} {
+	description := getStr(data, "description")
+	plain, excerpt := descriptionVariants(description)
+	gross, net := splitPrice(getFloat(data, "price"), feed.VATRate, feed.PricesIncludeVAT)
+	releaseDate, _ := parseReleaseDate(getStr(data, "release_date"))
+	extras := mapFeedExtras(data)
+	return []interface{}{productID, getStr(data, "title"), description, getStr(data, "image_url"), gross, normalizeStockStatus(getStr(data, "stock_status")),
+		net, feed.VATRate, feed.PricesIncludeVAT, feedOriginalPrice(feed, data), plain, excerpt, releaseDate,
+		extras.GiftText, extras.WarrantyMonths, extras.ExtraMessage, getStr(data, "item_group_id")}
+}
+
+// normalizeParams defines how PARAM tags map to attribute rows. Source order is kept
+// and numbered densely into position, a name repeated with different values becomes one
+// row per value, spellings of a repeated name follow its first occurrence, and exact
+// name/value repeats are dropped. The same feed therefore always yields the same rows.
+func normalizeParams(params []map[string]string) (names, values []string, positions []int32) {
+	spelling := map[string]string{}
+	seen := map[string]bool{}
+	for _, param := range params {
+		name, value := strings.TrimSpace(param["name"]), strings.TrimSpace(param["value"])
+		if name == "" || value == "" {
+			continue
+		}
+		key := strings.ToLower(name)
+		if first, ok := spelling[key]; ok {
+			name = first
+		} else {
+			spelling[key] = name
 		}
+		if seen[key+"\x00"+strings.ToLower(value)] {
+			continue
+		}
+		seen[key+"\x00"+strings.ToLower(value)] = true
+		names = append(names, name)
+		values = append(values, value)
+		positions = append(positions, int32(len(positions)))
 	}
+	return names, values, positions
 }
 
-func (h *Handlers) findOrCreateCategoryFeed(ctx context.Context, categoryText string) string {
-	parts := strings.Split(categoryText, " | ")
-	if len(parts) == 1 {
-		parts = strings.Split(categoryText, "|")
+// saveProductAttributes saves PARAM tags to product_attributes table
+// in one transaction, so a failed write keeps the previous attributes
+func (h *Handlers) saveProductAttributes(ctx context.Context, productID string, params []map[string]string) error {
+	if len(params) == 0 {
+		return nil
+	}
+
+	names, values, positions := normalizeParams(params)
+
+	tx, err := h.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
 	}
-	if len(parts) == 1 {
-		parts = strings.Split(categoryText, " > ")
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM product_attributes WHERE product_id = $1::uuid", productID); err != nil {
+		return err
 	}
-	if len(parts) == 1 {
-		parts = strings.Split(categoryText, ">")
+	// canonical columns: name, value, position
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO product_attributes (product_id, name, value, position, created_at)
+		SELECT $1::uuid, a.name, a.value, a.position, NOW()
+		FROM unnest($2::text[], $3::text[], $4::int[]) AS a(name, value, position)
+	`, productID, names, values, positions); err != nil {
+		return err
 	}
+	return tx.Commit(ctx)
+}
 
+// findOrCreateCategoryFeed resolves a feed category path to the ID of its last node.
+// Missing nodes are created when the feed's category_mode allows it, otherwise an
+// unmappedCategoryError is returned.
+func (h *Handlers) findOrCreateCategoryFeed(ctx context.Context, feed models.Feed, categoryText string) (string, error) {
 	var parentID *string
 	var lastID string
+	var path []string
 
-	for _, part := range parts {
+	for _, part := range splitCategoryPath(categoryText) {
 		name := strings.TrimSpace(part)
 		if name == "" {
 			continue
 		}
 		slug := makeSlug(name)
+		path = append(path, name)
 
+		// a category merged during review resolves to its merge target
+		parentKey := ""
+		if parentID != nil {
+			parentKey = *parentID
+		}
+		unlock := lockCategoryNode(parentKey, slug)
 		var catID string
 		if parentID != nil {
-			h.db.Pool.QueryRow(ctx, "SELECT id FROM categories WHERE slug = $1 AND parent_id = $2::uuid", slug, *parentID).Scan(&catID)
+			h.db.Pool.QueryRow(ctx, "SELECT COALESCE(merged_into_id, id) FROM categories WHERE slug = $1 AND parent_id = $2::uuid", slug, *parentID).Scan(&catID)
 		} else {
-			h.db.Pool.QueryRow(ctx, "SELECT id FROM categories WHERE slug = $1 AND parent_id IS NULL", slug).Scan(&catID)
+			h.db.Pool.QueryRow(ctx, "SELECT COALESCE(merged_into_id, id) FROM categories WHERE slug = $1 AND parent_id IS NULL", slug).Scan(&catID)
 		}
 
 		if catID == "" {
+			sourcePath := strings.Join(path, " > ")
+			if feed.CategoryMode == "skip" || feed.CategoryMode == "fail" {
+				unlock()
+				return "", unmappedCategoryError{sourcePath}
+			}
 			catID = uuid.New().String()
-			if parentID != nil {
-				h.db.Pool.Exec(ctx, "INSERT INTO categories (id, parent_id, name, slug, is_active, created_at, updated_at) VALUES ($1::uuid, $2::uuid, $3, $4, true, NOW(), NOW())", catID, *parentID, name, slug)
-			} else {
-				h.db.Pool.Exec(ctx, "INSERT INTO categories (id, name, slug, is_active, created_at, updated_at) VALUES ($1::uuid, $2, $3, true, NOW(), NOW())", catID, name, slug)
+			var runID interface{} = nil
+			if id := importRunID(feed.ID); id != "" {
+				runID = id
+			}
+			_, err := h.db.Pool.Exec(ctx, `
+				INSERT INTO categories (id, parent_id, name, slug, is_active, source, created_from_feed, review_status, source_path, created_by_feed_id, created_by_run_id, created_at, updated_at)
+				VALUES ($1::uuid, $2::uuid, $3, $4, false, 'import', true, 'pending', $5, $6::uuid, $7::uuid, NOW(), NOW())
+			`, catID, parentID, name, slug, sourcePath, feed.ID, runID)
+			if err == nil {
+				recordImportedCategory(feed.ID, ImportedCategory{ID: catID, Name: name, SourcePath: sourcePath})
 			}
 		}
+		unlock()
 
 		lastID = catID
 		parentID = &catID
 	}
 
-	return lastID
+	return lastID, nil
 }
 
-func (h *Handlers) syncFeedProductsToES(ctx context.Context, feedID string) {
+// syncFeedProductsToES queues the feed's products for the ES sync queue and writes
+// them right away; the error tells that search is behind. Failed writes stay queued
+// and are retried by the sync worker.
+func (h *Handlers) syncFeedProductsToES(ctx context.Context, feedID string) error {
 	if h.es == nil {
-		return
+		return nil
 	}
 
-	rows, _ := h.db.Pool.Query(ctx, `
-		SELECT p.id, p.title, p.slug, COALESCE(p.description,''), COALESCE(p.short_description,''),
-		       COALESCE(p.ean,''), COALESCE(p.sku,''), COALESCE(p.brand,''),
-		       COALESCE(p.category_id::text,''), COALESCE(c.name,''), COALESCE(c.slug,''),
-		       COALESCE(p.image_url,''), p.price_min, p.price_max, COALESCE(p.stock_status,'instock'),
-		       p.is_active, COALESCE(p.is_featured,false), p.created_at
-		FROM products p LEFT JOIN categories c ON p.category_id=c.id
-		WHERE p.feed_id=$1::uuid
-	`, feedID)
-	if rows == nil {
-		return
+	if err := h.enqueueESSync(ctx, "index", "SELECT id FROM products WHERE feed_id = $2::uuid", feedID); err != nil {
+		return err
 	}
-	defer rows.Close()
-
-	var products []elasticsearch.Product
-	for rows.Next() {
-		var p elasticsearch.Product
-		var createdAt time.Time
-		rows.Scan(&p.ID, &p.Title, &p.Slug, &p.Description, &p.ShortDescription,
-			&p.EAN, &p.SKU, &p.Brand, &p.CategoryID, &p.CategoryName, &p.CategorySlug,
-			&p.ImageURL, &p.PriceMin, &p.PriceMax, &p.StockStatus, &p.IsActive, &p.IsFeatured, &createdAt)
-		p.CreatedAt = createdAt.Format(time.RFC3339)
-		products = append(products, p)
-	}
-
-	if len(products) > 0 {
-		h.es.BulkIndex(products)
+	written, failed, err := h.processESSync(ctx, newWhere().add("q.product_id IN (SELECT id FROM products WHERE feed_id = ?::uuid)", feedID))
+	if written > 0 {
 		h.es.Refresh()
+		h.searchCache.bumpGeneration()
 	}
+	if err == nil && failed > 0 {
+		err = fmt.Errorf("%d products not indexed, queued for retry", failed)
+	}
+	return err
+}
+
+// feedFieldSources lists the source fields tried, in order, for each import field
+// the field_mapping does not cover
+var feedFieldSources = map[string][]string{
+	"title":             {"PRODUCTNAME", "PRODUCT", "NAME", "NAZOV", "TITLE", "title", "name", "product_name"},
+	"description":       {"DESCRIPTION", "POPIS", "DESC", "description", "long_description"},
+	"short_description": {"SHORT_DESCRIPTION", "SHORT_DESC", "KRATKY_POPIS"},
+	"price":             {"PRICE_VAT", "PRICE", "CENA", "price", "price_vat", "cena_s_dph"},
+	"original_price":    {"PRICE_BEFORE", "PRICE_BEFORE_VAT", "ORIGINAL_PRICE", "OLD_PRICE", "original_price", "old_price"},
+	"ean":               {"EAN", "EAN13", "GTIN", "BARCODE", "ean", "gtin", "barcode"},
+	"sku":               {"SKU", "ITEM_ID", "PRODUCTNO", "KOD", "sku", "item_id", "product_id", "PRODUCT_ID", "id"},
+	"brand":             {"MANUFACTURER", "BRAND", "VYROBCE", "ZNACKA", "brand", "manufacturer", "znacka"},
+	"image_url":         {"IMGURL", "IMG_URL", "IMAGE", "OBRAZOK", "image_url", "imgurl", "image", "img", "image_link"},
+	"affiliate_url":     {"URL", "ITEM_URL", "PRODUCT_URL", "url", "product_url", "link"},
+	"category":          {"CATEGORYTEXT", "CATEGORY", "KATEGORIA", "category", "kategorie", "category_text", "product_type"},
+	"stock_status":      {"STOCK_STATUS", "AVAILABILITY", "stock_status", "availability", "in_stock"},
+	"release_date":      {"RELEASE_DATE", "DATUM_VYDANIA", "PREORDER_DATE", "release_date", "availability_date"},
+	"gift_text":         {"GIFT", "DARCEK", "gift", "gift_text"},
+	"warranty":          {"EXTENDED_WARRANTY_VAL", "EXTENDED_WARRANTY", "WARRANTY", "ZARUKA", "warranty"},
+	"extra_message":     {"EXTRA_MESSAGE", "extra_message", "promo_message"},
+	"item_group_id":     {"ITEMGROUP_ID", "ITEM_GROUP_ID", "item_group_id", "GROUP_ID"},
 }
 
 func mapFields(item map[string]interface{}, mapping map[string]string) map[string]interface{} {
 	result := make(map[string]interface{})
 
 	for sourceField, targetField := range mapping {
-		if targetField != "" && targetField != "--" && targetField != "-- Ignorovat --" {
+		if targetField != "" && targetField != "--" && targetField != "-- Ignorovat --" && !strings.HasPrefix(targetField, "media:") {
 			if val, ok := item[sourceField]; ok && val != nil && val != "" {
 				result[targetField] = val
 			}
 		}
 	}
 
-	autoMap := map[string][]string{
-		"title":             {"PRODUCTNAME", "PRODUCT", "NAME", "NAZOV", "TITLE", "title", "name", "product_name"},
-		"description":       {"DESCRIPTION", "POPIS", "DESC", "description", "long_description"},
-		"short_description": {"SHORT_DESCRIPTION", "SHORT_DESC", "KRATKY_POPIS"},
-		"price":             {"PRICE_VAT", "PRICE", "CENA", "price", "price_vat", "cena_s_dph"},
-		"ean":               {"EAN", "EAN13", "GTIN", "BARCODE", "ean", "gtin", "barcode"},
-		"sku":               {"SKU", "ITEM_ID", "PRODUCTNO", "KOD", "sku", "item_id", "product_id", "PRODUCT_ID"},
-		"brand":             {"MANUFACTURER", "BRAND", "VYROBCE", "ZNACKA", "brand", "manufacturer", "znacka"},
-		"image_url":         {"IMGURL", "IMG_URL", "IMAGE", "OBRAZOK", "image_url", "imgurl", "image", "img"},
-		"affiliate_url":     {"URL", "ITEM_URL", "PRODUCT_URL", "url", "product_url", "link"},
-		"category":          {"CATEGORYTEXT", "CATEGORY", "KATEGORIA", "category", "kategorie", "category_text"},
-	}
-
-	for target, sources := range autoMap {
+	for target, sources := range feedFieldSources {
 		if result[target] == nil || result[target] == "" {
 			for _, src := range sources {
 				if val, ok := item[src]; ok && val != nil && val != "" {
@@ -744,122 +1709,8 @@ func getFloat(m map[string]interface{}, key string) float64 {
 	return 0
 }
 
-// ========== XML PARSING WITH PARAM SUPPORT ==========
-
-// parseFullXMLWithParams parses XML and extracts PARAM tags
-func parseFullXMLWithParams(data []byte, itemPath string) []map[string]interface{} {
-	if itemPath == "" {
-		itemPath = "SHOPITEM"
-	}
-
-	var items []map[string]interface{}
-	content := string(data)
-
-	pattern := fmt.Sprintf(`(?s)<%s[^>]*>(.*?)</%s>`, itemPath, itemPath)
-	re := regexp.MustCompile(pattern)
-	matches := re.FindAllStringSubmatch(content, -1)
-
-	for _, match := range matches {
-		if len(match) > 1 {
-			item := parseXMLItemWithParams(match[1])
-			if len(item) > 0 {
-				items = append(items, item)
-			}
-		}
-	}
-
-	return items
-}
-
-// parseXMLItemWithParams extracts fields AND PARAM tags from XML item
-func parseXMLItemWithParams(xmlStr string) map[string]interface{} {
-	result := make(map[string]interface{})
-
-	tags := []string{
-		"PRODUCTNAME", "PRODUCT", "DESCRIPTION", "PRICE_VAT", "PRICE",
-		"EAN", "ITEM_ID", "SKU", "MANUFACTURER", "BRAND",
-		"IMGURL", "URL", "CATEGORYTEXT", "CATEGORY",
-		"DELIVERY_DATE", "ITEM_TYPE", "PRODUCT_ID", "NAME",
-		"SHORT_DESCRIPTION", "PRODUCTNO",
-	}
-
-	for _, tag := range tags {
-		value := extractXMLTag(xmlStr, tag)
-		if value != "" {
-			result[tag] = value
-		}
-	}
-
-	// Extract PARAM tags - THIS IS THE KEY PART!
-	params := extractParams(xmlStr)
-	if len(params) > 0 {
-		result["_params"] = params
-	}
-
-	return result
-}
-
-// extractXMLTag extracts value from XML tag (handles CDATA)
-func extractXMLTag(xmlStr, tag string) string {
-	// Try CDATA first
-	cdataPattern := fmt.Sprintf(`<%s[^>]*><!\[CDATA\[(.*?)\]\]></%s>`, tag, tag)
-	re := regexp.MustCompile(cdataPattern)
-	match := re.FindStringSubmatch(xmlStr)
-	if len(match) > 1 {
-		return strings.TrimSpace(match[1])
-	}
-
-	// Try regular content
-	pattern := fmt.Sprintf(`<%s[^>]*>([^<]*)</%s>`, tag, tag)
-	re = regexp.MustCompile(pattern)
-	match = re.FindStringSubmatch(xmlStr)
-	if len(match) > 1 {
-		return strings.TrimSpace(match[1])
-	}
-
-	return ""
-}
-
-// extractParams extracts all PARAM tags from XML
-func extractParams(xmlStr string) []map[string]string {
-	var params []map[string]string
-
-	// Pattern for PARAM blocks
-	paramPattern := `(?s)<PARAM>(.*?)</PARAM>`
-	re := regexp.MustCompile(paramPattern)
-	matches := re.FindAllStringSubmatch(xmlStr, -1)
-
-	for _, match := range matches {
-		if len(match) > 1 {
-			paramContent := match[1]
-
-			// Extract PARAM_NAME
-			name := extractXMLTag(paramContent, "PARAM_NAME")
-			if name == "" {
-				name = extractXMLTag(paramContent, "NAME")
-			}
-
-			// Extract VAL (value)
-			value := extractXMLTag(paramContent, "VAL")
-			if value == "" {
-				value = extractXMLTag(paramContent, "VALUE")
-			}
-
-			if name != "" && value != "" {
-				params = append(params, map[string]string{
-					"name":  name,
-					"value": value,
-				})
-			}
-		}
-	}
-
-	return params
-}
-
-// parseXMLPreviewWithAttributes parses XML for preview including attributes stats
-func parseXMLPreviewWithAttributes(data []byte, itemPath string) FeedPreview {
-	items := parseFullXMLWithParams(data, itemPath)
+// previewFromItems builds the preview payload including attribute and category stats
+func previewFromItems(items []map[string]interface{}) FeedPreview {
 	totalItems := len(items)
 
 	// Collect attribute statistics
@@ -877,10 +1728,11 @@ func parseXMLPreviewWithAttributes(data []byte, itemPath string) FeedPreview {
 		}
 
 		// Count categories
-		if cat, ok := item["CATEGORYTEXT"].(string); ok && cat != "" {
-			catCounts[cat]++
-		} else if cat, ok := item["CATEGORY"].(string); ok && cat != "" {
-			catCounts[cat]++
+		for _, key := range []string{"CATEGORYTEXT", "CATEGORY", "category", "product_type"} {
+			if cat, ok := item[key].(string); ok && cat != "" {
+				catCounts[cat]++
+				break
+			}
 		}
 	}
 
@@ -932,7 +1784,7 @@ func parseXMLPreviewWithAttributes(data []byte, itemPath string) FeedPreview {
 	fieldsMap := make(map[string]bool)
 	for _, item := range sampleItems {
 		for k := range item {
-			if k != "_params" {
+			if k != "_params" && k != "_multi" {
 				fieldsMap[k] = true
 			}
 		}
@@ -965,120 +1817,21 @@ func parseXMLPreviewWithAttributes(data []byte, itemPath string) FeedPreview {
 	}
 }
 
-func parseJSONPreview(data []byte) FeedPreview {
-	items := parseFullJSON(data)
-	totalItems := len(items)
-	if len(items) > 5 {
-		items = items[:5]
-	}
-	fields := []string{}
-	if len(items) > 0 {
-		for k := range items[0] {
-			fields = append(fields, k)
-		}
-	}
-	if items == nil {
-		items = []map[string]interface{}{}
-	}
-	return FeedPreview{Fields: fields, Sample: items, TotalItems: totalItems}
-}
-
-func parseCSVPreview(data []byte) FeedPreview {
-	items := parseFullCSV(data)
-	totalItems := len(items)
-	if len(items) > 5 {
-		items = items[:5]
-	}
-	fields := []string{}
-	if len(items) > 0 {
-		for k := range items[0] {
-			fields = append(fields, k)
-		}
-	}
-	if items == nil {
-		items = []map[string]interface{}{}
-	}
-	return FeedPreview{Fields: fields, Sample: items, TotalItems: totalItems}
-}
-
-func parseFullJSON(data []byte) []map[string]interface{} {
-	var items []map[string]interface{}
-	var jsonData interface{}
-	if err := json.Unmarshal(data, &jsonData); err != nil {
-		return items
-	}
-	switch v := jsonData.(type) {
-	case []interface{}:
-		for _, item := range v {
-			if m, ok := item.(map[string]interface{}); ok {
-				items = append(items, m)
-			}
-		}
-	case map[string]interface{}:
-		for _, key := range []string{"products", "items", "data", "results", "offers"} {
-			if arr, ok := v[key].([]interface{}); ok {
-				for _, item := range arr {
-					if m, ok := item.(map[string]interface{}); ok {
-						items = append(items, m)
-					}
-				}
-				break
-			}
-		}
-	}
-	return items
-}
-
-func parseFullCSV(data []byte) []map[string]interface{} {
-	var items []map[string]interface{}
-
-	lines := strings.Split(string(data), "\n")
-	if len(lines) == 0 {
-		return items
-	}
-
-	firstLine := lines[0]
-	delimiter := ';'
-	if strings.Count(firstLine, ",") > strings.Count(firstLine, ";") {
-		delimiter = ','
-	}
-	if strings.Count(firstLine, "\t") > strings.Count(firstLine, string(delimiter)) {
-		delimiter = '\t'
-	}
-
-	reader := csv.NewReader(bytes.NewReader(data))
-	reader.Comma = delimiter
-	reader.LazyQuotes = true
-	reader.FieldsPerRecord = -1
-
-	header, err := reader.Read()
-	if err != nil {
-		return items
-	}
-
-	for {
-		row, err := reader.Read()
-		if err != nil {
-			break
-		}
-		item := make(map[string]interface{})
-		for j, val := range row {
-			if j < len(header) {
-				item[header[j]] = strings.TrimSpace(val)
-			}
-		}
-		items = append(items, item)
-	}
-	return items
-}
-
 func (h *Handlers) GetImportProgress(c *fiber.Ctx) error {
 	feedID := c.Params("id")
 	progressMutex.RLock()
 	progress, ok := importProgress[feedID]
+	var snapshot ImportProgress
+	if ok {
+		snapshot = progress.snapshot()
+	}
 	progressMutex.RUnlock()
 	if !ok {
+		// another instance, or this one before a restart, may have run it
+		if persisted, found := h.lastImportProgress(context.Background(), feedID); found {
+			return c.JSON(fiber.Map{"success": true, "data": persisted})
+		}
 		return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"status": "idle"}})
 	}
-	return c.JSON(fiber.Map{"success": true, "data": progress})
+	return c.JSON(fiber.Map{"success": true, "data": snapshot})
 }
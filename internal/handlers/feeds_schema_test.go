@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+
+	"megabuy-go/internal/models"
+)
+
+// TestFeedsCanonicalMigration applies migration 015 to a feeds table in the layout of
+// the older feed implementation, in a schema of its own
+func TestFeedsCanonicalMigration(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	schema := fmt.Sprintf("legacy_%d", time.Now().UnixNano())
+	if err := adminExec(ctx, env.base, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { adminExec(ctx, env.base, "DROP SCHEMA "+schema+" CASCADE") })
+
+	dbURL, err := withSearchPath(env.base, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := pgx.Connect(ctx, dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	lastImport := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE vendors (id UUID PRIMARY KEY DEFAULT gen_random_uuid());
+		CREATE TABLE feeds (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			name VARCHAR(255) NOT NULL,
+			url TEXT NOT NULL,
+			feed_type VARCHAR(20) NOT NULL,
+			last_import TIMESTAMP NOT NULL,
+			import_count INTEGER NOT NULL,
+			category_mapping JSONB NOT NULL
+		);
+		CREATE TABLE products (id UUID PRIMARY KEY DEFAULT gen_random_uuid(), feed_id UUID);
+	`); err != nil {
+		t.Fatal(err)
+	}
+	var heurekaID, blankID string
+	if err := conn.QueryRow(ctx, `
+		INSERT INTO feeds (name, url, feed_type, last_import, import_count, category_mapping)
+		VALUES ('Heureka', 'https://shop.example.com/heureka.xml', 'HEUREKA-XML', $1, 12, '{"Kávovary":"kavovary"}')
+		RETURNING id
+	`, lastImport).Scan(&heurekaID); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.QueryRow(ctx, `
+		INSERT INTO feeds (name, url, feed_type, last_import, import_count, category_mapping)
+		VALUES ('Blank', 'https://shop.example.com/feed', '', $1, 0, '{}')
+		RETURNING id
+	`, lastImport).Scan(&blankID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(ctx, "INSERT INTO products (feed_id) VALUES ($1), ($1), ($1)", heurekaID); err != nil {
+		t.Fatal(err)
+	}
+
+	migration, err := os.ReadFile("../../migrations/015_feeds_canonical.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// run twice: a deployment reapplies every migration
+	for i := 0; i < 2; i++ {
+		if _, err := conn.Exec(ctx, string(migration)); err != nil {
+			t.Fatalf("migration 015, run %d: %v", i+1, err)
+		}
+	}
+
+	tests := []struct {
+		id, wantType string
+		wantCount    int
+	}{
+		{heurekaID, "heureka-xml", 3},
+		{blankID, "xml", 0},
+	}
+	for _, tt := range tests {
+		var typ, schedule, status string
+		var lastRun *time.Time
+		var count int
+		var active bool
+		if err := conn.QueryRow(ctx, `
+			SELECT type, schedule, last_status, last_run, product_count, is_active FROM feeds WHERE id = $1
+		`, tt.id).Scan(&typ, &schedule, &status, &lastRun, &count, &active); err != nil {
+			t.Fatal(err)
+		}
+		if typ != tt.wantType || schedule != "daily" || status != "idle" || count != tt.wantCount || !active {
+			t.Errorf("feed %s: type %q, schedule %q, status %q, %d products, active %v", tt.id, typ, schedule, status, count, active)
+		}
+		if lastRun == nil || !lastRun.Equal(lastImport) {
+			t.Errorf("feed %s: last_run %v, want the legacy last_import %v", tt.id, lastRun, lastImport)
+		}
+	}
+
+	// the legacy columns no longer block inserts of canonical rows
+	if _, err := conn.Exec(ctx, "INSERT INTO feeds (name, url, type) VALUES ('New', 'https://shop.example.com/new.csv', 'csv')"); err != nil {
+		t.Errorf("inserting a canonical feed: %v", err)
+	}
+	var mapping string
+	conn.QueryRow(ctx, "SELECT category_mapping::text FROM feeds WHERE id = $1", heurekaID).Scan(&mapping)
+	if mapping != `{"Kávovary": "kavovary"}` {
+		t.Errorf("legacy category_mapping %s was not kept", mapping)
+	}
+}
+
+// TestGetFeedsDefaultsNullColumns lists feeds whose optional columns are NULL, as rows
+// carried over from the legacy layout are
+func TestGetFeedsDefaultsNullColumns(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	var id string
+	if err := env.db.Pool.QueryRow(ctx, `
+		INSERT INTO feeds (name, url, type, schedule, is_active, xml_item_path, field_mapping, last_status, product_count,
+		                   prices_include_vat, vat_rate, attribute_blacklist, import_mode)
+		VALUES ('Legacy', 'https://shop.example.com/legacy.xml', NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL)
+		RETURNING id::text
+	`).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { env.db.Pool.Exec(ctx, "DELETE FROM feeds WHERE id = $1::uuid", id) })
+
+	app := fiber.New()
+	app.Get("/admin/feeds", env.h.GetFeeds)
+	status, resp := callJSON(t, app, "GET", "/admin/feeds", nil)
+	if status != 200 || !resp.Success {
+		t.Fatalf("GET /admin/feeds: %d %s", status, resp.Error)
+	}
+	var feeds []models.Feed
+	if err := json.Unmarshal(resp.Data, &feeds); err != nil {
+		t.Fatal(err)
+	}
+	var got *models.Feed
+	for i := range feeds {
+		if feeds[i].ID == id {
+			got = &feeds[i]
+		}
+	}
+	if got == nil {
+		t.Fatalf("feed %s with NULL columns is missing from the %d listed", id, len(feeds))
+	}
+	if got.Type != "xml" || got.Schedule != "daily" || !got.IsActive || got.XMLItemPath != "SHOPITEM" ||
+		got.LastStatus != "idle" || got.ProductCount != 0 || !got.PricesIncludeVAT || got.VATRate != 20 || got.ImportMode != "live" {
+		t.Errorf("defaults not applied: %+v", *got)
+	}
+	if got.AttributeBlacklist == nil {
+		t.Error("attribute_blacklist is null, want []")
+	}
+}
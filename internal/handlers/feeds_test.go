@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/testutil"
+)
+
+func previewFeed(t *testing.T, spec testutil.FeedSpec, limit int) FeedPreview {
+	t.Helper()
+	srv := testutil.ServeFeed(spec)
+	defer srv.Close()
+
+	app := fiber.New()
+	app.Post("/preview", (&Handlers{}).PreviewFeed)
+	body, _ := json.Marshal(fiber.Map{"url": testutil.FeedURL(srv), "limit": limit})
+	req := httptest.NewRequest("POST", "/preview", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Success bool        `json:"success"`
+		Error   string      `json:"error"`
+		Data    FeedPreview `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || !out.Success {
+		t.Fatalf("preview failed: %s, %v", out.Error, err)
+	}
+	return out.Data
+}
+
+func TestPreviewFeedSmall(t *testing.T) {
+	p := previewFeed(t, testutil.FeedSpec{Items: 120, Seed: 2}, 50)
+	if p.DetectedType != "heureka-xml" || p.SampleSize != 50 {
+		t.Errorf("type %s, sample of %d items, want heureka-xml with 50", p.DetectedType, p.SampleSize)
+	}
+	if p.TotalItems != 120 || p.TotalAccuracy != "exact" {
+		t.Errorf("total %d (%s), want exactly 120", p.TotalItems, p.TotalAccuracy)
+	}
+}
+
+func TestPreviewFeedReadsOnlyTheHead(t *testing.T) {
+	spec := testutil.FeedSpec{Items: 20000, Seed: 2}
+	p := previewFeed(t, spec, 50)
+	if p.SampleSize != 50 {
+		t.Errorf("sample of %d items, want 50", p.SampleSize)
+	}
+	if p.DownloadedBytes > previewCountBudget+1 {
+		t.Errorf("read %d bytes, want at most the %d byte head", p.DownloadedBytes, previewCountBudget)
+	}
+	if p.TotalAccuracy != "estimated" || p.TotalItems < 18000 || p.TotalItems > 22000 {
+		t.Errorf("total %d (%s), want an estimate near 20000", p.TotalItems, p.TotalAccuracy)
+	}
+}
+
+func TestPreviewFeedLargeItems(t *testing.T) {
+	// 50 items of ~100 KB do not fit the head; the sample streams on past it
+	p := previewFeed(t, testutil.FeedSpec{Items: 60, DescriptionWords: 12000, Seed: 2}, 50)
+	if p.SampleSize != 50 {
+		t.Errorf("sample of %d items, want 50", p.SampleSize)
+	}
+	if p.DownloadedBytes <= previewCountBudget {
+		t.Errorf("read %d bytes, want the sample to continue past the head", p.DownloadedBytes)
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== SPARSE FIELDSETS ==========
+
+// jsonFieldNames lists the JSON names of a struct type's exported fields
+func jsonFieldNames(t reflect.Type) map[string]int {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	names := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names[name] = i
+	}
+	return names
+}
+
+// fieldsParam validates ?fields= against the JSON names of model's type.
+// It returns nil when the parameter is absent, meaning all fields.
+func fieldsParam(c *fiber.Ctx, model interface{}) ([]string, string) {
+	fields := splitList(c.Query("fields"))
+	if len(fields) == 0 {
+		return nil, ""
+	}
+	allowed := jsonFieldNames(reflect.TypeOf(model))
+	for _, f := range fields {
+		if _, ok := allowed[f]; !ok {
+			return nil, "Unknown field: " + f
+		}
+	}
+	return fields, ""
+}
+
+func invalidFields(c *fiber.Ctx, msg string) error {
+	return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+}
+
+// project returns v (a struct, or a slice of structs) restricted to fields.
+// With no fields v is returned unchanged.
+func project(v interface{}, fields []string) interface{} {
+	if fields == nil {
+		return v
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice {
+		out := make([]map[string]interface{}, rv.Len())
+		for i := range out {
+			out[i] = projectStruct(rv.Index(i), fields)
+		}
+		return out
+	}
+	return projectStruct(rv, fields)
+}
+
+func projectStruct(rv reflect.Value, fields []string) map[string]interface{} {
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	index := jsonFieldNames(rv.Type())
+	out := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if i, ok := index[f]; ok {
+			out[f] = rv.Field(i).Interface()
+		}
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+)
+
+// splitList parses a comma-separated query value, dropping blanks and duplicates
+func splitList(s string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" || seen[part] {
+			continue
+		}
+		seen[part] = true
+		out = append(out, part)
+	}
+	return out
+}
+
+// resolveCategorySubtrees expands category slugs to the ids of their whole subtrees.
+// Unknown slugs are reported as warnings rather than failing the request.
+func (h *Handlers) resolveCategorySubtrees(ctx context.Context, slugs []string) ([]string, []string) {
+	if len(slugs) == 0 {
+		return nil, nil
+	}
+	rows, err := h.db.Pool.Query(ctx, `
+		WITH RECURSIVE subcats AS (
+			SELECT id, slug AS root FROM categories WHERE slug = ANY($1)
+			UNION ALL
+			SELECT c.id, s.root FROM categories c JOIN subcats s ON c.parent_id = s.id
+		)
+		SELECT DISTINCT id::text, root FROM subcats
+	`, slugs)
+	if err != nil {
+		return nil, []string{"category filter ignored: " + err.Error()}
+	}
+	defer rows.Close()
+
+	var ids []string
+	found := make(map[string]bool)
+	for rows.Next() {
+		var id, root string
+		rows.Scan(&id, &root)
+		ids = append(ids, id)
+		found[root] = true
+	}
+
+	var warnings []string
+	for _, slug := range slugs {
+		if !found[slug] {
+			warnings = append(warnings, "unknown category ignored: "+slug)
+		}
+	}
+	return ids, warnings
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,576 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/graph-gophers/graphql-go"
+
+	"megabuy-go/internal/dataloader"
+	"megabuy-go/internal/elasticsearch"
+	"megabuy-go/internal/models"
+	"megabuy-go/internal/money"
+)
+
+// ========== GRAPHQL SCHEMA ==========
+
+const (
+	graphqlMaxDepth = 10
+	// graphqlMaxQueryLength bounds the work of parsing and validating a query
+	graphqlMaxQueryLength = 16 * 1024
+	// graphqlMaxCost bounds the root fields of one request, each of which runs its
+	// own database or search query; a product listing costs graphqlListingCost.
+	// Nested fields go through batching loaders and are not charged.
+	graphqlMaxCost     = 100
+	graphqlListingCost = 10
+	// graphqlMaxParallelism lets every item of a full page resolve at once, so
+	// their loader lookups land in one batch
+	graphqlMaxParallelism = maxPageLimit
+)
+
+// graphqlSchema is the read-only storefront schema served on /graphql
+const graphqlSchema = `schema {
+  query: Query
+}
+
+type Query {
+  """Product listing; search goes through Elasticsearch, everything else through the database"""
+  products(search: String, category: [String!], brand: [String!], min_price: Float, max_price: Float, in_stock: Boolean, sort: String, page: Int = 1, limit: Int = 20): ProductPage!
+  product(slug: String!): Product
+  """Top-level categories; descend with children"""
+  categories: [Category!]!
+  category(slug: String!): Category
+  """Brands with active products, most products first"""
+  brands(limit: Int = 100): [Brand!]!
+}
+
+type Brand {
+  name: String!
+  slug: String!
+  product_count: Int!
+  logo_url: String
+}
+
+type Category {
+  id: ID!
+  name: String!
+  slug: String!
+  icon: String
+  icon_url: String
+  product_count: Int!
+  parent: Category
+  children: [Category!]!
+}
+
+type FacetValue {
+  value: String!
+  count: Int!
+}
+
+type Facets {
+  brands: [FacetValue!]!
+  """Search only: category IDs of the hits"""
+  categories: [FacetValue!]!
+  """Search only"""
+  price_ranges: [FacetValue!]!
+  price_min: Float
+  price_max: Float
+}
+
+type Label {
+  slug: String!
+  name: String!
+  color: String
+  priority: Int!
+}
+
+type Product {
+  id: ID!
+  title: String!
+  slug: String!
+  short_description: String
+  """Only filled by the product query"""
+  description: String
+  ean: String
+  image_url: String
+  price_min: Float!
+  price_max: Float!
+  stock_status: String!
+  discount_percent: Int
+  release_date: String
+  brand: Brand
+  category: Category
+  labels: [Label!]!
+}
+
+type ProductPage {
+  items: [Product!]!
+  total: Int!
+  page: Int!
+  limit: Int!
+  total_pages: Int!
+  facets: Facets!
+  warnings: [String!]!
+}
+`
+
+// newGraphQLSchema binds the schema to its resolvers; a mismatch between the two
+// is a programming error and panics at startup
+func (h *Handlers) newGraphQLSchema() *graphql.Schema {
+	return graphql.MustParseSchema(graphqlSchema, &gqlQuery{h: h},
+		graphql.UseFieldResolvers(),
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(graphqlMaxDepth),
+		graphql.MaxQueryLength(graphqlMaxQueryLength),
+		graphql.MaxParallelism(graphqlMaxParallelism),
+	)
+}
+
+// gqlError is a resolver error carrying an extensions code
+type gqlError struct {
+	msg, code string
+}
+
+func (e gqlError) Error() string { return e.msg }
+
+func (e gqlError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+type gqlCostKey struct{}
+
+// withGraphQLCost starts the cost count of one request
+func withGraphQLCost(ctx context.Context) context.Context {
+	return context.WithValue(ctx, gqlCostKey{}, new(atomic.Int64))
+}
+
+// spendGraphQLCost charges a root field to the request; past graphqlMaxCost the
+// field fails instead of running its query
+func spendGraphQLCost(ctx context.Context, cost int64) error {
+	spent, ok := ctx.Value(gqlCostKey{}).(*atomic.Int64)
+	if ok && spent.Add(cost) > graphqlMaxCost {
+		return gqlError{msg: fmt.Sprintf("query cost exceeds the limit of %d", graphqlMaxCost), code: "QUERY_TOO_COMPLEX"}
+	}
+	return nil
+}
+
+// gqlString maps empty strings to null for nullable String fields
+func gqlString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// gqlProduct is the Product type, built from listing rows, search hits and product
+// pages alike; brand and category are resolved through batching loaders
+type gqlProduct struct {
+	ID               graphql.ID
+	Title            string
+	Slug             string
+	ShortDescription *string
+	Description      *string
+	EAN              *string
+	ImageURL         *string
+	PriceMin         money.Price
+	PriceMax         money.Price
+	StockStatus      string
+	DiscountPercent  *int32
+	ReleaseDate      *string
+	h                *Handlers
+	brandName        string
+	categorySlug     string
+}
+
+func (p *gqlProduct) Brand(ctx context.Context) (*gqlBrand, error) {
+	if p.brandName == "" {
+		return nil, nil
+	}
+	v, err := p.h.brandLoader(ctx).Load(p.brandName)
+	if b, ok := v.(brandEntry); ok {
+		return newGQLBrand(b), err
+	}
+	return nil, err
+}
+
+func (p *gqlProduct) Category(ctx context.Context) (*gqlCategory, error) {
+	if p.categorySlug == "" {
+		return nil, nil
+	}
+	v, err := p.h.categoryBySlugLoader(ctx).Load(p.categorySlug)
+	if cat, ok := v.(*models.Category); ok {
+		return newGQLCategory(p.h, cat), err
+	}
+	return nil, err
+}
+
+func (p *gqlProduct) Labels(ctx context.Context) ([]*gqlLabel, error) {
+	v, err := p.h.labelLoader(ctx).Load(string(p.ID))
+	labels, _ := v.([]models.Label)
+	out := make([]*gqlLabel, len(labels))
+	for i, l := range labels {
+		out[i] = &gqlLabel{Slug: l.Slug, Name: l.Name, Color: gqlString(l.Color), Priority: int32(l.Priority)}
+	}
+	return out, err
+}
+
+type gqlLabel struct {
+	Slug     string
+	Name     string
+	Color    *string
+	Priority int32
+}
+
+type gqlCategory struct {
+	ID           graphql.ID
+	Name         string
+	Slug         string
+	Icon         *string
+	IconURL      *string
+	ProductCount int32
+	h            *Handlers
+	parentID     string
+}
+
+func newGQLCategory(h *Handlers, cat *models.Category) *gqlCategory {
+	return &gqlCategory{
+		ID: graphql.ID(cat.ID), Name: cat.Name, Slug: cat.Slug, Icon: gqlString(cat.Icon), IconURL: gqlString(cat.IconURL),
+		ProductCount: int32(cat.ProductCount), h: h, parentID: cat.ParentID,
+	}
+}
+
+func newGQLCategories(h *Handlers, cats []*models.Category) []*gqlCategory {
+	out := make([]*gqlCategory, len(cats))
+	for i, cat := range cats {
+		out[i] = newGQLCategory(h, cat)
+	}
+	return out
+}
+
+func (c *gqlCategory) Parent(ctx context.Context) (*gqlCategory, error) {
+	if c.parentID == "" {
+		return nil, nil
+	}
+	v, err := c.h.categoryByIDLoader(ctx).Load(c.parentID)
+	if cat, ok := v.(*models.Category); ok {
+		return newGQLCategory(c.h, cat), err
+	}
+	return nil, err
+}
+
+func (c *gqlCategory) Children(ctx context.Context) ([]*gqlCategory, error) {
+	v, err := c.h.categoryChildrenLoader(ctx).Load(string(c.ID))
+	children, _ := v.([]*models.Category)
+	return newGQLCategories(c.h, children), err
+}
+
+type gqlBrand struct {
+	Name         string
+	Slug         string
+	ProductCount int32
+	LogoURL      *string
+}
+
+func newGQLBrand(b brandEntry) *gqlBrand {
+	return &gqlBrand{Name: b.Name, Slug: b.Slug, ProductCount: int32(b.ProductCount), LogoURL: gqlString(b.LogoURL)}
+}
+
+type gqlFacetValue struct {
+	Value string
+	Count int32
+}
+
+type gqlFacets struct {
+	Brands      []gqlFacetValue
+	Categories  []gqlFacetValue
+	PriceRanges []gqlFacetValue
+	PriceMin    *money.Price
+	PriceMax    *money.Price
+}
+
+type gqlProductPage struct {
+	Items      []*gqlProduct
+	Total      int32
+	Page       int32
+	Limit      int32
+	TotalPages int32
+	Facets     gqlFacets
+	Warnings   []string
+}
+
+// ========== RESOLVERS ==========
+
+// gqlQuery resolves the Query root
+type gqlQuery struct {
+	h *Handlers
+}
+
+type gqlProductsArgs struct {
+	Search   *string
+	Category *[]string
+	Brand    *[]string
+	MinPrice *float64
+	MaxPrice *float64
+	InStock  *bool
+	Sort     *string
+	Page     graphql.NullInt
+	Limit    graphql.NullInt
+}
+
+func (q *gqlQuery) Products(ctx context.Context, args gqlProductsArgs) (*gqlProductPage, error) {
+	if err := spendGraphQLCost(ctx, graphqlListingCost); err != nil {
+		return nil, err
+	}
+	h := q.h
+	page, limit := max(int(gqlArg(args.Page.Value)), 1), int(gqlArg(args.Limit.Value))
+	if limit < 1 || limit > maxPageLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+	}
+	search, sort, categories, brands := gqlArg(args.Search), gqlArg(args.Sort), gqlArg(args.Category), gqlArg(args.Brand)
+	minPrice, maxPrice, inStock := gqlArg(args.MinPrice), gqlArg(args.MaxPrice), gqlArg(args.InStock)
+
+	result := &gqlProductPage{Page: int32(page), Limit: int32(limit), Items: []*gqlProduct{}, Facets: gqlFacets{
+		Brands: []gqlFacetValue{}, Categories: []gqlFacetValue{}, PriceRanges: []gqlFacetValue{},
+	}}
+	var total int64
+
+	if search != "" {
+		if h.es == nil {
+			return nil, fmt.Errorf("search is not available")
+		}
+		categoryIDs, warnings := h.resolveCategorySubtrees(ctx, categories)
+		if len(categories) > 0 && len(categoryIDs) == 0 {
+			categoryIDs = []string{uuid.Nil.String()}
+		}
+		params := elasticsearch.SearchParams{
+			Query: search, CategoryIDs: categoryIDs, Brands: brands,
+			PriceMin: minPrice, PriceMax: maxPrice, InStock: inStock,
+			Page: page, Limit: limit,
+		}
+		params.Sort = effectiveSearchSort(sort, search, func() string {
+			return h.listingSortDefaults(ctx).forSlugs(categories)
+		})
+		variant, ranking, _ := h.rankingFor("")
+		params.Ranking, params.Variant = &ranking.Ranking, variant
+		found, _, err := h.searchWithCache(ctx, params, false)
+		if err != nil {
+			return nil, err
+		}
+		h.logSearchEvent(search, variant, ranking.Version, found.Total, found.Took)
+		for _, p := range found.Products {
+			result.Items = append(result.Items, &gqlProduct{
+				ID: graphql.ID(p.ID), Title: p.Title, Slug: p.Slug, ShortDescription: gqlString(p.ShortDescription), EAN: gqlString(p.EAN),
+				ImageURL: gqlString(p.ImageURL), PriceMin: p.PriceMin, PriceMax: p.PriceMax, StockStatus: p.StockStatus,
+				DiscountPercent: gqlDiscount(p.DiscountPercent), ReleaseDate: gqlString(p.ReleaseDate),
+				h: h, brandName: p.Brand, categorySlug: p.CategorySlug,
+			})
+		}
+		for key, values := range map[string]*[]gqlFacetValue{
+			"brands": &result.Facets.Brands, "categories": &result.Facets.Categories, "price_ranges": &result.Facets.PriceRanges,
+		} {
+			for _, f := range found.Facets[key] {
+				*values = append(*values, gqlFacetValue{Value: f.Value, Count: int32(f.Count)})
+			}
+		}
+		total, result.Warnings = found.Total, nonNilStrings(warnings)
+	} else {
+		listed := h.listProducts(ctx, productListQuery{
+			Categories: categories, Brands: brands,
+			MinPrice: minPrice, MaxPrice: maxPrice, InStock: inStock,
+			Sort: sort, PriceMode: "gross", Page: page, Limit: limit,
+		})
+		for _, p := range listed.Items {
+			result.Items = append(result.Items, &gqlProduct{
+				ID: graphql.ID(p.ID), Title: p.Title, Slug: p.Slug, ShortDescription: gqlString(p.ShortDescription), ImageURL: gqlString(p.ImageURL),
+				PriceMin: p.PriceMin, PriceMax: p.PriceMax, StockStatus: p.StockStatus, DiscountPercent: gqlDiscount(p.DiscountPercent),
+				ReleaseDate: gqlString(p.ReleaseDate), h: h, brandName: p.Brand, categorySlug: p.CategorySlug,
+			})
+		}
+		if brands, ok := listed.Facets["brands"].([]fiber.Map); ok {
+			for _, b := range brands {
+				name, _ := b["name"].(string)
+				count, _ := b["count"].(int)
+				result.Facets.Brands = append(result.Facets.Brands, gqlFacetValue{Value: name, Count: int32(count)})
+			}
+		}
+		if prices, ok := listed.Facets["price_range"].(fiber.Map); ok {
+			if v, ok := prices["min"].(money.Price); ok {
+				result.Facets.PriceMin = &v
+			}
+			if v, ok := prices["max"].(money.Price); ok {
+				result.Facets.PriceMax = &v
+			}
+		}
+		total, result.Warnings = listed.Total, nonNilStrings(listed.Warnings)
+	}
+	result.Total = int32(total)
+	result.TotalPages = int32(math.Ceil(float64(total) / float64(limit)))
+	return result, nil
+}
+
+// gqlArg reads an optional argument, the zero value when it is null
+func gqlArg[T any](v *T) T {
+	var zero T
+	if v == nil {
+		return zero
+	}
+	return *v
+}
+
+// gqlDiscount maps no discount to null
+func gqlDiscount(percent int) *int32 {
+	if percent == 0 {
+		return nil
+	}
+	v := int32(percent)
+	return &v
+}
+
+func (q *gqlQuery) Product(ctx context.Context, args struct{ Slug string }) (*gqlProduct, error) {
+	if err := spendGraphQLCost(ctx, 1); err != nil {
+		return nil, err
+	}
+	p, _, err := q.h.productBySlug(ctx, args.Slug)
+	if err != nil || !p.IsActive {
+		return nil, nil
+	}
+	return &gqlProduct{
+		ID: graphql.ID(p.ID), Title: p.Title, Slug: p.Slug, ShortDescription: gqlString(p.ShortDescription),
+		Description: gqlString(p.Description), EAN: gqlString(p.EAN), ImageURL: gqlString(p.ImageURL),
+		PriceMin: p.PriceMin, PriceMax: p.PriceMax, StockStatus: p.StockStatus,
+		ReleaseDate: gqlString(models.FormatReleaseDate(p.ReleaseDate)), h: q.h, brandName: p.Brand, categorySlug: p.CategorySlug,
+	}, nil
+}
+
+func (q *gqlQuery) Categories(ctx context.Context) ([]*gqlCategory, error) {
+	if err := spendGraphQLCost(ctx, 1); err != nil {
+		return nil, err
+	}
+	cats, err := q.h.loadCategories(ctx, "parent_id IS NULL")
+	return newGQLCategories(q.h, cats), err
+}
+
+func (q *gqlQuery) Category(ctx context.Context, args struct{ Slug string }) (*gqlCategory, error) {
+	if err := spendGraphQLCost(ctx, 1); err != nil {
+		return nil, err
+	}
+	v, err := q.h.categoryBySlugLoader(ctx).Load(args.Slug)
+	if cat, ok := v.(*models.Category); ok {
+		return newGQLCategory(q.h, cat), err
+	}
+	return nil, err
+}
+
+func (q *gqlQuery) Brands(ctx context.Context, args struct{ Limit graphql.NullInt }) ([]*gqlBrand, error) {
+	if err := spendGraphQLCost(ctx, 1); err != nil {
+		return nil, err
+	}
+	brands, err := q.h.brandIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if limit := args.Limit.Value; limit != nil && *limit >= 0 && int(*limit) < len(brands) {
+		brands = brands[:*limit]
+	}
+	out := make([]*gqlBrand, len(brands))
+	for i, b := range brands {
+		out[i] = newGQLBrand(b)
+	}
+	return out, nil
+}
+
+// ========== LOADERS ==========
+
+// loadCategories reads active categories matching cond, which may reference $1
+func (h *Handlers) loadCategories(ctx context.Context, cond string, args ...interface{}) ([]*models.Category, error) {
+	rows, err := h.db.ReadPool.Query(ctx, `
+		SELECT id::text, COALESCE(parent_id::text,''), name, slug, COALESCE(icon,''), COALESCE(icon_url,''), product_count
+		FROM categories WHERE is_active = true AND `+cond+` ORDER BY sort_order, name
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cats := []*models.Category{}
+	for rows.Next() {
+		cat := &models.Category{}
+		if err := rows.Scan(&cat.ID, &cat.ParentID, &cat.Name, &cat.Slug, &cat.Icon, &cat.IconURL, &cat.ProductCount); err != nil {
+			return nil, err
+		}
+		cats = append(cats, cat)
+	}
+	return cats, rows.Err()
+}
+
+func (h *Handlers) categoryBySlugLoader(ctx context.Context) *dataloader.Loader {
+	return dataloader.For(ctx, "category_by_slug", func(ctx context.Context, slugs []string) (map[string]any, error) {
+		cats, err := h.loadCategories(ctx, "slug = ANY($1)", slugs)
+		found := make(map[string]any, len(cats))
+		for _, cat := range cats {
+			found[cat.Slug] = cat
+		}
+		return found, err
+	})
+}
+
+func (h *Handlers) categoryByIDLoader(ctx context.Context) *dataloader.Loader {
+	return dataloader.For(ctx, "category_by_id", func(ctx context.Context, ids []string) (map[string]any, error) {
+		cats, err := h.loadCategories(ctx, "id = ANY($1::uuid[])", ids)
+		found := make(map[string]any, len(cats))
+		for _, cat := range cats {
+			found[cat.ID] = cat
+		}
+		return found, err
+	})
+}
+
+func (h *Handlers) categoryChildrenLoader(ctx context.Context) *dataloader.Loader {
+	return dataloader.For(ctx, "category_children", func(ctx context.Context, ids []string) (map[string]any, error) {
+		cats, err := h.loadCategories(ctx, "parent_id = ANY($1::uuid[])", ids)
+		children := make(map[string][]*models.Category, len(ids))
+		for _, cat := range cats {
+			children[cat.ParentID] = append(children[cat.ParentID], cat)
+		}
+		found := make(map[string]any, len(ids))
+		for _, id := range ids {
+			found[id] = append([]*models.Category{}, children[id]...)
+		}
+		return found, err
+	})
+}
+
+// brandLoader resolves products.brand spellings to their brand
+func (h *Handlers) brandLoader(ctx context.Context) *dataloader.Loader {
+	return dataloader.For(ctx, "brand", func(ctx context.Context, names []string) (map[string]any, error) {
+		brands, err := h.brandIndex(ctx)
+		if err != nil {
+			return nil, err
+		}
+		bySlug := make(map[string]brandEntry, len(brands))
+		for _, b := range brands {
+			bySlug[b.Slug] = b
+		}
+		found := make(map[string]any, len(names))
+		for _, name := range names {
+			if b, ok := bySlug[makeSlug(name)]; ok {
+				found[name] = b
+			}
+		}
+		return found, nil
+	})
+}
+
+func (h *Handlers) labelLoader(ctx context.Context) *dataloader.Loader {
+	return dataloader.For(ctx, "labels", func(ctx context.Context, ids []string) (map[string]any, error) {
+		labels := h.productLabels(ctx, ids)
+		found := make(map[string]any, len(ids))
+		for _, id := range ids {
+			found[id] = append([]models.Label{}, labels[id]...)
+		}
+		return found, nil
+	})
+}
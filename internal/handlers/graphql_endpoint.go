@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+
+	"megabuy-go/internal/dataloader"
+)
+
+// ========== GRAPHQL ENDPOINT ==========
+
+// maxPersistedQueryCache bounds the in-memory copy of graphql_persisted_queries
+const maxPersistedQueryCache = 1000
+
+// persistedQueries caches hash -> query text in front of the database
+type persistedQueries struct {
+	mu      sync.RWMutex
+	entries map[string]string
+}
+
+func newPersistedQueries() *persistedQueries {
+	return &persistedQueries{entries: make(map[string]string)}
+}
+
+func (pq *persistedQueries) get(hash string) (string, bool) {
+	pq.mu.RLock()
+	defer pq.mu.RUnlock()
+	query, ok := pq.entries[hash]
+	return query, ok
+}
+
+func (pq *persistedQueries) put(hash, query string) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if len(pq.entries) >= maxPersistedQueryCache {
+		// registered queries are few; on overflow start over rather than track recency
+		pq.entries = make(map[string]string)
+	}
+	pq.entries[hash] = query
+}
+
+// graphqlPersistedOnly restricts /graphql to queries registered by an admin
+func graphqlPersistedOnly() bool {
+	return os.Getenv("GRAPHQL_PERSISTED_ONLY") == "true"
+}
+
+func hashQuery(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// graphqlRequest is a GraphQL request with the automatic persisted query extension
+// (extensions.persistedQuery.sha256Hash) used by Apollo clients
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    struct {
+		PersistedQuery *struct {
+			Version    int    `json:"version"`
+			SHA256Hash string `json:"sha256Hash"`
+		} `json:"persistedQuery"`
+	} `json:"extensions"`
+}
+
+// parseGraphQLRequest reads the request from the JSON body of a POST or from the
+// query, operationName, variables and extensions parameters of a GET
+func parseGraphQLRequest(c *fiber.Ctx) (graphqlRequest, string) {
+	var req graphqlRequest
+	if c.Method() == fiber.MethodPost {
+		if err := json.Unmarshal(c.Body(), &req); err != nil {
+			return req, "Invalid JSON body"
+		}
+		return req, ""
+	}
+	req.Query, req.OperationName = c.Query("query"), c.Query("operationName")
+	if v := c.Query("variables"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+			return req, "variables must be a JSON object"
+		}
+	}
+	if v := c.Query("extensions"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Extensions); err != nil {
+			return req, "extensions must be a JSON object"
+		}
+	}
+	return req, ""
+}
+
+// graphqlErrorResponse wraps a request-level error tagged with code
+func graphqlErrorResponse(msg, code string) *graphql.Response {
+	return &graphql.Response{Errors: []*gqlerrors.QueryError{{Message: msg, Extensions: map[string]interface{}{"code": code}}}}
+}
+
+// validateGraphQLDocument checks a query before it is stored; variable values come
+// with each request, so missing ones are not reported
+func (h *Handlers) validateGraphQLDocument(query string) []*gqlerrors.QueryError {
+	var errs []*gqlerrors.QueryError
+	for _, e := range h.graphql.Validate(query) {
+		if e.Rule != "VariablesOfCorrectType" {
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+// persistedQuery returns the text stored under hash, or "" when it is unknown
+func (h *Handlers) persistedQuery(ctx context.Context, hash string) string {
+	if query, ok := h.persisted.get(hash); ok {
+		return query
+	}
+	var query string
+	if err := h.db.ReadPool.QueryRow(ctx, "SELECT query FROM graphql_persisted_queries WHERE hash = $1", hash).Scan(&query); err != nil {
+		return ""
+	}
+	h.persisted.put(hash, query)
+	return query
+}
+
+func (h *Handlers) storePersistedQuery(ctx context.Context, hash, query string) error {
+	_, err := h.db.Pool.Exec(ctx, `
+		INSERT INTO graphql_persisted_queries (hash, query, created_at) VALUES ($1, $2, NOW())
+		ON CONFLICT (hash) DO NOTHING
+	`, hash, query)
+	if err == nil {
+		h.persisted.put(hash, query)
+	}
+	return err
+}
+
+// GraphQL serves read-only queries over products, categories and brands. Requests
+// rejected before execution (parse, validation or limit errors) answer 400.
+func (h *Handlers) GraphQL(c *fiber.Ctx) error {
+	req, msg := parseGraphQLRequest(c)
+	if msg != "" {
+		return c.Status(400).JSON(graphqlErrorResponse(msg, "BAD_REQUEST"))
+	}
+
+	ctx := context.Background()
+	if pq := req.Extensions.PersistedQuery; pq != nil && pq.SHA256Hash != "" {
+		hash := strings.ToLower(pq.SHA256Hash)
+		if stored := h.persistedQuery(ctx, hash); stored != "" {
+			req.Query = stored
+		} else if req.Query == "" {
+			// Apollo clients resend the full query on this code
+			return c.JSON(graphqlErrorResponse("PersistedQueryNotFound", "PERSISTED_QUERY_NOT_FOUND"))
+		} else if graphqlPersistedOnly() {
+			return c.Status(403).JSON(graphqlErrorResponse("Only registered queries are allowed", "PERSISTED_QUERY_NOT_ALLOWED"))
+		} else if hashQuery(req.Query) != hash {
+			return c.Status(400).JSON(graphqlErrorResponse("provided sha256Hash does not match query", "INVALID_PERSISTED_QUERY"))
+		} else if len(req.Query) <= graphqlMaxQueryLength && len(h.validateGraphQLDocument(req.Query)) == 0 {
+			// only valid documents are worth keeping
+			if err := h.storePersistedQuery(ctx, hash, req.Query); err != nil {
+				log.Printf("GraphQL persisted query not stored: %v", err)
+			}
+		}
+	} else if graphqlPersistedOnly() {
+		return c.Status(403).JSON(graphqlErrorResponse("Only registered queries are allowed", "PERSISTED_QUERY_NOT_ALLOWED"))
+	}
+	if req.Query == "" {
+		return c.Status(400).JSON(graphqlErrorResponse("query is required", "BAD_REQUEST"))
+	}
+
+	ctx = withGraphQLCost(dataloader.WithLoaders(ctx))
+	resp := h.graphql.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	if resp.Data == nil {
+		for _, e := range resp.Errors {
+			if e.Extensions == nil {
+				e.Extensions = map[string]interface{}{"code": "GRAPHQL_VALIDATION_FAILED"}
+			}
+		}
+		return c.Status(400).JSON(resp)
+	}
+	return c.JSON(resp)
+}
+
+// GraphQLSchema returns the schema in SDL for client code generators
+func (h *Handlers) GraphQLSchema(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	return c.SendString(graphqlSchema)
+}
+
+// AdminRegisterPersistedQuery registers a query so clients can send its hash alone;
+// with GRAPHQL_PERSISTED_ONLY this is the only way to allow a query
+func (h *Handlers) AdminRegisterPersistedQuery(c *fiber.Ctx) error {
+	var input struct {
+		Query string `json:"query"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if strings.TrimSpace(input.Query) == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "query is required"})
+	}
+	if len(input.Query) > graphqlMaxQueryLength {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": fmt.Sprintf("query is longer than the limit of %d bytes", graphqlMaxQueryLength)})
+	}
+	if errs := h.validateGraphQLDocument(input.Query); len(errs) > 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": errs[0].Message})
+	}
+
+	ctx := context.Background()
+	hash := hashQuery(input.Query)
+	if err := h.storePersistedQuery(ctx, hash, input.Query); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	h.audit(ctx, c, "graphql.persisted_query.register", "graphql_persisted_query", "", fiber.Map{"hash": hash})
+	return c.Status(201).JSON(fiber.Map{"success": true, "data": fiber.Map{"sha256Hash": hash}})
+}
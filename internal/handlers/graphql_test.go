@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type graphqlResult struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func postGraphQL(t *testing.T, app *fiber.App, body any) (int, graphqlResult) {
+	t.Helper()
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/graphql", bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out graphqlResult
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, out
+}
+
+func graphqlApp(h *Handlers) *fiber.App {
+	app := fiber.New()
+	app.Post("/graphql", h.GraphQL)
+	app.Get("/graphql/schema", h.GraphQLSchema)
+	return app
+}
+
+// TestGraphQLRejectsBeforeExecution covers the requests answered 400 without
+// running a resolver, so no database is needed
+func TestGraphQLRejectsBeforeExecution(t *testing.T) {
+	h := &Handlers{}
+	h.graphql = h.newGraphQLSchema()
+	app := graphqlApp(h)
+
+	deep := "{ categories { " + strings.Repeat("children { ", 10) + "id" + strings.Repeat(" }", 10) + " } }"
+	tests := []struct {
+		name string
+		body any
+		code string
+	}{
+		{"syntax error", fiber.Map{"query": "{ products { items { id }"}, "GRAPHQL_VALIDATION_FAILED"},
+		{"unknown field", fiber.Map{"query": "{ products { nope } }"}, "GRAPHQL_VALIDATION_FAILED"},
+		{"too deep", fiber.Map{"query": deep}, "GRAPHQL_VALIDATION_FAILED"},
+		{"too long", fiber.Map{"query": "{ " + strings.Repeat("categories { id } ", graphqlMaxQueryLength/16) + "}"}, "GRAPHQL_VALIDATION_FAILED"},
+		{"missing query", fiber.Map{}, "BAD_REQUEST"},
+	}
+	for _, tt := range tests {
+		status, resp := postGraphQL(t, app, tt.body)
+		if status != 400 || len(resp.Errors) == 0 || resp.Data != nil {
+			t.Errorf("%s: status %d, %+v; want 400 without data", tt.name, status, resp)
+			continue
+		}
+		if code := resp.Errors[0].Extensions["code"]; code != tt.code {
+			t.Errorf("%s: code %v, want %s", tt.name, code, tt.code)
+		}
+	}
+}
+
+func TestGraphQLSchemaEndpoint(t *testing.T) {
+	h := &Handlers{}
+	h.graphql = h.newGraphQLSchema()
+	resp, err := graphqlApp(h).Test(httptest.NewRequest("GET", "/graphql/schema", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sdl bytes.Buffer
+	sdl.ReadFrom(resp.Body)
+	for _, want := range []string{"type Query {", "products(search: String", "total_pages: Int!", "labels: [Label!]!"} {
+		if !strings.Contains(sdl.String(), want) {
+			t.Errorf("schema is missing %q", want)
+		}
+	}
+	if errs := h.validateGraphQLDocument("query ($slug: String!) { product(slug: $slug) { title } }"); len(errs) > 0 {
+		t.Errorf("query with variables does not validate: %v", errs)
+	}
+	if errs := h.validateGraphQLDocument("query ($slug: Int!) { product(slug: $slug) { title } }"); len(errs) == 0 {
+		t.Error("variable of the wrong type validates")
+	}
+}
+
+// TestGraphQLBrands serves brands from a seeded brand index, covering argument
+// defaults and nullable fields without a database
+func TestGraphQLBrands(t *testing.T) {
+	brandsMutex.Lock()
+	saved, savedAt := brandsCache, brandsLoadedAt
+	brandsCache, brandsLoadedAt = []brandEntry{
+		{Slug: "samsung", Name: "Samsung", ProductCount: 12, LogoURL: "/uploads/samsung.png"},
+		{Slug: "lg", Name: "LG", ProductCount: 7},
+		{Slug: "sony", Name: "Sony", ProductCount: 3},
+	}, time.Now()
+	brandsMutex.Unlock()
+	t.Cleanup(func() {
+		brandsMutex.Lock()
+		brandsCache, brandsLoadedAt = saved, savedAt
+		brandsMutex.Unlock()
+	})
+
+	h := &Handlers{}
+	h.graphql = h.newGraphQLSchema()
+	status, resp := postGraphQL(t, graphqlApp(h), fiber.Map{"query": "{ all: brands { name product_count logo_url } top: brands(limit: 2) { slug } }"})
+	if status != 200 || len(resp.Errors) > 0 {
+		t.Fatalf("status %d, errors %+v", status, resp.Errors)
+	}
+	want := `{"all":[{"name":"Samsung","product_count":12,"logo_url":"/uploads/samsung.png"},{"name":"LG","product_count":7,"logo_url":null},{"name":"Sony","product_count":3,"logo_url":null}],"top":[{"slug":"samsung"},{"slug":"lg"}]}`
+	if string(resp.Data) != want {
+		t.Errorf("data = %s\nwant %s", resp.Data, want)
+	}
+}
+
+// TestGraphQLResolvesThroughLoaders lists products of one category and brand and
+// follows brand, category, parent and labels through the batching loaders
+func TestGraphQLResolvesThroughLoaders(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	parentID, _ := env.createTestCategory(t, "Elektronika", "")
+	categoryID, categorySlug := env.createTestCategory(t, "Televízory", parentID)
+	brand := fmt.Sprintf("Značka %s", categorySlug)
+	for _, title := range []string{"Televízor A", "Televízor B", "Televízor C"} {
+		id := env.createTestProduct(t, title, 399.9)
+		if _, err := env.db.Pool.Exec(ctx, "UPDATE products SET category_id = $2::uuid, brand = $3 WHERE id = $1::uuid", id, categoryID, brand); err != nil {
+			t.Fatal(err)
+		}
+	}
+	brandsMutex.Lock()
+	brandsCache = nil
+	brandsMutex.Unlock()
+
+	status, resp := postGraphQL(t, graphqlApp(env.h), fiber.Map{
+		"query": `query ($category: [String!]) {
+			products(category: $category, limit: 10) {
+				total
+				items { title price_min brand { name } category { slug parent { name } } labels { slug } }
+			}
+		}`,
+		"variables": fiber.Map{"category": []string{categorySlug}},
+	})
+	if status != 200 || len(resp.Errors) > 0 {
+		t.Fatalf("status %d, errors %+v", status, resp.Errors)
+	}
+	var data struct {
+		Products struct {
+			Total int
+			Items []struct {
+				Title    string
+				PriceMin json.Number `json:"price_min"`
+				Brand    *struct{ Name string }
+				Category *struct {
+					Slug   string
+					Parent *struct{ Name string }
+				}
+				Labels []struct{ Slug string }
+			}
+		}
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Products.Total != 3 || len(data.Products.Items) != 3 {
+		t.Fatalf("got %d of %d products, want 3", len(data.Products.Items), data.Products.Total)
+	}
+	for _, item := range data.Products.Items {
+		if item.PriceMin != "399.90" || item.Brand == nil || item.Brand.Name != brand ||
+			item.Category == nil || item.Category.Slug != categorySlug || item.Category.Parent == nil || item.Category.Parent.Name != "Elektronika" {
+			t.Errorf("%s: %+v", item.Title, item)
+		}
+		if item.Labels == nil {
+			t.Errorf("%s: labels is null, want a list", item.Title)
+		}
+	}
+}
+
+func TestGraphQLCostLimit(t *testing.T) {
+	env := newTestEnv(t)
+	app := graphqlApp(env.h)
+	aliases := func(n int) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&b, "c%d: category(slug: \"missing\") { id } ", i)
+		}
+		return "{ " + b.String() + "}"
+	}
+
+	if status, resp := postGraphQL(t, app, fiber.Map{"query": aliases(graphqlMaxCost)}); status != 200 || len(resp.Errors) > 0 {
+		t.Errorf("%d root fields: status %d, errors %+v", graphqlMaxCost, status, resp.Errors)
+	}
+	status, resp := postGraphQL(t, app, fiber.Map{"query": aliases(graphqlMaxCost + 1)})
+	if status != 200 || len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != "QUERY_TOO_COMPLEX" {
+		t.Errorf("%d root fields: status %d, errors %+v; want one QUERY_TOO_COMPLEX", graphqlMaxCost+1, status, resp.Errors)
+	}
+}
@@ -2,26 +2,42 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
-	"unicode"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
-	"golang.org/x/text/runes"
-	"golang.org/x/text/transform"
-	"golang.org/x/text/unicode/norm"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/jackc/pgx/v5"
 
 	"megabuy-go/internal/database"
 	"megabuy-go/internal/elasticsearch"
+	"megabuy-go/internal/models"
+	"megabuy-go/internal/money"
+	"megabuy-go/internal/notify"
+	"megabuy-go/internal/settings"
 )
 
 type Handlers struct {
-	db *database.DB
-	es *elasticsearch.Client
+	db     *database.DB
+	es     *elasticsearch.Client
+	sender notify.Sender
+	// esQueue batches index updates for products changed outside imports
+	esQueue *esSyncQueue
+	// searchCache is nil unless SEARCH_CACHE_TTL is set
+	searchCache *searchCache
+	imports     *importCoordinator
+	graphql     *graphql.Schema
+	persisted   *persistedQueries
+	settings    *settings.Store
+	// saveFeedBatch writes a batch of items of a live import; New sets it to
+	// saveFeedProducts and tests wrap it to make chosen writes fail
+	saveFeedBatch func(ctx context.Context, feed models.Feed, jobs []importJob, categories *importCategoryCache) []feedSaveResult
 }
 
 func New(db *database.DB) *Handlers {
@@ -29,62 +45,124 @@ func New(db *database.DB) *Handlers {
 	if es != nil {
 		es.CreateIndex()
 	}
-	return &Handlers{db: db, es: es}
+	h := &Handlers{db: db, es: es, sender: notify.NewFromEnv(), esQueue: newESSyncQueue(), searchCache: newSearchCacheFromEnv(), imports: newImportCoordinatorFromEnv(), persisted: newPersistedQueries(), settings: settings.New(db.Pool)}
+	h.saveFeedBatch = h.saveFeedProducts
+	h.registerSettingChecks()
+	h.graphql = h.newGraphQLSchema()
+	return h
 }
 
-func makeSlug(s string) string {
-	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
-	r, _, _ := transform.String(t, strings.ToLower(s))
-	result := strings.Map(func(c rune) rune {
-		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' {
-			return c
+// ========== SEARCH API (Elasticsearch) ==========
+
+// ReindexProducts writes every product to Elasticsearch and returns how many were indexed
+func (h *Handlers) ReindexProducts(ctx context.Context) (int, error) {
+	if h.es == nil {
+		return 0, fmt.Errorf("elasticsearch not configured")
+	}
+	products, err := h.loadESProducts(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+
+	batchSize := 1000
+	indexed := 0
+	for i := 0; i < len(products); i += batchSize {
+		end := i + batchSize
+		if end > len(products) {
+			end = len(products)
 		}
-		if c == ' ' || c == '-' {
-			return '-'
+		if err := h.es.BulkIndex(products[i:end]); err != nil {
+			return indexed, err
 		}
-		return -1
-	}, r)
-	for strings.Contains(result, "--") {
-		result = strings.ReplaceAll(result, "--", "-")
+		indexed += end - i
 	}
-	return strings.Trim(result, "-")
-}
 
-// ========== SEARCH API (Elasticsearch) ==========
+	h.es.Refresh()
+	h.searchCache.bumpGeneration()
+	return indexed, nil
+}
 
 func (h *Handlers) Search(c *fiber.Ctx) error {
 	if h.es == nil {
 		return c.Status(503).JSON(fiber.Map{"success": false, "error": "Elasticsearch not available"})
 	}
 
+	priceMode, ok := priceModeParam(c)
+	if !ok {
+		return invalidPriceMode(c)
+	}
+	fields, msg := fieldsParam(c, models.ProductCard{})
+	if msg != "" {
+		return invalidFields(c, msg)
+	}
+
+	ctx := context.Background()
+	categoryIDs, warnings := h.resolveCategorySubtrees(ctx, splitList(c.Query("category")))
+	excludeCategoryIDs, excludeWarnings := h.resolveCategorySubtrees(ctx, splitList(c.Query("category_not")))
+	warnings = append(warnings, excludeWarnings...)
+	for _, id := range splitList(c.Query("category_id")) {
+		if isUUID(id) {
+			categoryIDs = append(categoryIDs, id)
+		} else {
+			warnings = append(warnings, "invalid category_id ignored: "+id)
+		}
+	}
+	if c.Query("category") != "" && len(categoryIDs) == 0 {
+		// every requested category was unknown; match nothing rather than everything
+		categoryIDs = []string{uuid.Nil.String()}
+	}
+
 	params := elasticsearch.SearchParams{
-		Query:      c.Query("q"),
-		CategoryID: c.Query("category_id"),
-		Brand:      c.Query("brand"),
+		Query:              c.Query("q"),
+		CategoryIDs:        categoryIDs,
+		Brands:             splitList(c.Query("brand")),
+		ExcludeCategoryIDs: excludeCategoryIDs,
+		ExcludeBrands:      splitList(c.Query("brand_not")),
+		Labels:             splitList(c.Query("label")),
 		PriceMin:   float64(c.QueryInt("price_min", 0)),
 		PriceMax:   float64(c.QueryInt("price_max", 0)),
 		InStock:    c.Query("in_stock") == "true",
-		Sort:       c.Query("sort", "relevance"),
-		Page:       c.QueryInt("page", 1),
-		Limit:      c.QueryInt("limit", 20),
 	}
+	params.Sort = effectiveSearchSort(c.Query("sort"), params.Query, func() string {
+		defaults := h.listingSortDefaults(ctx)
+		if ids := splitList(c.Query("category_id")); len(ids) == 1 && c.Query("category") == "" {
+			sort, _ := defaults.forCategory(ids[0])
+			return sort
+		}
+		return defaults.forSlugs(splitList(c.Query("category")))
+	})
+	params.Page, params.Limit, _ = listPageParams(c, 20)
+	variant, ranking, known := h.rankingFor(c.Query("variant"))
+	if !known {
+		warnings = append(warnings, "unknown variant "+c.Query("variant")+", served "+variant)
+	}
+	params.Ranking, params.Variant = &ranking.Ranking, variant
 
-	result, err := h.es.Search(c.Context(), params)
+	result, err := h.cachedSearch(c, params)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
 	}
+	h.logSearchEvent(params.Query, variant, ranking.Version, result.Total, result.Took)
+	products := make([]models.ProductCard, len(result.Products))
+	for i, p := range result.Products {
+		products[i] = models.CardFromES(p, priceMode)
+	}
+	// indexed labels lag behind expiry until the purge reindexes; report the current ones
+	h.attachLabels(ctx, products)
+	addListDisplay(c, products)
 
-	return c.JSON(fiber.Map{
+	items := project(products, fields)
+	return sendListing(c, "search", items, fiber.Map{
 		"success": true,
-		"data": fiber.Map{
-			"items":       result.Products,
-			"total":       result.Total,
-			"page":        params.Page,
-			"limit":       params.Limit,
-			"total_pages": (result.Total + int64(params.Limit) - 1) / int64(params.Limit),
-			"facets":      result.Facets,
-			"took_ms":     result.Took,
-		},
+		"data": paginate(c, fiber.Map{
+			"items":      items,
+			"facets":     result.Facets,
+			"took_ms":    result.Took,
+			"price_mode": priceMode,
+			"sort":       params.Sort,
+			"variant":    variant,
+			"warnings":   nonNilStrings(warnings),
+		}, params.Page, params.Limit, result.Total),
 	})
 }
 
@@ -93,46 +171,11 @@ func (h *Handlers) SyncToElasticsearch(c *fiber.Ctx) error {
 		return c.Status(503).JSON(fiber.Map{"success": false, "error": "Elasticsearch not configured"})
 	}
 
-	ctx := context.Background()
-	rows, err := h.db.Pool.Query(ctx, `
-		SELECT p.id, p.title, p.slug, COALESCE(p.description,''), COALESCE(p.short_description,''),
-		       COALESCE(p.ean,''), COALESCE(p.sku,''), COALESCE(p.brand,''),
-		       COALESCE(p.category_id::text,''), COALESCE(c.name,''), COALESCE(c.slug,''),
-		       COALESCE(p.image_url,''), p.price_min, p.price_max, COALESCE(p.stock_status,'instock'),
-		       p.is_active, COALESCE(p.is_featured, false), p.created_at
-		FROM products p LEFT JOIN categories c ON p.category_id = c.id
-	`)
+	indexed, err := h.ReindexProducts(context.Background())
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
-	}
-	defer rows.Close()
-
-	var products []elasticsearch.Product
-	for rows.Next() {
-		var p elasticsearch.Product
-		var createdAt time.Time
-		rows.Scan(&p.ID, &p.Title, &p.Slug, &p.Description, &p.ShortDescription,
-			&p.EAN, &p.SKU, &p.Brand, &p.CategoryID, &p.CategoryName, &p.CategorySlug,
-			&p.ImageURL, &p.PriceMin, &p.PriceMax, &p.StockStatus, &p.IsActive, &p.IsFeatured, &createdAt)
-		p.CreatedAt = createdAt.Format(time.RFC3339)
-		products = append(products, p)
-	}
-
-	batchSize := 1000
-	indexed := 0
-	for i := 0; i < len(products); i += batchSize {
-		end := i + batchSize
-		if end > len(products) {
-			end = len(products)
-		}
-		if err := h.es.BulkIndex(products[i:end]); err != nil {
-			return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error(), "indexed": indexed})
-		}
-		indexed += end - i
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error(), "indexed": indexed})
 	}
 
-	h.es.Refresh()
-
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": fmt.Sprintf("Synced %d products to Elasticsearch", indexed),
@@ -143,109 +186,134 @@ func (h *Handlers) SyncToElasticsearch(c *fiber.Ctx) error {
 // ========== PUBLIC API ==========
 
 func (h *Handlers) GetProducts(c *fiber.Ctx) error {
-	page := c.QueryInt("page", 1)
-	limit := c.QueryInt("limit", 20)
-	if page < 1 {
-		page = 1
-	}
-	offset := (page - 1) * limit
-	ctx := context.Background()
+	page, limit, _ := listPageParams(c, 20)
+	priceMode, ok := priceModeParam(c)
+	if !ok {
+		return invalidPriceMode(c)
+	}
+	fields, msg := fieldsParam(c, models.ProductCard{})
+	if msg != "" {
+		return invalidFields(c, msg)
+	}
+
+	result := h.listProducts(context.Background(), productListQuery{
+		Categories:        splitList(c.Query("category")),
+		ExcludeCategories: splitList(c.Query("category_not")),
+		Brands:            splitList(c.Query("brand")),
+		ExcludeBrands:     splitList(c.Query("brand_not")),
+		MinPrice:          float64(c.QueryInt("min_price", 0)),
+		MaxPrice:          float64(c.QueryInt("max_price", 0)),
+		InStock:           c.Query("in_stock") == "true",
+		Sort:              c.Query("sort"),
+		PriceMode:         priceMode,
+		Page:              page,
+		Limit:             limit,
+	})
+	addListDisplay(c, result.Items)
+
+	items := project(result.Items, fields)
+	return sendListing(c, "products", items, fiber.Map{"success": true, "data": paginate(c, fiber.Map{
+		"items":      items,
+		"facets":     result.Facets,
+		"price_mode": priceMode,
+		"sort":       result.Sort,
+		"warnings":   nonNilStrings(result.Warnings),
+	}, page, limit, result.Total)})
+}
+
+// productListQuery is a storefront listing request, shared by GetProducts and the
+// GraphQL products field
+type productListQuery struct {
+	Categories, ExcludeCategories []string
+	Brands, ExcludeBrands         []string
+	MinPrice, MaxPrice            float64
+	InStock                       bool
+	Sort, PriceMode               string
+	Page, Limit                   int
+}
+
+type productListResult struct {
+	Items    []models.ProductCard
+	Total    int64
+	Facets   fiber.Map
+	Warnings []string
+	// Sort is the sort applied: the requested one or the category's or global default
+	Sort string
+}
 
-	whereClause := "WHERE p.is_active=true"
-	args := []interface{}{}
-	argNum := 1
+// listProducts runs a listing against the database with brand and price facets
+func (h *Handlers) listProducts(ctx context.Context, q productListQuery) productListResult {
+	priceMinCol, _ := priceColumns(q.PriceMode)
+	limit, offset := q.Limit, (q.Page-1)*q.Limit
 
-	if cat := c.Query("category"); cat != "" {
-		whereClause += fmt.Sprintf(" AND p.category_id IN (WITH RECURSIVE subcats AS (SELECT id FROM categories WHERE slug = $%d UNION ALL SELECT c.id FROM categories c JOIN subcats s ON c.parent_id = s.id) SELECT id FROM subcats)", argNum)
-		args = append(args, cat)
-		argNum++
-	}
+	where := newWhere("p.is_active=true")
 
-	if brand := c.Query("brand"); brand != "" {
-		brands := strings.Split(brand, ",")
-		placeholders := []string{}
-		for _, b := range brands {
-			placeholders = append(placeholders, fmt.Sprintf("$%d", argNum))
-			args = append(args, b)
-			argNum++
+	var warnings []string
+	if cats := q.Categories; len(cats) > 0 {
+		ids, w := h.resolveCategorySubtrees(ctx, cats)
+		warnings = append(warnings, w...)
+		if ids == nil {
+			ids = []string{}
+		}
+		where.add("p.category_id = ANY(?::uuid[])", ids)
+	}
+	if cats := q.ExcludeCategories; len(cats) > 0 {
+		ids, w := h.resolveCategorySubtrees(ctx, cats)
+		warnings = append(warnings, w...)
+		if len(ids) > 0 {
+			where.add("(p.category_id IS NULL OR p.category_id <> ALL(?::uuid[]))", ids)
 		}
-		whereClause += fmt.Sprintf(" AND p.brand IN (%s)", strings.Join(placeholders, ","))
 	}
 
-	if minPrice := c.QueryInt("min_price", 0); minPrice > 0 {
-		whereClause += fmt.Sprintf(" AND p.price_min >= $%d", argNum)
-		args = append(args, minPrice)
-		argNum++
+	if brands := q.Brands; len(brands) > 0 {
+		where.add("p.brand = ANY(?)", brands)
 	}
-	if maxPrice := c.QueryInt("max_price", 0); maxPrice > 0 {
-		whereClause += fmt.Sprintf(" AND p.price_min <= $%d", argNum)
-		args = append(args, maxPrice)
-		argNum++
+	if brands := q.ExcludeBrands; len(brands) > 0 {
+		where.add("COALESCE(p.brand,'') <> ALL(?)", brands)
 	}
 
-	if c.Query("in_stock") == "true" {
-		whereClause += " AND p.stock_status = 'instock'"
+	if q.MinPrice > 0 {
+		where.add(priceMinCol+" >= ?", q.MinPrice)
+	}
+	if q.MaxPrice > 0 {
+		where.add(priceMinCol+" <= ?", q.MaxPrice)
 	}
 
-	var total int
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM products p LEFT JOIN categories c ON p.category_id = c.id %s", whereClause)
-	h.db.Pool.QueryRow(ctx, countQuery, args...).Scan(&total)
-
-	orderBy := "ORDER BY p.created_at DESC"
-	switch c.Query("sort") {
-	case "price_asc":
-		orderBy = "ORDER BY p.price_min ASC"
-	case "price_desc":
-		orderBy = "ORDER BY p.price_min DESC"
-	case "name_asc":
-		orderBy = "ORDER BY p.title ASC"
-	case "newest":
-		orderBy = "ORDER BY p.created_at DESC"
+	if q.InStock {
+		where.add("p.stock_status = 'instock'")
 	}
+	policies := h.outOfStockPolicies(ctx)
+	policies.applyToWhere(where)
 
-	args = append(args, limit, offset)
+	var total int64
+	countQuery := "SELECT COUNT(*) FROM products p LEFT JOIN categories c ON p.category_id = c.id " + where.clause()
+	h.db.ReadPool.QueryRow(ctx, countQuery, where.params()...).Scan(&total)
+
+	sort := effectiveListingSort(q.Sort, func() string { return h.listingSortDefaults(ctx).forSlugs(q.Categories) })
+	list := where.clone()
+	orderBy := "ORDER BY " + policies.demoteOrderWhere(list) + listingOrder(sort, priceMinCol)
 	query := fmt.Sprintf(`
-		SELECT p.id, p.title, p.slug, COALESCE(p.short_description,''), COALESCE(p.image_url,''), 
-		       p.price_min, p.price_max, COALESCE(p.stock_status,'instock'), COALESCE(p.brand,''),
-		       COALESCE(c.name,''), COALESCE(c.slug,'')
+		SELECT %s
 		FROM products p LEFT JOIN categories c ON p.category_id = c.id
-		%s %s LIMIT $%d OFFSET $%d
-	`, whereClause, orderBy, argNum, argNum+1)
+		%s %s LIMIT %s OFFSET %s
+	`, productCardColumns(q.PriceMode), list.clause(), orderBy, list.arg(limit), list.arg(offset))
 
-	rows, _ := h.db.Pool.Query(ctx, query, args...)
-	defer rows.Close()
+	rows, _ := h.db.ReadPool.Query(ctx, query, list.params()...)
+	products := scanProductCards(rows)
+	h.attachLabels(ctx, products)
 
-	var products []fiber.Map
-	for rows.Next() {
-		var id, title, slug, shortDesc, img, stockStatus, brand, catName, catSlug string
-		var pmin, pmax float64
-		rows.Scan(&id, &title, &slug, &shortDesc, &img, &pmin, &pmax, &stockStatus, &brand, &catName, &catSlug)
-		products = append(products, fiber.Map{
-			"id": id, "title": title, "slug": slug, "short_description": shortDesc,
-			"image_url": img, "price_min": pmin, "price_max": pmax, "stock_status": stockStatus,
-			"brand": brand, "category_name": catName, "category_slug": catSlug,
-		})
-	}
-	if products == nil {
-		products = []fiber.Map{}
-	}
-
-	facets := h.getProductFacets(ctx, whereClause, args[:len(args)-2])
-
-	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
-		"items": products, "total": total, "page": page, "limit": limit,
-		"total_pages": (total + limit - 1) / limit,
-		"facets":      facets,
-	}})
+	facets := h.getProductFacets(ctx, where, priceMinCol)
+	return productListResult{Items: products, Total: total, Facets: facets, Warnings: warnings, Sort: sort}
 }
 
-func (h *Handlers) getProductFacets(ctx context.Context, whereClause string, args []interface{}) fiber.Map {
+func (h *Handlers) getProductFacets(ctx context.Context, where *sqlWhere, priceCol string) fiber.Map {
+	branded := where.with("p.brand != ''")
 	brandQuery := fmt.Sprintf(`
 		SELECT p.brand, COUNT(*) as cnt FROM products p 
 		LEFT JOIN categories c ON p.category_id = c.id
-		%s AND p.brand != '' GROUP BY p.brand ORDER BY cnt DESC LIMIT 50
-	`, whereClause)
-	brandRows, _ := h.db.Pool.Query(ctx, brandQuery, args...)
+		%s GROUP BY p.brand ORDER BY cnt DESC LIMIT 50
+	`, branded.clause())
+	brandRows, _ := h.db.ReadPool.Query(ctx, brandQuery, branded.params()...)
 	defer brandRows.Close()
 
 	var brands []fiber.Map
@@ -257,11 +325,11 @@ func (h *Handlers) getProductFacets(ctx context.Context, whereClause string, arg
 	}
 
 	priceQuery := fmt.Sprintf(`
-		SELECT MIN(p.price_min), MAX(p.price_min) FROM products p 
+		SELECT MIN(%s), MAX(%s) FROM products p 
 		LEFT JOIN categories c ON p.category_id = c.id %s
-	`, whereClause)
-	var minPrice, maxPrice float64
-	h.db.Pool.QueryRow(ctx, priceQuery, args...).Scan(&minPrice, &maxPrice)
+	`, priceCol, priceCol, where.clause())
+	var minPrice, maxPrice money.Price
+	h.db.ReadPool.QueryRow(ctx, priceQuery, where.params()...).Scan(&minPrice, &maxPrice)
 
 	return fiber.Map{
 		"brands":      brands,
@@ -272,157 +340,123 @@ func (h *Handlers) getProductFacets(ctx context.Context, whereClause string, arg
 func (h *Handlers) GetFeaturedProducts(c *fiber.Ctx) error {
 	limit := c.QueryInt("limit", 8)
 	ctx := context.Background()
-	rows, _ := h.db.Pool.Query(ctx, `
-		SELECT p.id, p.title, p.slug, COALESCE(p.image_url,''), p.price_min, p.price_max, COALESCE(p.brand,''), COALESCE(c.name,''), COALESCE(c.slug,'')
+	priceMode, ok := priceModeParam(c)
+	if !ok {
+		return invalidPriceMode(c)
+	}
+	rows, _ := h.db.ReadPool.Query(ctx, `
+		SELECT `+productCardColumns(priceMode)+`
 		FROM products p LEFT JOIN categories c ON p.category_id = c.id
 		WHERE p.is_active=true ORDER BY p.is_featured DESC, p.created_at DESC LIMIT $1
 	`, limit)
-	defer rows.Close()
-	var products []fiber.Map
-	for rows.Next() {
-		var id, title, slug, img, brand, catName, catSlug string
-		var pmin, pmax float64
-		rows.Scan(&id, &title, &slug, &img, &pmin, &pmax, &brand, &catName, &catSlug)
-		products = append(products, fiber.Map{"id": id, "title": title, "slug": slug, "image_url": img, "price_min": pmin, "price_max": pmax, "brand": brand, "category_name": catName, "category_slug": catSlug})
+	products := scanProductCards(rows)
+	h.attachLabels(ctx, products)
+	addListDisplay(c, products)
+	return c.JSON(fiber.Map{"success": true, "data": products})
+}
+
+// productBySlug loads the product behind slug. A renamed product is loaded under its
+// current slug and comes with redirect metadata.
+func (h *Handlers) productBySlug(ctx context.Context, slug string) (models.Product, fiber.Map, error) {
+	var p models.Product
+	load := func(slug string) error {
+		return h.db.Pool.QueryRow(ctx, `
+		SELECT p.id, p.title, p.slug, COALESCE(p.description,''), COALESCE(p.description_plain,''), COALESCE(p.excerpt,''),
+		       COALESCE(p.short_description,''), COALESCE(p.ean,''), COALESCE(p.sku,''), COALESCE(p.mpn,''), COALESCE(p.brand,''),
+		       COALESCE(p.image_url,''), COALESCE(p.stock_status,'instock'),
+		       COALESCE(p.category_id::text,''), COALESCE(c.name,''), COALESCE(c.slug,''),
+		       COALESCE(p.affiliate_url,''), COALESCE(p.currency,'EUR'), COALESCE(p.vat_rate,20),
+		       `+effectivePriceMin+`, `+effectivePriceMax+`, `+effectivePriceMinNet+`, `+effectivePriceMaxNet+`,
+		       p.is_active, COALESCE(p.is_featured,false), p.created_at,
+		       p.price_min, COALESCE(p.price_min_net, p.price_min), `+promoEndsColumn+`, p.release_date,
+		       COALESCE(p.gift_text,''), COALESCE(p.warranty_months,0), COALESCE(p.extra_message,'')
+		FROM products p LEFT JOIN categories c ON p.category_id = c.id WHERE p.slug = $1
+	`, slug).Scan(&p.ID, &p.Title, &p.Slug, &p.Description, &p.DescriptionPlain, &p.Excerpt, &p.ShortDescription, &p.EAN, &p.SKU, &p.MPN, &p.Brand, &p.ImageURL, &p.StockStatus, &p.CategoryID, &p.CategoryName, &p.CategorySlug, &p.AffiliateURL, &p.Currency, &p.VATRate, &p.PriceMin, &p.PriceMax, &p.PriceMinNet, &p.PriceMaxNet, &p.IsActive, &p.IsFeatured, &p.CreatedAt,
+			&p.RegularPriceMin, &p.RegularPriceMinNet, &p.PromoEndsAt, &p.ReleaseDate,
+			&p.GiftText, &p.WarrantyMonths, &p.ExtraMessage)
 	}
-	if products == nil {
-		products = []fiber.Map{}
+	err := load(slug)
+	var redirect fiber.Map
+	if err != nil {
+		// a renamed product is served under its current slug with redirect metadata
+		if target := h.resolveSlugRedirect(ctx, "product", slug); target != "" {
+			redirect = slugRedirect("product", target)
+			err = load(target)
+		}
 	}
-	return c.JSON(fiber.Map{"success": true, "data": products})
+	return p, redirect, err
 }
 
 func (h *Handlers) GetProductBySlug(c *fiber.Ctx) error {
 	slug := c.Params("slug")
 	ctx := context.Background()
-	var id, title, pslug, desc, shortDesc, ean, sku, mpn, brand, img, stockStatus, catID, catName, catSlug, affiliateURL string
-	var priceMin, priceMax float64
-	var isActive bool
-	var createdAt time.Time
-	err := h.db.Pool.QueryRow(ctx, `
-		SELECT p.id, p.title, p.slug, COALESCE(p.description,''), COALESCE(p.short_description,''),
-		       COALESCE(p.ean,''), COALESCE(p.sku,''), COALESCE(p.mpn,''), COALESCE(p.brand,''),
-		       COALESCE(p.image_url,''), COALESCE(p.stock_status,'instock'),
-		       COALESCE(p.category_id::text,''), COALESCE(c.name,''), COALESCE(c.slug,''),
-		       COALESCE(p.affiliate_url,''),
-		       p.price_min, p.price_max, p.is_active, p.created_at
-		FROM products p LEFT JOIN categories c ON p.category_id = c.id WHERE p.slug = $1
-	`, slug).Scan(&id, &title, &pslug, &desc, &shortDesc, &ean, &sku, &mpn, &brand, &img, &stockStatus, &catID, &catName, &catSlug, &affiliateURL, &priceMin, &priceMax, &isActive, &createdAt)
+	priceMode, ok := priceModeParam(c)
+	if !ok {
+		return invalidPriceMode(c)
+	}
+	fields, msg := fieldsParam(c, models.ProductDetail{})
+	if msg != "" {
+		return invalidFields(c, msg)
+	}
+	p, redirect, err := h.productBySlug(ctx, slug)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Product not found"})
 	}
-
-	imgRows, _ := h.db.Pool.Query(ctx, `SELECT url FROM product_images WHERE product_id = $1::uuid ORDER BY position`, id)
-	defer imgRows.Close()
-	var images []string
-	for imgRows.Next() {
-		var imgURL string
-		imgRows.Scan(&imgURL)
-		images = append(images, imgURL)
+	if !p.IsActive {
+		return h.productGone(c, ctx, p, priceMode)
 	}
 
-	// Get attributes using existing table structure (name, value)
-	attrRows, _ := h.db.Pool.Query(ctx, `SELECT name, value FROM product_attributes WHERE product_id = $1::uuid ORDER BY position, name`, id)
-	defer attrRows.Close()
-	var attributes []fiber.Map
-	for attrRows.Next() {
-		var name, value string
-		attrRows.Scan(&name, &value)
-		attributes = append(attributes, fiber.Map{"name": name, "value": value})
+	detail, err := h.productDetail(ctx, p, priceMode)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
 	}
+	addDetailDisplay(c, &detail)
+	go h.db.Pool.Exec(context.Background(), "UPDATE products SET view_count = COALESCE(view_count,0) + 1 WHERE id = $1::uuid", p.ID)
 
-	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
-		"id": id, "title": title, "slug": pslug, "description": desc, "short_description": shortDesc,
-		"ean": ean, "sku": sku, "mpn": mpn, "brand": brand, "image_url": img, "images": images,
-		"stock_status": stockStatus, "category_id": catID, "category_name": catName, "category_slug": catSlug,
-		"affiliate_url": affiliateURL, "price_min": priceMin, "price_max": priceMax, "is_active": isActive,
-		"created_at": createdAt, "attributes": attributes,
-	}})
+	if redirect != nil {
+		return c.JSON(fiber.Map{"success": true, "data": project(detail, fields), "redirect": redirect})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": project(detail, fields)})
 }
 
+// GetCategories lists the active categories; ?hide_empty=true leaves out those without
+// an in-stock product in their subtree
 func (h *Handlers) GetCategories(c *fiber.Ctx) error {
-	ctx := context.Background()
-	rows, _ := h.db.Pool.Query(ctx, `SELECT id, COALESCE(parent_id::text,''), name, slug, COALESCE(icon,''), product_count FROM categories WHERE is_active=true ORDER BY sort_order, name`)
-	defer rows.Close()
-
-	var cats []fiber.Map
-	for rows.Next() {
-		var id, parentID, name, slug, icon string
-		var productCount int
-		rows.Scan(&id, &parentID, &name, &slug, &icon, &productCount)
-		cats = append(cats, fiber.Map{"id": id, "parent_id": parentID, "name": name, "slug": slug, "icon": icon, "product_count": productCount})
-	}
-	if cats == nil {
-		cats = []fiber.Map{}
-	}
+	cats := categoryMaps(h.publicCategories(context.Background(), "sort_order, name", c.QueryBool("hide_empty")))
 	return c.JSON(fiber.Map{"success": true, "data": cats})
 }
 
 func (h *Handlers) GetCategoriesTree(c *fiber.Ctx) error {
-	ctx := context.Background()
-	rows, _ := h.db.Pool.Query(ctx, `SELECT id, COALESCE(parent_id::text,''), name, slug, COALESCE(icon,''), product_count FROM categories WHERE is_active=true ORDER BY sort_order, name`)
-	defer rows.Close()
-
-	type Cat struct {
-		ID           string `json:"id"`
-		ParentID     string `json:"parent_id,omitempty"`
-		Name         string `json:"name"`
-		Slug         string `json:"slug"`
-		Icon         string `json:"icon,omitempty"`
-		ProductCount int    `json:"product_count"`
-		Children     []*Cat `json:"children,omitempty"`
-	}
-	var cats []*Cat
-	catMap := make(map[string]*Cat)
-	for rows.Next() {
-		cat := &Cat{}
-		rows.Scan(&cat.ID, &cat.ParentID, &cat.Name, &cat.Slug, &cat.Icon, &cat.ProductCount)
-		cats = append(cats, cat)
-		catMap[cat.ID] = cat
-	}
-
-	var roots []*Cat
-	for _, cat := range cats {
-		if cat.ParentID == "" {
-			roots = append(roots, cat)
-		} else if parent, ok := catMap[cat.ParentID]; ok {
-			parent.Children = append(parent.Children, cat)
-		}
-	}
-	if roots == nil {
-		roots = []*Cat{}
-	}
-	return c.JSON(fiber.Map{"success": true, "data": roots})
+	cats := h.publicCategories(context.Background(), "sort_order, name", c.QueryBool("hide_empty"))
+	return c.JSON(fiber.Map{"success": true, "data": models.BuildCategoryTree(cats)})
 }
 
 func (h *Handlers) GetCategoriesFlat(c *fiber.Ctx) error {
-	ctx := context.Background()
-	rows, _ := h.db.Pool.Query(ctx, `SELECT id, COALESCE(parent_id::text,''), name, slug, COALESCE(icon,''), product_count FROM categories WHERE is_active=true ORDER BY name`)
-	defer rows.Close()
-
-	var cats []fiber.Map
-	for rows.Next() {
-		var id, parentID, name, slug, icon string
-		var productCount int
-		rows.Scan(&id, &parentID, &name, &slug, &icon, &productCount)
-		cats = append(cats, fiber.Map{"id": id, "parent_id": parentID, "name": name, "slug": slug, "icon": icon, "product_count": productCount})
-	}
-	if cats == nil {
-		cats = []fiber.Map{}
-	}
-	return c.JSON(fiber.Map{"success": true, "data": cats})
+	cats := categoryMaps(h.publicCategories(context.Background(), "name", c.QueryBool("hide_empty")))
+	return sendListing(c, "categories", cats, fiber.Map{"success": true, "data": cats})
 }
 
 func (h *Handlers) GetCategoryBySlug(c *fiber.Ctx) error {
 	slug := c.Params("slug")
 	ctx := context.Background()
-	var id, parentID, name, cslug, desc, icon string
+	var id, parentID, name, cslug, desc, icon, iconURL string
 	var productCount int
-	err := h.db.Pool.QueryRow(ctx, `SELECT id, COALESCE(parent_id::text,''), name, slug, COALESCE(description,''), COALESCE(icon,''), product_count FROM categories WHERE slug = $1 AND is_active=true`, slug).Scan(&id, &parentID, &name, &cslug, &desc, &icon, &productCount)
+	load := func(slug string) error {
+		return h.db.ReadPool.QueryRow(ctx, `SELECT id, COALESCE(parent_id::text,''), name, slug, COALESCE(description,''), COALESCE(icon,''), COALESCE(icon_url,''), product_count FROM categories WHERE slug = $1 AND is_active=true`, slug).Scan(&id, &parentID, &name, &cslug, &desc, &icon, &iconURL, &productCount)
+	}
+	err := load(slug)
+	var redirect fiber.Map
+	if err != nil {
+		if target := h.resolveSlugRedirect(ctx, "category", slug); target != "" {
+			redirect = slugRedirect("category", target)
+			err = load(target)
+		}
+	}
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Category not found"})
 	}
 
-	subRows, _ := h.db.Pool.Query(ctx, `SELECT id, name, slug, product_count FROM categories WHERE parent_id = $1::uuid AND is_active=true ORDER BY sort_order, name`, id)
+	subRows, _ := h.db.ReadPool.Query(ctx, `SELECT id, name, slug, product_count FROM categories WHERE parent_id = $1::uuid AND is_active=true ORDER BY sort_order, name`, id)
 	defer subRows.Close()
 	var subcategories []fiber.Map
 	for subRows.Next() {
@@ -432,24 +466,50 @@ func (h *Handlers) GetCategoryBySlug(c *fiber.Ctx) error {
 		subcategories = append(subcategories, fiber.Map{"id": subID, "name": subName, "slug": subSlug, "product_count": subCount})
 	}
 
-	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+	data := fiber.Map{
 		"id": id, "parent_id": parentID, "name": name, "slug": cslug, "description": desc,
-		"icon": icon, "product_count": productCount, "subcategories": subcategories,
-	}})
+		"icon": icon, "icon_url": iconURL, "product_count": productCount, "subcategories": subcategories,
+		"out_of_stock_policy": h.outOfStockPolicies(ctx).policyOf(id),
+	}
+	data["default_sort"], _ = h.listingSortDefaults(ctx).forCategory(id)
+	if redirect != nil {
+		return c.JSON(fiber.Map{"success": true, "data": data, "redirect": redirect})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": data})
 }
 
+// GetProductsByCategory lists the active products of a category and its subtree.
+// Asked for a page (?page= or ?limit=), data is the standard page object with items
+// and a Link header. Without either, data keeps its original shape: the bare array of
+// every matching product.
 func (h *Handlers) GetProductsByCategory(c *fiber.Ctx) error {
 	slug := c.Params("slug")
+	page, limit, offset := pageParams(c, 20)
+	paginated := c.Query("page") != "" || c.Query("limit") != ""
 	ctx := context.Background()
+	priceMode, ok := priceModeParam(c)
+	if !ok {
+		return invalidPriceMode(c)
+	}
+	priceMinCol, _ := priceColumns(priceMode)
+	fields, msg := fieldsParam(c, models.ProductCard{})
+	if msg != "" {
+		return invalidFields(c, msg)
+	}
 	
 	var categoryID string
-	err := h.db.Pool.QueryRow(ctx, "SELECT id FROM categories WHERE slug = $1", slug).Scan(&categoryID)
+	err := h.db.ReadPool.QueryRow(ctx, "SELECT id FROM categories WHERE slug = $1", slug).Scan(&categoryID)
+	if err != nil {
+		if target := h.resolveSlugRedirect(ctx, "category", slug); target != "" {
+			err = h.db.ReadPool.QueryRow(ctx, "SELECT id FROM categories WHERE slug = $1", target).Scan(&categoryID)
+		}
+	}
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Category not found"})
 	}
 	
 	// Get all subcategory IDs recursively
-	rows, _ := h.db.Pool.Query(ctx, `
+	rows, _ := h.db.ReadPool.Query(ctx, `
 		WITH RECURSIVE subcats AS (
 			SELECT id FROM categories WHERE id = $1::uuid
 			UNION ALL
@@ -469,55 +529,95 @@ func (h *Handlers) GetProductsByCategory(c *fiber.Ctx) error {
 		categoryIDs = []string{categoryID}
 	}
 	
-	prodRows, _ := h.db.Pool.Query(ctx, `
-		SELECT p.id, p.title, p.slug, COALESCE(p.image_url,''), p.price_min, p.price_max, COALESCE(p.brand,'')
-		FROM products p 
-		WHERE p.category_id = ANY($1::uuid[]) AND p.is_active=true 
-		ORDER BY p.created_at DESC`, categoryIDs)
-	defer prodRows.Close()
-	
-	var products []fiber.Map
-	for prodRows.Next() {
-		var id, title, pslug, img, brand string
-		var pmin, pmax float64
-		prodRows.Scan(&id, &title, &pslug, &img, &pmin, &pmax, &brand)
-		products = append(products, fiber.Map{"id": id, "title": title, "slug": pslug, "image_url": img, "price_min": pmin, "price_max": pmax, "brand": brand})
+	where := "WHERE p.category_id = ANY($1::uuid[]) AND p.is_active=true"
+	args := []interface{}{categoryIDs}
+	policies := h.outOfStockPolicies(ctx)
+	if cond := policies.hideCondition(&args); cond != "" {
+		where += " AND " + cond
 	}
-	if products == nil {
-		products = []fiber.Map{}
+	var total int64
+	if paginated {
+		h.db.ReadPool.QueryRow(ctx, "SELECT COUNT(*) FROM products p "+where, args...).Scan(&total)
 	}
-	return c.JSON(fiber.Map{"success": true, "data": products})
+
+	sort := effectiveListingSort(c.Query("sort"), func() string {
+		sort, _ := h.listingSortDefaults(ctx).forCategory(categoryID)
+		return sort
+	})
+	orderBy := policies.demoteOrder(&args) + listingOrder(sort, priceMinCol)
+	pageClause := ""
+	if paginated {
+		args = append(args, limit, offset)
+		pageClause = fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+	prodRows, _ := h.db.ReadPool.Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM products p LEFT JOIN categories c ON p.category_id = c.id
+		%s
+		ORDER BY %s%s`, productCardColumns(priceMode), where, orderBy, pageClause), args...)
+	products := scanProductCards(prodRows)
+	h.attachLabels(ctx, products)
+	addListDisplay(c, products)
+	if !paginated {
+		return c.JSON(fiber.Map{"success": true, "data": project(products, fields)})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{"items": project(products, fields), "sort": sort}, page, limit, total)})
 }
 
 func (h *Handlers) GetStats(c *fiber.Ctx) error {
 	ctx := context.Background()
 	var p, cat int64
-	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM products WHERE is_active=true").Scan(&p)
-	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM categories WHERE is_active=true").Scan(&cat)
+	h.db.ReadPool.QueryRow(ctx, "SELECT COUNT(*) FROM products WHERE is_active=true").Scan(&p)
+	h.db.ReadPool.QueryRow(ctx, "SELECT COUNT(*) FROM categories WHERE is_active=true").Scan(&cat)
 	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"products": p, "categories": cat}})
 }
 
-func (h *Handlers) AdminDashboard(c *fiber.Ctx) error { return h.GetStats(c) }
+func (h *Handlers) AdminDashboard(c *fiber.Ctx) error {
+	ctx := context.Background()
+	var p, cat int64
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM products WHERE is_active=true").Scan(&p)
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM categories WHERE is_active=true").Scan(&cat)
+	uncurated := h.uncuratedCategories(ctx, time.Now().AddDate(0, 0, -7))
+	var pending int64
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM categories WHERE review_status = 'pending'").Scan(&pending)
+	data := fiber.Map{"products": p, "categories": cat, "uncurated_categories_7d": uncurated, "pending_categories": pending, "imports": h.imports.stats()}
+	if stats, err := h.productStats(ctx); err == nil {
+		data["product_stats"] = stats
+	}
+	if attention, err := h.feedsNeedingAttention(ctx); err == nil {
+		data["feeds_needing_attention"] = attention
+	}
+	return c.JSON(fiber.Map{"success": true, "data": data})
+}
 
 func (h *Handlers) GetProductOffers(c *fiber.Ctx) error {
-	productID := c.Params("id")
-	ctx := context.Background()
+	offers, _ := h.productOffers(context.Background(), c.Params("id"))
+	addOfferDisplay(c, offers)
+	return c.JSON(fiber.Map{"success": true, "data": offers})
+}
 
-	var priceMin float64
+// productOffers returns the offers of a product, cheapest total (price plus shipping)
+// first. On error the offers are built from zero values.
+func (h *Handlers) productOffers(ctx context.Context, productID string) ([]fiber.Map, error) {
+	var priceMin, regularPrice money.Price
+	var promoEndsAt *time.Time
 	var stockStatus, affiliateURL string
-	h.db.Pool.QueryRow(ctx, "SELECT price_min, COALESCE(stock_status,'instock'), COALESCE(affiliate_url,'') FROM products WHERE id = $1::uuid", productID).Scan(&priceMin, &stockStatus, &affiliateURL)
+	err := h.db.Pool.QueryRow(ctx, "SELECT "+effectivePriceMin+", COALESCE(p.stock_status,'instock'), COALESCE(p.affiliate_url,''), "+promoColumns("gross")+" FROM products p WHERE p.id = $1::uuid", productID).Scan(&priceMin, &stockStatus, &affiliateURL, &regularPrice, &promoEndsAt)
 
-	shippingPrice := 2.99
-	if priceMin >= 49 {
+	shippingPrice := money.New(2.99)
+	if priceMin >= money.New(49) {
 		shippingPrice = 0
 	}
 
-	return c.JSON(fiber.Map{"success": true, "data": []fiber.Map{{
+	offers := []fiber.Map{{
 		"id": "default", "vendor_id": "megabuy", "vendor_name": "MegaBuy.sk",
 		"vendor_logo": "", "vendor_rating": 4.8, "vendor_reviews": 1250,
-		"price": priceMin, "shipping_price": shippingPrice, "delivery_days": "1-2",
+		"price": priceMin, "shipping_price": shippingPrice, "total_price": priceMin + shippingPrice, "delivery_days": "1-2",
 		"stock_status": stockStatus, "stock_quantity": 10, "is_megabuy": true, "affiliate_url": affiliateURL,
-	}}})
+		"promo": models.NewPromoPrice(regularPrice, priceMin, promoEndsAt),
+	}}
+	sort.SliceStable(offers, func(i, j int) bool { return offers[i]["total_price"].(money.Price) < offers[j]["total_price"].(money.Price) })
+	return offers, err
 }
 
 // ========== ATTRIBUTE STATS ==========
@@ -526,7 +626,7 @@ func (h *Handlers) GetAttributeStats(c *fiber.Ctx) error {
 	ctx := context.Background()
 
 	// Using existing table structure (name, value)
-	rows, _ := h.db.Pool.Query(ctx, `
+	rows, _ := h.db.ReadPool.Query(ctx, `
 		SELECT name, 
 		       COUNT(DISTINCT product_id) as product_count,
 		       COUNT(DISTINCT value) as value_count
@@ -555,94 +655,153 @@ func (h *Handlers) GetAttributeStats(c *fiber.Ctx) error {
 }
 
 func (h *Handlers) GetFilterSettings(c *fiber.Ctx) error {
-	ctx := context.Background()
-
-	var settings string
-	err := h.db.Pool.QueryRow(ctx, "SELECT settings FROM filter_settings WHERE id = 1").Scan(&settings)
-	if err != nil {
-		return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
-			"filterable_attributes": []string{},
-			"show_price_filter":     true,
-			"show_stock_filter":     true,
-			"show_brand_filter":     true,
-			"max_values_per_filter": 20,
-		}})
-	}
-	return c.JSON(fiber.Map{"success": true, "data": settings})
+	filters, _ := h.settings.Filters(context.Background())
+	return c.JSON(fiber.Map{"success": true, "data": filters})
 }
 
+// UpdateFilterSettings replaces the "filters" setting; the body is validated like
+// PUT /admin/settings/filters
 func (h *Handlers) UpdateFilterSettings(c *fiber.Ctx) error {
 	ctx := context.Background()
-	body := c.Body()
-
-	_, err := h.db.Pool.Exec(ctx, `
-		INSERT INTO filter_settings (id, settings, updated_at) 
-		VALUES (1, $1, NOW())
-		ON CONFLICT (id) DO UPDATE SET settings = $1, updated_at = NOW()
-	`, string(body))
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	if _, err := h.settings.Set(ctx, settings.KeyFilters, json.RawMessage(c.Body()), auditActor(c)); err != nil {
+		return settingsError(c, h.settings.Keys(), err)
 	}
 	return c.JSON(fiber.Map{"success": true, "message": "Filter settings updated"})
 }
 
 // ========== ADMIN API ==========
 
+// AdminProducts lists products for the admin, including their internal tags;
+// ?category_id= covers the whole subtree unless include_subtree=false, ?tag= filters
+// by tag slug, ?min_score=/?max_score= bound the completeness score,
+// ?sort=completeness_asc|completeness_desc orders by it and ?format=csv exports the
+// matching products
 func (h *Handlers) AdminProducts(c *fiber.Ctx) error {
-	page := c.QueryInt("page", 1)
-	limit := c.QueryInt("limit", 20)
-	search := c.Query("search")
-	if page < 1 {
-		page = 1
+	page, limit, offset := adminPageParams(c, 20)
+	csvExport := wantsCSV(c)
+	if csvExport {
+		page, limit, offset = 1, maxReportExportRows, 0
 	}
-	offset := (page - 1) * limit
+	search := c.Query("search")
 	ctx := context.Background()
 
-	var total int
-	if search != "" {
-		h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM products WHERE title ILIKE $1 OR ean ILIKE $1", "%"+search+"%").Scan(&total)
-	} else {
-		h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM products").Scan(&total)
-	}
+	where := newWhere()
 
-	var rows interface{ Close(); Next() bool; Scan(...interface{}) error }
-	var err error
 	if search != "" {
-		rows, err = h.db.Pool.Query(ctx, `SELECT p.id, p.title, p.slug, COALESCE(p.ean,''), COALESCE(p.sku,''), COALESCE(p.image_url,''), p.price_min, p.price_max, p.is_active, COALESCE(p.stock_status,'instock'), COALESCE(c.name,''), p.created_at FROM products p LEFT JOIN categories c ON p.category_id = c.id WHERE p.title ILIKE $3 OR p.ean ILIKE $3 ORDER BY p.created_at DESC LIMIT $1 OFFSET $2`, limit, offset, "%"+search+"%")
-	} else {
-		rows, err = h.db.Pool.Query(ctx, `SELECT p.id, p.title, p.slug, COALESCE(p.ean,''), COALESCE(p.sku,''), COALESCE(p.image_url,''), p.price_min, p.price_max, p.is_active, COALESCE(p.stock_status,'instock'), COALESCE(c.name,''), p.created_at FROM products p LEFT JOIN categories c ON p.category_id = c.id ORDER BY p.created_at DESC LIMIT $1 OFFSET $2`, limit, offset)
+		pattern := "%" + search + "%"
+		where.add("(p.title ILIKE ? OR p.ean ILIKE ?)", pattern, pattern)
+	}
+	if source := c.Query("source"); source != "" {
+		where.add("COALESCE(p.source,'admin') = ?", source)
+	}
+	if feedID := c.Query("feed_id"); feedID != "" {
+		if !isUUID(feedID) {
+			return invalidUUIDField(c, "feed_id")
+		}
+		where.add("p.feed_id = ?::uuid", feedID)
+	}
+	if categoryID := c.Query("category_id"); categoryID != "" {
+		if !isUUID(categoryID) {
+			return invalidUUIDField(c, "category_id")
+		}
+		if c.Query("include_subtree") == "false" {
+			where.add("p.category_id = ?::uuid", categoryID)
+		} else {
+			where.add(subtreeCategoryCondition, categoryID)
+		}
 	}
+	if tag := c.Query("tag"); tag != "" {
+		where.add("EXISTS (SELECT 1 FROM product_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.product_id = p.id AND t.slug = ?)", tag)
+	}
+	for _, bound := range []struct{ param, cond string }{{"min_score", "p.completeness >= ?"}, {"max_score", "p.completeness <= ?"}} {
+		raw := c.Query(bound.param)
+		if raw == "" {
+			continue
+		}
+		score, err := strconv.Atoi(raw)
+		if err != nil || score < 0 || score > 100 {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": bound.param + " must be between 0 and 100"})
+		}
+		where.add(bound.cond, score)
+	}
+	order := "p.created_at DESC"
+	switch c.Query("sort") {
+	case "completeness_asc":
+		order = "p.completeness ASC NULLS FIRST, p.created_at DESC"
+	case "completeness_desc":
+		order = "p.completeness DESC NULLS LAST, p.created_at DESC"
+	}
+
+	var total int64
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM products p "+where.clause(), where.params()...).Scan(&total)
+
+	list := where.clone()
+	rows, err := h.db.Pool.Query(ctx, fmt.Sprintf(`SELECT p.id, p.title, p.slug, COALESCE(p.ean,''), COALESCE(p.sku,''), COALESCE(p.image_url,''), p.price_min, p.price_max, p.is_active, COALESCE(p.stock_status,'instock'), COALESCE(c.name,''), COALESCE(p.source,'admin'), COALESCE(p.feed_id::text,''), COALESCE(f.name,''), p.created_at, (SELECT COUNT(*) FROM product_attributes pa WHERE pa.product_id = p.id), p.completeness FROM products p LEFT JOIN categories c ON p.category_id = c.id LEFT JOIN feeds f ON p.feed_id = f.id %s ORDER BY %s LIMIT %s OFFSET %s`, list.clause(), order, list.arg(limit), list.arg(offset)), list.params()...)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
 	}
 	defer rows.Close()
 
 	var products []fiber.Map
+	var ids []string
 	for rows.Next() {
-		var id, title, slug, ean, sku, img, stockStatus, catName string
-		var pmin, pmax float64
+		var id, title, slug, ean, sku, img, stockStatus, catName, source, feedID, feedName string
+		var pmin, pmax money.Price
 		var isActive bool
 		var createdAt time.Time
-		rows.Scan(&id, &title, &slug, &ean, &sku, &img, &pmin, &pmax, &isActive, &stockStatus, &catName, &createdAt)
-		products = append(products, fiber.Map{"id": id, "title": title, "slug": slug, "ean": ean, "sku": sku, "image_url": img, "price_min": pmin, "price_max": pmax, "is_active": isActive, "stock_status": stockStatus, "category_name": catName, "created_at": createdAt})
+		var attributeCount int
+		var completeness *int16
+		rows.Scan(&id, &title, &slug, &ean, &sku, &img, &pmin, &pmax, &isActive, &stockStatus, &catName, &source, &feedID, &feedName, &createdAt, &attributeCount, &completeness)
+		products = append(products, fiber.Map{"id": id, "title": title, "slug": slug, "ean": ean, "sku": sku, "image_url": img, "price_min": pmin, "price_max": pmax, "is_active": isActive, "stock_status": stockStatus, "category_name": catName, "source": source, "feed_id": feedID, "feed_name": feedName, "created_at": createdAt, "attribute_count": attributeCount, "completeness": completeness})
+		ids = append(ids, id)
+	}
+	tags := h.productTags(ctx, ids)
+	for i, id := range ids {
+		products[i]["tags"] = nonNilStrings(tags[id])
+	}
+	if csvExport {
+		out := make([][]string, 0, len(products))
+		for i, p := range products {
+			score := ""
+			if s := p["completeness"].(*int16); s != nil {
+				score = strconv.Itoa(int(*s))
+			}
+			out = append(out, []string{ids[i], p["title"].(string), p["ean"].(string), p["sku"].(string), csvPrice(p["price_min"].(money.Price)), csvPrice(p["price_max"].(money.Price)), strconv.FormatBool(p["is_active"].(bool)), p["stock_status"].(string), p["category_name"].(string), p["source"].(string), p["feed_name"].(string), strings.Join(tags[ids[i]], "|"), score})
+		}
+		return sendCSV(c, "products", []string{"id", "title", "ean", "sku", "price_min", "price_max", "is_active", "stock_status", "category", "source", "feed", "tags", "completeness"}, out)
 	}
 	if products == nil {
 		products = []fiber.Map{}
 	}
-	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"items": products, "total": total, "page": page, "limit": limit, "total_pages": (total + limit - 1) / limit}})
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{"items": products}, page, limit, total)})
 }
 
 func (h *Handlers) AdminGetProduct(c *fiber.Ctx) error {
-	productID := c.Params("id")
-	ctx := context.Background()
-	var id, title, slug, desc, shortDesc, ean, sku, mpn, brand, img, stockStatus, catID string
-	var priceMin, priceMax float64
-	var isActive, isFeatured bool
-	var createdAt, updatedAt time.Time
-	err := h.db.Pool.QueryRow(ctx, `SELECT id, title, slug, COALESCE(description,''), COALESCE(short_description,''), COALESCE(ean,''), COALESCE(sku,''), COALESCE(mpn,''), COALESCE(brand,''), COALESCE(image_url,''), COALESCE(stock_status,'instock'), COALESCE(category_id::text,''), price_min, price_max, is_active, COALESCE(is_featured,false), created_at, updated_at FROM products WHERE id = $1::uuid`, productID).Scan(&id, &title, &slug, &desc, &shortDesc, &ean, &sku, &mpn, &brand, &img, &stockStatus, &catID, &priceMin, &priceMax, &isActive, &isFeatured, &createdAt, &updatedAt)
+	product, err := h.adminProduct(context.Background(), c.Params("id"))
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Product not found"})
 	}
+	c.Set(fiber.HeaderETag, versionETag(product["version"].(int)))
+	return c.JSON(fiber.Map{"success": true, "data": product})
+}
+
+// adminProduct loads the full admin view of a product, including its edit version
+func (h *Handlers) adminProduct(ctx context.Context, productID string) (fiber.Map, error) {
+	var id, title, slug, desc, shortDesc, ean, eanRaw, sku, mpn, brand, img, stockStatus, catID, source, feedID, feedName, currency string
+	var priceMin, priceMax, priceMinNet, priceMaxNet money.Price
+	var vatRate float64
+	var isActive, isFeatured, priceIsGross bool
+	var createdAt, updatedAt time.Time
+	var version int
+	var promoPrice *money.Price
+	var promoStartsAt, promoEndsAt, releaseDate *time.Time
+	var completeness, warrantyMonths *int16
+	var giftText, extraMessage string
+	var shortDescLocked bool
+	err := h.db.Pool.QueryRow(ctx, `SELECT p.id, p.title, p.slug, COALESCE(p.description,''), COALESCE(p.short_description,''), p.short_description_locked, COALESCE(p.ean,''), COALESCE(p.ean_raw,''), COALESCE(p.sku,''), COALESCE(p.mpn,''), COALESCE(p.brand,''), COALESCE(p.image_url,''), COALESCE(p.stock_status,'instock'), COALESCE(p.category_id::text,''), COALESCE(p.source,'admin'), COALESCE(p.feed_id::text,''), COALESCE(f.name,''), p.price_min, p.price_max, COALESCE(p.price_min_net, p.price_min), COALESCE(p.price_max_net, p.price_max), COALESCE(p.vat_rate,20), COALESCE(p.price_is_gross,true), COALESCE(p.currency,'EUR'), p.is_active, COALESCE(p.is_featured,false), p.created_at, p.updated_at, COALESCE(p.version,1), p.promo_price, p.promo_starts_at, p.promo_ends_at, p.release_date, p.completeness, COALESCE(p.gift_text,''), p.warranty_months, COALESCE(p.extra_message,'') FROM products p LEFT JOIN feeds f ON p.feed_id = f.id WHERE p.id = $1::uuid`, productID).Scan(&id, &title, &slug, &desc, &shortDesc, &shortDescLocked, &ean, &eanRaw, &sku, &mpn, &brand, &img, &stockStatus, &catID, &source, &feedID, &feedName, &priceMin, &priceMax, &priceMinNet, &priceMaxNet, &vatRate, &priceIsGross, &currency, &isActive, &isFeatured, &createdAt, &updatedAt, &version, &promoPrice, &promoStartsAt, &promoEndsAt, &releaseDate, &completeness, &giftText, &warrantyMonths, &extraMessage)
+	if err != nil {
+		return nil, err
+	}
 
 	imgRows, _ := h.db.Pool.Query(ctx, `SELECT id, url, COALESCE(alt,''), position, is_main FROM product_images WHERE product_id = $1::uuid ORDER BY position`, productID)
 	defer imgRows.Close()
@@ -655,7 +814,7 @@ func (h *Handlers) AdminGetProduct(c *fiber.Ctx) error {
 		images = append(images, fiber.Map{"id": imgID, "url": imgURL, "alt": imgAlt, "position": imgPos, "is_main": imgMain})
 	}
 
-	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"id": id, "title": title, "slug": slug, "description": desc, "short_description": shortDesc, "ean": ean, "sku": sku, "mpn": mpn, "brand": brand, "image_url": img, "images": images, "stock_status": stockStatus, "category_id": catID, "price_min": priceMin, "price_max": priceMax, "is_active": isActive, "is_featured": isFeatured, "created_at": createdAt, "updated_at": updatedAt}})
+	return fiber.Map{"id": id, "title": title, "slug": slug, "description": desc, "short_description": shortDesc, "short_description_locked": shortDescLocked, "ean": ean, "ean_raw": eanRaw, "sku": sku, "mpn": mpn, "brand": brand, "image_url": img, "images": images, "stock_status": stockStatus, "category_id": catID, "source": source, "feed_id": feedID, "feed_name": feedName, "price_min": priceMin, "price_max": priceMax, "price_min_net": priceMinNet, "price_max_net": priceMaxNet, "vat_rate": vatRate, "price_is_gross": priceIsGross, "currency": currency, "is_active": isActive, "is_featured": isFeatured, "created_at": createdAt, "updated_at": updatedAt, "version": version, "promo_price": promoPrice, "promo_starts_at": promoStartsAt, "promo_ends_at": promoEndsAt, "release_date": models.FormatReleaseDate(releaseDate), "completeness": completeness, "gift_text": giftText, "warranty_months": warrantyMonths, "extra_message": extraMessage, "attributes": h.productAttributes(ctx, productID), "tags": nonNilStrings(h.productTags(ctx, []string{productID})[productID])}, nil
 }
 
 func (h *Handlers) AdminCreateProduct(c *fiber.Ctx) error {
@@ -670,10 +829,15 @@ func (h *Handlers) AdminCreateProduct(c *fiber.Ctx) error {
 		Brand            string  `json:"brand_name"`
 		CategoryID       string  `json:"category_id"`
 		ImageURL         string  `json:"image_url"`
-		PriceMin         float64 `json:"price_min"`
-		PriceMax         float64 `json:"price_max"`
+		PriceMin         money.Price `json:"price_min"`
+		PriceMax         money.Price `json:"price_max"`
 		StockStatus      string  `json:"stock_status"`
+		// ReleaseDate (YYYY-MM-DD) is required when stock_status is "preorder"
+		ReleaseDate string `json:"release_date"`
 		IsActive         bool    `json:"is_active"`
+		// Prices are taken as VAT-inclusive at 20 % unless stated otherwise
+		VATRate      *float64 `json:"vat_rate"`
+		PriceIsGross *bool    `json:"price_is_gross"`
 	}
 	if err := c.BodyParser(&input); err != nil {
 		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
@@ -682,14 +846,39 @@ func (h *Handlers) AdminCreateProduct(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Title required"})
 	}
 	if input.Slug == "" {
-		input.Slug = makeSlug(input.Title)
+		input.Slug = input.Title
 	}
+	input.Slug = makeSlug(input.Slug)
 	if input.StockStatus == "" {
 		input.StockStatus = "instock"
 	}
-	if input.PriceMax == 0 && input.PriceMin > 0 {
-		input.PriceMax = input.PriceMin
+	var msg string
+	if input.PriceMin, input.PriceMax, msg = priceRange(input.PriceMin, input.PriceMax); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+	releaseDate, msg := releaseDateInput(input.ReleaseDate, input.StockStatus)
+	if msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+
+	if input.CategoryID != "" && !isUUID(input.CategoryID) {
+		return invalidUUIDField(c, "category_id")
+	}
+	vatRate, priceIsGross := defaultVATRate, true
+	if input.VATRate != nil {
+		vatRate = *input.VATRate
+	}
+	if input.PriceIsGross != nil {
+		priceIsGross = *input.PriceIsGross
+	}
+	if vatRate < 0 || vatRate > 100 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "vat_rate must be between 0 and 100"})
 	}
+	grossMin, netMin := splitPrice(input.PriceMin.Float(), vatRate, priceIsGross)
+	grossMax, netMax := splitPrice(input.PriceMax.Float(), vatRate, priceIsGross)
+
+	plain, excerpt := descriptionVariants(input.Description)
+	ean, eanRaw := splitEAN(input.EAN)
 
 	ctx := context.Background()
 	productID := uuid.New()
@@ -698,15 +887,20 @@ func (h *Handlers) AdminCreateProduct(c *fiber.Ctx) error {
 		catID = input.CategoryID
 	}
 
-	_, err := h.db.Pool.Exec(ctx, `INSERT INTO products (id, category_id, title, slug, description, short_description, ean, sku, mpn, brand, image_url, price_min, price_max, stock_status, is_active, created_at, updated_at) VALUES ($1, $2::uuid, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NOW(), NOW())`, productID, catID, input.Title, input.Slug, input.Description, input.ShortDescription, input.EAN, input.SKU, input.MPN, input.Brand, input.ImageURL, input.PriceMin, input.PriceMax, input.StockStatus, input.IsActive)
+	_, err := h.db.Pool.Exec(ctx, `INSERT INTO products (id, category_id, title, slug, description, short_description, short_description_locked, ean, sku, mpn, brand, image_url, price_min, price_max, price_min_net, price_max_net, vat_rate, price_is_gross, stock_status, is_active, source, price_high, description_plain, excerpt, ean_raw, release_date, created_at, updated_at) VALUES ($1, $2::uuid, $3, $4, $5, $6, $6 <> '', $7, $8, $9, $10, $11, $12, $13, $16, $17, $18, $19, $14, $15, 'admin', $12, $20, $21, NULLIF($22,''), $23, NOW(), NOW())`, productID, catID, input.Title, input.Slug, input.Description, input.ShortDescription, ean, input.SKU, input.MPN, input.Brand, input.ImageURL, grossMin, grossMax, input.StockStatus, input.IsActive, netMin, netMax, vatRate, priceIsGross, plain, excerpt, eanRaw, releaseDate)
+	if isDuplicateEAN(err) {
+		return duplicateEAN(c)
+	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
 	}
 
 	if input.CategoryID != "" {
-		h.db.Pool.Exec(ctx, `UPDATE categories SET product_count = (SELECT COUNT(*) FROM products WHERE category_id = $1::uuid AND is_active=true) WHERE id = $1::uuid`, input.CategoryID)
+		h.recountCategories(ctx, input.CategoryID)
 	}
 
+	h.refreshProductCompleteness(ctx, productID.String())
+	h.queueESSync(productID.String())
 	return c.Status(201).JSON(fiber.Map{"success": true, "data": fiber.Map{"id": productID.String(), "slug": input.Slug}})
 }
 
@@ -723,27 +917,121 @@ func (h *Handlers) AdminUpdateProduct(c *fiber.Ctx) error {
 		Brand            string  `json:"brand_name"`
 		CategoryID       string  `json:"category_id"`
 		ImageURL         string  `json:"image_url"`
-		PriceMin         float64 `json:"price_min"`
-		PriceMax         float64 `json:"price_max"`
+		PriceMin         money.Price `json:"price_min"`
+		PriceMax         money.Price `json:"price_max"`
 		StockStatus      string  `json:"stock_status"`
+		// ReleaseDate (YYYY-MM-DD) is required when stock_status is "preorder"
+		ReleaseDate string `json:"release_date"`
 		IsActive         bool    `json:"is_active"`
+		// VAT fields left out keep the product's current values
+		VATRate      *float64 `json:"vat_rate"`
+		PriceIsGross *bool    `json:"price_is_gross"`
+		// Version is the edit version last read; the If-Match header takes precedence
+		Version *int `json:"version"`
+		// Attributes is applied when present: "replace" (default) swaps the whole set,
+		// "patch" upserts by name and honours delete markers
+		Attributes     *[]attributeEdit `json:"attributes"`
+		AttributesMode string           `json:"attributes_mode"`
+		// ShortDescriptionLocked keeps the short description from being generated; a
+		// changed short description locks it, false hands it back to the generator
+		ShortDescriptionLocked *bool `json:"short_description_locked"`
 	}
 	if err := c.BodyParser(&input); err != nil {
 		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
 	}
-
+	expectedVersion, ok := requestedVersion(c, input.Version)
+	if !ok {
+		return c.Status(428).JSON(fiber.Map{"success": false, "error": "If-Match header or version field required"})
+	}
+	if input.CategoryID != "" && !isUUID(input.CategoryID) {
+		return invalidUUIDField(c, "category_id")
+	}
+	var msg string
+	if input.PriceMin, input.PriceMax, msg = priceRange(input.PriceMin, input.PriceMax); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+	releaseDate, msg := releaseDateInput(input.ReleaseDate, input.StockStatus)
+	if msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+	if input.Attributes != nil {
+		if input.AttributesMode == "" {
+			input.AttributesMode = "replace"
+		}
+		if msg := validateAttributeEdits(input.AttributesMode, *input.Attributes); msg != "" {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+		}
+	}
 	ctx := context.Background()
+	// omitted VAT fields keep the product's stored ones; the version check below
+	// rejects the update if they change in between
+	vatRate, priceIsGross := defaultVATRate, true
+	if input.VATRate == nil || input.PriceIsGross == nil {
+		err := h.db.Pool.QueryRow(ctx, "SELECT COALESCE(vat_rate, $2), COALESCE(price_is_gross, true) FROM products WHERE id = $1::uuid", productID, defaultVATRate).Scan(&vatRate, &priceIsGross)
+		if err == pgx.ErrNoRows {
+			return c.Status(404).JSON(fiber.Map{"success": false, "error": "Product not found"})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+		}
+	}
+	if input.VATRate != nil {
+		vatRate = *input.VATRate
+	}
+	if input.PriceIsGross != nil {
+		priceIsGross = *input.PriceIsGross
+	}
+	if vatRate < 0 || vatRate > 100 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "vat_rate must be between 0 and 100"})
+	}
+	grossMin, netMin := splitPrice(input.PriceMin.Float(), vatRate, priceIsGross)
+	grossMax, netMax := splitPrice(input.PriceMax.Float(), vatRate, priceIsGross)
+	if input.Slug != "" {
+		input.Slug = makeSlug(input.Slug)
+	}
+	plain, excerpt := descriptionVariants(input.Description)
+	ean, eanRaw := splitEAN(input.EAN)
+
 	var catID interface{} = nil
 	if input.CategoryID != "" {
 		catID = input.CategoryID
 	}
 
-	_, err := h.db.Pool.Exec(ctx, `UPDATE products SET category_id = $2::uuid, title = COALESCE(NULLIF($3,''), title), slug = COALESCE(NULLIF($4,''), slug), description = $5, short_description = $6, ean = $7, sku = $8, mpn = $9, brand = $10, image_url = $11, price_min = $12, price_max = $13, stock_status = $14, is_active = $15, updated_at = NOW() WHERE id = $1::uuid`, productID, catID, input.Title, input.Slug, input.Description, input.ShortDescription, input.EAN, input.SKU, input.MPN, input.Brand, input.ImageURL, input.PriceMin, input.PriceMax, input.StockStatus, input.IsActive)
+	var oldStatus, newStatus, oldSlug, newSlug string
+	var newVersion int
+	err := h.db.Pool.QueryRow(ctx, `UPDATE products p SET category_id = $2::uuid, title = COALESCE(NULLIF($3,''), title), slug = COALESCE(NULLIF($4,''), slug), description = $5, description_plain = $21, excerpt = $22, short_description = $6, short_description_locked = COALESCE($25::boolean, p.short_description_locked OR COALESCE(p.short_description,'') <> $6), short_description_generated = CASE WHEN $25::boolean = false THEN true ELSE p.short_description_generated AND COALESCE(p.short_description,'') = $6 END, ean = $7, ean_raw = NULLIF($23,''), release_date = $24, sku = $8, mpn = $9, brand = $10, image_url = $11, price_min = $12, price_max = $13, price_high = GREATEST(COALESCE(p.price_high,0), $12), price_min_net = $16, price_max_net = $17, vat_rate = $18, price_is_gross = $19, stock_status = $14, is_active = $15, updated_at = NOW(), version = o.version + 1 FROM (SELECT id, slug AS old_slug, COALESCE(stock_status,'instock') AS old_status, COALESCE(version,1) AS version FROM products WHERE id = $1::uuid FOR UPDATE) o WHERE p.id = o.id AND o.version = $20 RETURNING o.old_status, COALESCE(p.stock_status,'instock'), p.version, COALESCE(o.old_slug,''), COALESCE(p.slug,'')`, productID, catID, input.Title, input.Slug, input.Description, input.ShortDescription, ean, input.SKU, input.MPN, input.Brand, input.ImageURL, grossMin, grossMax, input.StockStatus, input.IsActive, netMin, netMax, vatRate, priceIsGross, expectedVersion, plain, excerpt, eanRaw, releaseDate, input.ShortDescriptionLocked).Scan(&oldStatus, &newStatus, &newVersion, &oldSlug, &newSlug)
+	if err == pgx.ErrNoRows {
+		// either the product is gone or someone saved it since it was read
+		current, err := h.adminProduct(ctx, productID)
+		if err != nil {
+			return c.Status(404).JSON(fiber.Map{"success": false, "error": "Product not found"})
+		}
+		c.Set(fiber.HeaderETag, versionETag(current["version"].(int)))
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "Product was modified by someone else", "data": current})
+	}
+	if isDuplicateEAN(err) {
+		return duplicateEAN(c)
+	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
 	}
 
-	return c.JSON(fiber.Map{"success": true, "message": "Product updated"})
+	if input.Attributes != nil {
+		if err := h.applyAttributeEdits(ctx, productID, input.AttributesMode, *input.Attributes); err != nil {
+			return c.Status(500).JSON(fiber.Map{"success": false, "error": "Product saved but attributes failed: " + err.Error()})
+		}
+		h.generateShortDescription(ctx, productID)
+	}
+	h.recordSlugRedirect(ctx, "product", productID, oldSlug, newSlug)
+	h.refreshProductCompleteness(ctx, productID)
+	if isBackInStock(oldStatus, newStatus) {
+		h.fireStockAlerts(ctx, []string{productID})
+	}
+	h.queueESSync(productID)
+	go h.checkPriceAlerts(context.Background())
+
+	c.Set(fiber.HeaderETag, versionETag(newVersion))
+	return c.JSON(fiber.Map{"success": true, "message": "Product updated", "data": fiber.Map{"version": newVersion}})
 }
 
 func (h *Handlers) AdminDeleteProduct(c *fiber.Ctx) error {
@@ -755,9 +1043,7 @@ func (h *Handlers) AdminDeleteProduct(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
 	}
-	if h.es != nil {
-		h.es.DeleteProduct(productID)
-	}
+	h.queueESDelete(productID)
 	return c.JSON(fiber.Map{"success": true, "message": "Product deleted"})
 }
 
@@ -770,7 +1056,7 @@ func (h *Handlers) DeleteAllProducts(c *fiber.Ctx) error {
 	h.db.Pool.Exec(ctx, "DELETE FROM product_images")
 	h.db.Pool.Exec(ctx, "DELETE FROM product_attributes")
 	h.db.Pool.Exec(ctx, "DELETE FROM products")
-	h.db.Pool.Exec(ctx, "UPDATE categories SET product_count = 0")
+	h.db.Pool.Exec(ctx, "UPDATE categories SET product_count = 0, in_stock_count = 0")
 
 	os.RemoveAll("./uploads/products")
 	os.MkdirAll("./uploads/products", 0755)
@@ -789,19 +1075,39 @@ func (h *Handlers) DeleteAllCategories(c *fiber.Ctx) error {
 	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM categories").Scan(&count)
 	h.db.Pool.Exec(ctx, "UPDATE products SET category_id = NULL")
 	h.db.Pool.Exec(ctx, "DELETE FROM categories")
+	os.RemoveAll(categoryIconDir)
 	return c.JSON(fiber.Map{"success": true, "message": fmt.Sprintf("Deleted %d categories", count), "count": count})
 }
 
+// BulkDeleteProducts applies action to the listed ids or, when tag is given instead,
+// to every product carrying that internal tag
 func (h *Handlers) BulkDeleteProducts(c *fiber.Ctx) error {
 	var input struct {
 		IDs    []string `json:"ids"`
+		Tag    string   `json:"tag"`
 		Action string   `json:"action"`
 	}
 	if err := c.BodyParser(&input); err != nil {
 		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
 	}
+	for _, id := range input.IDs {
+		if !isUUID(id) {
+			return invalidUUIDField(c, "ids")
+		}
+	}
 
 	ctx := context.Background()
+	if input.Tag != "" {
+		if len(input.IDs) > 0 {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "Provide either ids or tag, not both"})
+		}
+		ids, err := h.filteredProductIDs(ctx, tagFilter{Tag: input.Tag})
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+		}
+		input.IDs = ids
+		h.audit(ctx, c, "product.bulk_"+input.Action, "tag", "", fiber.Map{"tag": input.Tag, "products": len(ids)})
+	}
 
 	switch input.Action {
 	case "delete":
@@ -809,39 +1115,48 @@ func (h *Handlers) BulkDeleteProducts(c *fiber.Ctx) error {
 			h.db.Pool.Exec(ctx, "DELETE FROM product_images WHERE product_id = $1::uuid", id)
 			h.db.Pool.Exec(ctx, "DELETE FROM product_attributes WHERE product_id = $1::uuid", id)
 			h.db.Pool.Exec(ctx, "DELETE FROM products WHERE id = $1::uuid", id)
-			if h.es != nil {
-				h.es.DeleteProduct(id)
-			}
 		}
+		h.queueESDelete(input.IDs...)
 	case "activate":
-		for _, id := range input.IDs {
-			h.db.Pool.Exec(ctx, "UPDATE products SET is_active = true WHERE id = $1::uuid", id)
-		}
+		h.db.Pool.Exec(ctx, "UPDATE products SET is_active = true, updated_at = NOW(), version = COALESCE(version,1) + 1 WHERE id = ANY($1::uuid[])", input.IDs)
 	case "deactivate":
-		for _, id := range input.IDs {
-			h.db.Pool.Exec(ctx, "UPDATE products SET is_active = false WHERE id = $1::uuid", id)
+		h.db.Pool.Exec(ctx, "UPDATE products SET is_active = false, updated_at = NOW(), version = COALESCE(version,1) + 1 WHERE id = ANY($1::uuid[])", input.IDs)
+	case "instock", "outofstock":
+		if err := h.setStockStatus(ctx, input.IDs, input.Action); err != nil {
+			return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
 		}
 	}
+	if input.Action != "delete" {
+		h.queueESSync(input.IDs...)
+	}
 
 	return c.JSON(fiber.Map{"success": true, "message": fmt.Sprintf("Processed %d products", len(input.IDs))})
 }
 
+// AdminCategories lists all categories; ?search=, ?is_active= or ?created_from_feed=
+// switch to the paginated search with ancestor paths
 func (h *Handlers) AdminCategories(c *fiber.Ctx) error {
+	if c.Query("search") != "" || c.Query("is_active") != "" || c.Query("created_from_feed") != "" {
+		return h.adminSearchCategories(c)
+	}
 	ctx := context.Background()
-	rows, _ := h.db.Pool.Query(ctx, `SELECT id, COALESCE(parent_id::text,''), name, slug, COALESCE(icon,''), product_count, is_active FROM categories ORDER BY sort_order, name`)
+	rows, _ := h.db.Pool.Query(ctx, `SELECT id, COALESCE(parent_id::text,''), name, slug, COALESCE(icon,''), COALESCE(icon_url,''), product_count, is_active, COALESCE(review_status,''), out_of_stock_policy FROM categories WHERE review_status IS DISTINCT FROM 'merged' ORDER BY sort_order, name`)
 	defer rows.Close()
 
+	policies := h.outOfStockPolicies(ctx)
 	var cats []fiber.Map
 	for rows.Next() {
-		var id, parentID, name, slug, icon string
+		var id, parentID, name, slug, icon, iconURL, reviewStatus, stockPolicy string
 		var productCount int
 		var isActive bool
-		rows.Scan(&id, &parentID, &name, &slug, &icon, &productCount, &isActive)
-		cats = append(cats, fiber.Map{"id": id, "parent_id": parentID, "name": name, "slug": slug, "icon": icon, "product_count": productCount, "is_active": isActive})
+		rows.Scan(&id, &parentID, &name, &slug, &icon, &iconURL, &productCount, &isActive, &reviewStatus, &stockPolicy)
+		cats = append(cats, fiber.Map{"id": id, "parent_id": parentID, "name": name, "slug": slug, "icon": icon, "icon_url": iconURL, "product_count": productCount, "is_active": isActive, "review_status": reviewStatus,
+			"out_of_stock_policy": stockPolicy, "effective_out_of_stock_policy": policies.policyOf(id)})
 	}
 	if cats == nil {
 		cats = []fiber.Map{}
 	}
+	h.attachCategoryCounts(ctx, cats)
 	return c.JSON(fiber.Map{"success": true, "data": cats})
 }
 
@@ -852,6 +1167,8 @@ func (h *Handlers) AdminCreateCategory(c *fiber.Ctx) error {
 		Slug        string `json:"slug"`
 		Description string `json:"description"`
 		Icon        string `json:"icon"`
+		// OutOfStockPolicy is inherit, hide, demote or show; empty inherits
+		OutOfStockPolicy string `json:"out_of_stock_policy"`
 	}
 	if err := c.BodyParser(&input); err != nil {
 		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
@@ -860,20 +1177,34 @@ func (h *Handlers) AdminCreateCategory(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Name required"})
 	}
 	if input.Slug == "" {
-		input.Slug = makeSlug(input.Name)
+		input.Slug = input.Name
+	}
+	input.Slug = makeSlug(input.Slug)
+	if input.ParentID != "" && !isUUID(input.ParentID) {
+		return invalidUUIDField(c, "parent_id")
+	}
+	if !validCategoryIcon(input.Icon) {
+		return invalidCategoryIcon(c)
+	}
+	if input.OutOfStockPolicy == "" {
+		input.OutOfStockPolicy = stockPolicyInherit
+	}
+	if !validStockPolicy(input.OutOfStockPolicy) {
+		return invalidStockPolicy(c)
 	}
 
 	ctx := context.Background()
 	id := uuid.New()
 	var err error
 	if input.ParentID != "" {
-		_, err = h.db.Pool.Exec(ctx, `INSERT INTO categories (id, parent_id, name, slug, description, icon, is_active, created_at, updated_at) VALUES ($1, $2::uuid, $3, $4, $5, $6, true, NOW(), NOW())`, id, input.ParentID, input.Name, input.Slug, input.Description, input.Icon)
+		_, err = h.db.Pool.Exec(ctx, `INSERT INTO categories (id, parent_id, name, slug, description, icon, out_of_stock_policy, is_active, created_at, updated_at) VALUES ($1, $2::uuid, $3, $4, $5, $6, $7, true, NOW(), NOW())`, id, input.ParentID, input.Name, input.Slug, input.Description, input.Icon, input.OutOfStockPolicy)
 	} else {
-		_, err = h.db.Pool.Exec(ctx, `INSERT INTO categories (id, name, slug, description, icon, is_active, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, true, NOW(), NOW())`, id, input.Name, input.Slug, input.Description, input.Icon)
+		_, err = h.db.Pool.Exec(ctx, `INSERT INTO categories (id, name, slug, description, icon, out_of_stock_policy, is_active, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, true, NOW(), NOW())`, id, input.Name, input.Slug, input.Description, input.Icon, input.OutOfStockPolicy)
 	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
 	}
+	invalidateStockPolicies()
 	return c.Status(201).JSON(fiber.Map{"success": true, "data": fiber.Map{"id": id.String(), "slug": input.Slug}})
 }
 
@@ -886,32 +1217,58 @@ func (h *Handlers) AdminUpdateCategory(c *fiber.Ctx) error {
 		Description string `json:"description"`
 		Icon        string `json:"icon"`
 		IsActive    bool   `json:"is_active"`
+		// OutOfStockPolicy is inherit, hide, demote or show; empty keeps the current one
+		OutOfStockPolicy string `json:"out_of_stock_policy"`
 	}
 	if err := c.BodyParser(&input); err != nil {
 		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
 	}
+	if input.ParentID != "" && !isUUID(input.ParentID) {
+		return invalidUUIDField(c, "parent_id")
+	}
+	if input.OutOfStockPolicy != "" && !validStockPolicy(input.OutOfStockPolicy) {
+		return invalidStockPolicy(c)
+	}
+	if input.Slug != "" {
+		input.Slug = makeSlug(input.Slug)
+	}
 
 	ctx := context.Background()
+	var oldSlug, oldIcon string
+	h.db.Pool.QueryRow(ctx, "SELECT slug, COALESCE(icon,'') FROM categories WHERE id = $1::uuid", categoryID).Scan(&oldSlug, &oldIcon)
+	// a legacy icon may be sent back unchanged; it is listed by /admin/categories/icons/invalid
+	if input.Icon != oldIcon && !validCategoryIcon(input.Icon) {
+		return invalidCategoryIcon(c)
+	}
 	var err error
 	if input.ParentID != "" {
-		_, err = h.db.Pool.Exec(ctx, `UPDATE categories SET parent_id = $2::uuid, name = COALESCE(NULLIF($3,''), name), slug = COALESCE(NULLIF($4,''), slug), description = $5, icon = $6, is_active = $7, updated_at = NOW() WHERE id = $1::uuid`, categoryID, input.ParentID, input.Name, input.Slug, input.Description, input.Icon, input.IsActive)
+		_, err = h.db.Pool.Exec(ctx, `UPDATE categories SET parent_id = $2::uuid, name = COALESCE(NULLIF($3,''), name), slug = COALESCE(NULLIF($4,''), slug), description = $5, icon = $6, is_active = $7, review_status = CASE WHEN $7 AND review_status = 'pending' THEN 'approved' ELSE review_status END, out_of_stock_policy = COALESCE(NULLIF($8,''), out_of_stock_policy), curated_at = NOW(), updated_at = NOW() WHERE id = $1::uuid`, categoryID, input.ParentID, input.Name, input.Slug, input.Description, input.Icon, input.IsActive, input.OutOfStockPolicy)
 	} else {
-		_, err = h.db.Pool.Exec(ctx, `UPDATE categories SET parent_id = NULL, name = COALESCE(NULLIF($2,''), name), slug = COALESCE(NULLIF($3,''), slug), description = $4, icon = $5, is_active = $6, updated_at = NOW() WHERE id = $1::uuid`, categoryID, input.Name, input.Slug, input.Description, input.Icon, input.IsActive)
+		_, err = h.db.Pool.Exec(ctx, `UPDATE categories SET parent_id = NULL, name = COALESCE(NULLIF($2,''), name), slug = COALESCE(NULLIF($3,''), slug), description = $4, icon = $5, is_active = $6, review_status = CASE WHEN $6 AND review_status = 'pending' THEN 'approved' ELSE review_status END, out_of_stock_policy = COALESCE(NULLIF($7,''), out_of_stock_policy), curated_at = NOW(), updated_at = NOW() WHERE id = $1::uuid`, categoryID, input.Name, input.Slug, input.Description, input.Icon, input.IsActive, input.OutOfStockPolicy)
 	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
 	}
+	// a new policy or parent changes what the category and its subtree inherit
+	invalidateStockPolicies()
+	if input.Slug != "" {
+		h.recordSlugRedirect(ctx, "category", categoryID, oldSlug, input.Slug)
+	}
 	return c.JSON(fiber.Map{"success": true, "message": "Category updated"})
 }
 
 func (h *Handlers) AdminDeleteCategory(c *fiber.Ctx) error {
 	categoryID := c.Params("id")
 	ctx := context.Background()
+	var iconURL string
+	h.db.Pool.QueryRow(ctx, "SELECT COALESCE(icon_url,'') FROM categories WHERE id = $1::uuid", categoryID).Scan(&iconURL)
 	h.db.Pool.Exec(ctx, "UPDATE categories SET parent_id = NULL WHERE parent_id = $1::uuid", categoryID)
 	_, err := h.db.Pool.Exec(ctx, "DELETE FROM categories WHERE id = $1::uuid", categoryID)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
 	}
+	removeCategoryIconFile(iconURL)
+	invalidateStockPolicies()
 	return c.JSON(fiber.Map{"success": true, "message": "Category deleted"})
 }
 
@@ -920,17 +1277,11 @@ func (h *Handlers) UploadImage(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"success": false, "error": "No file uploaded"})
 	}
-	uploadDir := "./uploads"
-	os.MkdirAll(uploadDir, 0755)
-	ext := filepath.Ext(file.Filename)
-	filename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
-	fpath := fmt.Sprintf("%s/%s", uploadDir, filename)
-	if err := c.SaveFile(file, fpath); err != nil {
-		return c.Status(500).JSON(fiber.Map{"success": false, "error": "Failed to save file"})
+	res, err := saveImageUpload(file, c.BaseURL())
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": err.Error()})
 	}
-	baseURL := c.BaseURL()
-	url := fmt.Sprintf("%s/uploads/%s", baseURL, filename)
-	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"url": url, "filename": filename}})
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"url": res.URL, "filename": res.Filename, "width": res.Width, "height": res.Height}})
 }
 
 func (h *Handlers) GetAttributeValues(c *fiber.Ctx) error {
@@ -974,7 +1325,7 @@ func (h *Handlers) GetAttributeValues(c *fiber.Ctx) error {
 		args = []interface{}{attrName}
 	}
 	
-	rows, _ := h.db.Pool.Query(ctx, query, args...)
+	rows, _ := h.db.ReadPool.Query(ctx, query, args...)
 	defer rows.Close()
 	
 	var values []fiber.Map
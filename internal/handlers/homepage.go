@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== HOMEPAGE BLOCKS ==========
+
+const (
+	homepageCacheTTL     = 60 * time.Second
+	defaultBlockProducts = 12
+	maxBlockProducts     = 48
+	maxGridCategories    = 24
+)
+
+var homepageBlockTypes = []string{"product_list", "category_grid", "banner"}
+
+// productListConfig selects products by explicit IDs, a category subtree or the featured flag
+type productListConfig struct {
+	ProductIDs []string `json:"product_ids,omitempty"`
+	CategoryID string   `json:"category_id,omitempty"`
+	Featured   bool     `json:"featured,omitempty"`
+	Sort       string   `json:"sort,omitempty"`
+	Limit      int      `json:"limit,omitempty"`
+}
+
+// categoryGridConfig lists explicit categories or the children of a parent
+type categoryGridConfig struct {
+	CategoryIDs []string `json:"category_ids,omitempty"`
+	ParentID    string   `json:"parent_id,omitempty"`
+}
+
+type bannerConfig struct {
+	ImageURL string `json:"image_url"`
+	LinkURL  string `json:"link_url,omitempty"`
+	Alt      string `json:"alt,omitempty"`
+}
+
+// decodeStrict rejects config keys that do not belong to the block type
+func decodeStrict(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		raw = json.RawMessage("{}")
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// validateBlockConfig checks config against the schema of blockType and returns it normalized
+func validateBlockConfig(blockType string, raw json.RawMessage) (json.RawMessage, string) {
+	var cfg interface{}
+	switch blockType {
+	case "product_list":
+		var pl productListConfig
+		if err := decodeStrict(raw, &pl); err != nil {
+			return nil, "Invalid product_list config: " + err.Error()
+		}
+		sources := 0
+		for _, set := range []bool{len(pl.ProductIDs) > 0, pl.CategoryID != "", pl.Featured} {
+			if set {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return nil, "product_list config needs exactly one of product_ids, category_id or featured"
+		}
+		if len(pl.ProductIDs) > maxBlockProducts {
+			return nil, fmt.Sprintf("product_list accepts at most %d product_ids", maxBlockProducts)
+		}
+		for _, id := range pl.ProductIDs {
+			if !isUUID(id) {
+				return nil, "Invalid product_ids: must be UUIDs"
+			}
+		}
+		if pl.CategoryID != "" && !isUUID(pl.CategoryID) {
+			return nil, "Invalid category_id: must be a UUID"
+		}
+		if pl.Sort != "" && !containsString(listingSorts, pl.Sort) {
+			return nil, "sort must be one of " + strings.Join(listingSorts, ", ")
+		}
+		if pl.Limit < 0 || pl.Limit > maxBlockProducts {
+			return nil, fmt.Sprintf("limit must be between 1 and %d", maxBlockProducts)
+		}
+		cfg = pl
+	case "category_grid":
+		var cg categoryGridConfig
+		if err := decodeStrict(raw, &cg); err != nil {
+			return nil, "Invalid category_grid config: " + err.Error()
+		}
+		if (len(cg.CategoryIDs) > 0) == (cg.ParentID != "") {
+			return nil, "category_grid config needs exactly one of category_ids or parent_id"
+		}
+		if len(cg.CategoryIDs) > maxGridCategories {
+			return nil, fmt.Sprintf("category_grid accepts at most %d category_ids", maxGridCategories)
+		}
+		for _, id := range append(cg.CategoryIDs, cg.ParentID) {
+			if id != "" && !isUUID(id) {
+				return nil, "Invalid category ids: must be UUIDs"
+			}
+		}
+		cfg = cg
+	case "banner":
+		var b bannerConfig
+		if err := decodeStrict(raw, &b); err != nil {
+			return nil, "Invalid banner config: " + err.Error()
+		}
+		if !validMediaURL(b.ImageURL) {
+			return nil, "banner image_url must be an absolute http(s) URL"
+		}
+		if b.LinkURL != "" && !strings.HasPrefix(b.LinkURL, "/") && !validMediaURL(b.LinkURL) {
+			return nil, "banner link_url must be a site path or an absolute http(s) URL"
+		}
+		cfg = b
+	default:
+		return nil, "type must be product_list, category_grid or banner"
+	}
+	normalized, _ := json.Marshal(cfg)
+	return normalized, ""
+}
+
+type HomepageBlock struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Title     string          `json:"title"`
+	Config    json.RawMessage `json:"config"`
+	Position  int             `json:"position"`
+	IsActive  bool            `json:"is_active"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+func (h *Handlers) queryHomepageBlocks(ctx context.Context, activeOnly bool) ([]HomepageBlock, error) {
+	query := `SELECT id, type, COALESCE(title,''), config::text, position, is_active, created_at, updated_at FROM homepage_blocks`
+	if activeOnly {
+		query += " WHERE is_active = true"
+	}
+	rows, err := h.db.Pool.Query(ctx, query+" ORDER BY position, created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	blocks := []HomepageBlock{}
+	for rows.Next() {
+		var b HomepageBlock
+		var config string
+		rows.Scan(&b.ID, &b.Type, &b.Title, &config, &b.Position, &b.IsActive, &b.CreatedAt, &b.UpdatedAt)
+		b.Config = json.RawMessage(config)
+		blocks = append(blocks, b)
+	}
+	return blocks, rows.Err()
+}
+
+// productCards loads active product cards matching a product_list config
+func (h *Handlers) productCards(ctx context.Context, cfg productListConfig, priceMode string) []models.ProductCard {
+	priceMinCol, _ := priceColumns(priceMode)
+	limit := cfg.Limit
+	if limit == 0 {
+		limit = defaultBlockProducts
+	}
+	orderBy := listingOrder(cfg.Sort, priceMinCol)
+
+	var where string
+	var arg interface{}
+	switch {
+	case len(cfg.ProductIDs) > 0:
+		where, arg = "p.id = ANY($1::uuid[])", cfg.ProductIDs
+		if cfg.Sort == "" {
+			orderBy = "array_position($1::uuid[], p.id)"
+		}
+	case cfg.CategoryID != "":
+		where, arg = `p.category_id IN (
+			WITH RECURSIVE subcats AS (
+				SELECT id FROM categories WHERE id = $1::uuid
+				UNION ALL
+				SELECT c.id FROM categories c JOIN subcats s ON c.parent_id = s.id
+			) SELECT id FROM subcats)`, cfg.CategoryID
+	default:
+		where, arg = "p.is_featured = $1", true
+	}
+
+	rows, err := h.db.ReadPool.Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM products p LEFT JOIN categories c ON p.category_id = c.id
+		WHERE p.is_active = true AND %s
+		ORDER BY %s LIMIT $2
+	`, productCardColumns(priceMode), where, orderBy), arg, limit)
+	if err != nil {
+		return []models.ProductCard{}
+	}
+	products := scanProductCards(rows)
+	h.attachLabels(ctx, products)
+	return products
+}
+
+func (h *Handlers) gridCategories(ctx context.Context, cfg categoryGridConfig) []fiber.Map {
+	var rows pgx.Rows
+	var err error
+	if len(cfg.CategoryIDs) > 0 {
+		rows, err = h.db.ReadPool.Query(ctx, `
+			SELECT id, name, slug, COALESCE(icon,''), COALESCE(icon_url,''), COALESCE(image_url,''), product_count FROM categories
+			WHERE id = ANY($1::uuid[]) AND is_active = true ORDER BY array_position($1::uuid[], id)`, cfg.CategoryIDs)
+	} else {
+		rows, err = h.db.ReadPool.Query(ctx, `
+			SELECT id, name, slug, COALESCE(icon,''), COALESCE(icon_url,''), COALESCE(image_url,''), product_count FROM categories
+			WHERE parent_id = $1::uuid AND is_active = true ORDER BY sort_order, name LIMIT $2`, cfg.ParentID, maxGridCategories)
+	}
+	categories := []fiber.Map{}
+	if err != nil {
+		return categories
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, name, slug, icon, iconURL, image string
+		var count int
+		rows.Scan(&id, &name, &slug, &icon, &iconURL, &image, &count)
+		categories = append(categories, fiber.Map{"id": id, "name": name, "slug": slug, "icon": icon, "icon_url": iconURL, "image_url": image, "product_count": count})
+	}
+	return categories
+}
+
+type cachedHomepage struct {
+	blocks []fiber.Map
+	at     time.Time
+}
+
+var (
+	homepageMutex sync.Mutex
+	homepageCache = map[string]cachedHomepage{}
+)
+
+func invalidateHomepage() {
+	homepageMutex.Lock()
+	homepageCache = map[string]cachedHomepage{}
+	homepageMutex.Unlock()
+}
+
+// GetHomepage returns every active block resolved to cards, cached briefly per price mode
+func (h *Handlers) GetHomepage(c *fiber.Ctx) error {
+	priceMode, ok := priceModeParam(c)
+	if !ok {
+		return invalidPriceMode(c)
+	}
+
+	homepageMutex.Lock()
+	cached, hit := homepageCache[priceMode]
+	homepageMutex.Unlock()
+	if hit && time.Since(cached.at) < homepageCacheTTL {
+		c.Set("X-Cache", "HIT")
+		return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"blocks": cached.blocks, "price_mode": priceMode}})
+	}
+
+	ctx := context.Background()
+	blocks, err := h.queryHomepageBlocks(ctx, true)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	resolved := []fiber.Map{}
+	for _, b := range blocks {
+		item := fiber.Map{"id": b.ID, "type": b.Type, "title": b.Title}
+		switch b.Type {
+		case "product_list":
+			var cfg productListConfig
+			json.Unmarshal(b.Config, &cfg)
+			products := h.productCards(ctx, cfg, priceMode)
+			if len(products) == 0 {
+				continue
+			}
+			item["products"] = products
+		case "category_grid":
+			var cfg categoryGridConfig
+			json.Unmarshal(b.Config, &cfg)
+			categories := h.gridCategories(ctx, cfg)
+			if len(categories) == 0 {
+				continue
+			}
+			item["categories"] = categories
+		case "banner":
+			var cfg bannerConfig
+			json.Unmarshal(b.Config, &cfg)
+			item["banner"] = cfg
+		}
+		resolved = append(resolved, item)
+	}
+
+	homepageMutex.Lock()
+	homepageCache[priceMode] = cachedHomepage{blocks: resolved, at: time.Now()}
+	homepageMutex.Unlock()
+
+	c.Set("X-Cache", "MISS")
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"blocks": resolved, "price_mode": priceMode}})
+}
+
+type homepageBlockInput struct {
+	Type     string          `json:"type"`
+	Title    string          `json:"title"`
+	Config   json.RawMessage `json:"config"`
+	Position *int            `json:"position"`
+	IsActive *bool           `json:"is_active"`
+}
+
+func (h *Handlers) AdminHomepageBlocks(c *fiber.Ctx) error {
+	blocks, err := h.queryHomepageBlocks(context.Background(), false)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": blocks})
+}
+
+func (h *Handlers) AdminCreateHomepageBlock(c *fiber.Ctx) error {
+	var input homepageBlockInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	config, msg := validateBlockConfig(input.Type, input.Config)
+	if msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+	isActive := input.IsActive == nil || *input.IsActive
+
+	ctx := context.Background()
+	var id string
+	err := h.db.Pool.QueryRow(ctx, `
+		INSERT INTO homepage_blocks (type, title, config, position, is_active, created_at, updated_at)
+		VALUES ($1, NULLIF($2,''), $3::jsonb, COALESCE($4, (SELECT COALESCE(MAX(position) + 1, 0) FROM homepage_blocks)), $5, NOW(), NOW())
+		RETURNING id
+	`, input.Type, strings.TrimSpace(input.Title), string(config), input.Position, isActive).Scan(&id)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	invalidateHomepage()
+	return c.Status(201).JSON(fiber.Map{"success": true, "data": fiber.Map{"id": id}})
+}
+
+func (h *Handlers) AdminUpdateHomepageBlock(c *fiber.Ctx) error {
+	blockID := c.Params("id")
+	var input homepageBlockInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	ctx := context.Background()
+
+	var currentType, currentConfig string
+	err := h.db.Pool.QueryRow(ctx, "SELECT type, config::text FROM homepage_blocks WHERE id = $1::uuid", blockID).Scan(&currentType, &currentConfig)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Block not found"})
+	}
+	if input.Type == "" {
+		input.Type = currentType
+	}
+	if len(input.Config) == 0 {
+		input.Config = json.RawMessage(currentConfig)
+	}
+	config, msg := validateBlockConfig(input.Type, input.Config)
+	if msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+
+	_, err = h.db.Pool.Exec(ctx, `
+		UPDATE homepage_blocks SET type = $2, title = COALESCE(NULLIF($3,''), title), config = $4::jsonb,
+		       position = COALESCE($5, position), is_active = COALESCE($6, is_active), updated_at = NOW()
+		WHERE id = $1::uuid
+	`, blockID, input.Type, strings.TrimSpace(input.Title), string(config), input.Position, input.IsActive)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	invalidateHomepage()
+	return c.JSON(fiber.Map{"success": true, "message": "Block updated"})
+}
+
+func (h *Handlers) AdminDeleteHomepageBlock(c *fiber.Ctx) error {
+	tag, err := h.db.Pool.Exec(context.Background(), "DELETE FROM homepage_blocks WHERE id = $1::uuid", c.Params("id"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Block not found"})
+	}
+	invalidateHomepage()
+	return c.JSON(fiber.Map{"success": true, "message": "Block deleted"})
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// ========== IDEMPOTENCY KEYS ==========
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	idempotencyTTL    = 24 * time.Hour
+	maxIdempotencyKey = 255
+)
+
+// Idempotency makes a create endpoint safe to retry. The first request with an
+// Idempotency-Key reserves the key; its response is stored and replayed for retries
+// with the same key and body within 24 hours. A different body under the same key, or
+// a retry while the first request is still running, gets 409. Server errors are not
+// stored so the request can be retried. Requests without the header pass through.
+func (h *Handlers) Idempotency() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(idempotencyHeader)
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKey {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "Idempotency-Key longer than 255 characters"})
+		}
+		sum := sha256.Sum256(c.Body())
+		bodyHash := hex.EncodeToString(sum[:])
+		route := c.Method() + " " + c.Path()
+		ctx := context.Background()
+
+		reserved, err := h.reserveIdempotencyKey(ctx, key, route, bodyHash)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+		}
+		if !reserved {
+			return h.replayIdempotent(ctx, c, key, route, bodyHash)
+		}
+
+		if err := c.Next(); err != nil {
+			h.releaseIdempotencyKey(ctx, key, route)
+			return err
+		}
+		status := c.Response().StatusCode()
+		if status >= 500 {
+			h.releaseIdempotencyKey(ctx, key, route)
+			return nil
+		}
+		if _, err := h.db.Pool.Exec(ctx, `
+			UPDATE idempotency_keys SET status_code = $3, content_type = $4, response = $5, completed_at = NOW()
+			WHERE key = $1 AND route = $2
+		`, key, route, status, string(c.Response().Header.ContentType()), c.Response().Body()); err != nil {
+			log.Printf("Idempotency key %s not stored: %v", key, err)
+		}
+		return nil
+	}
+}
+
+// reserveIdempotencyKey claims key for route, clearing an expired earlier use first.
+// It returns false when the key is already taken.
+func (h *Handlers) reserveIdempotencyKey(ctx context.Context, key, route, bodyHash string) (bool, error) {
+	if _, err := h.db.Pool.Exec(ctx, "DELETE FROM idempotency_keys WHERE key = $1 AND route = $2 AND created_at < $3", key, route, time.Now().Add(-idempotencyTTL)); err != nil {
+		return false, err
+	}
+	tag, err := h.db.Pool.Exec(ctx, `
+		INSERT INTO idempotency_keys (key, route, body_hash, created_at) VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (key, route) DO NOTHING
+	`, key, route, bodyHash)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (h *Handlers) releaseIdempotencyKey(ctx context.Context, key, route string) {
+	h.db.Pool.Exec(ctx, "DELETE FROM idempotency_keys WHERE key = $1 AND route = $2 AND completed_at IS NULL", key, route)
+}
+
+// replayIdempotent answers a retry with the stored response of the original request
+func (h *Handlers) replayIdempotent(ctx context.Context, c *fiber.Ctx, key, route, bodyHash string) error {
+	var storedHash, contentType string
+	var status *int
+	var response []byte
+	err := h.db.Pool.QueryRow(ctx, `
+		SELECT body_hash, status_code, COALESCE(content_type,''), response FROM idempotency_keys WHERE key = $1 AND route = $2
+	`, key, route).Scan(&storedHash, &status, &contentType, &response)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// the original failed and released the key between our insert and this read
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "Idempotency-Key was just released, retry the request"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if storedHash != bodyHash {
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "Idempotency-Key was already used with a different request body"})
+	}
+	if status == nil {
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "A request with this Idempotency-Key is still in progress"})
+	}
+	c.Set("Idempotent-Replayed", "true")
+	if contentType != "" {
+		c.Set(fiber.HeaderContentType, contentType)
+	}
+	return c.Status(*status).Send(response)
+}
+
+// RunIdempotencyPurgeWorker deletes keys older than the replay window
+func (h *Handlers) RunIdempotencyPurgeWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		tag, err := h.db.Pool.Exec(context.Background(), "DELETE FROM idempotency_keys WHERE created_at < $1", time.Now().Add(-idempotencyTTL))
+		if err != nil {
+			log.Printf("Idempotency key purge failed: %v", err)
+		} else if tag.RowsAffected() > 0 {
+			log.Printf("Purged %d expired idempotency keys", tag.RowsAffected())
+		}
+	}
+}
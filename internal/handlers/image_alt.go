@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/settings"
+)
+
+// ========== IMAGE ALT TEXT ==========
+
+const (
+	defaultImageAltTemplate = "{title} – obrázok {n}"
+	maxImageAltTemplate     = 200
+	maxImageAlt             = 255
+)
+
+// ImageAltSettings is the "image_alt" setting. Template fills the alt of imported
+// gallery images; {title} and {brand} come from the product, {n} numbers its images.
+type ImageAltSettings struct {
+	Template string `json:"template"`
+}
+
+var imageAltPlaceholder = regexp.MustCompile(`\{[a-z_]*\}`)
+
+func (s ImageAltSettings) validate() string {
+	if strings.TrimSpace(s.Template) == "" {
+		return "template is required"
+	}
+	if utf8.RuneCountInString(s.Template) > maxImageAltTemplate {
+		return "template must be at most " + strconv.Itoa(maxImageAltTemplate) + " characters"
+	}
+	for _, p := range imageAltPlaceholder.FindAllString(s.Template, -1) {
+		if p != "{title}" && p != "{brand}" && p != "{n}" {
+			return "unknown placeholder " + p + "; use {title}, {brand} or {n}"
+		}
+	}
+	return ""
+}
+
+// imageAltSettings returns the setting, falling back to the default template
+func (h *Handlers) imageAltSettings() ImageAltSettings {
+	var s ImageAltSettings
+	h.settings.Decode(context.Background(), settings.KeyImageAlt, &s)
+	if s.validate() != "" {
+		s = ImageAltSettings{Template: defaultImageAltTemplate}
+	}
+	return s
+}
+
+func (h *Handlers) GetImageAltSettings(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"success": true, "data": h.imageAltSettings()})
+}
+
+// SetImageAltSettings changes the template for future imports; existing alt texts stay
+func (h *Handlers) SetImageAltSettings(c *fiber.Ctx) error {
+	var input ImageAltSettings
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if msg := input.validate(); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+
+	ctx := context.Background()
+	if _, err := h.settings.SetValue(ctx, settings.KeyImageAlt, input, auditActor(c)); err != nil {
+		return settingsError(c, h.settings.Keys(), err)
+	}
+	h.audit(ctx, c, "image_alt.update", "settings", "", fiber.Map{"template": input.Template})
+
+	return c.JSON(fiber.Map{"success": true, "data": input})
+}
+
+// GetImageAltReport lists gallery images of active products whose alt text is missing
+// or repeated on another image of the same product. ?problem=missing|duplicate
+// narrows the list; ?format=csv exports it.
+func (h *Handlers) GetImageAltReport(c *fiber.Ctx) error {
+	problem := c.Query("problem")
+	if problem != "" && problem != "missing" && problem != "duplicate" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "problem must be missing or duplicate"})
+	}
+	csvExport, page, limit, offset := reportPage(c)
+	ctx := context.Background()
+
+	rows, err := h.db.Pool.Query(ctx, `
+		WITH images AS (
+			SELECT m.id, m.product_id, p.title, p.slug, m.url, COALESCE(m.alt,'') AS alt, COALESCE(m.position,0) AS position, COALESCE(m.source,'admin') AS source,
+			       CASE WHEN TRIM(COALESCE(m.alt,'')) = '' THEN 'missing'
+			            WHEN COUNT(*) OVER (PARTITION BY m.product_id, LOWER(TRIM(m.alt))) > 1 THEN 'duplicate'
+			       END AS problem
+			FROM product_media m
+			JOIN products p ON p.id = m.product_id AND p.is_active = true
+			WHERE m.type = 'image'
+		), counts AS (
+			SELECT COUNT(*) FILTER (WHERE problem = 'missing') AS missing,
+			       COUNT(*) FILTER (WHERE problem = 'duplicate') AS duplicate
+			FROM images
+		)
+		SELECT i.id, i.product_id, i.title, i.slug, i.url, i.alt, i.position, i.source, i.problem,
+		       COUNT(*) OVER (), counts.missing, counts.duplicate
+		FROM images i CROSS JOIN counts
+		WHERE i.problem IS NOT NULL AND ($1 = '' OR i.problem = $1)
+		ORDER BY i.title, i.product_id, i.position, i.id
+		LIMIT $2 OFFSET $3
+	`, problem, limit, offset)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+
+	var total, missing, duplicate int64
+	items := []fiber.Map{}
+	var records [][]string
+	for rows.Next() {
+		var id, productID, title, slug, imageURL, alt, source, kind string
+		var position int
+		rows.Scan(&id, &productID, &title, &slug, &imageURL, &alt, &position, &source, &kind, &total, &missing, &duplicate)
+		if csvExport {
+			records = append(records, []string{productID, title, id, imageURL, alt, source, kind})
+			continue
+		}
+		items = append(items, fiber.Map{
+			"media_id": id, "product_id": productID, "title": title, "slug": slug, "url": imageURL,
+			"alt": alt, "position": position, "source": source, "problem": kind,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	if csvExport {
+		return sendCSV(c, "image-alt", []string{"product_id", "title", "media_id", "url", "alt", "source", "problem"}, records)
+	}
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{
+		"missing":   missing,
+		"duplicate": duplicate,
+		"items":     items,
+	}, page, limit, total)})
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== IMPORT RETRIES AND ALERTS ==========
+
+// What started an import: manual runs are never retried
+const (
+	importTriggerManual    = "manual"
+	importTriggerScheduled = "scheduled"
+	importTriggerRetry     = "retry"
+)
+
+const (
+	defaultImportRetryDelay       = 30 * time.Minute
+	defaultImportMaxRetries       = 2
+	defaultImportFailureThreshold = 3
+)
+
+// importFailurePolicy decides how failed imports are retried and when a feed is flagged.
+// A failed scheduled run is retried after RetryDelay, at most MaxRetries times before the
+// feed waits for its next regular run; FailureThreshold consecutive failures of any kind
+// set needs_attention, alert ADMIN_EMAIL and post to WebhookURL when one is configured.
+type importFailurePolicy struct {
+	RetryDelay       time.Duration
+	MaxRetries       int
+	FailureThreshold int
+	WebhookURL       string
+}
+
+// importFailurePolicyFromEnv reads IMPORT_RETRY_DELAY (a Go duration, default 30m),
+// IMPORT_MAX_RETRIES (default 2, 0 disables retries), IMPORT_FAILURE_THRESHOLD
+// (default 3) and IMPORT_ALERT_WEBHOOK_URL
+func importFailurePolicyFromEnv() importFailurePolicy {
+	p := importFailurePolicy{
+		RetryDelay: defaultImportRetryDelay, MaxRetries: defaultImportMaxRetries,
+		FailureThreshold: defaultImportFailureThreshold, WebhookURL: os.Getenv("IMPORT_ALERT_WEBHOOK_URL"),
+	}
+	if d, err := time.ParseDuration(os.Getenv("IMPORT_RETRY_DELAY")); err == nil && d > 0 {
+		p.RetryDelay = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("IMPORT_MAX_RETRIES")); err == nil && n >= 0 {
+		p.MaxRetries = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("IMPORT_FAILURE_THRESHOLD")); err == nil && n > 0 {
+		p.FailureThreshold = n
+	}
+	return p
+}
+
+// recordImportOutcome keeps the feed's failure streak: a completed run clears it along
+// with any pending retry and the needs_attention flag; a failed one extends it, schedules
+// a retry of scheduled runs and raises the alert when the streak reaches the threshold
+func (h *Handlers) recordImportOutcome(ctx context.Context, feed models.Feed, runID, trigger, status, errMsg string) {
+	if status != "failed" {
+		h.db.Pool.Exec(ctx, `
+			UPDATE feeds SET consecutive_failures = 0, retry_count = 0, retry_at = NULL, needs_attention = false
+			WHERE id = $1::uuid
+		`, feed.ID)
+		return
+	}
+
+	policy := importFailurePolicyFromEnv()
+	var failures, retries int
+	var retryAt *time.Time
+	var flagged bool
+	if err := h.db.Pool.QueryRow(ctx, `
+		UPDATE feeds SET consecutive_failures = consecutive_failures + 1 WHERE id = $1::uuid
+		RETURNING consecutive_failures, retry_count, retry_at, needs_attention
+	`, feed.ID).Scan(&failures, &retries, &retryAt, &flagged); err != nil {
+		log.Printf("Failure streak of feed %s not recorded: %v", feed.ID, err)
+		return
+	}
+
+	// a regular scheduled run starts a new series of retries; manual runs leave it alone
+	if trigger != importTriggerManual {
+		if trigger == importTriggerScheduled {
+			retries = 0
+		}
+		retryAt = nil
+		if retries < policy.MaxRetries {
+			at := time.Now().Add(policy.RetryDelay)
+			retryAt = &at
+			retries++
+			log.Printf("Import of feed %s failed, retry %d/%d at %s", feed.ID, retries, policy.MaxRetries, at.Format(time.RFC3339))
+		}
+	}
+	raise := failures >= policy.FailureThreshold && !flagged
+	if _, err := h.db.Pool.Exec(ctx, `
+		UPDATE feeds SET retry_count = $2, retry_at = $3, needs_attention = needs_attention OR $4 WHERE id = $1::uuid
+	`, feed.ID, retries, retryAt, raise); err != nil {
+		log.Printf("Retry of feed %s not scheduled: %v", feed.ID, err)
+		return
+	}
+	if raise {
+		h.alertFeedNeedsAttention(ctx, policy, feed, runID, errMsg, failures)
+	}
+}
+
+// alertFeedNeedsAttention queues an e-mail to ADMIN_EMAIL and a post to the alert
+// webhook once a feed has failed FailureThreshold times in a row
+func (h *Handlers) alertFeedNeedsAttention(ctx context.Context, policy importFailurePolicy, feed models.Feed, runID, errMsg string, failures int) {
+	log.Printf("Feed %s (%s) needs attention after %d failed imports", feed.ID, feed.Name, failures)
+	if to := adminEmail(); to != "" {
+		data := map[string]any{"Feed": feed.Name, "FeedID": feed.ID, "RunID": runID, "Error": errMsg, "Failures": failures}
+		if err := h.queueNotification(ctx, "import_attention", adminLang(), to, data); err != nil {
+			log.Printf("Needs-attention alert for feed %s not queued: %v", feed.ID, err)
+		}
+	}
+	if policy.WebhookURL == "" {
+		return
+	}
+	payload := map[string]any{
+		"event": "feed.needs_attention", "priority": "high",
+		"feed_id": feed.ID, "feed_name": feed.Name, "run_id": runID,
+		"consecutive_failures": failures, "error": errMsg, "at": time.Now().UTC(),
+	}
+	if err := queueWebhookOn(ctx, h.db.Pool, "feed.needs_attention", policy.WebhookURL, payload); err != nil {
+		log.Printf("Needs-attention webhook for feed %s not queued: %v", feed.ID, err)
+	}
+}
+
+// feedsNeedingAttention lists the flagged feeds for the dashboard, longest streak first
+func (h *Handlers) feedsNeedingAttention(ctx context.Context) ([]fiber.Map, error) {
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT id::text, name, consecutive_failures, last_run, retry_at FROM feeds
+		WHERE needs_attention ORDER BY consecutive_failures DESC, name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	feeds := []fiber.Map{}
+	for rows.Next() {
+		var id, name string
+		var failures int
+		var lastRun, retryAt *time.Time
+		if err := rows.Scan(&id, &name, &failures, &lastRun, &retryAt); err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, fiber.Map{"id": id, "name": name, "consecutive_failures": failures, "last_run": lastRun, "retry_at": retryAt})
+	}
+	return feeds, rows.Err()
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"megabuy-go/internal/models"
+)
+
+// webhookRecorder is a webhook endpoint answering status and keeping the bodies posted to it
+type webhookRecorder struct {
+	*httptest.Server
+	mu     sync.Mutex
+	status int
+	bodies []string
+}
+
+func newWebhookRecorder(status int) *webhookRecorder {
+	w := &webhookRecorder{status: status}
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if r.Header.Get("Content-Type") == "application/json" {
+			w.bodies = append(w.bodies, string(body))
+		}
+		rw.WriteHeader(w.status)
+	}))
+	return w
+}
+
+func (w *webhookRecorder) received() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string{}, w.bodies...)
+}
+
+func (w *webhookRecorder) answer(status int) {
+	w.mu.Lock()
+	w.status = status
+	w.mu.Unlock()
+}
+
+func TestPostWebhook(t *testing.T) {
+	hook := newWebhookRecorder(http.StatusNoContent)
+	defer hook.Close()
+	ctx := context.Background()
+
+	if err := postWebhook(ctx, hook.URL, `{"event":"test"}`); err != nil {
+		t.Fatal(err)
+	}
+	if got := hook.received(); len(got) != 1 || got[0] != `{"event":"test"}` {
+		t.Errorf("webhook received %q", got)
+	}
+
+	hook.answer(http.StatusServiceUnavailable)
+	if err := postWebhook(ctx, hook.URL, `{}`); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("HTTP 503 answer: err = %v", err)
+	}
+	if err := postWebhook(ctx, "http://127.0.0.1:1/", `{}`); err == nil {
+		t.Error("unreachable webhook did not fail")
+	}
+}
+
+func TestImportAlertWebhookGoesThroughOutbox(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	t.Setenv("ADMIN_EMAIL", "")
+	hook := newWebhookRecorder(http.StatusServiceUnavailable)
+	defer hook.Close()
+
+	// e-mails stay queued without a sender; only the webhook is delivered
+	h := *env.h
+	h.sender = nil
+	feed := models.Feed{ID: "00000000-0000-0000-0000-000000001756", Name: "Dodávateľ"}
+	policy := importFailurePolicy{FailureThreshold: 3, WebhookURL: hook.URL}
+	h.alertFeedNeedsAttention(ctx, policy, feed, "run-1", "HTTP 404", 3)
+
+	if got := hook.received(); len(got) != 0 {
+		t.Fatalf("webhook posted while raising the alert: %q", got)
+	}
+	outbox := func() (status string, attempts int, lastError string) {
+		t.Helper()
+		if err := env.db.Pool.QueryRow(ctx, `
+			SELECT status, attempts, COALESCE(last_error,'') FROM notification_events
+			WHERE channel = 'webhook' AND recipient = $1
+		`, hook.URL).Scan(&status, &attempts, &lastError); err != nil {
+			t.Fatalf("queued webhook: %v", err)
+		}
+		return
+	}
+	if status, _, _ := outbox(); status != "pending" {
+		t.Fatalf("queued webhook is %s", status)
+	}
+
+	h.dispatchNotifications(ctx)
+	if status, attempts, lastError := outbox(); status != "pending" || attempts != 1 || !strings.Contains(lastError, "503") {
+		t.Fatalf("after a failed delivery: %s, %d attempts, %q", status, attempts, lastError)
+	}
+
+	hook.answer(http.StatusOK)
+	env.db.Pool.Exec(ctx, "UPDATE notification_events SET next_attempt_at = NOW() WHERE recipient = $1", hook.URL)
+	h.dispatchNotifications(ctx)
+	if status, attempts, _ := outbox(); status != "sent" || attempts != 2 {
+		t.Fatalf("after the retry: %s, %d attempts", status, attempts)
+	}
+
+	got := hook.received()
+	if len(got) != 2 {
+		t.Fatalf("webhook received %d posts, want 2", len(got))
+	}
+	var payload map[string]any
+	if err := json.Unmarshal([]byte(got[1]), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["event"] != "feed.needs_attention" || payload["feed_id"] != feed.ID || payload["consecutive_failures"] != float64(3) {
+		t.Errorf("webhook payload %v", payload)
+	}
+}
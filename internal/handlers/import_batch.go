@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== BATCHED IMPORT WRITES ==========
+
+const (
+	defaultImportBatchSize = 200
+	maxImportBatchSize     = 1000
+)
+
+// importBatchSize reads how many items an import worker writes per round trip from
+// IMPORT_BATCH_SIZE (default 200, capped at 1000; 1 writes item by item)
+func importBatchSize() int {
+	n, err := strconv.Atoi(os.Getenv("IMPORT_BATCH_SIZE"))
+	if err != nil || n <= 0 {
+		return defaultImportBatchSize
+	}
+	if n > maxImportBatchSize {
+		return maxImportBatchSize
+	}
+	return n
+}
+
+// feedSaveResult is what saveFeedProduct reports for one item
+type feedSaveResult struct {
+	productID string
+	isNew     bool
+	err       error
+}
+
+// importCategoryCache remembers resolved category paths for one import run, so the
+// path of every item is looked up once instead of once per item
+type importCategoryCache struct {
+	mu      sync.Mutex
+	results map[string]importCategoryResult
+}
+
+type importCategoryResult struct {
+	id  string
+	err error
+}
+
+func newImportCategoryCache() *importCategoryCache {
+	return &importCategoryCache{results: map[string]importCategoryResult{}}
+}
+
+// resolve returns findOrCreateCategoryFeed of the path, remembering IDs and unmapped
+// paths; other errors are not cached so the next item tries again
+func (cc *importCategoryCache) resolve(ctx context.Context, h *Handlers, feed models.Feed, path string) (string, error) {
+	cc.mu.Lock()
+	r, ok := cc.results[path]
+	cc.mu.Unlock()
+	if ok {
+		return r.id, r.err
+	}
+	id, err := h.findOrCreateCategoryFeed(ctx, feed, path)
+	if _, unmapped := err.(unmappedCategoryError); err == nil || unmapped {
+		cc.mu.Lock()
+		cc.results[path] = importCategoryResult{id, err}
+		cc.mu.Unlock()
+	}
+	return id, err
+}
+
+// feedProductWrite is an item of a batch together with the statement it needs:
+// productID is set for items matched to an existing product, which are updated, and
+// empty for items upserted on their EAN
+type feedProductWrite struct {
+	index      int
+	job        importJob
+	productID  string
+	categoryID *string
+	// oldStatus and newStatus are the stock status around the write
+	oldStatus, newStatus string
+	inserted             bool
+}
+
+// saveFeedProducts writes a batch of import items with the semantics of saveFeedProduct
+// and returns one result per item, in order. Matching and category lookups take one
+// query per batch; products, attributes, media and relations are written in one
+// transaction of two round trips. When that transaction fails, the batch is written
+// again item by item, so errors are attributed to the items that caused them.
+func (h *Handlers) saveFeedProducts(ctx context.Context, feed models.Feed, jobs []importJob, categories *importCategoryCache) []feedSaveResult {
+	results := make([]feedSaveResult, len(jobs))
+	if len(jobs) == 1 {
+		id, isNew, err := h.saveFeedProduct(ctx, feed, jobs[0].data, jobs[0].params)
+		results[0] = feedSaveResult{id, isNew, err}
+		return results
+	}
+
+	// Items without an EAN are matched on SKU, as in saveFeedProduct
+	var skus []string
+	for _, job := range jobs {
+		if getStr(job.data, "ean") == "" && getStr(job.data, "sku") != "" {
+			skus = append(skus, getStr(job.data, "sku"))
+		}
+	}
+	bySKU, err := h.productIDsBy(ctx, "sku", skus)
+	if err != nil {
+		return h.saveFeedProductsOneByOne(ctx, feed, jobs)
+	}
+
+	var writes []*feedProductWrite
+	var unmappedEANs []string
+	unmappedWrites := map[string]*feedProductWrite{}
+	for i, job := range jobs {
+		if !validFeedPrice(feed, job.data) {
+			results[i].err = errInvalidFeedPrice
+			continue
+		}
+		w := &feedProductWrite{index: i, job: job}
+		ean := getStr(job.data, "ean")
+		if ean == "" {
+			if id, ok := bySKU[getStr(job.data, "sku")]; ok {
+				w.productID = id
+				writes = append(writes, w)
+				continue
+			}
+		}
+		if category := getStr(job.data, "category"); category != "" {
+			catID, err := categories.resolve(ctx, h, feed, category)
+			if _, unmapped := err.(unmappedCategoryError); unmapped && ean != "" {
+				// existing products are never moved between categories, so an unmapped
+				// path only prevents creating the product
+				unmappedEANs = append(unmappedEANs, ean)
+				unmappedWrites[ean] = w
+				results[i].err = err
+				continue
+			}
+			if err != nil {
+				results[i].err = err
+				continue
+			}
+			if catID != "" {
+				w.categoryID = &catID
+			}
+		}
+		writes = append(writes, w)
+	}
+	if len(unmappedEANs) > 0 {
+		byEAN, err := h.productIDsBy(ctx, "ean", unmappedEANs)
+		if err != nil {
+			return h.saveFeedProductsOneByOne(ctx, feed, jobs)
+		}
+		for ean, id := range byEAN {
+			w := unmappedWrites[ean]
+			w.productID = id
+			results[w.index].err = nil
+			writes = append(writes, w)
+		}
+	}
+	if len(writes) == 0 {
+		return results
+	}
+
+	if err := h.writeFeedProducts(ctx, feed, writes); err != nil {
+		return h.saveFeedProductsOneByOne(ctx, feed, jobs)
+	}
+
+	var saved, backInStock []string
+	counts := map[string][2]int{}
+	for _, w := range writes {
+		results[w.index] = feedSaveResult{productID: w.productID, isNew: w.inserted}
+		saved = append(saved, w.productID)
+		if w.inserted && w.categoryID != nil {
+			c := counts[*w.categoryID]
+			c[0]++
+			if w.newStatus != "outofstock" {
+				c[1]++
+			}
+			counts[*w.categoryID] = c
+		} else if !w.inserted && isBackInStock(w.oldStatus, w.newStatus) {
+			backInStock = append(backInStock, w.productID)
+		}
+	}
+	h.bumpCategoryCounts(ctx, counts)
+	h.fireStockAlerts(ctx, backInStock)
+	if _, _, err := h.composeShortDescriptions(ctx, newWhere().add("p.id = ANY(?::uuid[])", saved), len(saved)); err != nil {
+		log.Printf("Short descriptions of feed %s batch: %v", feed.ID, err)
+	}
+	return results
+}
+
+// saveFeedProductsOneByOne is the fallback of saveFeedProducts
+func (h *Handlers) saveFeedProductsOneByOne(ctx context.Context, feed models.Feed, jobs []importJob) []feedSaveResult {
+	results := make([]feedSaveResult, len(jobs))
+	for i, job := range jobs {
+		id, isNew, err := h.saveFeedProduct(ctx, feed, job.data, job.params)
+		results[i] = feedSaveResult{id, isNew, err}
+	}
+	return results
+}
+
+// productIDsBy maps values of the ean or sku column to product IDs
+func (h *Handlers) productIDsBy(ctx context.Context, column string, values []string) (map[string]string, error) {
+	ids := map[string]string{}
+	if len(values) == 0 {
+		return ids, nil
+	}
+	rows, err := h.db.Pool.Query(ctx, "SELECT "+column+", id::text FROM products WHERE "+column+" = ANY($1::text[])", values)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var value, id string
+		if err := rows.Scan(&value, &id); err != nil {
+			return nil, err
+		}
+		if _, ok := ids[value]; !ok {
+			ids[value] = id
+		}
+	}
+	return ids, rows.Err()
+}
+
+// writeFeedProducts runs the product statements of a batch and then its attribute,
+// media and relation statements in one transaction, filling in the IDs and stock
+// statuses of writes. Rows are written in key order so concurrent batches lock them
+// in the same order.
+func (h *Handlers) writeFeedProducts(ctx context.Context, feed models.Feed, writes []*feedProductWrite) error {
+	sort.Slice(writes, func(a, b int) bool {
+		if (writes[a].productID == "") != (writes[b].productID == "") {
+			return writes[a].productID == ""
+		}
+		if writes[a].productID != "" {
+			return writes[a].productID < writes[b].productID
+		}
+		return getStr(writes[a].job.data, "ean") < getStr(writes[b].job.data, "ean")
+	})
+
+	tx, err := h.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, w := range writes {
+		if w.productID != "" {
+			batch.Queue(updateFeedProductSQL, updateFeedProductArgs(feed, w.productID, w.job.data)...)
+		} else {
+			batch.Queue(upsertFeedProductSQL, upsertFeedProductArgs(feed, w.job.data, w.categoryID)...)
+		}
+	}
+	br := tx.SendBatch(ctx, batch)
+	for _, w := range writes {
+		if w.productID != "" {
+			err = br.QueryRow().Scan(&w.oldStatus, &w.newStatus)
+		} else {
+			err = br.QueryRow().Scan(&w.productID, &w.inserted, &w.oldStatus, &w.newStatus)
+		}
+		if err != nil {
+			br.Close()
+			return err
+		}
+	}
+	if err := br.Close(); err != nil {
+		return err
+	}
+
+	// attributes follow saveProductAttributes: items without PARAMs keep theirs
+	var replaced, productIDs, names, values []string
+	var positions []int32
+	batch = &pgx.Batch{}
+	for _, w := range writes {
+		if len(w.job.params) > 0 {
+			replaced = append(replaced, w.productID)
+			n, v, p := normalizeParams(w.job.params)
+			for range n {
+				productIDs = append(productIDs, w.productID)
+			}
+			names, values, positions = append(names, n...), append(values, v...), append(positions, p...)
+		}
+		if media, ok := w.job.data["_media"].([]feedMedia); ok {
+			h.queueFeedMedia(batch, w.productID, media)
+		}
+		if relations, ok := w.job.data["_relations"].([]feedRelation); ok {
+			queueFeedRelations(batch, feed.ID, w.productID, relations)
+		}
+	}
+	if len(replaced) > 0 {
+		batch.Queue("DELETE FROM product_attributes WHERE product_id = ANY($1::uuid[])", replaced)
+		// canonical columns: name, value, position
+		batch.Queue(`
+			INSERT INTO product_attributes (product_id, name, value, position, created_at)
+			SELECT a.product_id, a.name, a.value, a.position, NOW()
+			FROM unnest($1::uuid[], $2::text[], $3::text[], $4::int[]) AS a(product_id, name, value, position)
+		`, productIDs, names, values, positions)
+	}
+	if batch.Len() > 0 {
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// bumpCategoryCounts adds products created by a batch to their categories' counts;
+// counts maps a category ID to its new products and how many of them are in stock
+func (h *Handlers) bumpCategoryCounts(ctx context.Context, counts map[string][2]int) {
+	if len(counts) == 0 {
+		return
+	}
+	var ids []string
+	var added, inStock []int32
+	for id, c := range counts {
+		ids = append(ids, id)
+		added = append(added, int32(c[0]))
+		inStock = append(inStock, int32(c[1]))
+	}
+	h.db.Pool.Exec(ctx, `
+		UPDATE categories c SET product_count = c.product_count + v.added, in_stock_count = c.in_stock_count + v.in_stock
+		FROM unnest($1::uuid[], $2::int[], $3::int[]) AS v(id, added, in_stock)
+		WHERE c.id = v.id
+	`, ids, added, inStock)
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"megabuy-go/internal/models"
+	"megabuy-go/internal/testutil"
+)
+
+// ========== IMPORT STAGES FOR BENCHMARKS ==========
+//
+// The parse and map stages run in process and report ns/item and allocations; the
+// import stage runs a full import against the test database and reports items/sec
+// and peak heap:
+//
+//	go test -run '^$' -bench 'Import' ./internal/handlers
+//	go test -run '^$' -bench 'ImportFeed' -benchtime 1x ./internal/handlers
+
+// parseFeed runs the parser registered for feedType over data, as an import does
+func parseFeed(feedType string, data []byte, itemPath string) ([]map[string]interface{}, error) {
+	parser, ok := feedParserFor(feedType)
+	if !ok {
+		return nil, fmt.Errorf("unknown feed type %q", feedType)
+	}
+	return collectItems(parser, bytes.NewReader(data), ParseOptions{ItemPath: itemPath})
+}
+
+// mapFeedItems runs the mapping stage of an import over items and returns how many
+// would be saved
+func mapFeedItems(feed models.Feed, items []map[string]interface{}) int {
+	mapper := newFeedItemMapper(feed)
+	importable := 0
+	for _, item := range items {
+		if mapper.mapItem(item).skip == "" {
+			importable++
+		}
+	}
+	return importable
+}
+
+// importFeed imports a stored feed synchronously, outside the import queue, and
+// returns its final progress. The feed is always downloaded in full.
+func (h *Handlers) importFeed(ctx context.Context, feedID string) (ImportProgress, error) {
+	feed, err := h.loadFeed(ctx, feedID)
+	if err != nil {
+		return ImportProgress{}, err
+	}
+	feed.ForceDownload = true
+	if h.imports.active(feedID) {
+		return ImportProgress{}, fmt.Errorf("import of feed %s already running or queued", feedID)
+	}
+
+	progressMutex.Lock()
+	importProgress[feedID] = &ImportProgress{FeedID: feedID, Status: "queued", Logs: []string{"Import requested for: " + feed.Name}}
+	progressMutex.Unlock()
+	h.beginImport(feed)
+	h.runImport(feed)
+
+	progressMutex.RLock()
+	defer progressMutex.RUnlock()
+	return importProgress[feedID].snapshot(), nil
+}
+
+// benchFeedItems keeps the generated feeds small enough for a default -benchtime
+const benchFeedItems = 5000
+
+func benchSpec(format testutil.FeedFormat) testutil.FeedSpec {
+	spec := testutil.DefaultSpec()
+	spec.Format, spec.Items = format, benchFeedItems
+	spec.DuplicateRate, spec.InvalidRate = 0.01, 0.01
+	return spec
+}
+
+// benchmarkImportParse measures parseFeed over a generated feed, per byte and per item
+func benchmarkImportParse(b *testing.B, spec testutil.FeedSpec) {
+	data := testutil.GenerateFeed(spec)
+	items, err := parseFeed(string(spec.Format), data, "SHOPITEM")
+	if err != nil || len(items) != spec.Items {
+		b.Fatalf("parsed %d of %d items: %v", len(items), spec.Items, err)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parseFeed(string(spec.Format), data, "SHOPITEM")
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*spec.Items), "ns/item")
+}
+
+func BenchmarkImportParseHeureka(b *testing.B) {
+	benchmarkImportParse(b, benchSpec(testutil.FormatHeureka))
+}
+func BenchmarkImportParseCSV(b *testing.B)  { benchmarkImportParse(b, benchSpec(testutil.FormatCSV)) }
+func BenchmarkImportParseJSON(b *testing.B) { benchmarkImportParse(b, benchSpec(testutil.FormatJSON)) }
+
+// BenchmarkImportParseHeurekaLongDescriptions weighs the text handling of the token loop
+func BenchmarkImportParseHeurekaLongDescriptions(b *testing.B) {
+	spec := benchSpec(testutil.FormatHeureka)
+	spec.Items, spec.DescriptionWords = benchFeedItems/10, 2000
+	benchmarkImportParse(b, spec)
+}
+
+func BenchmarkImportMap(b *testing.B) {
+	spec := benchSpec(testutil.FormatHeureka)
+	items, err := parseFeed(string(spec.Format), testutil.GenerateFeed(spec), "SHOPITEM")
+	if err != nil {
+		b.Fatal(err)
+	}
+	feed := models.Feed{ID: "bench", URL: "https://shop.example.com/feed", Type: string(spec.Format)}
+	if importable := mapFeedItems(feed, items); importable == 0 || importable == len(items) {
+		b.Fatalf("%d of %d items importable; the duplicates and invalid items should be rejected", importable, len(items))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mapFeedItems(feed, items)
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(items)), "ns/item")
+}
+
+// BenchmarkImportFeed imports a served feed through the regular pipeline; every
+// iteration after the first updates the products the first one created
+func BenchmarkImportFeed(b *testing.B) {
+	env := newTestEnv(b)
+	ctx := context.Background()
+	spec := benchSpec(testutil.FormatHeureka)
+	feedID := env.createTestFeed(b, "Import benchmark", spec)
+	srv := testutil.ServeFeed(spec)
+	defer srv.Close()
+	env.db.Pool.Exec(ctx, "UPDATE feeds SET url = $2 WHERE id = $1::uuid", feedID, testutil.FeedURL(srv))
+
+	// sample the heap while the imports run; runtime stats have no high-water mark
+	var peak atomic.Uint64
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		var ms runtime.MemStats
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				runtime.ReadMemStats(&ms)
+				if ms.HeapInuse > peak.Load() {
+					peak.Store(ms.HeapInuse)
+				}
+			}
+		}
+	}()
+
+	runtime.GC()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		progress, err := env.h.importFeed(ctx, feedID)
+		if err != nil || progress.Status != "completed" {
+			b.Fatalf("import %s: %v", progress.Status, err)
+		}
+	}
+	b.StopTimer()
+	close(done)
+	b.ReportMetric(float64(b.N*spec.Items)/b.Elapsed().Seconds(), "items/sec")
+	b.ReportMetric(float64(peak.Load())/(1<<20), "peak-heap-MB")
+}
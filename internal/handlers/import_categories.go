@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== IMPORT-CREATED CATEGORIES ==========
+
+// ImportedCategory is a category an import run created from a feed category path
+type ImportedCategory struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	SourcePath string `json:"source_path"`
+	Products   int    `json:"products"`
+}
+
+// unmappedCategoryError rejects an item whose category path has no existing category
+// while the feed's category_mode forbids creating one
+type unmappedCategoryError struct{ path string }
+
+func (e unmappedCategoryError) Error() string { return "category path not mapped: " + e.path }
+
+// category_mode "create" adds missing categories, "skip" and "fail" leave such items out
+// and count them as skipped or as errors
+func validCategoryMode(mode string) bool {
+	return mode == "create" || mode == "skip" || mode == "fail"
+}
+
+func invalidCategoryMode(c *fiber.Ctx) error {
+	return c.Status(400).JSON(fiber.Map{"success": false, "error": "category_mode must be create, skip or fail"})
+}
+
+// splitCategoryPath splits CATEGORYTEXT on the first separator style found in it
+func splitCategoryPath(categoryText string) []string {
+	for _, sep := range []string{" | ", "|", " > ", ">"} {
+		if parts := strings.Split(categoryText, sep); len(parts) > 1 {
+			return parts
+		}
+	}
+	return []string{categoryText}
+}
+
+// importRunID returns the feed_history ID of the feed's running import, if any
+func importRunID(feedID string) string {
+	progressMutex.RLock()
+	defer progressMutex.RUnlock()
+	if p, ok := importProgress[feedID]; ok {
+		return p.RunID
+	}
+	return ""
+}
+
+// recordImportedCategory adds a created category to the summary of the feed's running import
+func recordImportedCategory(feedID string, cat ImportedCategory) {
+	progressMutex.Lock()
+	defer progressMutex.Unlock()
+	if p, ok := importProgress[feedID]; ok {
+		p.CreatedCategories = append(p.CreatedCategories, cat)
+		p.Logs = append(p.Logs, "New category pending review: "+cat.SourcePath)
+	}
+}
+
+// countImportedCategoryProducts fills in how many products each created category holds
+func (h *Handlers) countImportedCategoryProducts(ctx context.Context, cats []ImportedCategory) {
+	if len(cats) == 0 {
+		return
+	}
+	ids := make([]string, len(cats))
+	for i, cat := range cats {
+		ids[i] = cat.ID
+	}
+	rows, err := h.db.Pool.Query(ctx, `SELECT category_id::text, COUNT(*) FROM products WHERE category_id = ANY($1::uuid[]) GROUP BY category_id`, ids)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	for rows.Next() {
+		var id string
+		var n int
+		rows.Scan(&id, &n)
+		counts[id] = n
+	}
+	for i := range cats {
+		cats[i].Products = counts[cats[i].ID]
+	}
+}
+
+// uncuratedCategories counts import-created categories no admin has edited since the given time
+func (h *Handlers) uncuratedCategories(ctx context.Context, since time.Time) int64 {
+	var n int64
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM categories WHERE source = 'import' AND curated_at IS NULL AND created_at >= $1", since).Scan(&n)
+	return n
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ========== IMPORT ERROR CLASSIFICATION ==========
+
+// Import phases passed to classifyImportError; the same low-level error means
+// different things while downloading the feed and while syncing search
+const (
+	phaseDownload = "download"
+	phaseParse    = "parse"
+	phaseDatabase = "database"
+	phaseSearch   = "search"
+)
+
+// maxItemErrorCodes caps the distinct codes kept in a run's error summary
+const maxItemErrorCodes = 20
+
+// localizedText carries a Slovak and an English variant of an admin-facing text
+type localizedText struct {
+	SK string `json:"sk"`
+	EN string `json:"en"`
+}
+
+// ImportError is a classified import failure. Code is stable for the frontend;
+// Details keeps the raw error for support.
+type ImportError struct {
+	Code    string        `json:"code"`
+	Message localizedText `json:"message"`
+	Hint    localizedText `json:"hint"`
+	// Field names the column a database constraint rejected
+	Field string `json:"field,omitempty"`
+	// Line locates malformed XML or CSV input
+	Line int `json:"line,omitempty"`
+	// Offset is the byte offset of malformed JSON input
+	Offset  int64  `json:"offset,omitempty"`
+	Details string `json:"details,omitempty"`
+	// Count is how often the error occurred in the run (per-item errors only)
+	Count int `json:"count,omitempty"`
+}
+
+// feedHTTPError is a non-200 answer of the feed server
+type feedHTTPError struct{ StatusCode int }
+
+func (e feedHTTPError) Error() string { return fmt.Sprintf("HTTP %d", e.StatusCode) }
+
+var importErrorTexts = map[string][2]localizedText{
+	"network_timeout": {
+		{"Server dodávateľa neodpovedal včas.", "The supplier's server did not respond in time."},
+		{"Skúste import neskôr. Ak sa to opakuje, overte URL feedu v prehliadači alebo kontaktujte dodávateľa.", "Retry later. If it keeps happening, open the feed URL in a browser or contact the supplier."},
+	},
+	"dns_error": {
+		{"Doménu feedu sa nepodarilo nájsť.", "The feed's domain could not be resolved."},
+		{"Skontrolujte preklepy v URL feedu; dodávateľ mohol zmeniť adresu.", "Check the feed URL for typos; the supplier may have moved it."},
+	},
+	"connection_refused": {
+		{"Server dodávateľa odmietol spojenie.", "The supplier's server refused the connection."},
+		{"Server je pravdepodobne mimo prevádzky. Skúste to neskôr.", "The server is probably down. Try again later."},
+	},
+	"tls_error": {
+		{"Zabezpečené spojenie so serverom dodávateľa zlyhalo.", "The secure connection to the supplier's server failed."},
+		{"Certifikát servera je neplatný alebo zastaraný. Požiadajte dodávateľa o opravu alebo skúste http:// adresu.", "The server certificate is invalid or outdated. Ask the supplier to fix it or try the http:// address."},
+	},
+	"http_unauthorized": {
+		{"Server dodávateľa odmietol prístup k feedu.", "The supplier's server denied access to the feed."},
+		{"Feed vyžaduje prihlásenie alebo platný token. Overte prístupové údaje v URL feedu.", "The feed needs a login or a valid token. Check the credentials in the feed URL."},
+	},
+	"http_not_found": {
+		{"Feed na zadanej adrese neexistuje.", "No feed exists at the configured address."},
+		{"Dodávateľ mohol feed presunúť. Vyžiadajte si aktuálnu URL.", "The supplier may have moved the feed. Ask for the current URL."},
+	},
+	"http_error": {
+		{"Server dodávateľa vrátil chybu.", "The supplier's server returned an error."},
+		{"Skúste import neskôr; ak chyba pretrváva, kontaktujte dodávateľa.", "Retry later; if the error persists, contact the supplier."},
+	},
+	"file_not_found": {
+		{"Súbor feedu sa nenašiel.", "The feed file was not found."},
+		{"Overte cestu k súboru na serveri.", "Check the file path on the server."},
+	},
+	"malformed_xml": {
+		{"Feed nie je platné XML.", "The feed is not valid XML."},
+		{"Otvorte feed a skontrolujte uvedený riadok; často ide o neuzavretú značku alebo neescapovaný znak &.", "Open the feed at the given line; usually an unclosed tag or an unescaped & character."},
+	},
+	"malformed_json": {
+		{"Feed nie je platný JSON.", "The feed is not valid JSON."},
+		{"Skontrolujte feed na uvedenej pozícii alebo zmeňte typ feedu.", "Check the feed at the given offset or change the feed type."},
+	},
+	"malformed_csv": {
+		{"CSV feed sa nedá prečítať.", "The CSV feed cannot be read."},
+		{"Skontrolujte oddeľovače a úvodzovky na uvedenom riadku.", "Check delimiters and quotes on the given line."},
+	},
+	"unknown_feed_type": {
+		{"Typ feedu nie je podporovaný.", "The feed type is not supported."},
+		{"V nastaveniach feedu vyberte jeden z podporovaných typov.", "Pick one of the supported types in the feed settings."},
+	},
+	"no_items": {
+		{"Vo feede sa nenašli žiadne produkty.", "No products were found in the feed."},
+		{"Skontrolujte nastavenie XML item path; náhľad feedu ukáže, ktoré elementy sa vo feede opakujú.", "Check the XML item path setting; the feed preview lists the repeated elements in the feed."},
+	},
+	"transform_failed": {
+		{"Pravidlo transformácie feedu sa nedalo použiť.", "A feed transform rule could not be applied."},
+		{"Položka sa importovala bez tohto pravidla. Skontrolujte, či pravidlo mieri na textové pole.", "The item was imported without that rule. Check that the rule targets a text field."},
+	},
+	"category_not_mapped": {
+		{"Kategória produktu nie je namapovaná.", "The product's category is not mapped."},
+		{"Vytvorte chýbajúce kategórie alebo prepnite režim kategórií feedu na vytváranie.", "Create the missing categories or switch the feed's category mode to create."},
+	},
+	"db_duplicate": {
+		{"Produkt s rovnakou hodnotou už existuje.", "A product with the same value already exists."},
+		{"Vo feede alebo v katalógu je duplicitná hodnota uvedeného poľa (napr. slug alebo EAN).", "The named field (e.g. slug or EAN) is duplicated in the feed or the catalog."},
+	},
+	"db_missing_value": {
+		{"Chýba povinná hodnota.", "A required value is missing."},
+		{"Namapujte uvedené pole v nastaveniach feedu.", "Map the named field in the feed settings."},
+	},
+	"db_value_too_long": {
+		{"Hodnota je príliš dlhá.", "A value is too long."},
+		{"Dodávateľ posiela príliš dlhý text alebo URL; skontrolujte mapovanie polí.", "The supplier sends an overly long text or URL; check the field mapping."},
+	},
+	"db_invalid_value": {
+		{"Hodnota má nesprávny formát.", "A value has the wrong format."},
+		{"Skontrolujte mapovanie polí; napríklad cena musí byť číslo.", "Check the field mapping; a price, for example, must be a number."},
+	},
+	"db_reference_missing": {
+		{"Odkazovaný záznam neexistuje.", "A referenced record does not exist."},
+		{"Kategória alebo predajca mohol byť medzitým zmazaný; spustite import znova.", "A category or vendor may have been deleted meanwhile; run the import again."},
+	},
+	"db_error": {
+		{"Uloženie do databázy zlyhalo.", "Saving to the database failed."},
+		{"Skúste import znova; ak chyba pretrváva, pošlite detaily správcovi.", "Retry the import; if it persists, send the details to the administrator."},
+	},
+	"search_unavailable": {
+		{"Vyhľadávanie (Elasticsearch) nie je dostupné.", "Search (Elasticsearch) is unavailable."},
+		{"Produkty sú uložené, ale vo vyhľadávaní sa objavia až po synchronizácii. Spustite ju v administrácii po obnovení služby.", "Products are saved but show up in search only after a sync. Run it from the admin once the service is back."},
+	},
+	"internal_error": {
+		{"Import skončil neočakávanou chybou.", "The import stopped with an unexpected error."},
+		{"Pošlite detaily správcovi.", "Send the details to the administrator."},
+	},
+	"unknown": {
+		{"Import zlyhal.", "The import failed."},
+		{"Pozrite si detaily chyby alebo ich pošlite správcovi.", "See the error details or send them to the administrator."},
+	},
+}
+
+// newImportError builds the classified error for code; raw may be nil
+func newImportError(code string, raw error) ImportError {
+	texts, ok := importErrorTexts[code]
+	if !ok {
+		code, texts = "unknown", importErrorTexts["unknown"]
+	}
+	e := ImportError{Code: code, Message: texts[0], Hint: texts[1]}
+	if raw != nil {
+		e.Details = raw.Error()
+	}
+	return e
+}
+
+// classifyImportError maps a raw error raised in phase to a stable code
+func classifyImportError(phase string, err error) ImportError {
+	var (
+		httpErr    feedHTTPError
+		dnsErr     *net.DNSError
+		netErr     net.Error
+		certErr    x509.CertificateInvalidError
+		authErr    x509.UnknownAuthorityError
+		hostErr    x509.HostnameError
+		xmlErr     *xml.SyntaxError
+		jsonErr    *json.SyntaxError
+		csvErr     *csv.ParseError
+		pgErr      *pgconn.PgError
+		unmapped   unmappedCategoryError
+		attrErr    attributeError
+		msg        = strings.ToLower(err.Error())
+		searchDown = phase == phaseSearch
+	)
+	if errors.As(err, &attrErr) {
+		err = attrErr.err
+	}
+
+	switch {
+	case errors.As(err, &unmapped):
+		return newImportError("category_not_mapped", err)
+	case errors.As(err, &pgErr):
+		return classifyPgError(pgErr)
+	case searchDown:
+		return newImportError("search_unavailable", err)
+	case errors.As(err, &httpErr):
+		switch httpErr.StatusCode {
+		case 401, 403:
+			return newImportError("http_unauthorized", err)
+		case 404, 410:
+			return newImportError("http_not_found", err)
+		}
+		return newImportError("http_error", err)
+	case errors.As(err, &xmlErr):
+		e := newImportError("malformed_xml", err)
+		e.Line = xmlErr.Line
+		return e
+	case errors.As(err, &jsonErr):
+		e := newImportError("malformed_json", err)
+		e.Offset = jsonErr.Offset
+		return e
+	case errors.As(err, &csvErr):
+		e := newImportError("malformed_csv", err)
+		e.Line = csvErr.Line
+		return e
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) || strings.Contains(msg, "timeout"):
+		return newImportError("network_timeout", err)
+	case errors.As(err, &dnsErr) || strings.Contains(msg, "no such host"):
+		return newImportError("dns_error", err)
+	case strings.Contains(msg, "connection refused") || strings.Contains(msg, "connection reset"):
+		return newImportError("connection_refused", err)
+	case errors.As(err, &certErr) || errors.As(err, &authErr) || errors.As(err, &hostErr) || strings.Contains(msg, "tls:") || strings.Contains(msg, "x509:"):
+		return newImportError("tls_error", err)
+	case errors.Is(err, os.ErrNotExist):
+		return newImportError("file_not_found", err)
+	case phase == phaseDatabase:
+		return newImportError("db_error", err)
+	}
+	return newImportError("unknown", err)
+}
+
+// importDBRetries is how many more times an item write that failed with a transient
+// database error is attempted at the end of a run; attempt n waits n*importDBRetryDelay
+const (
+	importDBRetries    = 3
+	importDBRetryDelay = 500 * time.Millisecond
+)
+
+// isTransientDBError reports whether a failed item write may succeed when retried:
+// serialization failures and deadlocks, lost connections, exhausted resources and
+// lock timeouts. Invalid items and constraint violations are not retried.
+func isTransientDBError(err error) bool {
+	var (
+		pgErr    *pgconn.PgError
+		unmapped unmappedCategoryError
+		attrErr  attributeError
+	)
+	switch {
+	case err == nil, errors.Is(err, errInvalidFeedPrice), errors.As(err, &unmapped), errors.As(err, &attrErr):
+		return false
+	case errors.As(err, &pgErr):
+		if len(pgErr.Code) < 2 {
+			return false
+		}
+		switch pgErr.Code[:2] {
+		case "40", "08", "53", "55", "57":
+			return true
+		}
+		return false
+	}
+	switch classifyImportError(phaseDatabase, err).Code {
+	case "network_timeout", "connection_refused":
+		return true
+	}
+	return false
+}
+
+// classifyPgError names the rejected column where PostgreSQL reports it
+func classifyPgError(pgErr *pgconn.PgError) ImportError {
+	code := "db_error"
+	switch pgErr.Code {
+	case "23505":
+		code = "db_duplicate"
+	case "23502":
+		code = "db_missing_value"
+	case "22001":
+		code = "db_value_too_long"
+	case "22P02", "22003", "22007", "22008":
+		code = "db_invalid_value"
+	case "23503":
+		code = "db_reference_missing"
+	}
+	e := newImportError(code, pgErr)
+	e.Field = pgErr.ColumnName
+	if e.Field == "" && pgErr.ConstraintName != "" {
+		e.Field = pgErr.ConstraintName
+	}
+	return e
+}
+
+// setImportError records the error that stopped the feed's running import
+func setImportError(feedID string, e ImportError) {
+	progressMutex.Lock()
+	defer progressMutex.Unlock()
+	if p, ok := importProgress[feedID]; ok {
+		p.Error = &e
+	}
+}
+
+// recordItemError counts a per-item failure of the feed's running import by code
+func recordItemError(feedID string, e ImportError) {
+	progressMutex.Lock()
+	defer progressMutex.Unlock()
+	p, ok := importProgress[feedID]
+	if !ok {
+		return
+	}
+	for i := range p.ErrorSummary {
+		if p.ErrorSummary[i].Code == e.Code && p.ErrorSummary[i].Field == e.Field {
+			p.ErrorSummary[i].Count++
+			return
+		}
+	}
+	if len(p.ErrorSummary) < maxItemErrorCodes {
+		e.Count = 1
+		p.ErrorSummary = append(p.ErrorSummary, e)
+	}
+}
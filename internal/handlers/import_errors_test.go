@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"megabuy-go/internal/models"
+	"megabuy-go/internal/testutil"
+)
+
+func TestIsTransientDBError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{&pgconn.PgError{Code: "40001"}, true},
+		{&pgconn.PgError{Code: "40P01"}, true},
+		{&pgconn.PgError{Code: "08006"}, true},
+		{&pgconn.PgError{Code: "53300"}, true},
+		{&pgconn.PgError{Code: "55P03"}, true},
+		{&pgconn.PgError{Code: "57014"}, true},
+		{fmt.Errorf("batch: %w", &pgconn.PgError{Code: "40001"}), true},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{&pgconn.PgError{Code: "22001"}, false},
+		{&pgconn.PgError{Code: "4"}, false},
+		{errInvalidFeedPrice, false},
+		{unmappedCategoryError{path: "A > B"}, false},
+		{attributeError{err: &pgconn.PgError{Code: "40001"}}, false},
+		{errors.New("dial tcp 10.0.0.1:5432: connection refused"), true},
+		{errors.New("read: i/o timeout"), true},
+		{errors.New("something else"), false},
+	}
+	for _, tt := range tests {
+		if got := isTransientDBError(tt.err); got != tt.want {
+			t.Errorf("isTransientDBError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestImportRetriesRecoverTransientFailures(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	spec := testutil.FeedSpec{Format: testutil.FormatHeureka, Items: 30, Categories: 3, Seed: 1732}
+	feedID := env.createTestFeed(t, "Retry test", spec)
+	srv := testutil.ServeFeed(spec)
+	defer srv.Close()
+	env.db.Pool.Exec(ctx, "UPDATE feeds SET url = $2 WHERE id = $1::uuid", feedID, testutil.FeedURL(srv))
+
+	// writes of these items fail with a deadlock as often as given; the last one
+	// fails on every attempt, retries included
+	failures := map[string]int{"GEN-0000003": 1, "GEN-0000017": 2, "GEN-0000025": importDBRetries + 1}
+	var mu sync.Mutex
+	h := *env.h
+	h.saveFeedBatch = func(ctx context.Context, feed models.Feed, jobs []importJob, categories *importCategoryCache) []feedSaveResult {
+		results := make([]feedSaveResult, len(jobs))
+		var rest []importJob
+		var restAt []int
+		mu.Lock()
+		for i, job := range jobs {
+			if sku := getStr(job.data, "sku"); failures[sku] > 0 {
+				failures[sku]--
+				results[i].err = &pgconn.PgError{Code: "40P01", Message: "deadlock detected"}
+				continue
+			}
+			rest = append(rest, job)
+			restAt = append(restAt, i)
+		}
+		mu.Unlock()
+		if len(rest) > 0 {
+			for k, r := range env.h.saveFeedProducts(ctx, feed, rest, categories) {
+				results[restAt[k]] = r
+			}
+		}
+		return results
+	}
+
+	progress, err := h.importFeed(ctx, feedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Status != "completed" {
+		t.Fatalf("import %s: %s", progress.Status, progress.Message)
+	}
+	o := progress.Outcomes
+	if o.Retried != 3 || o.Recovered != 2 || o.DBError != 1 {
+		t.Errorf("outcomes %+v, want 3 retried, 2 recovered, 1 database error", o)
+	}
+	if progress.Created != 29 || progress.Errors != 1 {
+		t.Errorf("created %d with %d errors, want 29 with 1", progress.Created, progress.Errors)
+	}
+	if len(progress.ErrorSummary) != 1 || progress.ErrorSummary[0].Code != "db_error" || progress.ErrorSummary[0].Count != 1 {
+		t.Errorf("error summary %+v, want one db_error", progress.ErrorSummary)
+	}
+
+	var skus []string
+	rows, err := env.db.Pool.Query(ctx, `
+		SELECT sku FROM products WHERE feed_id = $1::uuid AND sku = ANY($2) ORDER BY sku
+	`, feedID, []string{"GEN-0000003", "GEN-0000017", "GEN-0000025"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sku string
+		rows.Scan(&sku)
+		skus = append(skus, sku)
+	}
+	if fmt.Sprint(skus) != "[GEN-0000003 GEN-0000017]" {
+		t.Errorf("products of the failing items: %v, want the two recovered ones", skus)
+	}
+
+	// the failed write keeps the source validators unset, so the next run downloads
+	// the feed again instead of getting a 304 and never writing the item
+	var etag *string
+	env.db.Pool.QueryRow(ctx, "SELECT source_etag FROM feeds WHERE id = $1::uuid", feedID).Scan(&etag)
+	if etag != nil {
+		t.Errorf("ETag %q stored after a run with a failed write", *etag)
+	}
+	progress, err = env.h.importFeed(ctx, feedID)
+	if err != nil || progress.Status != "completed" || progress.Outcomes.DBError != 0 {
+		t.Fatalf("second import: %v %+v", err, progress)
+	}
+	env.db.Pool.QueryRow(ctx, "SELECT source_etag FROM feeds WHERE id = $1::uuid", feedID).Scan(&etag)
+	if etag == nil {
+		t.Error("ETag not stored after a clean run")
+	}
+}
+
+// TestImportOutcomeClassification runs an import with price-less items, an item whose
+// write fails for its content and one that hits a single deadlock, and checks each
+// lands in its own outcome, in the progress and in the stored run
+func TestImportOutcomeClassification(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	spec := testutil.FeedSpec{Format: testutil.FormatHeureka, Items: 40, Categories: 3, InvalidRate: 0.25, Seed: 17321}
+	feedID := env.createTestFeed(t, "Outcome test", spec)
+
+	items, err := parseFeed("heureka-xml", testutil.GenerateFeed(spec), "SHOPITEM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var invalid int
+	var valid []string
+	for _, item := range items {
+		if getStr(item, "PRICE_VAT") == "" {
+			invalid++
+		} else {
+			valid = append(valid, getStr(item, "ITEM_ID"))
+		}
+	}
+	if invalid == 0 || len(valid) < 2 {
+		t.Fatalf("fixture has %d invalid and %d valid items", invalid, len(valid))
+	}
+	tooLong, deadlocked := valid[0], valid[1]
+
+	var mu sync.Mutex
+	deadlocks := 1
+	h := *env.h
+	h.saveFeedBatch = func(ctx context.Context, feed models.Feed, jobs []importJob, categories *importCategoryCache) []feedSaveResult {
+		results := make([]feedSaveResult, len(jobs))
+		var rest []importJob
+		var restAt []int
+		mu.Lock()
+		for i, job := range jobs {
+			switch sku := getStr(job.data, "sku"); {
+			case sku == tooLong:
+				results[i].err = &pgconn.PgError{Code: "22001", Message: "value too long for type character varying(255)"}
+				continue
+			case sku == deadlocked && deadlocks > 0:
+				deadlocks--
+				results[i].err = &pgconn.PgError{Code: "40P01", Message: "deadlock detected"}
+				continue
+			}
+			rest = append(rest, job)
+			restAt = append(restAt, i)
+		}
+		mu.Unlock()
+		if len(rest) > 0 {
+			for k, r := range env.h.saveFeedProducts(ctx, feed, rest, categories) {
+				results[restAt[k]] = r
+			}
+		}
+		return results
+	}
+
+	progress, err := h.importFeed(ctx, feedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ImportOutcomes{ValidationSkip: invalid, DBError: 1, Retried: 1, Recovered: 1}
+	if progress.Outcomes != want {
+		t.Errorf("outcomes %+v, want %+v", progress.Outcomes, want)
+	}
+	if progress.Created != len(valid)-1 {
+		t.Errorf("created %d, want the %d valid items but the one too long", progress.Created, len(valid)-1)
+	}
+	var codes []string
+	for _, e := range progress.ErrorSummary {
+		codes = append(codes, e.Code)
+	}
+	if fmt.Sprint(codes) != "[db_value_too_long]" {
+		t.Errorf("error summary codes %v, want only the value too long", codes)
+	}
+
+	var stored ImportOutcomes
+	if err := env.db.Pool.QueryRow(ctx, `
+		SELECT outcomes FROM feed_history WHERE feed_id = $1::uuid ORDER BY started_at DESC LIMIT 1
+	`, feedID).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != want {
+		t.Errorf("stored outcomes %+v, want %+v", stored, want)
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== IMPORT COORDINATOR ==========
+
+const defaultMaxConcurrentImports = 2
+
+// importCoordinator caps how many feed imports run at once; the rest wait in a FIFO queue
+type importCoordinator struct {
+	mu         sync.Mutex
+	maxRunning int
+	running    map[string]time.Time
+	queue      []queuedImport
+}
+
+type queuedImport struct {
+	feed     models.Feed
+	queuedAt time.Time
+}
+
+// newImportCoordinatorFromEnv reads the limit from MAX_CONCURRENT_IMPORTS (default 2)
+func newImportCoordinatorFromEnv() *importCoordinator {
+	limit := defaultMaxConcurrentImports
+	if n, err := strconv.Atoi(os.Getenv("MAX_CONCURRENT_IMPORTS")); err == nil && n > 0 {
+		limit = n
+	}
+	return &importCoordinator{maxRunning: limit, running: make(map[string]time.Time)}
+}
+
+// active reports whether the feed is running or waiting
+func (ic *importCoordinator) active(feedID string) bool {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	return ic.activeLocked(feedID)
+}
+
+// activeLocked is active for callers holding ic.mu
+func (ic *importCoordinator) activeLocked(feedID string) bool {
+	if _, ok := ic.running[feedID]; ok {
+		return true
+	}
+	for _, q := range ic.queue {
+		if q.feed.ID == feedID {
+			return true
+		}
+	}
+	return false
+}
+
+// idle reports whether no import is running or waiting
+func (ic *importCoordinator) idle() bool {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	return len(ic.running) == 0 && len(ic.queue) == 0
+}
+
+// errImportActive rejects a feed that is already running or waiting
+var errImportActive = fmt.Errorf("import already running or queued for this feed")
+
+// submit starts the import when a slot is free and queues it otherwise, installing
+// progress as the feed's progress entry. A feed already running or waiting is
+// rejected with errImportActive; the check and the admission happen under one lock,
+// so two callers racing for the same feed cannot both get it in.
+// It returns the 1-based queue position, or 0 when the import may start now.
+func (ic *importCoordinator) submit(feed models.Feed, progress *ImportProgress) (int, error) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	if ic.activeLocked(feed.ID) {
+		return 0, errImportActive
+	}
+	progressMutex.Lock()
+	importProgress[feed.ID] = progress
+	progressMutex.Unlock()
+
+	if len(ic.running) < ic.maxRunning {
+		ic.running[feed.ID] = time.Now()
+		return 0, nil
+	}
+	ic.queue = append(ic.queue, queuedImport{feed: feed, queuedAt: time.Now()})
+	ic.publishPositions()
+	return len(ic.queue), nil
+}
+
+// finish frees the feed's slot and hands it to the next queued feed, if any
+func (ic *importCoordinator) finish(feedID string) (models.Feed, bool) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	delete(ic.running, feedID)
+	if len(ic.queue) == 0 {
+		return models.Feed{}, false
+	}
+	next := ic.queue[0].feed
+	ic.queue = ic.queue[1:]
+	ic.running[next.ID] = time.Now()
+	ic.publishPositions()
+	return next, true
+}
+
+// prioritize moves a queued feed to the front of the queue
+func (ic *importCoordinator) prioritize(feedID string) bool {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	for i, q := range ic.queue {
+		if q.feed.ID == feedID {
+			copy(ic.queue[1:i+1], ic.queue[:i])
+			ic.queue[0] = q
+			ic.publishPositions()
+			return true
+		}
+	}
+	return false
+}
+
+// publishPositions copies queue positions into the progress entries; callers hold ic.mu
+func (ic *importCoordinator) publishPositions() {
+	progressMutex.Lock()
+	defer progressMutex.Unlock()
+	for i, q := range ic.queue {
+		if p, ok := importProgress[q.feed.ID]; ok {
+			p.QueuePosition = i + 1
+			p.Message = fmt.Sprintf("Caka v rade na import (pozicia %d)", i+1)
+		}
+	}
+}
+
+func (ic *importCoordinator) stats() fiber.Map {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	running := []fiber.Map{}
+	for id, since := range ic.running {
+		running = append(running, fiber.Map{"feed_id": id, "started_at": since})
+	}
+	queued := []fiber.Map{}
+	for i, q := range ic.queue {
+		queued = append(queued, fiber.Map{"feed_id": q.feed.ID, "name": q.feed.Name, "position": i + 1, "queued_at": q.queuedAt})
+	}
+	return fiber.Map{"max_concurrent": ic.maxRunning, "running": running, "queued": queued}
+}
+
+// runImportSlot runs the feed and then keeps the slot busy with queued feeds until the queue is empty
+func (h *Handlers) runImportSlot(feed models.Feed) {
+	for ok := true; ok; feed, ok = h.imports.finish(feed.ID) {
+		h.beginImport(feed)
+		h.runImport(feed)
+	}
+}
+
+// beginImport marks a feed as running once it has a slot
+func (h *Handlers) beginImport(feed models.Feed) {
+	progressMutex.Lock()
+	if p, ok := importProgress[feed.ID]; ok {
+		p.Status = "downloading"
+		p.Message = "Stahujem feed..."
+		p.QueuePosition = 0
+		p.Logs = append(p.Logs, "Import started for: "+feed.Name)
+	}
+	progressMutex.Unlock()
+	h.db.Pool.Exec(context.Background(), "UPDATE feeds SET last_status='running', last_run=NOW() WHERE id=$1::uuid", feed.ID)
+}
+
+// PrioritizeImport moves a queued import to the front of the queue
+func (h *Handlers) PrioritizeImport(c *fiber.Ctx) error {
+	if !h.imports.prioritize(c.Params("id")) {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Feed is not waiting in the import queue"})
+	}
+	return c.JSON(fiber.Map{"success": true, "message": "Import moved to the front of the queue"})
+}
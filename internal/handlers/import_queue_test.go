@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"megabuy-go/internal/models"
+)
+
+func testCoordinator(limit int) *importCoordinator {
+	return &importCoordinator{maxRunning: limit, running: make(map[string]time.Time)}
+}
+
+func submitFeed(ic *importCoordinator, id string) (int, error) {
+	return ic.submit(models.Feed{ID: id, Name: id}, &ImportProgress{FeedID: id, Status: "queued"})
+}
+
+func TestImportCoordinatorQueue(t *testing.T) {
+	ic := testCoordinator(2)
+	for i, want := range []int{0, 0, 1, 2} {
+		id := fmt.Sprintf("queue-feed-%d", i)
+		if pos, err := submitFeed(ic, id); err != nil || pos != want {
+			t.Fatalf("submit %s = %d, %v; want position %d", id, pos, err, want)
+		}
+	}
+	progressMutex.RLock()
+	if p := importProgress["queue-feed-3"]; p == nil || p.QueuePosition != 2 {
+		t.Errorf("queued progress = %+v, want position 2", p)
+	}
+	progressMutex.RUnlock()
+
+	if !ic.prioritize("queue-feed-3") {
+		t.Fatal("prioritize of a queued feed failed")
+	}
+	if ic.prioritize("queue-feed-0") {
+		t.Error("prioritize of a running feed succeeded")
+	}
+	next, ok := ic.finish("queue-feed-0")
+	if !ok || next.ID != "queue-feed-3" {
+		t.Errorf("finish handed the slot to %q, want the prioritized feed", next.ID)
+	}
+	next, _ = ic.finish("queue-feed-1")
+	if next.ID != "queue-feed-2" {
+		t.Errorf("finish handed the slot to %q, want queue-feed-2", next.ID)
+	}
+	ic.finish("queue-feed-2")
+	if _, ok := ic.finish("queue-feed-3"); ok || !ic.idle() {
+		t.Error("coordinator not idle after every feed finished")
+	}
+}
+
+func TestImportCoordinatorRejectsActiveFeed(t *testing.T) {
+	ic := testCoordinator(1)
+	submitFeed(ic, "busy-running")
+	submitFeed(ic, "busy-queued")
+	for _, id := range []string{"busy-running", "busy-queued"} {
+		if _, err := submitFeed(ic, id); err != errImportActive {
+			t.Errorf("second submit of %s: err = %v, want errImportActive", id, err)
+		}
+	}
+	if got := len(ic.queue); got != 1 {
+		t.Errorf("queue holds %d feeds, want 1", got)
+	}
+}
+
+func TestImportCoordinatorConcurrentSubmit(t *testing.T) {
+	for _, limit := range []int{1, 2} {
+		ic := testCoordinator(limit)
+		submitFeed(ic, "filler")
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		accepted := 0
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := submitFeed(ic, "contested"); err == nil {
+					mu.Lock()
+					accepted++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if accepted != 1 {
+			t.Errorf("limit %d: %d of 50 concurrent submits accepted, want 1", limit, accepted)
+		}
+		queued := 0
+		for _, q := range ic.queue {
+			if q.feed.ID == "contested" {
+				queued++
+			}
+		}
+		if _, running := ic.running["contested"]; running {
+			queued++
+		}
+		if queued != 1 {
+			t.Errorf("limit %d: feed admitted %d times", limit, queued)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ImportMetrics holds phase timings of one import run, stored in feed_history.metrics
+type ImportMetrics struct {
+	DownloadMs     int64   `json:"download_ms"`
+	DownloadBytes  int     `json:"download_bytes"`
+	ParseMs        int64   `json:"parse_ms"`
+	DBWriteMs      int64   `json:"db_write_ms"`
+	ESSyncMs       int64   `json:"es_sync_ms"`
+	TotalMs        int64   `json:"total_ms"`
+	ItemsPerSecond float64 `json:"items_per_second"`
+	DBBatches      int     `json:"db_batches"`
+	DBBatchSize    float64 `json:"db_batch_size"`
+	Retries        int     `json:"retries"`
+	// Workers is how many goroutines wrote the items; DBWriteMs sums their time
+	Workers int `json:"workers,omitempty"`
+}
+
+const (
+	// importProgressFlushInterval is how often a running import writes its progress
+	importProgressFlushInterval = 5 * time.Second
+	// importLogExcerptLines is how much of the log tail a run keeps in feed_history
+	importLogExcerptLines = 20
+	// staleImportProgress is when a running run that stopped reporting is flagged stale
+	staleImportProgress = 2 * time.Minute
+	defaultRunHistory   = 20
+	maxRunHistory       = 100
+)
+
+func logExcerpt(logs []string) []string {
+	if len(logs) > importLogExcerptLines {
+		logs = logs[len(logs)-importLogExcerptLines:]
+	}
+	return append([]string{}, logs...)
+}
+
+// startImportRun creates the feed_history record for a new run
+func (h *Handlers) startImportRun(ctx context.Context, feedID string) string {
+	var runID string
+	err := h.db.Pool.QueryRow(ctx, `
+		INSERT INTO feed_history (feed_id, status, started_at) VALUES ($1::uuid, 'running', NOW()) RETURNING id
+	`, feedID).Scan(&runID)
+	if err != nil {
+		log.Printf("Import run record for feed %s not created: %v", feedID, err)
+	}
+	return runID
+}
+
+// finishImportRun stores final counters and metrics of a run
+func (h *Handlers) finishImportRun(ctx context.Context, runID, status, errMsg string, p ImportProgress, m ImportMetrics) {
+	if runID == "" {
+		return
+	}
+	metricsJSON, _ := json.Marshal(m)
+	categories := append([]ImportedCategory{}, p.CreatedCategories...)
+	h.countImportedCategoryProducts(ctx, categories)
+	categoriesJSON, _ := json.Marshal(categories)
+	skipJSON, _ := json.Marshal(nonNilSkipReasons(p.SkipReasons))
+	var errorCode string
+	var errorInfo *string
+	if p.Error != nil {
+		errorCode = p.Error.Code
+		b, _ := json.Marshal(p.Error)
+		s := string(b)
+		errorInfo = &s
+	}
+	summaryJSON, _ := json.Marshal(append([]ImportError{}, p.ErrorSummary...))
+	outcomesJSON, _ := json.Marshal(p.Outcomes)
+	logJSON, _ := json.Marshal(logExcerpt(p.Logs))
+	_, err := h.db.Pool.Exec(ctx, `
+		UPDATE feed_history SET status=$2, total_items=$3, created=$4, updated=$5, skipped=$6, errors=$7,
+		       duration=$8, error_message=NULLIF($9,''), metrics=$10::jsonb, created_categories=$11::jsonb,
+		       skip_reasons=$12::jsonb, error_code=NULLIF($13,''), error_info=$14::jsonb, error_summary=$15::jsonb,
+		       outcomes=$16::jsonb, phase=$2, processed=$17, message=NULLIF($18,''), log_excerpt=$19::jsonb,
+		       progress_at=NOW(), finished_at=NOW()
+		WHERE id=$1::uuid
+	`, runID, status, p.Total, p.Created, p.Updated, p.Skipped, p.Errors, m.TotalMs/1000, errMsg, string(metricsJSON), string(categoriesJSON), string(skipJSON),
+		errorCode, errorInfo, string(summaryJSON), string(outcomesJSON), p.Processed, p.Message, string(logJSON))
+	if err != nil {
+		log.Printf("Import run %s not finalized: %v", runID, err)
+	}
+}
+
+// saveImportProgress writes the live counters, phase and log tail of a running run
+func (h *Handlers) saveImportProgress(ctx context.Context, runID string, p ImportProgress) {
+	if runID == "" {
+		return
+	}
+	logJSON, _ := json.Marshal(logExcerpt(p.Logs))
+	outcomesJSON, _ := json.Marshal(p.Outcomes)
+	_, err := h.db.Pool.Exec(ctx, `
+		UPDATE feed_history SET phase=$2, total_items=$3, processed=$4, created=$5, updated=$6, skipped=$7, errors=$8,
+		       message=NULLIF($9,''), log_excerpt=$10::jsonb, outcomes=$11::jsonb, progress_at=NOW()
+		WHERE id=$1::uuid AND finished_at IS NULL
+	`, runID, p.Status, p.Total, p.Processed, p.Created, p.Updated, p.Skipped, p.Errors, p.Message, string(logJSON), string(outcomesJSON))
+	if err != nil {
+		log.Printf("Import run %s progress not saved: %v", runID, err)
+	}
+}
+
+// PersistedProgress is import progress read back from feed_history when this instance
+// holds none for the feed, e.g. after a restart. Stale marks a run that is still open
+// but has not reported progress for staleImportProgress.
+type PersistedProgress struct {
+	ImportProgress
+	Persisted  bool       `json:"persisted"`
+	Stale      bool       `json:"stale"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	ProgressAt *time.Time `json:"progress_at"`
+}
+
+// lastImportProgress loads the newest run of a feed; false when it never ran
+func (h *Handlers) lastImportProgress(ctx context.Context, feedID string) (PersistedProgress, bool) {
+	p := PersistedProgress{Persisted: true}
+	p.FeedID = feedID
+	var status, phase, logStr, outcomesStr string
+	err := h.db.Pool.QueryRow(ctx, `
+		SELECT id::text, status, COALESCE(phase,''), COALESCE(total_items,0), COALESCE(processed,0), COALESCE(created,0),
+		       COALESCE(updated,0), COALESCE(skipped,0), COALESCE(errors,0), COALESCE(message, error_message, ''),
+		       COALESCE(log_excerpt::text,'[]'), COALESCE(outcomes::text,'{}'), started_at, finished_at, progress_at
+		FROM feed_history WHERE feed_id=$1::uuid ORDER BY started_at DESC LIMIT 1
+	`, feedID).Scan(&p.RunID, &status, &phase, &p.Total, &p.Processed, &p.Created, &p.Updated, &p.Skipped, &p.Errors, &p.Message,
+		&logStr, &outcomesStr, &p.StartedAt, &p.FinishedAt, &p.ProgressAt)
+	if err != nil {
+		return p, false
+	}
+	json.Unmarshal([]byte(logStr), &p.Logs)
+	json.Unmarshal([]byte(outcomesStr), &p.Outcomes)
+	if p.Logs == nil {
+		p.Logs = []string{}
+	}
+
+	p.Status = status
+	if p.FinishedAt == nil {
+		if phase != "" {
+			p.Status = phase
+		}
+		last := p.StartedAt
+		if p.ProgressAt != nil {
+			last = *p.ProgressAt
+		}
+		p.Stale = time.Since(last) > staleImportProgress
+	}
+	if p.Total > 0 {
+		p.Percent = p.Processed * 100 / p.Total
+	}
+	if p.FinishedAt != nil && status == "completed" {
+		p.Percent = 100
+	}
+	return p, true
+}
+
+// GetFeedImports lists the last ?limit= runs of a feed (20 by default, at most 100),
+// newest first, each with its counts' change against the run before it
+func (h *Handlers) GetFeedImports(c *fiber.Ctx) error {
+	feedID := c.Params("id")
+	limit := c.QueryInt("limit", defaultRunHistory)
+	if limit <= 0 {
+		limit = defaultRunHistory
+	}
+	if limit > maxRunHistory {
+		limit = maxRunHistory
+	}
+
+	ctx := context.Background()
+	// one extra run so the oldest listed one has something to compare against
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT id::text, status, COALESCE(total_items,0), COALESCE(processed,0), COALESCE(created,0), COALESCE(updated,0),
+		       COALESCE(skipped,0), COALESCE(errors,0), COALESCE(error_code,''), COALESCE(error_message,''),
+		       COALESCE(log_excerpt::text,'[]'), started_at, finished_at,
+		       CASE WHEN finished_at IS NULL THEN EXTRACT(EPOCH FROM NOW() - started_at)::int ELSE duration END
+		FROM feed_history WHERE feed_id=$1::uuid ORDER BY started_at DESC LIMIT $2
+	`, feedID, limit+1)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+
+	type runCounts struct {
+		Total   int `json:"total"`
+		Created int `json:"created"`
+		Updated int `json:"updated"`
+		Skipped int `json:"skipped"`
+		Errors  int `json:"errors"`
+	}
+	var counts []runCounts
+	runs := []fiber.Map{}
+	for rows.Next() {
+		var id, status, errorCode, errMsg, logStr string
+		var n runCounts
+		var processed, duration int
+		var startedAt time.Time
+		var finishedAt *time.Time
+		rows.Scan(&id, &status, &n.Total, &processed, &n.Created, &n.Updated, &n.Skipped, &n.Errors, &errorCode, &errMsg, &logStr, &startedAt, &finishedAt, &duration)
+		logs := []string{}
+		json.Unmarshal([]byte(logStr), &logs)
+		counts = append(counts, n)
+		runs = append(runs, fiber.Map{
+			"id": id, "status": status, "total": n.Total, "processed": processed, "created": n.Created, "updated": n.Updated,
+			"skipped": n.Skipped, "errors": n.Errors, "error_code": errorCode, "error_message": errMsg, "log_excerpt": logs,
+			"started_at": startedAt, "finished_at": finishedAt, "duration": duration,
+		})
+	}
+	for i := range runs {
+		if i+1 >= len(runs) {
+			runs[i]["change"] = nil
+			continue
+		}
+		cur, prev := counts[i], counts[i+1]
+		runs[i]["change"] = runCounts{
+			Total: cur.Total - prev.Total, Created: cur.Created - prev.Created, Updated: cur.Updated - prev.Updated,
+			Skipped: cur.Skipped - prev.Skipped, Errors: cur.Errors - prev.Errors,
+		}
+	}
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"feed_id": feedID, "runs": runs}})
+}
+
+func (h *Handlers) GetImportRun(c *fiber.Ctx) error {
+	feedID := c.Params("id")
+	runID := c.Params("run_id")
+	ctx := context.Background()
+
+	var id, status, errMsg, metricsStr, categoriesStr, skipStr, summaryStr, outcomesStr string
+	var errorInfo *string
+	var total, created, updated, skipped, errors, duration int
+	var startedAt time.Time
+	var finishedAt *time.Time
+	err := h.db.Pool.QueryRow(ctx, `
+		SELECT id, status, total_items, created, updated, skipped, errors, duration,
+		       COALESCE(error_message,''), COALESCE(metrics::text,'{}'),
+		       COALESCE(created_categories::text,'[]'), COALESCE(skip_reasons::text,'{}'),
+		       error_info::text, COALESCE(error_summary::text,'[]'), COALESCE(outcomes::text,'{}'), started_at, finished_at
+		FROM feed_history WHERE id=$1::uuid AND feed_id=$2::uuid
+	`, runID, feedID).Scan(&id, &status, &total, &created, &updated, &skipped, &errors, &duration, &errMsg, &metricsStr, &categoriesStr, &skipStr,
+		&errorInfo, &summaryStr, &outcomesStr, &startedAt, &finishedAt)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Import run not found"})
+	}
+
+	var metrics ImportMetrics
+	json.Unmarshal([]byte(metricsStr), &metrics)
+	categories := []ImportedCategory{}
+	json.Unmarshal([]byte(categoriesStr), &categories)
+	skipReasons := map[string]SkipReason{}
+	json.Unmarshal([]byte(skipStr), &skipReasons)
+	var runError *ImportError
+	if errorInfo != nil {
+		runError = &ImportError{}
+		json.Unmarshal([]byte(*errorInfo), runError)
+	}
+	errorSummary := []ImportError{}
+	json.Unmarshal([]byte(summaryStr), &errorSummary)
+	var outcomes ImportOutcomes
+	json.Unmarshal([]byte(outcomesStr), &outcomes)
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"id": id, "feed_id": feedID, "status": status, "total": total, "created": created, "updated": updated,
+		"skipped": skipped, "errors": errors, "duration": duration, "error_message": errMsg,
+		"metrics": metrics, "created_categories": categories, "skip_reasons": skipReasons,
+		"error": runError, "error_summary": errorSummary, "outcomes": outcomes, "started_at": startedAt, "finished_at": finishedAt,
+	}})
+}
+
+// GetFeedPerformance returns metrics of the last 30 finished runs with averages,
+// comparing the newest five runs against the older ones to make regressions visible
+func (h *Handlers) GetFeedPerformance(c *fiber.Ctx) error {
+	feedID := c.Params("id")
+	ctx := context.Background()
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT id, status, total_items, COALESCE(metrics::text,'{}'), started_at
+		FROM feed_history WHERE feed_id=$1::uuid AND finished_at IS NOT NULL
+		ORDER BY started_at DESC LIMIT 30
+	`, feedID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+
+	type runPerf struct {
+		ID        string        `json:"id"`
+		Status    string        `json:"status"`
+		Total     int           `json:"total"`
+		Metrics   ImportMetrics `json:"metrics"`
+		StartedAt time.Time     `json:"started_at"`
+	}
+	runs := []runPerf{}
+	for rows.Next() {
+		var r runPerf
+		var metricsStr string
+		rows.Scan(&r.ID, &r.Status, &r.Total, &metricsStr, &r.StartedAt)
+		json.Unmarshal([]byte(metricsStr), &r.Metrics)
+		runs = append(runs, r)
+	}
+
+	average := func(list []runPerf) fiber.Map {
+		var download, parse, dbWrite, esSync, total int64
+		var ips float64
+		n := 0
+		for _, r := range list {
+			if r.Status != "completed" {
+				continue
+			}
+			download += r.Metrics.DownloadMs
+			parse += r.Metrics.ParseMs
+			dbWrite += r.Metrics.DBWriteMs
+			esSync += r.Metrics.ESSyncMs
+			total += r.Metrics.TotalMs
+			ips += r.Metrics.ItemsPerSecond
+			n++
+		}
+		if n == 0 {
+			return fiber.Map{"runs": 0}
+		}
+		return fiber.Map{
+			"runs":             n,
+			"download_ms":      download / int64(n),
+			"parse_ms":         parse / int64(n),
+			"db_write_ms":      dbWrite / int64(n),
+			"es_sync_ms":       esSync / int64(n),
+			"total_ms":         total / int64(n),
+			"items_per_second": ips / float64(n),
+		}
+	}
+
+	recent, older := runs, []runPerf{}
+	if len(runs) > 5 {
+		recent, older = runs[:5], runs[5:]
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"runs":    runs,
+		"average": average(runs),
+		"recent":  average(recent),
+		"older":   average(older),
+	}})
+}
+
+// parseRunTime accepts an RFC 3339 timestamp or a YYYY-MM-DD date; endOfDay moves a
+// plain date to the start of the following day so it bounds the range inclusively
+func parseRunTime(s string, endOfDay bool) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
+}
+
+// AdminListImports lists import runs of all feeds, newest first. Filters: ?status=,
+// ?feed_id=, ?from= and ?to= on the start time, and ?min_errors=. The summary covers
+// every run matching the filters, not just the page.
+func (h *Handlers) AdminListImports(c *fiber.Ctx) error {
+	page, limit, offset := adminPageParams(c, 50)
+	ctx := context.Background()
+
+	where := newWhere()
+	if status := c.Query("status"); status != "" {
+		where.add("r.status = ?", status)
+	}
+	if feedID := c.Query("feed_id"); feedID != "" {
+		if !isUUID(feedID) {
+			return invalidUUIDField(c, "feed_id")
+		}
+		where.add("r.feed_id = ?::uuid", feedID)
+	}
+	if from := c.Query("from"); from != "" {
+		t, ok := parseRunTime(from, false)
+		if !ok {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "from must be a date (YYYY-MM-DD) or RFC 3339 timestamp"})
+		}
+		where.add("r.started_at >= ?", t)
+	}
+	if to := c.Query("to"); to != "" {
+		t, ok := parseRunTime(to, true)
+		if !ok {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "to must be a date (YYYY-MM-DD) or RFC 3339 timestamp"})
+		}
+		where.add("r.started_at < ?", t)
+	}
+	if minErrors := c.QueryInt("min_errors", 0); minErrors > 0 {
+		where.add("r.errors >= ?", minErrors)
+	}
+
+	var total, finished, failed, processed int64
+	var avgDuration float64
+	h.db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE r.finished_at IS NOT NULL), COUNT(*) FILTER (WHERE r.status = 'failed'),
+		       COALESCE(SUM(r.total_items),0), COALESCE(AVG(r.duration) FILTER (WHERE r.finished_at IS NOT NULL),0)
+		FROM feed_history r `+where.clause(), where.params()...).Scan(&total, &finished, &failed, &processed, &avgDuration)
+	var failureRate float64
+	if finished > 0 {
+		failureRate = float64(failed) / float64(finished)
+	}
+
+	list := where.clone()
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT r.id, r.feed_id::text, COALESCE(f.name,''), r.status, r.total_items, r.created, r.updated, r.skipped, r.errors,
+		       COALESCE(r.error_code,''), COALESCE(r.outcomes::text,'{}'), r.started_at, r.finished_at,
+		       CASE WHEN r.finished_at IS NULL THEN EXTRACT(EPOCH FROM NOW() - r.started_at)::int ELSE r.duration END
+		FROM feed_history r LEFT JOIN feeds f ON f.id = r.feed_id
+		`+list.clause()+` ORDER BY r.started_at DESC LIMIT `+list.arg(limit)+` OFFSET `+list.arg(offset), list.params()...)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+	runs := []fiber.Map{}
+	for rows.Next() {
+		var id, feedID, feedName, status, errorCode, outcomesStr string
+		var totalItems, created, updated, skipped, errors, duration int
+		var startedAt time.Time
+		var finishedAt *time.Time
+		rows.Scan(&id, &feedID, &feedName, &status, &totalItems, &created, &updated, &skipped, &errors, &errorCode, &outcomesStr, &startedAt, &finishedAt, &duration)
+		var outcomes ImportOutcomes
+		json.Unmarshal([]byte(outcomesStr), &outcomes)
+		runs = append(runs, fiber.Map{
+			"id": id, "feed_id": feedID, "feed_name": feedName, "status": status, "total": totalItems,
+			"created": created, "updated": updated, "skipped": skipped, "errors": errors, "error_code": errorCode,
+			"outcomes": outcomes, "started_at": startedAt, "finished_at": finishedAt, "duration": duration,
+		})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{
+		"items": runs,
+		"summary": fiber.Map{
+			"runs": total, "finished": finished, "failed": failed, "items_processed": processed,
+			"avg_duration": avgDuration, "failure_rate": failureRate,
+		},
+	}, page, limit, total)})
+}
+
+// notifyImportFailed e-mails ADMIN_EMAIL about a failed import run
+func (h *Handlers) notifyImportFailed(ctx context.Context, feedName, runID, errMsg string, p ImportProgress) {
+	to := adminEmail()
+	if to == "" {
+		return
+	}
+	data := map[string]any{
+		"Feed": feedName, "RunID": runID, "Error": errMsg,
+		"Total": p.Total, "Created": p.Created, "Updated": p.Updated, "Errors": p.Errors,
+	}
+	if err := h.queueNotification(ctx, "import_failed", adminLang(), to, data); err != nil {
+		log.Printf("Import failure alert for run %s not queued: %v", runID, err)
+	}
+}
@@ -0,0 +1,449 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/settings"
+)
+
+// ========== IMPORT SCHEDULE AND BLACKOUT WINDOWS ==========
+
+// Scheduled imports run from RunImportScheduler according to feeds.schedule. During a
+// blackout window (e.g. 08:00-22:00, the peak shopping hours) they are deferred to the
+// next allowed slot; manual imports still start but are flagged. With defer_es_sync
+// the products of an import finishing inside a window go live in the database at once
+// while their Elasticsearch sync waits for the window to end.
+
+const defaultImportTimezone = "Europe/Bratislava"
+
+// scheduleIntervals maps feeds.schedule to the time between scheduled runs; feeds with
+// "manual" or any other unparsable schedule are only imported on request
+var scheduleIntervals = map[string]time.Duration{
+	"hourly": time.Hour,
+	"daily":  24 * time.Hour,
+	"weekly": 7 * 24 * time.Hour,
+}
+
+// staleImportClaim is how long a feed may stay queued or running before the scheduler
+// assumes the instance holding it died and claims it again
+const staleImportClaim = 6 * time.Hour
+
+var scheduleWeekdays = map[string]time.Weekday{
+	"mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday, "thu": time.Thursday,
+	"fri": time.Friday, "sat": time.Saturday, "sun": time.Sunday,
+}
+
+// feedSchedule is a parsed feeds.schedule. Plain "hourly", "daily" and "weekly" run
+// that long after the last run; "daily HH:MM" and "weekly DAY HH:MM" (DAY is mon..sun)
+// run at that local time of the blackout time zone, catching up on a missed slot.
+type feedSchedule struct {
+	interval time.Duration
+	// clock is minutes after midnight, -1 for interval schedules
+	clock   int
+	weekly  bool
+	weekday time.Weekday
+}
+
+func parseFeedSchedule(schedule string) (feedSchedule, bool) {
+	parts := strings.Fields(strings.ToLower(strings.ReplaceAll(schedule, "@", " ")))
+	if len(parts) == 0 {
+		return feedSchedule{}, false
+	}
+	interval, ok := scheduleIntervals[parts[0]]
+	if !ok {
+		return feedSchedule{}, false
+	}
+	s := feedSchedule{interval: interval, clock: -1, weekly: parts[0] == "weekly"}
+	args := parts[1:]
+	switch {
+	case len(args) == 0:
+		return s, true
+	case parts[0] == "hourly":
+		return feedSchedule{}, false
+	case s.weekly:
+		day, ok := scheduleWeekdays[args[0]]
+		if !ok || len(args) > 2 {
+			return feedSchedule{}, false
+		}
+		s.weekday, s.clock, args = day, 0, args[1:]
+	}
+	if len(args) == 1 {
+		clock, ok := parseClock(args[0])
+		if !ok {
+			return feedSchedule{}, false
+		}
+		s.clock, args = clock, args[1:]
+	}
+	return s, len(args) == 0
+}
+
+// validFeedSchedule accepts "manual" and every schedule the scheduler runs
+func validFeedSchedule(schedule string) bool {
+	_, ok := parseFeedSchedule(schedule)
+	return ok || schedule == "manual"
+}
+
+func invalidFeedSchedule(c *fiber.Ctx) error {
+	return c.Status(400).JSON(fiber.Map{"success": false, "error": `schedule must be manual, hourly, daily, "daily HH:MM", weekly or "weekly DAY HH:MM"`})
+}
+
+// due is when the feed is next due, ignoring blackout windows: now or earlier when it
+// should run at once
+func (s feedSchedule) due(lastRun *time.Time, now time.Time, loc *time.Location) time.Time {
+	if lastRun == nil {
+		return now
+	}
+	if s.clock < 0 {
+		return lastRun.Add(s.interval)
+	}
+	// the latest slot at or before now
+	local := now.In(loc)
+	slot := time.Date(local.Year(), local.Month(), local.Day(), s.clock/60, s.clock%60, 0, 0, loc)
+	for slot.After(now) || (s.weekly && slot.Weekday() != s.weekday) {
+		slot = slot.AddDate(0, 0, -1)
+	}
+	if lastRun.Before(slot) {
+		return slot
+	}
+	if s.weekly {
+		return slot.AddDate(0, 0, 7)
+	}
+	return slot.AddDate(0, 0, 1)
+}
+
+// BlackoutWindow is a daily time range in HH:MM; an end before the start wraps past midnight
+type BlackoutWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// ImportBlackout is the blackout setting as stored in the settings table
+type ImportBlackout struct {
+	Windows     []BlackoutWindow `json:"windows"`
+	Timezone    string           `json:"timezone,omitempty"`
+	DeferESSync bool             `json:"defer_es_sync"`
+}
+
+// parseClock reads HH:MM as minutes after midnight
+func parseClock(s string) (int, bool) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// validate returns a message describing the first invalid field, or ""
+func (b ImportBlackout) validate() string {
+	if b.Timezone != "" {
+		if _, err := time.LoadLocation(b.Timezone); err != nil {
+			return "timezone must be an IANA time zone such as Europe/Bratislava"
+		}
+	}
+	for i, w := range b.Windows {
+		start, ok1 := parseClock(w.Start)
+		end, ok2 := parseClock(w.End)
+		if !ok1 || !ok2 {
+			return fmt.Sprintf("windows[%d]: start and end must be HH:MM", i)
+		}
+		if start == end {
+			return fmt.Sprintf("windows[%d]: start and end must differ", i)
+		}
+	}
+	return ""
+}
+
+func (b ImportBlackout) location() *time.Location {
+	name := b.Timezone
+	if name == "" {
+		name = defaultImportTimezone
+	}
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc
+	}
+	return time.Local
+}
+
+// activeAt reports whether t falls inside any blackout window
+func (b ImportBlackout) activeAt(t time.Time) bool {
+	local := t.In(b.location())
+	m := local.Hour()*60 + local.Minute()
+	for _, w := range b.Windows {
+		start, ok1 := parseClock(w.Start)
+		end, ok2 := parseClock(w.End)
+		if !ok1 || !ok2 {
+			continue
+		}
+		if start < end && m >= start && m < end || start > end && (m >= start || m < end) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextAllowed returns t when no window is active, otherwise the end of the blackout,
+// following overlapping windows
+func (b ImportBlackout) nextAllowed(t time.Time) time.Time {
+	if !b.activeAt(t) {
+		return t
+	}
+	loc := b.location()
+	local := t.In(loc)
+	var ends []time.Time
+	for day := 0; day <= 2; day++ {
+		for _, w := range b.Windows {
+			end, ok := parseClock(w.End)
+			if !ok {
+				continue
+			}
+			e := time.Date(local.Year(), local.Month(), local.Day()+day, end/60, end%60, 0, 0, loc)
+			if e.After(t) {
+				ends = append(ends, e)
+			}
+		}
+	}
+	sort.Slice(ends, func(i, j int) bool { return ends[i].Before(ends[j]) })
+	for _, e := range ends {
+		if !b.activeAt(e) {
+			return e
+		}
+	}
+	return t
+}
+
+// importBlackout returns the setting; other instances notice a change within settings.CacheTTL
+func (h *Handlers) importBlackout() ImportBlackout {
+	var b ImportBlackout
+	h.settings.Decode(context.Background(), settings.KeyImportBlackout, &b)
+	return b
+}
+
+// nextScheduledRun is when the scheduler will start the feed: its interval after the
+// last run, moved out of any blackout window; nil for feeds it does not run
+func nextScheduledRun(b ImportBlackout, schedule string, isActive bool, lastRun *time.Time, now time.Time) *time.Time {
+	s, ok := parseFeedSchedule(schedule)
+	if !ok || !isActive {
+		return nil
+	}
+	due := s.due(lastRun, now, b.location())
+	if due.Before(now) {
+		due = now
+	}
+	next := b.nextAllowed(due)
+	return &next
+}
+
+// importSchedulerState is what the last scheduler pass saw
+type importSchedulerState struct {
+	LastCheck *time.Time `json:"last_check"`
+	// Deferred lists feeds that were due but held back by a blackout window
+	Deferred []string `json:"deferred"`
+}
+
+var (
+	importSchedulerMutex sync.Mutex
+	importScheduler      = importSchedulerState{Deferred: []string{}}
+)
+
+// RunImportScheduler starts due scheduled imports and deferred Elasticsearch syncs
+func (h *Handlers) RunImportScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		h.runScheduledImports(context.Background(), now)
+	}
+}
+
+func (h *Handlers) runScheduledImports(ctx context.Context, now time.Time) {
+	b := h.importBlackout()
+	blackout := b.activeAt(now)
+	deferred := []string{}
+	defer func() {
+		importSchedulerMutex.Lock()
+		importScheduler = importSchedulerState{LastCheck: &now, Deferred: deferred}
+		importSchedulerMutex.Unlock()
+	}()
+	if h.maintenanceState().Enabled {
+		return
+	}
+	if !blackout {
+		h.runDeferredESSyncs(ctx)
+	}
+
+	rows, err := h.db.Pool.Query(ctx, `SELECT id::text, COALESCE(schedule,'daily'), last_run, retry_at FROM feeds WHERE COALESCE(is_active,true)`)
+	if err != nil {
+		log.Printf("Import schedule check failed: %v", err)
+		return
+	}
+	type dueFeed struct {
+		id      string
+		lastRun *time.Time
+		trigger string
+	}
+	var due []dueFeed
+	loc := b.location()
+	for rows.Next() {
+		var id, schedule string
+		var lastRun, retryAt *time.Time
+		rows.Scan(&id, &schedule, &lastRun, &retryAt)
+		if s, ok := parseFeedSchedule(schedule); ok && !s.due(lastRun, now, loc).After(now) {
+			due = append(due, dueFeed{id, lastRun, importTriggerScheduled})
+		} else if ok && retryAt != nil && !retryAt.After(now) {
+			due = append(due, dueFeed{id, lastRun, importTriggerRetry})
+		}
+	}
+	rows.Close()
+
+	for _, f := range due {
+		if blackout {
+			deferred = append(deferred, f.id)
+			continue
+		}
+		if h.imports.active(f.id) || !h.claimScheduledImport(ctx, f.id, f.lastRun) {
+			continue
+		}
+		feed, err := h.loadFeed(ctx, f.id)
+		if err != nil {
+			continue
+		}
+		logLine := "Scheduled import for: " + feed.Name
+		if f.trigger == importTriggerRetry {
+			logLine = "Retry of failed import for: " + feed.Name
+		}
+		if _, err := h.enqueueImport(ctx, feed, f.trigger, logLine); err != nil {
+			// a manual start won the race since the active check above
+			log.Printf("%s of feed %s skipped: %v", f.trigger, feed.ID, err)
+			continue
+		}
+		if f.trigger == importTriggerRetry {
+			log.Printf("Retrying failed import of feed %s (%s)", feed.ID, feed.Name)
+		} else {
+			log.Printf("Scheduled import of feed %s (%s)", feed.ID, feed.Name)
+		}
+	}
+}
+
+// claimScheduledImport marks a due feed queued unless another scheduler pass or instance
+// got there first: the claim only succeeds while last_run is still the value the pass
+// read, and not while a fresh import of the feed is queued or running. A pending retry
+// is used up by the claim.
+func (h *Handlers) claimScheduledImport(ctx context.Context, feedID string, lastRun *time.Time) bool {
+	tag, err := h.db.Pool.Exec(ctx, `
+		UPDATE feeds SET last_status = 'queued', last_run = NOW(), retry_at = NULL
+		WHERE id = $1::uuid AND last_run IS NOT DISTINCT FROM $2
+		  AND (COALESCE(last_status,'idle') NOT IN ('queued','running') OR last_run IS NULL OR last_run < NOW() - $3::interval)
+	`, feedID, lastRun, fmt.Sprintf("%d seconds", int(staleImportClaim.Seconds())))
+	return err == nil && tag.RowsAffected() == 1
+}
+
+// deferESSync records that the feed's products still have to be indexed; it reports
+// false when no sync needs deferring right now
+func (h *Handlers) deferESSync(ctx context.Context, feedID string) (time.Time, bool) {
+	b := h.importBlackout()
+	now := time.Now()
+	if h.es == nil || !b.DeferESSync || !b.activeAt(now) {
+		return now, false
+	}
+	if _, err := h.db.Pool.Exec(ctx, "UPDATE feeds SET es_sync_deferred_at = NOW() WHERE id = $1::uuid", feedID); err != nil {
+		return now, false
+	}
+	return b.nextAllowed(now), true
+}
+
+// runDeferredESSyncs indexes the products of feeds whose sync was held back by a blackout window
+func (h *Handlers) runDeferredESSyncs(ctx context.Context) {
+	rows, err := h.db.Pool.Query(ctx, "SELECT id::text FROM feeds WHERE es_sync_deferred_at IS NOT NULL")
+	if err != nil {
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		// a running import syncs the feed itself when it finishes
+		if h.imports.active(id) {
+			continue
+		}
+		if err := h.syncFeedProductsToES(ctx, id); err != nil {
+			log.Printf("Deferred Elasticsearch sync of feed %s failed: %v", id, err)
+			continue
+		}
+		h.db.Pool.Exec(ctx, "UPDATE feeds SET es_sync_deferred_at = NULL WHERE id = $1::uuid", id)
+		log.Printf("Deferred Elasticsearch sync of feed %s completed", id)
+	}
+}
+
+// GetImportSchedule shows the blackout setting, whether a window is active now, the
+// next allowed slot, the last scheduler pass and each feed's next scheduled run
+func (h *Handlers) GetImportSchedule(c *fiber.Ctx) error {
+	ctx := context.Background()
+	b := h.importBlackout()
+	now := time.Now()
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT id::text, name, COALESCE(schedule,'daily'), COALESCE(is_active,true), last_run, es_sync_deferred_at
+		FROM feeds ORDER BY name
+	`)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+	feeds := []fiber.Map{}
+	for rows.Next() {
+		var id, name, schedule string
+		var isActive bool
+		var lastRun, esDeferredAt *time.Time
+		rows.Scan(&id, &name, &schedule, &isActive, &lastRun, &esDeferredAt)
+		feeds = append(feeds, fiber.Map{
+			"id": id, "name": name, "schedule": schedule, "is_active": isActive, "last_run": lastRun,
+			"next_run_at": nextScheduledRun(b, schedule, isActive, lastRun, now), "es_sync_deferred_at": esDeferredAt,
+		})
+	}
+
+	importSchedulerMutex.Lock()
+	scheduler := importScheduler
+	importSchedulerMutex.Unlock()
+
+	if b.Windows == nil {
+		b.Windows = []BlackoutWindow{}
+	}
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"blackout": b, "blackout_active": b.activeAt(now), "next_allowed_at": b.nextAllowed(now),
+		"scheduler": scheduler, "feeds": feeds,
+	}})
+}
+
+// SetImportBlackout replaces the blackout windows for every instance sharing the database
+func (h *Handlers) SetImportBlackout(c *fiber.Ctx) error {
+	var input ImportBlackout
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if msg := input.validate(); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+	if input.Windows == nil {
+		input.Windows = []BlackoutWindow{}
+	}
+
+	ctx := context.Background()
+	if _, err := h.settings.SetValue(ctx, settings.KeyImportBlackout, input, auditActor(c)); err != nil {
+		return settingsError(c, h.settings.Keys(), err)
+	}
+	h.audit(ctx, c, "import_blackout.update", "settings", "", fiber.Map{"windows": input.Windows, "defer_es_sync": input.DeferESSync})
+
+	return c.JSON(fiber.Map{"success": true, "data": input})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import "fmt"
+
+// ========== IMPORT SKIP REASONS ==========
+
+// Reasons an import leaves a feed item out
+const (
+	skipMissingTitle     = "missing_title"
+	skipInvalidPrice     = "invalid_price"
+	skipDuplicateInFeed  = "duplicate_in_feed"
+	skipUnmappedCategory = "unmapped_category"
+)
+
+// ImportOutcomes classifies what happened to feed items that did not import cleanly
+type ImportOutcomes struct {
+	// ValidationSkip counts items rejected for their content: skipped items plus
+	// items with an invalid price or an unmapped category
+	ValidationSkip int `json:"validation_skip"`
+	// DBError counts items whose write still failed after all retries
+	DBError int `json:"db_error"`
+	// ESError counts written items left out of the search index by a failed sync
+	ESError int `json:"es_error"`
+	// Retried counts items whose write hit a transient database error and was
+	// retried at the end of the run, Recovered those that then succeeded
+	Retried   int `json:"retried"`
+	Recovered int `json:"recovered"`
+}
+
+// maxSkipExamples caps the item identifiers kept per reason
+const maxSkipExamples = 100
+
+// SkipReason counts the items skipped for one reason, with the first identifiers as examples
+type SkipReason struct {
+	Count    int      `json:"count"`
+	Examples []string `json:"examples"`
+}
+
+// itemIdentifier names a feed item for skip examples: EAN, then SKU, then title, then its position
+func itemIdentifier(data map[string]interface{}, index int) string {
+	for _, key := range []string{"ean", "sku", "title"} {
+		if v := getStr(data, key); v != "" {
+			return v
+		}
+	}
+	return fmt.Sprintf("#%d", index+1)
+}
+
+// duplicateKey is the key used to spot an item repeated within one feed file
+func duplicateKey(data map[string]interface{}) string {
+	if ean := getStr(data, "ean"); ean != "" {
+		return "ean:" + ean
+	}
+	if sku := getStr(data, "sku"); sku != "" {
+		return "sku:" + sku
+	}
+	return ""
+}
+
+// recordSkip adds a skipped item to the running import's breakdown
+func recordSkip(feedID, reason, identifier string) {
+	progressMutex.Lock()
+	defer progressMutex.Unlock()
+	p, ok := importProgress[feedID]
+	if !ok {
+		return
+	}
+	if p.SkipReasons == nil {
+		p.SkipReasons = map[string]SkipReason{}
+	}
+	r := p.SkipReasons[reason]
+	r.Count++
+	if len(r.Examples) < maxSkipExamples {
+		r.Examples = append(r.Examples, identifier)
+	}
+	p.SkipReasons[reason] = r
+}
+
+func nonNilSkipReasons(m map[string]SkipReason) map[string]SkipReason {
+	if m == nil {
+		return map[string]SkipReason{}
+	}
+	return m
+}
+
+// snapshot copies the progress so it can be read after progressMutex is released
+func (p *ImportProgress) snapshot() ImportProgress {
+	s := *p
+	s.Logs = append([]string(nil), p.Logs...)
+	s.CreatedCategories = append([]ImportedCategory(nil), p.CreatedCategories...)
+	s.ErrorSummary = append([]ImportError(nil), p.ErrorSummary...)
+	if p.Error != nil {
+		e := *p.Error
+		s.Error = &e
+	}
+	if p.SkipReasons != nil {
+		s.SkipReasons = make(map[string]SkipReason, len(p.SkipReasons))
+		for reason, r := range p.SkipReasons {
+			r.Examples = append([]string(nil), r.Examples...)
+			s.SkipReasons[reason] = r
+		}
+	}
+	return s
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"hash/fnv"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// ========== IMPORT WORKERS ==========
+
+const (
+	defaultImportWorkers = 8
+	maxImportWorkers     = 32
+)
+
+// importWorkerCount reads how many goroutines write one import's items from
+// IMPORT_WORKERS (default 8, capped at 32). Each worker holds at most one database
+// connection at a time, so the pool must fit MAX_CONCURRENT_IMPORTS times this.
+func importWorkerCount() int {
+	n, err := strconv.Atoi(os.Getenv("IMPORT_WORKERS"))
+	if err != nil || n <= 0 {
+		return defaultImportWorkers
+	}
+	if n > maxImportWorkers {
+		return maxImportWorkers
+	}
+	return n
+}
+
+// importJob is a mapped item handed to an import worker; index is its position in the feed
+type importJob struct {
+	index  int
+	data   map[string]interface{}
+	params []map[string]string
+}
+
+// categoryNodeLocks serializes the lookup and creation of one category node, so
+// import workers, and concurrent imports, resolving the same path create it once.
+// Nodes hash onto a fixed set of mutexes; unrelated nodes rarely share one.
+var categoryNodeLocks [64]sync.Mutex
+
+// lockCategoryNode locks the node with the given slug under parentID ("" for the
+// root) and returns the unlock function
+func lockCategoryNode(parentID, slug string) func() {
+	hash := fnv.New32a()
+	hash.Write([]byte(parentID + "/" + slug))
+	mu := &categoryNodeLocks[hash.Sum32()%uint32(len(categoryNodeLocks))]
+	mu.Lock()
+	return mu.Unlock
+}
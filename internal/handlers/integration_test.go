@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/models"
+	"megabuy-go/internal/testutil"
+)
+
+// TestIntegration exercises the critical API flows end to end against the test
+// database, with Elasticsearch replaced by the FakeES of the test environment:
+// product CRUD and indexing, a feed import followed through progress polling, the
+// category tree it builds, the storefront listing filters and compressed feeds. The
+// subtests run in order; the tree and listing ones use the import's products.
+func TestIntegration(t *testing.T) {
+	env := newTestEnv(t)
+	s := &integration{env: env, h: New(env.db)}
+	go s.h.RunESSyncWorker(50 * time.Millisecond)
+	t.Cleanup(func() {
+		s.h.DrainESSync(10 * time.Second)
+		for _, id := range s.feedIDs {
+			env.db.Pool.Exec(context.Background(), "DELETE FROM products WHERE feed_id = $1::uuid", id)
+		}
+	})
+	s.app = newIntegrationApp(s.h)
+
+	t.Run("product CRUD and indexing", s.productCRUD)
+	t.Run("feed import", s.feedImport)
+	t.Run("category tree", s.categoryTree)
+	t.Run("listing filters", s.listingFilters)
+	t.Run("compressed feeds", s.compressedFeeds)
+}
+
+const (
+	integrationFeedItems = 300
+	integrationESTimeout = 10 * time.Second
+)
+
+// integration is the state shared by the subtests of TestIntegration
+type integration struct {
+	env     *testEnv
+	h       *Handlers
+	app     *fiber.App
+	feedIDs []string
+
+	// set by the import subtest
+	feedID     string
+	categories []ImportedCategory
+}
+
+// newIntegrationApp serves the routes of cmd/main.go
+func newIntegrationApp(h *Handlers) *fiber.App {
+	app := fiber.New(fiber.Config{BodyLimit: 50 * 1024 * 1024})
+	RegisterRoutes(app, h)
+	return app
+}
+
+// apiEnvelope is the response body of every endpoint with typed data
+type apiEnvelope[T any] struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+	Data    T      `json:"data"`
+}
+
+// call sends a request to the app and decodes the JSON response into out, when given
+func (s *integration) call(t *testing.T, method, path string, body interface{}, header map[string]string, out interface{}) *http.Response {
+	t.Helper()
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, r)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: status %d, body not JSON: %v", method, path, resp.StatusCode, err)
+		}
+	}
+	return resp
+}
+
+// expectStatus stops the subtest unless resp has the given status
+func expectStatus(t *testing.T, resp *http.Response, status int, errMsg string) {
+	t.Helper()
+	if resp.StatusCode != status {
+		t.Fatalf("%s %s: status %d, want %d (%s)", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, status, errMsg)
+	}
+}
+
+// eventually polls cond until it holds or timeout passes
+func eventually(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if cond() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// integrationDoc is the part of an indexed product the flows compare
+type integrationDoc struct {
+	Title    string  `json:"title"`
+	PriceMin float64 `json:"price_min"`
+}
+
+func (s *integration) esDoc(id string) (integrationDoc, bool) {
+	var doc integrationDoc
+	raw, ok := s.env.es.Doc(id)
+	if ok {
+		json.Unmarshal(raw, &doc)
+	}
+	return doc, ok
+}
+
+func (s *integration) productCRUD(t *testing.T) {
+	product := map[string]interface{}{
+		"title": "E2E Kávovar Espresso", "ean": "4006381333931", "sku": "E2E-1", "brand_name": "E2E",
+		"price_min": 249.9, "price_max": 249.9, "stock_status": "instock", "is_active": true,
+	}
+	var created apiEnvelope[struct {
+		ID string `json:"id"`
+	}]
+	resp := s.call(t, http.MethodPost, "/api/v1/admin/products", product, nil, &created)
+	expectStatus(t, resp, http.StatusCreated, created.Error)
+	id := created.Data.ID
+	path := "/api/v1/admin/products/" + id
+	if !eventually(integrationESTimeout, func() bool { doc, ok := s.esDoc(id); return ok && doc.Title == product["title"] }) {
+		t.Fatalf("created product %s was not indexed", id)
+	}
+
+	var read apiEnvelope[map[string]interface{}]
+	resp = s.call(t, http.MethodGet, path, nil, nil, &read)
+	expectStatus(t, resp, http.StatusOK, read.Error)
+	if read.Data["title"] != product["title"] || read.Data["ean"] != product["ean"] {
+		t.Fatalf("read back title %v, ean %v", read.Data["title"], read.Data["ean"])
+	}
+	etag := resp.Header.Get("ETag")
+
+	product["title"], product["price_min"], product["price_max"] = "E2E Kávovar Espresso Pro", 229.9, 229.9
+	var updated apiEnvelope[json.RawMessage]
+	resp = s.call(t, http.MethodPut, path, product, map[string]string{"If-Match": etag}, &updated)
+	expectStatus(t, resp, http.StatusOK, updated.Error)
+	if !eventually(integrationESTimeout, func() bool { doc, _ := s.esDoc(id); return doc.Title == product["title"] && doc.PriceMin == 229.9 }) {
+		t.Fatalf("update of product %s was not indexed", id)
+	}
+
+	// a second save based on the old version loses against the first
+	resp = s.call(t, http.MethodPut, path, product, map[string]string{"If-Match": etag}, &updated)
+	expectStatus(t, resp, http.StatusConflict, "stale If-Match")
+
+	var deleted apiEnvelope[json.RawMessage]
+	resp = s.call(t, http.MethodDelete, path, nil, nil, &deleted)
+	expectStatus(t, resp, http.StatusOK, deleted.Error)
+	resp = s.call(t, http.MethodGet, path, nil, nil, nil)
+	expectStatus(t, resp, http.StatusNotFound, "deleted product")
+	if !eventually(integrationESTimeout, func() bool { _, ok := s.env.es.Doc(id); return !ok }) {
+		t.Fatalf("deleted product %s is still indexed", id)
+	}
+}
+
+func (s *integration) feedImport(t *testing.T) {
+	ctx := context.Background()
+	spec := testutil.FeedSpec{
+		Format: testutil.FormatHeureka, Items: integrationFeedItems, Categories: 6, Params: 3, Images: 1,
+		DescriptionWords: 20, DuplicateRate: 0.02, InvalidRate: 0.02, Seed: 42,
+	}
+	srv := testutil.ServeFeed(spec)
+	defer srv.Close()
+
+	s.feedID = s.createFeed(t, "E2E feed", srv, spec)
+	progress := s.importFeed(t, s.feedID)
+	if progress.Status != "completed" {
+		t.Fatalf("import ended %s: %s", progress.Status, progress.Message)
+	}
+	if progress.Processed != progress.Total || progress.Total != spec.Items {
+		t.Fatalf("processed %d of %d items, feed has %d", progress.Processed, progress.Total, spec.Items)
+	}
+	if progress.Errors > 0 || progress.Created == 0 || progress.Created+progress.Updated+progress.Skipped != progress.Total {
+		t.Fatalf("%d created, %d updated, %d skipped, %d errors of %d items",
+			progress.Created, progress.Updated, progress.Skipped, progress.Errors, progress.Total)
+	}
+	if len(progress.CreatedCategories) == 0 {
+		t.Fatal("import created no categories")
+	}
+	s.categories = progress.CreatedCategories
+
+	var ids []string
+	rows, err := s.env.db.Pool.Query(ctx, "SELECT id::text FROM products WHERE feed_id = $1::uuid", s.feedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if len(ids) != progress.Created {
+		t.Fatalf("%d products stored, import reported %d created", len(ids), progress.Created)
+	}
+	var missing []string
+	if !eventually(integrationESTimeout, func() bool {
+		missing = missing[:0]
+		for _, id := range ids {
+			if _, ok := s.env.es.Doc(id); !ok {
+				missing = append(missing, id)
+			}
+		}
+		return len(missing) == 0
+	}) {
+		t.Fatalf("%d of %d imported products not indexed", len(missing), len(ids))
+	}
+
+	// the feed server answers the stored ETag with 304, so nothing is written again
+	progress = s.importFeed(t, s.feedID)
+	if progress.Status != "not_modified" {
+		t.Fatalf("unchanged feed imported again with status %s", progress.Status)
+	}
+	var count int
+	s.env.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM products WHERE feed_id = $1::uuid", s.feedID).Scan(&count)
+	if count != len(ids) {
+		t.Fatalf("%d products after the unchanged import, %d before", count, len(ids))
+	}
+
+	var feeds apiEnvelope[[]models.Feed]
+	s.call(t, http.MethodGet, "/api/v1/admin/feeds", nil, nil, &feeds)
+	for _, f := range feeds.Data {
+		if f.ID == s.feedID && (f.SourceModifiedAt == nil || f.SourceCheckedAt == nil) {
+			t.Fatal("feed listing lacks source_modified_at or source_checked_at")
+		}
+	}
+}
+
+// createFeed stores an inactive feed of srv through the admin API; its products are
+// deleted when TestIntegration ends
+func (s *integration) createFeed(t *testing.T, name string, srv *httptest.Server, spec testutil.FeedSpec) string {
+	t.Helper()
+	var created apiEnvelope[struct {
+		ID string `json:"id"`
+	}]
+	resp := s.call(t, http.MethodPost, "/api/v1/admin/feeds", map[string]interface{}{
+		"name": name, "url": testutil.FeedURL(srv), "type": string(spec.Format), "is_active": false,
+	}, nil, &created)
+	expectStatus(t, resp, http.StatusCreated, created.Error)
+	s.feedIDs = append(s.feedIDs, created.Data.ID)
+	return created.Data.ID
+}
+
+// importFeed starts an import of a feed, once its previous one has freed the slot,
+// and polls its progress until it ends
+func (s *integration) importFeed(t *testing.T, feedID string) ImportProgress {
+	t.Helper()
+	path := "/api/v1/admin/feeds/" + feedID
+	var started apiEnvelope[json.RawMessage]
+	var resp *http.Response
+	eventually(30*time.Second, func() bool {
+		resp = s.call(t, http.MethodPost, path+"/import", nil, nil, &started)
+		return resp.StatusCode != http.StatusConflict
+	})
+	expectStatus(t, resp, http.StatusOK, started.Error)
+
+	var progress apiEnvelope[ImportProgress]
+	statuses := map[string]bool{}
+	done := eventually(2*time.Minute, func() bool {
+		s.call(t, http.MethodGet, path+"/progress", nil, nil, &progress)
+		statuses[progress.Data.Status] = true
+		switch progress.Data.Status {
+		case "completed", "failed", "not_modified", "skipped":
+			return true
+		}
+		return false
+	})
+	if !done {
+		t.Fatalf("import still %s after 2m (seen %v)", progress.Data.Status, statuses)
+	}
+	return progress.Data
+}
+
+func (s *integration) categoryTree(t *testing.T) {
+	if len(s.categories) == 0 {
+		t.Fatal("needs the categories of the feed import")
+	}
+	var ids []string
+	for _, c := range s.categories {
+		ids = append(ids, c.ID)
+	}
+	var approved apiEnvelope[json.RawMessage]
+	resp := s.call(t, http.MethodPost, "/api/v1/admin/categories/pending/approve", map[string]interface{}{"ids": ids}, nil, &approved)
+	expectStatus(t, resp, http.StatusOK, approved.Error)
+
+	roots := s.tree(t)
+	for _, c := range s.categories {
+		if findCategory(roots, strings.Split(c.SourcePath, " > ")) == nil {
+			t.Fatalf("category %q missing from the tree", c.SourcePath)
+		}
+	}
+
+	// a parent's listing includes the products of its whole subtree
+	root := s.importedRoot(t, roots)
+	var listing apiEnvelope[integrationPage]
+	resp = s.call(t, http.MethodGet, "/api/v1/categories/"+root.Slug+"/products?limit=100", nil, nil, &listing)
+	expectStatus(t, resp, http.StatusOK, listing.Error)
+	var want int64
+	s.env.db.Pool.QueryRow(context.Background(), `
+		WITH RECURSIVE sub AS (
+			SELECT id FROM categories WHERE id = $1::uuid
+			UNION ALL
+			SELECT c.id FROM categories c JOIN sub ON c.parent_id = sub.id
+		)
+		SELECT COUNT(*) FROM products WHERE category_id IN (SELECT id FROM sub) AND is_active = true
+	`, root.ID).Scan(&want)
+	if want == 0 || listing.Data.Total != want {
+		t.Fatalf("category %s lists %d products, its subtree has %d", root.Slug, listing.Data.Total, want)
+	}
+}
+
+func (s *integration) tree(t *testing.T) []*models.Category {
+	t.Helper()
+	var tree apiEnvelope[[]*models.Category]
+	resp := s.call(t, http.MethodGet, "/api/v1/categories/tree", nil, nil, &tree)
+	expectStatus(t, resp, http.StatusOK, tree.Error)
+	return tree.Data
+}
+
+// importedRoot is the root category of the first category the feed import created
+func (s *integration) importedRoot(t *testing.T, roots []*models.Category) *models.Category {
+	t.Helper()
+	if len(s.categories) == 0 {
+		t.Fatal("needs the categories of the feed import")
+	}
+	root := findCategory(roots, strings.Split(s.categories[0].SourcePath, " > ")[:1])
+	if root == nil {
+		t.Fatalf("root of %q missing from the tree", s.categories[0].SourcePath)
+	}
+	return root
+}
+
+// findCategory follows a path of category names down from the roots
+func findCategory(nodes []*models.Category, path []string) *models.Category {
+	for _, n := range nodes {
+		if n.Name != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return n
+		}
+		return findCategory(n.Children, path[1:])
+	}
+	return nil
+}
+
+func subtreeSlugs(c *models.Category, slugs map[string]bool) map[string]bool {
+	slugs[c.Slug] = true
+	for _, child := range c.Children {
+		subtreeSlugs(child, slugs)
+	}
+	return slugs
+}
+
+type integrationPage struct {
+	Items []models.ProductCard `json:"items"`
+	Total int64                `json:"total"`
+}
+
+func (s *integration) listing(t *testing.T, query url.Values) integrationPage {
+	t.Helper()
+	query.Set("limit", "100")
+	var page apiEnvelope[integrationPage]
+	resp := s.call(t, http.MethodGet, "/api/v1/products?"+query.Encode(), nil, nil, &page)
+	expectStatus(t, resp, http.StatusOK, page.Error)
+	if page.Data.Total == 0 {
+		t.Fatalf("no products for %s", query.Encode())
+	}
+	return page.Data
+}
+
+func (s *integration) listingFilters(t *testing.T) {
+	all := s.listing(t, url.Values{})
+
+	page := s.listing(t, url.Values{"min_price": {"100"}, "max_price": {"800"}})
+	for _, p := range page.Items {
+		if p.PriceMin.Float() < 100 || p.PriceMin.Float() > 800 {
+			t.Errorf("price filter 100-800 returned %s at %v", p.Slug, p.PriceMin)
+		}
+	}
+	if page.Total >= all.Total {
+		t.Errorf("price filter kept all %d products", all.Total)
+	}
+
+	page = s.listing(t, url.Values{"in_stock": {"true"}})
+	for _, p := range page.Items {
+		if p.StockStatus != "instock" {
+			t.Errorf("stock filter returned %s, %s", p.Slug, p.StockStatus)
+		}
+	}
+
+	brand := all.Items[0].Brand
+	page = s.listing(t, url.Values{"brand": {brand}})
+	var want int64
+	s.env.db.Pool.QueryRow(context.Background(), "SELECT COUNT(*) FROM products WHERE brand = $1 AND is_active = true", brand).Scan(&want)
+	if page.Total != want {
+		t.Errorf("brand %s lists %d products, %d stored", brand, page.Total, want)
+	}
+	for _, p := range page.Items {
+		if p.Brand != brand {
+			t.Errorf("brand filter %s returned %s of %s", brand, p.Slug, p.Brand)
+		}
+	}
+
+	root := s.importedRoot(t, s.tree(t))
+	slugs := subtreeSlugs(root, map[string]bool{})
+	page = s.listing(t, url.Values{"category": {root.Slug}})
+	for _, p := range page.Items {
+		if !slugs[p.CategorySlug] {
+			t.Errorf("category filter %s returned %s of %s", root.Slug, p.Slug, p.CategorySlug)
+		}
+	}
+}
+
+// compressedFeeds previews gzip and zip feeds and imports a gzip one
+func (s *integration) compressedFeeds(t *testing.T) {
+	for _, compression := range []string{"gzip", "zip"} {
+		spec := testutil.FeedSpec{Format: testutil.FormatHeureka, Items: 50, Categories: 3, DescriptionWords: 10, Seed: 7, Compression: compression}
+		srv := testutil.ServeFeed(spec)
+		defer srv.Close()
+
+		var preview apiEnvelope[FeedPreview]
+		resp := s.call(t, http.MethodPost, "/api/v1/admin/feeds/preview", map[string]interface{}{"url": testutil.FeedURL(srv)}, nil, &preview)
+		expectStatus(t, resp, http.StatusOK, preview.Error)
+		if preview.Data.TotalItems != spec.Items || preview.Data.SampleSize == 0 {
+			t.Errorf("%s preview found %d items (%d sampled), feed has %d",
+				compression, preview.Data.TotalItems, preview.Data.SampleSize, spec.Items)
+		}
+
+		if compression != "gzip" {
+			continue
+		}
+		progress := s.importFeed(t, s.createFeed(t, "E2E gzip feed", srv, spec))
+		if progress.Status != "completed" || progress.Total != spec.Items || progress.Errors > 0 {
+			t.Errorf("gzip import ended %s with %d of %d items, %d errors: %s",
+				progress.Status, progress.Total, spec.Items, progress.Errors, progress.Message)
+		}
+	}
+}
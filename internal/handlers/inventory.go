@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/money"
+)
+
+// ========== INVENTORY API ==========
+
+// maxInventoryBatch caps the entries accepted by one UpdateInventory call
+const maxInventoryBatch = 5000
+
+// InventoryEntry is one stock and/or price update pushed by the warehouse system.
+// Products are matched by SKU, or by EAN when no SKU is given.
+type InventoryEntry struct {
+	SKU           string       `json:"sku"`
+	EAN           string       `json:"ean"`
+	StockQuantity *int         `json:"stock_quantity"`
+	Price         *money.Price `json:"price"`
+}
+
+// InventoryResult reports what happened to one entry: updated, not_found or invalid
+type InventoryResult struct {
+	Index    int    `json:"index"`
+	SKU      string `json:"sku,omitempty"`
+	EAN      string `json:"ean,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Products int    `json:"products,omitempty"`
+}
+
+// validateInventoryEntry normalizes e in place and returns why it cannot be applied
+func validateInventoryEntry(e *InventoryEntry) string {
+	e.SKU = strings.TrimSpace(e.SKU)
+	if e.SKU == "" {
+		raw := strings.TrimSpace(e.EAN)
+		if raw == "" {
+			return "sku or ean is required"
+		}
+		ean, _ := splitEAN(raw)
+		if ean == "" {
+			return "invalid ean"
+		}
+		e.EAN = ean
+	}
+	if e.StockQuantity == nil && e.Price == nil {
+		return "stock_quantity or price is required"
+	}
+	if e.StockQuantity != nil && *e.StockQuantity < 0 {
+		return "stock_quantity must not be negative"
+	}
+	if e.Price != nil && *e.Price <= 0 {
+		return "price must be greater than 0"
+	}
+	return ""
+}
+
+// UpdateInventory applies a batch of stock levels and prices from the warehouse
+// system in one statement. stock_status follows the quantity: above zero is in
+// stock, zero is out of stock unless the product is on preorder. Restocked products
+// fire back-in-stock alerts and the changed products are reindexed in one bulk
+// request.
+func (h *Handlers) UpdateInventory(c *fiber.Ctx) error {
+	started := time.Now()
+	var input struct {
+		Entries []InventoryEntry `json:"entries"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if len(input.Entries) == 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "entries is required"})
+	}
+	if len(input.Entries) > maxInventoryBatch {
+		return c.Status(413).JSON(fiber.Map{"success": false, "error": fmt.Sprintf("at most %d entries per request", maxInventoryBatch)})
+	}
+
+	results := make([]InventoryResult, len(input.Entries))
+	var idx []int32
+	var skus, eans []string
+	var qtys []*int
+	// plain floats: pgx cannot encode a nil *money.Price array element as NULL
+	var prices []*float64
+	seen := map[string]int{}
+	for i := range input.Entries {
+		e := &input.Entries[i]
+		msg := validateInventoryEntry(e)
+		results[i] = InventoryResult{Index: i, SKU: e.SKU, EAN: e.EAN, Status: "invalid", Error: msg}
+		if msg != "" {
+			continue
+		}
+		key := "sku:" + e.SKU
+		if e.SKU == "" {
+			key = "ean:" + e.EAN
+		}
+		if first, dup := seen[key]; dup {
+			results[i].Error = fmt.Sprintf("duplicate of entry %d", first)
+			continue
+		}
+		seen[key] = i
+		results[i] = InventoryResult{Index: i, SKU: e.SKU, EAN: e.EAN, Status: "not_found"}
+		idx = append(idx, int32(i))
+		skus = append(skus, e.SKU)
+		eans = append(eans, e.EAN)
+		qtys = append(qtys, e.StockQuantity)
+		var price *float64
+		if e.Price != nil {
+			v := e.Price.Float()
+			price = &v
+		}
+		prices = append(prices, price)
+	}
+
+	ctx := context.Background()
+	var updatedIDs, restocked []string
+	priceChanged := false
+	if len(idx) > 0 {
+		rows, err := h.db.Pool.Query(ctx, `
+			WITH input AS (
+				SELECT * FROM unnest($1::int[], $2::text[], $3::text[], $4::int[], $5::numeric[]) AS t(idx, sku, ean, qty, price)
+			), by_sku AS (
+				SELECT i.idx, p.id, COALESCE(p.stock_status,'instock') AS old_status
+				FROM input i JOIN products p ON p.sku = i.sku
+				WHERE i.sku <> ''
+				FOR UPDATE OF p
+			), by_ean AS (
+				SELECT i.idx, p.id, COALESCE(p.stock_status,'instock') AS old_status
+				FROM input i JOIN products p ON p.ean = i.ean
+				WHERE i.sku = ''
+				FOR UPDATE OF p
+			), target AS (
+				SELECT t.idx, t.id, t.old_status, i.qty, i.price
+				FROM (SELECT * FROM by_sku UNION ALL SELECT * FROM by_ean) t JOIN input i ON i.idx = t.idx
+			)
+			UPDATE products p SET
+			       stock_quantity = COALESCE(t.qty, p.stock_quantity),
+			       stock_status = CASE
+			           WHEN t.qty IS NULL THEN p.stock_status
+			           WHEN t.qty > 0 THEN 'instock'
+			           WHEN t.old_status = 'preorder' THEN 'preorder'
+			           ELSE 'outofstock' END,
+			       price_min = COALESCE(t.price, p.price_min),
+			       price_max = COALESCE(t.price, p.price_max),
+			       price_min_net = COALESCE(ROUND(t.price / (1 + COALESCE(p.vat_rate, 20) / 100), 2), p.price_min_net),
+			       price_max_net = COALESCE(ROUND(t.price / (1 + COALESCE(p.vat_rate, 20) / 100), 2), p.price_max_net),
+			       price_high = GREATEST(COALESCE(p.price_high,0), COALESCE(t.price,0)),
+			       updated_at = NOW(), version = COALESCE(p.version,1) + 1
+			FROM target t
+			WHERE p.id = t.id
+			RETURNING t.idx, p.id::text, t.old_status, COALESCE(p.stock_status,'instock'), t.price IS NOT NULL
+		`, idx, skus, eans, qtys, prices)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+		}
+		for rows.Next() {
+			var i int
+			var id, oldStatus, newStatus string
+			var withPrice bool
+			if err := rows.Scan(&i, &id, &oldStatus, &newStatus, &withPrice); err != nil {
+				rows.Close()
+				return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+			}
+			results[i].Status = "updated"
+			results[i].Products++
+			updatedIDs = append(updatedIDs, id)
+			if isBackInStock(oldStatus, newStatus) {
+				restocked = append(restocked, id)
+			}
+			priceChanged = priceChanged || withPrice
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+		}
+	}
+
+	h.fireStockAlerts(ctx, restocked)
+	if priceChanged {
+		go h.checkPriceAlerts(context.Background())
+	}
+	esSynced := h.bulkSyncProducts(ctx, updatedIDs)
+
+	counts := map[string]int{"updated": 0, "not_found": 0, "invalid": 0}
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	h.audit(ctx, c, "inventory.update", "product", "", fiber.Map{
+		"source": c.Locals(apiKeyLocal), "entries": len(results), "products": len(updatedIDs),
+		"updated": counts["updated"], "not_found": counts["not_found"], "invalid": counts["invalid"],
+	})
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"results": results, "updated": counts["updated"], "not_found": counts["not_found"], "invalid": counts["invalid"],
+		"es_synced": esSynced, "duration_ms": time.Since(started).Milliseconds(),
+	}})
+}
+
+// bulkSyncProducts queues products for the ES sync queue and writes them right away;
+// false means some are left to the sync worker's retries
+func (h *Handlers) bulkSyncProducts(ctx context.Context, ids []string) bool {
+	if len(ids) == 0 || h.es == nil {
+		return len(ids) == 0
+	}
+	if err := h.enqueueESSync(ctx, "index", "SELECT unnest($2::uuid[])", ids); err != nil {
+		log.Printf("Inventory ES sync of %d products not queued: %v", len(ids), err)
+		h.queueESSync(ids...)
+		return false
+	}
+	_, failed, err := h.processESSync(ctx, newWhere().add("q.product_id = ANY(?::uuid[])", ids))
+	if err != nil || failed > 0 {
+		log.Printf("Inventory ES sync: %d of %d products left for retry (%v)", failed, len(ids), err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== PRODUCT LABELS ==========
+
+const (
+	// Automatic labels are computed from product data in addition to assignments
+	labelNew  = "new"
+	labelSale = "sale"
+
+	newProductDays      = 14
+	saleDiscountPercent = 10
+	maxBulkLabelItems   = 5000
+)
+
+var (
+	labelSlugPattern  = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+	labelColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+)
+
+// activeLabelsQuery selects the active labels of the products in $1. Expired
+// assignments are skipped here, so they vanish before the purge removes them.
+var activeLabelsQuery = fmt.Sprintf(`
+	SELECT p.id::text, l.slug, l.name, COALESCE(l.color,''), COALESCE(l.priority,0)
+	FROM products p
+	JOIN product_labels l ON EXISTS (
+		SELECT 1 FROM product_label_assignments a
+		WHERE a.product_id = p.id AND a.label_id = l.id AND (a.expires_at IS NULL OR a.expires_at > NOW())
+	) OR (l.slug = '%s' AND p.created_at > NOW() - INTERVAL '%d days')
+	  OR (l.slug = '%s' AND %s > %d)
+	WHERE p.id = ANY($1::uuid[])
+	ORDER BY l.priority DESC, l.name`, labelNew, newProductDays, labelSale, discountColumn, saleDiscountPercent)
+
+// productLabels returns the active labels keyed by product ID
+func (h *Handlers) productLabels(ctx context.Context, ids []string) map[string][]models.Label {
+	labels := map[string][]models.Label{}
+	if len(ids) == 0 {
+		return labels
+	}
+	rows, err := h.db.Pool.Query(ctx, activeLabelsQuery, ids)
+	if err != nil {
+		log.Printf("Loading product labels failed: %v", err)
+		return labels
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var l models.Label
+		rows.Scan(&id, &l.Slug, &l.Name, &l.Color, &l.Priority)
+		labels[id] = append(labels[id], l)
+	}
+	return labels
+}
+
+// attachLabels fills Labels of list items; items without labels get an empty list
+func (h *Handlers) attachLabels(ctx context.Context, items []models.ProductCard) {
+	ids := make([]string, len(items))
+	for i, p := range items {
+		ids[i] = p.ID
+	}
+	labels := h.productLabels(ctx, ids)
+	for i := range items {
+		items[i].Labels = append([]models.Label{}, labels[items[i].ID]...)
+	}
+}
+
+// RunLabelPurgeWorker deletes expired label assignments and reindexes products whose
+// labels changed with time, including those that stopped being new
+func (h *Handlers) RunLabelPurgeWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.purgeExpiredLabels(context.Background(), interval)
+	}
+}
+
+func (h *Handlers) purgeExpiredLabels(ctx context.Context, interval time.Duration) {
+	rows, err := h.db.Pool.Query(ctx, `
+		DELETE FROM product_label_assignments WHERE expires_at <= NOW() RETURNING product_id::text
+	`)
+	if err != nil {
+		log.Printf("Label purge failed: %v", err)
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	rows, err = h.db.Pool.Query(ctx, fmt.Sprintf(`
+		SELECT id::text FROM products
+		WHERE created_at <= NOW() - INTERVAL '%[1]d days' AND created_at > NOW() - INTERVAL '%[1]d days' - $1 * INTERVAL '1 second'
+	`, newProductDays), int(interval.Seconds()))
+	if err == nil {
+		for rows.Next() {
+			var id string
+			rows.Scan(&id)
+			ids = append(ids, id)
+		}
+		rows.Close()
+	}
+	if len(ids) > 0 {
+		h.queueESSync(ids...)
+		invalidateHomepage()
+	}
+}
+
+type labelInput struct {
+	Slug     string  `json:"slug"`
+	Name     string  `json:"name"`
+	Color    *string `json:"color"`
+	Priority *int    `json:"priority"`
+}
+
+// validate checks the fields that are set; create additionally requires slug and name
+func (in *labelInput) validate() string {
+	in.Slug = strings.TrimSpace(in.Slug)
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Slug != "" && !labelSlugPattern.MatchString(in.Slug) {
+		return "slug may contain only lowercase letters, digits and dashes"
+	}
+	if in.Color != nil && *in.Color != "" && !labelColorPattern.MatchString(*in.Color) {
+		return "color must be a hex color like #c62828"
+	}
+	return ""
+}
+
+// labelProductIDs returns the products currently carrying a manual assignment of the label
+func (h *Handlers) labelProductIDs(ctx context.Context, labelID string) []string {
+	var ids []string
+	rows, err := h.db.Pool.Query(ctx, "SELECT product_id::text FROM product_label_assignments WHERE label_id = $1::uuid", labelID)
+	if err != nil {
+		return ids
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (h *Handlers) AdminListLabels(c *fiber.Ctx) error {
+	ctx := context.Background()
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT l.id, l.slug, l.name, COALESCE(l.color,''), COALESCE(l.priority,0),
+		       (SELECT COUNT(*) FROM product_label_assignments a WHERE a.label_id = l.id AND (a.expires_at IS NULL OR a.expires_at > NOW()))
+		FROM product_labels l ORDER BY l.priority DESC, l.name
+	`)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+	labels := []fiber.Map{}
+	for rows.Next() {
+		var id string
+		var l models.Label
+		var assigned int
+		rows.Scan(&id, &l.Slug, &l.Name, &l.Color, &l.Priority, &assigned)
+		labels = append(labels, fiber.Map{
+			"id": id, "slug": l.Slug, "name": l.Name, "color": l.Color, "priority": l.Priority,
+			"automatic": l.Slug == labelNew || l.Slug == labelSale, "assigned": assigned,
+		})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": labels})
+}
+
+func (h *Handlers) AdminCreateLabel(c *fiber.Ctx) error {
+	var input labelInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if msg := input.validate(); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+	if input.Name == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "name is required"})
+	}
+	if input.Slug == "" {
+		input.Slug = makeSlug(input.Name)
+	}
+
+	ctx := context.Background()
+	var id string
+	err := h.db.Pool.QueryRow(ctx, `
+		INSERT INTO product_labels (slug, name, color, priority, created_at, updated_at)
+		VALUES ($1, $2, NULLIF($3,''), COALESCE($4, 0), NOW(), NOW())
+		ON CONFLICT (slug) DO NOTHING RETURNING id
+	`, input.Slug, input.Name, input.Color, input.Priority).Scan(&id)
+	if err != nil {
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "Label with this slug already exists"})
+	}
+	return c.Status(201).JSON(fiber.Map{"success": true, "data": fiber.Map{"id": id, "slug": input.Slug}})
+}
+
+func (h *Handlers) AdminUpdateLabel(c *fiber.Ctx) error {
+	labelID := c.Params("id")
+	var input labelInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if msg := input.validate(); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+
+	ctx := context.Background()
+	tag, err := h.db.Pool.Exec(ctx, `
+		UPDATE product_labels SET slug = COALESCE(NULLIF($2,''), slug), name = COALESCE(NULLIF($3,''), name),
+		       color = CASE WHEN $4::text IS NULL THEN color ELSE NULLIF($4,'') END,
+		       priority = COALESCE($5, priority), updated_at = NOW()
+		WHERE id = $1::uuid
+	`, labelID, input.Slug, input.Name, input.Color, input.Priority)
+	if err != nil {
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "Label with this slug already exists"})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Label not found"})
+	}
+	// automatic labels hang off products not listed in assignments; a full resync picks those up
+	h.queueESSync(h.labelProductIDs(ctx, labelID)...)
+	invalidateHomepage()
+	return c.JSON(fiber.Map{"success": true, "message": "Label updated"})
+}
+
+func (h *Handlers) AdminDeleteLabel(c *fiber.Ctx) error {
+	labelID := c.Params("id")
+	ctx := context.Background()
+	ids := h.labelProductIDs(ctx, labelID)
+	tag, err := h.db.Pool.Exec(ctx, "DELETE FROM product_labels WHERE id = $1::uuid", labelID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Label not found"})
+	}
+	h.queueESSync(ids...)
+	invalidateHomepage()
+	return c.JSON(fiber.Map{"success": true, "message": "Label deleted"})
+}
+
+// parseLabelExpiry accepts an RFC 3339 timestamp or a YYYY-MM-DD date, which
+// expires at the end of that day
+func parseLabelExpiry(s string) (*time.Time, bool) {
+	if s == "" {
+		return nil, true
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, true
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		t = t.AddDate(0, 0, 1)
+		return &t, true
+	}
+	return nil, false
+}
+
+// assignLabel upserts assignments of labelID to productIDs and returns how many products exist
+func (h *Handlers) assignLabel(ctx context.Context, labelID string, productIDs []string, expiresAt *time.Time) (int64, error) {
+	tag, err := h.db.Pool.Exec(ctx, `
+		INSERT INTO product_label_assignments (product_id, label_id, expires_at, created_at)
+		SELECT p.id, $2::uuid, $3, NOW() FROM products p WHERE p.id = ANY($1::uuid[])
+		ON CONFLICT (product_id, label_id) DO UPDATE SET expires_at = EXCLUDED.expires_at
+	`, productIDs, labelID, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	h.queueESSync(productIDs...)
+	invalidateHomepage()
+	return tag.RowsAffected(), nil
+}
+
+func (h *Handlers) unassignLabel(ctx context.Context, labelID string, productIDs []string) (int64, error) {
+	tag, err := h.db.Pool.Exec(ctx, `
+		DELETE FROM product_label_assignments WHERE label_id = $1::uuid AND product_id = ANY($2::uuid[])
+	`, labelID, productIDs)
+	if err != nil {
+		return 0, err
+	}
+	h.queueESSync(productIDs...)
+	invalidateHomepage()
+	return tag.RowsAffected(), nil
+}
+
+// AdminAssignProductLabel assigns one label to a product; expires_at is optional
+func (h *Handlers) AdminAssignProductLabel(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	var input struct {
+		LabelID   string `json:"label_id"`
+		ExpiresAt string `json:"expires_at"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if !isUUID(input.LabelID) {
+		return invalidUUIDField(c, "label_id")
+	}
+	expiresAt, ok := parseLabelExpiry(input.ExpiresAt)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "expires_at must be a date (YYYY-MM-DD) or RFC 3339 timestamp"})
+	}
+
+	ctx := context.Background()
+	var exists bool
+	h.db.Pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM product_labels WHERE id = $1::uuid)", input.LabelID).Scan(&exists)
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Label not found"})
+	}
+	assigned, err := h.assignLabel(ctx, input.LabelID, []string{productID}, expiresAt)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if assigned == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Product not found"})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": h.productLabels(ctx, []string{productID})[productID]})
+}
+
+func (h *Handlers) AdminRemoveProductLabel(c *fiber.Ctx) error {
+	removed, err := h.unassignLabel(context.Background(), c.Params("label_id"), []string{c.Params("id")})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if removed == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Label not assigned"})
+	}
+	return c.JSON(fiber.Map{"success": true, "message": "Label removed"})
+}
+
+// AdminBulkLabelProducts assigns or, with "remove": true, unassigns a label for many products
+func (h *Handlers) AdminBulkLabelProducts(c *fiber.Ctx) error {
+	labelID := c.Params("id")
+	var input struct {
+		ProductIDs []string `json:"product_ids"`
+		ExpiresAt  string   `json:"expires_at"`
+		Remove     bool     `json:"remove"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if len(input.ProductIDs) == 0 || len(input.ProductIDs) > maxBulkLabelItems {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "product_ids must list 1 to 5000 products"})
+	}
+	for _, id := range input.ProductIDs {
+		if !isUUID(id) {
+			return invalidUUIDField(c, "product_ids")
+		}
+	}
+	expiresAt, ok := parseLabelExpiry(input.ExpiresAt)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "expires_at must be a date (YYYY-MM-DD) or RFC 3339 timestamp"})
+	}
+
+	ctx := context.Background()
+	var slug string
+	if err := h.db.Pool.QueryRow(ctx, "SELECT slug FROM product_labels WHERE id = $1::uuid", labelID).Scan(&slug); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Label not found"})
+	}
+
+	action := "label.assign"
+	var changed int64
+	var err error
+	if input.Remove {
+		action = "label.unassign"
+		changed, err = h.unassignLabel(ctx, labelID, input.ProductIDs)
+	} else {
+		changed, err = h.assignLabel(ctx, labelID, input.ProductIDs, expiresAt)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	h.audit(ctx, c, action, "label", labelID, fiber.Map{"slug": slug, "products": len(input.ProductIDs), "changed": changed, "expires_at": expiresAt})
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"changed": changed}})
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== AFFILIATE LINK HEALTH ==========
+
+const (
+	linkCheckWorkers = 16
+	// linkCheckPerHost caps parallel requests to one partner
+	linkCheckPerHost = 2
+	linkCheckTimeout = 15 * time.Second
+)
+
+// Link check results
+const (
+	linkOK           = "ok"
+	linkRedirectHome = "redirect_home"
+	linkNotFound     = "not_found"
+	linkTimeout      = "timeout"
+	linkError        = "error"
+)
+
+type LinkCheckProgress struct {
+	Status     string         `json:"status"`
+	Total      int            `json:"total"`
+	Checked    int            `json:"checked"`
+	Results    map[string]int `json:"results"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+}
+
+var (
+	linkCheck      *LinkCheckProgress
+	linkCheckMutex sync.Mutex
+)
+
+type linkCheckResult struct {
+	status     string
+	httpStatus int
+	finalURL   string
+	err        string
+	duration   time.Duration
+}
+
+// StartLinkCheck scans affiliate URLs in the background. mode "sample" checks
+// sample_size random active products, "full" checks all of them.
+func (h *Handlers) StartLinkCheck(c *fiber.Ctx) error {
+	var input struct {
+		Mode       string `json:"mode"`
+		SampleSize int    `json:"sample_size"`
+	}
+	c.BodyParser(&input)
+	if input.Mode == "" {
+		input.Mode = "sample"
+	}
+	if input.Mode != "sample" && input.Mode != "full" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "mode must be sample or full"})
+	}
+	if input.SampleSize <= 0 {
+		input.SampleSize = 200
+	}
+
+	linkCheckMutex.Lock()
+	if linkCheck != nil && linkCheck.Status == "running" {
+		linkCheckMutex.Unlock()
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "Link check already running"})
+	}
+	linkCheck = &LinkCheckProgress{Status: "running", Results: map[string]int{}, StartedAt: time.Now()}
+	linkCheckMutex.Unlock()
+
+	query := "SELECT id, affiliate_url FROM products WHERE is_active = true AND COALESCE(affiliate_url,'') <> ''"
+	args := []interface{}{}
+	if input.Mode == "sample" {
+		query += " ORDER BY random() LIMIT $1"
+		args = append(args, input.SampleSize)
+	}
+	rows, err := h.db.Pool.Query(context.Background(), query, args...)
+	if err != nil {
+		linkCheckMutex.Lock()
+		linkCheck.Status = "failed"
+		linkCheckMutex.Unlock()
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	type target struct{ id, url string }
+	var targets []target
+	for rows.Next() {
+		var t target
+		rows.Scan(&t.id, &t.url)
+		targets = append(targets, t)
+	}
+	rows.Close()
+
+	linkCheckMutex.Lock()
+	linkCheck.Total = len(targets)
+	linkCheckMutex.Unlock()
+
+	go func() {
+		ctx := context.Background()
+		client := &http.Client{Timeout: linkCheckTimeout}
+		hostSlots := map[string]chan struct{}{}
+		var hostMu sync.Mutex
+		slot := func(host string) chan struct{} {
+			hostMu.Lock()
+			defer hostMu.Unlock()
+			if hostSlots[host] == nil {
+				hostSlots[host] = make(chan struct{}, linkCheckPerHost)
+			}
+			return hostSlots[host]
+		}
+
+		jobs := make(chan target)
+		var wg sync.WaitGroup
+		for i := 0; i < linkCheckWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for t := range jobs {
+					host := ""
+					if u, err := url.Parse(t.url); err == nil {
+						host = strings.ToLower(u.Host)
+					}
+					s := slot(host)
+					s <- struct{}{}
+					res := checkLink(client, t.url)
+					<-s
+
+					h.db.Pool.Exec(ctx, `
+						INSERT INTO product_link_checks (product_id, url, status, http_status, final_url, error, duration_ms, checked_at)
+						VALUES ($1::uuid, $2, $3, NULLIF($4,0), NULLIF($5,''), NULLIF($6,''), $7, NOW())
+						ON CONFLICT (product_id) DO UPDATE SET url=EXCLUDED.url, status=EXCLUDED.status, http_status=EXCLUDED.http_status,
+						       final_url=EXCLUDED.final_url, error=EXCLUDED.error, duration_ms=EXCLUDED.duration_ms, checked_at=NOW()
+					`, t.id, t.url, res.status, res.httpStatus, res.finalURL, res.err, res.duration.Milliseconds())
+
+					linkCheckMutex.Lock()
+					linkCheck.Checked++
+					linkCheck.Results[res.status]++
+					linkCheckMutex.Unlock()
+				}
+			}()
+		}
+		for _, t := range targets {
+			jobs <- t
+		}
+		close(jobs)
+		wg.Wait()
+
+		now := time.Now()
+		linkCheckMutex.Lock()
+		linkCheck.Status = "completed"
+		linkCheck.FinishedAt = &now
+		log.Printf("Link check completed: %d links, %v", linkCheck.Checked, linkCheck.Results)
+		linkCheckMutex.Unlock()
+	}()
+
+	return c.Status(202).JSON(fiber.Map{"success": true, "message": fmt.Sprintf("Checking %d links", len(targets))})
+}
+
+// checkLink requests rawURL with HEAD, falling back to GET for servers that reject HEAD
+func checkLink(client *http.Client, rawURL string) linkCheckResult {
+	start := time.Now()
+	resp, err := doLinkRequest(client, "HEAD", rawURL)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented || resp.StatusCode == http.StatusForbidden) {
+		resp.Body.Close()
+		resp, err = doLinkRequest(client, "GET", rawURL)
+	}
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return linkCheckResult{status: linkTimeout, err: err.Error(), duration: time.Since(start)}
+		}
+		return linkCheckResult{status: linkError, err: err.Error(), duration: time.Since(start)}
+	}
+	resp.Body.Close()
+
+	res := linkCheckResult{httpStatus: resp.StatusCode, finalURL: resp.Request.URL.String(), duration: time.Since(start)}
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		res.status = linkNotFound
+	case resp.StatusCode >= 400:
+		res.status = linkError
+	case isRedirectToHome(rawURL, resp.Request.URL):
+		res.status = linkRedirectHome
+	default:
+		res.status = linkOK
+	}
+	if res.finalURL == rawURL {
+		res.finalURL = ""
+	}
+	return res
+}
+
+func doLinkRequest(client *http.Client, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; MegaBuyLinkChecker/1.0)")
+	return client.Do(req)
+}
+
+// isRedirectToHome reports a product link that ended on the shop's front page
+func isRedirectToHome(original string, final *url.URL) bool {
+	orig, err := url.Parse(original)
+	if err != nil || final == nil {
+		return false
+	}
+	origPath := strings.Trim(orig.Path, "/")
+	finalPath := strings.Trim(final.Path, "/")
+	if origPath == "" || origPath == finalPath {
+		return false
+	}
+	switch strings.ToLower(finalPath) {
+	case "", "index.php", "index.html", "sk", "cz", "en", "home":
+		return true
+	}
+	return false
+}
+
+// GetLinkCheckReport returns the running scan progress, result counts and the broken links
+func (h *Handlers) GetLinkCheckReport(c *fiber.Ctx) error {
+	page, limit, offset := adminPageParams(c, 50)
+	ctx := context.Background()
+
+	linkCheckMutex.Lock()
+	var progress *LinkCheckProgress
+	if linkCheck != nil {
+		p := *linkCheck
+		p.Results = map[string]int{}
+		for k, v := range linkCheck.Results {
+			p.Results[k] = v
+		}
+		progress = &p
+	}
+	linkCheckMutex.Unlock()
+
+	summary := map[string]int{}
+	rows, _ := h.db.Pool.Query(ctx, "SELECT status, COUNT(*) FROM product_link_checks GROUP BY status")
+	if rows != nil {
+		for rows.Next() {
+			var status string
+			var n int
+			rows.Scan(&status, &n)
+			summary[status] = n
+		}
+		rows.Close()
+	}
+
+	statuses := splitList(c.Query("status"))
+	if len(statuses) == 0 {
+		statuses = []string{linkRedirectHome, linkNotFound, linkTimeout, linkError}
+	}
+	var total int64
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM product_link_checks WHERE status = ANY($1)", statuses).Scan(&total)
+
+	items := []fiber.Map{}
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT l.product_id, p.title, p.is_active, l.url, l.status, COALESCE(l.http_status,0), COALESCE(l.final_url,''),
+		       COALESCE(l.error,''), l.checked_at
+		FROM product_link_checks l JOIN products p ON p.id = l.product_id
+		WHERE l.status = ANY($1) ORDER BY l.checked_at DESC LIMIT $2 OFFSET $3
+	`, statuses, limit, offset)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, title, linkURL, status, finalURL, errMsg string
+		var isActive bool
+		var httpStatus int
+		var checkedAt time.Time
+		rows.Scan(&id, &title, &isActive, &linkURL, &status, &httpStatus, &finalURL, &errMsg, &checkedAt)
+		items = append(items, fiber.Map{
+			"product_id": id, "title": title, "is_active": isActive, "url": linkURL, "status": status,
+			"http_status": httpStatus, "final_url": finalURL, "error": errMsg, "checked_at": checkedAt,
+		})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{
+		"progress": progress,
+		"summary":  summary,
+		"items":    items,
+	}, page, limit, total)})
+}
+
+// DeactivateDeadLinks deactivates products whose last check has one of the given statuses
+func (h *Handlers) DeactivateDeadLinks(c *fiber.Ctx) error {
+	var input struct {
+		Statuses []string `json:"statuses"`
+	}
+	c.BodyParser(&input)
+	if len(input.Statuses) == 0 {
+		input.Statuses = []string{linkNotFound}
+	}
+	for _, s := range input.Statuses {
+		if s == linkOK {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "Cannot deactivate ok links"})
+		}
+	}
+
+	ctx := context.Background()
+	rows, err := h.db.Pool.Query(ctx, `
+		UPDATE products p SET is_active = false, updated_at = NOW(), version = COALESCE(p.version,1) + 1
+		FROM product_link_checks l
+		WHERE l.product_id = p.id AND l.status = ANY($1) AND p.is_active = true
+		RETURNING p.id::text
+	`, input.Statuses)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	h.queueESSync(ids...)
+
+	return c.JSON(fiber.Map{"success": true, "message": fmt.Sprintf("Deactivated %d products", len(ids)), "count": len(ids)})
+}
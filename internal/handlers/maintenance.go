@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/settings"
+)
+
+// ========== MAINTENANCE MODE ==========
+
+const defaultMaintenanceMessage = "Admin is read-only during maintenance, please try again later"
+
+// MaintenanceState is the maintenance setting as stored in the settings table
+type MaintenanceState struct {
+	Enabled   bool       `json:"enabled"`
+	Message   string     `json:"message,omitempty"`
+	Since     *time.Time `json:"since,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
+}
+
+// maintenanceState returns the setting; other instances notice a toggle within
+// settings.CacheTTL. A window with an elapsed "until" counts as off.
+func (h *Handlers) maintenanceState() MaintenanceState {
+	var state MaintenanceState
+	h.settings.Decode(context.Background(), settings.KeyMaintenance, &state)
+	if state.Enabled && state.Until != nil && time.Now().After(*state.Until) {
+		state.Enabled = false
+	}
+	return state
+}
+
+// MaintenanceEnabled reports whether admin writes are currently refused
+func (h *Handlers) MaintenanceEnabled() bool {
+	return h.maintenanceState().Enabled
+}
+
+func (s MaintenanceState) message() string {
+	if s.Message != "" {
+		return s.Message
+	}
+	return defaultMaintenanceMessage
+}
+
+// MaintenanceGuard refuses mutating admin requests with 503 while maintenance mode is on.
+// Reads and the maintenance toggle itself keep working.
+func (h *Handlers) MaintenanceGuard() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return c.Next()
+		}
+		if strings.HasSuffix(c.Path(), "/admin/maintenance") {
+			return c.Next()
+		}
+		state := h.maintenanceState()
+		if !state.Enabled {
+			return c.Next()
+		}
+		if state.Until != nil {
+			if wait := time.Until(*state.Until); wait > 0 {
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(wait.Seconds())+1))
+			}
+		}
+		return c.Status(503).JSON(fiber.Map{"success": false, "error": state.message(), "maintenance": true})
+	}
+}
+
+func (h *Handlers) GetMaintenance(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"success": true, "data": h.maintenanceState()})
+}
+
+// SetMaintenance toggles maintenance mode for every instance sharing the database
+func (h *Handlers) SetMaintenance(c *fiber.Ctx) error {
+	var input struct {
+		Enabled         bool   `json:"enabled"`
+		Message         string `json:"message"`
+		DurationMinutes int    `json:"duration_minutes"`
+		UpdatedBy       string `json:"updated_by"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if input.DurationMinutes < 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "duration_minutes must not be negative"})
+	}
+
+	state := MaintenanceState{Enabled: input.Enabled, UpdatedBy: strings.TrimSpace(input.UpdatedBy)}
+	if input.Enabled {
+		now := time.Now()
+		state.Message = strings.TrimSpace(input.Message)
+		state.Since = &now
+		if input.DurationMinutes > 0 {
+			until := now.Add(time.Duration(input.DurationMinutes) * time.Minute)
+			state.Until = &until
+		}
+	}
+
+	updatedBy := state.UpdatedBy
+	if updatedBy == "" {
+		updatedBy = auditActor(c)
+	}
+	if _, err := h.settings.SetValue(context.Background(), settings.KeyMaintenance, state, updatedBy); err != nil {
+		return settingsError(c, h.settings.Keys(), err)
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": state})
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// ========== PRODUCT MEDIA ==========
+
+const maxMediaURLLength = 1000
+
+var mediaTypes = []string{"image", "video", "document"}
+
+// feedMedia is one media URL taken from a feed item
+type feedMedia struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// Source fields collected into media when field_mapping does not say otherwise.
+// A field_mapping target of "media:image", "media:video" or "media:document"
+// adds any other source field.
+var autoMediaFields = map[string][]string{
+	"image":    {"IMGURL_ALTERNATIVE", "additional_image_link", "images"},
+	"video":    {"VIDEO_URL", "VIDEO", "video_url", "video_link"},
+	"document": {"DATASHEET", "DATASHEET_URL", "DOCUMENT_URL", "MANUAL_URL", "datasheet", "document_url"},
+}
+
+// mapMedia collects every value of the media source fields, normalized by norm and
+// without duplicates. URLs norm rejects are returned separately for the import log.
+func mapMedia(item map[string]interface{}, mapping map[string]string, norm feedURLNormalizer) ([]feedMedia, []string) {
+	sources := map[string][]string{}
+	for mediaType, fields := range autoMediaFields {
+		sources[mediaType] = append(sources[mediaType], fields...)
+	}
+	for sourceField, target := range mapping {
+		if mediaType := strings.TrimPrefix(target, "media:"); mediaType != target && containsString(mediaTypes, mediaType) {
+			sources[mediaType] = append(sources[mediaType], sourceField)
+		}
+	}
+
+	var media []feedMedia
+	var invalid []string
+	seen := map[string]bool{}
+	for _, mediaType := range mediaTypes {
+		for _, field := range sources[mediaType] {
+			for _, raw := range getAllStr(item, field) {
+				u, ok := norm.normalize(raw, maxMediaURLLength)
+				if !ok {
+					invalid = append(invalid, field+": "+raw)
+					continue
+				}
+				if seen[u] {
+					continue
+				}
+				seen[u] = true
+				media = append(media, feedMedia{Type: mediaType, URL: u})
+			}
+		}
+	}
+	return media, invalid
+}
+
+// getAllStr returns every value of a possibly repeated field
+func getAllStr(item map[string]interface{}, key string) []string {
+	if multi, ok := item["_multi"].(map[string][]string); ok {
+		if values, ok := multi[key]; ok {
+			return values
+		}
+	}
+	switch v := item[key].(type) {
+	case []interface{}:
+		var values []string
+		for _, e := range v {
+			if s, ok := e.(string); ok && strings.TrimSpace(s) != "" {
+				values = append(values, strings.TrimSpace(s))
+			}
+		}
+		return values
+	case nil:
+		return nil
+	}
+	if s := getStr(item, key); s != "" {
+		return []string{s}
+	}
+	return nil
+}
+
+// validMediaURL accepts absolute http(s) URLs up to 1000 characters; the target is never fetched
+func validMediaURL(raw string) bool {
+	if raw == "" || len(raw) > maxMediaURLLength {
+		return false
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+// saveFeedMedia replaces the feed-sourced media of a product; admin entries are kept.
+// Images get their alt from the image_alt template unless an admin edited the alt of
+// the same URL before.
+func (h *Handlers) saveFeedMedia(ctx context.Context, productID string, media []feedMedia) {
+	batch := &pgx.Batch{}
+	h.queueFeedMedia(batch, productID, media)
+	if batch.Len() > 0 {
+		h.db.Pool.SendBatch(ctx, batch).Close()
+	}
+}
+
+// queueFeedMedia adds the statement of saveFeedMedia to a batch
+func (h *Handlers) queueFeedMedia(batch *pgx.Batch, productID string, media []feedMedia) {
+	if len(media) == 0 {
+		return
+	}
+	types := make([]string, len(media))
+	urls := make([]string, len(media))
+	for i, m := range media {
+		types[i], urls[i] = m.Type, m.URL
+	}
+	batch.Queue(`
+		WITH old AS (
+			DELETE FROM product_media WHERE product_id = $1::uuid AND source = 'feed' RETURNING url, alt, alt_edited
+		)
+		INSERT INTO product_media (product_id, type, url, alt, alt_edited, position, source, created_at, updated_at)
+		SELECT p.id, m.type, m.url,
+		       CASE WHEN m.type <> 'image' THEN NULL
+		            WHEN o.alt_edited THEN o.alt
+		            ELSE LEFT(REPLACE(REPLACE(REPLACE($4, '{title}', p.title), '{brand}', COALESCE(p.brand,'')), '{n}',
+		                 (ROW_NUMBER() OVER (PARTITION BY m.type ORDER BY m.ord))::text), $5)
+		       END,
+		       COALESCE(o.alt_edited AND m.type = 'image', false), m.ord - 1, 'feed', NOW(), NOW()
+		FROM unnest($2::text[], $3::text[]) WITH ORDINALITY AS m(type, url, ord)
+		JOIN products p ON p.id = $1::uuid
+		LEFT JOIN (SELECT DISTINCT ON (url) url, alt, alt_edited FROM old ORDER BY url, alt_edited DESC) o ON o.url = m.url
+	`, productID, types, urls, h.imageAltSettings().Template, maxImageAlt)
+}
+
+type mediaInput struct {
+	Type  string `json:"type"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	// Alt is the alt text of an image; an edited alt survives re-imports
+	Alt      string `json:"alt"`
+	Position *int   `json:"position"`
+}
+
+func (in *mediaInput) validate(c *fiber.Ctx) error {
+	in.URL = strings.TrimSpace(in.URL)
+	if !containsString(mediaTypes, in.Type) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "type must be image, video or document"})
+	}
+	if !validMediaURL(in.URL) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "url must be an absolute http(s) URL up to 1000 characters"})
+	}
+	if len(in.Title) > 255 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "title is too long"})
+	}
+	in.Alt = strings.TrimSpace(in.Alt)
+	if utf8.RuneCountInString(in.Alt) > maxImageAlt {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "alt is too long"})
+	}
+	if in.Alt != "" && in.Type != "image" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "alt applies to images only"})
+	}
+	return nil
+}
+
+func (h *Handlers) AdminListProductMedia(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	ctx := context.Background()
+	rows, err := h.db.Pool.Query(ctx, `SELECT id, type, url, COALESCE(title,''), COALESCE(alt,''), position, COALESCE(source,'admin') FROM product_media WHERE product_id = $1::uuid ORDER BY type, position, created_at`, productID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+	media := []fiber.Map{}
+	for rows.Next() {
+		var id, mediaType, mediaURL, title, alt, source string
+		var position int
+		rows.Scan(&id, &mediaType, &mediaURL, &title, &alt, &position, &source)
+		media = append(media, fiber.Map{"id": id, "type": mediaType, "url": mediaURL, "title": title, "alt": alt, "position": position, "source": source})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": media})
+}
+
+func (h *Handlers) AdminCreateProductMedia(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	var input mediaInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if err := input.validate(c); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var id string
+	err := h.db.Pool.QueryRow(ctx, `
+		INSERT INTO product_media (product_id, type, url, title, alt, alt_edited, position, source, created_at, updated_at)
+		SELECT p.id, $2, $3, NULLIF($4,''), NULLIF($6,''), $6 <> '',
+		       COALESCE($5, (SELECT COALESCE(MAX(position) + 1, 0) FROM product_media WHERE product_id = p.id AND type = $2)),
+		       'admin', NOW(), NOW()
+		FROM products p WHERE p.id = $1::uuid
+		RETURNING id
+	`, productID, input.Type, input.URL, input.Title, input.Position, input.Alt).Scan(&id)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Product not found"})
+	}
+	h.refreshProductCompleteness(ctx, productID)
+	return c.Status(201).JSON(fiber.Map{"success": true, "data": fiber.Map{"id": id}})
+}
+
+func (h *Handlers) AdminUpdateProductMedia(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	mediaID := c.Params("media_id")
+	var input mediaInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if err := input.validate(c); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	tag, err := h.db.Pool.Exec(ctx, `
+		UPDATE product_media SET type = $3, url = $4, title = NULLIF($5,''), position = COALESCE($6, position),
+		       alt = NULLIF($7,''), alt_edited = alt_edited OR COALESCE(alt,'') <> $7, updated_at = NOW()
+		WHERE id = $1::uuid AND product_id = $2::uuid
+	`, mediaID, productID, input.Type, input.URL, input.Title, input.Position, input.Alt)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Media not found"})
+	}
+	h.refreshProductCompleteness(ctx, productID)
+	return c.JSON(fiber.Map{"success": true, "message": "Media updated"})
+}
+
+func (h *Handlers) AdminDeleteProductMedia(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	mediaID := c.Params("media_id")
+	ctx := context.Background()
+	tag, err := h.db.Pool.Exec(ctx, "DELETE FROM product_media WHERE id = $1::uuid AND product_id = $2::uuid", mediaID, productID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Media not found"})
+	}
+	h.refreshProductCompleteness(ctx, productID)
+	return c.JSON(fiber.Map{"success": true, "message": "Media deleted"})
+}
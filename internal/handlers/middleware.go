@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// RequireUUID rejects requests whose route parameter is not a valid UUID before
+// it reaches Postgres, which would otherwise fail with a 500.
+func RequireUUID(param string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !isUUID(c.Params(param)) {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid " + param + ": must be a UUID"})
+		}
+		return c.Next()
+	}
+}
+
+// isUUID validates optional UUID references in request bodies. Only the canonical
+// 36-character hyphenated form is accepted; uuid.Parse alone also takes the urn:uuid:,
+// braced and unhyphenated forms, which we never hand out.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	_, err := uuid.Parse(s)
+	return err == nil
+}
+
+func invalidUUIDField(c *fiber.Ctx, field string) error {
+	return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid " + field + ": must be a UUID"})
+}
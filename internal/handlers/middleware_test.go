@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+)
+
+// garbageIDs are route parameters that must never reach a query
+var garbageIDs = []string{
+	"abc",
+	"1",
+	"0",
+	"null",
+	"undefined",
+	"' OR '1'='1",
+	"1; DROP TABLE products",
+	"00000000-0000-0000-0000-00000000000Z",
+	"00000000-0000-0000-0000-0000000000001",
+	"00000000-0000-0000-0000-00000000000",
+	"urn:uuid:6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+	"{6ba7b810-9dad-11d1-80b4-00c04fd430c8}",
+	"6ba7b8109dad11d180b400c04fd430c8",
+	"%00",
+	"..%2F..%2Fetc%2Fpasswd",
+	strings.Repeat("a", 400),
+}
+
+const testUUID = "3f2504e0-4f89-41d3-9a0c-0305e82c3301"
+
+func TestRequireUUIDRouteGroups(t *testing.T) {
+	reached := func(c *fiber.Ctx) error { return c.SendStatus(204) }
+	app := fiber.New()
+	validID := RequireUUID("id")
+	api := app.Group("/api/v1")
+	api.Get("/products/:id/offers", validID, reached)
+	admin := api.Group("/admin")
+	admin.Put("/products/:id/media/:media_id", validID, RequireUUID("media_id"), reached)
+	admin.Get("/feeds/:id/imports/:run_id", validID, RequireUUID("run_id"), reached)
+	admin.Put("/questions/:question_id", RequireUUID("question_id"), reached)
+	admin.Post("/notifications/:id/retry", validID, reached)
+
+	// each route with {} standing for the parameter under test; the others are valid
+	routes := []struct {
+		method, path, param string
+	}{
+		{"GET", "/api/v1/products/{}/offers", "id"},
+		{"PUT", "/api/v1/admin/products/{}/media/" + testUUID, "id"},
+		{"PUT", "/api/v1/admin/products/" + testUUID + "/media/{}", "media_id"},
+		{"GET", "/api/v1/admin/feeds/{}/imports/" + testUUID, "id"},
+		{"GET", "/api/v1/admin/feeds/" + testUUID + "/imports/{}", "run_id"},
+		{"PUT", "/api/v1/admin/questions/{}", "question_id"},
+		{"POST", "/api/v1/admin/notifications/{}/retry", "id"},
+	}
+	for _, r := range routes {
+		status, body := send(t, app, r.method, strings.Replace(r.path, "{}", testUUID, 1), "")
+		if status != 204 {
+			t.Errorf("%s %s with a valid UUID: %d %s", r.method, r.path, status, body)
+		}
+		for _, id := range garbageIDs {
+			path := strings.Replace(r.path, "{}", url.PathEscape(id), 1)
+			status, body := send(t, app, r.method, path, "")
+			if status != 400 || !strings.Contains(body, "Invalid "+r.param+": must be a UUID") {
+				t.Errorf("%s %s: %d %s", r.method, path, status, body)
+			}
+		}
+	}
+}
+
+// TestUUIDReferencesRejectedUpFront sends invalid UUID references in bodies and queries
+// to handlers without a database: each must answer 400 before it queries
+func TestUUIDReferencesRejectedUpFront(t *testing.T) {
+	h := &Handlers{}
+	app := fiber.New()
+	app.Use(recover.New())
+	app.Get("/admin/products", h.AdminProducts)
+	app.Post("/admin/products", h.AdminCreateProduct)
+	app.Put("/admin/products/:id", h.AdminUpdateProduct)
+	app.Post("/admin/products/bulk", h.BulkDeleteProducts)
+	app.Get("/admin/audit", h.AdminAuditLog)
+	app.Post("/admin/feeds", h.CreateFeed)
+	app.Post("/admin/feeds/:id/adopt", h.AdoptFeedProducts)
+	app.Post("/admin/categories/:id/reassign", h.AdminReassignCategoryProducts)
+	app.Post("/admin/es-sync/requeue", h.RequeueESSync)
+
+	tests := []struct {
+		method, path, body, field string
+	}{
+		{"GET", "/admin/products?feed_id=abc", "", "feed_id"},
+		{"GET", "/admin/products?category_id=1%27%20OR%201%3D1", "", "category_id"},
+		{"POST", "/admin/products", `{"title":"Kávovar","price_min":10,"price_max":10,"category_id":"kavovary"}`, "category_id"},
+		{"PUT", "/admin/products/" + testUUID, `{"version":1,"title":"Kávovar","category_id":"0"}`, "category_id"},
+		{"POST", "/admin/products/bulk", `{"ids":["` + testUUID + `","abc"],"action":"delete"}`, "ids"},
+		{"GET", "/admin/audit?entity_id=abc", "", "entity_id"},
+		{"GET", "/admin/audit?entity_id=urn:uuid:" + testUUID, "", "entity_id"},
+		{"POST", "/admin/categories/" + testUUID + "/reassign", `{"target_id":"{` + testUUID + `}"}`, "target_id"},
+		{"POST", "/admin/feeds", `{"name":"Feed","url":"https://shop.example.com/feed.xml","type":"heureka-xml","vendor_id":"shop"}`, "vendor_id"},
+		{"POST", "/admin/feeds/" + testUUID + "/adopt", `{"source_feed_id":"old-feed"}`, "source_feed_id"},
+		{"POST", "/admin/feeds/" + testUUID + "/adopt", `{}`, "source_feed_id"},
+		{"POST", "/admin/categories/" + testUUID + "/reassign", `{"target_id":"null"}`, "target_id"},
+		{"POST", "/admin/categories/" + testUUID + "/reassign", `{"target_id":"` + testUUID[:35] + `0","feed_id":"x"}`, "feed_id"},
+		{"POST", "/admin/es-sync/requeue", `{"product_ids":["` + testUUID + `",""]}`, "product_ids"},
+	}
+	for _, tt := range tests {
+		status, body := send(t, app, tt.method, tt.path, tt.body)
+		if status != 400 || !strings.Contains(body, "Invalid "+tt.field+": must be a UUID") {
+			t.Errorf("%s %s %s: %d %s", tt.method, tt.path, tt.body, status, body)
+		}
+	}
+}
+
+// send makes a request with an optional JSON body and returns the status and body
+func send(t *testing.T, app *fiber.App, method, path, body string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== CONTENT NEGOTIATION ==========
+
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+	// maxCSVListLimit caps ?limit= of a CSV listing; JSON pages stay at maxPageLimit
+	maxCSVListLimit = 10000
+
+	formatLocal    = "response_format"
+	csvServedLocal = "csv_served"
+)
+
+// utf8BOM makes Excel read CSV exports as UTF-8
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// NegotiateFormat picks the response format: ?format=json|csv, else text/csv when the
+// Accept header prefers it over application/json, else JSON. Endpoints answer CSV
+// through sendListing or sendCSV; any other endpoint asked for CSV gets 406 listing
+// what it supports instead of its JSON. Only GET requests can ask for CSV, so nothing
+// else runs before that answer.
+func NegotiateFormat(c *fiber.Ctx) error {
+	format := formatJSON
+	switch f := strings.ToLower(c.Query("format")); f {
+	case "":
+		if c.Get(fiber.HeaderAccept) != "" && c.Accepts(fiber.MIMEApplicationJSON, "text/csv") == "text/csv" {
+			format = formatCSV
+		}
+	case formatJSON, formatCSV:
+		format = f
+	default:
+		return c.Status(406).JSON(fiber.Map{"success": false, "error": "Unsupported format: " + f, "supported_formats": []string{formatJSON, formatCSV}})
+	}
+	if format != formatCSV {
+		return c.Next()
+	}
+	if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+		return notAcceptable(c)
+	}
+
+	c.Locals(formatLocal, formatCSV)
+	if err := c.Next(); err != nil {
+		return err
+	}
+	// errors keep their JSON body; a successful JSON answer is replaced
+	if c.Locals(csvServedLocal) == nil && c.Response().StatusCode() < 400 {
+		c.Response().ResetBody()
+		c.Response().Header.Del(fiber.HeaderLink)
+		c.Response().Header.Del(fiber.HeaderETag)
+		c.Response().Header.Del(fiber.HeaderContentDisposition)
+		return notAcceptable(c)
+	}
+	return nil
+}
+
+func notAcceptable(c *fiber.Ctx) error {
+	return c.Status(406).JSON(fiber.Map{"success": false, "error": "CSV is not available for this endpoint", "supported_formats": []string{fiber.MIMEApplicationJSON}})
+}
+
+// wantsCSV reports whether the request negotiated CSV
+func wantsCSV(c *fiber.Ctx) bool {
+	return c.Locals(formatLocal) == formatCSV || (c.Locals(formatLocal) == nil && c.Query("format") == formatCSV)
+}
+
+// listPageParams is pageParams for endpoints answering through sendListing; a CSV
+// listing may ask for up to maxCSVListLimit rows
+func listPageParams(c *fiber.Ctx, defaultLimit int) (page, limit, offset int) {
+	page, limit, offset = pageParams(c, defaultLimit)
+	if wantsCSV(c) {
+		limit = c.QueryInt("limit", defaultLimit)
+		if limit < 1 {
+			limit = defaultLimit
+		}
+		limit = min(limit, maxCSVListLimit)
+		offset = (page - 1) * limit
+	}
+	return page, limit, offset
+}
+
+// sendListing answers a listing in the negotiated format: body as JSON, or items (a
+// slice of structs or maps) as CSV with one flattened row per item, named
+// name-YYYY-MM-DD.csv. Struct items keep their field order with nested structs as
+// dotted columns; map items follow ?fields= or else sort their keys.
+func sendListing(c *fiber.Ctx, name string, items interface{}, body fiber.Map) error {
+	if !wantsCSV(c) {
+		return c.JSON(body)
+	}
+	header, rows, err := flattenItems(items, splitList(c.Query("fields")))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	c.Locals(csvServedLocal, true)
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Attachment(fmt.Sprintf("%s-%s.csv", name, time.Now().Format("2006-01-02")))
+	c.Context().SetBodyStreamWriter(func(bw *bufio.Writer) {
+		bw.Write(utf8BOM)
+		w := csv.NewWriter(bw)
+		w.Write(header)
+		w.WriteAll(rows)
+	})
+	return nil
+}
+
+// flattenItems turns items into a header and rows of cells
+func flattenItems(items interface{}, fields []string) ([]string, [][]string, error) {
+	rv := reflect.ValueOf(items)
+	if rv.Kind() != reflect.Slice {
+		return nil, nil, fmt.Errorf("listing items must be a slice, got %T", items)
+	}
+	var columns []string
+	elem := rv.Type().Elem()
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() == reflect.Struct {
+		columns = structColumns(elem, "")
+	} else if len(fields) > 0 {
+		columns = append(columns, fields...)
+	}
+	known := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		known[col] = true
+	}
+
+	cells := make([]map[string]string, rv.Len())
+	var extra []string
+	for i := range cells {
+		raw, err := json.Marshal(rv.Index(i).Interface())
+		if err != nil {
+			return nil, nil, err
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, nil, err
+		}
+		cells[i] = map[string]string{}
+		flattenCell("", v, known, cells[i])
+		for col := range cells[i] {
+			if !known[col] {
+				known[col] = true
+				extra = append(extra, col)
+			}
+		}
+	}
+	// columns only some map items carry, in a fixed order
+	sort.Strings(extra)
+	columns = append(columns, extra...)
+
+	rows := make([][]string, len(cells))
+	for i, row := range cells {
+		rows[i] = make([]string, len(columns))
+		for j, col := range columns {
+			rows[i][j] = row[col]
+		}
+	}
+	return columns, rows, nil
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// structColumns lists the CSV columns of a struct type in field order; nested structs
+// become dotted columns, embedded ones are inlined
+func structColumns(t reflect.Type, prefix string) []string {
+	var columns []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		nested := ft.Kind() == reflect.Struct && ft != timeType &&
+			!reflect.PointerTo(ft).Implements(jsonMarshalerType) && !reflect.PointerTo(ft).Implements(textMarshalerType)
+		if f.Anonymous && name == "" && nested {
+			columns = append(columns, structColumns(ft, prefix)...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if nested {
+			columns = append(columns, structColumns(ft, prefix+name+".")...)
+		} else {
+			columns = append(columns, prefix+name)
+		}
+	}
+	return columns
+}
+
+// flattenCell writes v under key, spreading objects over dotted keys unless key is a
+// column of its own
+func flattenCell(key string, v interface{}, columns map[string]bool, out map[string]string) {
+	if obj, ok := v.(map[string]interface{}); ok && !columns[key] {
+		for k, child := range obj {
+			if key != "" {
+				k = key + "." + k
+			}
+			flattenCell(k, child, columns, out)
+		}
+		return
+	}
+	if v == nil && !columns[key] && hasNestedColumns(key, columns) {
+		// a nil nested struct leaves its dotted columns empty
+		return
+	}
+	out[key] = csvCell(v)
+}
+
+func hasNestedColumns(key string, columns map[string]bool) bool {
+	for col := range columns {
+		if strings.HasPrefix(col, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// csvCell renders a JSON value as a cell: lists of scalars joined with "|", other
+// lists and objects as JSON
+func csvCell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				raw, _ := json.Marshal(v)
+				return string(raw)
+			}
+			parts = append(parts, csvCell(item))
+		}
+		return strings.Join(parts, "|")
+	}
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"megabuy-go/internal/display"
+	"megabuy-go/internal/money"
+	"megabuy-go/internal/notify"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	tokenSecret     []byte
+	tokenSecretOnce sync.Once
+)
+
+// getTokenSecret returns the HMAC key for signed links. Without TOKEN_SECRET a
+// random per-process key is used, so links stop working after a restart.
+func getTokenSecret() []byte {
+	tokenSecretOnce.Do(func() {
+		if s := os.Getenv("TOKEN_SECRET"); s != "" {
+			tokenSecret = []byte(s)
+			return
+		}
+		log.Println("TOKEN_SECRET not set, using random key for signed links")
+		tokenSecret = make([]byte, 32)
+		rand.Read(tokenSecret)
+	})
+	return tokenSecret
+}
+
+// signToken creates a tamper-proof token binding an action to a record id
+func signToken(action, id string) string {
+	payload := action + ":" + id
+	mac := hmac.New(sha256.New, getTokenSecret())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyToken returns the record id when token is valid for action
+func verifyToken(token, action string) (string, bool) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", false
+	}
+	mac := hmac.New(sha256.New, getTokenSecret())
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", false
+	}
+	prefix := action + ":"
+	if !strings.HasPrefix(string(payload), prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(string(payload), prefix), true
+}
+
+// publicURL is the base used for links in outgoing e-mails
+func publicURL() string {
+	if u := os.Getenv("PUBLIC_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return "http://localhost:8080"
+}
+
+// Outbox delivery: failed messages are retried with exponential backoff and
+// dead-lettered once notificationMaxAttempts is reached
+const (
+	notificationMaxAttempts = 5
+	notificationBaseBackoff = time.Minute
+	notificationMaxBackoff  = time.Hour
+)
+
+// Outbox channels: e-mails go through the configured sender, webhooks are POSTed as
+// JSON to the URL in recipient
+const (
+	notificationChannelEmail   = "email"
+	notificationChannelWebhook = "webhook"
+	notificationWebhookTimeout = 10 * time.Second
+)
+
+// notificationStatuses are the outbox states an admin can filter by
+var notificationStatuses = map[string]bool{"pending": true, "sent": true, "dead": true}
+
+// notificationBackoff is the wait before the next delivery attempt after the given
+// number of failed attempts
+func notificationBackoff(attempts int) time.Duration {
+	d := notificationBaseBackoff
+	for i := 1; i < attempts && d < notificationMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > notificationMaxBackoff {
+		d = notificationMaxBackoff
+	}
+	return d
+}
+
+// adminLang is the language of e-mails sent to ADMIN_EMAIL, from ADMIN_LANG
+func adminLang() string {
+	return notify.NormalizeLang(os.Getenv("ADMIN_LANG"))
+}
+
+// requestLang picks the language of a subscriber's e-mails: an explicit lang field
+// wins over the Accept-Language header
+func requestLang(c *fiber.Ctx, explicit string) string {
+	if explicit != "" {
+		return notify.NormalizeLang(explicit)
+	}
+	return notify.NormalizeLang(c.Get(fiber.HeaderAcceptLanguage))
+}
+
+// queueNotification renders the named template in the recipient's language and
+// stores the message in the outbox for the notification worker
+func (h *Handlers) queueNotification(ctx context.Context, template, lang, recipient string, data any) error {
+	return queueNotificationOn(ctx, h.db.Pool, template, lang, recipient, data)
+}
+
+// dbExecer is satisfied by the pool and by a pgx.Tx
+type dbExecer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// queueNotificationOn is queueNotification through db, so a transaction can commit
+// the notification together with the change it reports
+func queueNotificationOn(ctx context.Context, db dbExecer, template, lang, recipient string, data any) error {
+	msg, err := notify.Render(template, lang, data)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(ctx, `
+		INSERT INTO notification_events (type, recipient, lang, subject, body, html_body, status, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6,''), 'pending', NOW(), NOW())
+	`, template, recipient, notify.NormalizeLang(lang), msg.Subject, msg.Body, msg.HTML)
+	return err
+}
+
+// queueWebhookOn stores a webhook delivery of payload to url in the outbox; event
+// names it in the admin list, e.g. feed.needs_attention
+func queueWebhookOn(ctx context.Context, db dbExecer, event, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(ctx, `
+		INSERT INTO notification_events (type, channel, recipient, subject, body, status, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $1, $4, 'pending', NOW(), NOW())
+	`, event, notificationChannelWebhook, url, string(body))
+	return err
+}
+
+// postWebhook sends body as JSON to url; any non-2xx answer is an error
+func postWebhook(ctx context.Context, url, body string) error {
+	ctx, cancel := context.WithTimeout(ctx, notificationWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader([]byte(body)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// RunNotificationWorker delivers queued notifications until the process exits.
+// Without a sender e-mails stay queued while webhooks are still delivered.
+func (h *Handlers) RunNotificationWorker(interval time.Duration) {
+	if h.sender == nil {
+		log.Println("Notification sender not configured, e-mails stay queued")
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.dispatchNotifications(context.Background())
+	}
+}
+
+func (h *Handlers) dispatchNotifications(ctx context.Context) {
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT id, channel, recipient, subject, body, COALESCE(html_body,''), attempts FROM notification_events
+		WHERE status = 'pending' AND COALESCE(next_attempt_at, created_at) <= NOW() AND (channel <> $1 OR $2)
+		ORDER BY created_at LIMIT 100
+	`, notificationChannelEmail, h.sender != nil)
+	if err != nil {
+		log.Printf("Notification query failed: %v", err)
+		return
+	}
+
+	type event struct {
+		id       string
+		channel  string
+		attempts int
+		msg      notify.Message
+	}
+	var events []event
+	for rows.Next() {
+		var e event
+		rows.Scan(&e.id, &e.channel, &e.msg.To, &e.msg.Subject, &e.msg.Body, &e.msg.HTML, &e.attempts)
+		events = append(events, e)
+	}
+	rows.Close()
+
+	var dead int
+	for _, e := range events {
+		var err error
+		if e.channel == notificationChannelWebhook {
+			err = postWebhook(ctx, e.msg.To, e.msg.Body)
+		} else {
+			err = h.sender.Send(ctx, e.msg)
+		}
+		if err != nil {
+			attempts := e.attempts + 1
+			if attempts >= notificationMaxAttempts {
+				dead++
+				h.db.Pool.Exec(ctx, `
+					UPDATE notification_events SET attempts = $2, last_error = $3, status = 'dead', dead_at = NOW()
+					WHERE id = $1::uuid
+				`, e.id, attempts, err.Error())
+				continue
+			}
+			h.db.Pool.Exec(ctx, `
+				UPDATE notification_events SET attempts = $2, last_error = $3,
+				       next_attempt_at = NOW() + $4 * INTERVAL '1 second'
+				WHERE id = $1::uuid
+			`, e.id, attempts, err.Error(), int(notificationBackoff(attempts).Seconds()))
+			continue
+		}
+		h.db.Pool.Exec(ctx, "UPDATE notification_events SET status = 'sent', attempts = attempts + 1, sent_at = NOW() WHERE id = $1::uuid", e.id)
+	}
+	if len(events) > 0 {
+		log.Printf("Dispatched %d notifications (%d dead-lettered)", len(events), dead)
+	}
+}
+
+// AdminListNotifications lists recent outbox messages, newest first, with per-status
+// counts; ?status=, ?type= and ?recipient= filter the list
+func (h *Handlers) AdminListNotifications(c *fiber.Ctx) error {
+	ctx := context.Background()
+	page, limit, offset := adminPageParams(c, 50)
+
+	where := newWhere()
+	if status := c.Query("status"); status != "" {
+		if !notificationStatuses[status] {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "status must be pending, sent or dead"})
+		}
+		where.add("status = ?", status)
+	}
+	if kind := c.Query("type"); kind != "" {
+		where.add("type = ?", kind)
+	}
+	if recipient := strings.TrimSpace(strings.ToLower(c.Query("recipient"))); recipient != "" {
+		where.add("LOWER(recipient) = ?", recipient)
+	}
+
+	var total int64
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM notification_events "+where.clause(), where.params()...).Scan(&total)
+
+	counts := fiber.Map{"pending": 0, "sent": 0, "dead": 0}
+	if rows, err := h.db.Pool.Query(ctx, "SELECT status, COUNT(*) FROM notification_events GROUP BY status"); err == nil {
+		for rows.Next() {
+			var status string
+			var n int
+			rows.Scan(&status, &n)
+			counts[status] = n
+		}
+		rows.Close()
+	}
+
+	list := where.clone()
+	limitArg, offsetArg := list.arg(limit), list.arg(offset)
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT id::text, type, channel, recipient, lang, subject, status, attempts, COALESCE(last_error,''),
+		       created_at, next_attempt_at, sent_at, dead_at
+		FROM notification_events `+list.clause()+`
+		ORDER BY created_at DESC LIMIT `+limitArg+` OFFSET `+offsetArg, list.params()...)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+	items := []fiber.Map{}
+	for rows.Next() {
+		var id, kind, channel, recipient, lang, subject, status, lastError string
+		var attempts int
+		var createdAt time.Time
+		var nextAttemptAt, sentAt, deadAt *time.Time
+		rows.Scan(&id, &kind, &channel, &recipient, &lang, &subject, &status, &attempts, &lastError, &createdAt, &nextAttemptAt, &sentAt, &deadAt)
+		item := fiber.Map{
+			"id": id, "type": kind, "channel": channel, "recipient": recipient, "lang": lang, "subject": subject, "status": status,
+			"attempts": attempts, "last_error": lastError, "created_at": createdAt, "sent_at": sentAt, "dead_at": deadAt,
+		}
+		if status == "pending" {
+			item["next_attempt_at"] = nextAttemptAt
+		}
+		items = append(items, item)
+	}
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{"items": items, "counts": counts}, page, limit, total)})
+}
+
+// AdminRetryNotification moves a dead-lettered message back to the outbox with a
+// fresh set of attempts
+func (h *Handlers) AdminRetryNotification(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx := context.Background()
+	tag, err := h.db.Pool.Exec(ctx, `
+		UPDATE notification_events SET status = 'pending', attempts = 0, next_attempt_at = NOW(), dead_at = NULL
+		WHERE id = $1::uuid AND status = 'dead'
+	`, id)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Dead-lettered notification not found"})
+	}
+	h.audit(ctx, c, "notification.retry", "notification", id, nil)
+	return c.JSON(fiber.Map{"success": true, "message": "Notification requeued"})
+}
+
+// formatPrice formats an amount for notifications, which word prices the Slovak way
+func formatPrice(v float64) string {
+	return display.Price(money.New(v), display.LocaleSK)
+}
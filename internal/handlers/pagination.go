@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	maxPageLimit = 100
+	// maxAdminPageLimit lets admin tools load large pages, as they could before
+	// listings were clamped
+	maxAdminPageLimit = 1000
+)
+
+// pageParams reads page and limit, clamping limit to 1..100
+func pageParams(c *fiber.Ctx, defaultLimit int) (page, limit, offset int) {
+	return pageParamsUpTo(c, defaultLimit, maxPageLimit)
+}
+
+// adminPageParams is pageParams for admin listings, clamping limit to 1..1000
+func adminPageParams(c *fiber.Ctx, defaultLimit int) (page, limit, offset int) {
+	return pageParamsUpTo(c, defaultLimit, maxAdminPageLimit)
+}
+
+func pageParamsUpTo(c *fiber.Ctx, defaultLimit, maxLimit int) (page, limit, offset int) {
+	page = c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+	limit = c.QueryInt("limit", defaultLimit)
+	if limit < 1 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return page, limit, (page - 1) * limit
+}
+
+// paginate adds the standard page metadata to data and sets an RFC 5988 Link header
+func paginate(c *fiber.Ctx, data fiber.Map, page, limit int, total int64) fiber.Map {
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	data["page"] = page
+	data["limit"] = limit
+	data["total"] = total
+	data["total_pages"] = totalPages
+	data["has_next"] = page < totalPages
+	data["has_prev"] = page > 1
+
+	var links []string
+	addLink := func(rel string, p int) {
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, pageURL(c, p), rel))
+	}
+	if totalPages > 0 {
+		addLink("first", 1)
+	}
+	if page > 1 {
+		addLink("prev", min(page-1, max(totalPages, 1)))
+	}
+	if page < totalPages {
+		addLink("next", page+1)
+	}
+	if totalPages > 0 {
+		addLink("last", totalPages)
+	}
+	if len(links) > 0 {
+		c.Set(fiber.HeaderLink, strings.Join(links, ", "))
+	}
+	return data
+}
+
+// pageURL is the current request URL with the page query parameter replaced
+func pageURL(c *fiber.Ctx, page int) string {
+	args := fiber.AcquireArgs()
+	defer fiber.ReleaseArgs(args)
+	c.Request().URI().QueryArgs().CopyTo(args)
+	args.Set("page", strconv.Itoa(page))
+	return c.BaseURL() + c.Path() + "?" + args.String()
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/models"
+)
+
+func TestPageParams(t *testing.T) {
+	tests := []struct {
+		query                     string
+		admin                     bool
+		page, limit, offset, dflt int
+	}{
+		{"", false, 1, 20, 0, 20},
+		{"?page=3&limit=10", false, 3, 10, 20, 20},
+		{"?page=0&limit=0", false, 1, 20, 0, 20},
+		{"?page=-2&limit=-5", false, 1, 50, 0, 50},
+		{"?page=abc&limit=xyz", false, 1, 20, 0, 20},
+		{"?limit=5000", false, 1, 100, 0, 20},
+		{"?limit=5000", true, 1, 1000, 0, 20},
+		{"?page=2&limit=500", true, 2, 500, 500, 50},
+	}
+	for _, tt := range tests {
+		app := fiber.New()
+		app.Get("/", func(c *fiber.Ctx) error {
+			var page, limit, offset int
+			if tt.admin {
+				page, limit, offset = adminPageParams(c, tt.dflt)
+			} else {
+				page, limit, offset = pageParams(c, tt.dflt)
+			}
+			if page != tt.page || limit != tt.limit || offset != tt.offset {
+				t.Errorf("%q (admin=%v) = page %d, limit %d, offset %d; want %d, %d, %d", tt.query, tt.admin, page, limit, offset, tt.page, tt.limit, tt.offset)
+			}
+			return nil
+		})
+		app.Test(httptest.NewRequest("GET", "/"+tt.query, nil))
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		query string
+		page  int
+		total int64
+		pages int
+		next  bool
+		prev  bool
+		links map[string]string
+	}{
+		{"?page=1&limit=10&sort=price", 1, 25, 3, true, false, map[string]string{"first": "1", "next": "2", "last": "3"}},
+		{"?page=2&limit=10&sort=price", 2, 25, 3, true, true, map[string]string{"first": "1", "prev": "1", "next": "3", "last": "3"}},
+		{"?page=3&limit=10&sort=price", 3, 25, 3, false, true, map[string]string{"first": "1", "prev": "2", "last": "3"}},
+		{"?page=9&limit=10&sort=price", 9, 25, 3, false, true, map[string]string{"first": "1", "prev": "3", "last": "3"}},
+		{"?limit=10", 1, 0, 0, false, false, map[string]string{}},
+	}
+	for _, tt := range tests {
+		app := fiber.New()
+		app.Get("/items", func(c *fiber.Ctx) error {
+			return c.JSON(paginate(c, fiber.Map{"items": []int{}}, tt.page, 10, tt.total))
+		})
+		resp, err := app.Test(httptest.NewRequest("GET", "/items"+tt.query, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data struct {
+			Page       int   `json:"page"`
+			Limit      int   `json:"limit"`
+			Total      int64 `json:"total"`
+			TotalPages int   `json:"total_pages"`
+			HasNext    bool  `json:"has_next"`
+			HasPrev    bool  `json:"has_prev"`
+		}
+		json.NewDecoder(resp.Body).Decode(&data)
+		if data.Page != tt.page || data.Limit != 10 || data.Total != tt.total || data.TotalPages != tt.pages || data.HasNext != tt.next || data.HasPrev != tt.prev {
+			t.Errorf("%s: metadata %+v", tt.query, data)
+		}
+		links := parseLinkHeader(t, resp.Header.Get("Link"))
+		if len(links) != len(tt.links) {
+			t.Errorf("%s: Link relations %v, want %v", tt.query, links, tt.links)
+		}
+		for rel, page := range tt.links {
+			u, ok := links[rel]
+			if !ok {
+				t.Errorf("%s: Link lacks rel=%q", tt.query, rel)
+				continue
+			}
+			if got := u.Query().Get("page"); got != page {
+				t.Errorf("%s: rel=%q points at page %s, want %s", tt.query, rel, got, page)
+			}
+			if u.Query().Get("sort") != "price" || u.Query().Get("limit") != "10" {
+				t.Errorf("%s: rel=%q drops the other query parameters: %s", tt.query, rel, u)
+			}
+		}
+	}
+}
+
+// parseLinkHeader maps each rel of an RFC 8288 Link header to its URL
+func parseLinkHeader(t *testing.T, header string) map[string]*url.URL {
+	t.Helper()
+	links := map[string]*url.URL{}
+	if header == "" {
+		return links
+	}
+	for _, part := range strings.Split(header, ", ") {
+		target, params, ok := strings.Cut(part, ">; ")
+		if !ok || !strings.HasPrefix(target, "<") || !strings.HasPrefix(params, "rel=") {
+			t.Fatalf("malformed Link entry %q", part)
+		}
+		u, err := url.Parse(strings.TrimPrefix(target, "<"))
+		if err != nil {
+			t.Fatalf("Link entry %q: %v", part, err)
+		}
+		links[strings.Trim(strings.TrimPrefix(params, "rel="), `"`)] = u
+	}
+	return links
+}
+
+// pageMetadataKeys are the fields every paginated listing returns next to its items
+var pageMetadataKeys = []string{"page", "limit", "total", "total_pages", "has_next", "has_prev"}
+
+func TestListingPaginationContract(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	rootID, rootSlug := env.createTestCategory(t, "Kuchyňa", "")
+	childID, _ := env.createTestCategory(t, "Kávovary", rootID)
+	for i := 0; i < 3; i++ {
+		id := env.createTestProduct(t, fmt.Sprintf("Kávovar %d", i), 50+float64(i))
+		if _, err := env.db.Pool.Exec(ctx, "UPDATE products SET category_id = $2::uuid WHERE id = $1::uuid", id, childID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := fiber.New()
+	app.Get("/products", env.h.GetProducts)
+	app.Get("/categories/:slug/products", env.h.GetProductsByCategory)
+	app.Get("/admin/products", env.h.AdminProducts)
+
+	for _, path := range []string{
+		"/products?limit=2",
+		"/admin/products?limit=2",
+		"/categories/" + rootSlug + "/products?limit=2",
+	} {
+		status, out := callJSON(t, app, "GET", path, nil)
+		var data map[string]json.RawMessage
+		if status != 200 || json.Unmarshal(out.Data, &data) != nil {
+			t.Errorf("%s: status %d, data %s", path, status, out.Data)
+			continue
+		}
+		for _, key := range append([]string{"items"}, pageMetadataKeys...) {
+			if _, ok := data[key]; !ok {
+				t.Errorf("%s: data lacks %q", path, key)
+			}
+		}
+	}
+
+	var page struct {
+		Items      []json.RawMessage `json:"items"`
+		Total      int64             `json:"total"`
+		TotalPages int               `json:"total_pages"`
+		HasNext    bool              `json:"has_next"`
+	}
+	_, out := callJSON(t, app, "GET", "/categories/"+rootSlug+"/products?limit=2&page=2", nil)
+	json.Unmarshal(out.Data, &page)
+	if len(page.Items) != 1 || page.Total != 3 || page.TotalPages != 2 || page.HasNext {
+		t.Errorf("second category page: %d items of %d, %d pages, has_next=%v", len(page.Items), page.Total, page.TotalPages, page.HasNext)
+	}
+
+	// without page or limit the category listing keeps its original bare array
+	_, out = callJSON(t, app, "GET", "/categories/"+rootSlug+"/products", nil)
+	var legacy []json.RawMessage
+	if err := json.Unmarshal(out.Data, &legacy); err != nil || len(legacy) != 3 {
+		t.Errorf("unpaginated category listing = %s, want an array of the 3 products", out.Data)
+	}
+
+	var admin struct {
+		Limit int `json:"limit"`
+	}
+	_, out = callJSON(t, app, "GET", "/admin/products?limit=500", nil)
+	json.Unmarshal(out.Data, &admin)
+	if admin.Limit != 500 {
+		t.Errorf("admin listing limit %d, want 500", admin.Limit)
+	}
+}
+
+func TestSearchAndQuestionsPagination(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	id := env.createTestProduct(t, "Kávovar s otázkami", 199)
+	for i := 0; i < 5; i++ {
+		if _, err := env.db.Pool.Exec(ctx, `
+			INSERT INTO product_questions (product_id, body, status, created_at) VALUES ($1::uuid, $2, 'approved', NOW() - $3 * INTERVAL '1 minute')
+		`, id, fmt.Sprintf("Otázka %d?", i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env.db.Pool.Exec(ctx, "INSERT INTO product_questions (product_id, body, status) VALUES ($1::uuid, 'Spam', 'pending')", id)
+
+	app := fiber.New()
+	app.Get("/search", env.h.Search)
+	app.Get("/products/:id/questions", RequireUUID("id"), env.h.GetProductQuestions)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/products/"+id+"/questions?page=2&limit=2", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Data struct {
+			Items []struct {
+				Body string `json:"body"`
+			} `json:"items"`
+			Page       int   `json:"page"`
+			Total      int64 `json:"total"`
+			TotalPages int   `json:"total_pages"`
+			HasNext    bool  `json:"has_next"`
+			HasPrev    bool  `json:"has_prev"`
+		} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+	d := out.Data
+	if len(d.Items) != 2 || d.Items[0].Body != "Otázka 2?" || d.Page != 2 || d.Total != 5 || d.TotalPages != 3 || !d.HasNext || !d.HasPrev {
+		t.Errorf("second page of questions: %+v", d)
+	}
+	links := parseLinkHeader(t, resp.Header.Get("Link"))
+	for rel, page := range map[string]string{"first": "1", "prev": "1", "next": "3", "last": "3"} {
+		if u, ok := links[rel]; !ok || u.Query().Get("page") != page || u.Path != "/products/"+id+"/questions" {
+			t.Errorf("questions Link rel=%q: %v, want page %s", rel, u, page)
+		}
+	}
+
+	// the fake index answers every search with all its documents, which is enough to
+	// check the metadata and links next to the results
+	for i := 0; i < 3; i++ {
+		doc := models.Product{ID: fmt.Sprintf("00000000-0000-0000-0000-00000000167%d", i), Title: fmt.Sprintf("Hľadaný %d", i), IsActive: true}
+		if err := env.h.es.IndexProduct(doc.ToESDocument()); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { env.h.es.DeleteProduct(doc.ID) })
+	}
+	resp, err = app.Test(httptest.NewRequest("GET", "/search?q=hladany&limit=1", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var search struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&search)
+	for _, key := range append([]string{"items"}, pageMetadataKeys...) {
+		if _, ok := search.Data[key]; !ok {
+			t.Errorf("search data lacks %q", key)
+		}
+	}
+	links = parseLinkHeader(t, resp.Header.Get("Link"))
+	if next, ok := links["next"]; !ok || next.Query().Get("page") != "2" || next.Query().Get("q") != "hladany" {
+		t.Errorf("search Link next %v", next)
+	}
+	if _, ok := links["prev"]; ok {
+		t.Error("first search page links a previous page")
+	}
+}
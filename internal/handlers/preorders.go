@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== PREORDERS ==========
+
+// releaseDateLayouts are the date formats accepted from feeds and the admin
+var releaseDateLayouts = []string{"2006-01-02", time.RFC3339, "2006-01-02 15:04:05", "02.01.2006", "2.1.2006", "2006/01/02"}
+
+// parseReleaseDate reads a release date, dropping any time of day. Empty input gives nil.
+func parseReleaseDate(s string) (*time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, true
+	}
+	for _, layout := range releaseDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			return &d, true
+		}
+	}
+	return nil, false
+}
+
+// releaseDateInput validates the release date of an admin save; preorder products need one
+func releaseDateInput(raw, stockStatus string) (*time.Time, string) {
+	releaseDate, ok := parseReleaseDate(raw)
+	if !ok {
+		return nil, "release_date must be a date (YYYY-MM-DD)"
+	}
+	if stockStatus == "preorder" && releaseDate == nil {
+		return nil, "release_date is required for preorder products"
+	}
+	return releaseDate, ""
+}
+
+// GetUpcomingProducts lists active preorder products releasing today or later, soonest
+// first (?sort=release_date_desc reverses). ?category= takes slugs with their subtrees,
+// ?from= and ?to= bound the release date.
+func (h *Handlers) GetUpcomingProducts(c *fiber.Ctx) error {
+	page, limit, offset := pageParams(c, 20)
+	ctx := context.Background()
+	priceMode, ok := priceModeParam(c)
+	if !ok {
+		return invalidPriceMode(c)
+	}
+
+	where := newWhere("p.is_active = true", "p.stock_status = 'preorder'", "p.release_date >= CURRENT_DATE")
+	var warnings []string
+	if cats := splitList(c.Query("category")); len(cats) > 0 {
+		ids, w := h.resolveCategorySubtrees(ctx, cats)
+		warnings = append(warnings, w...)
+		where.add("p.category_id = ANY(?::uuid[])", nonNilStrings(ids))
+	}
+	for _, bound := range []struct{ param, cond string }{{"from", "p.release_date >= ?"}, {"to", "p.release_date <= ?"}} {
+		param, cond := bound.param, bound.cond
+		if raw := c.Query(param); raw != "" {
+			d, ok := parseReleaseDate(raw)
+			if !ok {
+				return c.Status(400).JSON(fiber.Map{"success": false, "error": param + " must be a date (YYYY-MM-DD)"})
+			}
+			where.add(cond, *d)
+		}
+	}
+	order := "p.release_date ASC, p.title"
+	if c.Query("sort") == "release_date_desc" {
+		order = "p.release_date DESC, p.title"
+	}
+
+	var total int64
+	h.db.ReadPool.QueryRow(ctx, "SELECT COUNT(*) FROM products p "+where.clause(), where.params()...).Scan(&total)
+
+	list := where.clone()
+	rows, err := h.db.ReadPool.Query(ctx, `
+		SELECT `+productCardColumns(priceMode)+`
+		FROM products p LEFT JOIN categories c ON p.category_id = c.id
+		`+list.clause()+` ORDER BY `+order+` LIMIT `+list.arg(limit)+` OFFSET `+list.arg(offset), list.params()...)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	products := scanProductCards(rows)
+	h.attachLabels(ctx, products)
+	addListDisplay(c, products)
+
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{
+		"items":      products,
+		"price_mode": priceMode,
+		"warnings":   nonNilStrings(warnings),
+	}, page, limit, total)})
+}
+
+// RunPreorderWorker switches preorder products to instock once their release date has come
+func (h *Handlers) RunPreorderWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		h.releasePreorders(context.Background())
+		<-ticker.C
+	}
+}
+
+func (h *Handlers) releasePreorders(ctx context.Context) {
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT id::text, title, release_date FROM products
+		WHERE stock_status = 'preorder' AND release_date <= CURRENT_DATE
+	`)
+	if err != nil {
+		log.Printf("Preorder release check failed: %v", err)
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id, title string
+		var releaseDate time.Time
+		rows.Scan(&id, &title, &releaseDate)
+		log.Printf("Preorder released: %s %q (release date %s)", id, title, releaseDate.Format("2006-01-02"))
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if len(ids) == 0 {
+		return
+	}
+	if err := h.setStockStatus(ctx, ids, "instock"); err != nil {
+		log.Printf("Preorder release failed for %d products: %v", len(ids), err)
+		return
+	}
+	log.Printf("Released %d preorder products to instock", len(ids))
+	h.queueESSync(ids...)
+	invalidateHomepage()
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// ========== FEED PREVIEW LIMITS ==========
+
+const (
+	defaultPreviewFieldRunes    = 500
+	defaultPreviewListEntries   = 100
+	defaultPreviewResponseBytes = 1024 * 1024
+)
+
+// previewLimits keeps a feed preview small enough for the admin browser. FieldRunes cuts
+// sample values, ListEntries caps the attribute and category stats and ResponseBytes
+// bounds the encoded preview, dropping sample items from the end to fit.
+type previewLimits struct {
+	FieldRunes    int
+	ListEntries   int
+	ResponseBytes int
+}
+
+// previewLimitsFromEnv reads FEED_PREVIEW_MAX_FIELD_CHARS, FEED_PREVIEW_MAX_ENTRIES and
+// FEED_PREVIEW_MAX_BYTES, keeping the default for unset or non-positive values
+func previewLimitsFromEnv() previewLimits {
+	l := previewLimits{FieldRunes: defaultPreviewFieldRunes, ListEntries: defaultPreviewListEntries, ResponseBytes: defaultPreviewResponseBytes}
+	for env, limit := range map[string]*int{
+		"FEED_PREVIEW_MAX_FIELD_CHARS": &l.FieldRunes,
+		"FEED_PREVIEW_MAX_ENTRIES":     &l.ListEntries,
+		"FEED_PREVIEW_MAX_BYTES":       &l.ResponseBytes,
+	} {
+		if n, err := strconv.Atoi(os.Getenv(env)); err == nil && n > 0 {
+			*limit = n
+		}
+	}
+	return l
+}
+
+// apply trims p in place to the limits
+func (l previewLimits) apply(p *FeedPreview) {
+	sort.Slice(p.Attributes, func(i, j int) bool {
+		a, b := p.Attributes[i], p.Attributes[j]
+		return a.Count > b.Count || (a.Count == b.Count && a.Name < b.Name)
+	})
+	if len(p.Attributes) > l.ListEntries {
+		p.MoreAttributes = len(p.Attributes) - l.ListEntries
+		p.Attributes = p.Attributes[:l.ListEntries]
+	}
+	sort.Slice(p.Categories, func(i, j int) bool {
+		a, b := p.Categories[i], p.Categories[j]
+		return a.Count > b.Count || (a.Count == b.Count && a.Name < b.Name)
+	})
+	if len(p.Categories) > l.ListEntries {
+		p.MoreCategories = len(p.Categories) - l.ListEntries
+		p.Categories = p.Categories[:l.ListEntries]
+	}
+
+	truncated := map[string]bool{}
+	for _, item := range p.Sample {
+		for key, v := range item {
+			if cut, ok := l.truncateValue(v); ok {
+				item[key] = cut
+				truncated[key] = true
+			}
+		}
+	}
+	p.TruncatedFields = make([]string, 0, len(truncated))
+	for key := range truncated {
+		p.TruncatedFields = append(p.TruncatedFields, key)
+	}
+	sort.Strings(p.TruncatedFields)
+	p.SampleTruncated = len(truncated) > 0
+
+	// keep the sample items that fit next to the rest of the preview
+	sample := p.Sample
+	p.Sample = nil
+	base, _ := json.Marshal(p)
+	size := len(base)
+	kept := 0
+	for _, item := range sample {
+		raw, _ := json.Marshal(item)
+		if size+len(raw)+1 > l.ResponseBytes {
+			break
+		}
+		size += len(raw) + 1
+		kept++
+	}
+	p.Sample, p.SampleOmitted = sample[:kept], len(sample)-kept
+}
+
+// truncateValue cuts strings, also inside lists and objects, to FieldRunes runes plus
+// "…"; ok reports whether anything was cut
+func (l previewLimits) truncateValue(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case string:
+		if cut := truncateRunes(v, l.FieldRunes); len(cut) < len(v) {
+			return cut + "…", true
+		}
+	case []string:
+		out, cut := make([]string, len(v)), false
+		for i, s := range v {
+			value, ok := l.truncateValue(s)
+			out[i], cut = value.(string), cut || ok
+		}
+		return out, cut
+	case []interface{}:
+		out, cut := make([]interface{}, len(v)), false
+		for i, item := range v {
+			value, ok := l.truncateValue(item)
+			out[i], cut = value, cut || ok
+		}
+		return out, cut
+	case map[string]string:
+		out, cut := make(map[string]string, len(v)), false
+		for k, s := range v {
+			value, ok := l.truncateValue(s)
+			out[k], cut = value.(string), cut || ok
+		}
+		return out, cut
+	case map[string]interface{}:
+		out, cut := make(map[string]interface{}, len(v)), false
+		for k, item := range v {
+			value, ok := l.truncateValue(item)
+			out[k], cut = value, cut || ok
+		}
+		return out, cut
+	case []map[string]string:
+		out, cut := make([]map[string]string, len(v)), false
+		for i, m := range v {
+			value, ok := l.truncateValue(m)
+			out[i], cut = value.(map[string]string), cut || ok
+		}
+		return out, cut
+	}
+	return v, false
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"megabuy-go/internal/testutil"
+)
+
+func TestPreviewLimitsFromEnv(t *testing.T) {
+	want := previewLimits{FieldRunes: defaultPreviewFieldRunes, ListEntries: defaultPreviewListEntries, ResponseBytes: defaultPreviewResponseBytes}
+	if got := previewLimitsFromEnv(); got != want {
+		t.Errorf("defaults %+v, want %+v", got, want)
+	}
+
+	t.Setenv("FEED_PREVIEW_MAX_FIELD_CHARS", "80")
+	t.Setenv("FEED_PREVIEW_MAX_ENTRIES", "0")
+	t.Setenv("FEED_PREVIEW_MAX_BYTES", "lots")
+	want.FieldRunes = 80
+	if got := previewLimitsFromEnv(); got != want {
+		t.Errorf("from the environment %+v, want %+v: invalid values keep the default", got, want)
+	}
+}
+
+func TestPreviewLimitsTruncateValues(t *testing.T) {
+	l := previewLimits{FieldRunes: 5, ListEntries: 10, ResponseBytes: 1 << 20}
+	p := FeedPreview{Sample: []map[string]interface{}{{
+		"PRODUCTNAME":        "Kávovar",
+		"ITEM_ID":            "A1",
+		"DESCRIPTION":        "Žltý čajník",
+		"_param_count":       float64(2),
+		"_params_preview":    []interface{}{"Farba: čierna", "Objem: 1,7 l"},
+		"_multi":             map[string]interface{}{"IMGURL_ALTERNATIVE": []interface{}{"https://a", "b"}},
+		"IMGURL_ALTERNATIVE": []string{"x", "https://b"},
+	}}}
+	l.apply(&p)
+
+	want := map[string]interface{}{
+		"PRODUCTNAME":        "Kávov…",
+		"ITEM_ID":            "A1",
+		"DESCRIPTION":        "Žltý …",
+		"_param_count":       float64(2),
+		"_params_preview":    []interface{}{"Farba…", "Objem…"},
+		"_multi":             map[string]interface{}{"IMGURL_ALTERNATIVE": []interface{}{"https…", "b"}},
+		"IMGURL_ALTERNATIVE": []string{"x", "https…"},
+	}
+	if !reflect.DeepEqual(p.Sample[0], want) {
+		t.Errorf("sample %v, want %v", p.Sample[0], want)
+	}
+	if !p.SampleTruncated || !reflect.DeepEqual(p.TruncatedFields, []string{"DESCRIPTION", "IMGURL_ALTERNATIVE", "PRODUCTNAME", "_multi", "_params_preview"}) {
+		t.Errorf("truncated %v: %v", p.SampleTruncated, p.TruncatedFields)
+	}
+	for key, v := range p.Sample[0] {
+		if s, ok := v.(string); ok && !utf8.ValidString(s) {
+			t.Errorf("%s cut inside a character: %q", key, s)
+		}
+	}
+
+	short := FeedPreview{Sample: []map[string]interface{}{{"ITEM_ID": "A1", "PRICE_VAT": "9.90"}}}
+	l.apply(&short)
+	if short.SampleTruncated || short.TruncatedFields == nil || len(short.TruncatedFields) != 0 {
+		t.Errorf("nothing to cut: %v %v", short.SampleTruncated, short.TruncatedFields)
+	}
+}
+
+func TestPreviewLimitsCapLists(t *testing.T) {
+	l := previewLimits{FieldRunes: 100, ListEntries: 3, ResponseBytes: 1 << 20}
+	p := FeedPreview{
+		Attributes: []AttributePreview{{"Farba", 4}, {"Záruka", 9}, {"Výkon", 1}, {"Objem", 4}, {"Hmotnosť", 7}},
+		Categories: []CategoryPreview{{"Kuchyňa", 2}, {"Dielňa", 5}},
+	}
+	l.apply(&p)
+	if !reflect.DeepEqual(p.Attributes, []AttributePreview{{"Záruka", 9}, {"Hmotnosť", 7}, {"Farba", 4}}) || p.MoreAttributes != 2 {
+		t.Errorf("attributes %v and %d more, want the 3 most used and 2 more", p.Attributes, p.MoreAttributes)
+	}
+	if !reflect.DeepEqual(p.Categories, []CategoryPreview{{"Dielňa", 5}, {"Kuchyňa", 2}}) || p.MoreCategories != 0 {
+		t.Errorf("categories %v and %d more", p.Categories, p.MoreCategories)
+	}
+}
+
+func TestPreviewLimitsResponseSize(t *testing.T) {
+	l := previewLimits{FieldRunes: 1000, ListEntries: 10, ResponseBytes: 4096}
+	p := FeedPreview{Fields: []string{"ITEM_ID", "DESCRIPTION"}}
+	for i := 0; i < 20; i++ {
+		p.Sample = append(p.Sample, map[string]interface{}{"ITEM_ID": fmt.Sprintf("A%d", i), "DESCRIPTION": strings.Repeat("x", 900)})
+	}
+	l.apply(&p)
+	if len(p.Sample) == 0 || len(p.Sample) >= 20 || len(p.Sample)+p.SampleOmitted != 20 {
+		t.Fatalf("kept %d items, omitted %d", len(p.Sample), p.SampleOmitted)
+	}
+	if p.Sample[0]["ITEM_ID"] != "A0" {
+		t.Errorf("first kept item %v, want items dropped from the end", p.Sample[0]["ITEM_ID"])
+	}
+	raw, _ := json.Marshal(p)
+	if len(raw) > l.ResponseBytes {
+		t.Errorf("preview of %d bytes, over the %d byte limit", len(raw), l.ResponseBytes)
+	}
+}
+
+// TestPreviewFeedLongDescriptions previews a feed whose descriptions run to ~50 KB
+func TestPreviewFeedLongDescriptions(t *testing.T) {
+	spec := testutil.FeedSpec{Items: 30, DescriptionWords: 5000, Params: 3, Seed: 3}
+	p := previewFeed(t, spec, 30)
+	if p.SampleSize != 30 || len(p.Sample) != 5 || p.SampleOmitted != 0 {
+		t.Fatalf("sample of %d items (%d sent, %d omitted), want 30 read and 5 sent", p.SampleSize, len(p.Sample), p.SampleOmitted)
+	}
+	if !p.SampleTruncated || !reflect.DeepEqual(p.TruncatedFields, []string{"DESCRIPTION"}) {
+		t.Errorf("truncated %v: %v, want only DESCRIPTION", p.SampleTruncated, p.TruncatedFields)
+	}
+	for _, item := range p.Sample {
+		desc, _ := item["DESCRIPTION"].(string)
+		if utf8.RuneCountInString(desc) != defaultPreviewFieldRunes+1 || !strings.HasSuffix(desc, "…") {
+			t.Fatalf("description of %d runes: %.40q…", utf8.RuneCountInString(desc), desc)
+		}
+	}
+	if p.DownloadedBytes < 30*40*1024 || p.FeedBytes != p.DownloadedBytes {
+		t.Errorf("downloaded %d of %d bytes, want the whole ~1.5 MB feed", p.DownloadedBytes, p.FeedBytes)
+	}
+	raw, _ := json.Marshal(p)
+	if len(raw) > 100*1024 {
+		t.Errorf("preview of %d bytes", len(raw))
+	}
+
+	// a tighter size limit drops sample items instead of failing
+	t.Setenv("FEED_PREVIEW_MAX_BYTES", "6144")
+	p = previewFeed(t, spec, 30)
+	if len(p.Sample) == 0 || len(p.Sample)+p.SampleOmitted != 5 || p.SampleOmitted == 0 {
+		t.Errorf("%d items sent and %d omitted under a 6 KB limit", len(p.Sample), p.SampleOmitted)
+	}
+	if raw, _ := json.Marshal(p); len(raw) > 6144 {
+		t.Errorf("preview of %d bytes, over the 6 KB limit", len(raw))
+	}
+}
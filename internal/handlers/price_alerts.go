@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/money"
+)
+
+// ========== PRICE ALERTS ==========
+
+const (
+	// priceAlertsPerIPHour limits how many alerts one client IP may create
+	priceAlertsPerIPHour = 20
+	// priceAlertsPerEmailHour limits the confirmation e-mails one address receives
+	priceAlertsPerEmailHour = 5
+)
+
+var (
+	priceAlertIPLimiter    = newHourlyLimiter(priceAlertsPerIPHour)
+	priceAlertEmailLimiter = newHourlyLimiter(priceAlertsPerEmailHour)
+)
+
+// CreatePriceAlert subscribes an e-mail to a product's price dropping to target_price.
+// Subscribing again to the same product updates the open alert: an unconfirmed one
+// gets the new target and a fresh confirmation e-mail, a confirmed one is left as it is.
+func (h *Handlers) CreatePriceAlert(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	var input struct {
+		Email       string      `json:"email"`
+		TargetPrice money.Price `json:"target_price"`
+		Lang        string      `json:"lang"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	input.Email = strings.TrimSpace(strings.ToLower(input.Email))
+	if addr, err := mail.ParseAddress(input.Email); err != nil || addr.Address != input.Email {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid email"})
+	}
+	if input.TargetPrice <= 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "target_price must be greater than 0"})
+	}
+
+	if !priceAlertIPLimiter.allow(c.IP()) || !priceAlertEmailLimiter.allow(input.Email) {
+		c.Set(fiber.HeaderRetryAfter, "3600")
+		return c.Status(429).JSON(fiber.Map{"success": false, "error": "Too many price alerts, try again later"})
+	}
+
+	ctx := context.Background()
+	var title string
+	if err := h.db.Pool.QueryRow(ctx, "SELECT title FROM products WHERE id = $1::uuid AND is_active = true", productID).Scan(&title); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Product not found"})
+	}
+
+	lang := requestLang(c, input.Lang)
+	var alertID string
+	var confirmed, created bool
+	err := h.db.Pool.QueryRow(ctx, `
+		INSERT INTO price_alerts (product_id, email, target_price, lang, created_at)
+		VALUES ($1::uuid, $2, $3, $4, NOW())
+		ON CONFLICT (product_id, email) WHERE triggered_at IS NULL AND unsubscribed_at IS NULL DO UPDATE SET
+			target_price = CASE WHEN price_alerts.is_confirmed THEN price_alerts.target_price ELSE EXCLUDED.target_price END,
+			lang = CASE WHEN price_alerts.is_confirmed THEN price_alerts.lang ELSE EXCLUDED.lang END
+		RETURNING id, COALESCE(is_confirmed, false), xmax = 0
+	`, productID, input.Email, input.TargetPrice, lang).Scan(&alertID, &confirmed, &created)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if confirmed {
+		return c.JSON(fiber.Map{"success": true, "message": "Price alert already active", "data": fiber.Map{"id": alertID}})
+	}
+
+	confirmURL := fmt.Sprintf("%s/api/v1/price-alerts/confirm?token=%s", publicURL(), signToken("price-alert-confirm", alertID))
+	data := map[string]string{"Title": title, "TargetPrice": formatPrice(input.TargetPrice.Float()), "ConfirmURL": confirmURL}
+	if err := h.queueNotification(ctx, "price_alert_confirm", lang, input.Email, data); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	status := 200
+	if created {
+		status = 201
+	}
+	return c.Status(status).JSON(fiber.Map{"success": true, "message": "Confirmation email sent", "data": fiber.Map{"id": alertID}})
+}
+
+func (h *Handlers) ConfirmPriceAlert(c *fiber.Ctx) error {
+	alertID, ok := verifyToken(c.Query("token"), "price-alert-confirm")
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid token"})
+	}
+	ctx := context.Background()
+	tag, err := h.db.Pool.Exec(ctx, `
+		UPDATE price_alerts SET is_confirmed = true, confirmed_at = COALESCE(confirmed_at, NOW())
+		WHERE id = $1::uuid AND unsubscribed_at IS NULL
+	`, alertID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Alert not found"})
+	}
+
+	// The price may already be below target at confirmation time
+	go h.checkPriceAlerts(context.Background())
+
+	return c.JSON(fiber.Map{"success": true, "message": "Price alert confirmed"})
+}
+
+func (h *Handlers) UnsubscribePriceAlert(c *fiber.Ctx) error {
+	alertID, ok := verifyToken(c.Query("token"), "price-alert-unsubscribe")
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid token"})
+	}
+	ctx := context.Background()
+	_, err := h.db.Pool.Exec(ctx, "UPDATE price_alerts SET unsubscribed_at = COALESCE(unsubscribed_at, NOW()) WHERE id = $1::uuid", alertID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"success": true, "message": "Unsubscribed"})
+}
+
+func (h *Handlers) AdminPriceAlertStats(c *fiber.Ctx) error {
+	ctx := context.Background()
+	var total, confirmed, pending, triggered, unsubscribed int
+	err := h.db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE is_confirmed AND triggered_at IS NULL AND unsubscribed_at IS NULL),
+		       COUNT(*) FILTER (WHERE NOT is_confirmed AND unsubscribed_at IS NULL),
+		       COUNT(*) FILTER (WHERE triggered_at IS NOT NULL),
+		       COUNT(*) FILTER (WHERE unsubscribed_at IS NOT NULL)
+		FROM price_alerts
+	`).Scan(&total, &confirmed, &pending, &triggered, &unsubscribed)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	var queued, sent, failed int
+	h.db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*) FILTER (WHERE status = 'pending'),
+		       COUNT(*) FILTER (WHERE status = 'sent'),
+		       COUNT(*) FILTER (WHERE status = 'dead')
+		FROM notification_events WHERE type LIKE 'price_alert%'
+	`).Scan(&queued, &sent, &failed)
+
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"total": total, "active": confirmed, "unconfirmed": pending, "triggered": triggered, "unsubscribed": unsubscribed,
+		"notifications": fiber.Map{"queued": queued, "sent": sent, "failed": failed},
+	}})
+}
+
+// checkPriceAlerts fires every confirmed alert whose product price dropped to the target.
+// Each alert is triggered at most once: marking it triggered and queueing its e-mail
+// commit together, so a failed queue leaves the alert for the next check.
+func (h *Handlers) checkPriceAlerts(ctx context.Context) {
+	tx, err := h.db.Pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Price alert check failed: %v", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		UPDATE price_alerts a SET triggered_at = NOW()
+		FROM products p
+		WHERE a.product_id = p.id AND a.is_confirmed = true AND a.triggered_at IS NULL
+		  AND a.unsubscribed_at IS NULL AND p.is_active = true AND p.price_min > 0 AND (`+effectivePriceMin+`) <= a.target_price
+		RETURNING a.id, a.email, a.lang, a.target_price, p.title, `+effectivePriceMin+`
+	`)
+	if err != nil {
+		log.Printf("Price alert check failed: %v", err)
+		return
+	}
+
+	type triggered struct {
+		id, email, lang, title string
+		target, price          float64
+	}
+	var alerts []triggered
+	for rows.Next() {
+		var t triggered
+		if err := rows.Scan(&t.id, &t.email, &t.lang, &t.target, &t.title, &t.price); err != nil {
+			rows.Close()
+			log.Printf("Price alert check failed: %v", err)
+			return
+		}
+		alerts = append(alerts, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Printf("Price alert check failed: %v", err)
+		return
+	}
+
+	for _, a := range alerts {
+		unsubscribeURL := fmt.Sprintf("%s/api/v1/price-alerts/unsubscribe?token=%s", publicURL(), signToken("price-alert-unsubscribe", a.id))
+		data := map[string]string{"Title": a.title, "Price": formatPrice(a.price), "TargetPrice": formatPrice(a.target), "UnsubscribeURL": unsubscribeURL}
+		if err := queueNotificationOn(ctx, tx, "price_alert", a.lang, a.email, data); err != nil {
+			log.Printf("Price alert %s: queue failed, %d alerts left for the next check: %v", a.id, len(alerts), err)
+			return
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("Price alert check failed: %v", err)
+		return
+	}
+	if len(alerts) > 0 {
+		log.Printf("Triggered %d price alerts", len(alerts))
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestHourlyLimiter(t *testing.T) {
+	l := newHourlyLimiter(3)
+	for i := 0; i < 3; i++ {
+		if !l.allow("1.2.3.4") {
+			t.Fatalf("event %d refused under the limit", i+1)
+		}
+	}
+	if l.allow("1.2.3.4") {
+		t.Error("fourth event within the hour allowed")
+	}
+	if !l.allow("5.6.7.8") {
+		t.Error("another key refused")
+	}
+
+	old := time.Now().Add(-61 * time.Minute)
+	l.events["1.2.3.4"] = []time.Time{old, old, old}
+	if !l.allow("1.2.3.4") {
+		t.Error("events older than an hour still counted")
+	}
+	if n := len(l.events["1.2.3.4"]); n != 1 {
+		t.Errorf("%d events kept, want the expired ones dropped", n)
+	}
+
+	// keys whose window emptied are dropped by the next sweep
+	l.events["9.9.9.9"] = []time.Time{old}
+	l.events["8.8.8.8"] = []time.Time{old, time.Now()}
+	l.lastSweep = time.Now().Add(-limiterSweepInterval - time.Second)
+	l.allow("1.2.3.4")
+	if _, ok := l.events["9.9.9.9"]; ok {
+		t.Error("key without events in the last hour kept")
+	}
+	if _, ok := l.events["8.8.8.8"]; !ok {
+		t.Error("key with a recent event swept")
+	}
+	if len(l.events) != 3 {
+		t.Errorf("%d keys after the sweep, want 3", len(l.events))
+	}
+}
+
+func priceAlertApp(h *Handlers) *fiber.App {
+	app := fiber.New()
+	app.Post("/products/:id/price-alerts", h.CreatePriceAlert)
+	return app
+}
+
+func TestCreatePriceAlertValidation(t *testing.T) {
+	app := priceAlertApp(&Handlers{})
+	path := "/products/3f0c9a4e-8d1b-4c57-9a0e-2b6d7f1e5c48/price-alerts"
+	tests := map[string]struct {
+		body interface{}
+		want int
+	}{
+		"invalid email":  {fiber.Map{"email": "not-an-email", "target_price": 10}, 400},
+		"display name":   {fiber.Map{"email": "Ján <jan@example.com>", "target_price": 10}, 400},
+		"zero target":    {fiber.Map{"email": "jan@example.com", "target_price": 0}, 400},
+		"negative price": {fiber.Map{"email": "jan@example.com", "target_price": -5}, 400},
+		"malformed body": {"[", 400},
+	}
+	for name, tt := range tests {
+		if status, _ := callJSON(t, app, "POST", path, tt.body); status != tt.want {
+			t.Errorf("%s: status %d, want %d", name, status, tt.want)
+		}
+	}
+
+	// the address has had its confirmation e-mails for this hour
+	email := "limited@example.com"
+	for i := 0; i < priceAlertsPerEmailHour; i++ {
+		priceAlertEmailLimiter.allow(email)
+	}
+	status, out := callJSON(t, app, "POST", path, fiber.Map{"email": "  Limited@Example.com ", "target_price": 10})
+	if status != 429 || out.Success {
+		t.Errorf("over the per-email limit: status %d, want 429", status)
+	}
+}
+
+func TestCreatePriceAlertDedupe(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	productID := env.createTestProduct(t, "Kávovar", 120)
+	app := priceAlertApp(env.h)
+	path := "/products/" + productID + "/price-alerts"
+	email := fmt.Sprintf("dedupe-%d@example.com", time.Now().UnixNano())
+
+	alertID := func(out testEnvelope) string {
+		var data struct {
+			ID string `json:"id"`
+		}
+		json.Unmarshal(out.Data, &data)
+		return data.ID
+	}
+	status, first := callJSON(t, app, "POST", path, fiber.Map{"email": email, "target_price": 100})
+	if status != 201 {
+		t.Fatalf("first subscription: status %d (%s)", status, first.Error)
+	}
+	status, second := callJSON(t, app, "POST", path, fiber.Map{"email": email, "target_price": 90})
+	if status != 200 || alertID(second) != alertID(first) {
+		t.Fatalf("repeated subscription: status %d, id %s, want 200 and the first alert %s", status, alertID(second), alertID(first))
+	}
+	var target float64
+	env.db.Pool.QueryRow(ctx, "SELECT target_price FROM price_alerts WHERE id = $1::uuid", alertID(first)).Scan(&target)
+	if target != 90 {
+		t.Errorf("unconfirmed alert target %v, want the updated 90", target)
+	}
+
+	env.db.Pool.Exec(ctx, "UPDATE price_alerts SET is_confirmed = true WHERE id = $1::uuid", alertID(first))
+	status, third := callJSON(t, app, "POST", path, fiber.Map{"email": email, "target_price": 500})
+	if status != 200 || third.Message != "Price alert already active" {
+		t.Errorf("subscription to a confirmed alert: status %d %q", status, third.Message)
+	}
+	env.db.Pool.QueryRow(ctx, "SELECT target_price FROM price_alerts WHERE id = $1::uuid", alertID(first)).Scan(&target)
+	if target != 90 {
+		t.Errorf("confirmed alert target changed to %v", target)
+	}
+
+	var alerts, mails int
+	env.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM price_alerts WHERE email = $1", email).Scan(&alerts)
+	env.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM notification_events WHERE recipient = $1 AND type = 'price_alert_confirm'", email).Scan(&mails)
+	if alerts != 1 || mails != 2 {
+		t.Errorf("%d alerts and %d confirmation e-mails, want 1 and 2", alerts, mails)
+	}
+}
+
+func TestCheckPriceAlertsQueuesInTransaction(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	productID := env.createTestProduct(t, "Mixér", 80)
+	blocked := fmt.Sprintf("blocked-%d@example.com", time.Now().UnixNano())
+
+	var alertID string
+	err := env.db.Pool.QueryRow(ctx, `
+		INSERT INTO price_alerts (product_id, email, target_price, is_confirmed, confirmed_at) VALUES ($1::uuid, $2, 100, true, NOW()) RETURNING id
+	`, productID, blocked).Scan(&alertID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := func() (triggered bool, queued int) {
+		env.db.Pool.QueryRow(ctx, "SELECT triggered_at IS NOT NULL FROM price_alerts WHERE id = $1::uuid", alertID).Scan(&triggered)
+		env.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM notification_events WHERE recipient = $1 AND type = 'price_alert'", blocked).Scan(&queued)
+		return
+	}
+
+	// the outbox refuses the e-mail, so the alert must stay untriggered
+	if _, err := env.db.Pool.Exec(ctx, fmt.Sprintf("ALTER TABLE notification_events ADD CONSTRAINT test_blocked CHECK (recipient <> '%s') NOT VALID", blocked)); err != nil {
+		t.Fatal(err)
+	}
+	env.h.checkPriceAlerts(ctx)
+	if triggered, queued := state(); triggered || queued != 0 {
+		t.Errorf("failed queue: triggered=%v, %d e-mails; want the alert left for the next check", triggered, queued)
+	}
+
+	env.db.Pool.Exec(ctx, "ALTER TABLE notification_events DROP CONSTRAINT test_blocked")
+	env.h.checkPriceAlerts(ctx)
+	if triggered, queued := state(); !triggered || queued != 1 {
+		t.Errorf("next check: triggered=%v, %d e-mails; want triggered with one e-mail", triggered, queued)
+	}
+	env.h.checkPriceAlerts(ctx)
+	if _, queued := state(); queued != 1 {
+		t.Errorf("%d e-mails after another check, want the alert fired once", queued)
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== PRICE RANGE REPAIR ==========
+
+// AdminRepairPriceRanges brings existing rows in line with price_max >= price_min > 0.
+// A price_max below price_min (usually 0) becomes price_min, a missing price_min takes
+// price_max, and the net columns follow the same rules. Products without any price
+// cannot be repaired and are only reported. ?dry_run=true counts without writing.
+func (h *Handlers) AdminRepairPriceRanges(c *fiber.Ctx) error {
+	dryRun := c.QueryBool("dry_run")
+	ctx := context.Background()
+
+	var unrepairable int64
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM products WHERE COALESCE(price_min,0) <= 0 AND COALESCE(price_max,0) <= 0").Scan(&unrepairable)
+
+	const broken = `(COALESCE(price_min,0) <= 0 AND price_max > 0) OR (price_min > 0 AND COALESCE(price_max,0) < price_min)
+		OR (price_min_net > 0 AND COALESCE(price_max_net,0) < price_min_net)`
+	if dryRun {
+		var count int64
+		if err := h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM products WHERE "+broken).Scan(&count); err != nil {
+			return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"dry_run": true, "repairable": count, "unrepairable": unrepairable}})
+	}
+
+	ids, err := collectIDs(h.db.Pool.Query(ctx, `
+		UPDATE products SET
+		       price_min = CASE WHEN COALESCE(price_min,0) <= 0 THEN price_max ELSE price_min END,
+		       price_min_net = CASE WHEN COALESCE(price_min,0) <= 0 THEN COALESCE(price_max_net, price_min_net) ELSE price_min_net END,
+		       price_max = GREATEST(price_min, price_max),
+		       price_max_net = GREATEST(price_min_net, price_max_net),
+		       updated_at = NOW(), version = COALESCE(version,1) + 1
+		WHERE `+broken+`
+		RETURNING id::text
+	`))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	h.queueESSync(ids...)
+	if len(ids) > 0 {
+		invalidateHomepage()
+	}
+	h.audit(ctx, c, "price.repair", "product", "", fiber.Map{"repaired": len(ids), "unrepairable": unrepairable})
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"repaired": len(ids), "unrepairable": unrepairable}})
+}
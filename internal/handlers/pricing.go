@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"math"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/models"
+	"megabuy-go/internal/money"
+)
+
+const defaultVATRate = 20.0
+
+// roundCents rounds half away from zero to two decimals; the tiny bias absorbs
+// binary representation error such as 1.005*100 = 100.49999...
+func roundCents(v float64) float64 {
+	return math.Round(v*100+math.Copysign(1e-7, v)) / 100
+}
+
+// splitPrice returns the gross and net value of price given the VAT rate in percent
+// and whether price already includes VAT
+func splitPrice(price, vatRate float64, isGross bool) (gross, net float64) {
+	if vatRate < 0 {
+		vatRate = defaultVATRate
+	}
+	factor := 1 + vatRate/100
+	if isGross {
+		return roundCents(price), roundCents(price / factor)
+	}
+	return roundCents(price * factor), roundCents(price)
+}
+
+// priceModeParam reads ?price_mode=net|gross (default gross)
+func priceModeParam(c *fiber.Ctx) (string, bool) {
+	switch mode := c.Query("price_mode", "gross"); mode {
+	case "gross", "net":
+		return mode, true
+	default:
+		return "", false
+	}
+}
+
+func invalidPriceMode(c *fiber.Ctx) error {
+	return c.Status(400).JSON(fiber.Map{"success": false, "error": "price_mode must be net or gross"})
+}
+
+// promoActive holds while a product's scheduled promo price applies
+const promoActive = "(p.promo_price IS NOT NULL AND p.promo_starts_at <= NOW() AND p.promo_ends_at > NOW())"
+
+// Effective prices: a running promo lowers price_min, and price_max too unless the
+// product spans a price range
+const (
+	effectivePriceMin    = "CASE WHEN " + promoActive + " THEN LEAST(p.promo_price, p.price_min) ELSE p.price_min END"
+	effectivePriceMax    = "CASE WHEN " + promoActive + " AND p.price_max <= p.price_min THEN LEAST(p.promo_price, p.price_max) ELSE p.price_max END"
+	effectivePriceMinNet = "CASE WHEN " + promoActive + " THEN LEAST(COALESCE(p.promo_price_net, p.promo_price), COALESCE(p.price_min_net, p.price_min)) ELSE COALESCE(p.price_min_net, p.price_min) END"
+	effectivePriceMaxNet = "CASE WHEN " + promoActive + " AND p.price_max <= p.price_min THEN LEAST(COALESCE(p.promo_price_net, p.promo_price), COALESCE(p.price_max_net, p.price_max)) ELSE COALESCE(p.price_max_net, p.price_max) END"
+
+	// promoEndsColumn is NULL unless a promo is running
+	promoEndsColumn = "CASE WHEN " + promoActive + " THEN p.promo_ends_at END"
+)
+
+// priceColumns returns the SQL expressions of the effective min/max prices for mode
+func priceColumns(mode string) (string, string) {
+	if mode == "net" {
+		return "(" + effectivePriceMinNet + ")", "(" + effectivePriceMaxNet + ")"
+	}
+	return "(" + effectivePriceMin + ")", "(" + effectivePriceMax + ")"
+}
+
+// promoColumns selects the regular price_min for mode and the end of a running promo,
+// scanned into models.NewPromoPrice
+func promoColumns(mode string) string {
+	if mode == "net" {
+		return "COALESCE(p.price_min_net, p.price_min), " + promoEndsColumn
+	}
+	return "p.price_min, " + promoEndsColumn
+}
+
+// feedOriginalPrice is the gross reference price (PRICE_BEFORE) of a feed item, nil when absent
+func feedOriginalPrice(feed models.Feed, data map[string]interface{}) *float64 {
+	price := getFloat(data, "original_price")
+	if price <= 0 {
+		return nil
+	}
+	gross, _ := splitPrice(price, feed.VATRate, feed.PricesIncludeVAT)
+	return &gross
+}
+
+// priceRange enforces the price invariant price_max >= price_min > 0. A missing
+// price_max defaults to price_min; the message is empty when the range is valid.
+func priceRange(priceMin, priceMax money.Price) (money.Price, money.Price, string) {
+	if priceMin <= 0 {
+		return 0, 0, "price_min must be greater than 0"
+	}
+	if priceMax == 0 {
+		priceMax = priceMin
+	}
+	if priceMax < priceMin {
+		return 0, 0, "price_max must not be lower than price_min"
+	}
+	return priceMin, priceMax, ""
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/models"
+	"megabuy-go/internal/money"
+)
+
+func TestSplitPrice(t *testing.T) {
+	tests := []struct {
+		name    string
+		price   float64
+		vatRate float64
+		isGross bool
+		gross   float64
+		net     float64
+	}{
+		{"19.99 gross at 20%", 19.99, 20, true, 19.99, 16.66},
+		{"19.99 gross at 23%", 19.99, 23, true, 19.99, 16.25},
+		{"net at 20% back to 19.99", 16.66, 20, false, 19.99, 16.66},
+		{"net at 23% back to 19.99", 16.25, 23, false, 19.99, 16.25},
+		{"zero rate", 19.99, 0, true, 19.99, 19.99},
+		{"negative rate falls back to 20%", 19.99, -1, true, 19.99, 16.66},
+		{"half cent rounds up", 1.005, 0, true, 1.01, 1.01},
+		{"reduced 10% rate", 5.50, 10, true, 5.50, 5.00},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gross, net := splitPrice(tt.price, tt.vatRate, tt.isGross)
+			if gross != tt.gross || net != tt.net {
+				t.Errorf("splitPrice(%v, %v, %v) = %v, %v; want %v, %v", tt.price, tt.vatRate, tt.isGross, gross, net, tt.gross, tt.net)
+			}
+		})
+	}
+}
+
+func TestRoundCents(t *testing.T) {
+	for in, want := range map[float64]float64{
+		19.990000000000002: 19.99,
+		16.658333:          16.66,
+		0.125:              0.13,
+		-0.125:             -0.13,
+		2.675:              2.68,
+	} {
+		if got := roundCents(in); got != want {
+			t.Errorf("roundCents(%v) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestFeedOriginalPrice(t *testing.T) {
+	feed := models.Feed{VATRate: 20, PricesIncludeVAT: false}
+	if got := feedOriginalPrice(feed, map[string]interface{}{"original_price": 16.66}); got == nil || *got != 19.99 {
+		t.Errorf("net PRICE_BEFORE = %v, want gross 19.99", got)
+	}
+	if got := feedOriginalPrice(feed, map[string]interface{}{}); got != nil {
+		t.Errorf("missing PRICE_BEFORE = %v, want nil", *got)
+	}
+}
+
+func TestPriceRange(t *testing.T) {
+	if min, max, msg := priceRange(money.New(19.99), 0); msg != "" || min != max {
+		t.Errorf("missing price_max: %v-%v %q, want it set to price_min", min, max, msg)
+	}
+	if _, _, msg := priceRange(0, money.New(1)); msg == "" {
+		t.Error("zero price_min accepted")
+	}
+	if _, _, msg := priceRange(money.New(5), money.New(1)); msg == "" {
+		t.Error("price_max below price_min accepted")
+	}
+}
+
+func TestPriceModeParam(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		mode, ok := priceModeParam(c)
+		if !ok {
+			return invalidPriceMode(c)
+		}
+		return c.SendString(mode)
+	})
+	for query, want := range map[string]int{"": 200, "?price_mode=net": 200, "?price_mode=gross": 200, "?price_mode=NET": 400, "?price_mode=eur": 400} {
+		resp, err := app.Test(httptest.NewRequest("GET", "/"+query, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("%q: status %d, want %d", query, resp.StatusCode, want)
+		}
+	}
+}
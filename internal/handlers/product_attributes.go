@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== ADMIN PRODUCT ATTRIBUTES ==========
+
+const (
+	maxAttributeNameLength  = 255
+	maxAttributeValueLength = 1000
+)
+
+// attributeEdit is one entry of the attributes array on admin product updates.
+// In patch mode entries are matched by name and Delete removes the attribute.
+type attributeEdit struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Position *int   `json:"position"`
+	Delete   bool   `json:"delete"`
+}
+
+// validateAttributeEdits trims the entries in place and returns a message for the first invalid one
+func validateAttributeEdits(mode string, edits []attributeEdit) string {
+	if mode != "replace" && mode != "patch" {
+		return "attributes_mode must be replace or patch"
+	}
+	seen := map[string]bool{}
+	for i := range edits {
+		e := &edits[i]
+		e.Name, e.Value = strings.TrimSpace(e.Name), strings.TrimSpace(e.Value)
+		switch {
+		case e.Name == "":
+			return fmt.Sprintf("attributes[%d]: name required", i)
+		case len(e.Name) > maxAttributeNameLength:
+			return fmt.Sprintf("attributes[%d]: name longer than %d characters", i, maxAttributeNameLength)
+		case e.Delete && mode == "replace":
+			return fmt.Sprintf("attributes[%d]: delete is only allowed in patch mode", i)
+		case !e.Delete && e.Value == "":
+			return fmt.Sprintf("attributes[%d]: value required", i)
+		case len(e.Value) > maxAttributeValueLength:
+			return fmt.Sprintf("attributes[%d]: value longer than %d characters", i, maxAttributeValueLength)
+		case seen[strings.ToLower(e.Name)]:
+			return fmt.Sprintf("attributes[%d]: duplicate name %q", i, e.Name)
+		}
+		seen[strings.ToLower(e.Name)] = true
+	}
+	return ""
+}
+
+// applyAttributeEdits replaces the product's attributes or patches them by name, in one transaction
+func (h *Handlers) applyAttributeEdits(ctx context.Context, productID, mode string, edits []attributeEdit) error {
+	tx, err := h.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if mode == "replace" {
+		if _, err := tx.Exec(ctx, "DELETE FROM product_attributes WHERE product_id = $1::uuid", productID); err != nil {
+			return err
+		}
+	}
+	for i, e := range edits {
+		if e.Delete {
+			if _, err := tx.Exec(ctx, "DELETE FROM product_attributes WHERE product_id = $1::uuid AND lower(name) = lower($2)", productID, e.Name); err != nil {
+				return err
+			}
+			continue
+		}
+		position := e.Position
+		if position == nil && mode == "replace" {
+			position = &i
+		}
+		tag, err := tx.Exec(ctx, `
+			UPDATE product_attributes SET name = $2, value = $3, position = COALESCE($4, position)
+			WHERE product_id = $1::uuid AND lower(name) = lower($2)
+		`, productID, e.Name, e.Value, position)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() > 0 {
+			continue
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO product_attributes (product_id, name, value, position, created_at)
+			VALUES ($1::uuid, $2, $3, COALESCE($4, (SELECT COALESCE(MAX(position) + 1, 0) FROM product_attributes WHERE product_id = $1::uuid)), NOW())
+		`, productID, e.Name, e.Value, position); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// productAttributes lists a product's attributes for the admin edit form
+func (h *Handlers) productAttributes(ctx context.Context, productID string) []fiber.Map {
+	attributes := []fiber.Map{}
+	rows, err := h.db.Pool.Query(ctx, `SELECT id, name, value, COALESCE(position,0) FROM product_attributes WHERE product_id = $1::uuid ORDER BY position, name`, productID)
+	if err != nil {
+		return attributes
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, name, value string
+		var position int
+		rows.Scan(&id, &name, &value, &position)
+		attributes = append(attributes, fiber.Map{"id": id, "name": name, "slug": makeSlug(name), "value": value, "position": position})
+	}
+	return attributes
+}
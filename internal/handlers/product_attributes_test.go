@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"megabuy-go/internal/models"
+)
+
+type attributeRow struct {
+	name, value string
+	position    int
+}
+
+func (env *testEnv) productAttributes(t *testing.T, productID string) []attributeRow {
+	t.Helper()
+	rows, err := env.db.Pool.Query(context.Background(),
+		"SELECT name, value, position FROM product_attributes WHERE product_id = $1::uuid ORDER BY position", productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var attrs []attributeRow
+	for rows.Next() {
+		var a attributeRow
+		if err := rows.Scan(&a.name, &a.value, &a.position); err != nil {
+			t.Fatal(err)
+		}
+		attrs = append(attrs, a)
+	}
+	return attrs
+}
+
+func TestAttributeError(t *testing.T) {
+	cause := errors.New("value too long for type character varying(255)")
+	var err error = attributeError{cause}
+	if err.Error() != "saving attributes: value too long for type character varying(255)" {
+		t.Errorf("message %q", err.Error())
+	}
+	if _, ok := err.(attributeError); !ok {
+		t.Error("not recognisable as an attributeError")
+	}
+}
+
+func TestSaveProductAttributesIsAtomic(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	id := env.createTestProduct(t, "Rýchlovarná kanvica", 29.9)
+
+	params := []map[string]string{
+		{"name": "Farba", "value": "čierna"},
+		{"name": "", "value": "bez názvu"},
+		{"name": "Objem", "value": "1,7 l"},
+	}
+	if err := env.h.saveProductAttributes(ctx, id, params); err != nil {
+		t.Fatal(err)
+	}
+	want := []attributeRow{{"Farba", "čierna", 0}, {"Objem", "1,7 l", 1}}
+	got := env.productAttributes(t, id)
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("attributes %v, want %v", got, want)
+	}
+
+	// the over-long name fails the insert after the delete: the old rows must survive
+	failing := []map[string]string{
+		{"name": "Príkon", "value": "2200 W"},
+		{"name": strings.Repeat("n", 300), "value": "x"},
+	}
+	if err := env.h.saveProductAttributes(ctx, id, failing); err == nil {
+		t.Fatal("a 300 character attribute name was saved")
+	}
+	if got := env.productAttributes(t, id); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("after a failed save: %v, want the previous %v", got, want)
+	}
+
+	// no PARAMs leaves the attributes alone
+	if err := env.h.saveProductAttributes(ctx, id, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := env.productAttributes(t, id); len(got) != 2 {
+		t.Errorf("saving no params left %v", got)
+	}
+}
+
+func TestFeedUpdateReportsAttributeFailure(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	id := env.createTestProduct(t, "Kanvica", 29.9)
+	feed := models.Feed{ID: "00000000-0000-0000-0000-000000001689", VATRate: 20, PricesIncludeVAT: true}
+	data := map[string]interface{}{"title": "Kanvica Tefal", "price": 34.9}
+
+	err := env.h.updateProductFromFeed(ctx, feed, id, data, []map[string]string{{"name": strings.Repeat("n", 300), "value": "x"}})
+	if _, ok := err.(attributeError); !ok {
+		t.Fatalf("err = %v, want an attributeError", err)
+	}
+	// the product row itself was saved
+	var title string
+	env.db.Pool.QueryRow(ctx, "SELECT title FROM products WHERE id = $1::uuid", id).Scan(&title)
+	if title != "Kanvica Tefal" {
+		t.Errorf("title %q, want the feed's", title)
+	}
+
+	if err := env.h.updateProductFromFeed(ctx, feed, id, data, []map[string]string{{"name": "Farba", "value": "biela"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := env.productAttributes(t, id); len(got) != 1 || got[0] != (attributeRow{"Farba", "biela", 0}) {
+		t.Errorf("attributes %v", got)
+	}
+}
+
+func TestNormalizeParams(t *testing.T) {
+	params := []map[string]string{
+		{"name": "Farba", "value": "čierna"},
+		{"name": "Objem", "value": " 1,7 l "},
+		{"name": "farba", "value": "biela"},
+		{"name": "", "value": "bez názvu"},
+		{"name": "Príkon", "value": ""},
+		{"name": "FARBA", "value": "Čierna"},
+		{"name": " Objem ", "value": "1,7 l"},
+		{"name": "Materiál", "value": "nerez"},
+		{"name": "Farba", "value": "červená"},
+	}
+	names, values, positions := normalizeParams(params)
+	wantNames := []string{"Farba", "Objem", "Farba", "Materiál", "Farba"}
+	wantValues := []string{"čierna", "1,7 l", "biela", "nerez", "červená"}
+	if !reflect.DeepEqual(names, wantNames) || !reflect.DeepEqual(values, wantValues) {
+		t.Errorf("rows %v = %v, want %v = %v", names, values, wantNames, wantValues)
+	}
+	if !reflect.DeepEqual(positions, []int32{0, 1, 2, 3, 4}) {
+		t.Errorf("positions %v, want dense source order", positions)
+	}
+
+	// the same feed always yields the same rows
+	for i := 0; i < 10; i++ {
+		n, v, p := normalizeParams(params)
+		if !reflect.DeepEqual(n, names) || !reflect.DeepEqual(v, values) || !reflect.DeepEqual(p, positions) {
+			t.Fatal("normalizeParams is not deterministic")
+		}
+	}
+	if n, _, _ := normalizeParams([]map[string]string{{"name": " ", "value": "x"}}); len(n) != 0 {
+		t.Errorf("blank name kept: %v", n)
+	}
+}
+
+func TestMultiValueAttributes(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	id := env.createTestProduct(t, "Tričko", 12.9)
+	params := []map[string]string{
+		{"name": "Veľkosť", "value": "M"},
+		{"name": "Farba", "value": "čierna"},
+		{"name": "Veľkosť", "value": "L"},
+		{"name": "Farba", "value": "biela"},
+		{"name": "Materiál", "value": "bavlna"},
+	}
+	if err := env.h.saveProductAttributes(ctx, id, params); err != nil {
+		t.Fatal(err)
+	}
+
+	detail, err := env.h.productDetail(ctx, models.Product{ID: id}, "gross")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.Attribute{
+		{Name: "Veľkosť", Value: "M", Values: []string{"M", "L"}},
+		{Name: "Farba", Value: "čierna", Values: []string{"čierna", "biela"}},
+		{Name: "Materiál", Value: "bavlna", Values: []string{"bavlna"}},
+	}
+	if !reflect.DeepEqual(detail.Attributes, want) {
+		t.Errorf("detail attributes %v, want %v", detail.Attributes, want)
+	}
+
+	// the index gets one nested document per value, so a filter on any value matches
+	indexed, err := env.h.esAttributes(ctx, []string{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, a := range indexed[id] {
+		got = append(got, a.Name+"="+a.Value)
+	}
+	if !reflect.DeepEqual(got, []string{"Veľkosť=M", "Farba=čierna", "Veľkosť=L", "Farba=biela", "Materiál=bavlna"}) {
+		t.Errorf("indexed attributes %v", got)
+	}
+}
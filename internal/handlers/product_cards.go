@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"megabuy-go/internal/models"
+	"megabuy-go/internal/money"
+)
+
+// ========== PRODUCT CARDS ==========
+
+// productCardColumns selects a models.ProductCard from products p joined with
+// categories c, in the order scanProductCard reads it
+func productCardColumns(priceMode string) string {
+	priceMinCol, priceMaxCol := priceColumns(priceMode)
+	return `p.id, p.title, p.slug, COALESCE(p.short_description,''), COALESCE(p.excerpt,''), COALESCE(p.image_url,''), ` + priceMinCol + `, ` + priceMaxCol + `,
+		COALESCE(p.stock_status,'instock'), COALESCE(p.brand,''), COALESCE(c.name,''), COALESCE(c.slug,''), ` + discountColumn + `,
+		COALESCE(p.rating,0)::float8, p.release_date, ` + promoColumns(priceMode)
+}
+
+// scanProductCard reads the productCardColumns of the current row, then any columns
+// selected after them into extra. Labels is left for attachLabels.
+func scanProductCard(rows pgx.Rows, extra ...interface{}) (models.ProductCard, error) {
+	var p models.ProductCard
+	var regularPrice money.Price
+	var releaseDate, promoEndsAt *time.Time
+	dest := []interface{}{&p.ID, &p.Title, &p.Slug, &p.ShortDescription, &p.Excerpt, &p.ImageURL, &p.PriceMin, &p.PriceMax,
+		&p.StockStatus, &p.Brand, &p.CategoryName, &p.CategorySlug, &p.DiscountPercent,
+		&p.Rating, &releaseDate, &regularPrice, &promoEndsAt}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return p, err
+	}
+	p.ReleaseDate = models.FormatReleaseDate(releaseDate)
+	p.Promo = models.NewPromoPrice(regularPrice, p.PriceMin, promoEndsAt)
+	return p, nil
+}
+
+// scanProductCards reads rows of productCardColumns and closes them
+func scanProductCards(rows pgx.Rows) []models.ProductCard {
+	defer rows.Close()
+	products := []models.ProductCard{}
+	for rows.Next() {
+		if p, err := scanProductCard(rows); err == nil {
+			products = append(products, p)
+		}
+	}
+	return products
+}
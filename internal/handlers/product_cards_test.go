@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/models"
+	"megabuy-go/internal/money"
+)
+
+// cardsWithID collects the objects of a decoded response that are cards of the product id
+func cardsWithID(v interface{}, id string, found *[]map[string]interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if _, ok := v["price_min"]; ok && v["id"] == id {
+			*found = append(*found, v)
+		}
+		for _, child := range v {
+			cardsWithID(child, id, found)
+		}
+	case []interface{}:
+		for _, child := range v {
+			cardsWithID(child, id, found)
+		}
+	}
+}
+
+// TestProductCardContract requests every endpoint that lists product cards and checks
+// they all send the same fields for the same product, apart from the extras an
+// endpoint documents on top of the card
+func TestProductCardContract(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	suffix := time.Now().UnixNano()
+	categoryID, categorySlug := env.createTestCategory(t, "Kávovary", "")
+	brand := fmt.Sprintf("Kartabrand%d", suffix)
+	release := time.Now().AddDate(0, 1, 0).UTC().Truncate(24 * time.Hour)
+
+	id := env.createTestProduct(t, "Kávovar na karte", 299.9)
+	host := env.createTestProduct(t, "Mlynček", 49.9)
+	if _, err := env.db.Pool.Exec(ctx, `
+		UPDATE products SET brand = $2, category_id = $3::uuid, is_featured = true, stock_status = 'preorder',
+		       release_date = $4, short_description = 'Automatický kávovar', excerpt = 'Automatický kávovar', rating = 4.5
+		WHERE id = $1::uuid
+	`, id, brand, categoryID, release); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.db.Pool.Exec(ctx, `
+		INSERT INTO product_relations (product_id, related_product_id, relation_type, source, position, resolved_at)
+		VALUES ($1::uuid, $2::uuid, 'accessory', 'admin', 0, NOW())
+	`, host, id); err != nil {
+		t.Fatal(err)
+	}
+	config, _ := json.Marshal(productListConfig{ProductIDs: []string{id}})
+	var blockID string
+	if err := env.db.Pool.QueryRow(ctx, `
+		INSERT INTO homepage_blocks (type, title, config, position) VALUES ('product_list', 'Karty', $1::jsonb, -1) RETURNING id::text
+	`, string(config)).Scan(&blockID); err != nil {
+		t.Fatal(err)
+	}
+	invalidateBrands()
+	invalidateHomepage()
+	t.Cleanup(func() {
+		env.db.Pool.Exec(ctx, "DELETE FROM homepage_blocks WHERE id = $1::uuid", blockID)
+		env.db.Pool.Exec(ctx, "DELETE FROM products WHERE id = ANY($1::uuid[])", []string{id, host})
+		env.h.es.DeleteProduct(id)
+		invalidateBrands()
+		invalidateHomepage()
+	})
+
+	var hostSlug string
+	env.db.Pool.QueryRow(ctx, "SELECT slug FROM products WHERE id = $1::uuid", host).Scan(&hostSlug)
+	p := models.Product{
+		ID: id, Title: "Kávovar na karte", Brand: brand, CategoryID: categoryID, CategoryName: "Kávovary", CategorySlug: categorySlug,
+		PriceMin: money.New(299.9), PriceMax: money.New(299.9), StockStatus: "preorder", IsActive: true, IsFeatured: true,
+		ShortDescription: "Automatický kávovar", Excerpt: "Automatický kávovar", Rating: 4.5, ReleaseDate: &release,
+	}
+	env.db.Pool.QueryRow(ctx, "SELECT slug FROM products WHERE id = $1::uuid", id).Scan(&p.Slug)
+	if err := env.h.es.IndexProduct(p.ToESDocument()); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	validID := RequireUUID("id")
+	app.Get("/search", env.h.Search)
+	app.Get("/products", env.h.GetProducts)
+	app.Get("/products/featured", env.h.GetFeaturedProducts)
+	app.Get("/products/upcoming", env.h.GetUpcomingProducts)
+	app.Get("/products/:slug/full", env.h.GetProductView)
+	app.Get("/products/:id/accessories", validID, env.h.GetProductAccessories)
+	app.Get("/products/:id/variants", validID, env.h.GetProductVariants)
+	app.Get("/categories/:slug/products", env.h.GetProductsByCategory)
+	app.Get("/brands/:slug/products", env.h.GetBrandProducts)
+	app.Get("/homepage", env.h.GetHomepage)
+
+	endpoints := []struct {
+		path   string
+		extras []string
+	}{
+		{"/products/featured?limit=1000", nil},
+		{"/products?brand=" + brand, nil},
+		{"/products/upcoming?limit=100", nil},
+		{"/categories/" + categorySlug + "/products", nil},
+		{"/brands/" + makeSlug(brand) + "/products", nil},
+		{"/homepage", nil},
+		{"/search?q=kavovar&limit=100", nil},
+		{"/products/" + hostSlug + "/full", []string{"relation_type"}},
+		{"/products/" + host + "/accessories", []string{"relation_type"}},
+		{"/products/" + id + "/variants", []string{"in_stock", "options", "sku"}},
+	}
+	want := []string{"brand", "category_name", "category_slug", "discount_percent", "excerpt", "id", "image_url", "labels",
+		"price_max", "price_min", "rating", "release_date", "short_description", "slug", "stock_status", "title"}
+	var reference map[string]interface{}
+	for _, e := range endpoints {
+		status, resp := callJSON(t, app, "GET", e.path, nil)
+		if status != 200 {
+			t.Errorf("GET %s: %d %s", e.path, status, resp.Error)
+			continue
+		}
+		var data interface{}
+		json.Unmarshal(resp.Data, &data)
+		var cards []map[string]interface{}
+		cardsWithID(data, id, &cards)
+		if len(cards) == 0 {
+			t.Errorf("GET %s: no card of the product", e.path)
+			continue
+		}
+		for _, card := range cards {
+			var got []string
+			for key := range card {
+				if !containsString(e.extras, key) {
+					got = append(got, key)
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("GET %s: card fields %v, want %v", e.path, got, want)
+			}
+			if reference == nil {
+				reference = card
+				continue
+			}
+			for _, key := range want {
+				if key != "labels" && !reflect.DeepEqual(card[key], reference[key]) {
+					t.Errorf("GET %s: %s = %v, want %v as on %s", e.path, key, card[key], reference[key], endpoints[0].path)
+				}
+			}
+		}
+	}
+}
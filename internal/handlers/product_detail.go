@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== PRODUCT DETAIL ==========
+
+// productDetail builds the detail payload of p. Every collection hanging off the
+// product goes out in one pgx batch, so a new section costs a queued query rather
+// than another round trip; keep it that way when adding offers or reviews.
+func (h *Handlers) productDetail(ctx context.Context, p models.Product, priceMode string) (models.ProductDetail, error) {
+	batch := &pgx.Batch{}
+	batch.Queue(`SELECT url FROM product_images WHERE product_id = $1::uuid ORDER BY position`, p.ID)
+	batch.Queue(`SELECT name, value FROM product_attributes WHERE product_id = $1::uuid ORDER BY position, name`, p.ID)
+	batch.Queue(`SELECT type, url, COALESCE(title,''), COALESCE(alt,'') FROM product_media WHERE product_id = $1::uuid ORDER BY type, position, created_at`, p.ID)
+	batch.Queue(`SELECT COUNT(*) FROM product_questions WHERE product_id = $1::uuid AND status = 'approved'`, p.ID)
+	batch.Queue(activeLabelsQuery, []string{p.ID})
+
+	br := h.db.Pool.SendBatch(ctx, batch)
+	defer br.Close()
+
+	rows, err := br.Query()
+	if err != nil {
+		return models.ProductDetail{}, err
+	}
+	for rows.Next() {
+		var imgURL string
+		rows.Scan(&imgURL)
+		p.Images = append(p.Images, imgURL)
+	}
+	rows.Close()
+
+	if rows, err = br.Query(); err != nil {
+		return models.ProductDetail{}, err
+	}
+	for rows.Next() {
+		var name, value string
+		rows.Scan(&name, &value)
+		p.Attributes = append(p.Attributes, models.Attribute{Name: name, Value: value})
+	}
+	rows.Close()
+
+	if rows, err = br.Query(); err != nil {
+		return models.ProductDetail{}, err
+	}
+	media := groupProductMedia(rows)
+
+	var questionCount int
+	if err := br.QueryRow().Scan(&questionCount); err != nil {
+		return models.ProductDetail{}, err
+	}
+
+	if rows, err = br.Query(); err != nil {
+		return models.ProductDetail{}, err
+	}
+	p.Labels = []models.Label{}
+	for rows.Next() {
+		var id string
+		var l models.Label
+		rows.Scan(&id, &l.Slug, &l.Name, &l.Color, &l.Priority)
+		p.Labels = append(p.Labels, l)
+	}
+	rows.Close()
+
+	detail := p.ToDetail(priceMode)
+	detail.Media = media
+	detail.QuestionCount = questionCount
+	return detail, nil
+}
+
+// groupProductMedia keys type, url, title, alt rows by media type and closes rows
+func groupProductMedia(rows pgx.Rows) fiber.Map {
+	defer rows.Close()
+	grouped := fiber.Map{}
+	for _, t := range mediaTypes {
+		grouped[t] = []fiber.Map{}
+	}
+	for rows.Next() {
+		var mediaType, mediaURL, title, alt string
+		rows.Scan(&mediaType, &mediaURL, &title, &alt)
+		if list, ok := grouped[mediaType].([]fiber.Map); ok {
+			item := fiber.Map{"url": mediaURL, "title": title}
+			if mediaType == "image" {
+				item["alt"] = alt
+			}
+			grouped[mediaType] = append(list, item)
+		}
+	}
+	return grouped
+}
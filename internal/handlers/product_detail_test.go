@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"megabuy-go/internal/database"
+	"megabuy-go/internal/models"
+)
+
+// roundTripCounter counts the statements a pool sends on their own and the batches
+type roundTripCounter struct {
+	queries, batches atomic.Int32
+}
+
+func (c *roundTripCounter) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	c.queries.Add(1)
+	return ctx
+}
+
+func (c *roundTripCounter) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+func (c *roundTripCounter) TraceBatchStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchStartData) context.Context {
+	c.batches.Add(1)
+	return ctx
+}
+
+func (c *roundTripCounter) TraceBatchQuery(context.Context, *pgx.Conn, pgx.TraceBatchQueryData) {}
+
+func (c *roundTripCounter) TraceBatchEnd(context.Context, *pgx.Conn, pgx.TraceBatchEndData) {}
+
+func TestProductDetailLoadsInOneBatch(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	id := env.createTestProduct(t, "Kávovar DeLonghi", 299.9)
+	for _, sql := range []string{
+		`INSERT INTO product_images (product_id, url, position) VALUES ($1, 'https://cdn.example.com/2.jpg', 2), ($1, 'https://cdn.example.com/1.jpg', 1)`,
+		`INSERT INTO product_attributes (product_id, name, value, position) VALUES ($1, 'Tlak', '15 bar', 1), ($1, 'Farba', 'čierna', 0)`,
+		`INSERT INTO product_media (product_id, type, url, title, alt, position) VALUES
+			($1, 'image', 'https://cdn.example.com/detail.jpg', 'Detail', 'Kávovar spredu', 0),
+			($1, 'video', 'https://video.example.com/ecam', 'Recenzia', NULL, 0),
+			($1, 'document', 'https://cdn.example.com/navod.pdf', 'Návod', NULL, 0)`,
+		`INSERT INTO product_questions (product_id, body, status) VALUES ($1, 'Má mlynček?', 'approved'), ($1, 'Spam', 'pending'), ($1, 'Koľko váži?', 'approved')`,
+	} {
+		if _, err := env.db.Pool.Exec(ctx, sql, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dbURL, err := withSearchPath(env.base, env.schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := &roundTripCounter{}
+	config.ConnConfig.Tracer = counter
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	h := &Handlers{db: &database.DB{Pool: pool}}
+
+	detail, err := h.productDetail(ctx, models.Product{ID: id, Title: "Kávovar DeLonghi"}, "gross")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q, b := counter.queries.Load(), counter.batches.Load(); q != 0 || b != 1 {
+		t.Errorf("%d queries and %d batches, want the one batch", q, b)
+	}
+
+	if len(detail.Images) != 2 || detail.Images[0] != "https://cdn.example.com/1.jpg" {
+		t.Errorf("images %v", detail.Images)
+	}
+	if len(detail.Attributes) != 2 || detail.Attributes[0].Name != "Farba" || detail.Attributes[1].Value != "15 bar" {
+		t.Errorf("attributes %v", detail.Attributes)
+	}
+	if detail.QuestionCount != 2 {
+		t.Errorf("%d questions, want the 2 approved", detail.QuestionCount)
+	}
+	if detail.Labels == nil {
+		t.Error("labels are null, want a list")
+	}
+	images, _ := detail.Media["image"].([]fiber.Map)
+	videos, _ := detail.Media["video"].([]fiber.Map)
+	documents, _ := detail.Media["document"].([]fiber.Map)
+	if len(images) != 1 || images[0]["alt"] != "Kávovar spredu" || len(videos) != 1 || len(documents) != 1 {
+		t.Errorf("media %v", detail.Media)
+	}
+	if _, ok := videos[0]["alt"]; ok {
+		t.Error("only images carry alt text")
+	}
+
+	// a product with nothing attached still lists every section
+	bare := env.createTestProduct(t, "Holý produkt", 10)
+	detail, err = h.productDetail(ctx, models.Product{ID: bare}, "gross")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mediaType := range mediaTypes {
+		if list, ok := detail.Media[mediaType].([]fiber.Map); !ok || len(list) != 0 {
+			t.Errorf("media %s of a bare product: %v", mediaType, detail.Media[mediaType])
+		}
+	}
+	if detail.QuestionCount != 0 || len(detail.Attributes) != 0 {
+		t.Errorf("bare product detail %+v", detail)
+	}
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/models"
+	"megabuy-go/internal/money"
+)
+
+// ========== PRODUCT PAGE VIEW ==========
+
+const (
+	// productViewSectionTimeout bounds each optional section; a section that runs
+	// out of time is returned as null and listed in "degraded"
+	productViewSectionTimeout = 800 * time.Millisecond
+	maxViewRelated            = 8
+)
+
+// productView is everything the product page renders, in one response. Every
+// section except product may be null when it could not be loaded in time.
+type productView struct {
+	Product        *models.ProductDetail `json:"product"`
+	Offers         []fiber.Map           `json:"offers"`
+	Breadcrumb     []breadcrumbItem      `json:"breadcrumb"`
+	Related        []relatedProduct      `json:"related"`
+	Reviews        *reviewSummary        `json:"reviews"`
+	Labels         []models.Label        `json:"labels"`
+	StructuredData fiber.Map             `json:"structured_data"`
+}
+
+type breadcrumbItem struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type reviewSummary struct {
+	Rating      float64 `json:"rating"`
+	ReviewCount int     `json:"review_count"`
+}
+
+// GetProductView returns the product detail with its offers (cheapest total first),
+// category breadcrumb, related products, review summary, labels and schema.org data.
+// Sections load concurrently; ?fields= picks sections and skips loading the others.
+func (h *Handlers) GetProductView(c *fiber.Ctx) error {
+	ctx := context.Background()
+	priceMode, ok := priceModeParam(c)
+	if !ok {
+		return invalidPriceMode(c)
+	}
+	fields, msg := fieldsParam(c, productView{})
+	if msg != "" {
+		return invalidFields(c, msg)
+	}
+	wanted := func(section string) bool { return fields == nil || containsString(fields, section) }
+
+	p, redirect, err := h.productBySlug(ctx, c.Params("slug"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Product not found"})
+	}
+	if !p.IsActive {
+		return h.productGone(c, ctx, p, priceMode)
+	}
+	detail, err := h.productDetail(ctx, p, priceMode)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	addDetailDisplay(c, &detail)
+	go h.db.Pool.Exec(context.Background(), "UPDATE products SET view_count = COALESCE(view_count,0) + 1 WHERE id = $1::uuid", p.ID)
+
+	view := productView{Product: &detail, Labels: detail.Labels}
+	var mu sync.Mutex
+	var degraded []string
+	var wg sync.WaitGroup
+	section := func(name string, load func(ctx context.Context) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sctx, cancel := context.WithTimeout(ctx, productViewSectionTimeout)
+			defer cancel()
+			if err := load(sctx); err != nil {
+				mu.Lock()
+				degraded = append(degraded, name)
+				mu.Unlock()
+			}
+		}()
+	}
+	// offers, breadcrumb and reviews also feed the structured data
+	needStructured := wanted("structured_data")
+	if wanted("offers") || needStructured {
+		section("offers", func(ctx context.Context) error {
+			offers, err := h.productOffers(ctx, p.ID)
+			if err == nil {
+				view.Offers = offers
+			}
+			return err
+		})
+	}
+	if wanted("breadcrumb") || needStructured {
+		section("breadcrumb", func(ctx context.Context) error {
+			crumbs, err := h.categoryBreadcrumb(ctx, detail.CategoryID)
+			if err == nil {
+				view.Breadcrumb = crumbs
+			}
+			return err
+		})
+	}
+	if wanted("reviews") || needStructured {
+		section("reviews", func(ctx context.Context) error {
+			var r reviewSummary
+			err := h.db.Pool.QueryRow(ctx, "SELECT COALESCE(rating,0)::float8, COALESCE(review_count,0) FROM products WHERE id = $1::uuid", p.ID).Scan(&r.Rating, &r.ReviewCount)
+			if err == nil {
+				view.Reviews = &r
+			}
+			return err
+		})
+	}
+	if wanted("related") {
+		section("related", func(ctx context.Context) error {
+			related, err := h.viewRelatedProducts(ctx, p.ID, detail.CategoryID, priceMode)
+			if err == nil {
+				view.Related = related
+			}
+			return err
+		})
+	}
+	wg.Wait()
+
+	if needStructured {
+		view.StructuredData = productStructuredData(detail, view.Offers, view.Reviews)
+	}
+	addOfferDisplay(c, view.Offers)
+	addRelatedDisplay(c, view.Related)
+	resp := fiber.Map{"success": true, "data": project(view, fields)}
+	if degraded != nil {
+		resp["degraded"] = degraded
+	}
+	if redirect != nil {
+		resp["redirect"] = redirect
+	}
+	return c.JSON(resp)
+}
+
+// categoryBreadcrumb returns the active ancestors of a category, root first, ending
+// with the category itself
+func (h *Handlers) categoryBreadcrumb(ctx context.Context, categoryID string) ([]breadcrumbItem, error) {
+	crumbs := []breadcrumbItem{}
+	if categoryID == "" {
+		return crumbs, nil
+	}
+	paths, err := h.categoryPaths(ctx, []string{categoryID})
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range paths[categoryID] {
+		if n.IsActive {
+			crumbs = append(crumbs, breadcrumbItem{ID: n.ID, Name: n.Name, Slug: n.Slug})
+		}
+	}
+	return crumbs, nil
+}
+
+// viewRelatedProducts returns the product's relations, topped up with popular
+// products of the same category (relation_type "similar") up to maxViewRelated
+func (h *Handlers) viewRelatedProducts(ctx context.Context, productID, categoryID, priceMode string) ([]relatedProduct, error) {
+	cardColumns := productCardColumns(priceMode)
+
+	rows, err := h.db.ReadPool.Query(ctx, `
+		SELECT * FROM (
+			SELECT DISTINCT ON (p.id) `+cardColumns+`, r.relation_type, r.position
+			FROM product_relations r
+			JOIN products p ON p.id = r.related_product_id AND p.is_active = true
+			LEFT JOIN categories c ON p.category_id = c.id
+			WHERE r.product_id = $1::uuid
+			ORDER BY p.id, r.position
+		) related ORDER BY position LIMIT $2
+	`, productID, maxViewRelated)
+	if err != nil {
+		return nil, err
+	}
+	related := scanRelatedProducts(rows)
+
+	if len(related) < maxViewRelated && categoryID != "" {
+		exclude := []string{productID}
+		for _, r := range related {
+			exclude = append(exclude, r.ID)
+		}
+		rows, err := h.db.ReadPool.Query(ctx, `
+			SELECT `+cardColumns+`, 'similar', 0
+			FROM products p LEFT JOIN categories c ON p.category_id = c.id
+			WHERE p.category_id = $1::uuid AND p.is_active = true AND p.id <> ALL($2::uuid[])
+			ORDER BY `+popularityExpr+` DESC NULLS LAST, p.created_at DESC
+			LIMIT $3
+		`, categoryID, exclude, maxViewRelated-len(related))
+		if err != nil {
+			return nil, err
+		}
+		related = append(related, scanRelatedProducts(rows)...)
+	}
+	h.attachRelatedLabels(ctx, related)
+	return related, nil
+}
+
+// schemaAvailability maps stock statuses to schema.org item availability
+var schemaAvailability = map[string]string{
+	"instock":    "https://schema.org/InStock",
+	"outofstock": "https://schema.org/OutOfStock",
+	"preorder":   "https://schema.org/PreOrder",
+}
+
+// productStructuredData builds the schema.org Product object for the page's JSON-LD
+func productStructuredData(p models.ProductDetail, offers []fiber.Map, reviews *reviewSummary) fiber.Map {
+	data := fiber.Map{"@context": "https://schema.org", "@type": "Product", "name": p.Title}
+	if p.Excerpt != "" {
+		data["description"] = p.Excerpt
+	}
+	images := p.Images
+	if len(images) == 0 && p.ImageURL != "" {
+		images = []string{p.ImageURL}
+	}
+	if len(images) > 0 {
+		data["image"] = images
+	}
+	if p.SKU != "" {
+		data["sku"] = p.SKU
+	}
+	if p.MPN != "" {
+		data["mpn"] = p.MPN
+	}
+	if p.EAN != "" {
+		data["gtin"] = p.EAN
+	}
+	if p.Brand != "" {
+		data["brand"] = fiber.Map{"@type": "Brand", "name": p.Brand}
+	}
+
+	availability := schemaAvailability[p.StockStatus]
+	if availability == "" {
+		availability = schemaAvailability["instock"]
+	}
+	offer := fiber.Map{"@type": "AggregateOffer", "priceCurrency": p.Currency, "lowPrice": p.PriceMin, "highPrice": p.PriceMax, "availability": availability}
+	if len(offers) > 0 {
+		low, high := offers[0]["price"].(money.Price), offers[0]["price"].(money.Price)
+		for _, o := range offers[1:] {
+			price := o["price"].(money.Price)
+			if price < low {
+				low = price
+			}
+			if price > high {
+				high = price
+			}
+		}
+		offer["lowPrice"], offer["highPrice"], offer["offerCount"] = low, high, len(offers)
+	}
+	data["offers"] = offer
+
+	if reviews != nil && reviews.ReviewCount > 0 {
+		data["aggregateRating"] = fiber.Map{"@type": "AggregateRating", "ratingValue": reviews.Rating, "reviewCount": reviews.ReviewCount}
+	}
+	return data
+}
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+
+	"megabuy-go/internal/money"
+)
+
+// ========== PROMO PRICES ==========
+
+// A product holds one promo at a time. Scheduling another while one has not ended is
+// rejected with 409: the windows either overlap or the earlier promo would be lost.
+
+const maxPromoConflictSample = 20
+
+type promoInput struct {
+	// PromoPrice is gross; DiscountPercent derives it from the regular price_min instead
+	PromoPrice      money.Price `json:"promo_price"`
+	DiscountPercent float64     `json:"discount_percent"`
+	StartsAt        string      `json:"starts_at"`
+	EndsAt          string      `json:"ends_at"`
+}
+
+// window parses the promo window; a missing start means now
+func (in promoInput) window() (time.Time, time.Time, string) {
+	startsAt := time.Now()
+	if in.StartsAt != "" {
+		t, err := time.Parse(time.RFC3339, in.StartsAt)
+		if err != nil {
+			return startsAt, startsAt, "starts_at must be an RFC 3339 timestamp"
+		}
+		startsAt = t
+	}
+	endsAt, err := time.Parse(time.RFC3339, in.EndsAt)
+	if err != nil {
+		return startsAt, startsAt, "ends_at must be an RFC 3339 timestamp"
+	}
+	if !endsAt.After(startsAt) {
+		return startsAt, endsAt, "ends_at must be after starts_at"
+	}
+	if !endsAt.After(time.Now()) {
+		return startsAt, endsAt, "ends_at must be in the future"
+	}
+	return startsAt, endsAt, ""
+}
+
+// promoConflict describes the unfinished promo blocking a new one
+func promoConflict(startsAt, endsAt, existingStart, existingEnd time.Time) fiber.Map {
+	return fiber.Map{
+		"starts_at": existingStart,
+		"ends_at":   existingEnd,
+		"overlaps":  existingStart.Before(endsAt) && startsAt.Before(existingEnd),
+	}
+}
+
+// AdminSetProductPromo schedules a promo price for one product
+func (h *Handlers) AdminSetProductPromo(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	var input promoInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if (input.PromoPrice > 0) == (input.DiscountPercent > 0) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Set exactly one of promo_price or discount_percent"})
+	}
+	if input.DiscountPercent >= 100 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "discount_percent must be below 100"})
+	}
+	startsAt, endsAt, msg := input.window()
+	if msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+
+	ctx := context.Background()
+	var priceMin, vatRate float64
+	var existingStart, existingEnd *time.Time
+	err := h.db.Pool.QueryRow(ctx, `
+		SELECT p.price_min, COALESCE(p.vat_rate,20),
+		       CASE WHEN p.promo_ends_at > NOW() THEN p.promo_starts_at END, CASE WHEN p.promo_ends_at > NOW() THEN p.promo_ends_at END
+		FROM products p WHERE p.id = $1::uuid
+	`, productID).Scan(&priceMin, &vatRate, &existingStart, &existingEnd)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Product not found"})
+	}
+	promoPrice := input.PromoPrice.Float()
+	if input.DiscountPercent > 0 {
+		promoPrice = roundCents(priceMin * (1 - input.DiscountPercent/100))
+	}
+	if promoPrice <= 0 || promoPrice >= priceMin {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": fmt.Sprintf("promo price must be above 0 and below the regular price %.2f", priceMin)})
+	}
+	if existingEnd != nil {
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "Product already has a promo that has not ended; remove it first",
+			"conflict": promoConflict(startsAt, endsAt, *existingStart, *existingEnd)})
+	}
+	_, promoNet := splitPrice(promoPrice, vatRate, true)
+
+	tag, err := h.db.Pool.Exec(ctx, `
+		UPDATE products SET promo_price = $2, promo_price_net = $3, promo_starts_at = $4, promo_ends_at = $5, updated_at = NOW(),
+		       version = COALESCE(version,1) + 1
+		WHERE id = $1::uuid AND (promo_ends_at IS NULL OR promo_ends_at <= NOW())
+	`, productID, promoPrice, promoNet, startsAt, endsAt)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		// a concurrent request scheduled a promo in between
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "Product already has a promo that has not ended; remove it first"})
+	}
+	h.promoChanged(productID)
+	h.audit(ctx, c, "product.promo_set", "product", productID, fiber.Map{"promo_price": promoPrice, "starts_at": startsAt, "ends_at": endsAt})
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"promo_price": promoPrice, "promo_price_net": promoNet, "starts_at": startsAt, "ends_at": endsAt, "regular_price": priceMin,
+	}})
+}
+
+// AdminDeleteProductPromo cancels the product's promo, running or scheduled
+func (h *Handlers) AdminDeleteProductPromo(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	ctx := context.Background()
+	tag, err := h.db.Pool.Exec(ctx, `
+		UPDATE products SET promo_price = NULL, promo_price_net = NULL, promo_starts_at = NULL, promo_ends_at = NULL, updated_at = NOW(),
+		       version = COALESCE(version,1) + 1
+		WHERE id = $1::uuid AND promo_price IS NOT NULL
+	`, productID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Product has no promo"})
+	}
+	h.promoChanged(productID)
+	h.audit(ctx, c, "product.promo_removed", "product", productID, nil)
+	return c.JSON(fiber.Map{"success": true, "message": "Promo removed"})
+}
+
+// AdminBulkPromo schedules a percentage promo for all active products of a category
+// subtree and/or brand. Nothing is written when any of them has an unfinished promo.
+func (h *Handlers) AdminBulkPromo(c *fiber.Ctx) error {
+	var input struct {
+		promoInput
+		CategoryID string `json:"category_id"`
+		Brand      string `json:"brand"`
+		DryRun     bool   `json:"dry_run"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	input.Brand = strings.TrimSpace(input.Brand)
+	if input.CategoryID == "" && input.Brand == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Set category_id and/or brand"})
+	}
+	if input.CategoryID != "" && !isUUID(input.CategoryID) {
+		return invalidUUIDField(c, "category_id")
+	}
+	if input.PromoPrice > 0 || input.DiscountPercent <= 0 || input.DiscountPercent >= 100 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Bulk promos take discount_percent between 0 and 100"})
+	}
+	startsAt, endsAt, msg := input.window()
+	if msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+
+	conds := []string{"p.is_active = true", "p.price_min > 0"}
+	args := []interface{}{}
+	if input.CategoryID != "" {
+		args = append(args, input.CategoryID)
+		conds = append(conds, fmt.Sprintf(`p.category_id IN (WITH RECURSIVE subcats AS (
+			SELECT id FROM categories WHERE id = $%d::uuid
+			UNION ALL
+			SELECT c.id FROM categories c JOIN subcats s ON c.parent_id = s.id
+		) SELECT id FROM subcats)`, len(args)))
+	}
+	if input.Brand != "" {
+		args = append(args, input.Brand)
+		conds = append(conds, fmt.Sprintf("LOWER(p.brand) = LOWER($%d)", len(args)))
+	}
+	where := strings.Join(conds, " AND ")
+
+	ctx := context.Background()
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT p.id::text, p.title, p.promo_starts_at, p.promo_ends_at FROM products p
+		WHERE `+where+` AND p.promo_ends_at > NOW() ORDER BY p.title
+	`, args...)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	conflicts := []fiber.Map{}
+	conflictCount := 0
+	for rows.Next() {
+		var id, title string
+		var existingStart, existingEnd time.Time
+		rows.Scan(&id, &title, &existingStart, &existingEnd)
+		conflictCount++
+		if len(conflicts) < maxPromoConflictSample {
+			conflict := promoConflict(startsAt, endsAt, existingStart, existingEnd)
+			conflict["product_id"], conflict["title"] = id, title
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	rows.Close()
+	if conflictCount > 0 {
+		return c.Status(409).JSON(fiber.Map{"success": false,
+			"error":     fmt.Sprintf("%d products already have a promo that has not ended; remove those first", conflictCount),
+			"conflicts": conflicts, "conflict_count": conflictCount})
+	}
+
+	if input.DryRun {
+		var count int64
+		h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM products p WHERE "+where, args...).Scan(&count)
+		return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"dry_run": true, "count": count}})
+	}
+
+	// the promo_ends_at guard skips products that got a promo after the check above
+	args = append(args, 1-input.DiscountPercent/100, startsAt, endsAt)
+	n := len(args)
+	ids, err := collectIDs(h.db.Pool.Query(ctx, fmt.Sprintf(`
+		UPDATE products p SET promo_price = ROUND(p.price_min * $%[1]d, 2),
+		       promo_price_net = ROUND(p.price_min * $%[1]d / (1 + COALESCE(p.vat_rate,20) / 100), 2),
+		       promo_starts_at = $%[2]d, promo_ends_at = $%[3]d, updated_at = NOW(), version = COALESCE(p.version,1) + 1
+		WHERE %[4]s AND (p.promo_ends_at IS NULL OR p.promo_ends_at <= NOW()) RETURNING p.id::text
+	`, n-2, n-1, n, where), args...))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	h.promoChanged(ids...)
+	h.audit(ctx, c, "product.promo_bulk", "category", input.CategoryID, fiber.Map{
+		"brand": input.Brand, "discount_percent": input.DiscountPercent, "starts_at": startsAt, "ends_at": endsAt, "products": len(ids),
+	})
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"scheduled": len(ids)}})
+}
+
+// collectIDs reads a single text column and closes rows
+func collectIDs(rows pgx.Rows, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// promoChanged reindexes products whose promo changed; search and cached pages
+// otherwise keep the old effective price
+func (h *Handlers) promoChanged(ids ...string) {
+	h.queueESSync(ids...)
+	invalidateHomepage()
+}
+
+// RunPromoWorker reindexes products whose promo started or ended since the last tick
+// and checks price alerts when a promo starts
+func (h *Handlers) RunPromoWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := time.Now()
+	for now := range ticker.C {
+		h.flipPromos(context.Background(), last, now)
+		last = now
+	}
+}
+
+func (h *Handlers) flipPromos(ctx context.Context, since, until time.Time) {
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT id::text, promo_starts_at > $1 AND promo_starts_at <= $2 FROM products
+		WHERE promo_price IS NOT NULL
+		  AND ((promo_starts_at > $1 AND promo_starts_at <= $2) OR (promo_ends_at > $1 AND promo_ends_at <= $2))
+	`, since, until)
+	if err != nil {
+		log.Printf("Promo schedule check failed: %v", err)
+		return
+	}
+	var ids []string
+	started := false
+	for rows.Next() {
+		var id string
+		var isStart bool
+		rows.Scan(&id, &isStart)
+		ids = append(ids, id)
+		started = started || isStart
+	}
+	rows.Close()
+	if len(ids) == 0 {
+		return
+	}
+	log.Printf("Promo prices changed for %d products, reindexing", len(ids))
+	h.promoChanged(ids...)
+	if started {
+		h.checkPriceAlerts(ctx)
+	}
+}
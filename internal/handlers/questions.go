@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== PRODUCT Q&A ==========
+
+const (
+	maxQuestionLength = 2000
+	maxAnswerLength   = 5000
+	maxAuthorLength   = 100
+
+	// questionsPerHour limits how many questions one client IP may post
+	questionsPerHour = 5
+)
+
+var questionStatuses = []string{"pending", "approved", "rejected"}
+
+// questionLimiter counts question posts per client IP
+var questionLimiter = newHourlyLimiter(questionsPerHour)
+
+// hourlyLimiter allows each key at most limit events in any hour. Keys without an
+// event in the last hour are swept, so addresses seen once do not stay in memory.
+type hourlyLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	events    map[string][]time.Time
+	lastSweep time.Time
+}
+
+func newHourlyLimiter(limit int) *hourlyLimiter {
+	return &hourlyLimiter{limit: limit, events: make(map[string][]time.Time), lastSweep: time.Now()}
+}
+
+// allow records an event for key and reports whether it is within the hourly limit
+func (l *hourlyLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	cutoff := now.Add(-time.Hour)
+	if now.Sub(l.lastSweep) > limiterSweepInterval {
+		l.sweep(cutoff)
+		l.lastSweep = now
+	}
+	recent := l.events[key][:0]
+	for _, t := range l.events[key] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= l.limit {
+		l.events[key] = recent
+		return false
+	}
+	l.events[key] = append(recent, now)
+	return true
+}
+
+// limiterSweepInterval is how often allow drops keys whose window has emptied
+const limiterSweepInterval = 10 * time.Minute
+
+// sweep deletes the keys whose newest event is not after cutoff; callers hold l.mu
+func (l *hourlyLimiter) sweep(cutoff time.Time) {
+	for key, times := range l.events {
+		if len(times) == 0 || !times[len(times)-1].After(cutoff) {
+			delete(l.events, key)
+		}
+	}
+}
+
+// adminEmail receives moderation alerts; empty disables them
+func adminEmail() string {
+	return os.Getenv("ADMIN_EMAIL")
+}
+
+// questionAnswers loads the answers of the given questions keyed by question ID
+func (h *Handlers) questionAnswers(ctx context.Context, questionIDs []string) map[string][]fiber.Map {
+	answers := map[string][]fiber.Map{}
+	if len(questionIDs) == 0 {
+		return answers
+	}
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT id, question_id::text, COALESCE(author_name,''), body, created_at
+		FROM product_answers WHERE question_id = ANY($1::uuid[]) ORDER BY created_at
+	`, questionIDs)
+	if err != nil {
+		return answers
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, questionID, author, body string
+		var createdAt time.Time
+		rows.Scan(&id, &questionID, &author, &body, &createdAt)
+		answers[questionID] = append(answers[questionID], fiber.Map{"id": id, "author_name": author, "body": body, "created_at": createdAt})
+	}
+	return answers
+}
+
+// GetProductQuestions returns approved questions of a product with their answers, newest first
+func (h *Handlers) GetProductQuestions(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	page, limit, offset := pageParams(c, 10)
+	ctx := context.Background()
+
+	var total int64
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM product_questions WHERE product_id = $1::uuid AND status = 'approved'", productID).Scan(&total)
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT id, COALESCE(author_name,''), body, created_at FROM product_questions
+		WHERE product_id = $1::uuid AND status = 'approved'
+		ORDER BY created_at DESC LIMIT $2 OFFSET $3
+	`, productID, limit, offset)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	var questions []fiber.Map
+	var ids []string
+	for rows.Next() {
+		var id, author, body string
+		var createdAt time.Time
+		rows.Scan(&id, &author, &body, &createdAt)
+		questions = append(questions, fiber.Map{"id": id, "author_name": author, "body": body, "created_at": createdAt})
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	answers := h.questionAnswers(ctx, ids)
+	for _, q := range questions {
+		list := answers[q["id"].(string)]
+		if list == nil {
+			list = []fiber.Map{}
+		}
+		q["answers"] = list
+	}
+	if questions == nil {
+		questions = []fiber.Map{}
+	}
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{"items": questions}, page, limit, total)})
+}
+
+// CreateProductQuestion stores a question for moderation and alerts the admin
+func (h *Handlers) CreateProductQuestion(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	var input struct {
+		AuthorName string `json:"author_name"`
+		Email      string `json:"email"`
+		Body       string `json:"body"`
+		Lang       string `json:"lang"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	input.AuthorName = strings.TrimSpace(input.AuthorName)
+	input.Body = strings.TrimSpace(input.Body)
+	input.Email = strings.TrimSpace(strings.ToLower(input.Email))
+	if input.Body == "" || len(input.Body) > maxQuestionLength {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": fmt.Sprintf("body must be 1 to %d characters", maxQuestionLength)})
+	}
+	if len(input.AuthorName) > maxAuthorLength {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "author_name is too long"})
+	}
+	if input.Email != "" {
+		if addr, err := mail.ParseAddress(input.Email); err != nil || addr.Address != input.Email {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid email"})
+		}
+	}
+	if !questionLimiter.allow(c.IP()) {
+		c.Set(fiber.HeaderRetryAfter, "3600")
+		return c.Status(429).JSON(fiber.Map{"success": false, "error": "Too many questions, try again later"})
+	}
+
+	ctx := context.Background()
+	var title string
+	if err := h.db.Pool.QueryRow(ctx, "SELECT title FROM products WHERE id = $1::uuid AND is_active = true", productID).Scan(&title); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Product not found"})
+	}
+
+	var questionID string
+	err := h.db.Pool.QueryRow(ctx, `
+		INSERT INTO product_questions (product_id, author_name, email, body, status, client_ip, lang, created_at)
+		VALUES ($1::uuid, NULLIF($2,''), NULLIF($3,''), $4, 'pending', $5, $6, NOW()) RETURNING id
+	`, productID, input.AuthorName, input.Email, input.Body, c.IP(), requestLang(c, input.Lang)).Scan(&questionID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	if to := adminEmail(); to != "" {
+		data := map[string]string{"Title": title, "QuestionID": questionID, "Body": input.Body}
+		if err := h.queueNotification(ctx, "product_question", adminLang(), to, data); err != nil {
+			log.Printf("Question %s admin alert not queued: %v", questionID, err)
+		}
+	}
+
+	return c.Status(201).JSON(fiber.Map{"success": true, "message": "Question submitted for approval", "data": fiber.Map{"id": questionID}})
+}
+
+// AdminListQuestions lists questions for moderation, filtered by ?status= (default pending)
+func (h *Handlers) AdminListQuestions(c *fiber.Ctx) error {
+	status := c.Query("status", "pending")
+	if !containsString(questionStatuses, status) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "status must be pending, approved or rejected"})
+	}
+	page, limit, offset := adminPageParams(c, 50)
+	ctx := context.Background()
+
+	var total int64
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM product_questions WHERE status = $1", status).Scan(&total)
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT q.id, q.product_id::text, COALESCE(p.title,''), COALESCE(q.author_name,''), COALESCE(q.email,''), q.body, q.status, q.created_at,
+		       (SELECT COUNT(*) FROM product_answers a WHERE a.question_id = q.id)
+		FROM product_questions q LEFT JOIN products p ON p.id = q.product_id
+		WHERE q.status = $1 ORDER BY q.created_at DESC LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+	questions := []fiber.Map{}
+	for rows.Next() {
+		var id, productID, productTitle, author, email, body, qStatus string
+		var createdAt time.Time
+		var answerCount int
+		rows.Scan(&id, &productID, &productTitle, &author, &email, &body, &qStatus, &createdAt, &answerCount)
+		questions = append(questions, fiber.Map{"id": id, "product_id": productID, "product_title": productTitle, "author_name": author, "email": email, "body": body, "status": qStatus, "created_at": createdAt, "answer_count": answerCount})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{"items": questions}, page, limit, total)})
+}
+
+// AdminUpdateQuestion approves or rejects a question
+func (h *Handlers) AdminUpdateQuestion(c *fiber.Ctx) error {
+	questionID := c.Params("question_id")
+	var input struct {
+		Status string `json:"status"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if !containsString(questionStatuses, input.Status) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "status must be pending, approved or rejected"})
+	}
+	ctx := context.Background()
+	tag, err := h.db.Pool.Exec(ctx, `
+		UPDATE product_questions SET status = $2,
+		       approved_at = CASE WHEN $2 = 'approved' THEN COALESCE(approved_at, NOW()) ELSE NULL END
+		WHERE id = $1::uuid
+	`, questionID, input.Status)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Question not found"})
+	}
+	return c.JSON(fiber.Map{"success": true, "message": "Question updated"})
+}
+
+// AdminAnswerQuestion adds an answer, approves the question and tells the asker when they left an e-mail
+func (h *Handlers) AdminAnswerQuestion(c *fiber.Ctx) error {
+	questionID := c.Params("question_id")
+	var input struct {
+		AuthorName string `json:"author_name"`
+		Body       string `json:"body"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	input.Body = strings.TrimSpace(input.Body)
+	input.AuthorName = strings.TrimSpace(input.AuthorName)
+	if input.Body == "" || len(input.Body) > maxAnswerLength {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": fmt.Sprintf("body must be 1 to %d characters", maxAnswerLength)})
+	}
+	if len(input.AuthorName) > maxAuthorLength {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "author_name is too long"})
+	}
+	if input.AuthorName == "" {
+		input.AuthorName = "MegaBuy"
+	}
+
+	ctx := context.Background()
+	var email, lang, productTitle string
+	err := h.db.Pool.QueryRow(ctx, `
+		UPDATE product_questions q SET status = 'approved', approved_at = COALESCE(q.approved_at, NOW())
+		FROM products p WHERE q.id = $1::uuid AND p.id = q.product_id
+		RETURNING COALESCE(q.email,''), q.lang, p.title
+	`, questionID).Scan(&email, &lang, &productTitle)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Question not found"})
+	}
+
+	var answerID string
+	err = h.db.Pool.QueryRow(ctx, `
+		INSERT INTO product_answers (question_id, author_name, body, created_at) VALUES ($1::uuid, $2, $3, NOW()) RETURNING id
+	`, questionID, input.AuthorName, input.Body).Scan(&answerID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	if email != "" {
+		data := map[string]string{"Title": productTitle, "Answer": input.Body}
+		if err := h.queueNotification(ctx, "product_question_answered", lang, email, data); err != nil {
+			log.Printf("Answer %s notification not queued: %v", answerID, err)
+		}
+	}
+
+	return c.Status(201).JSON(fiber.Map{"success": true, "data": fiber.Map{"id": answerID}})
+}
+
+func (h *Handlers) AdminDeleteQuestion(c *fiber.Ctx) error {
+	questionID := c.Params("question_id")
+	ctx := context.Background()
+	tag, err := h.db.Pool.Exec(ctx, "DELETE FROM product_questions WHERE id = $1::uuid", questionID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Question not found"})
+	}
+	return c.JSON(fiber.Map{"success": true, "message": "Question deleted"})
+}
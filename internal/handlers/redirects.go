@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== SLUG REDIRECTS ==========
+
+const (
+	defaultRedirectsLimit = 1000
+	maxRedirectsLimit     = 5000
+)
+
+// redirectTables maps slug_redirects.entity_type to the table holding the live slug
+var redirectTables = map[string]string{
+	"product":  "products",
+	"category": "categories",
+}
+
+// recordSlugRedirect remembers oldSlug after a rename. The new slug is live again,
+// so a redirect stored for it earlier is dropped.
+func (h *Handlers) recordSlugRedirect(ctx context.Context, entityType, entityID, oldSlug, newSlug string) {
+	if oldSlug == "" || oldSlug == newSlug {
+		return
+	}
+	h.db.Pool.Exec(ctx, `
+		INSERT INTO slug_redirects (entity_type, entity_id, old_slug, created_at)
+		VALUES ($1, $2::uuid, $3, NOW())
+		ON CONFLICT (entity_type, old_slug) DO UPDATE SET entity_id = EXCLUDED.entity_id, created_at = NOW()
+	`, entityType, entityID, oldSlug)
+	h.db.Pool.Exec(ctx, "DELETE FROM slug_redirects WHERE entity_type = $1 AND old_slug = $2", entityType, newSlug)
+}
+
+// resolveSlugRedirect returns the current slug for a historical one, or "" when slug is unknown
+func (h *Handlers) resolveSlugRedirect(ctx context.Context, entityType, slug string) string {
+	table, ok := redirectTables[entityType]
+	if !ok {
+		return ""
+	}
+	var target string
+	h.db.Pool.QueryRow(ctx, `
+		SELECT t.slug FROM slug_redirects r JOIN `+table+` t ON t.id = r.entity_id
+		WHERE r.entity_type = $1 AND r.old_slug = $2
+	`, entityType, slug).Scan(&target)
+	if target == slug {
+		return ""
+	}
+	return target
+}
+
+// slugRedirect is the metadata returned alongside an entity requested by a historical slug
+func slugRedirect(entityType, target string) fiber.Map {
+	return fiber.Map{"status": 301, "type": entityType, "slug": target}
+}
+
+// GetRedirects lists slug renames recorded after ?since= (RFC 3339) so the frontend
+// can keep its redirect map current. Pass next_since back to fetch the following page.
+func (h *Handlers) GetRedirects(c *fiber.Ctx) error {
+	since := time.Time{}
+	if s := c.Query("since"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "since must be an RFC 3339 timestamp"})
+		}
+		since = t
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = defaultRedirectsLimit
+	}
+	if limit > maxRedirectsLimit {
+		limit = maxRedirectsLimit
+	}
+
+	ctx := context.Background()
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT r.entity_type, r.entity_id::text, r.old_slug, COALESCE(p.slug, c.slug), r.created_at
+		FROM slug_redirects r
+		LEFT JOIN products p ON r.entity_type = 'product' AND p.id = r.entity_id
+		LEFT JOIN categories c ON r.entity_type = 'category' AND c.id = r.entity_id
+		WHERE r.created_at > $1 AND COALESCE(p.slug, c.slug) IS NOT NULL
+		ORDER BY r.created_at, r.id
+		LIMIT $2
+	`, since, limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+
+	slugs := []fiber.Map{}
+	ids := []fiber.Map{}
+	seenIDs := map[string]bool{}
+	var last time.Time
+	n := 0
+	for rows.Next() {
+		var entityType, entityID, from, to string
+		var createdAt time.Time
+		if err := rows.Scan(&entityType, &entityID, &from, &to, &createdAt); err != nil {
+			continue
+		}
+		n++
+		last = createdAt
+		if from != to {
+			slugs = append(slugs, fiber.Map{"type": entityType, "from": from, "to": to, "changed_at": createdAt})
+		}
+		if !seenIDs[entityID] {
+			seenIDs[entityID] = true
+			ids = append(ids, fiber.Map{"type": entityType, "id": entityID, "slug": to})
+		}
+	}
+
+	result := fiber.Map{"slugs": slugs, "ids": ids}
+	if n == limit {
+		result["next_since"] = last.Format(time.RFC3339Nano)
+	}
+	return c.JSON(fiber.Map{"success": true, "data": result})
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== PRODUCT RELATIONS ==========
+
+var relationTypes = []string{"accessory", "gift", "replacement", "bundle"}
+
+// feedRelation is one related item identifier taken from a feed item
+type feedRelation struct {
+	Type       string `json:"type"`
+	Identifier string `json:"identifier"`
+}
+
+// Source fields holding related item identifiers (ITEM_ID or EAN of another item in the feed)
+var feedRelationFields = map[string][]string{
+	"accessory": {"ACCESSORY", "accessory", "accessories"},
+	"gift":      {"GIFT_ID", "gift_id"},
+}
+
+// mapRelations collects related item identifiers from a feed item
+func mapRelations(item map[string]interface{}) []feedRelation {
+	var relations []feedRelation
+	seen := map[string]bool{}
+	for _, relationType := range []string{"accessory", "gift"} {
+		for _, field := range feedRelationFields[relationType] {
+			for _, identifier := range getAllStr(item, field) {
+				key := relationType + "|" + identifier
+				if len(identifier) > 255 || seen[key] {
+					continue
+				}
+				seen[key] = true
+				relations = append(relations, feedRelation{Type: relationType, Identifier: identifier})
+			}
+		}
+	}
+	return relations
+}
+
+// saveFeedRelations replaces the feed-sourced relations of a product; admin entries are kept.
+// Identifiers are resolved later by resolveFeedRelations.
+func (h *Handlers) saveFeedRelations(ctx context.Context, feedID, productID string, relations []feedRelation) {
+	batch := &pgx.Batch{}
+	queueFeedRelations(batch, feedID, productID, relations)
+	h.db.Pool.SendBatch(ctx, batch).Close()
+}
+
+// queueFeedRelations adds the statements of saveFeedRelations to a batch
+func queueFeedRelations(batch *pgx.Batch, feedID, productID string, relations []feedRelation) {
+	batch.Queue("DELETE FROM product_relations WHERE product_id = $1::uuid AND source = 'feed'", productID)
+	if len(relations) == 0 {
+		return
+	}
+	types := make([]string, len(relations))
+	identifiers := make([]string, len(relations))
+	for i, r := range relations {
+		types[i], identifiers[i] = r.Type, r.Identifier
+	}
+	batch.Queue(`
+		INSERT INTO product_relations (product_id, related_identifier, relation_type, feed_id, source, position, created_at)
+		SELECT $1::uuid, r.identifier, r.type, $2::uuid, 'feed', r.ord - 1, NOW()
+		FROM unnest($3::text[], $4::text[]) WITH ORDINALITY AS r(type, identifier, ord)
+	`, productID, feedID, types, identifiers)
+}
+
+// resolveFeedRelations links pending identifiers to products of the same feed by SKU (ITEM_ID) or EAN.
+// Identifiers that still match nothing stay pending and are retried on the next import.
+func (h *Handlers) resolveFeedRelations(ctx context.Context, feedID string) (resolved, pending int64) {
+	tag, err := h.db.Pool.Exec(ctx, `
+		WITH matches AS (
+			SELECT DISTINCT ON (r.id) r.id, p.id AS related_id
+			FROM product_relations r
+			JOIN products p ON p.feed_id = r.feed_id AND p.id <> r.product_id
+			                AND (p.sku = r.related_identifier OR p.ean = r.related_identifier)
+			WHERE r.feed_id = $1::uuid AND r.related_product_id IS NULL
+			ORDER BY r.id, (p.sku = r.related_identifier) DESC
+		)
+		UPDATE product_relations r SET related_product_id = m.related_id, resolved_at = NOW()
+		FROM matches m WHERE r.id = m.id
+	`, feedID)
+	if err == nil {
+		resolved = tag.RowsAffected()
+	}
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM product_relations WHERE feed_id = $1::uuid AND related_product_id IS NULL", feedID).Scan(&pending)
+	return resolved, pending
+}
+
+// relatedProduct is a resolved related product as returned to the storefront
+type relatedProduct struct {
+	models.ProductCard
+	RelationType string `json:"relation_type"`
+}
+
+// GetProductAccessories returns the active accessories and gifts of a product
+func (h *Handlers) GetProductAccessories(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	ctx := context.Background()
+	priceMode, ok := priceModeParam(c)
+	if !ok {
+		return invalidPriceMode(c)
+	}
+	types := splitList(c.Query("type", "accessory,gift"))
+	for _, t := range types {
+		if !containsString(relationTypes, t) {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "type must be accessory, gift, replacement or bundle"})
+		}
+	}
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT * FROM (
+			SELECT DISTINCT ON (r.relation_type, p.id) `+productCardColumns(priceMode)+`, r.relation_type, r.position
+			FROM product_relations r
+			JOIN products p ON p.id = r.related_product_id AND p.is_active = true
+			LEFT JOIN categories c ON p.category_id = c.id
+			WHERE r.product_id = $1::uuid AND r.relation_type = ANY($2)
+			ORDER BY r.relation_type, p.id, r.position
+		) related ORDER BY relation_type, position
+	`, productID, types)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	related := scanRelatedProducts(rows)
+	h.attachRelatedLabels(ctx, related)
+	addRelatedDisplay(c, related)
+	return c.JSON(fiber.Map{"success": true, "data": related})
+}
+
+// scanRelatedProducts reads rows of productCardColumns followed by relation type and
+// position, and closes them
+func scanRelatedProducts(rows pgx.Rows) []relatedProduct {
+	defer rows.Close()
+	related := []relatedProduct{}
+	for rows.Next() {
+		var relationType string
+		var position int
+		card, err := scanProductCard(rows, &relationType, &position)
+		if err != nil {
+			continue
+		}
+		related = append(related, relatedProduct{ProductCard: card, RelationType: relationType})
+	}
+	return related
+}
+
+func (h *Handlers) attachRelatedLabels(ctx context.Context, related []relatedProduct) {
+	ids := make([]string, len(related))
+	for i, r := range related {
+		ids[i] = r.ID
+	}
+	labels := h.productLabels(ctx, ids)
+	for i := range related {
+		related[i].Labels = append([]models.Label{}, labels[related[i].ID]...)
+	}
+}
+
+func (h *Handlers) AdminListProductRelations(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	ctx := context.Background()
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT r.id, r.relation_type, COALESCE(r.related_identifier,''), COALESCE(r.related_product_id::text,''),
+		       COALESCE(p.title,''), COALESCE(r.source,'admin'), r.position
+		FROM product_relations r LEFT JOIN products p ON p.id = r.related_product_id
+		WHERE r.product_id = $1::uuid
+		ORDER BY r.relation_type, r.position, r.created_at
+	`, productID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+	relations := []fiber.Map{}
+	for rows.Next() {
+		var id, relationType, identifier, relatedID, relatedTitle, source string
+		var position int
+		rows.Scan(&id, &relationType, &identifier, &relatedID, &relatedTitle, &source, &position)
+		relations = append(relations, fiber.Map{
+			"id": id, "relation_type": relationType, "related_identifier": identifier,
+			"related_product_id": relatedID, "related_title": relatedTitle, "resolved": relatedID != "",
+			"source": source, "position": position,
+		})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": relations})
+}
+
+func (h *Handlers) AdminCreateProductRelation(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	var input struct {
+		RelatedProductID string `json:"related_product_id"`
+		RelationType     string `json:"relation_type"`
+		Position         *int   `json:"position"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	input.RelationType = strings.ToLower(strings.TrimSpace(input.RelationType))
+	if !containsString(relationTypes, input.RelationType) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "relation_type must be accessory, gift, replacement or bundle"})
+	}
+	if !isUUID(input.RelatedProductID) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "related_product_id must be a UUID"})
+	}
+	if input.RelatedProductID == productID {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "A product cannot be related to itself"})
+	}
+
+	ctx := context.Background()
+	var exists int
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM products WHERE id = ANY($1::uuid[])", []string{productID, input.RelatedProductID}).Scan(&exists)
+	if exists < 2 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Product not found"})
+	}
+
+	var id string
+	err := h.db.Pool.QueryRow(ctx, `
+		INSERT INTO product_relations (product_id, related_product_id, relation_type, source, position, created_at, resolved_at)
+		VALUES ($1::uuid, $2::uuid, $3, 'admin',
+		        COALESCE($4, (SELECT COALESCE(MAX(position) + 1, 0) FROM product_relations WHERE product_id = $1::uuid AND relation_type = $3)),
+		        NOW(), NOW())
+		ON CONFLICT (product_id, relation_type, related_product_id) WHERE source = 'admin' DO NOTHING
+		RETURNING id
+	`, productID, input.RelatedProductID, input.RelationType, input.Position).Scan(&id)
+	if err != nil {
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "Relation already exists"})
+	}
+	return c.Status(201).JSON(fiber.Map{"success": true, "data": fiber.Map{"id": id}})
+}
+
+func (h *Handlers) AdminDeleteProductRelation(c *fiber.Ctx) error {
+	ctx := context.Background()
+	tag, err := h.db.Pool.Exec(ctx, "DELETE FROM product_relations WHERE id = $1::uuid AND product_id = $2::uuid", c.Params("relation_id"), c.Params("id"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Relation not found"})
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"megabuy-go/internal/money"
+)
+
+// ========== ADMIN SANITY REPORTS ==========
+
+const (
+	defaultOutlierFactor   = 10.0
+	defaultOutlierCategory = 5
+	// maxReportExportRows caps ?format=csv, which ignores pagination
+	maxReportExportRows = 50000
+)
+
+// reportKeys maps the URL name of a report to the key stored in report_reviews
+var reportKeys = map[string]string{
+	"price-outliers": "price_outliers",
+	"uncategorized":  "uncategorized",
+}
+
+// reportPage returns limit and offset of the request, or every row for a CSV export
+func reportPage(c *fiber.Ctx) (csvExport bool, page, limit, offset int) {
+	if wantsCSV(c) {
+		return true, 1, maxReportExportRows, 0
+	}
+	page, limit, offset = adminPageParams(c, 50)
+	return false, page, limit, offset
+}
+
+// sendCSV writes rows as a CSV attachment named after the report and today's date
+func sendCSV(c *fiber.Ctx, report string, header []string, rows [][]string) error {
+	c.Locals(csvServedLocal, true)
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Attachment(fmt.Sprintf("%s-%s.csv", report, time.Now().Format("2006-01-02")))
+	c.Write(utf8BOM)
+	w := csv.NewWriter(c)
+	w.Write(header)
+	w.WriteAll(rows)
+	return w.Error()
+}
+
+// csvPrice formats prices with a dot so spreadsheets parse them as numbers
+func csvPrice(v money.Price) string {
+	return v.String()
+}
+
+// GetPriceOutliersReport flags active products whose price is more than ?factor= times
+// above or below the median of their category, typically decimal errors from imports.
+// Medians come from window functions in the same query; categories with fewer than
+// ?min_products= priced products are skipped because their median means little.
+func (h *Handlers) GetPriceOutliersReport(c *fiber.Ctx) error {
+	factor := c.QueryFloat("factor", defaultOutlierFactor)
+	if factor <= 1 || factor > 1000 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "factor must be greater than 1 and at most 1000"})
+	}
+	minProducts := c.QueryInt("min_products", defaultOutlierCategory)
+	if minProducts < 3 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "min_products must be at least 3"})
+	}
+	csvExport, page, limit, offset := reportPage(c)
+	ctx := context.Background()
+
+	rows, err := h.db.Pool.Query(ctx, `
+		WITH ranked AS (
+			SELECT p.id, p.category_id, p.price_min,
+			       ROW_NUMBER() OVER (PARTITION BY p.category_id ORDER BY p.price_min) AS rn,
+			       COUNT(*) OVER (PARTITION BY p.category_id) AS n
+			FROM products p
+			WHERE p.is_active = true AND p.category_id IS NOT NULL AND p.price_min > 0
+		), medians AS (
+			SELECT category_id, AVG(price_min) AS median, MAX(n) AS n
+			FROM ranked WHERE rn IN ((n + 1) / 2, (n + 2) / 2)
+			GROUP BY category_id
+		), outliers AS (
+			SELECT r.id, r.category_id, r.price_min, m.median, m.n,
+			       GREATEST(r.price_min / m.median, m.median / r.price_min) AS deviation
+			FROM ranked r JOIN medians m ON m.category_id = r.category_id
+			WHERE m.n >= $2 AND GREATEST(r.price_min / m.median, m.median / r.price_min) > $1
+		)
+		SELECT o.id, p.title, COALESCE(p.ean,''), COALESCE(p.brand,''), COALESCE(c.name,''), COALESCE(f.name,''),
+		       o.price_min::float8, ROUND(o.median, 2)::float8, ROUND(o.deviation, 2)::float8, o.n, rr.reviewed_at,
+		       COUNT(*) OVER ()
+		FROM outliers o
+		JOIN products p ON p.id = o.id
+		LEFT JOIN categories c ON c.id = o.category_id
+		LEFT JOIN feeds f ON f.id = p.feed_id
+		LEFT JOIN report_reviews rr ON rr.report = 'price_outliers' AND rr.product_id = o.id AND rr.price_min = o.price_min
+		WHERE $3 OR rr.product_id IS NULL
+		ORDER BY o.deviation DESC, o.id
+		LIMIT $4 OFFSET $5
+	`, factor, minProducts, c.QueryBool("include_reviewed"), limit, offset)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+
+	var total int64
+	items := []fiber.Map{}
+	var records [][]string
+	for rows.Next() {
+		var id, title, ean, brand, category, feed string
+		var price, median money.Price
+		var deviation float64
+		var categorySize int64
+		var reviewedAt *time.Time
+		rows.Scan(&id, &title, &ean, &brand, &category, &feed, &price, &median, &deviation, &categorySize, &reviewedAt, &total)
+		direction := "high"
+		if price < median {
+			direction = "low"
+		}
+		if csvExport {
+			records = append(records, []string{id, title, ean, brand, category, feed, csvPrice(price), csvPrice(median),
+				strconv.FormatFloat(deviation, 'f', 2, 64), direction})
+			continue
+		}
+		items = append(items, fiber.Map{
+			"product_id": id, "title": title, "ean": ean, "brand": brand, "category": category, "feed": feed,
+			"price": price, "category_median": median, "deviation": deviation, "direction": direction,
+			"category_products": categorySize, "reviewed_at": reviewedAt,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	if csvExport {
+		return sendCSV(c, "price-outliers", []string{"product_id", "title", "ean", "brand", "category", "feed", "price", "category_median", "deviation", "direction"}, records)
+	}
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{
+		"factor":       factor,
+		"min_products": minProducts,
+		"items":        items,
+	}, page, limit, total)})
+}
+
+// GetUncategorizedReport lists active products without a category, most visited first
+func (h *Handlers) GetUncategorizedReport(c *fiber.Ctx) error {
+	csvExport, page, limit, offset := reportPage(c)
+	ctx := context.Background()
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT p.id, p.title, COALESCE(p.ean,''), COALESCE(p.brand,''), COALESCE(f.name,''), p.price_min::float8,
+		       COALESCE(p.view_count,0), COALESCE(p.click_count,0), p.created_at, rr.reviewed_at,
+		       COUNT(*) OVER ()
+		FROM products p
+		LEFT JOIN feeds f ON f.id = p.feed_id
+		LEFT JOIN report_reviews rr ON rr.report = 'uncategorized' AND rr.product_id = p.id
+		WHERE p.is_active = true AND p.category_id IS NULL AND ($1 OR rr.product_id IS NULL)
+		ORDER BY `+popularityExpr+` DESC NULLS LAST, p.created_at DESC, p.id
+		LIMIT $2 OFFSET $3
+	`, c.QueryBool("include_reviewed"), limit, offset)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+
+	var total int64
+	items := []fiber.Map{}
+	var records [][]string
+	for rows.Next() {
+		var id, title, ean, brand, feed string
+		var price money.Price
+		var views, clicks int64
+		var createdAt time.Time
+		var reviewedAt *time.Time
+		rows.Scan(&id, &title, &ean, &brand, &feed, &price, &views, &clicks, &createdAt, &reviewedAt, &total)
+		if csvExport {
+			records = append(records, []string{id, title, ean, brand, feed, csvPrice(price),
+				strconv.FormatInt(views, 10), strconv.FormatInt(clicks, 10), createdAt.Format(time.RFC3339)})
+			continue
+		}
+		items = append(items, fiber.Map{
+			"product_id": id, "title": title, "ean": ean, "brand": brand, "feed": feed, "price": price,
+			"view_count": views, "click_count": clicks, "created_at": createdAt, "reviewed_at": reviewedAt,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	if csvExport {
+		return sendCSV(c, "uncategorized", []string{"product_id", "title", "ean", "brand", "feed", "price", "view_count", "click_count", "created_at"}, records)
+	}
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{"items": items}, page, limit, total)})
+}
+
+// MarkReportReviewed hides products from a report; DELETE on the same route shows them again
+func (h *Handlers) MarkReportReviewed(c *fiber.Ctx) error {
+	report, ok := reportKeys[c.Params("report")]
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Unknown report"})
+	}
+	var input struct {
+		ProductIDs []string `json:"product_ids"`
+		Note       string   `json:"note"`
+	}
+	if err := c.BodyParser(&input); err != nil || len(input.ProductIDs) == 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "product_ids required"})
+	}
+	for _, id := range input.ProductIDs {
+		if !isUUID(id) {
+			return invalidUUIDField(c, "product_ids")
+		}
+	}
+
+	ctx := context.Background()
+	var tag pgconn.CommandTag
+	var err error
+	if c.Method() == fiber.MethodDelete {
+		tag, err = h.db.Pool.Exec(ctx, "DELETE FROM report_reviews WHERE report = $1 AND product_id = ANY($2::uuid[])", report, input.ProductIDs)
+	} else {
+		// the reviewed price lets a later price change bring an outlier back
+		tag, err = h.db.Pool.Exec(ctx, `
+			INSERT INTO report_reviews (report, product_id, price_min, note, reviewed_at)
+			SELECT $1, id, price_min, NULLIF($3,''), NOW() FROM products WHERE id = ANY($2::uuid[])
+			ON CONFLICT (report, product_id) DO UPDATE SET price_min = EXCLUDED.price_min, note = EXCLUDED.note, reviewed_at = NOW()
+		`, report, input.ProductIDs, input.Note)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	action := "report.reviewed"
+	if c.Method() == fiber.MethodDelete {
+		action = "report.unreviewed"
+	}
+	h.audit(ctx, c, action, "report", "", fiber.Map{"report": report, "product_ids": input.ProductIDs, "note": input.Note})
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"report": c.Params("report"), "updated": tag.RowsAffected()}})
+}
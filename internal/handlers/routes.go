@@ -0,0 +1,220 @@
+package handlers
+
+import "github.com/gofiber/fiber/v2"
+
+// RegisterRoutes mounts the HTTP API of h on app; cmd/main.go and the integration
+// tests serve the same routes through it
+func RegisterRoutes(app *fiber.App, h *Handlers) {
+	validID := RequireUUID("id")
+
+	app.Use("/uploads/category-icons", CategoryIconHeaders)
+	app.Static("/uploads", "./uploads")
+
+	app.Get("/health", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"status": "ok", "maintenance": h.MaintenanceEnabled()})
+	})
+
+	// API v1 routes
+	api := app.Group("/api/v1", NegotiateFormat)
+
+	// Public routes
+	api.Get("/search", h.Search)
+	api.Get("/products", h.GetProducts)
+	api.Get("/products/featured", h.GetFeaturedProducts)
+	api.Get("/products/upcoming", h.GetUpcomingProducts)
+	api.Get("/products/slug/:slug", h.GetProductBySlug)
+	api.Get("/products/:slug/full", h.GetProductView)
+	api.Get("/products/:id/offers", validID, h.GetProductOffers)
+	api.Post("/products/:id/price-alerts", validID, h.CreatePriceAlert)
+	api.Post("/products/:id/stock-alerts", validID, h.CreateStockAlert)
+	api.Get("/products/:id/accessories", validID, h.GetProductAccessories)
+	api.Get("/products/:id/variants", validID, h.GetProductVariants)
+	api.Get("/products/:id/questions", validID, h.GetProductQuestions)
+	api.Post("/products/:id/questions", validID, h.CreateProductQuestion)
+	api.Get("/price-alerts/confirm", h.ConfirmPriceAlert)
+	api.Get("/price-alerts/unsubscribe", h.UnsubscribePriceAlert)
+	api.Get("/categories", h.GetCategories)
+	api.Get("/categories/tree", h.GetCategoriesTree)
+	api.Get("/categories/flat", h.GetCategoriesFlat)
+	api.Get("/categories/slug/:slug", h.GetCategoryBySlug)
+	api.Get("/categories/:slug/products", h.GetProductsByCategory)
+	api.Get("/categories/:slug/filter-path", h.GetCategoryFilterPath)
+	api.Get("/categories/:slug/f/*", h.GetCategoryFilterPage)
+	api.Get("/brands", h.GetBrands)
+	api.Get("/brands/:slug/products", h.GetBrandProducts)
+	api.Get("/stats", h.GetStats)
+	api.Get("/homepage", h.GetHomepage)
+	api.Get("/redirects", h.GetRedirects)
+	api.Get("/graphql", h.GraphQL)
+	api.Post("/graphql", h.GraphQL)
+	api.Get("/graphql/schema", h.GraphQLSchema)
+
+	// Attribute stats (public for filtering)
+	api.Get("/attributes/stats", h.GetAttributeStats)
+	api.Get("/attributes/values", h.GetAttributeValues)
+
+	// Warehouse integration, authenticated by scoped API key
+	api.Put("/inventory", h.MaintenanceGuard(), h.RequireAPIKey("inventory:write"), h.UpdateInventory)
+
+	// Admin routes
+	admin := api.Group("/admin", h.MaintenanceGuard())
+	admin.Get("/maintenance", h.GetMaintenance)
+	admin.Post("/maintenance", h.SetMaintenance)
+	admin.Get("/dashboard", h.AdminDashboard)
+	admin.Get("/stats", h.AdminProductStats)
+	admin.Get("/audit-log", h.AdminAuditLog)
+	admin.Get("/api-keys", h.AdminListAPIKeys)
+	admin.Post("/api-keys", h.AdminCreateAPIKey)
+	admin.Delete("/api-keys/:id", validID, h.AdminRevokeAPIKey)
+	admin.Post("/graphql/persisted-queries", h.AdminRegisterPersistedQuery)
+	admin.Get("/snapshots", h.AdminListSnapshots)
+	admin.Post("/snapshots", h.AdminCreateSnapshot)
+	admin.Get("/snapshots/:id/restore", validID, h.GetSnapshotRestore)
+	admin.Post("/snapshots/:id/restore", validID, h.AdminRestoreSnapshot)
+	admin.Get("/descriptions/backfill", h.GetDescriptionBackfill)
+	admin.Post("/descriptions/backfill", h.StartDescriptionBackfill)
+	admin.Get("/short-descriptions/generate", h.GetShortDescriptionRun)
+	admin.Post("/short-descriptions/generate", h.StartShortDescriptionRun)
+	admin.Put("/brands/:slug", h.AdminSetBrand)
+	admin.Post("/prices/repair", h.AdminRepairPriceRanges)
+	admin.Post("/eans/normalize", h.AdminNormalizeEANs)
+	admin.Get("/reports/price-outliers", h.GetPriceOutliersReport)
+	admin.Get("/reports/uncategorized", h.GetUncategorizedReport)
+	admin.Get("/reports/image-alt", h.GetImageAltReport)
+	admin.Post("/reports/:report/reviewed", h.MarkReportReviewed)
+	admin.Delete("/reports/:report/reviewed", h.MarkReportReviewed)
+	admin.Post("/sync-elasticsearch", h.SyncToElasticsearch)
+	admin.Get("/price-alerts", h.AdminPriceAlertStats)
+	admin.Get("/notifications", h.AdminListNotifications)
+	admin.Post("/notifications/:id/retry", validID, h.AdminRetryNotification)
+	admin.Post("/attributes/bulk-delete", h.AdminBulkDeleteAttributes)
+	admin.Delete("/attributes/:slug", h.AdminDeleteAttribute)
+	admin.Get("/debug/db", h.DebugDB)
+	admin.Get("/debug/es-sync", h.DebugESSync)
+	admin.Get("/search/sync-queue", h.GetESSyncQueue)
+	admin.Post("/search/sync-queue/requeue", h.RequeueESSync)
+	admin.Get("/debug/search-cache", h.DebugSearchCache)
+	admin.Get("/search-config", h.AdminSearchConfigs)
+	admin.Get("/search-config/stats", h.AdminSearchVariantStats)
+	admin.Put("/search-config/:variant", h.AdminSetSearchConfig)
+	admin.Delete("/search-config/:variant", h.AdminDeleteSearchConfig)
+
+	// Filter settings
+	admin.Get("/filter-settings", h.GetFilterSettings)
+	admin.Put("/filter-settings", h.UpdateFilterSettings)
+
+	// Products
+	admin.Get("/products", h.AdminProducts)
+	admin.Delete("/products/all", h.DeleteAllProducts)
+	admin.Post("/products/bulk", h.BulkDeleteProducts)
+	admin.Post("/products/check-links", h.StartLinkCheck)
+	admin.Get("/products/check-links", h.GetLinkCheckReport)
+	admin.Post("/products/check-links/deactivate", h.DeactivateDeadLinks)
+	admin.Get("/products/:id", validID, h.AdminGetProduct)
+	admin.Post("/products", h.Idempotency(), h.AdminCreateProduct)
+	admin.Put("/products/:id", validID, h.AdminUpdateProduct)
+	admin.Delete("/products/:id", validID, h.AdminDeleteProduct)
+	admin.Post("/products/:id/merge", validID, h.AdminMergeProduct)
+	admin.Get("/products/:id/media", validID, h.AdminListProductMedia)
+	admin.Post("/products/:id/media", validID, h.AdminCreateProductMedia)
+	admin.Put("/products/:id/media/:media_id", validID, RequireUUID("media_id"), h.AdminUpdateProductMedia)
+	admin.Delete("/products/:id/media/:media_id", validID, RequireUUID("media_id"), h.AdminDeleteProductMedia)
+	admin.Get("/settings/image-alt", h.GetImageAltSettings)
+	admin.Put("/settings/image-alt", h.SetImageAltSettings)
+	admin.Get("/settings/listing-sort", h.GetListingSortSettings)
+	admin.Put("/settings/listing-sort", h.SetListingSortSettings)
+	admin.Get("/settings", h.AdminGetSettings)
+	admin.Put("/settings", h.AdminSetSettings)
+	admin.Get("/settings/:key", h.AdminGetSetting)
+	admin.Put("/settings/:key", h.AdminSetSetting)
+	admin.Get("/products/:id/relations", validID, h.AdminListProductRelations)
+	admin.Post("/products/:id/relations", validID, h.AdminCreateProductRelation)
+	admin.Delete("/products/:id/relations/:relation_id", validID, RequireUUID("relation_id"), h.AdminDeleteProductRelation)
+	admin.Put("/products/:id/promo", validID, h.AdminSetProductPromo)
+	admin.Delete("/products/:id/promo", validID, h.AdminDeleteProductPromo)
+	admin.Post("/promos/bulk", h.AdminBulkPromo)
+	admin.Post("/products/:id/labels", validID, h.AdminAssignProductLabel)
+	admin.Delete("/products/:id/labels/:label_id", validID, RequireUUID("label_id"), h.AdminRemoveProductLabel)
+	admin.Post("/products/:id/tags", validID, h.AdminTagProduct)
+	admin.Delete("/products/:id/tags/:tag_id", validID, RequireUUID("tag_id"), h.AdminUntagProduct)
+	// Labels
+	admin.Get("/labels", h.AdminListLabels)
+	admin.Post("/labels", h.AdminCreateLabel)
+	admin.Put("/labels/:id", validID, h.AdminUpdateLabel)
+	admin.Delete("/labels/:id", validID, h.AdminDeleteLabel)
+	admin.Post("/labels/:id/products", validID, h.AdminBulkLabelProducts)
+	// Internal tags
+	admin.Get("/tags", h.AdminListTags)
+	admin.Post("/tags", h.AdminCreateTag)
+	admin.Delete("/tags/:id", validID, h.AdminDeleteTag)
+	admin.Post("/tags/:id/products", validID, h.AdminBulkTagProducts)
+	// Questions
+	admin.Get("/questions", h.AdminListQuestions)
+	admin.Put("/questions/:question_id", RequireUUID("question_id"), h.AdminUpdateQuestion)
+	admin.Delete("/questions/:question_id", RequireUUID("question_id"), h.AdminDeleteQuestion)
+	admin.Post("/questions/:question_id/answers", RequireUUID("question_id"), h.AdminAnswerQuestion)
+	// Homepage
+	admin.Get("/homepage-blocks", h.AdminHomepageBlocks)
+	admin.Post("/homepage-blocks", h.AdminCreateHomepageBlock)
+	admin.Put("/homepage-blocks/:id", validID, h.AdminUpdateHomepageBlock)
+	admin.Delete("/homepage-blocks/:id", validID, h.AdminDeleteHomepageBlock)
+	// Categories
+	admin.Delete("/categories/all", h.DeleteAllCategories)
+	admin.Get("/categories", h.AdminCategories)
+	admin.Get("/categories/pending", h.AdminPendingCategories)
+	admin.Get("/completeness", h.AdminGetCompleteness)
+	admin.Put("/completeness", h.AdminSetCompleteness)
+	admin.Post("/completeness/recompute", h.AdminRecomputeCompleteness)
+	admin.Get("/categories/icons", h.AdminCategoryIcons)
+	admin.Get("/categories/icons/invalid", h.AdminInvalidCategoryIcons)
+	admin.Post("/categories/pending/approve", h.AdminApprovePendingCategories)
+	admin.Post("/categories/pending/rename", h.AdminRenamePendingCategories)
+	admin.Post("/categories/pending/merge", h.AdminMergePendingCategories)
+	admin.Post("/categories", h.Idempotency(), h.AdminCreateCategory)
+	admin.Get("/categories/:id/attribute-stats", validID, h.AdminCategoryAttributeStats)
+	admin.Get("/categories/:id/short-description-template", validID, h.GetShortDescriptionTemplate)
+	admin.Put("/categories/:id/short-description-template", validID, h.SetShortDescriptionTemplate)
+	admin.Post("/categories/:id/short-description-template/preview", validID, h.PreviewShortDescription)
+	admin.Get("/categories/:id/default-sort", validID, h.GetCategoryDefaultSort)
+	admin.Put("/categories/:id/default-sort", validID, h.SetCategoryDefaultSort)
+	admin.Put("/categories/:id", validID, h.AdminUpdateCategory)
+	admin.Post("/categories/:id/reassign", validID, h.AdminReassignCategoryProducts)
+	admin.Post("/categories/:id/icon", validID, h.AdminUploadCategoryIcon)
+	admin.Delete("/categories/:id/icon", validID, h.AdminDeleteCategoryIcon)
+	admin.Delete("/categories/:id", validID, h.AdminDeleteCategory)
+
+	// Upload
+	admin.Post("/upload", h.UploadImage)
+	admin.Post("/upload/multiple", h.UploadImages)
+
+	// Feeds
+	admin.Get("/feeds", h.GetFeeds)
+	admin.Post("/feeds", h.Idempotency(), h.CreateFeed)
+	admin.Post("/feeds/preview", h.PreviewFeed)
+	admin.Get("/feeds/export", h.ExportFeeds)
+	admin.Get("/feeds/schedule", h.GetImportSchedule)
+	admin.Put("/feeds/schedule/blackout", h.SetImportBlackout)
+	admin.Post("/feeds/import", h.ImportFeeds)
+	admin.Put("/feeds/:id", validID, h.UpdateFeed)
+	admin.Delete("/feeds/:id", validID, h.DeleteFeed)
+	admin.Post("/feeds/:id/preview-diff", validID, h.PreviewFeedDiff)
+	admin.Post("/feeds/:id/adopt", validID, h.AdoptFeedProducts)
+	admin.Post("/feeds/:id/import", validID, h.StartImport)
+	admin.Post("/feeds/:id/import/prioritize", validID, h.PrioritizeImport)
+	admin.Get("/feeds/:id/progress", validID, h.GetImportProgress)
+	admin.Get("/feeds/:id/imports", validID, h.GetFeedImports)
+	admin.Get("/feeds/:id/imports/:run_id", validID, RequireUUID("run_id"), h.GetImportRun)
+	admin.Get("/imports", h.AdminListImports)
+	admin.Get("/feeds/:id/performance", validID, h.GetFeedPerformance)
+	admin.Get("/feeds/:id/attribute-coverage", validID, h.GetFeedAttributeCoverage)
+	admin.Get("/feeds/:id/staged", validID, h.GetStagedProducts)
+	admin.Post("/feeds/:id/staged/approve", validID, h.ApproveStagedProducts)
+	admin.Post("/feeds/:id/staged/reject", validID, h.RejectStagedProducts)
+
+	// Legacy routes without /api/v1 prefix (frontend compatibility)
+	app.Get("/products", NegotiateFormat, h.GetProducts)
+	app.Get("/categories", NegotiateFormat, h.GetCategories)
+	app.Get("/categories/tree", NegotiateFormat, h.GetCategoriesTree)
+	app.Get("/categories/flat", NegotiateFormat, h.GetCategoriesFlat)
+	app.Get("/admin/products", NegotiateFormat, h.AdminProducts)
+}
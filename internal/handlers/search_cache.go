@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/elasticsearch"
+)
+
+// ========== SEARCH RESULT CACHE ==========
+
+const maxSearchCacheEntries = 5000
+
+// searchCache keeps recent ES responses keyed by normalized SearchParams. Entries are
+// dropped when the sync queue writes products they could contain; bulk reindexes bump
+// the generation, which is part of every key, so nothing outlives a full resync or the TTL.
+type searchCache struct {
+	ttl        time.Duration
+	generation atomic.Uint64
+
+	mu      sync.Mutex
+	entries map[string]*searchCacheEntry
+
+	hits          atomic.Int64
+	misses        atomic.Int64
+	bypassed      atomic.Int64
+	invalidations atomic.Int64
+}
+
+type searchCacheEntry struct {
+	result     *elasticsearch.SearchResult
+	at         time.Time
+	categories map[string]bool
+	brands     map[string]bool
+	products   map[string]bool
+}
+
+// newSearchCacheFromEnv enables the cache when SEARCH_CACHE_TTL is a positive duration such as "30s"
+func newSearchCacheFromEnv() *searchCache {
+	ttl, err := time.ParseDuration(os.Getenv("SEARCH_CACHE_TTL"))
+	if err != nil || ttl <= 0 {
+		return nil
+	}
+	return &searchCache{ttl: ttl, entries: make(map[string]*searchCacheEntry)}
+}
+
+// key normalizes params so equivalent searches share an entry
+func (sc *searchCache) key(params elasticsearch.SearchParams) string {
+	norm := func(values []string) []string {
+		out := append([]string(nil), values...)
+		sort.Strings(out)
+		return out
+	}
+	params.Query = strings.ToLower(strings.Join(strings.Fields(params.Query), " "))
+	params.CategoryIDs = norm(params.CategoryIDs)
+	params.Brands = norm(params.Brands)
+	params.ExcludeCategoryIDs = norm(params.ExcludeCategoryIDs)
+	params.ExcludeBrands = norm(params.ExcludeBrands)
+	params.Labels = norm(params.Labels)
+	if params.Sort == "" {
+		params.Sort = "relevance"
+	}
+	raw, _ := json.Marshal(params)
+	return strconv.FormatUint(sc.generation.Load(), 10) + ":" + string(raw)
+}
+
+func toSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// copyResult lets callers adjust products (e.g. net prices) without touching the cached entry
+func copyResult(r *elasticsearch.SearchResult) *elasticsearch.SearchResult {
+	out := *r
+	out.Products = append([]elasticsearch.Product(nil), r.Products...)
+	return &out
+}
+
+func (sc *searchCache) get(key string) (*elasticsearch.SearchResult, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	e, ok := sc.entries[key]
+	if !ok || time.Since(e.at) > sc.ttl {
+		delete(sc.entries, key)
+		return nil, false
+	}
+	return copyResult(e.result), true
+}
+
+func (sc *searchCache) put(key string, params elasticsearch.SearchParams, result *elasticsearch.SearchResult) {
+	e := &searchCacheEntry{
+		result:     copyResult(result),
+		at:         time.Now(),
+		categories: toSet(params.CategoryIDs),
+		brands:     toSet(params.Brands),
+		products:   make(map[string]bool, len(result.Products)),
+	}
+	for _, p := range result.Products {
+		e.products[p.ID] = true
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if len(sc.entries) >= maxSearchCacheEntries {
+		for k, old := range sc.entries {
+			if time.Since(old.at) > sc.ttl || len(sc.entries) >= maxSearchCacheEntries {
+				delete(sc.entries, k)
+			}
+		}
+	}
+	sc.entries[key] = e
+}
+
+// invalidateProducts drops entries that show one of the written products or whose
+// filters would match them now
+func (sc *searchCache) invalidateProducts(ids []string, products []elasticsearch.Product) {
+	if sc == nil || (len(ids) == 0 && len(products) == 0) {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for k, e := range sc.entries {
+		if e.affectedBy(ids, products) {
+			delete(sc.entries, k)
+			sc.invalidations.Add(1)
+		}
+	}
+}
+
+func (e *searchCacheEntry) affectedBy(ids []string, products []elasticsearch.Product) bool {
+	for _, id := range ids {
+		if e.products[id] {
+			return true
+		}
+	}
+	for _, p := range products {
+		if (e.categories == nil || e.categories[p.CategoryID]) && (e.brands == nil || e.brands[p.Brand]) {
+			return true
+		}
+	}
+	return false
+}
+
+// bumpGeneration retires every entry at once, for writes that cannot be attributed
+func (sc *searchCache) bumpGeneration() {
+	if sc == nil {
+		return
+	}
+	sc.generation.Add(1)
+	sc.mu.Lock()
+	sc.invalidations.Add(int64(len(sc.entries)))
+	sc.entries = make(map[string]*searchCacheEntry)
+	sc.mu.Unlock()
+}
+
+// cachedSearch serves Search through the cache. "X-Search-Cache: bypass" skips it for debugging.
+func (h *Handlers) cachedSearch(c *fiber.Ctx, params elasticsearch.SearchParams) (*elasticsearch.SearchResult, error) {
+	result, status, err := h.searchWithCache(c.Context(), params, strings.EqualFold(c.Get("X-Search-Cache"), "bypass"))
+	if status != "" {
+		c.Set("X-Search-Cache", status)
+	}
+	return result, err
+}
+
+// searchWithCache runs an ES search through the result cache and reports how the
+// cache was used: HIT, MISS, BYPASS, or "" when caching is off
+func (h *Handlers) searchWithCache(ctx context.Context, params elasticsearch.SearchParams, bypass bool) (*elasticsearch.SearchResult, string, error) {
+	policies := h.outOfStockPolicies(ctx)
+	params.HideOutOfStockCategoryIDs, params.DemoteOutOfStockCategoryIDs = policies.hide, policies.demote
+	sc := h.searchCache
+	if sc == nil {
+		result, err := h.es.Search(ctx, params)
+		return result, "", err
+	}
+	if bypass {
+		sc.bypassed.Add(1)
+		result, err := h.es.Search(ctx, params)
+		return result, "BYPASS", err
+	}
+
+	key := sc.key(params)
+	if result, ok := sc.get(key); ok {
+		sc.hits.Add(1)
+		return result, "HIT", nil
+	}
+	sc.misses.Add(1)
+	result, err := h.es.Search(ctx, params)
+	if err != nil {
+		return nil, "MISS", err
+	}
+	sc.put(key, params, result)
+	return result, "MISS", nil
+}
+
+func (h *Handlers) DebugSearchCache(c *fiber.Ctx) error {
+	sc := h.searchCache
+	if sc == nil {
+		return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"enabled": false}})
+	}
+	sc.mu.Lock()
+	size := len(sc.entries)
+	sc.mu.Unlock()
+	hits, misses := sc.hits.Load(), sc.misses.Load()
+	var hitRate float64
+	if hits+misses > 0 {
+		hitRate = float64(hits) / float64(hits+misses)
+	}
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"enabled":       true,
+		"ttl_seconds":   sc.ttl.Seconds(),
+		"entries":       size,
+		"generation":    sc.generation.Load(),
+		"hits":          hits,
+		"misses":        misses,
+		"bypassed":      sc.bypassed.Load(),
+		"hit_rate":      hitRate,
+		"invalidations": sc.invalidations.Load(),
+	}})
+}
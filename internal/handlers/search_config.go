@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/elasticsearch"
+)
+
+// ========== SEARCH RANKING CONFIG ==========
+
+const (
+	defaultSearchVariant = "default"
+	// searchConfigCacheTTL bounds how long instances take to pick up a config change
+	searchConfigCacheTTL = 5 * time.Second
+)
+
+var searchVariantPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// searchVariant is a stored ranking config; Version increases with every change
+type searchVariant struct {
+	Ranking   elasticsearch.RankingConfig `json:"config"`
+	Version   int                         `json:"version"`
+	UpdatedAt time.Time                   `json:"updated_at"`
+}
+
+var (
+	searchConfigMutex    sync.RWMutex
+	searchConfigCache    map[string]searchVariant
+	searchConfigLoadedAt time.Time
+)
+
+// searchVariants returns all ranking variants, reloading them when the cache is stale.
+// A failed reload keeps serving the previous configs.
+func (h *Handlers) searchVariants() map[string]searchVariant {
+	searchConfigMutex.RLock()
+	variants, fresh := searchConfigCache, time.Since(searchConfigLoadedAt) < searchConfigCacheTTL
+	searchConfigMutex.RUnlock()
+	if fresh {
+		return variants
+	}
+
+	rows, err := h.db.Pool.Query(context.Background(), "SELECT variant, config::text, COALESCE(version,1), updated_at FROM search_config")
+	if err == nil {
+		loaded := map[string]searchVariant{}
+		for rows.Next() {
+			var name, raw string
+			var v searchVariant
+			rows.Scan(&name, &raw, &v.Version, &v.UpdatedAt)
+			v.Ranking = elasticsearch.DefaultRanking()
+			if err := json.Unmarshal([]byte(raw), &v.Ranking); err != nil {
+				log.Printf("Search config %s unreadable, using defaults: %v", name, err)
+			}
+			loaded[name] = v
+		}
+		rows.Close()
+		if rows.Err() == nil {
+			variants = loaded
+		}
+	}
+	searchConfigMutex.Lock()
+	searchConfigCache, searchConfigLoadedAt = variants, time.Now()
+	searchConfigMutex.Unlock()
+	return variants
+}
+
+func invalidateSearchConfig() {
+	searchConfigMutex.Lock()
+	searchConfigLoadedAt = time.Time{}
+	searchConfigMutex.Unlock()
+}
+
+// rankingFor resolves ?variant=; unknown variants fall back to the default one
+func (h *Handlers) rankingFor(variant string) (string, searchVariant, bool) {
+	variants := h.searchVariants()
+	if variant == "" {
+		variant = defaultSearchVariant
+	}
+	if v, ok := variants[variant]; ok {
+		return variant, v, true
+	}
+	if v, ok := variants[defaultSearchVariant]; ok {
+		return defaultSearchVariant, v, variant == defaultSearchVariant
+	}
+	return defaultSearchVariant, searchVariant{Ranking: elasticsearch.DefaultRanking()}, variant == defaultSearchVariant
+}
+
+// logSearchEvent records a served search for per-variant analytics without delaying the response
+func (h *Handlers) logSearchEvent(query, variant string, version int, total, took int64) {
+	go func() {
+		_, err := h.db.Pool.Exec(context.Background(), `
+			INSERT INTO search_events (query, variant, config_version, total, took_ms, created_at)
+			VALUES (NULLIF($1,''), $2, $3, $4, $5, NOW())
+		`, strings.ToLower(strings.TrimSpace(query)), variant, version, total, took)
+		if err != nil {
+			log.Printf("Search event not logged: %v", err)
+		}
+	}()
+}
+
+func (h *Handlers) AdminSearchConfigs(c *fiber.Ctx) error {
+	invalidateSearchConfig()
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"variants": h.searchVariants(),
+		"fields":   elasticsearch.SearchableFields,
+	}})
+}
+
+// AdminSetSearchConfig creates or replaces the ranking config of a variant
+func (h *Handlers) AdminSetSearchConfig(c *fiber.Ctx) error {
+	variant := c.Params("variant")
+	if !searchVariantPattern.MatchString(variant) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "variant may contain lowercase letters, digits, - and _ (max 50)"})
+	}
+	ranking := elasticsearch.DefaultRanking()
+	ranking.FieldBoosts = nil
+	if err := json.Unmarshal(c.Body(), &ranking); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if err := ranking.Validate(); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	raw, _ := json.Marshal(ranking)
+	ctx := context.Background()
+	var version int
+	err := h.db.Pool.QueryRow(ctx, `
+		INSERT INTO search_config (variant, config, version, updated_at) VALUES ($1, $2::jsonb, 1, NOW())
+		ON CONFLICT (variant) DO UPDATE SET config = EXCLUDED.config, version = search_config.version + 1, updated_at = NOW()
+		RETURNING version
+	`, variant, string(raw)).Scan(&version)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	invalidateSearchConfig()
+	h.audit(ctx, c, "search_config.update", "search_config", "", fiber.Map{"variant": variant, "version": version, "config": ranking})
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"variant": variant, "version": version, "config": ranking}})
+}
+
+func (h *Handlers) AdminDeleteSearchConfig(c *fiber.Ctx) error {
+	variant := c.Params("variant")
+	if variant == defaultSearchVariant {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "The default variant cannot be deleted"})
+	}
+	ctx := context.Background()
+	tag, err := h.db.Pool.Exec(ctx, "DELETE FROM search_config WHERE variant = $1", variant)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Variant not found"})
+	}
+	invalidateSearchConfig()
+	h.audit(ctx, c, "search_config.delete", "search_config", "", fiber.Map{"variant": variant})
+	return c.JSON(fiber.Map{"success": true, "message": "Variant deleted"})
+}
+
+// AdminSearchVariantStats compares variants over the last ?days= (default 7)
+func (h *Handlers) AdminSearchVariantStats(c *fiber.Ctx) error {
+	days := c.QueryInt("days", 7)
+	if days < 1 || days > 90 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "days must be between 1 and 90"})
+	}
+	ctx := context.Background()
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT variant, COUNT(*), COUNT(*) FILTER (WHERE total = 0), COALESCE(AVG(total),0), COALESCE(AVG(took_ms),0)
+		FROM search_events WHERE created_at > NOW() - $1 * INTERVAL '1 day'
+		GROUP BY variant ORDER BY variant
+	`, days)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+	stats := []fiber.Map{}
+	for rows.Next() {
+		var variant string
+		var searches, zeroResults int64
+		var avgTotal, avgTook float64
+		rows.Scan(&variant, &searches, &zeroResults, &avgTotal, &avgTook)
+		stats = append(stats, fiber.Map{
+			"variant": variant, "searches": searches, "zero_results": zeroResults,
+			"zero_result_rate": float64(zeroResults) / float64(searches),
+			"avg_total":        avgTotal, "avg_took_ms": avgTook,
+		})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": stats})
+}
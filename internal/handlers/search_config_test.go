@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+
+	"megabuy-go/internal/elasticsearch"
+)
+
+// withSearchVariants serves variants from the config cache for the rest of the test
+func withSearchVariants(t *testing.T, variants map[string]searchVariant) {
+	t.Helper()
+	searchConfigMutex.Lock()
+	searchConfigCache, searchConfigLoadedAt = variants, time.Now().Add(time.Hour)
+	searchConfigMutex.Unlock()
+	t.Cleanup(invalidateSearchConfig)
+}
+
+func TestRankingForVariant(t *testing.T) {
+	h := &Handlers{}
+	boosted := elasticsearch.DefaultRanking()
+	boosted.PopularityWeight = 2
+	withSearchVariants(t, map[string]searchVariant{
+		"default": {Ranking: elasticsearch.DefaultRanking(), Version: 4},
+		"b":       {Ranking: boosted, Version: 2},
+	})
+	tests := []struct {
+		asked, served string
+		version       int
+		known         bool
+	}{
+		{"", "default", 4, true},
+		{"default", "default", 4, true},
+		{"b", "b", 2, true},
+		{"missing", "default", 4, false},
+	}
+	for _, tt := range tests {
+		served, v, known := h.rankingFor(tt.asked)
+		if served != tt.served || v.Version != tt.version || known != tt.known {
+			t.Errorf("rankingFor(%q) = %s v%d %v, want %s v%d %v", tt.asked, served, v.Version, known, tt.served, tt.version, tt.known)
+		}
+	}
+	if _, v, _ := h.rankingFor("b"); v.Ranking.PopularityWeight != 2 {
+		t.Errorf("variant b ranking %+v", v.Ranking)
+	}
+
+	// nothing stored yet: the built-in defaults, as version 0
+	withSearchVariants(t, map[string]searchVariant{})
+	served, v, known := h.rankingFor("b")
+	if served != "default" || v.Version != 0 || known || v.Ranking.Fuzziness != "AUTO" {
+		t.Errorf("without configs: %s %+v %v", served, v, known)
+	}
+}
+
+// TestSetSearchConfigValidation needs no database: bad configs are rejected before it
+func TestSetSearchConfigValidation(t *testing.T) {
+	app := fiber.New()
+	app.Use(recover.New())
+	app.Put("/admin/search-config/:variant", (&Handlers{}).AdminSetSearchConfig)
+	tests := []struct {
+		variant, body, want string
+	}{
+		{"B", `{"field_boosts":{"title":2}}`, "lowercase letters"},
+		{"-b", `{"field_boosts":{"title":2}}`, "lowercase letters"},
+		{strings.Repeat("b", 51), `{"field_boosts":{"title":2}}`, "lowercase letters"},
+		{"b", `{"field_boosts":`, "Invalid request"},
+		{"b", `{}`, "at least one field"},
+		{"b", `{"field_boosts":{"price":2}}`, "unknown field"},
+		{"b", `{"field_boosts":{"title":2},"fuzziness":"5"}`, "fuzziness"},
+		{"b", `{"field_boosts":{"title":2},"in_stock_weight":-1}`, "in_stock_weight"},
+	}
+	for _, tt := range tests {
+		status, body := send(t, app, "PUT", "/admin/search-config/"+tt.variant, tt.body)
+		if status != 400 || !strings.Contains(body, tt.want) {
+			t.Errorf("PUT %s %s: %d %s", tt.variant, tt.body, status, body)
+		}
+	}
+}
+
+func TestSearchConfigVersions(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	invalidateSearchConfig()
+	t.Cleanup(func() {
+		env.db.Pool.Exec(ctx, "DELETE FROM search_config WHERE variant = 'test-1710'")
+		env.db.Pool.Exec(ctx, "DELETE FROM search_events WHERE variant = 'test-1710'")
+		invalidateSearchConfig()
+	})
+	app := fiber.New()
+	app.Put("/admin/search-config/:variant", env.h.AdminSetSearchConfig)
+	app.Delete("/admin/search-config/:variant", env.h.AdminDeleteSearchConfig)
+	app.Get("/admin/search-config/stats", env.h.AdminSearchVariantStats)
+
+	for want := 1; want <= 2; want++ {
+		status, resp := callJSON(t, app, "PUT", "/admin/search-config/test-1710", map[string]any{
+			"field_boosts": map[string]float64{"title": 4, "brand": 2}, "rating_weight": float64(want),
+		})
+		var saved struct {
+			Version int                         `json:"version"`
+			Config  elasticsearch.RankingConfig `json:"config"`
+		}
+		json.Unmarshal(resp.Data, &saved)
+		if status != 200 || saved.Version != want || saved.Config.Fuzziness != "AUTO" {
+			t.Fatalf("save %d: %d %s %+v", want, status, resp.Error, saved)
+		}
+	}
+	// a save is served at once on this instance
+	served, v, known := env.h.rankingFor("test-1710")
+	if served != "test-1710" || !known || v.Version != 2 || v.Ranking.RatingWeight != 2 || len(v.Ranking.FieldBoosts) != 2 {
+		t.Errorf("served %s %+v %v", served, v, known)
+	}
+
+	env.h.logSearchEvent(" Kávovar ", "test-1710", 2, 0, 12)
+	env.h.logSearchEvent("mlynček", "test-1710", 2, 8, 20)
+	var stats []map[string]any
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, resp := callJSON(t, app, "GET", "/admin/search-config/stats", nil)
+		json.Unmarshal(resp.Data, &stats)
+		var found map[string]any
+		for _, s := range stats {
+			if s["variant"] == "test-1710" {
+				found = s
+			}
+		}
+		if found != nil && found["searches"] == float64(2) {
+			if found["zero_results"] != float64(1) || found["zero_result_rate"] != 0.5 || found["avg_total"] != float64(4) {
+				t.Errorf("stats %v", found)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("search events not logged: %v", stats)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if status, resp := callJSON(t, app, "DELETE", "/admin/search-config/default", nil); status != 400 {
+		t.Errorf("deleting the default variant: %d %s", status, resp.Error)
+	}
+	if status, _ := callJSON(t, app, "DELETE", "/admin/search-config/test-1710", nil); status != 200 {
+		t.Errorf("delete: %d", status)
+	}
+	if status, _ := callJSON(t, app, "DELETE", "/admin/search-config/test-1710", nil); status != 404 {
+		t.Errorf("second delete: %d", status)
+	}
+	if served, _, known := env.h.rankingFor("test-1710"); served != "default" || known {
+		t.Errorf("deleted variant still served as %s", served)
+	}
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/settings"
+)
+
+// ========== SEO FILTER URLS ==========
+
+const (
+	// brandFilterSlug prefixes brand segments, e.g. znacka-samsung
+	brandFilterSlug       = "znacka"
+	defaultMaxFacetDepth  = 2
+	maxFilterPathSegments = 10
+)
+
+// facetSettings returns the "filters" setting, which drives crawlable filter URLs
+func (h *Handlers) facetSettings(ctx context.Context) settings.Filters {
+	filters, _ := h.settings.Filters(ctx)
+	if filters.MaxFacetDepth <= 0 {
+		filters.MaxFacetDepth = defaultMaxFacetDepth
+	}
+	return filters
+}
+
+// facetContext holds what a category's filter URLs can refer to, keyed by slug
+type facetContext struct {
+	categorySlug string
+	categoryIDs  []string
+	maxDepth     int
+	attributes   []string                     // filterable attribute names, in configured order
+	attrBySlug   map[string]string            // attribute slug -> name
+	brands       map[string]string            // brand slug -> brand
+	values       map[string]map[string]string // attribute name -> value slug -> value
+}
+
+func (h *Handlers) loadFacetContext(ctx context.Context, categorySlug string) (*facetContext, error) {
+	categoryIDs, _ := h.resolveCategorySubtrees(ctx, []string{categorySlug})
+	if len(categoryIDs) == 0 {
+		return nil, fmt.Errorf("category not found")
+	}
+	filters := h.facetSettings(ctx)
+	fc := &facetContext{
+		categorySlug: categorySlug,
+		categoryIDs:  categoryIDs,
+		maxDepth:     filters.MaxFacetDepth,
+		attrBySlug:   map[string]string{},
+		brands:       map[string]string{},
+		values:       map[string]map[string]string{},
+	}
+	for _, name := range filters.FilterableAttributes {
+		slug := makeSlug(name)
+		if slug == brandFilterSlug || fc.attrBySlug[slug] != "" {
+			continue
+		}
+		fc.attrBySlug[slug] = name
+		fc.attributes = append(fc.attributes, name)
+		fc.values[name] = map[string]string{}
+	}
+
+	rows, err := h.db.ReadPool.Query(ctx, `
+		SELECT DISTINCT brand FROM products
+		WHERE category_id = ANY($1::uuid[]) AND is_active = true AND COALESCE(brand,'') <> ''
+	`, categoryIDs)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var brand string
+		rows.Scan(&brand)
+		fc.brands[makeSlug(brand)] = brand
+	}
+	rows.Close()
+
+	if len(fc.attributes) > 0 {
+		rows, err = h.db.ReadPool.Query(ctx, `
+			SELECT DISTINCT pa.name, pa.value FROM product_attributes pa
+			JOIN products p ON p.id = pa.product_id
+			WHERE p.category_id = ANY($1::uuid[]) AND p.is_active = true AND pa.name = ANY($2)
+		`, categoryIDs, fc.attributes)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var name, value string
+			rows.Scan(&name, &value)
+			fc.values[name][makeSlug(value)] = value
+		}
+		rows.Close()
+	}
+	return fc, nil
+}
+
+// facetSelection is a filter set; values within one attribute are ORed
+type facetSelection struct {
+	Brands     []string            `json:"brands"`
+	Attributes map[string][]string `json:"attributes"`
+}
+
+func (s facetSelection) depth() int {
+	n := len(s.Brands)
+	for _, values := range s.Attributes {
+		n += len(values)
+	}
+	return n
+}
+
+// parsePath turns segments like znacka-samsung/farba-cierna into a selection.
+// Attribute slugs may contain hyphens, so the longest matching prefix wins.
+func (fc *facetContext) parsePath(segments []string) (facetSelection, string) {
+	sel := facetSelection{Attributes: map[string][]string{}}
+	for _, seg := range segments {
+		if valueSlug, ok := strings.CutPrefix(seg, brandFilterSlug+"-"); ok {
+			brand, ok := fc.brands[valueSlug]
+			if !ok {
+				return sel, "Unknown brand in filter: " + seg
+			}
+			if !containsString(sel.Brands, brand) {
+				sel.Brands = append(sel.Brands, brand)
+			}
+			continue
+		}
+
+		name, matched, valueSlug := "", "", ""
+		for attrSlug, attrName := range fc.attrBySlug {
+			if rest, ok := strings.CutPrefix(seg, attrSlug+"-"); ok && len(attrSlug) > len(matched) {
+				name, matched, valueSlug = attrName, attrSlug, rest
+			}
+		}
+		if name == "" {
+			return sel, "Unknown or non-filterable attribute in filter: " + seg
+		}
+		value, ok := fc.values[name][valueSlug]
+		if !ok {
+			return sel, "Unknown value in filter: " + seg
+		}
+		if !containsString(sel.Attributes[name], value) {
+			sel.Attributes[name] = append(sel.Attributes[name], value)
+		}
+	}
+	return sel, ""
+}
+
+// canonicalPath orders segments as brand first, then attributes in configured order,
+// values sorted by slug, so every filter set has exactly one URL
+func (fc *facetContext) canonicalPath(sel facetSelection) string {
+	var segments []string
+	brandSlugs := make([]string, 0, len(sel.Brands))
+	for _, b := range sel.Brands {
+		brandSlugs = append(brandSlugs, makeSlug(b))
+	}
+	sort.Strings(brandSlugs)
+	for _, slug := range brandSlugs {
+		segments = append(segments, brandFilterSlug+"-"+slug)
+	}
+	for _, name := range fc.attributes {
+		valueSlugs := make([]string, 0, len(sel.Attributes[name]))
+		for _, v := range sel.Attributes[name] {
+			valueSlugs = append(valueSlugs, makeSlug(v))
+		}
+		sort.Strings(valueSlugs)
+		for _, slug := range valueSlugs {
+			segments = append(segments, makeSlug(name)+"-"+slug)
+		}
+	}
+	path := "/categories/" + fc.categorySlug
+	if len(segments) > 0 {
+		path += "/f/" + strings.Join(segments, "/")
+	}
+	return path
+}
+
+func (fc *facetContext) seo(sel facetSelection) fiber.Map {
+	depth := sel.depth()
+	return fiber.Map{
+		"canonical_url": fc.canonicalPath(sel),
+		"depth":         depth,
+		"max_depth":     fc.maxDepth,
+		"noindex":       depth > fc.maxDepth,
+	}
+}
+
+// GetCategoryFilterPage serves /categories/:slug/f/<segments> as a filtered category listing
+func (h *Handlers) GetCategoryFilterPage(c *fiber.Ctx) error {
+	page, limit, offset := pageParams(c, 20)
+	priceMode, ok := priceModeParam(c)
+	if !ok {
+		return invalidPriceMode(c)
+	}
+	ctx := context.Background()
+
+	var segments []string
+	for _, seg := range strings.Split(c.Params("*"), "/") {
+		if seg = strings.ToLower(strings.TrimSpace(seg)); seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	if len(segments) > maxFilterPathSegments {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": fmt.Sprintf("At most %d filter segments allowed", maxFilterPathSegments)})
+	}
+
+	fc, err := h.loadFacetContext(ctx, c.Params("slug"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Category not found"})
+	}
+	sel, msg := fc.parsePath(segments)
+	if msg != "" {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": msg})
+	}
+
+	priceMinCol, _ := priceColumns(priceMode)
+	where := "WHERE p.is_active = true AND p.category_id = ANY($1::uuid[])"
+	args := []interface{}{fc.categoryIDs}
+	if len(sel.Brands) > 0 {
+		args = append(args, sel.Brands)
+		where += fmt.Sprintf(" AND p.brand = ANY($%d)", len(args))
+	}
+	for _, name := range fc.attributes {
+		if values := sel.Attributes[name]; len(values) > 0 {
+			args = append(args, name, values)
+			where += fmt.Sprintf(" AND EXISTS (SELECT 1 FROM product_attributes pa WHERE pa.product_id = p.id AND pa.name = $%d AND pa.value = ANY($%d))", len(args)-1, len(args))
+		}
+	}
+
+	policies := h.outOfStockPolicies(ctx)
+	if cond := policies.hideCondition(&args); cond != "" {
+		where += " AND " + cond
+	}
+
+	var total int64
+	h.db.ReadPool.QueryRow(ctx, "SELECT COUNT(*) FROM products p "+where, args...).Scan(&total)
+
+	orderBy := policies.demoteOrder(&args) + listingOrder(c.Query("sort"), priceMinCol)
+	args = append(args, limit, offset)
+	rows, err := h.db.ReadPool.Query(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM products p LEFT JOIN categories c ON p.category_id = c.id
+		%s ORDER BY %s LIMIT $%d OFFSET $%d
+	`, productCardColumns(priceMode), where, orderBy, len(args)-1, len(args)), args...)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	products := scanProductCards(rows)
+	h.attachLabels(ctx, products)
+	addListDisplay(c, products)
+
+	requested := "/categories/" + fc.categorySlug
+	if len(segments) > 0 {
+		requested += "/f/" + strings.Join(segments, "/")
+	}
+	seo := fc.seo(sel)
+	// non-canonical spellings of the same filter set should redirect to canonical_url
+	seo["is_canonical"] = seo["canonical_url"] == requested
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{
+		"items":      products,
+		"filters":    sel,
+		"seo":        seo,
+		"price_mode": priceMode,
+	}, page, limit, total)})
+}
+
+// GetCategoryFilterPath builds the canonical filter URL for ?brand=Samsung&filter=Farba:čierna
+func (h *Handlers) GetCategoryFilterPath(c *fiber.Ctx) error {
+	ctx := context.Background()
+	fc, err := h.loadFacetContext(ctx, c.Params("slug"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Category not found"})
+	}
+
+	var segments []string
+	for _, brand := range splitList(c.Query("brand")) {
+		segments = append(segments, brandFilterSlug+"-"+makeSlug(brand))
+	}
+	for _, raw := range c.Context().QueryArgs().PeekMulti("filter") {
+		name, value, ok := strings.Cut(string(raw), ":")
+		if !ok || strings.TrimSpace(value) == "" {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "filter must look like Name:value"})
+		}
+		segments = append(segments, makeSlug(name)+"-"+makeSlug(value))
+	}
+	if len(segments) > maxFilterPathSegments {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": fmt.Sprintf("At most %d filters allowed", maxFilterPathSegments)})
+	}
+
+	sel, msg := fc.parsePath(segments)
+	if msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": fc.seo(sel)})
+}
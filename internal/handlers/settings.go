@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/settings"
+)
+
+// ========== SETTINGS ==========
+
+// registerSettingChecks adds the rules of settings owned by handlers to the store
+// and drops derived caches when a setting changes
+func (h *Handlers) registerSettingChecks() {
+	h.settings.AddCheck(settings.KeyListingSort, func(raw json.RawMessage) string {
+		var s ListingSortSettings
+		json.Unmarshal(raw, &s)
+		if !containsString(listingSorts, s.DefaultSort) {
+			return "default_sort must be one of " + strings.Join(listingSorts, ", ")
+		}
+		return ""
+	})
+	h.settings.AddCheck(settings.KeyImageAlt, func(raw json.RawMessage) string {
+		var s ImageAltSettings
+		json.Unmarshal(raw, &s)
+		return s.validate()
+	})
+	h.settings.AddCheck(settings.KeyImportBlackout, func(raw json.RawMessage) string {
+		var b ImportBlackout
+		json.Unmarshal(raw, &b)
+		return b.validate()
+	})
+	h.settings.AddCheck(settings.KeyCompletenessWeights, func(raw json.RawMessage) string {
+		w := defaultCompletenessWeights()
+		json.Unmarshal(raw, &w)
+		return w.validate()
+	})
+	h.settings.OnChange(settings.KeyListingSort, invalidateListingSorts)
+}
+
+// settingsError answers a failed settings write: 400 for unknown keys and invalid
+// values, 500 otherwise
+func settingsError(c *fiber.Ctx, keys []string, err error) error {
+	var verr *settings.ValidationError
+	switch {
+	case errors.Is(err, settings.ErrUnknownKey):
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": err.Error(), "known_keys": keys})
+	case errors.As(err, &verr):
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": verr.Error(), "field": verr.Path})
+	}
+	return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+}
+
+// AdminGetSettings lists every known setting with its value, or default while unset
+func (h *Handlers) AdminGetSettings(c *fiber.Ctx) error {
+	entries, err := h.settings.All(context.Background())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": entries})
+}
+
+func (h *Handlers) AdminGetSetting(c *fiber.Ctx) error {
+	entry, err := h.settings.Entry(context.Background(), c.Params("key"))
+	if errors.Is(err, settings.ErrUnknownKey) {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": err.Error(), "known_keys": h.settings.Keys()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": entry})
+}
+
+// AdminSetSettings writes several settings given as {"key": value, ...}. Every value is
+// validated before any is written, so one bad key leaves all of them unchanged.
+func (h *Handlers) AdminSetSettings(c *fiber.Ctx) error {
+	var input map[string]json.RawMessage
+	if err := json.Unmarshal(c.Body(), &input); err != nil || len(input) == 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Body must be an object of setting keys and values"})
+	}
+	keys := make([]string, 0, len(input))
+	for key, value := range input {
+		if err := h.settings.Validate(key, value); err != nil {
+			return settingsError(c, h.settings.Keys(), err)
+		}
+		keys = append(keys, key)
+	}
+
+	ctx := context.Background()
+	var entries []settings.Entry
+	for _, key := range h.settings.Keys() {
+		value, ok := input[key]
+		if !ok {
+			continue
+		}
+		entry, err := h.settings.Set(ctx, key, value, auditActor(c))
+		if err != nil {
+			return settingsError(c, h.settings.Keys(), err)
+		}
+		entries = append(entries, entry)
+	}
+	h.audit(ctx, c, "settings.update", "settings", "", fiber.Map{"keys": keys})
+	return c.JSON(fiber.Map{"success": true, "data": entries})
+}
+
+// AdminSetSetting replaces one setting with the request body
+func (h *Handlers) AdminSetSetting(c *fiber.Ctx) error {
+	key := c.Params("key")
+	ctx := context.Background()
+	entry, err := h.settings.Set(ctx, key, json.RawMessage(c.Body()), auditActor(c))
+	if err != nil {
+		return settingsError(c, h.settings.Keys(), err)
+	}
+	h.audit(ctx, c, "settings.update", "settings", "", fiber.Map{"keys": []string{key}})
+	return c.JSON(fiber.Map{"success": true, "data": entry})
+}
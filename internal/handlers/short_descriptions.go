@@ -0,0 +1,482 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== GENERATED SHORT DESCRIPTIONS ==========
+
+const (
+	maxShortDescription         = 160
+	maxShortDescriptionTemplate = 500
+	shortDescriptionBatch       = 500
+	shortDescriptionTemplateTTL = 30 * time.Second
+)
+
+// shortDescriptionPlaceholder matches an attribute name in braces, e.g. {Uhlopriečka}
+var shortDescriptionPlaceholder = regexp.MustCompile(`\{([^{}]+)\}`)
+
+func validateShortDescriptionTemplate(template string) string {
+	if utf8.RuneCountInString(template) > maxShortDescriptionTemplate {
+		return "template must be at most " + strconv.Itoa(maxShortDescriptionTemplate) + " characters"
+	}
+	if template != "" && !shortDescriptionPlaceholder.MatchString(template) {
+		return "template must name at least one attribute, e.g. {RAM}"
+	}
+	return ""
+}
+
+// renderShortDescription fills a template with attribute values, matching names case
+// insensitively. The text between two placeholders only separates values that are
+// present, so "{A}, {B}, {C}" without B renders "a, c"; without any value it renders "".
+func renderShortDescription(template string, attrs map[string]string) string {
+	locs := shortDescriptionPlaceholder.FindAllStringSubmatchIndex(template, -1)
+	if len(locs) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	end := 0
+	for _, loc := range locs {
+		separator := template[end:loc[0]]
+		end = loc[1]
+		value := attrs[attributeKey(template[loc[2]:loc[3]])]
+		if value == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(separator)
+		}
+		b.WriteString(value)
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return shortenShortDescription(template[:locs[0][0]] + b.String() + template[end:])
+}
+
+func attributeKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// shortenShortDescription collapses whitespace and keeps the text within
+// maxShortDescription runes, cutting at a word where possible
+func shortenShortDescription(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= maxShortDescription {
+		return s
+	}
+	cut := string([]rune(s)[:maxShortDescription-1])
+	if i := strings.LastIndex(cut, " "); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:-/|") + "…"
+}
+
+// shortDescriptionAttributes maps attribute names to values; a name repeated with
+// several values lists them all
+func shortDescriptionAttributes(names, values []string) map[string]string {
+	attrs := make(map[string]string, len(names))
+	for i, name := range names {
+		key := attributeKey(name)
+		if attrs[key] != "" {
+			attrs[key] += " / " + values[i]
+		} else {
+			attrs[key] = values[i]
+		}
+	}
+	return attrs
+}
+
+// missingPlaceholders lists the placeholders of a template without a value
+func missingPlaceholders(template string, attrs map[string]string) []string {
+	missing := []string{}
+	for _, m := range shortDescriptionPlaceholder.FindAllStringSubmatch(template, -1) {
+		if attrs[attributeKey(m[1])] == "" {
+			missing = append(missing, m[1])
+		}
+	}
+	return missing
+}
+
+// shortDescriptionTemplates holds the templates of the category tree
+type shortDescriptionTemplates struct {
+	own, parents map[string]string
+	// categories lists every category with an own or inherited template
+	categories []string
+}
+
+var (
+	shortDescriptionTemplateMutex    sync.RWMutex
+	shortDescriptionTemplateCache    *shortDescriptionTemplates
+	shortDescriptionTemplateLoadedAt time.Time
+)
+
+func invalidateShortDescriptionTemplates() {
+	shortDescriptionTemplateMutex.Lock()
+	shortDescriptionTemplateCache = nil
+	shortDescriptionTemplateMutex.Unlock()
+}
+
+func (h *Handlers) shortDescriptionTemplates(ctx context.Context) *shortDescriptionTemplates {
+	shortDescriptionTemplateMutex.RLock()
+	cached, fresh := shortDescriptionTemplateCache, time.Since(shortDescriptionTemplateLoadedAt) < shortDescriptionTemplateTTL
+	shortDescriptionTemplateMutex.RUnlock()
+	if cached != nil && fresh {
+		return cached
+	}
+
+	t := &shortDescriptionTemplates{own: map[string]string{}, parents: map[string]string{}}
+	rows, err := h.db.Pool.Query(ctx, "SELECT id::text, COALESCE(parent_id::text,''), COALESCE(short_description_template,'') FROM categories")
+	if err != nil {
+		if cached != nil {
+			return cached
+		}
+		return t
+	}
+	var ids []string
+	for rows.Next() {
+		var id, parentID, template string
+		rows.Scan(&id, &parentID, &template)
+		t.parents[id] = parentID
+		if template != "" {
+			t.own[id] = template
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	for _, id := range ids {
+		if template, _ := t.forCategory(id); template != "" {
+			t.categories = append(t.categories, id)
+		}
+	}
+
+	shortDescriptionTemplateMutex.Lock()
+	shortDescriptionTemplateCache, shortDescriptionTemplateLoadedAt = t, time.Now()
+	shortDescriptionTemplateMutex.Unlock()
+	return t
+}
+
+// forCategory returns the template of the category or its nearest ancestor with one,
+// and the category it comes from
+func (t *shortDescriptionTemplates) forCategory(categoryID string) (string, string) {
+	seen := map[string]bool{}
+	for id := categoryID; id != "" && !seen[id]; id = t.parents[id] {
+		if template := t.own[id]; template != "" {
+			return template, id
+		}
+		seen[id] = true
+	}
+	return "", ""
+}
+
+// composeShortDescriptions writes generated short descriptions for up to limit
+// products matching where, ordered by ID. Locked texts and texts that did not come from
+// the generator (admin or feed ones) are kept, as are products of categories without a
+// template. It returns the IDs it looked at and those whose text changed.
+func (h *Handlers) composeShortDescriptions(ctx context.Context, where *sqlWhere, limit int) (seen, changed []string, err error) {
+	templates := h.shortDescriptionTemplates(ctx)
+	if len(templates.categories) == 0 {
+		return nil, nil, nil
+	}
+	where = where.with("p.category_id = ANY(?::uuid[])", templates.categories).
+		add("NOT p.short_description_locked AND (COALESCE(p.short_description,'') = '' OR p.short_description_generated)")
+	limitArg := where.arg(limit)
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT p.id::text, p.category_id::text,
+		       COALESCE(array_agg(pa.name ORDER BY pa.position) FILTER (WHERE pa.name IS NOT NULL), '{}'),
+		       COALESCE(array_agg(pa.value ORDER BY pa.position) FILTER (WHERE pa.name IS NOT NULL), '{}')
+		FROM products p LEFT JOIN product_attributes pa ON pa.product_id = p.id
+		`+where.clause()+`
+		GROUP BY p.id ORDER BY p.id LIMIT `+limitArg, where.params()...)
+	if err != nil {
+		return nil, nil, err
+	}
+	var texts []string
+	for rows.Next() {
+		var id, categoryID string
+		var names, values []string
+		rows.Scan(&id, &categoryID, &names, &values)
+		template, _ := templates.forCategory(categoryID)
+		seen = append(seen, id)
+		texts = append(texts, renderShortDescription(template, shortDescriptionAttributes(names, values)))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(seen) == 0 {
+		return nil, nil, nil
+	}
+
+	// the conditions are checked again so an admin edit in between wins
+	rows, err = h.db.Pool.Query(ctx, `
+		UPDATE products p SET short_description = NULLIF(v.text, ''), short_description_generated = v.text <> ''
+		FROM unnest($1::uuid[], $2::text[]) AS v(id, text)
+		WHERE p.id = v.id AND NOT p.short_description_locked
+		  AND (COALESCE(p.short_description,'') = '' OR p.short_description_generated)
+		  AND COALESCE(p.short_description,'') <> v.text
+		RETURNING p.id::text
+	`, seen, texts)
+	if err != nil {
+		return seen, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		changed = append(changed, id)
+	}
+	return seen, changed, rows.Err()
+}
+
+// generateShortDescription composes the short description of one product, as imports
+// do after saving its attributes
+func (h *Handlers) generateShortDescription(ctx context.Context, productID string) {
+	if _, _, err := h.composeShortDescriptions(ctx, newWhere().add("p.id = ?::uuid", productID), 1); err != nil {
+		log.Printf("Short description of %s: %v", productID, err)
+	}
+}
+
+func (h *Handlers) GetShortDescriptionTemplate(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx := context.Background()
+	var template string
+	if err := h.db.Pool.QueryRow(ctx, "SELECT COALESCE(short_description_template,'') FROM categories WHERE id = $1::uuid", id).Scan(&template); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Category not found"})
+	}
+	effective, from := h.shortDescriptionTemplates(ctx).forCategory(id)
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"template": template, "effective_template": effective, "inherited_from": inheritedFrom(id, from)}})
+}
+
+func inheritedFrom(categoryID, sourceID string) string {
+	if sourceID == categoryID {
+		return ""
+	}
+	return sourceID
+}
+
+// SetShortDescriptionTemplate changes the template of a category; an empty template
+// makes it use its parent's. Existing texts change on the next import or generate run.
+func (h *Handlers) SetShortDescriptionTemplate(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var input struct {
+		Template string `json:"template"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	input.Template = strings.TrimSpace(input.Template)
+	if msg := validateShortDescriptionTemplate(input.Template); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+
+	ctx := context.Background()
+	tag, err := h.db.Pool.Exec(ctx, "UPDATE categories SET short_description_template = NULLIF($2,''), updated_at = NOW() WHERE id = $1::uuid", id, input.Template)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Category not found"})
+	}
+	h.audit(ctx, c, "short_description_template.update", "category", id, fiber.Map{"template": input.Template})
+	invalidateShortDescriptionTemplates()
+
+	effective, from := h.shortDescriptionTemplates(ctx).forCategory(id)
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"template": input.Template, "effective_template": effective, "inherited_from": inheritedFrom(id, from)}})
+}
+
+// PreviewShortDescription applies a template to a sample product of the category
+// subtree without saving anything. The body may carry the template to try (default:
+// the category's effective one) and a product_id (default: the active product with
+// the most attributes). The category's most common attributes are listed as
+// placeholder suggestions.
+func (h *Handlers) PreviewShortDescription(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var input struct {
+		Template  string `json:"template"`
+		ProductID string `json:"product_id"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+		}
+	}
+	if input.ProductID != "" && !isUUID(input.ProductID) {
+		return invalidUUIDField(c, "product_id")
+	}
+	ctx := context.Background()
+	var exists bool
+	h.db.Pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1::uuid)", id).Scan(&exists)
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Category not found"})
+	}
+	template := strings.TrimSpace(input.Template)
+	if template == "" {
+		template, _ = h.shortDescriptionTemplates(ctx).forCategory(id)
+	}
+	if template == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "The category has no template; send one to preview"})
+	}
+	if msg := validateShortDescriptionTemplate(template); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+
+	where := newWhere()
+	if input.ProductID != "" {
+		where.add("p.id = ?::uuid", input.ProductID)
+	} else {
+		where.add("p.is_active = true").add(subtreeCategoryCondition, id)
+	}
+	var productID, title, current string
+	var locked, generated bool
+	var names, values []string
+	err := h.db.Pool.QueryRow(ctx, `
+		SELECT p.id::text, p.title, COALESCE(p.short_description,''), p.short_description_locked, p.short_description_generated,
+		       COALESCE(array_agg(pa.name ORDER BY pa.position) FILTER (WHERE pa.name IS NOT NULL), '{}'),
+		       COALESCE(array_agg(pa.value ORDER BY pa.position) FILTER (WHERE pa.name IS NOT NULL), '{}')
+		FROM products p LEFT JOIN product_attributes pa ON pa.product_id = p.id
+		`+where.clause()+`
+		GROUP BY p.id ORDER BY COUNT(pa.name) DESC, p.id LIMIT 1
+	`, where.params()...).Scan(&productID, &title, &current, &locked, &generated, &names, &values)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "No product to preview with"})
+	}
+
+	attrs := shortDescriptionAttributes(names, values)
+	text := renderShortDescription(template, attrs)
+	suggestions := []string{}
+	if stats, _, err := h.categoryAttributeStats(ctx, id); err == nil {
+		for _, s := range stats {
+			if len(suggestions) == 10 {
+				break
+			}
+			suggestions = append(suggestions, s.Name)
+		}
+	}
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"template":          template,
+		"short_description": text,
+		"length":            utf8.RuneCountInString(text),
+		"missing":           missingPlaceholders(template, attrs),
+		"product": fiber.Map{
+			"id": productID, "title": title, "short_description": current,
+			"short_description_locked": locked, "short_description_generated": generated,
+		},
+		"suggested_attributes": suggestions,
+	}})
+}
+
+type ShortDescriptionRunProgress struct {
+	Status     string     `json:"status"`
+	CategoryID string     `json:"category_id,omitempty"`
+	Total      int64      `json:"total"`
+	Processed  int64      `json:"processed"`
+	Changed    int64      `json:"changed"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+var (
+	shortDescriptionRun      *ShortDescriptionRunProgress
+	shortDescriptionRunMutex sync.Mutex
+)
+
+// StartShortDescriptionRun generates the short descriptions of existing products in the
+// background; ?category_id= limits it to a category subtree
+func (h *Handlers) StartShortDescriptionRun(c *fiber.Ctx) error {
+	categoryID := c.Query("category_id")
+	if categoryID != "" && !isUUID(categoryID) {
+		return invalidUUIDField(c, "category_id")
+	}
+	ctx := context.Background()
+	templates := h.shortDescriptionTemplates(ctx)
+	if len(templates.categories) == 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "No category has a short description template"})
+	}
+
+	shortDescriptionRunMutex.Lock()
+	if shortDescriptionRun != nil && shortDescriptionRun.Status == "running" {
+		shortDescriptionRunMutex.Unlock()
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "Generation already running"})
+	}
+	progress := &ShortDescriptionRunProgress{Status: "running", CategoryID: categoryID, StartedAt: time.Now()}
+	shortDescriptionRun = progress
+	shortDescriptionRunMutex.Unlock()
+
+	scope := shortDescriptionScope(categoryID)
+	count := scope.with("p.category_id = ANY(?::uuid[])", templates.categories).
+		add("NOT p.short_description_locked AND (COALESCE(p.short_description,'') = '' OR p.short_description_generated)")
+	var total int64
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM products p "+count.clause(), count.params()...).Scan(&total)
+	shortDescriptionRunMutex.Lock()
+	progress.Total = total
+	shortDescriptionRunMutex.Unlock()
+
+	h.audit(ctx, c, "short_descriptions.generate", "product", "", fiber.Map{"category_id": categoryID, "total": total})
+	go h.runShortDescriptions(progress, scope)
+	return c.Status(202).JSON(fiber.Map{"success": true, "data": progress.snapshot()})
+}
+
+func shortDescriptionScope(categoryID string) *sqlWhere {
+	if categoryID == "" {
+		return newWhere()
+	}
+	return newWhere().add(subtreeCategoryCondition, categoryID)
+}
+
+// runShortDescriptions walks products by ID in batches so it never holds long locks
+func (h *Handlers) runShortDescriptions(progress *ShortDescriptionRunProgress, scope *sqlWhere) {
+	ctx := context.Background()
+	status, errMsg := "completed", ""
+	after := "00000000-0000-0000-0000-000000000000"
+	for {
+		seen, changed, err := h.composeShortDescriptions(ctx, scope.with("p.id > ?::uuid", after), shortDescriptionBatch)
+		if err != nil {
+			status, errMsg = "failed", err.Error()
+			break
+		}
+		if len(seen) == 0 {
+			break
+		}
+		h.queueESSync(changed...)
+		after = seen[len(seen)-1]
+
+		shortDescriptionRunMutex.Lock()
+		progress.Processed += int64(len(seen))
+		progress.Changed += int64(len(changed))
+		shortDescriptionRunMutex.Unlock()
+	}
+
+	now := time.Now()
+	shortDescriptionRunMutex.Lock()
+	progress.Status, progress.Error, progress.FinishedAt = status, errMsg, &now
+	processed, changed := progress.Processed, progress.Changed
+	shortDescriptionRunMutex.Unlock()
+	log.Printf("Short description generation %s: %d products, %d changed", status, processed, changed)
+}
+
+func (p *ShortDescriptionRunProgress) snapshot() ShortDescriptionRunProgress {
+	shortDescriptionRunMutex.Lock()
+	defer shortDescriptionRunMutex.Unlock()
+	return *p
+}
+
+func (h *Handlers) GetShortDescriptionRun(c *fiber.Ctx) error {
+	shortDescriptionRunMutex.Lock()
+	progress := shortDescriptionRun
+	shortDescriptionRunMutex.Unlock()
+	if progress == nil {
+		return c.JSON(fiber.Map{"success": true, "data": nil})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": progress.snapshot()})
+}
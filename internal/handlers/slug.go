@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const maxSlugLength = 80
+
+// slugTranslit covers letters that do not decompose to ASCII (Cyrillic, Greek, a few Latin ones)
+var slugTranslit = map[rune]string{
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'ґ': "g", 'д': "d", 'е': "e", 'ё': "e", 'є': "ye",
+	'ж': "zh", 'з': "z", 'и': "i", 'і': "i", 'ї': "yi", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ў': "u", 'ф': "f",
+	'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e",
+	'ю': "yu", 'я': "ya", 'ђ': "dj", 'ј': "j", 'љ': "lj", 'њ': "nj", 'ћ': "c", 'џ': "dz",
+	// Greek (accents are stripped by NFD first)
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i",
+	'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s",
+	'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	// Latin letters without a decomposition
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ł': "l", 'þ': "th", 'ð': "d", 'ı': "i",
+}
+
+// foldText lowercases s and strips diacritics so "Kabeláž" matches "kabelaz"
+func foldText(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	r, _, _ := transform.String(t, strings.ToLower(s))
+	return r
+}
+
+// makeSlug builds a URL slug of at most 80 characters. Input that yields no
+// ASCII letters or digits (emoji, unsupported scripts, punctuation) gets a short hash.
+func makeSlug(s string) string {
+	r := foldText(s)
+
+	var b strings.Builder
+	for _, c := range r {
+		switch {
+		case c >= 'a' && c <= 'z' || c >= '0' && c <= '9':
+			b.WriteRune(c)
+		case c == ' ' || c == '-' || c == '_' || c == '/' || c == '.' || c == ',':
+			b.WriteByte('-')
+		default:
+			if tr, ok := slugTranslit[c]; ok {
+				b.WriteString(tr)
+			} else if unicode.IsSpace(c) {
+				b.WriteByte('-')
+			}
+		}
+	}
+	result := b.String()
+	for strings.Contains(result, "--") {
+		result = strings.ReplaceAll(result, "--", "-")
+	}
+	result = strings.Trim(result, "-")
+
+	if len(result) > maxSlugLength {
+		result = result[:maxSlugLength]
+		if i := strings.LastIndex(result, "-"); i > 0 {
+			result = result[:i]
+		}
+		result = strings.Trim(result, "-")
+	}
+	if result == "" {
+		sum := sha1.Sum([]byte(s))
+		result = "p-" + hex.EncodeToString(sum[:4])
+	}
+	return result
+}
+
+// Slug exposes the catalog slug rules to commands outside the API, such as the seeder
+func Slug(s string) string {
+	return makeSlug(s)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+var hashSlugPattern = regexp.MustCompile(`^p-[0-9a-f]{8}$`)
+
+func TestMakeSlug(t *testing.T) {
+	tests := []struct {
+		title, want string
+	}{
+		{"Kávovar DeLonghi Magnifica S ECAM 22.110.B", "kavovar-delonghi-magnifica-s-ecam-22-110-b"},
+		{"Žehlička Philips – GC 3920/20", "zehlicka-philips-gc-3920-20"},
+		{"Ľadnička Gorenje, 60 cm", "ladnicka-gorenje-60-cm"},
+		{"Смартфон Xiaomi Redmi", "smartfon-xiaomi-redmi"},
+		{"Щітка для волосся", "shchitka-dlya-volossya"},
+		{"Φορητός υπολογιστής", "foritos-ypologistis"},
+		{"Straße & Größe", "strasse-grosse"},
+		{"Smørrebrød Łódź Æble", "smorrebrod-lodz-aeble"},
+		{"İstanbul ılık", "istanbul-ilik"},
+		{"Café crème", "cafe-creme"},
+		{"  --Hello__World..  ", "hello-world"},
+		{"Tab\tand\nnewline nbsp", "tab-and-newline-nbsp"},
+		{"100% bio 🍎🍏", "100-bio"},
+		{"<script>alert(1)</script>", "scriptalert1-script"},
+		{"'; DROP TABLE products; --", "drop-table-products"},
+		{"../../etc/passwd", "etc-passwd"},
+		{"a-b--c---d", "a-b-c-d"},
+	}
+	for _, tt := range tests {
+		if got := makeSlug(tt.title); got != tt.want {
+			t.Errorf("makeSlug(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestMakeSlugFallsBackToHash(t *testing.T) {
+	seen := map[string]string{}
+	for _, title := range []string{"", "🍎🍏", "日本語の製品", "!!!", "—", "Ｆｕｌｌｗｉｄｔｈ", "​‍"} {
+		got := makeSlug(title)
+		if !hashSlugPattern.MatchString(got) {
+			t.Errorf("makeSlug(%q) = %q, want a p-<hash> slug", title, got)
+		}
+		if makeSlug(title) != got {
+			t.Errorf("makeSlug(%q) is not deterministic", title)
+		}
+		if other, ok := seen[got]; ok {
+			t.Errorf("%q and %q share the slug %s", title, other, got)
+		}
+		seen[got] = title
+	}
+}
+
+func TestMakeSlugLength(t *testing.T) {
+	tests := []struct {
+		title, prefix string
+	}{
+		{strings.Repeat("dlhý názov ", 20), "dlhy-nazov-dlhy-nazov"},
+		{strings.Repeat("a", 200), strings.Repeat("a", maxSlugLength)},
+		{strings.Repeat("ш", 200), "shshsh"},
+		{"Kávovar " + strings.Repeat("x", 90), "kavovar"},
+		{strings.Repeat("ab-", 60), "ab-ab-ab"},
+	}
+	for _, tt := range tests {
+		got := makeSlug(tt.title)
+		if len(got) > maxSlugLength || !slugPattern.MatchString(got) || !strings.HasPrefix(got, tt.prefix) {
+			t.Errorf("makeSlug(%.20q…) = %q (%d characters)", tt.title, got, len(got))
+		}
+	}
+	// a cut never leaves half a word when the slug has words to cut at
+	if got := makeSlug(strings.Repeat("word ", 30)); strings.HasSuffix(got, "-wor") || !strings.HasSuffix(got, "word") {
+		t.Errorf("cut mid-word: %q", got)
+	}
+}
+
+func TestSlugShape(t *testing.T) {
+	titles := []string{
+		"Kávovar", "Smart TV 55\" 4K", "USB-C ↔ HDMI", "C++ / C#", "50/50", "-", "a", "Ä", "ß",
+		"Tričko (XL) – čierne", "Мобильный телефон 5G", "O'Neill", "foo\x00bar", "\xff\xfe",
+	}
+	for _, title := range titles {
+		got := makeSlug(title)
+		if !slugPattern.MatchString(got) && !hashSlugPattern.MatchString(got) {
+			t.Errorf("makeSlug(%q) = %q", title, got)
+		}
+		if Slug(title) != got {
+			t.Errorf("Slug(%q) differs from makeSlug", title)
+		}
+	}
+}
@@ -0,0 +1,952 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ========== CATALOG SNAPSHOTS ==========
+
+const (
+	snapshotFormatVersion = 1
+	// snapshotRestoreBatch products, with their child rows, are restored per transaction
+	snapshotRestoreBatch = 200
+	maxSnapshotName      = 255
+)
+
+// snapshotChildTables are saved with each product. A restore replaces the product's
+// rows in them with the saved ones.
+var snapshotChildTables = []string{"product_images", "product_attributes", "product_media", "offers", "product_offers"}
+
+// snapshotDir holds the snapshot files: SNAPSHOT_DIR, or ./snapshots. It is kept
+// apart from ./uploads, which is served publicly.
+func snapshotDir() string {
+	if dir := os.Getenv("SNAPSHOT_DIR"); dir != "" {
+		return dir
+	}
+	return "./snapshots"
+}
+
+// snapshotHeader is the first line of a snapshot file. Columns lists the saved columns
+// per table; a restore writes only those, so columns added since keep their values.
+type snapshotHeader struct {
+	Version   int                 `json:"version"`
+	CreatedAt time.Time           `json:"created_at"`
+	Columns   map[string][]string `json:"columns"`
+}
+
+// snapshotLine is one of the following lines: a category, or a product with its rows
+// from snapshotChildTables. Categories come first.
+type snapshotLine struct {
+	Category json.RawMessage              `json:"category,omitempty"`
+	Product  json.RawMessage              `json:"product,omitempty"`
+	Children map[string][]json.RawMessage `json:"children,omitempty"`
+}
+
+// snapshotRef holds the fields of a saved category or product a restore plans with
+type snapshotRef struct {
+	ID         string  `json:"id"`
+	ParentID   *string `json:"parent_id"`
+	CategoryID *string `json:"category_id"`
+	FeedID     *string `json:"feed_id"`
+}
+
+type CatalogSnapshot struct {
+	ID             string          `json:"id"`
+	Name           string          `json:"name"`
+	Status         string          `json:"status"`
+	SizeBytes      int64           `json:"size_bytes"`
+	Counts         json.RawMessage `json:"counts"`
+	Error          string          `json:"error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	CompletedAt    *time.Time      `json:"completed_at,omitempty"`
+	LastRestoredAt *time.Time      `json:"last_restored_at,omitempty"`
+}
+
+// AdminListSnapshots lists snapshots, newest first, with their file sizes and row counts
+func (h *Handlers) AdminListSnapshots(c *fiber.Ctx) error {
+	page, limit, offset := adminPageParams(c, 50)
+	rows, err := h.db.Pool.Query(context.Background(), `
+		SELECT id::text, name, status, size_bytes, counts::text, COALESCE(error,''), created_at, completed_at, last_restored_at,
+		       COUNT(*) OVER (), SUM(size_bytes) OVER ()
+		FROM catalog_snapshots ORDER BY created_at DESC LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+
+	var total, totalSize int64
+	items := []CatalogSnapshot{}
+	for rows.Next() {
+		var s CatalogSnapshot
+		var counts string
+		rows.Scan(&s.ID, &s.Name, &s.Status, &s.SizeBytes, &counts, &s.Error, &s.CreatedAt, &s.CompletedAt, &s.LastRestoredAt, &total, &totalSize)
+		s.Counts = json.RawMessage(counts)
+		items = append(items, s)
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{"total_size_bytes": totalSize, "items": items}, page, limit, total)})
+}
+
+// AdminCreateSnapshot starts writing a snapshot of the catalog in the background; the
+// list shows it as ready once the file is complete
+func (h *Handlers) AdminCreateSnapshot(c *fiber.Ctx) error {
+	var input struct {
+		Name string `json:"name"`
+	}
+	c.BodyParser(&input)
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = "Snapshot " + time.Now().Format("2006-01-02 15:04")
+	}
+	if utf8.RuneCountInString(name) > maxSnapshotName {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": fmt.Sprintf("name must be at most %d characters", maxSnapshotName)})
+	}
+	if err := os.MkdirAll(snapshotDir(), 0o750); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	ctx := context.Background()
+	s := CatalogSnapshot{ID: uuid.New().String(), Name: name, Status: "creating", Counts: json.RawMessage("{}")}
+	file := s.ID + ".jsonl.gz"
+	if err := h.db.Pool.QueryRow(ctx, `
+		INSERT INTO catalog_snapshots (id, name, file) VALUES ($1::uuid, $2, $3) RETURNING created_at
+	`, s.ID, name, file).Scan(&s.CreatedAt); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	h.audit(ctx, c, "snapshot.create", "catalog_snapshot", s.ID, fiber.Map{"name": name})
+
+	go h.writeSnapshot(s.ID, filepath.Join(snapshotDir(), file))
+	return c.Status(202).JSON(fiber.Map{"success": true, "data": s})
+}
+
+func (h *Handlers) writeSnapshot(id, path string) {
+	ctx := context.Background()
+	start := time.Now()
+	counts, err := h.exportSnapshot(ctx, path)
+	if err != nil {
+		os.Remove(path)
+		log.Printf("Snapshot %s failed: %v", id, err)
+		h.db.Pool.Exec(ctx, "UPDATE catalog_snapshots SET status = 'failed', error = $2, completed_at = NOW() WHERE id = $1::uuid", id, err.Error())
+		return
+	}
+	var size int64
+	if fi, err := os.Stat(path); err == nil {
+		size = fi.Size()
+	}
+	countsJSON, _ := json.Marshal(counts)
+	h.db.Pool.Exec(ctx, `
+		UPDATE catalog_snapshots SET status = 'ready', size_bytes = $2, counts = $3::jsonb, completed_at = NOW() WHERE id = $1::uuid
+	`, id, size, string(countsJSON))
+	log.Printf("Snapshot %s ready: %d products, %d bytes in %s", id, counts["products"], size, time.Since(start).Round(time.Second))
+}
+
+// exportSnapshot writes the catalog to path as gzip JSON lines and returns row counts
+// per table. A read-only repeatable-read transaction keeps the export consistent while
+// imports continue.
+func (h *Handlers) exportSnapshot(ctx context.Context, path string) (map[string]int64, error) {
+	tables := append([]string{"categories", "products"}, snapshotChildTables...)
+	header := snapshotHeader{Version: snapshotFormatVersion, CreatedAt: time.Now(), Columns: map[string][]string{}}
+	for _, table := range tables {
+		cols, err := h.tableColumns(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+		header.Columns[table] = cols
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	w := bufio.NewWriterSize(gz, 64*1024)
+
+	tx, err := h.db.Pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	counts := map[string]int64{}
+	for _, table := range tables {
+		var n int64
+		if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM "+table).Scan(&n); err != nil {
+			return nil, err
+		}
+		counts[table] = n
+	}
+
+	line, _ := json.Marshal(header)
+	w.Write(line)
+	w.WriteByte('\n')
+	if err := writeSnapshotLines(ctx, tx, w, "SELECT json_build_object('category', row_to_json(c))::text FROM categories c ORDER BY c.id"); err != nil {
+		return nil, err
+	}
+	children := make([]string, len(snapshotChildTables))
+	for i, table := range snapshotChildTables {
+		children[i] = fmt.Sprintf("'%s', COALESCE((SELECT json_agg(x ORDER BY x.id) FROM %s x WHERE x.product_id = p.id), '[]')", table, table)
+	}
+	if err := writeSnapshotLines(ctx, tx, w, `
+		SELECT json_build_object('product', row_to_json(p), 'children', json_build_object(`+strings.Join(children, ", ")+`))::text
+		FROM products p ORDER BY p.id
+	`); err != nil {
+		return nil, err
+	}
+
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return counts, f.Sync()
+}
+
+// writeSnapshotLines streams the single text column of query to w, one row per line
+func writeSnapshotLines(ctx context.Context, tx pgx.Tx, w *bufio.Writer, query string) error {
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line []byte
+		if err := rows.Scan(&line); err != nil {
+			return err
+		}
+		w.Write(line)
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// tableColumns lists the stored (non-generated) columns of a table
+func (h *Handlers) tableColumns(ctx context.Context, table string) ([]string, error) {
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1 AND is_generated = 'NEVER'
+		ORDER BY ordinal_position
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cols []string
+	for rows.Next() {
+		var col string
+		rows.Scan(&col)
+		cols = append(cols, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("table %s not found", table)
+	}
+	return cols, nil
+}
+
+// ========== SNAPSHOT RESTORE ==========
+
+type SnapshotRestoreProgress struct {
+	SnapshotID string `json:"snapshot_id"`
+	FeedID     string `json:"feed_id,omitempty"`
+	CategoryID string `json:"category_id,omitempty"`
+	// Status is planning, restoring, reindexing, completed or failed
+	Status     string `json:"status"`
+	Categories int64  `json:"categories"`
+	Total      int64  `json:"total"`
+	Processed  int64  `json:"processed"`
+	Restored   int64  `json:"restored"`
+	// Conflicts are saved products whose slug or EAN another product holds now; they
+	// are left out, as the current product replaced them
+	Conflicts  int64      `json:"conflicts"`
+	Indexed    int        `json:"indexed"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+var (
+	snapshotRestore      *SnapshotRestoreProgress
+	snapshotRestoreMutex sync.Mutex
+)
+
+func (p *SnapshotRestoreProgress) snapshot() SnapshotRestoreProgress {
+	snapshotRestoreMutex.Lock()
+	defer snapshotRestoreMutex.Unlock()
+	return *p
+}
+
+func (p *SnapshotRestoreProgress) update(fn func(p *SnapshotRestoreProgress)) {
+	snapshotRestoreMutex.Lock()
+	fn(p)
+	snapshotRestoreMutex.Unlock()
+}
+
+// AdminRestoreSnapshot replays a snapshot into the database in the background. Saved
+// products are upserted with their images, attributes, media and offers replaced;
+// products created since the snapshot are left alone. feed_id or category_id (the
+// category and its subtree as saved) limits the restore to those products.
+func (h *Handlers) AdminRestoreSnapshot(c *fiber.Ctx) error {
+	var input struct {
+		FeedID     string `json:"feed_id"`
+		CategoryID string `json:"category_id"`
+	}
+	c.BodyParser(&input)
+	if input.FeedID != "" && input.CategoryID != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Scope a restore to a feed or a category, not both"})
+	}
+	if input.FeedID != "" && !isUUID(input.FeedID) {
+		return invalidUUIDField(c, "feed_id")
+	}
+	if input.CategoryID != "" && !isUUID(input.CategoryID) {
+		return invalidUUIDField(c, "category_id")
+	}
+
+	ctx := context.Background()
+	id := c.Params("id")
+	var status, file string
+	if err := h.db.Pool.QueryRow(ctx, "SELECT status, file FROM catalog_snapshots WHERE id = $1::uuid", id).Scan(&status, &file); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Snapshot not found"})
+	}
+	if status != "ready" {
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "Snapshot is " + status})
+	}
+	path := filepath.Join(snapshotDir(), file)
+	if _, err := os.Stat(path); err != nil {
+		return c.Status(410).JSON(fiber.Map{"success": false, "error": "Snapshot file is missing"})
+	}
+	if !h.imports.idle() {
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "Feed imports are running; restore once they finish"})
+	}
+
+	snapshotRestoreMutex.Lock()
+	if snapshotRestore != nil && snapshotRestore.FinishedAt == nil {
+		snapshotRestoreMutex.Unlock()
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "Restore already running"})
+	}
+	progress := &SnapshotRestoreProgress{SnapshotID: id, FeedID: input.FeedID, CategoryID: input.CategoryID, Status: "planning", StartedAt: time.Now()}
+	snapshotRestore = progress
+	snapshotRestoreMutex.Unlock()
+
+	h.audit(ctx, c, "snapshot.restore", "catalog_snapshot", id, fiber.Map{"feed_id": input.FeedID, "category_id": input.CategoryID})
+	go h.restoreSnapshot(progress, path)
+	return c.Status(202).JSON(fiber.Map{"success": true, "data": progress.snapshot()})
+}
+
+// GetSnapshotRestore returns the progress of the running or last restore of a snapshot
+func (h *Handlers) GetSnapshotRestore(c *fiber.Ctx) error {
+	id := c.Params("id")
+	snapshotRestoreMutex.Lock()
+	progress := snapshotRestore
+	snapshotRestoreMutex.Unlock()
+	if progress != nil && progress.SnapshotID == id {
+		return c.JSON(fiber.Map{"success": true, "data": progress.snapshot()})
+	}
+
+	var last *string
+	if err := h.db.Pool.QueryRow(context.Background(), "SELECT last_restore::text FROM catalog_snapshots WHERE id = $1::uuid", id).Scan(&last); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Snapshot not found"})
+	}
+	if last == nil {
+		return c.JSON(fiber.Map{"success": true, "data": nil})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": json.RawMessage(*last)})
+}
+
+func (h *Handlers) restoreSnapshot(progress *SnapshotRestoreProgress, path string) {
+	ctx := context.Background()
+	restoredIDs, err := h.replaySnapshot(ctx, progress, path)
+
+	// rows restored before a failure stay, so counts and search catch up either way
+	indexed := 0
+	if current := progress.snapshot(); current.Categories > 0 || current.Restored > 0 {
+		h.recountCategories(ctx)
+		progress.update(func(p *SnapshotRestoreProgress) { p.Status = "reindexing" })
+		var indexErr error
+		indexed, indexErr = h.reindexRestored(ctx, progress, restoredIDs)
+		if err == nil && indexErr != nil {
+			err = fmt.Errorf("restored, but re-indexing search failed: %w", indexErr)
+		}
+	}
+
+	now := time.Now()
+	progress.update(func(p *SnapshotRestoreProgress) {
+		p.Status, p.Indexed, p.FinishedAt = "completed", indexed, &now
+		if err != nil {
+			p.Status, p.Error = "failed", err.Error()
+		}
+	})
+	final := progress.snapshot()
+	finalJSON, _ := json.Marshal(final)
+	h.db.Pool.Exec(ctx, "UPDATE catalog_snapshots SET last_restored_at = NOW(), last_restore = $2::jsonb WHERE id = $1::uuid", final.SnapshotID, string(finalJSON))
+	log.Printf("Snapshot %s restore %s: %d of %d products restored, %d conflicts", final.SnapshotID, final.Status, final.Restored, final.Total, final.Conflicts)
+}
+
+// reindexRestored re-syncs Elasticsearch after a restore: the whole index for a full
+// restore, otherwise the restored products. ids is nil for a full restore.
+func (h *Handlers) reindexRestored(ctx context.Context, progress *SnapshotRestoreProgress, ids []string) (int, error) {
+	if h.es == nil {
+		return 0, nil
+	}
+	if ids == nil {
+		return h.ReindexProducts(ctx)
+	}
+	// restored products go through the sync queue, so a failed write is retried later
+	indexed := 0
+	for i := 0; i < len(ids); i += esSyncBatchSize {
+		end := min(i+esSyncBatchSize, len(ids))
+		if err := h.enqueueESSync(ctx, "index", "SELECT unnest($2::uuid[])", ids[i:end]); err != nil {
+			return indexed, err
+		}
+		written, failed, err := h.processESSync(ctx, newWhere().add("q.product_id = ANY(?::uuid[])", ids[i:end]))
+		if err != nil {
+			return indexed, err
+		}
+		if failed > 0 {
+			return indexed, fmt.Errorf("%d products not indexed, queued for retry", failed)
+		}
+		indexed += written
+		progress.update(func(p *SnapshotRestoreProgress) { p.Indexed = indexed })
+	}
+	h.es.Refresh()
+	h.searchCache.bumpGeneration()
+	return indexed, nil
+}
+
+// snapshotReader reads the lines of a snapshot file after its header
+type snapshotReader struct {
+	f      *os.File
+	gz     *gzip.Reader
+	r      *bufio.Reader
+	header snapshotHeader
+}
+
+func openSnapshot(path string) (*snapshotReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	sr := &snapshotReader{f: f, gz: gz, r: bufio.NewReaderSize(gz, 64*1024)}
+	line, err := sr.r.ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &sr.header)
+	}
+	if err == nil && sr.header.Version != snapshotFormatVersion {
+		err = fmt.Errorf("unsupported snapshot version %d", sr.header.Version)
+	}
+	if err != nil {
+		sr.Close()
+		return nil, fmt.Errorf("reading snapshot header: %w", err)
+	}
+	return sr, nil
+}
+
+// next returns the following line, or io.EOF after the last
+func (sr *snapshotReader) next() (snapshotLine, error) {
+	var line snapshotLine
+	raw, err := sr.r.ReadBytes('\n')
+	if len(bytes.TrimSpace(raw)) == 0 {
+		if err == nil {
+			return sr.next()
+		}
+		return line, err
+	}
+	if jsonErr := json.Unmarshal(raw, &line); jsonErr != nil {
+		return line, jsonErr
+	}
+	return line, nil
+}
+
+func (sr *snapshotReader) Close() {
+	sr.gz.Close()
+	sr.f.Close()
+}
+
+// snapshotPlan is the first pass over a snapshot: the saved categories and which of
+// them the restore writes
+type snapshotPlan struct {
+	categories map[string]json.RawMessage
+	parents    map[string]string
+	// subtree is the scope category and its descendants as saved, for a category scope
+	subtree map[string]bool
+	// overwrite are categories restored as saved; ensure are only created when missing,
+	// so products and parents outside the scope still have their category
+	overwrite, ensure map[string]bool
+	products          int64
+}
+
+func (plan *snapshotPlan) inScope(progress *SnapshotRestoreProgress, p snapshotRef) bool {
+	switch {
+	case progress.FeedID != "":
+		return p.FeedID != nil && *p.FeedID == progress.FeedID
+	case progress.CategoryID != "":
+		return p.CategoryID != nil && plan.subtree[*p.CategoryID]
+	}
+	return true
+}
+
+// ensureWithAncestors marks a category and its saved ancestors for creation when missing
+func (plan *snapshotPlan) ensureWithAncestors(id string) {
+	for seen := map[string]bool{}; id != "" && !seen[id]; id = plan.parents[id] {
+		seen[id] = true
+		if plan.categories[id] != nil && !plan.overwrite[id] {
+			plan.ensure[id] = true
+		}
+	}
+}
+
+func (h *Handlers) planSnapshotRestore(progress *SnapshotRestoreProgress, path string) (*snapshotPlan, error) {
+	sr, err := openSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	defer sr.Close()
+
+	plan := &snapshotPlan{categories: map[string]json.RawMessage{}, parents: map[string]string{}, overwrite: map[string]bool{}, ensure: map[string]bool{}}
+	scoped := false
+	for {
+		line, err := sr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var ref snapshotRef
+		if line.Category != nil {
+			if err := json.Unmarshal(line.Category, &ref); err != nil {
+				return nil, err
+			}
+			plan.categories[ref.ID] = line.Category
+			if ref.ParentID != nil {
+				plan.parents[ref.ID] = *ref.ParentID
+			}
+			continue
+		}
+		if !scoped {
+			// categories precede products, so the scope is known from here on
+			plan.scope(progress)
+			scoped = true
+		}
+		if err := json.Unmarshal(line.Product, &ref); err != nil {
+			return nil, err
+		}
+		if plan.inScope(progress, ref) {
+			plan.products++
+			if ref.CategoryID != nil {
+				plan.ensureWithAncestors(*ref.CategoryID)
+			}
+		}
+	}
+	if !scoped {
+		plan.scope(progress)
+	}
+	return plan, nil
+}
+
+// scope picks the overwritten categories: all of them for a full restore, the saved
+// subtree for a category scope and none for a feed scope
+func (plan *snapshotPlan) scope(progress *SnapshotRestoreProgress) {
+	switch {
+	case progress.FeedID != "":
+	case progress.CategoryID != "":
+		plan.subtree = map[string]bool{progress.CategoryID: true}
+		for changed := true; changed; {
+			changed = false
+			for id, parent := range plan.parents {
+				if plan.subtree[parent] && !plan.subtree[id] {
+					plan.subtree[id], changed = true, true
+				}
+			}
+		}
+		for id := range plan.subtree {
+			if plan.categories[id] != nil {
+				plan.overwrite[id] = true
+			}
+		}
+		plan.ensureWithAncestors(plan.parents[progress.CategoryID])
+	default:
+		for id := range plan.categories {
+			plan.overwrite[id] = true
+		}
+	}
+}
+
+// replaySnapshot restores categories in one transaction and then products in batches
+// of snapshotRestoreBatch, each in its own transaction. It returns the restored product
+// IDs for a scoped restore and nil for a full one.
+func (h *Handlers) replaySnapshot(ctx context.Context, progress *SnapshotRestoreProgress, path string) ([]string, error) {
+	plan, err := h.planSnapshotRestore(progress, path)
+	if err != nil {
+		return nil, err
+	}
+	if progress.CategoryID != "" && plan.categories[progress.CategoryID] == nil {
+		return nil, fmt.Errorf("category %s is not in the snapshot", progress.CategoryID)
+	}
+	progress.update(func(p *SnapshotRestoreProgress) { p.Status, p.Total = "restoring", plan.products })
+
+	sr, err := openSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	defer sr.Close()
+	cols, err := h.restoreColumns(ctx, sr.header)
+	if err != nil {
+		return nil, err
+	}
+
+	remap, restoredCategories, err := h.restoreCategories(ctx, plan, cols["categories"])
+	if err != nil {
+		return nil, fmt.Errorf("restoring categories: %w", err)
+	}
+	progress.update(func(p *SnapshotRestoreProgress) { p.Categories = restoredCategories })
+
+	var restoredIDs []string
+	if progress.FeedID != "" || progress.CategoryID != "" {
+		restoredIDs = []string{}
+	}
+	batch := make([]snapshotLine, 0, snapshotRestoreBatch)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		ids, err := h.restoreProductBatch(ctx, batch, cols)
+		if err != nil {
+			return err
+		}
+		if restoredIDs != nil {
+			restoredIDs = append(restoredIDs, ids...)
+		}
+		n := int64(len(batch))
+		progress.update(func(p *SnapshotRestoreProgress) {
+			p.Processed += n
+			p.Restored += int64(len(ids))
+			p.Conflicts += n - int64(len(ids))
+		})
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		line, err := sr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return restoredIDs, err
+		}
+		if line.Product == nil {
+			continue
+		}
+		var ref snapshotRef
+		if err := json.Unmarshal(line.Product, &ref); err != nil {
+			return restoredIDs, err
+		}
+		if !plan.inScope(progress, ref) {
+			continue
+		}
+		if ref.CategoryID != nil && remap[*ref.CategoryID] != "" {
+			if line.Product, err = setJSONField(line.Product, "category_id", remap[*ref.CategoryID]); err != nil {
+				return restoredIDs, err
+			}
+		}
+		batch = append(batch, line)
+		if len(batch) == snapshotRestoreBatch {
+			if err := flush(); err != nil {
+				return restoredIDs, err
+			}
+		}
+	}
+	return restoredIDs, flush()
+}
+
+// restoreColumns intersects the saved columns of each table with its current ones
+func (h *Handlers) restoreColumns(ctx context.Context, header snapshotHeader) (map[string][]string, error) {
+	cols := map[string][]string{}
+	for _, table := range append([]string{"categories", "products"}, snapshotChildTables...) {
+		current, err := h.tableColumns(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+		saved := map[string]bool{}
+		for _, col := range header.Columns[table] {
+			saved[col] = true
+		}
+		for _, col := range current {
+			if saved[col] {
+				cols[table] = append(cols[table], col)
+			}
+		}
+		if !saved["id"] {
+			return nil, fmt.Errorf("snapshot has no ids for %s", table)
+		}
+	}
+	return cols, nil
+}
+
+// restoreCategories writes the planned categories. A saved category whose slug another
+// category holds now is not restored; the returned remap points its ID, and thereby
+// its products and children, at that category.
+func (h *Handlers) restoreCategories(ctx context.Context, plan *snapshotPlan, cols []string) (map[string]string, int64, error) {
+	tx, err := h.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	planned := make([]json.RawMessage, 0, len(plan.overwrite)+len(plan.ensure))
+	for id := range plan.overwrite {
+		planned = append(planned, plan.categories[id])
+	}
+	for id := range plan.ensure {
+		planned = append(planned, plan.categories[id])
+	}
+	remap := map[string]string{}
+	rows, err := tx.Query(ctx, `
+		SELECT r.id::text, x.id::text FROM json_populate_recordset(NULL::categories, $1::json) r
+		JOIN categories x ON x.slug = r.slug AND x.id <> r.id
+	`, jsonArray(planned))
+	if err != nil {
+		return nil, 0, err
+	}
+	for rows.Next() {
+		var saved, current string
+		rows.Scan(&saved, &current)
+		remap[saved] = current
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	split := func(ids map[string]bool) ([]json.RawMessage, error) {
+		out := []json.RawMessage{}
+		for id := range ids {
+			if remap[id] != "" {
+				continue
+			}
+			row := plan.categories[id]
+			var refs struct {
+				ParentID     *string `json:"parent_id"`
+				MergedIntoID *string `json:"merged_into_id"`
+			}
+			if err := json.Unmarshal(row, &refs); err != nil {
+				return nil, err
+			}
+			for field, to := range map[string]*string{"parent_id": refs.ParentID, "merged_into_id": refs.MergedIntoID} {
+				if to == nil || remap[*to] == "" {
+					continue
+				}
+				var err error
+				if row, err = setJSONField(row, field, remap[*to]); err != nil {
+					return nil, err
+				}
+			}
+			out = append(out, row)
+		}
+		return out, nil
+	}
+	overwrite, err := split(plan.overwrite)
+	if err != nil {
+		return nil, 0, err
+	}
+	ensure, err := split(plan.ensure)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// references are checked at the end of each statement, so a parent may come later
+	// in the same batch; references to categories that exist nowhere are dropped
+	knownCategory := func(col string) string {
+		return fmt.Sprintf("CASE WHEN r.%[1]s IN (SELECT (e->>'id')::uuid FROM json_array_elements($1::json) e UNION ALL SELECT id FROM categories) THEN r.%[1]s END", col)
+	}
+	exprs := map[string]string{
+		"parent_id":          knownCategory("parent_id"),
+		"merged_into_id":     knownCategory("merged_into_id"),
+		"created_by_feed_id": "(SELECT f.id FROM feeds f WHERE f.id = r.created_by_feed_id)",
+	}
+	var restored int64
+	for _, step := range []struct {
+		rows      []json.RawMessage
+		overwrite bool
+	}{{ensure, false}, {overwrite, true}} {
+		if len(step.rows) == 0 {
+			continue
+		}
+		ids, err := upsertSnapshotRows(ctx, tx, "categories", cols, jsonArray(step.rows), exprs, "", step.overwrite)
+		if err != nil {
+			return nil, 0, err
+		}
+		restored += int64(len(ids))
+	}
+	return remap, restored, tx.Commit(ctx)
+}
+
+// restoreProductBatch upserts the products of batch and replaces their child rows in
+// one transaction. It returns the IDs of the restored products.
+func (h *Handlers) restoreProductBatch(ctx context.Context, batch []snapshotLine, cols map[string][]string) ([]string, error) {
+	tx, err := h.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	products := make([]json.RawMessage, len(batch))
+	for i, line := range batch {
+		products[i] = line.Product
+	}
+	ids, err := upsertSnapshotRows(ctx, tx, "products", cols["products"], jsonArray(products), map[string]string{
+		"category_id": "(SELECT c.id FROM categories c WHERE c.id = r.category_id)",
+		"feed_id":     "(SELECT f.id FROM feeds f WHERE f.id = r.feed_id)",
+	}, "NOT EXISTS (SELECT 1 FROM products x WHERE x.id <> r.id AND (x.slug = r.slug OR (COALESCE(r.ean,'') <> '' AND x.ean = r.ean)))", true)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return ids, tx.Commit(ctx)
+	}
+
+	restored := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		restored[id] = true
+	}
+	for _, table := range snapshotChildTables {
+		if _, err := tx.Exec(ctx, "DELETE FROM "+table+" WHERE product_id = ANY($1::uuid[])", ids); err != nil {
+			return nil, err
+		}
+		var rows []json.RawMessage
+		for _, line := range batch {
+			var ref snapshotRef
+			json.Unmarshal(line.Product, &ref)
+			if restored[ref.ID] {
+				rows = append(rows, line.Children[table]...)
+			}
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		exprs, where := map[string]string{}, ""
+		switch table {
+		case "offers":
+			// vendor_id is required there, so offers of removed vendors stay out
+			where = "EXISTS (SELECT 1 FROM vendors v WHERE v.id = r.vendor_id)"
+		case "product_offers":
+			exprs["vendor_id"] = "(SELECT v.id FROM vendors v WHERE v.id = r.vendor_id)"
+		}
+		if _, err := upsertSnapshotRows(ctx, tx, table, cols[table], jsonArray(rows), exprs, where, true); err != nil {
+			return nil, fmt.Errorf("%s: %w", table, err)
+		}
+	}
+	return ids, tx.Commit(ctx)
+}
+
+// upsertSnapshotRows inserts rows, a JSON array of saved rows of table, matching on id.
+// exprs replaces the value of a column with an SQL expression over the saved row r and
+// where filters the rows. Existing rows are updated when overwrite is set and kept
+// otherwise. It returns the IDs written.
+func upsertSnapshotRows(ctx context.Context, tx pgx.Tx, table string, cols []string, rows []byte, exprs map[string]string, where string, overwrite bool) ([]string, error) {
+	result, err := tx.Query(ctx, snapshotUpsertQuery(table, cols, exprs, where, overwrite), string(rows))
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	ids := []string{}
+	for result.Next() {
+		var id string
+		result.Scan(&id)
+		ids = append(ids, id)
+	}
+	return ids, result.Err()
+}
+
+// snapshotUpsertQuery builds the statement of upsertSnapshotRows. An overwritten row
+// does not get the saved edit version back, which an editor holding an older read
+// could match; its current version is bumped instead.
+func snapshotUpsertQuery(table string, cols []string, exprs map[string]string, where string, overwrite bool) string {
+	values := make([]string, len(cols))
+	var sets []string
+	for i, col := range cols {
+		values[i] = "r." + col
+		if expr, ok := exprs[col]; ok {
+			values[i] = expr
+		}
+		switch col {
+		case "id":
+		case "version":
+			sets = append(sets, fmt.Sprintf("version = COALESCE(%s.version,1) + 1", table))
+		default:
+			sets = append(sets, col+" = EXCLUDED."+col)
+		}
+	}
+	conflict := "DO NOTHING"
+	if overwrite && len(sets) > 0 {
+		conflict = "DO UPDATE SET " + strings.Join(sets, ", ")
+	}
+	if where == "" {
+		where = "true"
+	}
+	return fmt.Sprintf(`
+		INSERT INTO %[1]s (%[2]s)
+		SELECT %[3]s FROM json_populate_recordset(NULL::%[1]s, $1::json) r WHERE %[4]s
+		ON CONFLICT (id) %[5]s RETURNING id::text
+	`, table, strings.Join(cols, ", "), strings.Join(values, ", "), where, conflict)
+}
+
+func jsonArray(rows []json.RawMessage) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, row := range rows {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(row)
+	}
+	buf.WriteByte(']')
+	return buf.Bytes()
+}
+
+// setJSONField rewrites one field of a saved row, keeping numbers exactly as saved
+func setJSONField(row json.RawMessage, field string, value interface{}) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(row))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, errors.New("saved row is not an object")
+	}
+	m[field] = value
+	return json.Marshal(m)
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSnapshotUpsertQueryBumpsVersion(t *testing.T) {
+	q := snapshotUpsertQuery("products", []string{"id", "title", "version"}, nil, "", true)
+	if !strings.Contains(q, "version = COALESCE(products.version,1) + 1") {
+		t.Errorf("overwrite does not bump the version:\n%s", q)
+	}
+	if strings.Contains(q, "version = EXCLUDED.version") {
+		t.Errorf("overwrite restores the saved version:\n%s", q)
+	}
+	if !strings.Contains(q, "title = EXCLUDED.title") {
+		t.Errorf("overwrite does not restore the other columns:\n%s", q)
+	}
+	if !strings.Contains(q, "SELECT r.id, r.title, r.version FROM") {
+		t.Errorf("inserted rows do not keep the saved values:\n%s", q)
+	}
+}
+
+func TestSnapshotUpsertQueryKeepExisting(t *testing.T) {
+	q := snapshotUpsertQuery("offers", []string{"id", "price"}, map[string]string{"price": "r.price * 2"}, "r.price > 0", false)
+	for _, want := range []string{"ON CONFLICT (id) DO NOTHING", "SELECT r.id, r.price * 2 FROM", "WHERE r.price > 0"} {
+		if !strings.Contains(q, want) {
+			t.Errorf("query lacks %q:\n%s", want, q)
+		}
+	}
+}
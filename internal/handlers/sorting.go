@@ -0,0 +1,38 @@
+package handlers
+
+// ========== LISTING SORT ==========
+
+// SQL expressions behind the popularity, rating and discount sorts. Each is NULL when
+// the product has no data, so those products sort last. A click weighs five views.
+const (
+	popularityExpr = "NULLIF(COALESCE(p.click_count,0) * 5 + COALESCE(p.view_count,0), 0)"
+	ratingExpr     = "NULLIF(p.rating, 0)"
+	// discountExpr is the whole-percent drop of the effective price_min below the feed's
+	// original price or, without one, below the highest price seen
+	discountExpr = `CASE WHEN p.price_min > 0 AND COALESCE(p.original_price, p.price_high) > (` + effectivePriceMin + `)
+		THEN ROUND((1 - (` + effectivePriceMin + `) / COALESCE(p.original_price, p.price_high)) * 100)::int END`
+)
+
+// discountColumn is the discount_percent select column of product cards
+const discountColumn = "COALESCE(" + discountExpr + ", 0)"
+
+var listingSorts = []string{"newest", "price_asc", "price_desc", "name_asc", "popularity", "discount", "rating"}
+
+// listingOrder returns the ORDER BY expressions for a listing sort; unknown sorts fall back to newest
+func listingOrder(sort, priceMinCol string) string {
+	switch sort {
+	case "price_asc":
+		return priceMinCol + " ASC"
+	case "price_desc":
+		return priceMinCol + " DESC"
+	case "name_asc":
+		return "p.title ASC"
+	case "popularity":
+		return popularityExpr + " DESC NULLS LAST, p.created_at DESC"
+	case "discount":
+		return discountExpr + " DESC NULLS LAST, p.created_at DESC"
+	case "rating":
+		return ratingExpr + " DESC NULLS LAST, COALESCE(p.review_count,0) DESC, p.created_at DESC"
+	}
+	return "p.created_at DESC"
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ========== SQL WHERE BUILDER ==========
+
+// sqlWhere collects AND-ed conditions and their arguments, numbering placeholders
+// itself so a filter is added once and reused by the count, list and facet queries.
+// Conditions write ? for each argument; they must not use the jsonb ? operators.
+type sqlWhere struct {
+	conds []string
+	args  []interface{}
+}
+
+// newWhere starts a builder with argument-free base conditions
+func newWhere(conds ...string) *sqlWhere {
+	return &sqlWhere{conds: append([]string(nil), conds...)}
+}
+
+// add appends cond, replacing its ? placeholders with $n for args in order
+func (w *sqlWhere) add(cond string, args ...interface{}) *sqlWhere {
+	if n := strings.Count(cond, "?"); n != len(args) {
+		panic("sqlWhere: " + strconv.Itoa(n) + " placeholders for " + strconv.Itoa(len(args)) + " args in " + cond)
+	}
+	var b strings.Builder
+	for _, arg := range args {
+		i := strings.IndexByte(cond, '?')
+		b.WriteString(cond[:i])
+		b.WriteString(w.arg(arg))
+		cond = cond[i+1:]
+	}
+	b.WriteString(cond)
+	w.conds = append(w.conds, b.String())
+	return w
+}
+
+// arg registers a value used outside the conditions, such as LIMIT and OFFSET,
+// and returns its placeholder
+func (w *sqlWhere) arg(v interface{}) string {
+	w.args = append(w.args, v)
+	return "$" + strconv.Itoa(len(w.args))
+}
+
+// clone returns an independent copy, so variants such as the paged list can add
+// arguments without affecting the count and facet queries
+func (w *sqlWhere) clone() *sqlWhere {
+	return &sqlWhere{conds: append([]string(nil), w.conds...), args: append([]interface{}(nil), w.args...)}
+}
+
+// with returns a copy extended by cond, leaving w untouched for other variants
+func (w *sqlWhere) with(cond string, args ...interface{}) *sqlWhere {
+	return w.clone().add(cond, args...)
+}
+
+// clause renders the WHERE clause; without conditions it matches every row
+func (w *sqlWhere) clause() string {
+	if len(w.conds) == 0 {
+		return "WHERE TRUE"
+	}
+	return "WHERE " + strings.Join(w.conds, " AND ")
+}
+
+// params returns the arguments in placeholder order
+func (w *sqlWhere) params() []interface{} {
+	return w.args
+}
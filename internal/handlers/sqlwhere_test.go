@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLWhereNumbersPlaceholders(t *testing.T) {
+	w := newWhere("p.is_active=true")
+	w.add("p.category_id = ANY(?::uuid[])", []string{"a", "b"})
+	w.add("p.price_min BETWEEN ? AND ?", 10.0, 20.0)
+	w.add("p.stock_status = 'instock'")
+	w.add("(p.title ILIKE ? OR p.ean ILIKE ?)", "%kávovar%", "%kávovar%")
+
+	want := "WHERE p.is_active=true AND p.category_id = ANY($1::uuid[]) AND p.price_min BETWEEN $2 AND $3 AND p.stock_status = 'instock' AND (p.title ILIKE $4 OR p.ean ILIKE $5)"
+	if got := w.clause(); got != want {
+		t.Errorf("clause\n got %s\nwant %s", got, want)
+	}
+	wantArgs := []interface{}{[]string{"a", "b"}, 10.0, 20.0, "%kávovar%", "%kávovar%"}
+	if got := w.params(); !reflect.DeepEqual(got, wantArgs) {
+		t.Errorf("params %v, want %v", got, wantArgs)
+	}
+	// LIMIT and OFFSET continue the numbering
+	if limit, offset := w.arg(20), w.arg(40); limit != "$6" || offset != "$7" {
+		t.Errorf("LIMIT %s OFFSET %s, want $6 and $7", limit, offset)
+	}
+}
+
+func TestSQLWhereEmpty(t *testing.T) {
+	w := newWhere()
+	if w.clause() != "WHERE TRUE" || len(w.params()) != 0 {
+		t.Errorf("empty builder: %s %v", w.clause(), w.params())
+	}
+	// a literal ? inside an argument is a value, not a placeholder
+	w.add("p.title = ?", "Čo je nové?")
+	if w.clause() != "WHERE p.title = $1" || w.params()[0] != "Čo je nové?" {
+		t.Errorf("%s %v", w.clause(), w.params())
+	}
+}
+
+func TestSQLWhereVariantsAreIndependent(t *testing.T) {
+	base := newWhere("p.is_active=true").add("p.brand = ANY(?)", []string{"Bosch"})
+
+	list := base.clone()
+	limit := list.arg(20)
+	branded := base.with("p.brand != ''")
+	cheap := base.with("p.price_min < ?", 50)
+
+	if base.clause() != "WHERE p.is_active=true AND p.brand = ANY($1)" || len(base.params()) != 1 {
+		t.Errorf("base changed: %s %v", base.clause(), base.params())
+	}
+	if limit != "$2" || len(list.params()) != 2 {
+		t.Errorf("list: LIMIT %s, params %v", limit, list.params())
+	}
+	if branded.clause() != base.clause()+" AND p.brand != ''" || len(branded.params()) != 1 {
+		t.Errorf("branded: %s %v", branded.clause(), branded.params())
+	}
+	// each variant numbers its own arguments after the shared ones
+	if cheap.clause() != base.clause()+" AND p.price_min < $2" || !reflect.DeepEqual(cheap.params(), []interface{}{[]string{"Bosch"}, 50}) {
+		t.Errorf("cheap: %s %v", cheap.clause(), cheap.params())
+	}
+
+	// appending to a clone never writes into the backing array of another
+	a, b := base.clone(), base.clone()
+	a.add("p.id = ?", "a")
+	b.add("p.id = ?", "b")
+	if a.params()[1] != "a" || b.params()[1] != "b" {
+		t.Errorf("clones share arguments: %v %v", a.params(), b.params())
+	}
+}
+
+func TestSQLWhereArgumentCountMismatchPanics(t *testing.T) {
+	tests := []struct {
+		cond string
+		args []interface{}
+	}{
+		{"p.price_min >= ?", nil},
+		{"p.price_min >= ?", []interface{}{1, 2}},
+		{"p.is_active = true", []interface{}{true}},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "placeholders") {
+					t.Errorf("add(%q, %v) did not panic: %v", tt.cond, tt.args, r)
+				}
+			}()
+			newWhere().add(tt.cond, tt.args...)
+		}()
+	}
+}
+
+// TestSQLWhereBindsInPostgres runs a built filter, so placeholder numbering and the
+// argument types are checked by the server rather than by string comparison
+func TestSQLWhereBindsInPostgres(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	marker := fmt.Sprintf("sqlwhere-%d", time.Now().UnixNano())
+	var ids []string
+	for i, price := range []float64{5, 15, 25, 35} {
+		id := env.createTestProduct(t, fmt.Sprintf("%s %d", marker, i), price)
+		env.db.Pool.Exec(ctx, "UPDATE products SET brand = $2 WHERE id = $1::uuid", id, []string{"Bosch", "Tefal", "Bosch", "Bosch"}[i])
+		ids = append(ids, id)
+	}
+
+	where := newWhere("p.is_active=true").
+		add("p.id = ANY(?::uuid[])", ids).
+		add("p.brand = ANY(?)", []string{"Bosch"}).
+		add("p.price_min >= ?", 10.0)
+	var count int
+	if err := env.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM products p "+where.clause(), where.params()...).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("count %d, want the 2 Bosch products from 10", count)
+	}
+
+	list := where.clone()
+	rows, err := env.db.Pool.Query(ctx, fmt.Sprintf("SELECT p.title FROM products p %s ORDER BY p.price_min LIMIT %s OFFSET %s",
+		list.clause(), list.arg(1), list.arg(1)), list.params()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for rows.Next() {
+		var title string
+		rows.Scan(&title)
+		titles = append(titles, title)
+	}
+	rows.Close()
+	if len(titles) != 1 || titles[0] != marker+" 3" {
+		t.Errorf("second page %v, want the 35 € product", titles)
+	}
+}
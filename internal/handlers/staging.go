@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== STAGED IMPORTS ==========
+
+func validImportMode(mode string) bool {
+	return mode == "live" || mode == "staged"
+}
+
+func invalidImportMode(c *fiber.Ctx) error {
+	return c.Status(400).JSON(fiber.Map{"success": false, "error": "import_mode must be live or staged"})
+}
+
+// findExistingProduct matches a feed item to the catalog by EAN, then SKU
+func (h *Handlers) findExistingProduct(ctx context.Context, ean, sku string) string {
+	var existingID string
+	if ean != "" {
+		h.db.Pool.QueryRow(ctx, "SELECT id FROM products WHERE ean=$1", ean).Scan(&existingID)
+	}
+	if existingID == "" && sku != "" {
+		h.db.Pool.QueryRow(ctx, "SELECT id FROM products WHERE sku=$1", sku).Scan(&existingID)
+	}
+	return existingID
+}
+
+// stageProduct upserts an imported item into staged_products keyed by EAN, SKU or title,
+// so re-imports refresh the pending row. Reports whether the row is new.
+func (h *Handlers) stageProduct(ctx context.Context, feedID string, data map[string]interface{}, params []map[string]string) (bool, error) {
+	matchKey := getStr(data, "ean")
+	if matchKey == "" {
+		matchKey = getStr(data, "sku")
+	}
+	if matchKey == "" {
+		matchKey = "title:" + getStr(data, "title")
+	}
+	paramsJSON, _ := json.Marshal(params)
+	media, _ := data["_media"].([]feedMedia)
+	mediaJSON, _ := json.Marshal(media)
+	relations, _ := data["_relations"].([]feedRelation)
+	relationsJSON, _ := json.Marshal(relations)
+
+	var isNew bool
+	err := h.db.Pool.QueryRow(ctx, `
+		INSERT INTO staged_products (feed_id, match_key, title, description, short_description, ean, sku, brand,
+		                             image_url, affiliate_url, category, price, stock_status, params, media, relations, created_at, updated_at)
+		VALUES ($1::uuid, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14::jsonb, $15::jsonb, $16::jsonb, NOW(), NOW())
+		ON CONFLICT (feed_id, match_key) DO UPDATE SET
+		       title=EXCLUDED.title, description=EXCLUDED.description, short_description=EXCLUDED.short_description,
+		       ean=EXCLUDED.ean, sku=EXCLUDED.sku, brand=EXCLUDED.brand, image_url=EXCLUDED.image_url,
+		       affiliate_url=EXCLUDED.affiliate_url, category=EXCLUDED.category, price=EXCLUDED.price,
+		       stock_status=EXCLUDED.stock_status, params=EXCLUDED.params, media=EXCLUDED.media,
+		       relations=EXCLUDED.relations, updated_at=NOW()
+		RETURNING (xmax = 0)
+	`, feedID, matchKey, getStr(data, "title"), getStr(data, "description"), getStr(data, "short_description"),
+		getStr(data, "ean"), getStr(data, "sku"), getStr(data, "brand"), getStr(data, "image_url"),
+		getStr(data, "affiliate_url"), getStr(data, "category"), getFloat(data, "price"), getStr(data, "stock_status"),
+		string(paramsJSON), string(mediaJSON), string(relationsJSON)).Scan(&isNew)
+	return isNew, err
+}
+
+type stagedProduct struct {
+	ID         string
+	Data       map[string]interface{}
+	Params     []map[string]string
+	MatchID    string
+	MatchTitle string
+	MatchPrice float64
+	MatchBrand string
+	MatchImage string
+	MatchStock string
+	UpdatedAt  time.Time
+}
+
+// stagedSelect reads staged rows with their best catalog match
+const stagedSelect = `
+	SELECT s.id, s.title, COALESCE(s.description,''), COALESCE(s.short_description,''), COALESCE(s.ean,''),
+	       COALESCE(s.sku,''), COALESCE(s.brand,''), COALESCE(s.image_url,''), COALESCE(s.affiliate_url,''),
+	       COALESCE(s.category,''), COALESCE(s.price,0), COALESCE(s.stock_status,''),
+	       COALESCE(s.params::text,'[]'), COALESCE(s.media::text,'[]'), COALESCE(s.relations::text,'[]'), s.updated_at,
+	       COALESCE(m.id::text,''), COALESCE(m.title,''), COALESCE(m.price_min,0), COALESCE(m.brand,''),
+	       COALESCE(m.image_url,''), COALESCE(m.stock_status,'')
+	FROM staged_products s
+	LEFT JOIN LATERAL (
+		SELECT id, title, price_min, brand, image_url, stock_status FROM products
+		WHERE (s.ean <> '' AND ean = s.ean) OR (s.sku <> '' AND sku = s.sku)
+		ORDER BY (s.ean <> '' AND ean = s.ean) DESC LIMIT 1
+	) m ON true
+`
+
+func (h *Handlers) queryStaged(ctx context.Context, where string, args ...interface{}) ([]stagedProduct, error) {
+	rows, err := h.db.Pool.Query(ctx, stagedSelect+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var staged []stagedProduct
+	for rows.Next() {
+		var s stagedProduct
+		var title, desc, shortDesc, ean, sku, brand, img, affiliateURL, category, stockStatus, paramsStr, mediaStr, relationsStr string
+		var price float64
+		rows.Scan(&s.ID, &title, &desc, &shortDesc, &ean, &sku, &brand, &img, &affiliateURL, &category, &price, &stockStatus,
+			&paramsStr, &mediaStr, &relationsStr, &s.UpdatedAt, &s.MatchID, &s.MatchTitle, &s.MatchPrice, &s.MatchBrand, &s.MatchImage, &s.MatchStock)
+		var media []feedMedia
+		var relations []feedRelation
+		json.Unmarshal([]byte(paramsStr), &s.Params)
+		json.Unmarshal([]byte(mediaStr), &media)
+		json.Unmarshal([]byte(relationsStr), &relations)
+		s.Data = map[string]interface{}{
+			"title": title, "description": desc, "short_description": shortDesc, "ean": ean, "sku": sku,
+			"brand": brand, "image_url": img, "affiliate_url": affiliateURL, "category": category,
+			"price": price, "stock_status": stockStatus, "_media": media,
+			"_relations": relations,
+		}
+		staged = append(staged, s)
+	}
+	return staged, rows.Err()
+}
+
+// stagedDiff lists fields where the staged row would change the matched product
+func stagedDiff(feed models.Feed, s stagedProduct) fiber.Map {
+	diff := fiber.Map{}
+	if s.MatchID == "" {
+		return diff
+	}
+	compare := func(field, current, staged string) {
+		if staged != "" && staged != current {
+			diff[field] = fiber.Map{"current": current, "staged": staged}
+		}
+	}
+	compare("title", s.MatchTitle, getStr(s.Data, "title"))
+	compare("brand", s.MatchBrand, getStr(s.Data, "brand"))
+	compare("image_url", s.MatchImage, getStr(s.Data, "image_url"))
+	compare("stock_status", s.MatchStock, normalizeStockStatus(getStr(s.Data, "stock_status")))
+	if gross, _ := splitPrice(getFloat(s.Data, "price"), feed.VATRate, feed.PricesIncludeVAT); gross != s.MatchPrice {
+		diff["price"] = fiber.Map{"current": s.MatchPrice, "staged": gross}
+	}
+	return diff
+}
+
+func (h *Handlers) GetStagedProducts(c *fiber.Ctx) error {
+	feedID := c.Params("id")
+	page, limit, offset := adminPageParams(c, 50)
+	ctx := context.Background()
+
+	feed, err := h.loadFeed(ctx, feedID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Feed not found"})
+	}
+
+	var total int64
+	h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM staged_products WHERE feed_id=$1::uuid", feedID).Scan(&total)
+
+	staged, err := h.queryStaged(ctx, "WHERE s.feed_id=$1::uuid ORDER BY s.created_at LIMIT $2 OFFSET $3", feedID, limit, offset)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	items := []fiber.Map{}
+	for _, s := range staged {
+		item := fiber.Map{"id": s.ID, "updated_at": s.UpdatedAt, "param_count": len(s.Params)}
+		for k, v := range s.Data {
+			if k != "_media" && k != "_relations" {
+				item[k] = v
+			}
+		}
+		if s.MatchID != "" {
+			item["match"] = fiber.Map{"id": s.MatchID, "title": s.MatchTitle}
+		}
+		item["diff"] = stagedDiff(feed, s)
+		items = append(items, item)
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": paginate(c, fiber.Map{"items": items}, page, limit, total)})
+}
+
+type stagedSelection struct {
+	IDs []string `json:"ids"`
+	All bool     `json:"all"`
+}
+
+// problem returns a validation message, or "" when the selection is usable
+func (s stagedSelection) problem() string {
+	if !s.All && len(s.IDs) == 0 {
+		return "ids or all required"
+	}
+	for _, id := range s.IDs {
+		if !isUUID(id) {
+			return "Invalid ids: must be a UUID"
+		}
+	}
+	return ""
+}
+
+// ApproveStagedProducts promotes staged rows through the regular import create/update path
+func (h *Handlers) ApproveStagedProducts(c *fiber.Ctx) error {
+	feedID := c.Params("id")
+	var sel stagedSelection
+	if err := c.BodyParser(&sel); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if msg := sel.problem(); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+	ctx := context.Background()
+
+	feed, err := h.loadFeed(ctx, feedID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Feed not found"})
+	}
+
+	where, args := "WHERE s.feed_id=$1::uuid", []interface{}{feedID}
+	if !sel.All {
+		where += " AND s.id = ANY($2::uuid[])"
+		args = append(args, sel.IDs)
+	}
+	staged, err := h.queryStaged(ctx, where+" ORDER BY s.created_at", args...)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	created, updated, failed := 0, 0, 0
+	var approved []string
+	for _, s := range staged {
+		id, isNew, err := h.saveFeedProduct(ctx, feed, s.Data, s.Params)
+		if _, attrFailed := err.(attributeError); id == "" || (err != nil && !attrFailed && !isNew) {
+			failed++
+			continue
+		}
+		if isNew {
+			created++
+		} else {
+			updated++
+		}
+		approved = append(approved, s.ID)
+	}
+
+	if len(approved) > 0 {
+		h.db.Pool.Exec(ctx, "DELETE FROM staged_products WHERE id = ANY($1::uuid[])", approved)
+		h.db.Pool.Exec(ctx, "UPDATE feeds SET product_count=(SELECT COUNT(*) FROM products WHERE feed_id=$1::uuid) WHERE id=$1::uuid", feedID)
+		h.resolveFeedRelations(ctx, feedID)
+		h.refreshCompleteness(ctx, "p.feed_id = $1::uuid", feedID)
+		h.syncFeedProductsToES(ctx, feedID)
+		go h.checkPriceAlerts(context.Background())
+	}
+
+	return c.JSON(fiber.Map{"success": true, "message": fmt.Sprintf("Approved %d staged products", len(approved)), "data": fiber.Map{
+		"created": created, "updated": updated, "failed": failed,
+	}})
+}
+
+func (h *Handlers) RejectStagedProducts(c *fiber.Ctx) error {
+	feedID := c.Params("id")
+	var sel stagedSelection
+	if err := c.BodyParser(&sel); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if msg := sel.problem(); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": msg})
+	}
+	ctx := context.Background()
+
+	query, args := "DELETE FROM staged_products WHERE feed_id=$1::uuid", []interface{}{feedID}
+	if !sel.All {
+		query += " AND id = ANY($2::uuid[])"
+		args = append(args, sel.IDs)
+	}
+	tag, err := h.db.Pool.Exec(ctx, query, args...)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"success": true, "message": fmt.Sprintf("Rejected %d staged products", tag.RowsAffected())})
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== PRODUCT STATS ==========
+
+const productStatsTTL = 60 * time.Second
+
+// ProductStats is the detailed catalogue breakdown for the admin; the public
+// /stats endpoint keeps returning only the active totals
+type ProductStats struct {
+	Total        int64            `json:"total"`
+	ByStatus     map[string]int64 `json:"by_stock_status"`
+	Active       int64            `json:"active"`
+	Inactive     int64            `json:"inactive"`
+	Added24h     int64            `json:"added_24h"`
+	Added7d      int64            `json:"added_7d"`
+	ZeroPrice    int64            `json:"zero_price"`
+	MissingImage int64            `json:"missing_image"`
+	Feeds        []FeedStats      `json:"feeds"`
+	// Completeness is the score distribution charted on the dashboard
+	Completeness    []CompletenessBucket `json:"completeness"`
+	AvgCompleteness float64              `json:"avg_completeness"`
+	ComputedAt      time.Time            `json:"computed_at"`
+}
+
+type FeedStats struct {
+	FeedID string `json:"feed_id"`
+	Name   string `json:"name"`
+	Total  int64  `json:"total"`
+	Active int64  `json:"active"`
+}
+
+var (
+	productStatsMutex sync.Mutex
+	productStatsCache *ProductStats
+)
+
+// productStats returns the cached breakdown, recomputing it once the TTL has passed
+func (h *Handlers) productStats(ctx context.Context) (*ProductStats, error) {
+	productStatsMutex.Lock()
+	defer productStatsMutex.Unlock()
+	if productStatsCache != nil && time.Since(productStatsCache.ComputedAt) < productStatsTTL {
+		return productStatsCache, nil
+	}
+
+	s := &ProductStats{ByStatus: map[string]int64{}, Feeds: []FeedStats{}, ComputedAt: time.Now()}
+	now := time.Now()
+	err := h.db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE is_active),
+		       COUNT(*) FILTER (WHERE created_at >= $1),
+		       COUNT(*) FILTER (WHERE created_at >= $2),
+		       COUNT(*) FILTER (WHERE COALESCE(price_min,0) <= 0),
+		       COUNT(*) FILTER (WHERE COALESCE(image_url,'') = '')
+		FROM products
+	`, now.Add(-24*time.Hour), now.AddDate(0, 0, -7)).Scan(&s.Total, &s.Active, &s.Added24h, &s.Added7d, &s.ZeroPrice, &s.MissingImage)
+	if err != nil {
+		return nil, err
+	}
+	s.Inactive = s.Total - s.Active
+
+	rows, err := h.db.Pool.Query(ctx, "SELECT COALESCE(stock_status,'instock'), COUNT(*) FROM products GROUP BY 1")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var status string
+		var count int64
+		rows.Scan(&status, &count)
+		s.ByStatus[status] += count
+	}
+	rows.Close()
+
+	rows, err = h.db.Pool.Query(ctx, `
+		SELECT f.id::text, f.name, COUNT(p.id), COUNT(p.id) FILTER (WHERE p.is_active)
+		FROM feeds f LEFT JOIN products p ON p.feed_id = f.id
+		GROUP BY f.id, f.name ORDER BY COUNT(p.id) DESC, f.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var f FeedStats
+		rows.Scan(&f.FeedID, &f.Name, &f.Total, &f.Active)
+		s.Feeds = append(s.Feeds, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if s.Completeness, s.AvgCompleteness, err = h.completenessDistribution(ctx); err != nil {
+		return nil, err
+	}
+
+	productStatsCache = s
+	return s, nil
+}
+
+// AdminProductStats returns the detailed product breakdown, cached for a minute
+func (h *Handlers) AdminProductStats(c *fiber.Ctx) error {
+	stats, err := h.productStats(context.Background())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": stats})
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/mail"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== BACK-IN-STOCK ALERTS ==========
+
+func (h *Handlers) CreateStockAlert(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	var input struct {
+		Email string `json:"email"`
+		Lang  string `json:"lang"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	input.Email = strings.TrimSpace(strings.ToLower(input.Email))
+	if addr, err := mail.ParseAddress(input.Email); err != nil || addr.Address != input.Email {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid email"})
+	}
+
+	ctx := context.Background()
+	var stockStatus string
+	err := h.db.Pool.QueryRow(ctx, "SELECT COALESCE(stock_status,'instock') FROM products WHERE id = $1::uuid AND is_active = true", productID).Scan(&stockStatus)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Product not found"})
+	}
+	if stockStatus == "instock" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Product is in stock"})
+	}
+
+	tag, err := h.db.Pool.Exec(ctx, `
+		INSERT INTO stock_alerts (product_id, email, lang, created_at) VALUES ($1::uuid, $2, $3, NOW())
+		ON CONFLICT (product_id, email) DO NOTHING
+	`, productID, input.Email, requestLang(c, input.Lang))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.JSON(fiber.Map{"success": true, "message": "Already subscribed"})
+	}
+	return c.Status(201).JSON(fiber.Map{"success": true, "message": "Subscribed"})
+}
+
+// isBackInStock reports whether a stock_status change should fire back-in-stock alerts.
+// Subscriptions are taken for any status but instock, so a released preorder fires too.
+func isBackInStock(oldStatus, newStatus string) bool {
+	return oldStatus != "instock" && newStatus == "instock"
+}
+
+// normalizeStockStatus maps supplier availability values to our stock_status values.
+// Unknown values return "" so callers keep the current status.
+func normalizeStockStatus(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "instock", "in stock", "in_stock", "skladom", "na sklade", "available", "1", "true", "yes":
+		return "instock"
+	case "outofstock", "out of stock", "out_of_stock", "vypredane", "vypredané", "nedostupne", "nedostupné", "unavailable", "0", "false", "no":
+		return "outofstock"
+	case "preorder", "pre-order", "predobjednavka", "predobjednávka":
+		return "preorder"
+	}
+	return ""
+}
+
+// setStockStatus changes stock_status for many products at once and fires
+// back-in-stock alerts for those that transitioned
+func (h *Handlers) setStockStatus(ctx context.Context, productIDs []string, status string) error {
+	rows, err := h.db.Pool.Query(ctx, `
+		UPDATE products p SET stock_status = $2, updated_at = NOW(), version = COALESCE(p.version,1) + 1
+		FROM (SELECT id, COALESCE(stock_status,'instock') AS old_status FROM products WHERE id = ANY($1::uuid[]) FOR UPDATE) o
+		WHERE p.id = o.id
+		RETURNING p.id, o.old_status
+	`, productIDs, status)
+	if err != nil {
+		return err
+	}
+	var restocked []string
+	for rows.Next() {
+		var id, oldStatus string
+		rows.Scan(&id, &oldStatus)
+		if isBackInStock(oldStatus, status) {
+			restocked = append(restocked, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	h.fireStockAlerts(ctx, restocked)
+	return nil
+}
+
+// fireStockAlerts consumes the subscriptions of the given products and queues one
+// notification per subscriber. Callers pass only products that came back in stock.
+// Deleting the subscriptions and queueing their e-mails commit together, so a failed
+// queue keeps the subscriptions for the next restock.
+func (h *Handlers) fireStockAlerts(ctx context.Context, productIDs []string) {
+	if len(productIDs) == 0 {
+		return
+	}
+	tx, err := h.db.Pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Stock alert check failed: %v", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		WITH fired AS (
+			DELETE FROM stock_alerts WHERE product_id = ANY($1::uuid[])
+			RETURNING product_id, email, lang
+		)
+		SELECT f.email, f.lang, p.title FROM fired f JOIN products p ON p.id = f.product_id
+	`, productIDs)
+	if err != nil {
+		log.Printf("Stock alert check failed: %v", err)
+		return
+	}
+
+	type fired struct{ email, lang, title string }
+	var alerts []fired
+	for rows.Next() {
+		var f fired
+		if err := rows.Scan(&f.email, &f.lang, &f.title); err != nil {
+			rows.Close()
+			log.Printf("Stock alert check failed: %v", err)
+			return
+		}
+		alerts = append(alerts, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Printf("Stock alert check failed: %v", err)
+		return
+	}
+
+	for _, a := range alerts {
+		if err := queueNotificationOn(ctx, tx, "stock_alert", a.lang, a.email, map[string]string{"Title": a.title}); err != nil {
+			log.Printf("Stock alert for %s: queue failed, %d alerts kept: %v", a.email, len(alerts), err)
+			return
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("Stock alert check failed: %v", err)
+		return
+	}
+	if len(alerts) > 0 {
+		log.Printf("Fired %d back-in-stock alerts", len(alerts))
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestIsBackInStock(t *testing.T) {
+	tests := []struct {
+		old, new string
+		want     bool
+	}{
+		{"outofstock", "instock", true},
+		{"preorder", "instock", true},
+		{"instock", "instock", false},
+		{"instock", "outofstock", false},
+		{"outofstock", "preorder", false},
+		{"preorder", "outofstock", false},
+	}
+	for _, tt := range tests {
+		if got := isBackInStock(tt.old, tt.new); got != tt.want {
+			t.Errorf("isBackInStock(%q, %q) = %v, want %v", tt.old, tt.new, got, tt.want)
+		}
+	}
+}
+
+// TestPreorderReleaseFiresStockAlerts subscribes to a preorder product and releases it
+func TestPreorderReleaseFiresStockAlerts(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	productID := env.createTestProduct(t, "Konzola na predobjednávku", 499)
+	if _, err := env.db.Pool.Exec(ctx, "UPDATE products SET stock_status = 'preorder', release_date = CURRENT_DATE WHERE id = $1::uuid", productID); err != nil {
+		t.Fatal(err)
+	}
+	email := fmt.Sprintf("preorder-%d@example.com", time.Now().UnixNano())
+
+	app := fiber.New()
+	app.Post("/products/:id/stock-alerts", RequireUUID("id"), env.h.CreateStockAlert)
+	if status, resp := callJSON(t, app, "POST", "/products/"+productID+"/stock-alerts", fiber.Map{"email": email}); status != 201 {
+		t.Fatalf("subscription to a preorder: status %d (%s)", status, resp.Error)
+	}
+
+	env.h.releasePreorders(ctx)
+	var status string
+	var subscriptions, mails int
+	env.db.Pool.QueryRow(ctx, "SELECT stock_status FROM products WHERE id = $1::uuid", productID).Scan(&status)
+	env.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM stock_alerts WHERE email = $1", email).Scan(&subscriptions)
+	env.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM notification_events WHERE recipient = $1 AND type = 'stock_alert'", email).Scan(&mails)
+	if status != "instock" || subscriptions != 0 || mails != 1 {
+		t.Errorf("after the release: %s, %d subscriptions, %d e-mails; want instock, 0 and 1", status, subscriptions, mails)
+	}
+}
+
+func TestFireStockAlertsQueuesInTransaction(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	productID := env.createTestProduct(t, "Kanvica", 39)
+	blocked := fmt.Sprintf("blocked-%d@example.com", time.Now().UnixNano())
+	if _, err := env.db.Pool.Exec(ctx, "INSERT INTO stock_alerts (product_id, email, lang, created_at) VALUES ($1::uuid, $2, 'sk', NOW())", productID, blocked); err != nil {
+		t.Fatal(err)
+	}
+	state := func() (subscriptions, queued int) {
+		env.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM stock_alerts WHERE email = $1", blocked).Scan(&subscriptions)
+		env.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM notification_events WHERE recipient = $1 AND type = 'stock_alert'", blocked).Scan(&queued)
+		return
+	}
+
+	// the outbox refuses the e-mail, so the subscription must be kept
+	if _, err := env.db.Pool.Exec(ctx, fmt.Sprintf("ALTER TABLE notification_events ADD CONSTRAINT test_blocked CHECK (recipient <> '%s') NOT VALID", blocked)); err != nil {
+		t.Fatal(err)
+	}
+	env.h.fireStockAlerts(ctx, []string{productID})
+	if subscriptions, queued := state(); subscriptions != 1 || queued != 0 {
+		t.Errorf("failed queue: %d subscriptions, %d e-mails; want the subscription kept", subscriptions, queued)
+	}
+
+	env.db.Pool.Exec(ctx, "ALTER TABLE notification_events DROP CONSTRAINT test_blocked")
+	env.h.fireStockAlerts(ctx, []string{productID})
+	if subscriptions, queued := state(); subscriptions != 0 || queued != 1 {
+		t.Errorf("next restock: %d subscriptions, %d e-mails; want the subscription consumed with one e-mail", subscriptions, queued)
+	}
+}
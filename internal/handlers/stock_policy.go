@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== OUT-OF-STOCK POLICY ==========
+
+const (
+	stockPolicyInherit = "inherit"
+	stockPolicyHide    = "hide"
+	stockPolicyDemote  = "demote"
+	stockPolicyShow    = "show"
+	stockPolicyTTL     = 30 * time.Second
+)
+
+var stockPolicies = []string{stockPolicyInherit, stockPolicyHide, stockPolicyDemote, stockPolicyShow}
+
+func validStockPolicy(policy string) bool {
+	for _, p := range stockPolicies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+func invalidStockPolicy(c *fiber.Ctx) error {
+	return c.Status(400).JSON(fiber.Map{"success": false, "error": "out_of_stock_policy must be inherit, hide, demote or show"})
+}
+
+// outOfStockPolicies holds every category's effective policy and the categories whose
+// out-of-stock products listings hide or demote
+type outOfStockPolicies struct {
+	effective    map[string]string
+	hide, demote []string
+}
+
+var (
+	stockPolicyMutex    sync.RWMutex
+	stockPolicyCache    *outOfStockPolicies
+	stockPolicyLoadedAt time.Time
+)
+
+// invalidateStockPolicies makes the next listing reload the policies after an admin change
+func invalidateStockPolicies() {
+	stockPolicyMutex.Lock()
+	stockPolicyCache = nil
+	stockPolicyMutex.Unlock()
+}
+
+// outOfStockPolicies resolves inherited policies over the category tree. A category
+// inherits its parent's policy; a root that inherits, or a loop, shows.
+func (h *Handlers) outOfStockPolicies(ctx context.Context) *outOfStockPolicies {
+	stockPolicyMutex.RLock()
+	cached, fresh := stockPolicyCache, time.Since(stockPolicyLoadedAt) < stockPolicyTTL
+	stockPolicyMutex.RUnlock()
+	if cached != nil && fresh {
+		return cached
+	}
+
+	parents, own := map[string]string{}, map[string]string{}
+	rows, err := h.db.ReadPool.Query(ctx, "SELECT id::text, COALESCE(parent_id::text,''), out_of_stock_policy FROM categories")
+	if err != nil {
+		if cached != nil {
+			return cached
+		}
+		return &outOfStockPolicies{effective: map[string]string{}}
+	}
+	for rows.Next() {
+		var id, parentID, policy string
+		rows.Scan(&id, &parentID, &policy)
+		parents[id], own[id] = parentID, policy
+	}
+	rows.Close()
+
+	p := &outOfStockPolicies{effective: make(map[string]string, len(own))}
+	var resolve func(id string, seen map[string]bool) string
+	resolve = func(id string, seen map[string]bool) string {
+		if policy, ok := p.effective[id]; ok {
+			return policy
+		}
+		policy, ok := own[id]
+		if !ok || seen[id] {
+			return stockPolicyShow
+		}
+		if policy == stockPolicyInherit {
+			seen[id] = true
+			policy = resolve(parents[id], seen)
+		}
+		p.effective[id] = policy
+		return policy
+	}
+	for id := range own {
+		switch resolve(id, map[string]bool{}) {
+		case stockPolicyHide:
+			p.hide = append(p.hide, id)
+		case stockPolicyDemote:
+			p.demote = append(p.demote, id)
+		}
+	}
+	// sorted so the search cache key does not depend on map order
+	sort.Strings(p.hide)
+	sort.Strings(p.demote)
+
+	stockPolicyMutex.Lock()
+	stockPolicyCache, stockPolicyLoadedAt = p, time.Now()
+	stockPolicyMutex.Unlock()
+	return p
+}
+
+// policyOf returns the effective policy of a category, "show" for unknown ones
+func (p *outOfStockPolicies) policyOf(categoryID string) string {
+	if policy, ok := p.effective[categoryID]; ok {
+		return policy
+	}
+	return stockPolicyShow
+}
+
+// outOfStockInCategories matches out-of-stock products of the categories in the
+// placeholder array; uncategorized products never match
+const outOfStockInCategories = "(COALESCE(p.stock_status,'instock') = 'outofstock' AND COALESCE(p.category_id = ANY(%s::uuid[]), false))"
+
+// applyToWhere hides the out-of-stock products of hiding categories
+func (p *outOfStockPolicies) applyToWhere(where *sqlWhere) {
+	if len(p.hide) > 0 {
+		where.add("NOT "+fmt.Sprintf(outOfStockInCategories, "?"), p.hide)
+	}
+}
+
+// hideCondition is applyToWhere for queries numbering their own placeholders; it
+// appends the argument to args and returns the condition, or "" when nothing is hidden
+func (p *outOfStockPolicies) hideCondition(args *[]interface{}) string {
+	if len(p.hide) == 0 {
+		return ""
+	}
+	*args = append(*args, p.hide)
+	return "NOT " + fmt.Sprintf(outOfStockInCategories, fmt.Sprintf("$%d", len(*args)))
+}
+
+// demoteOrder returns an ORDER BY prefix sorting out-of-stock products of demoting
+// categories after the rest, or "" when nothing is demoted; the argument is appended
+// to args
+func (p *outOfStockPolicies) demoteOrder(args *[]interface{}) string {
+	if len(p.demote) == 0 {
+		return ""
+	}
+	*args = append(*args, p.demote)
+	return fmt.Sprintf(outOfStockInCategories, fmt.Sprintf("$%d", len(*args))) + " ASC, "
+}
+
+// demoteOrderWhere is demoteOrder registering the argument with a builder
+func (p *outOfStockPolicies) demoteOrderWhere(w *sqlWhere) string {
+	if len(p.demote) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(outOfStockInCategories, w.arg(p.demote)) + " ASC, "
+}
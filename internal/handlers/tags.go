@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== INTERNAL PRODUCT TAGS ==========
+
+// Tags are free-form admin markers such as "christmas-2024" or "clearance-candidate".
+// Unlike labels they never reach shoppers: no public query, model or ES document
+// reads product_tags, so only the admin endpoints below expose them.
+
+const maxBulkTagItems = 50000
+
+type tagInput struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+// tagFilter selects products by explicit IDs or by attributes, so tags can be
+// assigned to everything matching a filter and bulk actions can target a tag
+type tagFilter struct {
+	ProductIDs []string `json:"product_ids,omitempty"`
+	CategoryID string   `json:"category_id,omitempty"`
+	Brand      string   `json:"brand,omitempty"`
+	FeedID     string   `json:"feed_id,omitempty"`
+	// TitlePattern matches case-insensitively; * is a wildcard, otherwise it is a substring
+	TitlePattern string `json:"title_pattern,omitempty"`
+	// Tag is the slug of a tag the products already carry
+	Tag string `json:"tag,omitempty"`
+}
+
+func (f tagFilter) empty() bool {
+	return len(f.ProductIDs) == 0 && f.CategoryID == "" && f.Brand == "" && f.FeedID == "" && f.TitlePattern == "" && f.Tag == ""
+}
+
+// validate returns the name of the first malformed field, or ""
+func (f tagFilter) validate() string {
+	for _, id := range f.ProductIDs {
+		if !isUUID(id) {
+			return "product_ids"
+		}
+	}
+	if f.CategoryID != "" && !isUUID(f.CategoryID) {
+		return "category_id"
+	}
+	if f.FeedID != "" && !isUUID(f.FeedID) {
+		return "feed_id"
+	}
+	return ""
+}
+
+// where builds the product condition; args start at $1
+func (f tagFilter) where() (string, []interface{}) {
+	conds := []string{"TRUE"}
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if len(f.ProductIDs) > 0 {
+		add("p.id = ANY($%d::uuid[])", f.ProductIDs)
+	}
+	if f.CategoryID != "" {
+		add("p.category_id = $%d::uuid", f.CategoryID)
+	}
+	if f.Brand != "" {
+		add("LOWER(p.brand) = LOWER($%d)", f.Brand)
+	}
+	if f.FeedID != "" {
+		add("p.feed_id = $%d::uuid", f.FeedID)
+	}
+	if f.TitlePattern != "" {
+		add("p.title ILIKE $%d", likePattern(f.TitlePattern))
+	}
+	if f.Tag != "" {
+		add("EXISTS (SELECT 1 FROM product_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.product_id = p.id AND t.slug = $%d)", f.Tag)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// filteredProductIDs resolves a filter to product IDs
+func (h *Handlers) filteredProductIDs(ctx context.Context, f tagFilter) ([]string, error) {
+	where, args := f.where()
+	return collectIDs(h.db.Pool.Query(ctx, "SELECT p.id::text FROM products p WHERE "+where, args...))
+}
+
+// productTags returns the tag slugs of each product, for admin views only
+func (h *Handlers) productTags(ctx context.Context, productIDs []string) map[string][]string {
+	tags := map[string][]string{}
+	if len(productIDs) == 0 {
+		return tags
+	}
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT pt.product_id::text, t.slug FROM product_tags pt JOIN tags t ON t.id = pt.tag_id
+		WHERE pt.product_id = ANY($1::uuid[]) ORDER BY t.slug
+	`, productIDs)
+	if err != nil {
+		return tags
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var productID, slug string
+		rows.Scan(&productID, &slug)
+		tags[productID] = append(tags[productID], slug)
+	}
+	return tags
+}
+
+func (h *Handlers) AdminListTags(c *fiber.Ctx) error {
+	ctx := context.Background()
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT t.id, t.slug, t.name, (SELECT COUNT(*) FROM product_tags pt WHERE pt.tag_id = t.id)
+		FROM tags t ORDER BY t.slug
+	`)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	defer rows.Close()
+	tags := []fiber.Map{}
+	for rows.Next() {
+		var id, slug, name string
+		var products int
+		rows.Scan(&id, &slug, &name, &products)
+		tags = append(tags, fiber.Map{"id": id, "slug": slug, "name": name, "products": products})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": tags})
+}
+
+func (h *Handlers) AdminCreateTag(c *fiber.Ctx) error {
+	var input tagInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	input.Slug = strings.TrimSpace(input.Slug)
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Slug == "" && input.Name == "" {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "name or slug is required"})
+	}
+	if input.Slug == "" {
+		input.Slug = makeSlug(input.Name)
+	}
+	if !labelSlugPattern.MatchString(input.Slug) {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "slug may contain only lowercase letters, digits and dashes"})
+	}
+	if input.Name == "" {
+		input.Name = input.Slug
+	}
+
+	ctx := context.Background()
+	var id string
+	err := h.db.Pool.QueryRow(ctx, `
+		INSERT INTO tags (slug, name, created_at) VALUES ($1, $2, NOW())
+		ON CONFLICT (slug) DO NOTHING RETURNING id
+	`, input.Slug, input.Name).Scan(&id)
+	if err != nil {
+		return c.Status(409).JSON(fiber.Map{"success": false, "error": "Tag with this slug already exists"})
+	}
+	h.audit(ctx, c, "tag.create", "tag", id, fiber.Map{"slug": input.Slug})
+	return c.Status(201).JSON(fiber.Map{"success": true, "data": fiber.Map{"id": id, "slug": input.Slug, "name": input.Name}})
+}
+
+func (h *Handlers) AdminDeleteTag(c *fiber.Ctx) error {
+	tagID := c.Params("id")
+	ctx := context.Background()
+	var slug string
+	if err := h.db.Pool.QueryRow(ctx, "DELETE FROM tags WHERE id = $1::uuid RETURNING slug", tagID).Scan(&slug); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Tag not found"})
+	}
+	h.audit(ctx, c, "tag.delete", "tag", tagID, fiber.Map{"slug": slug})
+	return c.JSON(fiber.Map{"success": true, "message": "Tag deleted"})
+}
+
+// AdminTagProduct adds one tag to a product
+func (h *Handlers) AdminTagProduct(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	var input struct {
+		TagID string `json:"tag_id"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if !isUUID(input.TagID) {
+		return invalidUUIDField(c, "tag_id")
+	}
+
+	ctx := context.Background()
+	var exists bool
+	h.db.Pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM tags WHERE id = $1::uuid)", input.TagID).Scan(&exists)
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Tag not found"})
+	}
+	h.db.Pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM products WHERE id = $1::uuid)", productID).Scan(&exists)
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Product not found"})
+	}
+	if _, err := h.db.Pool.Exec(ctx, `
+		INSERT INTO product_tags (product_id, tag_id, created_at) VALUES ($1::uuid, $2::uuid, NOW())
+		ON CONFLICT DO NOTHING
+	`, productID, input.TagID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": nonNilStrings(h.productTags(ctx, []string{productID})[productID])})
+}
+
+func (h *Handlers) AdminUntagProduct(c *fiber.Ctx) error {
+	tag, err := h.db.Pool.Exec(context.Background(), "DELETE FROM product_tags WHERE product_id = $1::uuid AND tag_id = $2::uuid", c.Params("id"), c.Params("tag_id"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Tag not assigned"})
+	}
+	return c.JSON(fiber.Map{"success": true, "message": "Tag removed"})
+}
+
+// AdminBulkTagProducts assigns or, with "remove": true, unassigns a tag for every
+// product matching the filter: explicit product_ids, category_id, brand, feed_id,
+// title_pattern or another tag. dry_run only counts the matches.
+func (h *Handlers) AdminBulkTagProducts(c *fiber.Ctx) error {
+	tagID := c.Params("id")
+	var input struct {
+		Remove bool `json:"remove"`
+		DryRun bool `json:"dry_run"`
+		tagFilter
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if input.empty() {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "product_ids or a filter is required"})
+	}
+	if field := input.validate(); field != "" {
+		return invalidUUIDField(c, field)
+	}
+
+	ctx := context.Background()
+	var slug string
+	if err := h.db.Pool.QueryRow(ctx, "SELECT slug FROM tags WHERE id = $1::uuid", tagID).Scan(&slug); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Tag not found"})
+	}
+	ids, err := h.filteredProductIDs(ctx, input.tagFilter)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if len(ids) > maxBulkTagItems {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": fmt.Sprintf("filter matches %d products, at most %d can be tagged at once", len(ids), maxBulkTagItems)})
+	}
+	if input.DryRun {
+		return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"dry_run": true, "matched": len(ids)}})
+	}
+
+	action := "tag.assign"
+	query := `
+		INSERT INTO product_tags (product_id, tag_id, created_at)
+		SELECT id, $2::uuid, NOW() FROM unnest($1::uuid[]) AS id
+		ON CONFLICT DO NOTHING
+	`
+	if input.Remove {
+		action = "tag.unassign"
+		query = "DELETE FROM product_tags WHERE product_id = ANY($1::uuid[]) AND tag_id = $2::uuid"
+	}
+	tag, err := h.db.Pool.Exec(ctx, query, ids, tagID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	filter := input.tagFilter
+	filter.ProductIDs = nil
+	h.audit(ctx, c, action, "tag", tagID, fiber.Map{"slug": slug, "filter": filter, "matched": len(ids), "changed": tag.RowsAffected()})
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{"matched": len(ids), "changed": tag.RowsAffected()}})
+}
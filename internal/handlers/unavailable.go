@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== UNAVAILABLE PRODUCTS ==========
+
+const (
+	maxUnavailableAlternatives = 6
+	// maxMergeHops bounds the merged_into_id chain followed to a live product
+	maxMergeHops = 10
+)
+
+// productGone answers 410 for an inactive product: its basic info flagged unavailable
+// and up to maxUnavailableAlternatives active products of the same category or brand.
+// A product merged into another carries redirect metadata to the live one, which also
+// leads the alternatives.
+func (h *Handlers) productGone(c *fiber.Ctx, ctx context.Context, p models.Product, priceMode string) error {
+	targetID, targetSlug := h.mergeTarget(ctx, p.ID)
+	alternatives, err := h.unavailableAlternatives(ctx, p, targetID, priceMode)
+	if err != nil {
+		alternatives = []relatedProduct{}
+	}
+	h.attachRelatedLabels(ctx, alternatives)
+	addRelatedDisplay(c, alternatives)
+
+	body := fiber.Map{"success": false, "error": "Product is no longer available", "data": fiber.Map{
+		"product": fiber.Map{
+			"id": p.ID, "title": p.Title, "slug": p.Slug, "image_url": p.ImageURL, "brand": p.Brand,
+			"category_name": p.CategoryName, "category_slug": p.CategorySlug, "available": false,
+		},
+		"alternatives": alternatives,
+	}}
+	if targetSlug != "" {
+		redirect := slugRedirect("product", targetSlug)
+		redirect["reason"] = "merged"
+		body["redirect"] = redirect
+	}
+	return c.Status(410).JSON(body)
+}
+
+// mergeTarget follows merged_into_id from productID to the first active product;
+// empty when the product was not merged or the chain ends in inactive products
+func (h *Handlers) mergeTarget(ctx context.Context, productID string) (id, slug string) {
+	h.db.ReadPool.QueryRow(ctx, `
+		WITH RECURSIVE chain AS (
+			SELECT p.merged_into_id AS id, 1 AS hop FROM products p WHERE p.id = $1::uuid
+			UNION ALL
+			SELECT p.merged_into_id, chain.hop + 1
+			FROM chain JOIN products p ON p.id = chain.id
+			WHERE p.is_active = false AND chain.hop < $2
+		)
+		SELECT p.id::text, p.slug FROM chain JOIN products p ON p.id = chain.id
+		WHERE p.is_active = true ORDER BY chain.hop LIMIT 1
+	`, productID, maxMergeHops).Scan(&id, &slug)
+	return id, slug
+}
+
+// unavailableAlternatives lists active products sharing the category or brand of p:
+// the merge target first, then same category and brand, same category, same brand,
+// in stock before out of stock, most popular first
+func (h *Handlers) unavailableAlternatives(ctx context.Context, p models.Product, targetID, priceMode string) ([]relatedProduct, error) {
+	if p.CategoryID == "" && p.Brand == "" && targetID == "" {
+		return []relatedProduct{}, nil
+	}
+	rows, err := h.db.ReadPool.Query(ctx, `
+		SELECT `+productCardColumns(priceMode)+`,
+		       CASE WHEN p.id = NULLIF($4,'')::uuid THEN 'replacement'
+		            WHEN $3 <> '' AND p.brand = $3 THEN 'same_brand' ELSE 'similar' END, 0
+		FROM products p LEFT JOIN categories c ON p.category_id = c.id
+		WHERE p.is_active = true AND p.id <> $1::uuid
+		  AND (p.id = NULLIF($4,'')::uuid OR p.category_id = NULLIF($2,'')::uuid OR ($3 <> '' AND p.brand = $3))
+		ORDER BY p.id = NULLIF($4,'')::uuid DESC,
+		         COALESCE(p.category_id = NULLIF($2,'')::uuid, false) DESC, ($3 <> '' AND p.brand = $3) DESC,
+		         COALESCE(p.stock_status,'instock') <> 'outofstock' DESC,
+		         `+popularityExpr+` DESC NULLS LAST, p.created_at DESC
+		LIMIT $5
+	`, p.ID, p.CategoryID, p.Brand, targetID, maxUnavailableAlternatives)
+	if err != nil {
+		return nil, err
+	}
+	return scanRelatedProducts(rows), nil
+}
+
+// AdminMergeProduct merges a product into another: it is deactivated and its page
+// answers 410 with redirect metadata to the product it was merged into
+func (h *Handlers) AdminMergeProduct(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	var input struct {
+		IntoID string `json:"into_id"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid request"})
+	}
+	if !isUUID(input.IntoID) {
+		return invalidUUIDField(c, "into_id")
+	}
+	if input.IntoID == productID {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "A product cannot be merged into itself"})
+	}
+
+	ctx := context.Background()
+	var targetActive bool
+	if err := h.db.Pool.QueryRow(ctx, "SELECT is_active FROM products WHERE id = $1::uuid", input.IntoID).Scan(&targetActive); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Target product not found"})
+	}
+	if !targetActive {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Target product is not active"})
+	}
+	tag, err := h.db.Pool.Exec(ctx, `
+		UPDATE products SET merged_into_id = $2::uuid, is_active = false, updated_at = NOW(), version = COALESCE(version,1) + 1
+		WHERE id = $1::uuid
+	`, productID, input.IntoID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Product not found"})
+	}
+	h.queueESSync(productID)
+	h.audit(ctx, c, "product.merge", "product", productID, fiber.Map{"into_id": input.IntoID})
+	return c.JSON(fiber.Map{"success": true, "message": "Product merged", "data": fiber.Map{"id": productID, "merged_into_id": input.IntoID}})
+}
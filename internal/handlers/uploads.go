@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ========== IMAGE UPLOADS ==========
+
+const (
+	uploadDir          = "./uploads"
+	maxUploadFileSize  = 10 * 1024 * 1024
+	maxUploadFiles     = 20
+	maxUploadBatchSize = 40 * 1024 * 1024
+	uploadWorkers      = 4
+	// maxImageDimension rejects images too large to be product photos
+	maxImageDimension = 10000
+)
+
+// uploadImageTypes maps the accepted sniffed content types to the stored extension
+var uploadImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// uploadResult is the outcome for one uploaded file; Error is set for rejected files
+type uploadResult struct {
+	Name     string `json:"name"`
+	URL      string `json:"url,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// saveImageUpload validates an uploaded image by its content rather than its name and
+// stores it under a fresh name. The returned error is a reason fit for the client.
+func saveImageUpload(file *multipart.FileHeader, baseURL string) (uploadResult, error) {
+	res := uploadResult{Name: file.Filename}
+	if file.Size > maxUploadFileSize {
+		return res, fmt.Errorf("file exceeds %d MB", maxUploadFileSize/(1024*1024))
+	}
+	f, err := file.Open()
+	if err != nil {
+		return res, errors.New("file could not be read")
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxUploadFileSize+1))
+	f.Close()
+	if err != nil {
+		return res, errors.New("file could not be read")
+	}
+	if len(data) > maxUploadFileSize {
+		return res, fmt.Errorf("file exceeds %d MB", maxUploadFileSize/(1024*1024))
+	}
+
+	ext, ok := uploadImageTypes[http.DetectContentType(data)]
+	if !ok {
+		return res, errors.New("not a JPEG, PNG, GIF or WebP image")
+	}
+	width, height, err := imageSize(data, ext)
+	if err != nil {
+		return res, errors.New("image is corrupt or truncated")
+	}
+	if width <= 0 || height <= 0 || width > maxImageDimension || height > maxImageDimension {
+		return res, fmt.Errorf("image dimensions must be between 1 and %d pixels", maxImageDimension)
+	}
+
+	os.MkdirAll(uploadDir, 0755)
+	filename := uuid.New().String() + ext
+	if err := os.WriteFile(filepath.Join(uploadDir, filename), data, 0644); err != nil {
+		return res, errors.New("failed to save file")
+	}
+	res.Filename, res.Width, res.Height = filename, width, height
+	res.URL = fmt.Sprintf("%s/uploads/%s", baseURL, filename)
+	return res, nil
+}
+
+// imageSize reads the dimensions from the image header; WebP has no decoder in the
+// standard library, so its header is parsed directly
+func imageSize(data []byte, ext string) (int, int, error) {
+	if ext == ".webp" {
+		return webpSize(data)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// webpSize handles the lossy (VP8), lossless (VP8L) and extended (VP8X) formats
+func webpSize(data []byte) (int, int, error) {
+	errInvalid := errors.New("invalid WebP header")
+	if len(data) < 30 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return 0, 0, errInvalid
+	}
+	chunk := data[12:]
+	switch string(chunk[0:4]) {
+	case "VP8 ":
+		if chunk[11] != 0x9d || chunk[12] != 0x01 || chunk[13] != 0x2a {
+			return 0, 0, errInvalid
+		}
+		w := int(binary.LittleEndian.Uint16(chunk[14:16]) & 0x3fff)
+		h := int(binary.LittleEndian.Uint16(chunk[16:18]) & 0x3fff)
+		return w, h, nil
+	case "VP8L":
+		if chunk[8] != 0x2f {
+			return 0, 0, errInvalid
+		}
+		bits := binary.LittleEndian.Uint32(chunk[9:13])
+		return int(bits&0x3fff) + 1, int((bits>>14)&0x3fff) + 1, nil
+	case "VP8X":
+		w := int(chunk[12]) | int(chunk[13])<<8 | int(chunk[14])<<16
+		h := int(chunk[15]) | int(chunk[16])<<8 | int(chunk[17])<<16
+		return w + 1, h + 1, nil
+	}
+	return 0, 0, errInvalid
+}
+
+// UploadImages stores the multipart "files" concurrently and reports per file, so one
+// rejected file does not fail the batch. The request fails only when the batch itself
+// is too large.
+func (h *Handlers) UploadImages(c *fiber.Ctx) error {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "Invalid multipart form"})
+	}
+	files := form.File["files"]
+	if len(files) == 0 {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": "No files uploaded"})
+	}
+	if len(files) > maxUploadFiles {
+		return c.Status(400).JSON(fiber.Map{"success": false, "error": fmt.Sprintf("At most %d files per upload", maxUploadFiles)})
+	}
+	var total int64
+	for _, file := range files {
+		total += file.Size
+	}
+	if total > maxUploadBatchSize {
+		return c.Status(413).JSON(fiber.Map{"success": false, "error": fmt.Sprintf("Upload exceeds %d MB in total", maxUploadBatchSize/(1024*1024))})
+	}
+
+	baseURL := c.BaseURL()
+	results := make([]uploadResult, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < uploadWorkers && w < len(files); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				res, err := saveImageUpload(files[i], baseURL)
+				if err != nil {
+					res.Error = err.Error()
+				}
+				results[i] = res
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	uploaded := 0
+	for _, res := range results {
+		if res.Error == "" {
+			uploaded++
+		}
+	}
+	return c.JSON(fiber.Map{"success": true, "data": fiber.Map{
+		"files": results, "uploaded": uploaded, "rejected": len(results) - uploaded,
+	}})
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type uploadFile struct {
+	name string
+	data []byte
+}
+
+func encodedImage(t *testing.T, format string, w, h int) []byte {
+	t.Helper()
+	img := image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{color.White, color.Black})
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// losslessWebP is the header of a VP8L WebP of w×h pixels, which is all the
+// validation reads
+func losslessWebP(w, h int) []byte {
+	chunk := make([]byte, 5+4+20)
+	chunk[0] = 0x2f
+	binary.LittleEndian.PutUint32(chunk[1:5], uint32(w-1)|uint32(h-1)<<14)
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(4+8+len(chunk)))
+	buf.WriteString("WEBPVP8L")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(chunk)))
+	buf.Write(chunk)
+	return buf.Bytes()
+}
+
+// inUploadDir runs the test from a temporary directory so uploads land there
+func inUploadDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return filepath.Join(dir, uploadDir)
+}
+
+func postUploads(t *testing.T, field string, files []uploadFile) (int, testEnvelope) {
+	t.Helper()
+	app := fiber.New(fiber.Config{BodyLimit: 50 * 1024 * 1024})
+	app.Post("/upload/multiple", (&Handlers{}).UploadImages)
+	app.Post("/upload", (&Handlers{}).UploadImage)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, f := range files {
+		part, err := mw.CreateFormFile(field, f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(f.data)
+	}
+	mw.Close()
+	path := "/upload/multiple"
+	if field == "file" {
+		path = "/upload"
+	}
+	req := httptest.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out testEnvelope
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, out
+}
+
+func TestUploadImagesMixedBatch(t *testing.T) {
+	dir := inUploadDir(t)
+	pngData := encodedImage(t, "png", 40, 30)
+	files := []uploadFile{
+		{"foto.png", pngData},
+		{"foto.jpg", encodedImage(t, "jpeg", 64, 48)},
+		{"animacia.gif", encodedImage(t, "gif", 8, 8)},
+		{"foto.webp", losslessWebP(800, 600)},
+		{"faktura.jpg", []byte("%PDF-1.4 not an image at all")},
+		{"script.png", []byte("<script>alert(1)</script>")},
+		{"odrezany.png", pngData[:len(pngData)/2]},
+		{"obrovsky.png", encodedImage(t, "png", maxImageDimension+1, 1)},
+		{"velky.jpg", make([]byte, maxUploadFileSize+1)},
+		{"prazdny.png", nil},
+		// named as a JPEG but sniffed as PNG: stored by its content
+		{"premenovany.jpg", encodedImage(t, "png", 2, 2)},
+	}
+	status, resp := postUploads(t, "files", files)
+	if status != 200 || !resp.Success {
+		t.Fatalf("%d %s", status, resp.Error)
+	}
+	var out struct {
+		Files    []uploadResult `json:"files"`
+		Uploaded int            `json:"uploaded"`
+		Rejected int            `json:"rejected"`
+	}
+	if err := json.Unmarshal(resp.Data, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		ext, err string
+		w, h     int
+	}{
+		{".png", "", 40, 30},
+		{".jpg", "", 64, 48},
+		{".gif", "", 8, 8},
+		{".webp", "", 800, 600},
+		{"", "not a JPEG, PNG, GIF or WebP image", 0, 0},
+		{"", "not a JPEG, PNG, GIF or WebP image", 0, 0},
+		{"", "image is corrupt or truncated", 0, 0},
+		{"", "image dimensions must be between 1 and 10000 pixels", 0, 0},
+		{"", "file exceeds 10 MB", 0, 0},
+		{"", "not a JPEG, PNG, GIF or WebP image", 0, 0},
+		{".png", "", 2, 2},
+	}
+	if len(out.Files) != len(want) || out.Uploaded != 5 || out.Rejected != 6 {
+		t.Fatalf("%d results, %d uploaded, %d rejected", len(out.Files), out.Uploaded, out.Rejected)
+	}
+	for i, w := range want {
+		got := out.Files[i]
+		if got.Name != files[i].name {
+			t.Errorf("result %d is for %s, want %s: results keep the request order", i, got.Name, files[i].name)
+		}
+		if got.Error != w.err || got.Width != w.w || got.Height != w.h || filepath.Ext(got.Filename) != w.ext {
+			t.Errorf("%s: %+v", files[i].name, got)
+		}
+		if w.err != "" {
+			if got.URL != "" || got.Filename != "" {
+				t.Errorf("%s was rejected but has a URL: %+v", files[i].name, got)
+			}
+			continue
+		}
+		if !strings.HasSuffix(got.URL, "/uploads/"+got.Filename) {
+			t.Errorf("%s: URL %s", files[i].name, got.URL)
+		}
+		// stored under a fresh name, never the client's
+		stored, err := os.ReadFile(filepath.Join(dir, got.Filename))
+		if err != nil || !bytes.Equal(stored, files[i].data) || strings.Contains(got.Filename, "foto") {
+			t.Errorf("%s stored as %s: %v", files[i].name, got.Filename, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 5 {
+		t.Errorf("%d files in the upload directory, want the 5 accepted", len(entries))
+	}
+}
+
+func TestUploadImagesBatchLimits(t *testing.T) {
+	inUploadDir(t)
+	if status, resp := postUploads(t, "files", nil); status != 400 || resp.Error != "No files uploaded" {
+		t.Errorf("no files: %d %s", status, resp.Error)
+	}
+
+	tiny := encodedImage(t, "png", 1, 1)
+	many := make([]uploadFile, maxUploadFiles+1)
+	for i := range many {
+		many[i] = uploadFile{"foto.png", tiny}
+	}
+	if status, resp := postUploads(t, "files", many); status != 400 || resp.Error != "At most 20 files per upload" {
+		t.Errorf("%d files: %d %s", len(many), status, resp.Error)
+	}
+
+	// each file is under the per-file limit, together they are over the batch limit
+	large := make([]uploadFile, 5)
+	for i := range large {
+		large[i] = uploadFile{"foto.png", make([]byte, 9*1024*1024)}
+	}
+	if status, resp := postUploads(t, "files", large); status != 413 || !strings.Contains(resp.Error, "40 MB") {
+		t.Errorf("45 MB batch: %d %s", status, resp.Error)
+	}
+}
+
+func TestUploadImageUsesSameValidation(t *testing.T) {
+	inUploadDir(t)
+	status, resp := postUploads(t, "file", []uploadFile{{"foto.jpg", []byte("GIF89a")}})
+	if status != 400 || resp.Error != "image is corrupt or truncated" {
+		t.Errorf("truncated GIF: %d %s", status, resp.Error)
+	}
+	status, resp = postUploads(t, "file", []uploadFile{{"foto.webp", losslessWebP(1200, 900)}})
+	var data map[string]any
+	json.Unmarshal(resp.Data, &data)
+	if status != 200 || data["width"] != float64(1200) || data["height"] != float64(900) {
+		t.Errorf("WebP: %d %s %v", status, resp.Error, data)
+	}
+}
+
+func TestWebPSize(t *testing.T) {
+	vp8 := make([]byte, 30)
+	copy(vp8, "RIFF\x00\x00\x00\x00WEBPVP8 ")
+	copy(vp8[12+11:], []byte{0x9d, 0x01, 0x2a})
+	binary.LittleEndian.PutUint16(vp8[12+14:], 640)
+	binary.LittleEndian.PutUint16(vp8[12+16:], 480)
+	vp8x := make([]byte, 30)
+	copy(vp8x, "RIFF\x00\x00\x00\x00WEBPVP8X")
+	vp8x[12+12], vp8x[12+13] = 0xff, 0x0f // 4095 + 1
+	vp8x[12+15] = 0x63                    // 99 + 1
+
+	tests := []struct {
+		name string
+		data []byte
+		w, h int
+		ok   bool
+	}{
+		{"lossy", vp8, 640, 480, true},
+		{"lossless", losslessWebP(1, 16384), 1, 16384, true},
+		{"extended", vp8x, 4096, 100, true},
+		{"short", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), 0, 0, false},
+		{"bad lossy signature", append(append([]byte{}, vp8[:23]...), make([]byte, 7)...), 0, 0, false},
+		{"unknown chunk", append([]byte("RIFF\x00\x00\x00\x00WEBPALPH"), make([]byte, 20)...), 0, 0, false},
+	}
+	for _, tt := range tests {
+		w, h, err := webpSize(tt.data)
+		if (err == nil) != tt.ok || w != tt.w || h != tt.h {
+			t.Errorf("%s: %d×%d, %v", tt.name, w, h, err)
+		}
+	}
+}
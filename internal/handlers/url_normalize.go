@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== FEED URL NORMALIZATION ==========
+
+const (
+	maxAffiliateURLLength = 2000
+	// maxInvalidURLLogs caps the invalid URLs listed in one import log
+	maxInvalidURLLogs = 20
+)
+
+// feedURLNormalizer cleans the URLs of one feed's items: protocol-relative and
+// relative values are resolved, unsafe characters percent-encoded and anything that
+// still is not an absolute http(s) URL is dropped
+type feedURLNormalizer struct {
+	base       *url.URL
+	forceHTTPS bool
+}
+
+func newFeedURLNormalizer(feed models.Feed) feedURLNormalizer {
+	n := feedURLNormalizer{forceHTTPS: feed.ForceHTTPS}
+	if u, err := url.Parse(feed.URL); err == nil && u.Host != "" {
+		n.base = u
+	}
+	return n
+}
+
+// normalize returns the cleaned URL, or ok=false when raw cannot be turned into an
+// absolute http(s) URL of at most maxLen characters
+func (n feedURLNormalizer) normalize(raw string, maxLen int) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", false
+	}
+	if strings.HasPrefix(raw, "//") {
+		raw = "https:" + raw
+	}
+	u, err := url.Parse(escapeUnsafeURLChars(raw))
+	if err != nil {
+		return "", false
+	}
+	if !u.IsAbs() {
+		if n.base == nil || u.Host != "" || !looksLikePath(raw) {
+			return "", false
+		}
+		u = n.base.ResolveReference(u)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false
+	}
+	if u.Host == "" || strings.ContainsAny(u.Host, " <>\"{}|\\^`") {
+		return "", false
+	}
+	if n.forceHTTPS && u.Scheme == "http" {
+		u.Scheme = "https"
+	}
+	normalized := u.String()
+	if len(normalized) > maxLen {
+		return "", false
+	}
+	return normalized, true
+}
+
+// normalizeFields cleans image_url and affiliate_url of mapped item data in place.
+// Invalid values are removed and returned as "field: value" for the import log.
+func (n feedURLNormalizer) normalizeFields(data map[string]interface{}) []string {
+	var invalid []string
+	for field, maxLen := range map[string]int{"image_url": maxMediaURLLength, "affiliate_url": maxAffiliateURLLength} {
+		raw := getStr(data, field)
+		if raw == "" {
+			continue
+		}
+		if normalized, ok := n.normalize(raw, maxLen); ok {
+			data[field] = normalized
+		} else {
+			delete(data, field)
+			invalid = append(invalid, fmt.Sprintf("%s: %s", field, raw))
+		}
+	}
+	return invalid
+}
+
+// escapeUnsafeURLChars percent-encodes spaces, control and non-ASCII bytes and the
+// characters RFC 3986 never allows unescaped. A '%' not starting an escape becomes %25.
+func escapeUnsafeURLChars(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '%' && i+2 < len(s) && isHexDigit(s[i+1]) && isHexDigit(s[i+2]):
+			b.WriteByte(c)
+		case c <= 0x20 || c >= 0x7f || c == '%' || strings.IndexByte("\"<>\\^`{|}", c) >= 0:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// looksLikePath tells a relative path ("/img/1.jpg", "img/1.jpg") from placeholder
+// text such as "N/A" or "none": it must be rooted or end in a file name with an extension
+func looksLikePath(s string) bool {
+	if strings.HasPrefix(s, "/") {
+		return true
+	}
+	s, _, _ = strings.Cut(s, "?")
+	last := s[strings.LastIndex(s, "/")+1:]
+	return strings.Contains(last, ".") && !strings.HasPrefix(last, ".")
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
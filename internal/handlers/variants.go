@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"megabuy-go/internal/models"
+)
+
+// ========== PRODUCT VARIANTS ==========
+
+// productVariant is one option of a configurable product: its card, SKU and its value
+// for each distinguishing dimension
+type productVariant struct {
+	models.ProductCard
+	SKU     string            `json:"sku"`
+	Options map[string]string `json:"options"`
+	InStock bool              `json:"in_stock"`
+}
+
+// variantDimension is an attribute whose value differs between the variants. A value
+// is in stock when at least one variant carrying it is.
+type variantDimension struct {
+	Name   string         `json:"name"`
+	Slug   string         `json:"slug"`
+	Values []variantValue `json:"values"`
+}
+
+type variantValue struct {
+	Value   string `json:"value"`
+	InStock bool   `json:"in_stock"`
+}
+
+// variantKeySeparator joins a variant's dimension values, in dimension order, into its
+// matrix key
+const variantKeySeparator = "|"
+
+// GetProductVariants returns the option matrix of a product imported with an
+// ITEMGROUP_ID: the attributes that tell its variants apart with their values, every
+// active variant of the group (out-of-stock ones flagged) and a matrix of the existing
+// combinations, keyed by the dimension values joined with "|", so the product page can
+// disable impossible ones. ?options=name:value,... resolves a combination, matched by
+// dimension name or slug, to a variant, preferring one in stock. A product without a
+// group is its own only variant.
+func (h *Handlers) GetProductVariants(c *fiber.Ctx) error {
+	productID := c.Params("id")
+	priceMode, ok := priceModeParam(c)
+	if !ok {
+		return invalidPriceMode(c)
+	}
+	ctx := context.Background()
+
+	var feedID, groupID string
+	if err := h.db.Pool.QueryRow(ctx, "SELECT COALESCE(feed_id::text,''), COALESCE(item_group_id,'') FROM products WHERE id = $1::uuid AND is_active = true", productID).Scan(&feedID, &groupID); err != nil {
+		return c.Status(404).JSON(fiber.Map{"success": false, "error": "Product not found"})
+	}
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT `+productCardColumns(priceMode)+`, COALESCE(p.sku,'')
+		FROM products p LEFT JOIN categories c ON p.category_id = c.id
+		WHERE p.id = $1::uuid
+		   OR ($2 <> '' AND p.feed_id = NULLIF($3,'')::uuid AND p.item_group_id = $2 AND p.is_active = true)
+		ORDER BY COALESCE(p.sku,''), p.id
+	`, productID, groupID, feedID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+	var cards []models.ProductCard
+	var skus []string
+	for rows.Next() {
+		var sku string
+		if p, err := scanProductCard(rows, &sku); err == nil {
+			cards = append(cards, p)
+			skus = append(skus, sku)
+		}
+	}
+	rows.Close()
+	h.attachLabels(ctx, cards)
+	addListDisplay(c, cards)
+
+	ids := make([]string, len(cards))
+	for i, p := range cards {
+		ids[i] = p.ID
+	}
+	attributes, names, err := h.variantAttributes(ctx, ids)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"success": false, "error": err.Error()})
+	}
+
+	variants := make([]productVariant, len(cards))
+	for i, p := range cards {
+		variants[i] = productVariant{ProductCard: p, SKU: skus[i], Options: map[string]string{}, InStock: p.StockStatus != "outofstock"}
+	}
+	dimensions := variantDimensions(variants, attributes, names)
+	matrix := map[string]fiber.Map{}
+	for _, v := range variants {
+		values := make([]string, len(dimensions))
+		for i, d := range dimensions {
+			values[i] = v.Options[d.Name]
+		}
+		key := strings.Join(values, variantKeySeparator)
+		// duplicate combinations resolve to the first variant in stock
+		if existing, ok := matrix[key]; ok && (existing["in_stock"].(bool) || !v.InStock) {
+			continue
+		}
+		matrix[key] = fiber.Map{"variant_id": v.ID, "in_stock": v.InStock}
+	}
+
+	data := fiber.Map{
+		"product_id": productID, "item_group_id": groupID,
+		"dimensions": dimensions, "variants": variants, "matrix": matrix,
+	}
+	if raw := c.Query("options"); raw != "" {
+		selected, ok := parseVariantOptions(raw, dimensions)
+		if !ok {
+			return c.Status(400).JSON(fiber.Map{"success": false, "error": "options must be name:value pairs of the product's dimensions"})
+		}
+		data["resolved"] = resolveVariant(variants, selected)
+	}
+	return c.JSON(fiber.Map{"success": true, "data": data})
+}
+
+// variantAttributes loads the first value of each attribute of the products, keyed by
+// product ID and name, and the attribute names in display order
+func (h *Handlers) variantAttributes(ctx context.Context, ids []string) (map[string]map[string]string, []string, error) {
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT product_id::text, name, value FROM product_attributes
+		WHERE product_id = ANY($1::uuid[]) AND COALESCE(name,'') <> ''
+		ORDER BY position, name
+	`, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	attributes := map[string]map[string]string{}
+	var names []string
+	seen := map[string]bool{}
+	for rows.Next() {
+		var productID, name, value string
+		if err := rows.Scan(&productID, &name, &value); err != nil {
+			return nil, nil, err
+		}
+		if attributes[productID] == nil {
+			attributes[productID] = map[string]string{}
+		}
+		if _, ok := attributes[productID][name]; !ok {
+			attributes[productID][name] = value
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return attributes, names, rows.Err()
+}
+
+// variantDimensions keeps the attributes whose value is not the same on every variant
+// and fills in each variant's Options with its values for them
+func variantDimensions(variants []productVariant, attributes map[string]map[string]string, names []string) []variantDimension {
+	dimensions := []variantDimension{}
+	if len(variants) < 2 {
+		return dimensions
+	}
+	for _, name := range names {
+		first := attributes[variants[0].ID][name]
+		distinct := false
+		for _, v := range variants[1:] {
+			if attributes[v.ID][name] != first {
+				distinct = true
+				break
+			}
+		}
+		if !distinct {
+			continue
+		}
+		d := variantDimension{Name: name, Slug: makeSlug(name), Values: []variantValue{}}
+		index := map[string]int{}
+		for _, v := range variants {
+			value := attributes[v.ID][name]
+			v.Options[name] = value
+			if value == "" {
+				continue
+			}
+			i, ok := index[value]
+			if !ok {
+				i = len(d.Values)
+				index[value] = i
+				d.Values = append(d.Values, variantValue{Value: value})
+			}
+			d.Values[i].InStock = d.Values[i].InStock || v.InStock
+		}
+		dimensions = append(dimensions, d)
+	}
+	return dimensions
+}
+
+// parseVariantOptions reads name:value pairs, mapping each name or slug to its dimension
+func parseVariantOptions(raw string, dimensions []variantDimension) (map[string]string, bool) {
+	selected := map[string]string{}
+	for _, pair := range splitList(raw) {
+		name, value, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, false
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		found := false
+		for _, d := range dimensions {
+			if strings.EqualFold(d.Name, name) || d.Slug == name {
+				selected[d.Name], found = value, true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return selected, true
+}
+
+// resolveVariant picks the variant carrying all selected values, in stock first; nil
+// when the combination does not exist
+func resolveVariant(variants []productVariant, selected map[string]string) fiber.Map {
+	var match *productVariant
+	for i, v := range variants {
+		matches := true
+		for name, value := range selected {
+			if !strings.EqualFold(v.Options[name], value) {
+				matches = false
+				break
+			}
+		}
+		if matches && (match == nil || (!match.InStock && v.InStock)) {
+			match = &variants[i]
+		}
+	}
+	if match == nil {
+		return nil
+	}
+	return fiber.Map{"variant_id": match.ID, "sku": match.SKU, "in_stock": match.InStock, "options": match.Options}
+}
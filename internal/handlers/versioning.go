@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ========== OPTIMISTIC CONCURRENCY ==========
+
+// versionETag formats a product edit version as a strong ETag
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// requestedVersion reads the version an update was based on, from If-Match or
+// the body. ok is false when neither carries a usable version.
+func requestedVersion(c *fiber.Ctx, bodyVersion *int) (int, bool) {
+	if match := strings.TrimSpace(c.Get(fiber.HeaderIfMatch)); match != "" {
+		match = strings.Trim(strings.TrimPrefix(match, "W/"), `"`)
+		version, err := strconv.Atoi(match)
+		return version, err == nil
+	}
+	if bodyVersion != nil {
+		return *bodyVersion, true
+	}
+	return 0, false
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+)
+
+func TestRequestedVersion(t *testing.T) {
+	five := 5
+	tests := []struct {
+		ifMatch     string
+		bodyVersion *int
+		want        int
+		ok          bool
+	}{
+		{`"3"`, nil, 3, true},
+		{`W/"3"`, nil, 3, true},
+		{` "3" `, nil, 3, true},
+		{"3", nil, 3, true},
+		{`"3"`, &five, 3, true}, // If-Match takes precedence
+		{"", &five, 5, true},
+		{"", nil, 0, false},
+		{`"abc"`, &five, 0, false},
+		{`*`, nil, 0, false},
+		{`"3", "4"`, nil, 0, false},
+	}
+	for _, tt := range tests {
+		app := fiber.New()
+		var got int
+		var ok bool
+		app.Put("/", func(c *fiber.Ctx) error {
+			got, ok = requestedVersion(c, tt.bodyVersion)
+			return nil
+		})
+		req := httptest.NewRequest("PUT", "/", nil)
+		if tt.ifMatch != "" {
+			req.Header.Set("If-Match", tt.ifMatch)
+		}
+		if _, err := app.Test(req, -1); err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("If-Match %q, body version %v: %d, %v; want %d, %v", tt.ifMatch, tt.bodyVersion, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestVersionETag(t *testing.T) {
+	if got := versionETag(12); got != `"12"` {
+		t.Errorf("versionETag(12) = %s", got)
+	}
+}
+
+// TestProductUpdateRequiresVersion needs no database: the version is checked first
+func TestProductUpdateRequiresVersion(t *testing.T) {
+	app := fiber.New()
+	app.Use(recover.New())
+	app.Put("/admin/products/:id", (&Handlers{}).AdminUpdateProduct)
+	status, body := send(t, app, "PUT", "/admin/products/"+testUUID, `{"title":"Kávovar"}`)
+	if status != 428 || !strings.Contains(body, "If-Match header or version field required") {
+		t.Errorf("update without a version: %d %s", status, body)
+	}
+}
+
+func TestConcurrentProductUpdates(t *testing.T) {
+	env := newTestEnv(t)
+	id := env.createTestProduct(t, "Kávovar", 199)
+	app := fiber.New()
+	app.Get("/admin/products/:id", env.h.AdminGetProduct)
+	app.Put("/admin/products/:id", env.h.AdminUpdateProduct)
+
+	update := func(ifMatch string, body map[string]any) (int, string, testEnvelope) {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest("PUT", "/admin/products/"+id, strings.NewReader(string(b)))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out testEnvelope
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, resp.Header.Get("ETag"), out
+	}
+	edit := func(title string) map[string]any {
+		return map[string]any{"title": title, "price_min": 199, "price_max": 199, "is_active": true}
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/admin/products/"+id, nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("new product has ETag %q, want \"1\"", etag)
+	}
+
+	// two editors saving on top of the same read: the second gets the current product
+	status, next, out := update(etag, edit("Kávovar A"))
+	if status != 200 || next != `"2"` {
+		t.Fatalf("first save: %d, ETag %q, %s", status, next, out.Error)
+	}
+	status, current, out := update(etag, edit("Kávovar B"))
+	if status != 409 || current != `"2"` {
+		t.Fatalf("stale save: %d, ETag %q, %s", status, current, out.Error)
+	}
+	var product map[string]any
+	if err := json.Unmarshal(out.Data, &product); err != nil {
+		t.Fatal(err)
+	}
+	if product["title"] != "Kávovar A" || product["version"] != float64(2) {
+		t.Errorf("conflict returned %v %v, want the first editor's version 2", product["title"], product["version"])
+	}
+
+	// the body version works without If-Match
+	body := edit("Kávovar C")
+	body["version"] = 2
+	if status, next, out := update("", body); status != 200 || next != `"3"` {
+		t.Fatalf("save with the body version: %d, ETag %q, %s", status, next, out.Error)
+	}
+	if status, _, _ := update(fmt.Sprintf(`W/"%d"`, 3), edit("Kávovar D")); status != 200 {
+		t.Errorf("save with a weak If-Match: %d", status)
+	}
+
+	gone := "00000000-0000-0000-0000-000000001687"
+	req := httptest.NewRequest("PUT", "/admin/products/"+gone, strings.NewReader(`{"title":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
+	if resp, err := app.Test(req, -1); err != nil {
+		t.Fatal(err)
+	} else if resp.StatusCode != 404 {
+		t.Errorf("update of a missing product: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,60 @@
+package models
+
+// Category is a node of the public category tree
+type Category struct {
+	ID           string      `json:"id"`
+	ParentID     string      `json:"parent_id,omitempty"`
+	Name         string      `json:"name"`
+	Slug         string      `json:"slug"`
+	Icon         string      `json:"icon,omitempty"`
+	IconURL      string      `json:"icon_url,omitempty"`
+	ProductCount int         `json:"product_count"`
+	InStockCount int         `json:"in_stock_count"`
+	Children     []*Category `json:"children,omitempty"`
+}
+
+// BuildCategoryTree links categories to their parents and returns the roots.
+// Categories whose parent is not in the list are dropped.
+func BuildCategoryTree(cats []*Category) []*Category {
+	byID := make(map[string]*Category, len(cats))
+	for _, cat := range cats {
+		byID[cat.ID] = cat
+	}
+	roots := []*Category{}
+	for _, cat := range cats {
+		if cat.ParentID == "" {
+			roots = append(roots, cat)
+		} else if parent, ok := byID[cat.ParentID]; ok {
+			parent.Children = append(parent.Children, cat)
+		}
+	}
+	return roots
+}
+
+// WithoutEmptyCategories drops the categories with no in-stock product in their
+// subtree, summing InStockCount up the parent chain. A kept category's ancestors are
+// kept too, so the result still builds into a connected tree.
+func WithoutEmptyCategories(cats []*Category) []*Category {
+	parents := make(map[string]string, len(cats))
+	for _, cat := range cats {
+		parents[cat.ID] = cat.ParentID
+	}
+	subtree := make(map[string]int, len(cats))
+	for _, cat := range cats {
+		if cat.InStockCount == 0 {
+			continue
+		}
+		seen := map[string]bool{}
+		for id := cat.ID; id != "" && !seen[id]; id = parents[id] {
+			subtree[id] += cat.InStockCount
+			seen[id] = true
+		}
+	}
+	kept := []*Category{}
+	for _, cat := range cats {
+		if subtree[cat.ID] > 0 {
+			kept = append(kept, cat)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,32 @@
+package models
+
+import "testing"
+
+func TestBuildCategoryTree(t *testing.T) {
+	cats := []*Category{
+		{ID: "kuchyna", Name: "Kuchyňa"},
+		{ID: "kavovary", ParentID: "kuchyna", Name: "Kávovary"},
+		{ID: "automaticke", ParentID: "kavovary", Name: "Automatické kávovary"},
+		{ID: "kanvice", ParentID: "kuchyna", Name: "Kanvice"},
+		{ID: "orphan", ParentID: "deleted", Name: "Sirota"},
+		{ID: "zahrada", Name: "Záhrada"},
+	}
+	roots := BuildCategoryTree(cats)
+	if len(roots) != 2 || roots[0].ID != "kuchyna" || roots[1].ID != "zahrada" {
+		t.Fatalf("roots %v", roots)
+	}
+	kitchen := roots[0].Children
+	if len(kitchen) != 2 || kitchen[0].ID != "kavovary" || kitchen[1].ID != "kanvice" {
+		t.Fatalf("children of Kuchyňa %v", kitchen)
+	}
+	if len(kitchen[0].Children) != 1 || kitchen[0].Children[0].ID != "automaticke" {
+		t.Errorf("children of Kávovary %v", kitchen[0].Children)
+	}
+	if len(roots[1].Children) != 0 {
+		t.Errorf("Záhrada has children %v", roots[1].Children)
+	}
+
+	if roots := BuildCategoryTree(nil); roots == nil || len(roots) != 0 {
+		t.Errorf("empty list built %v, want an empty non-nil slice", roots)
+	}
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ExcerptLength is the rune budget of Excerpt, sized for meta descriptions
+const ExcerptLength = 160
+
+var (
+	// script and style bodies are code, not text
+	htmlCodeBlocks = regexp.MustCompile(`(?is)<(script|style)\b[^>]*>.*?</(script|style)\s*>`)
+	htmlComments   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	// block-level tags and line breaks separate words even without surrounding spaces
+	htmlBreaks = regexp.MustCompile(`(?i)<(br|/?(p|div|li|ul|ol|tr|td|th|h[1-6]|table|section|article|blockquote))\b[^>]*>`)
+	htmlTags   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// PlainText turns an HTML description into text with tags removed, entities decoded
+// and whitespace collapsed
+func PlainText(s string) string {
+	s = htmlCodeBlocks.ReplaceAllString(s, " ")
+	s = htmlComments.ReplaceAllString(s, " ")
+	s = htmlBreaks.ReplaceAllString(s, " ")
+	s = htmlTags.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Excerpt shortens plain text to at most ExcerptLength runes, cutting at a word
+// boundary where possible and marking the cut with an ellipsis
+func Excerpt(plain string) string {
+	if utf8.RuneCountInString(plain) <= ExcerptLength {
+		return plain
+	}
+	runes := []rune(plain)[:ExcerptLength-1]
+	cut := string(runes)
+	if i := strings.LastIndex(cut, " "); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:-") + "…"
+}
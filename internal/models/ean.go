@@ -0,0 +1,43 @@
+package models
+
+import "strings"
+
+// NormalizeEAN cleans a barcode as delivered by feeds and spreadsheets: everything but
+// digits is dropped (spaces, dashes, Excel's leading apostrophe) and a 12-digit UPC-A
+// is padded to EAN-13. The result is valid when it is an EAN-8, EAN-13 or GTIN-14 with
+// a correct check digit. Empty input yields "" and true.
+func NormalizeEAN(raw string) (string, bool) {
+	var b strings.Builder
+	for _, r := range raw {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	digits := b.String()
+	if digits == "" {
+		return "", strings.TrimSpace(raw) == ""
+	}
+	if len(digits) == 12 {
+		digits = "0" + digits
+	}
+	switch len(digits) {
+	case 8, 13, 14:
+	default:
+		return digits, false
+	}
+	return digits, eanCheckDigit(digits[:len(digits)-1]) == digits[len(digits)-1]
+}
+
+// eanCheckDigit computes the GS1 mod-10 check digit; weights alternate 3 and 1
+// starting from the rightmost digit of the payload
+func eanCheckDigit(payload string) byte {
+	sum := 0
+	for i := len(payload) - 1; i >= 0; i-- {
+		d := int(payload[i] - '0')
+		if (len(payload)-1-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// Feed is a configured product feed import
+type Feed struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	URL          string            `json:"url"`
+	Type         string            `json:"type"`
+	VendorID     string            `json:"vendor_id,omitempty"`
+	Schedule     string            `json:"schedule"`
+	IsActive     bool              `json:"is_active"`
+	XMLItemPath  string            `json:"xml_item_path,omitempty"`
+	FieldMapping map[string]string `json:"field_mapping,omitempty"`
+	// PricesIncludeVAT tells whether incoming prices are gross at VATRate percent
+	PricesIncludeVAT bool    `json:"prices_include_vat"`
+	VATRate          float64 `json:"vat_rate"`
+	// AttributeBlacklist lists PARAM names or slugs dropped at import
+	AttributeBlacklist []string `json:"attribute_blacklist"`
+	// ImportMode "staged" holds imported items in staged_products until approved
+	ImportMode string `json:"import_mode"`
+	// CategoryMode "create" adds missing categories at import; "skip" and "fail" reject such items
+	CategoryMode string `json:"category_mode"`
+	// ForceHTTPS upgrades http:// image and product URLs to https:// at import
+	ForceHTTPS bool `json:"force_https"`
+	// TransformRules rewrite mapped fields, in order, before items are validated
+	TransformRules []FeedTransform `json:"transform_rules"`
+	LastRun        *time.Time      `json:"last_run,omitempty"`
+	LastStatus     string          `json:"last_status,omitempty"`
+	ProductCount   int             `json:"product_count"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	// NextRunAt is when the scheduler starts the feed, moved past blackout windows;
+	// ESSyncDeferredAt is set while its Elasticsearch sync waits for a window to end
+	NextRunAt        *time.Time `json:"next_run_at,omitempty"`
+	ESSyncDeferredAt *time.Time `json:"es_sync_deferred_at,omitempty"`
+	// ConsecutiveFailures counts failed imports since the last successful one; past the
+	// alert threshold NeedsAttention is set until an import succeeds. RetryAt is when
+	// a failed scheduled import is retried.
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	NeedsAttention      bool       `json:"needs_attention"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+	// SourceModifiedAt is when the feed content last changed, from its Last-Modified
+	// header or else the download that brought new content; SourceCheckedAt is when
+	// the source was last requested
+	SourceModifiedAt *time.Time `json:"source_modified_at,omitempty"`
+	SourceCheckedAt  *time.Time `json:"source_checked_at,omitempty"`
+	// SourceETag and SourceLastModified are the validators sent with the next download
+	SourceETag         string `json:"-"`
+	SourceLastModified string `json:"-"`
+	// ForceDownload makes one import ignore the validators; it is not stored
+	ForceDownload bool `json:"-"`
+}
+
+// FeedTransform is one declarative rule applied to a mapped field (or to the value of
+// the attribute named after "param:") of every item: replace [old, new],
+// regex_replace [pattern, replacement], prepend [text], append [text], trim [cutset],
+// lowercase, uppercase or remove.
+type FeedTransform struct {
+	Field     string   `json:"field"`
+	Operation string   `json:"operation"`
+	Args      []string `json:"args,omitempty"`
+}
@@ -0,0 +1,344 @@
+// Package models holds the product, category and feed shapes shared by the HTTP
+// handlers and the Elasticsearch sync. Responses and index documents are derived
+// from Product through the To* helpers so a new column is added in one place.
+package models
+
+import (
+	"time"
+
+	"megabuy-go/internal/display"
+	"megabuy-go/internal/elasticsearch"
+	"megabuy-go/internal/money"
+)
+
+// Attribute is a product specification (PARAM) name/value pair. Grouped attributes
+// also carry every value of a repeated name in Values; Value is then the first one.
+type Attribute struct {
+	Name   string   `json:"name"`
+	Value  string   `json:"value"`
+	Values []string `json:"values,omitempty"`
+}
+
+// GroupAttributes merges rows sharing a name into one attribute listing all values,
+// keeping the position of each name's first row
+func GroupAttributes(rows []Attribute) []Attribute {
+	var grouped []Attribute
+	index := map[string]int{}
+	for _, a := range rows {
+		if i, ok := index[a.Name]; ok {
+			grouped[i].Values = append(grouped[i].Values, a.Value)
+			continue
+		}
+		index[a.Name] = len(grouped)
+		grouped = append(grouped, Attribute{Name: a.Name, Value: a.Value, Values: []string{a.Value}})
+	}
+	return grouped
+}
+
+// Label is a merchandising badge of a product
+type Label struct {
+	Slug     string `json:"slug"`
+	Name     string `json:"name"`
+	Color    string `json:"color,omitempty"`
+	Priority int    `json:"priority"`
+}
+
+// PromoPrice is the was/now pair of a running promo for strike-through display
+type PromoPrice struct {
+	Was    money.Price `json:"was"`
+	Now    money.Price `json:"now"`
+	EndsAt time.Time   `json:"ends_at"`
+}
+
+// NewPromoPrice returns nil unless a promo is running (endsAt set) and lowers the price
+func NewPromoPrice(was, now money.Price, endsAt *time.Time) *PromoPrice {
+	if endsAt == nil || was <= now {
+		return nil
+	}
+	return &PromoPrice{Was: was, Now: now, EndsAt: *endsAt}
+}
+
+// Product is a catalog product as stored in the products table.
+// PriceMin/PriceMax are VAT-inclusive, the Net fields exclude VAT; both are the
+// effective prices, lowered by a running promo.
+type Product struct {
+	ID               string
+	Title            string
+	Slug             string
+	Description      string
+	ShortDescription string
+	// DescriptionPlain and Excerpt are derived from Description on every write
+	DescriptionPlain string
+	Excerpt          string
+	EAN              string
+	SKU              string
+	MPN              string
+	Brand            string
+	ImageURL         string
+	Images           []string
+	AffiliateURL     string
+	CategoryID       string
+	CategoryName     string
+	CategorySlug     string
+	PriceMin         money.Price
+	PriceMax         money.Price
+	PriceMinNet      money.Price
+	PriceMaxNet      money.Price
+	VATRate          float64
+	Currency         string
+	StockStatus      string
+	IsActive         bool
+	IsFeatured       bool
+	Attributes       []Attribute
+	CreatedAt        time.Time
+	// Popularity, Rating and DiscountPercent are zero when unknown
+	Popularity      int
+	Rating          float64
+	DiscountPercent int
+	// Labels are the active badges, highest priority first
+	Labels []Label
+	// RegularPriceMin(Net) is price_min without the promo ending at PromoEndsAt (nil when none runs)
+	RegularPriceMin    money.Price
+	RegularPriceMinNet money.Price
+	PromoEndsAt        *time.Time
+	// ReleaseDate is set for preorder products
+	ReleaseDate *time.Time
+	// Promotional extras from the feed; WarrantyMonths is 0 when unknown
+	GiftText       string
+	WarrantyMonths int
+	ExtraMessage   string
+}
+
+// FormatReleaseDate renders a release date as YYYY-MM-DD, or "" when there is none
+func FormatReleaseDate(d *time.Time) string {
+	if d == nil {
+		return ""
+	}
+	return d.Format("2006-01-02")
+}
+
+// ProductCard is the product card of every storefront listing: product lists,
+// category, brand and SEO filter pages, featured, homepage, preorder, related and
+// search results all return exactly these fields
+type ProductCard struct {
+	ID               string      `json:"id"`
+	Title            string      `json:"title"`
+	Slug             string      `json:"slug"`
+	ShortDescription string      `json:"short_description"`
+	Excerpt          string      `json:"excerpt"`
+	ImageURL         string      `json:"image_url"`
+	PriceMin         money.Price `json:"price_min"`
+	PriceMax         money.Price `json:"price_max"`
+	StockStatus      string      `json:"stock_status"`
+	Brand            string      `json:"brand"`
+	CategoryName     string      `json:"category_name"`
+	CategorySlug     string      `json:"category_slug"`
+	DiscountPercent  int         `json:"discount_percent"`
+	Rating           float64     `json:"rating"`
+	Labels           []Label     `json:"labels"`
+	Promo            *PromoPrice `json:"promo,omitempty"`
+	ReleaseDate      string      `json:"release_date,omitempty"`
+	// Display is set for ?include_display=true
+	Display *display.Product `json:"display,omitempty"`
+}
+
+// ProductDetail is a single product as returned by the product page endpoint
+type ProductDetail struct {
+	ID               string                 `json:"id"`
+	Title            string                 `json:"title"`
+	Slug             string                 `json:"slug"`
+	Description      string                 `json:"description"`
+	DescriptionPlain string                 `json:"description_plain"`
+	Excerpt          string                 `json:"excerpt"`
+	ShortDescription string                 `json:"short_description"`
+	EAN              string                 `json:"ean"`
+	SKU              string                 `json:"sku"`
+	MPN              string                 `json:"mpn"`
+	Brand            string                 `json:"brand"`
+	ImageURL         string                 `json:"image_url"`
+	Images           []string               `json:"images"`
+	StockStatus      string                 `json:"stock_status"`
+	CategoryID       string                 `json:"category_id"`
+	CategoryName     string                 `json:"category_name"`
+	CategorySlug     string                 `json:"category_slug"`
+	AffiliateURL     string                 `json:"affiliate_url"`
+	PriceMin         money.Price            `json:"price_min"`
+	PriceMax         money.Price            `json:"price_max"`
+	IsActive         bool                   `json:"is_active"`
+	Currency         string                 `json:"currency"`
+	VATRate          float64                `json:"vat_rate"`
+	PriceMode        string                 `json:"price_mode"`
+	CreatedAt        time.Time              `json:"created_at"`
+	Attributes       []Attribute            `json:"attributes"`
+	Media            map[string]interface{} `json:"media"`
+	QuestionCount    int                    `json:"question_count"`
+	Labels           []Label                `json:"labels"`
+	Promo            *PromoPrice            `json:"promo,omitempty"`
+	ReleaseDate      string                 `json:"release_date,omitempty"`
+	GiftText         string                 `json:"gift_text,omitempty"`
+	WarrantyMonths   int                    `json:"warranty_months,omitempty"`
+	ExtraMessage     string                 `json:"extra_message,omitempty"`
+	// Display is set for ?include_display=true
+	Display *display.Product `json:"display,omitempty"`
+}
+
+// Prices returns the min and max price for a price mode ("net" or "gross")
+func (p Product) Prices(priceMode string) (money.Price, money.Price) {
+	if priceMode == "net" {
+		return p.PriceMinNet, p.PriceMaxNet
+	}
+	return p.PriceMin, p.PriceMax
+}
+
+// promo returns the was/now pair of a running promo for a price mode
+func (p Product) promo(priceMode string) *PromoPrice {
+	priceMin, _ := p.Prices(priceMode)
+	if priceMode == "net" {
+		return NewPromoPrice(p.RegularPriceMinNet, priceMin, p.PromoEndsAt)
+	}
+	return NewPromoPrice(p.RegularPriceMin, priceMin, p.PromoEndsAt)
+}
+
+// ToCard converts a product to its card
+func (p Product) ToCard(priceMode string) ProductCard {
+	priceMin, priceMax := p.Prices(priceMode)
+	return ProductCard{
+		ID:               p.ID,
+		Title:            p.Title,
+		Slug:             p.Slug,
+		ShortDescription: p.ShortDescription,
+		Excerpt:          p.Excerpt,
+		ImageURL:         p.ImageURL,
+		PriceMin:         priceMin,
+		PriceMax:         priceMax,
+		StockStatus:      p.StockStatus,
+		Brand:            p.Brand,
+		CategoryName:     p.CategoryName,
+		CategorySlug:     p.CategorySlug,
+		DiscountPercent:  p.DiscountPercent,
+		Rating:           p.Rating,
+		Labels:           p.Labels,
+		Promo:            p.promo(priceMode),
+		ReleaseDate:      FormatReleaseDate(p.ReleaseDate),
+	}
+}
+
+// ToDetail converts a product to its product page representation; Media is left to the caller
+func (p Product) ToDetail(priceMode string) ProductDetail {
+	priceMin, priceMax := p.Prices(priceMode)
+	return ProductDetail{
+		ID:               p.ID,
+		Title:            p.Title,
+		Slug:             p.Slug,
+		Description:      p.Description,
+		DescriptionPlain: p.DescriptionPlain,
+		Excerpt:          p.Excerpt,
+		ShortDescription: p.ShortDescription,
+		EAN:              p.EAN,
+		SKU:              p.SKU,
+		MPN:              p.MPN,
+		Brand:            p.Brand,
+		ImageURL:         p.ImageURL,
+		Images:           p.Images,
+		StockStatus:      p.StockStatus,
+		CategoryID:       p.CategoryID,
+		CategoryName:     p.CategoryName,
+		CategorySlug:     p.CategorySlug,
+		AffiliateURL:     p.AffiliateURL,
+		PriceMin:         priceMin,
+		PriceMax:         priceMax,
+		IsActive:         p.IsActive,
+		Currency:         p.Currency,
+		VATRate:          p.VATRate,
+		PriceMode:        priceMode,
+		CreatedAt:        p.CreatedAt,
+		Attributes:       GroupAttributes(p.Attributes),
+		Labels:           p.Labels,
+		Promo:            p.promo(priceMode),
+		ReleaseDate:      FormatReleaseDate(p.ReleaseDate),
+		GiftText:         p.GiftText,
+		WarrantyMonths:   p.WarrantyMonths,
+		ExtraMessage:     p.ExtraMessage,
+	}
+}
+
+// ToESDocument converts a product to the document indexed in Elasticsearch. The
+// description is indexed as plain text so highlights carry no markup.
+func (p Product) ToESDocument() elasticsearch.Product {
+	if p.DescriptionPlain == "" && p.Description != "" {
+		p.DescriptionPlain = PlainText(p.Description)
+	}
+	doc := elasticsearch.Product{
+		ID:               p.ID,
+		Title:            p.Title,
+		Slug:             p.Slug,
+		Description:      p.DescriptionPlain,
+		ShortDescription: p.ShortDescription,
+		Excerpt:          p.Excerpt,
+		EAN:              p.EAN,
+		SKU:              p.SKU,
+		MPN:              p.MPN,
+		Brand:            p.Brand,
+		CategoryID:       p.CategoryID,
+		CategoryName:     p.CategoryName,
+		CategorySlug:     p.CategorySlug,
+		ImageURL:         p.ImageURL,
+		PriceMin:         p.PriceMin,
+		PriceMax:         p.PriceMax,
+		PriceMinNet:      p.PriceMinNet,
+		PriceMaxNet:      p.PriceMaxNet,
+		VATRate:          p.VATRate,
+		StockStatus:      p.StockStatus,
+		IsActive:         p.IsActive,
+		IsFeatured:       p.IsFeatured,
+		CreatedAt:        p.CreatedAt.Format(time.RFC3339),
+		Popularity:       p.Popularity,
+		Rating:           p.Rating,
+		DiscountPercent:  p.DiscountPercent,
+		ReleaseDate:      FormatReleaseDate(p.ReleaseDate),
+	}
+	for _, a := range p.Attributes {
+		doc.Attributes = append(doc.Attributes, elasticsearch.Attr{Name: a.Name, Value: a.Value})
+	}
+	for _, l := range p.Labels {
+		doc.Labels = append(doc.Labels, l.Slug)
+	}
+	if p.promo("gross") != nil {
+		doc.PriceWas = p.RegularPriceMin
+		doc.PriceWasNet = p.RegularPriceMinNet
+		doc.PromoEndsAt = p.PromoEndsAt.Format(time.RFC3339)
+	}
+	return doc
+}
+
+// CardFromES converts an indexed product to its card. The index holds label slugs
+// only, so Labels is left empty for the caller to fill.
+func CardFromES(doc elasticsearch.Product, priceMode string) ProductCard {
+	priceMin, priceMax, was := doc.PriceMin, doc.PriceMax, doc.PriceWas
+	if priceMode == "net" {
+		priceMin, priceMax, was = doc.PriceMinNet, doc.PriceMaxNet, doc.PriceWasNet
+	}
+	var promoEndsAt *time.Time
+	if t, err := time.Parse(time.RFC3339, doc.PromoEndsAt); err == nil {
+		promoEndsAt = &t
+	}
+	return ProductCard{
+		ID:               doc.ID,
+		Title:            doc.Title,
+		Slug:             doc.Slug,
+		ShortDescription: doc.ShortDescription,
+		Excerpt:          doc.Excerpt,
+		ImageURL:         doc.ImageURL,
+		PriceMin:         priceMin,
+		PriceMax:         priceMax,
+		StockStatus:      doc.StockStatus,
+		Brand:            doc.Brand,
+		CategoryName:     doc.CategoryName,
+		CategorySlug:     doc.CategorySlug,
+		DiscountPercent:  doc.DiscountPercent,
+		Rating:           doc.Rating,
+		Labels:           []Label{},
+		Promo:            NewPromoPrice(was, priceMin, promoEndsAt),
+		ReleaseDate:      doc.ReleaseDate,
+	}
+}
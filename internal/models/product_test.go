@@ -0,0 +1,182 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"megabuy-go/internal/money"
+)
+
+func testProduct() Product {
+	return Product{
+		ID:               "3f2504e0-4f89-41d3-9a0c-0305e82c3301",
+		Title:            "Kávovar DeLonghi Magnifica S",
+		Slug:             "kavovar-delonghi-magnifica-s",
+		Description:      "<p>Automatický <b>kávovar</b></p>",
+		DescriptionPlain: "Automatický kávovar",
+		ShortDescription: "Automatický kávovar",
+		EAN:              "8004399327252",
+		SKU:              "ECAM22110B",
+		Brand:            "DeLonghi",
+		ImageURL:         "https://cdn.example.com/ecam.jpg",
+		Images:           []string{"https://cdn.example.com/ecam-2.jpg"},
+		CategoryID:       "kavovary",
+		CategoryName:     "Kávovary",
+		CategorySlug:     "kavovary",
+		PriceMin:         money.New(299.9),
+		PriceMax:         money.New(349),
+		PriceMinNet:      money.New(249.92),
+		PriceMaxNet:      money.New(290.83),
+		VATRate:          20,
+		Currency:         "EUR",
+		StockStatus:      "instock",
+		IsActive:         true,
+		IsFeatured:       true,
+		CreatedAt:        time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+		Attributes:       []Attribute{{Name: "Farba", Value: "čierna"}, {Name: "Tlak", Value: "15 bar"}},
+	}
+}
+
+func TestPrices(t *testing.T) {
+	p := testProduct()
+	if low, high := p.Prices("gross"); low != p.PriceMin || high != p.PriceMax {
+		t.Errorf("gross prices %v–%v", low, high)
+	}
+	if low, high := p.Prices("net"); low != p.PriceMinNet || high != p.PriceMaxNet {
+		t.Errorf("net prices %v–%v", low, high)
+	}
+	// anything but "net" is gross
+	if low, _ := p.Prices(""); low != p.PriceMin {
+		t.Errorf("default price mode gave %v", low)
+	}
+}
+
+func TestToDetail(t *testing.T) {
+	p := testProduct()
+	d := p.ToDetail("net")
+	if d.ID != p.ID || d.Title != p.Title || d.Slug != p.Slug || d.EAN != p.EAN || d.SKU != p.SKU ||
+		d.Brand != p.Brand || d.CategorySlug != p.CategorySlug || d.Currency != "EUR" || !d.IsActive || !d.CreatedAt.Equal(p.CreatedAt) {
+		t.Errorf("fields not carried over: %+v", d)
+	}
+	if d.PriceMode != "net" || d.PriceMin != p.PriceMinNet || d.PriceMax != p.PriceMaxNet {
+		t.Errorf("net detail priced %v–%v (%s)", d.PriceMin, d.PriceMax, d.PriceMode)
+	}
+	if d.Description != p.Description || d.DescriptionPlain != p.DescriptionPlain {
+		t.Errorf("descriptions %q / %q", d.Description, d.DescriptionPlain)
+	}
+	if len(d.Attributes) != 2 || d.Attributes[0].Name != "Farba" || d.Attributes[1].Value != "15 bar" {
+		t.Errorf("attributes %v", d.Attributes)
+	}
+	if d.Media != nil {
+		t.Error("Media is left to the caller")
+	}
+}
+
+func TestToESDocument(t *testing.T) {
+	p := testProduct()
+	doc := p.ToESDocument()
+	if doc.ID != p.ID || doc.Title != p.Title || doc.CategoryID != "kavovary" || doc.EAN != p.EAN || !doc.IsFeatured {
+		t.Errorf("fields not carried over: %+v", doc)
+	}
+	if doc.PriceMin != p.PriceMin || doc.PriceMaxNet != p.PriceMaxNet || doc.VATRate != 20 {
+		t.Errorf("prices %v %v, VAT %v", doc.PriceMin, doc.PriceMaxNet, doc.VATRate)
+	}
+	if doc.Description != "Automatický kávovar" {
+		t.Errorf("indexed description %q, want the plain text", doc.Description)
+	}
+	if doc.CreatedAt != "2024-05-01T08:30:00Z" {
+		t.Errorf("created_at %q", doc.CreatedAt)
+	}
+	if len(doc.Attributes) != 2 || doc.Attributes[0].Name != "Farba" || doc.Attributes[0].Value != "čierna" {
+		t.Errorf("attributes %v", doc.Attributes)
+	}
+
+	// rows read without description_plain still index plain text
+	p.DescriptionPlain = ""
+	if doc := p.ToESDocument(); doc.Description == "" || doc.Description == p.Description {
+		t.Errorf("description indexed as %q", doc.Description)
+	}
+}
+
+func TestGroupAttributes(t *testing.T) {
+	rows := []Attribute{
+		{Name: "Veľkosť", Value: "M"},
+		{Name: "Farba", Value: "čierna"},
+		{Name: "Veľkosť", Value: "L"},
+		{Name: "Materiál", Value: "bavlna"},
+		{Name: "Veľkosť", Value: "XL"},
+	}
+	want := []Attribute{
+		{Name: "Veľkosť", Value: "M", Values: []string{"M", "L", "XL"}},
+		{Name: "Farba", Value: "čierna", Values: []string{"čierna"}},
+		{Name: "Materiál", Value: "bavlna", Values: []string{"bavlna"}},
+	}
+	if got := GroupAttributes(rows); !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupAttributes = %v, want %v", got, want)
+	}
+	if got := GroupAttributes(nil); len(got) != 0 {
+		t.Errorf("GroupAttributes(nil) = %v", got)
+	}
+}
+
+// TestCardSources converts the same product to a card from its row and from its index
+// document: listings read cards from either, so both must agree field for field
+func TestCardSources(t *testing.T) {
+	p := testProduct()
+	p.Excerpt = "Automatický kávovar s mlynčekom"
+	p.Rating = 4.6
+	p.DiscountPercent = 14
+	ends := time.Date(2024, 6, 30, 22, 0, 0, 0, time.UTC)
+	release := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	p.RegularPriceMin, p.RegularPriceMinNet, p.PromoEndsAt = money.New(349), money.New(290.83), &ends
+	p.ReleaseDate = &release
+	p.Labels = []Label{{Slug: "novinka", Name: "Novinka", Priority: 10}}
+
+	for _, mode := range []string{"gross", "net"} {
+		fromRow := p.ToCard(mode)
+		fromIndex := CardFromES(p.ToESDocument(), mode)
+		if !reflect.DeepEqual(fromIndex.Labels, []Label{}) {
+			t.Errorf("%s: indexed card labels %v, want [] for the caller to fill", mode, fromIndex.Labels)
+		}
+		fromIndex.Labels = fromRow.Labels
+		if !reflect.DeepEqual(fromRow, fromIndex) {
+			t.Errorf("%s cards differ:\nrow   %+v\nindex %+v", mode, fromRow, fromIndex)
+		}
+	}
+
+	card := p.ToCard("net")
+	if card.PriceMin != p.PriceMinNet || card.PriceMax != p.PriceMaxNet || card.ReleaseDate != "2024-09-01" {
+		t.Errorf("net card %v–%v, release %q", card.PriceMin, card.PriceMax, card.ReleaseDate)
+	}
+	if card.Promo == nil || card.Promo.Was != p.RegularPriceMinNet || card.Promo.Now != p.PriceMinNet || !card.Promo.EndsAt.Equal(ends) {
+		t.Errorf("net promo %+v", card.Promo)
+	}
+
+	// without a running promo neither source reports one
+	p.PromoEndsAt = nil
+	if p.ToCard("gross").Promo != nil || CardFromES(p.ToESDocument(), "gross").Promo != nil {
+		t.Error("promo reported after it ended")
+	}
+}
+
+func TestProductCardJSON(t *testing.T) {
+	raw, err := json.Marshal(testProduct().ToCard("gross"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	json.Unmarshal(raw, &fields)
+	var got []string
+	for k := range fields {
+		got = append(got, k)
+	}
+	sort.Strings(got)
+	want := []string{"brand", "category_name", "category_slug", "discount_percent", "excerpt", "id", "image_url", "labels",
+		"price_max", "price_min", "rating", "short_description", "slug", "stock_status", "title"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("card fields %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,173 @@
+package money
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Price is an amount in euros counted in whole cents. Price columns are
+// NUMERIC(12,2); Price reads and writes them through pgx's numeric support without
+// going through floats, sums and differences are exact, and it always serializes
+// with exactly two decimals, so float artifacts such as 19.990000000000002 never
+// reach clients.
+type Price int64
+
+// JSONAsString makes prices marshal as "19.99" strings instead of 19.99 numbers;
+// main sets it from PRICE_JSON_FORMAT=string
+var JSONAsString bool
+
+// New rounds v half away from zero to whole cents; the tiny bias absorbs binary
+// representation error such as 1.005*100 = 100.49999...
+func New(v float64) Price {
+	return Price(math.Round(v*100 + math.Copysign(1e-7, v)))
+}
+
+// FromCents returns the amount of c cents
+func FromCents(c int64) Price { return Price(c) }
+
+// Float returns the amount in euros as a float64, for ratios such as VAT and
+// discounts; sums and differences stay on Price
+func (p Price) Float() float64 { return float64(p) / 100 }
+
+// Cents returns the amount in whole cents
+func (p Price) Cents() int64 { return int64(p) }
+
+// String formats the amount with two decimals, e.g. "19.99"
+func (p Price) String() string {
+	c, sign := int64(p), ""
+	if c < 0 {
+		c, sign = -c, "-"
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, c/100, c%100)
+}
+
+// maxCents bounds parsed amounts to what an int64 of cents holds
+var maxCents = big.NewInt(math.MaxInt64)
+
+// Parse reads an amount from a decimal string; a decimal comma is accepted and
+// fractions of a cent round half away from zero
+func Parse(s string) (Price, error) {
+	d := strings.Replace(strings.TrimSpace(s), ",", ".", 1)
+	// ParseFloat decides what is a number; the exact value comes from big.Rat
+	f, err := strconv.ParseFloat(d, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid price %q", s)
+	}
+	r, ok := new(big.Rat).SetString(d)
+	if !ok {
+		return 0, fmt.Errorf("invalid price %q", s)
+	}
+	r.Mul(r, big.NewRat(100, 1))
+	cents := roundHalfAway(r.Num(), r.Denom())
+	if cents.CmpAbs(maxCents) > 0 {
+		return 0, fmt.Errorf("price %q out of range", s)
+	}
+	return Price(cents.Int64()), nil
+}
+
+// roundHalfAway divides num by the positive den, rounding half away from zero
+func roundHalfAway(num, den *big.Int) *big.Int {
+	q, m := new(big.Int).QuoRem(num, den, new(big.Int))
+	if m.Sign() != 0 && new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(int64(num.Sign())))
+	}
+	return q
+}
+
+// MarshalJSON writes the amount as a two-decimal number, or string with JSONAsString
+func (p Price) MarshalJSON() ([]byte, error) {
+	if JSONAsString {
+		return []byte(`"` + p.String() + `"`), nil
+	}
+	return []byte(p.String()), nil
+}
+
+// UnmarshalJSON accepts a number or a numeric string; null leaves the price unchanged
+func (p *Price) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if string(b) == "null" {
+		return nil
+	}
+	if len(b) >= 2 && b[0] == '"' && b[len(b)-1] == '"' {
+		b = b[1 : len(b)-1]
+	}
+	v, err := Parse(string(b))
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
+
+// ImplementsGraphQLType lets the GraphQL schema serve prices as Float
+func (Price) ImplementsGraphQLType(name string) bool { return name == "Float" }
+
+// UnmarshalGraphQL reads a Float or Int GraphQL argument in euros
+func (p *Price) UnmarshalGraphQL(input interface{}) error {
+	switch v := input.(type) {
+	case float64:
+		*p = New(v)
+	case int32:
+		*p = Price(int64(v) * 100)
+	default:
+		return fmt.Errorf("invalid price %v", input)
+	}
+	return nil
+}
+
+// ScanNumeric implements pgtype.NumericScanner; NULL scans as 0. More than two
+// decimals round half away from zero.
+func (p *Price) ScanNumeric(v pgtype.Numeric) error {
+	*p = 0
+	if !v.Valid {
+		return nil
+	}
+	if v.NaN || v.InfinityModifier != pgtype.Finite {
+		return fmt.Errorf("price cannot be %v", v)
+	}
+	cents := new(big.Int).Set(v.Int)
+	if exp := int64(v.Exp) + 2; exp >= 0 {
+		cents.Mul(cents, new(big.Int).Exp(big.NewInt(10), big.NewInt(exp), nil))
+	} else {
+		cents = roundHalfAway(cents, new(big.Int).Exp(big.NewInt(10), big.NewInt(-exp), nil))
+	}
+	if cents.CmpAbs(maxCents) > 0 {
+		return fmt.Errorf("price %v out of range", v.Int)
+	}
+	*p = Price(cents.Int64())
+	return nil
+}
+
+// ScanFloat64 implements pgtype.Float64Scanner for computed float8 expressions
+func (p *Price) ScanFloat64(v pgtype.Float8) error {
+	*p = 0
+	if v.Valid {
+		*p = New(v.Float64)
+	}
+	return nil
+}
+
+// ScanInt64 implements pgtype.Int64Scanner for whole euro amounts such as MIN over integers
+func (p *Price) ScanInt64(v pgtype.Int8) error {
+	*p = 0
+	if v.Valid {
+		*p = Price(v.Int64 * 100)
+	}
+	return nil
+}
+
+// NumericValue implements pgtype.NumericValuer so prices are written as exact decimals
+func (p Price) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(int64(p)), Exp: -2, Valid: true}, nil
+}
+
+// Float64Value implements pgtype.Float64Valuer so float8 parameters get euros, not cents
+func (p Price) Float64Value() (pgtype.Float8, error) {
+	return pgtype.Float8{Float64: p.Float(), Valid: true}, nil
+}
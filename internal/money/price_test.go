@@ -0,0 +1,177 @@
+package money
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+	}{
+		{19.990000000000002, "19.99"},
+		{0.1 + 0.2, "0.30"},
+		{1.005, "1.01"},
+		{2.675, "2.68"},
+		{-1.005, "-1.01"},
+		{1299.9, "1299.90"},
+		{0.004, "0.00"},
+		{0, "0.00"},
+	}
+	for _, tt := range tests {
+		if got := New(tt.in).String(); got != tt.want {
+			t.Errorf("New(%v) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+	if c := New(19.99).Cents(); c != 1999 {
+		t.Errorf("Cents() = %d, want 1999", c)
+	}
+	if f := FromCents(1999).Float(); f != 19.99 {
+		t.Errorf("Float() = %v, want 19.99", f)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	// sums of cents are exact where float64 sums are not
+	if sum := New(0.1) + New(0.2); sum != New(0.3) || sum.String() != "0.30" {
+		t.Errorf("0.10 + 0.20 = %s", sum)
+	}
+	var total Price
+	for i := 0; i < 1000; i++ {
+		total += New(19.99)
+	}
+	if total != FromCents(1999000) {
+		t.Errorf("1000 × 19.99 = %s, want 19990.00", total)
+	}
+	if d := New(24.9) - New(22.5); d.String() != "2.40" {
+		t.Errorf("24.90 - 22.50 = %s", d)
+	}
+	if n := New(0.5) - New(1.25); n.String() != "-0.75" {
+		t.Errorf("0.50 - 1.25 = %s", n)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"19.99", "19.99", true},
+		{" 19,99 ", "19.99", true},
+		{"7", "7.00", true},
+		{"1e2", "100.00", true},
+		{"0.005", "0.01", true},
+		{"-0.005", "-0.01", true},
+		{"0.0049999", "0.00", true},
+		{"12345678901234.99", "12345678901234.99", true},
+		{"1/2", "", false},
+		{"1e30", "", false},
+		{"", "", false},
+		{"19.99 €", "", false},
+		{"1.299,90", "", false},
+		{"NaN", "", false},
+		{"Inf", "", false},
+	}
+	for _, tt := range tests {
+		p, err := Parse(tt.in)
+		if (err == nil) != tt.ok || (tt.ok && p.String() != tt.want) {
+			t.Errorf("Parse(%q) = %s, %v", tt.in, p, err)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	prices := []Price{New(19.990000000000002), New(0.1 + 0.2), New(5)}
+	raw, _ := json.Marshal(prices)
+	if string(raw) != "[19.99,0.30,5.00]" {
+		t.Errorf("numbers: %s", raw)
+	}
+
+	JSONAsString = true
+	t.Cleanup(func() { JSONAsString = false })
+	raw, _ = json.Marshal(map[string]Price{"price": New(1299.9)})
+	if string(raw) != `{"price":"1299.90"}` {
+		t.Errorf("strings: %s", raw)
+	}
+
+	var in struct {
+		A, B, C, D Price
+	}
+	in.D = 3
+	if err := json.Unmarshal([]byte(`{"A": 19.99, "B": "24,50", "C": 0.30000000000000004, "D": null}`), &in); err != nil {
+		t.Fatal(err)
+	}
+	if in.A != New(19.99) || in.B != New(24.5) || in.C != New(0.3) || in.D != 3 {
+		t.Errorf("decoded %+v; null keeps the previous value", in)
+	}
+	for _, bad := range []string{`"abc"`, `true`, `"19.99 €"`} {
+		var p Price
+		if err := json.Unmarshal([]byte(bad), &p); err == nil {
+			t.Errorf("decoded %s as %s", bad, p)
+		}
+	}
+}
+
+func TestNumeric(t *testing.T) {
+	v, err := New(19.99).NumericValue()
+	if err != nil || v.Int.Cmp(big.NewInt(1999)) != 0 || v.Exp != -2 || !v.Valid {
+		t.Errorf("NumericValue = %+v, %v", v, err)
+	}
+
+	var p Price
+	tests := []struct {
+		n    pgtype.Numeric
+		want Price
+	}{
+		{pgtype.Numeric{Int: big.NewInt(1999), Exp: -2, Valid: true}, New(19.99)},
+		{pgtype.Numeric{Int: big.NewInt(19999), Exp: -3, Valid: true}, New(20)},
+		{pgtype.Numeric{Int: big.NewInt(12), Exp: 1, Valid: true}, New(120)},
+		{pgtype.Numeric{Int: big.NewInt(-100500), Exp: -5, Valid: true}, New(-1.01)},
+		{pgtype.Numeric{Int: big.NewInt(1234567890123499), Exp: -2, Valid: true}, FromCents(1234567890123499)},
+		{pgtype.Numeric{}, 0},
+	}
+	for _, tt := range tests {
+		p = 7
+		if err := p.ScanNumeric(tt.n); err != nil || p != tt.want {
+			t.Errorf("ScanNumeric(%+v) = %s, %v; want %s", tt.n, p, err, tt.want)
+		}
+	}
+
+	p = 7
+	if p.ScanFloat64(pgtype.Float8{Float64: 19.990000000000002, Valid: true}); p != New(19.99) {
+		t.Errorf("ScanFloat64 = %s", p)
+	}
+	if p.ScanFloat64(pgtype.Float8{}); p != 0 {
+		t.Errorf("ScanFloat64(NULL) = %s", p)
+	}
+	if p.ScanInt64(pgtype.Int8{Int64: 42, Valid: true}); p != New(42) {
+		t.Errorf("ScanInt64 = %s, want whole euros", p)
+	}
+	if err := p.ScanNumeric(pgtype.Numeric{NaN: true, Valid: true}); err == nil {
+		t.Error("ScanNumeric accepted NaN")
+	}
+	if f, _ := New(19.99).Float64Value(); f.Float64 != 19.99 || !f.Valid {
+		t.Errorf("Float64Value = %+v, want euros", f)
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	var p Price
+	if !p.ImplementsGraphQLType("Float") || p.ImplementsGraphQLType("String") {
+		t.Error("Price must serve as Float only")
+	}
+	if err := p.UnmarshalGraphQL(19.99); err != nil || p != New(19.99) {
+		t.Errorf("UnmarshalGraphQL(19.99) = %s, %v", p, err)
+	}
+	if err := p.UnmarshalGraphQL(int32(49)); err != nil || p != New(49) {
+		t.Errorf("UnmarshalGraphQL(49) = %s, %v; want whole euros", p, err)
+	}
+	if err := p.UnmarshalGraphQL("19.99"); err == nil {
+		t.Error("UnmarshalGraphQL accepted a string")
+	}
+}
@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Message is a single outgoing e-mail; HTML is an optional alternative to the
+// plain text Body
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+	HTML    string
+}
+
+// Sender delivers messages; implementations must be safe for concurrent use
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type SMTPSender struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPSender(host string, port int, username, password, from string) *SMTPSender {
+	return &SMTPSender{host: host, port: port, username: username, password: password, from: from}
+}
+
+// NopSender accepts every message without delivering it, for development and
+// staging environments that must not e-mail real customers
+type NopSender struct{}
+
+func (NopSender) Send(ctx context.Context, msg Message) error {
+	log.Printf("Notification to %s not delivered (no-op sender): %s", msg.To, msg.Subject)
+	return nil
+}
+
+// NewFromEnv returns the sender selected by NOTIFY_SENDER: "nop" for NopSender,
+// otherwise an SMTP sender configured from SMTP_* variables, or nil when SMTP_HOST
+// is not set
+func NewFromEnv() Sender {
+	if os.Getenv("NOTIFY_SENDER") == "nop" {
+		return NopSender{}
+	}
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil
+	}
+	port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
+	if port == 0 {
+		port = 587
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = "noreply@megabuy.sk"
+	}
+	return NewSMTPSender(host, port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from)
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid header value")
+	}
+
+	var auth smtp.Auth
+	if s.username != "" {
+		auth = smtp.PlainAuth("", s.username, s.password, s.host)
+	}
+
+	var b strings.Builder
+	b.WriteString("From: " + s.from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	if msg.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		b.WriteString("\r\n")
+		b.WriteString(msg.Body)
+	} else {
+		writeAlternative(&b, msg.Body, msg.HTML)
+	}
+
+	addr := fmt.Sprintf("%s:%d", s.host, s.port)
+	return smtp.SendMail(addr, auth, s.from, []string{msg.To}, []byte(b.String()))
+}
+
+// writeAlternative writes a multipart/alternative body with the text part first,
+// so clients prefer the HTML part when they can render it
+func writeAlternative(b *strings.Builder, text, html string) {
+	w := multipart.NewWriter(b)
+	b.WriteString("Content-Type: multipart/alternative; boundary=" + w.Boundary() + "\r\n")
+	b.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", text},
+		{"text/html; charset=UTF-8", html},
+	} {
+		pw, _ := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		qw := quotedprintable.NewWriter(pw)
+		qw.Write([]byte(part.body))
+		qw.Close()
+	}
+	w.Close()
+}
@@ -0,0 +1,81 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	"text/template"
+)
+
+// Languages with template variants; DefaultLang is used for everything else
+const (
+	LangSK      = "sk"
+	LangEN      = "en"
+	DefaultLang = LangSK
+)
+
+// Templates live in templates/<name>.<lang>.txt, which defines the "subject" and
+// "text" blocks, with an optional templates/<name>.<lang>.html body next to it
+//
+//go:embed templates
+var templateFS embed.FS
+
+// NormalizeLang maps a language tag or an Accept-Language header to a supported
+// language, falling back to DefaultLang
+func NormalizeLang(s string) string {
+	for _, part := range strings.Split(s, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		tag = strings.SplitN(tag, "-", 2)[0]
+		switch tag {
+		case LangSK, LangEN:
+			return tag
+		case "cs":
+			// Czech readers get the Slovak variant
+			return LangSK
+		}
+	}
+	return DefaultLang
+}
+
+// Render builds the message for template name in lang; a missing language variant
+// falls back to DefaultLang. The recipient is left for the caller to set.
+func Render(name, lang string, data any) (Message, error) {
+	lang = NormalizeLang(lang)
+	txt, err := fs.ReadFile(templateFS, "templates/"+name+"."+lang+".txt")
+	if err != nil && lang != DefaultLang {
+		lang = DefaultLang
+		txt, err = fs.ReadFile(templateFS, "templates/"+name+"."+lang+".txt")
+	}
+	if err != nil {
+		return Message{}, fmt.Errorf("unknown notification template %q", name)
+	}
+
+	t, err := template.New(name).Parse(string(txt))
+	if err != nil {
+		return Message{}, err
+	}
+	var subject, body bytes.Buffer
+	if err := t.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := t.ExecuteTemplate(&body, "text", data); err != nil {
+		return Message{}, err
+	}
+	msg := Message{Subject: strings.TrimSpace(subject.String()), Body: body.String()}
+
+	if raw, err := fs.ReadFile(templateFS, "templates/"+name+"."+lang+".html"); err == nil {
+		ht, err := htmltemplate.New(name).Parse(string(raw))
+		if err != nil {
+			return Message{}, err
+		}
+		var html bytes.Buffer
+		if err := ht.Execute(&html, data); err != nil {
+			return Message{}, err
+		}
+		msg.HTML = html.String()
+	}
+	return msg, nil
+}
@@ -0,0 +1,95 @@
+package settings
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Known keys
+const (
+	KeyFilters             = "filters"
+	KeyListingSort         = "listing_sort"
+	KeyImageAlt            = "image_alt"
+	KeyMaintenance         = "maintenance"
+	KeyImportBlackout      = "import_blackout"
+	KeyCompletenessWeights = "completeness_weights"
+)
+
+// Filters is the "filters" setting, formerly the single filter_settings row
+type Filters struct {
+	FilterableAttributes []string `json:"filterable_attributes"`
+	ShowPriceFilter      bool     `json:"show_price_filter"`
+	ShowStockFilter      bool     `json:"show_stock_filter"`
+	ShowBrandFilter      bool     `json:"show_brand_filter"`
+	MaxValuesPerFilter   int      `json:"max_values_per_filter"`
+	// MaxFacetDepth is how many filter segments a landing page may have and still be indexed
+	MaxFacetDepth int `json:"max_facet_depth"`
+}
+
+// Filters returns the filter setting
+func (s *Store) Filters(ctx context.Context) (Filters, error) {
+	return Get[Filters](ctx, s, KeyFilters)
+}
+
+// Known returns the definitions of every key the store accepts
+func Known() []Definition {
+	weight := func() *Schema { return Integer(0, 100) }
+	return []Definition{
+		{
+			Key:         KeyFilters,
+			Description: "Filters shown on category listings and the depth of crawlable filter URLs",
+			Schema: Object(map[string]*Schema{
+				"filterable_attributes": Array(String(255), 100),
+				"show_price_filter":     Boolean(),
+				"show_stock_filter":     Boolean(),
+				"show_brand_filter":     Boolean(),
+				"max_values_per_filter": Integer(1, 500),
+				"max_facet_depth":       Integer(1, 10),
+			}),
+			Default: json.RawMessage(`{"filterable_attributes": [], "show_price_filter": true, "show_stock_filter": true, "show_brand_filter": true, "max_values_per_filter": 20, "max_facet_depth": 2}`),
+		},
+		{
+			Key:         KeyListingSort,
+			Description: "Sort of listings whose category sets none",
+			Schema:      Object(map[string]*Schema{"default_sort": String(20)}, "default_sort"),
+			Default:     json.RawMessage(`{"default_sort": "newest"}`),
+		},
+		{
+			Key:         KeyImageAlt,
+			Description: "Template for alt texts of imported gallery images",
+			Schema:      Object(map[string]*Schema{"template": String(200)}, "template"),
+			Default:     json.RawMessage(`{"template": "{title} – obrázok {n}"}`),
+		},
+		{
+			Key:         KeyMaintenance,
+			Description: "Read-only admin mode shared by all instances",
+			Schema: Object(map[string]*Schema{
+				"enabled":    Boolean(),
+				"message":    String(500),
+				"since":      Timestamp(),
+				"until":      Timestamp(),
+				"updated_by": String(255),
+			}, "enabled"),
+			Default: json.RawMessage(`{"enabled": false}`),
+		},
+		{
+			Key:         KeyImportBlackout,
+			Description: "Windows in which scheduled imports do not start",
+			Schema: Object(map[string]*Schema{
+				"windows":       Array(Object(map[string]*Schema{"start": String(5), "end": String(5)}, "start", "end"), 24),
+				"timezone":      String(64),
+				"defer_es_sync": Boolean(),
+			}),
+			Default: json.RawMessage(`{"windows": [], "defer_es_sync": false}`),
+		},
+		{
+			Key:         KeyCompletenessWeights,
+			Description: "Weights of the product completeness checks",
+			Schema: Object(map[string]*Schema{
+				"image": weight(), "gallery": weight(), "description": weight(), "ean": weight(),
+				"attributes": weight(), "category": weight(), "brand": weight(),
+			}),
+			Default: json.RawMessage(`{"image": 20, "gallery": 10, "description": 20, "ean": 15, "attributes": 15, "category": 10, "brand": 10}`),
+		},
+	}
+}
@@ -0,0 +1,180 @@
+package settings
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is the subset of JSON Schema settings are validated with. Objects reject
+// properties they do not list, so a misspelled field fails like a misspelled key.
+type Schema struct {
+	Type string // object, array, string, integer, number or boolean
+	// Properties and Required describe objects
+	Properties map[string]*Schema
+	Required   []string
+	// Items describes array elements; MaxItems bounds their count when positive
+	Items    *Schema
+	MaxItems int
+	// Enum lists the allowed strings; MaxLength bounds strings in characters when positive
+	Enum      []string
+	MaxLength int
+	// Minimum and Maximum bound numbers
+	Minimum, Maximum *float64
+	// Nullable also accepts null
+	Nullable bool
+}
+
+func Object(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: "object", Properties: properties, Required: required}
+}
+
+func Array(items *Schema, maxItems int) *Schema {
+	return &Schema{Type: "array", Items: items, MaxItems: maxItems}
+}
+
+func String(maxLength int) *Schema {
+	return &Schema{Type: "string", MaxLength: maxLength}
+}
+
+func Integer(min, max float64) *Schema {
+	return &Schema{Type: "integer", Minimum: &min, Maximum: &max}
+}
+
+func Boolean() *Schema {
+	return &Schema{Type: "boolean"}
+}
+
+// Timestamp is an RFC 3339 string or null
+func Timestamp() *Schema {
+	return &Schema{Type: "string", MaxLength: 64, Nullable: true}
+}
+
+// ValidationError names the part of a value that broke its schema
+type ValidationError struct {
+	Key string `json:"key"`
+	// Path is the JSON path inside the value, "" for the value itself
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("setting %s: %s", e.Key, e.Message)
+	}
+	return fmt.Sprintf("setting %s: %s %s", e.Key, e.Path, e.Message)
+}
+
+// Validate checks a value decoded by encoding/json against the schema
+func (s *Schema) Validate(value interface{}) error {
+	path, msg := s.check(value, "")
+	if msg == "" {
+		return nil
+	}
+	return &ValidationError{Path: path, Message: msg}
+}
+
+func (s *Schema) check(value interface{}, path string) (string, string) {
+	if value == nil {
+		if s.Nullable {
+			return "", ""
+		}
+		return path, "must not be null"
+	}
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return path, "must be an object"
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return join(path, name), "is required"
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				return join(path, name), "is not a known field; use " + strings.Join(s.propertyNames(), ", ")
+			}
+			if p, msg := prop.check(obj[name], join(path, name)); msg != "" {
+				return p, msg
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return path, "must be an array"
+		}
+		if s.MaxItems > 0 && len(arr) > s.MaxItems {
+			return path, fmt.Sprintf("must have at most %d items", s.MaxItems)
+		}
+		for i, item := range arr {
+			if p, msg := s.Items.check(item, fmt.Sprintf("%s[%d]", path, i)); msg != "" {
+				return p, msg
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return path, "must be a string"
+		}
+		if s.MaxLength > 0 && utf8.RuneCountInString(str) > s.MaxLength {
+			return path, fmt.Sprintf("must be at most %d characters", s.MaxLength)
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			return path, "must be one of " + strings.Join(s.Enum, ", ")
+		}
+	case "integer", "number":
+		num, ok := value.(float64)
+		if !ok {
+			return path, "must be a number"
+		}
+		if s.Type == "integer" && num != math.Trunc(num) {
+			return path, "must be a whole number"
+		}
+		if s.Minimum != nil && num < *s.Minimum {
+			return path, fmt.Sprintf("must be at least %g", *s.Minimum)
+		}
+		if s.Maximum != nil && num > *s.Maximum {
+			return path, fmt.Sprintf("must be at most %g", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return path, "must be true or false"
+		}
+	}
+	return "", ""
+}
+
+func (s *Schema) propertyNames() []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
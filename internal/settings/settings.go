@@ -0,0 +1,259 @@
+// Package settings stores global configuration as JSON values in the settings table.
+// Only keys defined in Known can be written; every write is validated against the
+// key's schema, and reads are cached per instance for CacheTTL or until a write
+// through the same Store.
+package settings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CacheTTL bounds how long other instances take to notice a write
+const CacheTTL = 10 * time.Second
+
+// ErrUnknownKey rejects keys without a definition, which are usually typos
+var ErrUnknownKey = errors.New("unknown setting")
+
+// Definition describes a known key
+type Definition struct {
+	Key         string
+	Description string
+	Schema      *Schema
+	// Default is returned while the key has never been written
+	Default json.RawMessage
+	// Check validates what the schema cannot express; it returns a message or ""
+	Check func(value json.RawMessage) string
+}
+
+// Entry is a setting as returned by the admin API
+type Entry struct {
+	Key         string          `json:"key"`
+	Description string          `json:"description"`
+	Value       json.RawMessage `json:"value"`
+	UpdatedAt   *time.Time      `json:"updated_at,omitempty"`
+	UpdatedBy   string          `json:"updated_by,omitempty"`
+	// IsDefault is set while the key has never been written
+	IsDefault bool `json:"is_default"`
+}
+
+type cachedEntry struct {
+	entry    Entry
+	loadedAt time.Time
+}
+
+type Store struct {
+	pool      *pgxpool.Pool
+	mu        sync.RWMutex
+	defs      map[string]*Definition
+	cache     map[string]cachedEntry
+	listeners map[string][]func()
+}
+
+// New returns a store for the Known keys
+func New(pool *pgxpool.Pool) *Store {
+	s := &Store{pool: pool, defs: map[string]*Definition{}, cache: map[string]cachedEntry{}, listeners: map[string][]func(){}}
+	for _, def := range Known() {
+		def := def
+		s.defs[def.Key] = &def
+	}
+	return s
+}
+
+// AddCheck sets the semantic check of a known key, for rules that live with the code using it
+func (s *Store) AddCheck(key string, check func(value json.RawMessage) string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if def, ok := s.defs[key]; ok {
+		def.Check = check
+	}
+}
+
+// OnChange registers fn to run after every write of key through this store
+func (s *Store) OnChange(key string, fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners[key] = append(s.listeners[key], fn)
+}
+
+// Keys lists the known keys in order
+func (s *Store) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.defs))
+	for key := range s.defs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Store) definition(key string) (*Definition, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	def, ok := s.defs[key]
+	return def, ok
+}
+
+// Entry returns the stored value of key, or its default while it has none
+func (s *Store) Entry(ctx context.Context, key string) (Entry, error) {
+	def, ok := s.definition(key)
+	if !ok {
+		return Entry{}, fmt.Errorf("%w %q", ErrUnknownKey, key)
+	}
+	s.mu.RLock()
+	cached, ok := s.cache[key]
+	s.mu.RUnlock()
+	if ok && time.Since(cached.loadedAt) < CacheTTL {
+		return cached.entry, nil
+	}
+
+	entry := Entry{Key: key, Description: def.Description}
+	var raw string
+	var updatedAt time.Time
+	err := s.pool.QueryRow(ctx, "SELECT value::text, COALESCE(updated_at, NOW()), COALESCE(updated_by,'') FROM settings WHERE key = $1", key).Scan(&raw, &updatedAt, &entry.UpdatedBy)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		entry.Value, entry.IsDefault = def.Default, true
+	case err != nil:
+		// a stale value beats the default while the database is unreachable
+		if ok {
+			return cached.entry, err
+		}
+		entry.Value, entry.IsDefault = def.Default, true
+		return entry, err
+	default:
+		entry.Value, entry.UpdatedAt = json.RawMessage(raw), &updatedAt
+	}
+	s.store(entry)
+	return entry, nil
+}
+
+// All returns every known key
+func (s *Store) All(ctx context.Context) ([]Entry, error) {
+	var entries []Entry
+	for _, key := range s.Keys() {
+		entry, err := s.Entry(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Decode unmarshals the key's default and then its stored value into dst, so fields
+// the stored value lacks keep their defaults. Fields dst sets beforehand survive both.
+// On a read error dst holds the last value read, or the default, and the error is returned.
+func (s *Store) Decode(ctx context.Context, key string, dst interface{}) error {
+	def, ok := s.definition(key)
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownKey, key)
+	}
+	if len(def.Default) > 0 {
+		if err := json.Unmarshal(def.Default, dst); err != nil {
+			return fmt.Errorf("default of setting %s: %w", key, err)
+		}
+	}
+	entry, err := s.Entry(ctx, key)
+	if entry.IsDefault || len(entry.Value) == 0 {
+		return err
+	}
+	if uerr := json.Unmarshal(entry.Value, dst); uerr != nil {
+		return uerr
+	}
+	return err
+}
+
+// Get is the typed form of Decode
+func Get[T any](ctx context.Context, s *Store, key string) (T, error) {
+	var v T
+	err := s.Decode(ctx, key, &v)
+	return v, err
+}
+
+// Validate checks value against the key's schema and check without storing it
+func (s *Store) Validate(key string, value json.RawMessage) error {
+	def, ok := s.definition(key)
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownKey, key)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(value, &decoded); err != nil {
+		return &ValidationError{Key: key, Message: "is not valid JSON"}
+	}
+	if err := def.Schema.Validate(decoded); err != nil {
+		verr := err.(*ValidationError)
+		verr.Key = key
+		return verr
+	}
+	if def.Check != nil {
+		if msg := def.Check(value); msg != "" {
+			return &ValidationError{Key: key, Message: msg}
+		}
+	}
+	return nil
+}
+
+// Set validates and stores value, refreshes the cache and runs the key's OnChange hooks
+func (s *Store) Set(ctx context.Context, key string, value json.RawMessage, updatedBy string) (Entry, error) {
+	if err := s.Validate(key, value); err != nil {
+		return Entry{}, err
+	}
+	def, _ := s.definition(key)
+	entry := Entry{Key: key, Description: def.Description, UpdatedBy: updatedBy}
+	var updatedAt time.Time
+	var raw string
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO settings (key, value, updated_at, updated_by) VALUES ($1, $2::jsonb, NOW(), NULLIF($3,''))
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = NOW(), updated_by = EXCLUDED.updated_by
+		RETURNING value::text, updated_at
+	`, key, string(value), updatedBy).Scan(&raw, &updatedAt)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry.Value, entry.UpdatedAt = json.RawMessage(raw), &updatedAt
+	s.store(entry)
+
+	s.mu.RLock()
+	listeners := s.listeners[key]
+	s.mu.RUnlock()
+	for _, fn := range listeners {
+		fn()
+	}
+	return entry, nil
+}
+
+// SetValue marshals v and stores it with Set
+func (s *Store) SetValue(ctx context.Context, key string, v interface{}, updatedBy string) (Entry, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return Entry{}, err
+	}
+	return s.Set(ctx, key, raw, updatedBy)
+}
+
+// Invalidate drops the cached value of key, or of every key when key is ""
+func (s *Store) Invalidate(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key == "" {
+		s.cache = map[string]cachedEntry{}
+		return
+	}
+	delete(s.cache, key)
+}
+
+func (s *Store) store(entry Entry) {
+	s.mu.Lock()
+	s.cache[entry.Key] = cachedEntry{entry: entry, loadedAt: time.Now()}
+	s.mu.Unlock()
+}
@@ -0,0 +1,121 @@
+package testutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// FakeES stands in for the Elasticsearch endpoints the handlers call. It keeps the
+// documents written through the document and bulk APIs, answers every search with
+// all of them, and records each request so callers can assert what was sent.
+// Point ELASTICSEARCH_URL at URL before creating the handlers.
+type FakeES struct {
+	*httptest.Server
+	mu       sync.Mutex
+	docs     map[string]json.RawMessage
+	requests []ESRequest
+}
+
+// ESRequest is a request FakeES received
+type ESRequest struct {
+	Method string
+	Path   string
+	Body   []byte
+}
+
+// NewFakeES starts a fake Elasticsearch; callers Close it
+func NewFakeES() *FakeES {
+	f := &FakeES{docs: map[string]json.RawMessage{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+// Doc returns the indexed document of a product
+func (f *FakeES) Doc(id string) (json.RawMessage, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	doc, ok := f.docs[id]
+	return doc, ok
+}
+
+// Count is how many documents are indexed
+func (f *FakeES) Count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.docs)
+}
+
+// Requests returns the requests received so far, oldest first
+func (f *FakeES) Requests() []ESRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]ESRequest{}, f.requests...)
+}
+
+func (f *FakeES) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, ESRequest{Method: r.Method, Path: r.URL.Path, Body: body})
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.URL.Path == "/_bulk":
+		json.NewEncoder(w).Encode(map[string]interface{}{"took": 1, "errors": false, "items": f.bulk(body)})
+	case strings.HasPrefix(r.URL.Path, "/products/_doc/"):
+		id := strings.TrimPrefix(r.URL.Path, "/products/_doc/")
+		if r.Method == http.MethodDelete {
+			delete(f.docs, id)
+		} else {
+			f.docs[id] = json.RawMessage(body)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"_id": id, "result": "ok"})
+	case strings.HasSuffix(r.URL.Path, "/_search"):
+		hits := []map[string]interface{}{}
+		for id, doc := range f.docs {
+			hits = append(hits, map[string]interface{}{"_id": id, "_source": doc})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"took": 1,
+			"hits": map[string]interface{}{"total": map[string]int{"value": len(hits)}, "hits": hits},
+		})
+	case r.URL.Path == "/products" && r.Method == http.MethodDelete:
+		f.docs = map[string]json.RawMessage{}
+		w.Write([]byte(`{"acknowledged":true}`))
+	default:
+		w.Write([]byte(`{"acknowledged":true}`))
+	}
+}
+
+// bulk applies index and delete actions of a bulk body; callers hold f.mu
+func (f *FakeES) bulk(body []byte) []map[string]interface{} {
+	items := []map[string]interface{}{}
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	sc.Buffer(make([]byte, 1<<20), 64<<20)
+	for sc.Scan() {
+		var action map[string]struct {
+			ID string `json:"_id"`
+		}
+		if json.Unmarshal(sc.Bytes(), &action) != nil {
+			continue
+		}
+		for op, meta := range action {
+			switch op {
+			case "index", "create", "update":
+				if sc.Scan() {
+					f.docs[meta.ID] = json.RawMessage(append([]byte{}, sc.Bytes()...))
+				}
+			case "delete":
+				delete(f.docs, meta.ID)
+			}
+			items = append(items, map[string]interface{}{op: map[string]interface{}{"_id": meta.ID, "status": 200}})
+		}
+	}
+	return items
+}
@@ -0,0 +1,319 @@
+// Package testutil generates synthetic supplier feeds for measuring and exercising the
+// import pipeline: Heureka XML, CSV and JSON documents of any size, deterministic for
+// a given seed, and an HTTP server that serves them the way a supplier would. FakeES
+// replaces Elasticsearch for end-to-end runs.
+package testutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+)
+
+// FeedFormat names a generated document format and matches the feeds.type it imports as
+type FeedFormat string
+
+const (
+	FormatHeureka FeedFormat = "heureka-xml"
+	FormatCSV     FeedFormat = "csv"
+	FormatJSON    FeedFormat = "json"
+)
+
+// FeedSpec describes a synthetic feed. Zero values get the defaults of DefaultSpec.
+type FeedSpec struct {
+	Format FeedFormat
+	Items  int
+	// Categories is how many distinct category paths the items spread over
+	Categories int
+	// Params and Images are per item; Images counts alternative images only
+	Params int
+	Images int
+	// DescriptionWords sizes descriptions, which dominate the document size
+	DescriptionWords int
+	// DuplicateRate and InvalidRate are the shares of items repeating an earlier EAN
+	// or lacking a price, so skip handling is part of the measurement
+	DuplicateRate float64
+	InvalidRate   float64
+	Seed          int64
+	// Compression makes ServeFeed send the document as "gzip" or as a "zip" archive
+	// holding it; empty sends it as it is
+	Compression string
+}
+
+// DefaultSpec is a mid-sized Heureka feed resembling a typical electronics supplier
+func DefaultSpec() FeedSpec {
+	return FeedSpec{Format: FormatHeureka, Items: 10000, Categories: 50, Params: 8, Images: 3, DescriptionWords: 80, Seed: 1}
+}
+
+func (s FeedSpec) withDefaults() FeedSpec {
+	d := DefaultSpec()
+	if s.Format == "" {
+		s.Format = d.Format
+	}
+	if s.Items <= 0 {
+		s.Items = d.Items
+	}
+	if s.Categories <= 0 {
+		s.Categories = d.Categories
+	}
+	if s.DescriptionWords <= 0 {
+		s.DescriptionWords = d.DescriptionWords
+	}
+	return s
+}
+
+// Extension is the file extension of the format
+func (f FeedFormat) Extension() string {
+	switch f {
+	case FormatCSV:
+		return ".csv"
+	case FormatJSON:
+		return ".json"
+	}
+	return ".xml"
+}
+
+// ContentType is what ServeFeed sends for the format
+func (f FeedFormat) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSON:
+		return "application/json"
+	}
+	return "application/xml; charset=utf-8"
+}
+
+var (
+	brands     = []string{"Samsung", "Xiaomi", "Lenovo", "Bosch", "Philips", "Sony", "Tefal", "Makita", "Lego", "Adidas"}
+	nouns      = []string{"Mobilný telefón", "Notebook", "Vysávač", "Kávovar", "Slúchadlá", "Vŕtačka", "Stavebnica", "Tenisky"}
+	sections   = []string{"Elektronika", "Domácnosť", "Dielňa", "Hračky", "Šport"}
+	paramNames = []string{"Farba", "Hmotnosť", "Výkon", "Materiál", "Záruka", "Rozmery", "Kapacita", "Napätie", "Typ", "Model"}
+	colors     = []string{"čierna", "biela", "strieborná", "modrá", "červená"}
+	words      = strings.Fields("kvalitný výkonný moderný praktický odolný tichý ľahký úsporný elegantný spoľahlivý " +
+		"produkt zariadenie batéria displej motor puzdro príslušenstvo technológia dizajn ovládanie")
+)
+
+// feedItem is one generated product with Heureka field names
+type feedItem struct {
+	ItemID      string
+	Name        string
+	Description string
+	Price       string
+	EAN         string
+	Brand       string
+	Category    string
+	URL         string
+	Image       string
+	Images      []string
+	Params      [][2]string
+}
+
+func generateItems(s FeedSpec) []feedItem {
+	rng := rand.New(rand.NewSource(s.Seed))
+	items := make([]feedItem, s.Items)
+	for i := range items {
+		noun := nouns[rng.Intn(len(nouns))]
+		brand := brands[rng.Intn(len(brands))]
+		cat := rng.Intn(s.Categories)
+		it := feedItem{
+			ItemID:   fmt.Sprintf("GEN-%07d", i+1),
+			Name:     fmt.Sprintf("%s %s %s-%d", noun, brand, strings.ToUpper(brand[:2]), 100+i),
+			Price:    fmt.Sprintf("%.2f", 5+rng.Float64()*1500),
+			EAN:      ean13(2000000000000 + int64(i)),
+			Brand:    brand,
+			Category: fmt.Sprintf("%s | %s | Skupina %d", sections[cat%len(sections)], noun, cat),
+			URL:      fmt.Sprintf("https://shop.example.com/p/%d", i+1),
+			Image:    fmt.Sprintf("https://cdn.example.com/img/%d/main.jpg", i+1),
+		}
+		desc := make([]string, s.DescriptionWords)
+		for w := range desc {
+			desc[w] = words[rng.Intn(len(words))]
+		}
+		it.Description = strings.Join(desc, " ")
+		for n := 1; n <= s.Images; n++ {
+			it.Images = append(it.Images, fmt.Sprintf("https://cdn.example.com/img/%d/%d.jpg", i+1, n))
+		}
+		for p := 0; p < s.Params; p++ {
+			value := colors[rng.Intn(len(colors))]
+			if p > 0 {
+				value = fmt.Sprintf("%d", 1+rng.Intn(500))
+			}
+			it.Params = append(it.Params, [2]string{paramNames[p%len(paramNames)], value})
+		}
+		switch r := rng.Float64(); {
+		case i > 0 && r < s.DuplicateRate:
+			it.EAN = items[rng.Intn(i)].EAN
+		case r < s.DuplicateRate+s.InvalidRate:
+			it.Price = ""
+		}
+		items[i] = it
+	}
+	return items
+}
+
+// ean13 appends the check digit to the first 12 digits of n
+func ean13(n int64) string {
+	digits := fmt.Sprintf("%012d", n%1000000000000)
+	sum := 0
+	for i, d := range digits {
+		v := int(d - '0')
+		if i%2 == 1 {
+			v *= 3
+		}
+		sum += v
+	}
+	return fmt.Sprintf("%s%d", digits, (10-sum%10)%10)
+}
+
+// GenerateFeed renders the feed described by s
+func GenerateFeed(s FeedSpec) []byte {
+	s = s.withDefaults()
+	items := generateItems(s)
+	var buf bytes.Buffer
+	switch s.Format {
+	case FormatCSV:
+		writeCSV(&buf, items)
+	case FormatJSON:
+		writeJSON(&buf, items)
+	default:
+		writeHeureka(&buf, items)
+	}
+	return buf.Bytes()
+}
+
+func writeHeureka(buf *bytes.Buffer, items []feedItem) {
+	esc := func(v string) string {
+		var b strings.Builder
+		xmlEscape(&b, v)
+		return b.String()
+	}
+	buf.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<SHOP>\n")
+	for _, it := range items {
+		buf.WriteString("<SHOPITEM>\n")
+		fmt.Fprintf(buf, "<ITEM_ID>%s</ITEM_ID>\n<PRODUCTNAME>%s</PRODUCTNAME>\n<DESCRIPTION>%s</DESCRIPTION>\n",
+			it.ItemID, esc(it.Name), esc(it.Description))
+		fmt.Fprintf(buf, "<URL>%s</URL>\n<IMGURL>%s</IMGURL>\n", it.URL, it.Image)
+		for _, img := range it.Images {
+			fmt.Fprintf(buf, "<IMGURL_ALTERNATIVE>%s</IMGURL_ALTERNATIVE>\n", img)
+		}
+		fmt.Fprintf(buf, "<PRICE_VAT>%s</PRICE_VAT>\n<EAN>%s</EAN>\n<MANUFACTURER>%s</MANUFACTURER>\n<CATEGORYTEXT>%s</CATEGORYTEXT>\n<DELIVERY_DATE>0</DELIVERY_DATE>\n",
+			it.Price, it.EAN, esc(it.Brand), esc(it.Category))
+		for _, p := range it.Params {
+			fmt.Fprintf(buf, "<PARAM>\n<PARAM_NAME>%s</PARAM_NAME>\n<VAL>%s</VAL>\n</PARAM>\n", esc(p[0]), esc(p[1]))
+		}
+		buf.WriteString("</SHOPITEM>\n")
+	}
+	buf.WriteString("</SHOP>\n")
+}
+
+func xmlEscape(b *strings.Builder, v string) {
+	for _, r := range v {
+		switch r {
+		case '<':
+			b.WriteString("&lt;")
+		case '>':
+			b.WriteString("&gt;")
+		case '&':
+			b.WriteString("&amp;")
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+// writeCSV writes the semicolon-separated layout Slovak suppliers export; CSV has no
+// attributes, so params are left out
+func writeCSV(buf *bytes.Buffer, items []feedItem) {
+	w := csv.NewWriter(buf)
+	w.Comma = ';'
+	w.Write([]string{"ITEM_ID", "PRODUCTNAME", "DESCRIPTION", "PRICE_VAT", "EAN", "MANUFACTURER", "CATEGORYTEXT", "URL", "IMGURL"})
+	for _, it := range items {
+		w.Write([]string{it.ItemID, it.Name, it.Description, it.Price, it.EAN, it.Brand, it.Category, it.URL, it.Image})
+	}
+	w.Flush()
+}
+
+// writeJSON writes {"products": [...]} with lower-case field names and images as a list
+func writeJSON(buf *bytes.Buffer, items []feedItem) {
+	products := make([]map[string]interface{}, len(items))
+	for i, it := range items {
+		p := map[string]interface{}{
+			"id": it.ItemID, "title": it.Name, "description": it.Description, "ean": it.EAN,
+			"brand": it.Brand, "category": it.Category, "url": it.URL, "image_url": it.Image, "images": it.Images,
+		}
+		if it.Price != "" {
+			p["price"] = it.Price
+		}
+		products[i] = p
+	}
+	json.NewEncoder(buf).Encode(map[string]interface{}{"products": products})
+}
+
+// ServeFeed serves the generated feed at /feed on a local server, rendering it once.
+// It sends an ETag and answers a matching If-None-Match with 304, as most suppliers do.
+// Callers Close the server; FeedURL returns the address to store on the feed.
+func ServeFeed(s FeedSpec) *httptest.Server {
+	s = s.withDefaults()
+	data := GenerateFeed(s)
+	contentType := s.Format.ContentType()
+	switch s.Compression {
+	case "gzip":
+		data, contentType = Gzip(data), "application/gzip"
+	case "zip":
+		data, contentType = Zip("feed"+s.Format.Extension(), data), "application/zip"
+	}
+	etag := fmt.Sprintf(`"%x"`, sha1.Sum(data))
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/feed" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+	}))
+}
+
+// FeedURL is the feed address of a server started by ServeFeed
+func FeedURL(srv *httptest.Server) string {
+	return srv.URL + "/feed"
+}
+
+// Gzip compresses a document the way suppliers serve feed.xml.gz
+func Gzip(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// Zip packs a document into an archive with a readme before it, so readers have to
+// pick the feed entry rather than the first one
+func Zip(name string, data []byte) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if w, err := zw.Create("README.txt"); err == nil {
+		w.Write([]byte("Product feed export\n"))
+	}
+	if w, err := zw.Create(name); err == nil {
+		w.Write(data)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,32 @@
+-- Price alert subscriptions
+CREATE TABLE IF NOT EXISTS price_alerts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    target_price DECIMAL(12,2) NOT NULL,
+    is_confirmed BOOLEAN DEFAULT false,
+    confirmed_at TIMESTAMP,
+    triggered_at TIMESTAMP,
+    unsubscribed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_alerts_product ON price_alerts(product_id);
+CREATE INDEX IF NOT EXISTS idx_price_alerts_pending ON price_alerts(product_id)
+    WHERE is_confirmed = true AND triggered_at IS NULL AND unsubscribed_at IS NULL;
+
+-- Queued outgoing notifications
+CREATE TABLE IF NOT EXISTS notification_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    type VARCHAR(50) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    status VARCHAR(20) DEFAULT 'pending',
+    attempts INTEGER DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    sent_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_events_status ON notification_events(status, created_at);
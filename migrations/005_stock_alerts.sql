@@ -0,0 +1,10 @@
+-- Back-in-stock subscriptions, consumed once the notification is queued
+CREATE TABLE IF NOT EXISTS stock_alerts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (product_id, email)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_alerts_product ON stock_alerts(product_id);
@@ -0,0 +1,22 @@
+-- Track how each product entered the catalog
+ALTER TABLE products ADD COLUMN IF NOT EXISTS feed_id UUID REFERENCES feeds(id) ON DELETE SET NULL;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS source VARCHAR(20) DEFAULT 'admin';
+
+CREATE INDEX IF NOT EXISTS idx_products_source ON products(source);
+
+-- Backfill feed linkage for rows imported before feed_id was recorded:
+-- match the affiliate URL host against the feed URL host
+UPDATE products p SET feed_id = f.id
+FROM feeds f
+WHERE p.feed_id IS NULL
+  AND COALESCE(p.affiliate_url, '') <> ''
+  AND lower(substring(p.affiliate_url from '^[a-zA-Z]+://(?:www\.)?([^/:?#]+)')) =
+      lower(substring(f.url from '^[a-zA-Z]+://(?:www\.)?([^/:?#]+)'));
+
+UPDATE products p SET source = CASE WHEN f.type = 'csv' THEN 'csv' ELSE 'import' END
+FROM feeds f
+WHERE p.feed_id = f.id AND p.source = 'admin';
+
+-- Imported products whose feed no longer exists still came from an import
+UPDATE products SET source = 'import'
+WHERE feed_id IS NULL AND source = 'admin' AND COALESCE(affiliate_url, '') <> '';
@@ -0,0 +1,15 @@
+-- VAT handling: price_min/price_max stay VAT-inclusive (gross), net values are stored alongside
+ALTER TABLE products ADD COLUMN IF NOT EXISTS currency VARCHAR(3) DEFAULT 'EUR';
+ALTER TABLE products ADD COLUMN IF NOT EXISTS vat_rate DECIMAL(5,2) DEFAULT 20;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS price_is_gross BOOLEAN DEFAULT true;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS price_min_net DECIMAL(12,2);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS price_max_net DECIMAL(12,2);
+
+UPDATE products SET
+    price_min_net = ROUND(price_min / (1 + COALESCE(vat_rate, 20) / 100), 2),
+    price_max_net = ROUND(price_max / (1 + COALESCE(vat_rate, 20) / 100), 2)
+WHERE price_min_net IS NULL;
+
+-- Whether prices delivered by the feed already include VAT
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS prices_include_vat BOOLEAN DEFAULT true;
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS vat_rate DECIMAL(5,2) DEFAULT 20;
@@ -0,0 +1,4 @@
+-- Per-run phase timings for import performance tracking
+ALTER TABLE feed_history ADD COLUMN IF NOT EXISTS metrics JSONB DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_feed_history_feed_started ON feed_history(feed_id, started_at DESC);
@@ -0,0 +1,14 @@
+-- Typed media (images, videos, documents) attached to products
+CREATE TABLE IF NOT EXISTS product_media (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('image', 'video', 'document')),
+    url VARCHAR(1000) NOT NULL,
+    title VARCHAR(255),
+    position INTEGER DEFAULT 0,
+    source VARCHAR(20) DEFAULT 'admin',
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_media_product ON product_media(product_id, type, position);
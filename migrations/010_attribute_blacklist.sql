@@ -0,0 +1,2 @@
+-- PARAM names (or their slugs) skipped when importing a feed
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS attribute_blacklist JSONB DEFAULT '[]';
@@ -0,0 +1,26 @@
+-- Staged imports: items wait for admin approval before reaching products
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS import_mode VARCHAR(20) DEFAULT 'live';
+
+CREATE TABLE IF NOT EXISTS staged_products (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    match_key VARCHAR(500) NOT NULL,
+    title VARCHAR(500) NOT NULL,
+    description TEXT,
+    short_description TEXT,
+    ean VARCHAR(50),
+    sku VARCHAR(100),
+    brand VARCHAR(255),
+    image_url VARCHAR(1000),
+    affiliate_url VARCHAR(2000),
+    category TEXT,
+    price DECIMAL(12,2),
+    stock_status VARCHAR(50),
+    params JSONB DEFAULT '[]',
+    media JSONB DEFAULT '[]',
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(feed_id, match_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_staged_products_feed ON staged_products(feed_id, created_at);
@@ -0,0 +1,13 @@
+-- Last affiliate link health check per product
+CREATE TABLE IF NOT EXISTS product_link_checks (
+    product_id UUID PRIMARY KEY REFERENCES products(id) ON DELETE CASCADE,
+    url VARCHAR(2000) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    http_status INTEGER,
+    final_url VARCHAR(2000),
+    error TEXT,
+    duration_ms INTEGER,
+    checked_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_link_checks_status ON product_link_checks(status);
@@ -0,0 +1,20 @@
+-- Related products (accessories, gifts, replacements, bundles).
+-- Feed rows keep the raw identifier until it resolves to a product of the same feed.
+CREATE TABLE IF NOT EXISTS product_relations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    related_identifier VARCHAR(255),
+    related_product_id UUID REFERENCES products(id) ON DELETE CASCADE,
+    relation_type VARCHAR(20) NOT NULL CHECK (relation_type IN ('accessory', 'gift', 'replacement', 'bundle')),
+    feed_id UUID REFERENCES feeds(id) ON DELETE CASCADE,
+    source VARCHAR(20) DEFAULT 'admin',
+    position INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW(),
+    resolved_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_relations_product ON product_relations(product_id, relation_type, position);
+CREATE INDEX IF NOT EXISTS idx_product_relations_unresolved ON product_relations(feed_id) WHERE related_product_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_relations_admin ON product_relations(product_id, relation_type, related_product_id) WHERE source = 'admin';
+
+ALTER TABLE staged_products ADD COLUMN IF NOT EXISTS relations JSONB DEFAULT '[]';
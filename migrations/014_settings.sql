@@ -0,0 +1,9 @@
+-- Runtime settings shared by all API instances
+CREATE TABLE IF NOT EXISTS settings (
+    key VARCHAR(100) PRIMARY KEY,
+    value JSONB NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+INSERT INTO settings (key, value) VALUES ('maintenance', '{"enabled": false}')
+ON CONFLICT (key) DO NOTHING;
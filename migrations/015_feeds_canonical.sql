@@ -0,0 +1,48 @@
+-- Canonical feeds layout. Databases created by the older feed implementation use
+-- feed_type/last_import/import_count; this adds every canonical column and carries
+-- the legacy values over. Legacy columns are kept (nullable) rather than dropped.
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS type VARCHAR(20) DEFAULT 'xml';
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS vendor_id UUID REFERENCES vendors(id) ON DELETE SET NULL;
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS schedule VARCHAR(50) DEFAULT 'daily';
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS is_active BOOLEAN DEFAULT true;
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS xml_item_path VARCHAR(100) DEFAULT 'SHOPITEM';
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS field_mapping JSONB DEFAULT '{}';
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS last_run TIMESTAMP;
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS last_status VARCHAR(50) DEFAULT 'idle';
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS product_count INTEGER DEFAULT 0;
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS prices_include_vat BOOLEAN DEFAULT true;
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS vat_rate DECIMAL(5,2) DEFAULT 20;
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS attribute_blacklist JSONB DEFAULT '[]';
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS import_mode VARCHAR(20) DEFAULT 'live';
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS created_at TIMESTAMP DEFAULT NOW();
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT NOW();
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'feeds' AND column_name = 'feed_type') THEN
+        UPDATE feeds SET type = lower(feed_type) WHERE COALESCE(feed_type, '') <> '';
+        ALTER TABLE feeds ALTER COLUMN feed_type DROP NOT NULL;
+    END IF;
+
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'feeds' AND column_name = 'last_import') THEN
+        UPDATE feeds SET last_run = last_import WHERE last_run IS NULL;
+        ALTER TABLE feeds ALTER COLUMN last_import DROP NOT NULL;
+    END IF;
+
+    -- import_count counted runs, which feed_history now records; product_count is rebuilt below
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'feeds' AND column_name = 'import_count') THEN
+        ALTER TABLE feeds ALTER COLUMN import_count DROP NOT NULL;
+    END IF;
+
+    -- category_mapping has no canonical counterpart; keep the data but stop requiring it
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'feeds' AND column_name = 'category_mapping') THEN
+        ALTER TABLE feeds ALTER COLUMN category_mapping DROP NOT NULL;
+    END IF;
+END $$;
+
+UPDATE feeds SET type = 'xml' WHERE type IS NULL OR type = '';
+UPDATE feeds SET product_count = (SELECT COUNT(*) FROM products WHERE products.feed_id = feeds.id);
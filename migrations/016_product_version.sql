@@ -0,0 +1,2 @@
+-- Edit version for optimistic concurrency on admin product updates
+ALTER TABLE products ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
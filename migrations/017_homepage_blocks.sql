@@ -0,0 +1,13 @@
+-- Curated homepage sections (product lists, category grids, banners)
+CREATE TABLE IF NOT EXISTS homepage_blocks (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    type VARCHAR(30) NOT NULL CHECK (type IN ('product_list', 'category_grid', 'banner')),
+    title VARCHAR(255),
+    config JSONB NOT NULL DEFAULT '{}',
+    position INTEGER DEFAULT 0,
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_homepage_blocks_position ON homepage_blocks(is_active, position);
@@ -0,0 +1,30 @@
+-- Canonical product_attributes columns are name/value/position. Databases created by the
+-- older handlers have attribute_name/attribute_slug/attribute_value; copy them over and
+-- stop requiring the legacy columns so new writes succeed.
+ALTER TABLE product_attributes ADD COLUMN IF NOT EXISTS name VARCHAR(255);
+ALTER TABLE product_attributes ADD COLUMN IF NOT EXISTS value TEXT;
+ALTER TABLE product_attributes ADD COLUMN IF NOT EXISTS position INTEGER DEFAULT 0;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'product_attributes' AND column_name = 'attribute_name') THEN
+        UPDATE product_attributes SET name = attribute_name WHERE name IS NULL;
+        ALTER TABLE product_attributes ALTER COLUMN attribute_name DROP NOT NULL;
+    END IF;
+
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'product_attributes' AND column_name = 'attribute_value') THEN
+        UPDATE product_attributes SET value = attribute_value WHERE value IS NULL;
+        ALTER TABLE product_attributes ALTER COLUMN attribute_value DROP NOT NULL;
+    END IF;
+
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'product_attributes' AND column_name = 'attribute_slug') THEN
+        ALTER TABLE product_attributes ALTER COLUMN attribute_slug DROP NOT NULL;
+    END IF;
+END $$;
+
+DELETE FROM product_attributes WHERE name IS NULL OR value IS NULL;
+ALTER TABLE product_attributes ALTER COLUMN name SET NOT NULL;
+ALTER TABLE product_attributes ALTER COLUMN value SET NOT NULL;
@@ -0,0 +1,12 @@
+-- Track categories created by feed imports and let feeds require mapped category paths
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS category_mode VARCHAR(20) DEFAULT 'create';
+
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS source VARCHAR(20) DEFAULT 'admin';
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS source_path TEXT;
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS created_by_feed_id UUID REFERENCES feeds(id) ON DELETE SET NULL;
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS created_by_run_id UUID;
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS curated_at TIMESTAMP;
+
+ALTER TABLE feed_history ADD COLUMN IF NOT EXISTS created_categories JSONB DEFAULT '[]';
+
+CREATE INDEX IF NOT EXISTS idx_categories_uncurated ON categories(created_at) WHERE source = 'import' AND curated_at IS NULL;
@@ -0,0 +1,2 @@
+-- Per-run breakdown of skipped feed items: {reason: {count, examples}}
+ALTER TABLE feed_history ADD COLUMN IF NOT EXISTS skip_reasons JSONB DEFAULT '{}';
@@ -0,0 +1,6 @@
+-- Reference prices for discount badges and the "discount" listing sort:
+-- original_price comes from feeds (PRICE_BEFORE), price_high is the highest price_min seen
+ALTER TABLE products ADD COLUMN IF NOT EXISTS original_price DECIMAL(12,2);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS price_high DECIMAL(12,2);
+
+UPDATE products SET price_high = price_min WHERE price_high IS NULL;
@@ -0,0 +1,25 @@
+-- Product Q&A: customer questions are moderated before they show on the product page
+CREATE TABLE IF NOT EXISTS product_questions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    author_name VARCHAR(100),
+    email VARCHAR(255),
+    body TEXT NOT NULL,
+    status VARCHAR(20) DEFAULT 'pending',
+    client_ip VARCHAR(64),
+    created_at TIMESTAMP DEFAULT NOW(),
+    approved_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_questions_product ON product_questions(product_id, status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_product_questions_status ON product_questions(status, created_at);
+
+CREATE TABLE IF NOT EXISTS product_answers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    question_id UUID NOT NULL REFERENCES product_questions(id) ON DELETE CASCADE,
+    author_name VARCHAR(100),
+    body TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_answers_question ON product_answers(question_id, created_at);
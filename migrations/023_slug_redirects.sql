@@ -0,0 +1,13 @@
+-- Historical slugs of renamed products and categories; the target slug is read
+-- from the entity so chains of renames resolve to the current slug
+CREATE TABLE IF NOT EXISTS slug_redirects (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    entity_type VARCHAR(20) NOT NULL,
+    entity_id UUID NOT NULL,
+    old_slug VARCHAR(500) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(entity_type, old_slug)
+);
+
+CREATE INDEX IF NOT EXISTS idx_slug_redirects_created ON slug_redirects(created_at);
+CREATE INDEX IF NOT EXISTS idx_slug_redirects_entity ON slug_redirects(entity_type, entity_id);
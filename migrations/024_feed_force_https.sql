@@ -0,0 +1,2 @@
+-- Per-feed switch upgrading http:// image and product URLs to https:// at import
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS force_https BOOLEAN DEFAULT false;
@@ -0,0 +1,10 @@
+-- Feed-created categories wait hidden in a review queue until an admin approves,
+-- renames or merges them. A merged category stays as a pointer so later imports of
+-- the same path land in the merge target.
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS created_from_feed BOOLEAN DEFAULT false;
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS review_status VARCHAR(20);
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS merged_into_id UUID REFERENCES categories(id) ON DELETE SET NULL;
+
+UPDATE categories SET created_from_feed = true WHERE source = 'import' AND created_from_feed = false;
+
+CREATE INDEX IF NOT EXISTS idx_categories_pending ON categories(created_at) WHERE review_status = 'pending';
@@ -0,0 +1,5 @@
+-- Classified import failures: stable code plus localized message and hint, and
+-- per-item errors grouped by code
+ALTER TABLE feed_history ADD COLUMN IF NOT EXISTS error_code VARCHAR(50);
+ALTER TABLE feed_history ADD COLUMN IF NOT EXISTS error_info JSONB;
+ALTER TABLE feed_history ADD COLUMN IF NOT EXISTS error_summary JSONB DEFAULT '[]';
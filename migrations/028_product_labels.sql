@@ -0,0 +1,29 @@
+-- Merchandising badges. "new" and "sale" are also computed from product data
+-- (created within 14 days, price dropped more than 10%); deleting them turns that off.
+CREATE TABLE IF NOT EXISTS product_labels (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    slug VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    color VARCHAR(20),
+    priority INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Expired assignments are ignored at read and purged periodically
+CREATE TABLE IF NOT EXISTS product_label_assignments (
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    label_id UUID NOT NULL REFERENCES product_labels(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (product_id, label_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_label_assignments_label ON product_label_assignments(label_id);
+CREATE INDEX IF NOT EXISTS idx_product_label_assignments_expires ON product_label_assignments(expires_at) WHERE expires_at IS NOT NULL;
+
+INSERT INTO product_labels (slug, name, color, priority) VALUES
+    ('new', 'Novinka', '#2e7d32', 10),
+    ('sale', 'Výpredaj', '#c62828', 20),
+    ('top', 'Top', '#f9a825', 30)
+ON CONFLICT (slug) DO NOTHING;
@@ -0,0 +1,9 @@
+-- Time-boxed promo prices: promo_price (gross, with its net counterpart) replaces
+-- price_min from promo_starts_at until promo_ends_at
+ALTER TABLE products ADD COLUMN IF NOT EXISTS promo_price DECIMAL(12,2);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS promo_price_net DECIMAL(12,2);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS promo_starts_at TIMESTAMP;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS promo_ends_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_products_promo_starts ON products(promo_starts_at) WHERE promo_price IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_products_promo_ends ON products(promo_ends_at) WHERE promo_price IS NOT NULL;
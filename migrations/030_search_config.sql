@@ -0,0 +1,24 @@
+-- Tunable search ranking per A/B variant; "default" serves requests without ?variant=
+CREATE TABLE IF NOT EXISTS search_config (
+    variant VARCHAR(50) PRIMARY KEY,
+    config JSONB NOT NULL,
+    version INTEGER DEFAULT 1,
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+INSERT INTO search_config (variant, config) VALUES
+    ('default', '{"field_boosts": {"title": 3, "description": 1, "short_description": 1, "brand": 2, "ean": 4, "sku": 4, "mpn": 4}, "fuzziness": "AUTO"}')
+ON CONFLICT (variant) DO NOTHING;
+
+-- Search analytics: one row per served search, tagged with the ranking variant and its config version
+CREATE TABLE IF NOT EXISTS search_events (
+    id BIGSERIAL PRIMARY KEY,
+    query TEXT,
+    variant VARCHAR(50) NOT NULL,
+    config_version INTEGER,
+    total BIGINT,
+    took_ms BIGINT,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_search_events_variant ON search_events(variant, created_at);
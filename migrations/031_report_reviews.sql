@@ -0,0 +1,12 @@
+-- Products an admin has reviewed on a sanity report. Outliers reviewed at one price
+-- come back if the price changes; reviewed uncategorized products stay hidden.
+CREATE TABLE IF NOT EXISTS report_reviews (
+    report VARCHAR(50) NOT NULL,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    price_min DECIMAL(12,2),
+    note TEXT,
+    reviewed_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (report, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_products_uncategorized ON products(created_at) WHERE category_id IS NULL AND is_active = true;
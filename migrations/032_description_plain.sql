@@ -0,0 +1,5 @@
+-- Plain-text description and a ~160 character excerpt for meta tags and cards, derived
+-- from the HTML description on every write. Existing rows are filled by
+-- POST /api/v1/admin/descriptions/backfill.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS description_plain TEXT;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS excerpt VARCHAR(200);
@@ -0,0 +1,13 @@
+-- Optional brand landing data keyed by the brand slug. Brands themselves come from
+-- products.brand; a row here only adds a logo, description and SEO fields.
+CREATE TABLE IF NOT EXISTS brands (
+    slug VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255),
+    logo_url TEXT,
+    description TEXT,
+    seo_title VARCHAR(255),
+    seo_description TEXT,
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_products_brand_active ON products(brand) WHERE is_active = true;
@@ -0,0 +1,15 @@
+-- Responses of admin create requests sent with an Idempotency-Key header, replayed on
+-- retries for 24 hours. A row without completed_at is a request still in flight.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key VARCHAR(255) NOT NULL,
+    route VARCHAR(255) NOT NULL,
+    body_hash CHAR(64) NOT NULL,
+    status_code INTEGER,
+    content_type VARCHAR(255),
+    response BYTEA,
+    created_at TIMESTAMP DEFAULT NOW(),
+    completed_at TIMESTAMP,
+    PRIMARY KEY (key, route)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys(created_at);
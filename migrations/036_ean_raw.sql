@@ -0,0 +1,3 @@
+-- EANs that fail normalization are kept here for reference and never used for
+-- matching; products.ean only holds valid, normalized codes.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS ean_raw VARCHAR(255);
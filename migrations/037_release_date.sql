@@ -0,0 +1,5 @@
+-- Release date of preorder products; the preorder worker switches them to instock
+-- once the date has passed.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS release_date DATE;
+
+CREATE INDEX IF NOT EXISTS idx_products_preorder_release ON products(release_date) WHERE stock_status = 'preorder';
@@ -0,0 +1,2 @@
+-- The cross-feed import list filters and sorts all runs by start time
+CREATE INDEX IF NOT EXISTS idx_feed_history_started ON feed_history(started_at DESC);
@@ -0,0 +1,13 @@
+-- Imports upsert on the EAN, which needs it to be unique. Empty strings become NULL;
+-- where several products share an EAN the oldest keeps it and the others move the
+-- code to ean_raw, which is never used for matching, so they can be reviewed.
+UPDATE products SET ean = NULL WHERE ean = '';
+
+WITH ranked AS (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY ean ORDER BY created_at, id) AS rn
+    FROM products WHERE ean IS NOT NULL
+)
+UPDATE products p SET ean_raw = COALESCE(p.ean_raw, p.ean), ean = NULL
+FROM ranked r WHERE p.id = r.id AND r.rn > 1;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_ean_unique ON products(ean) WHERE ean IS NOT NULL AND ean <> '';
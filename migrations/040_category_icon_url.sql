@@ -0,0 +1,3 @@
+-- Uploaded, sanitized SVG icon of a category. categories.icon keeps the identifier
+-- from the fixed icon set served by /admin/categories/icons.
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS icon_url VARCHAR(500);
@@ -0,0 +1,6 @@
+-- Data completeness score (0-100) from the weighted checks in the
+-- "completeness_weights" setting. NULL until first computed; POST
+-- /admin/completeness/recompute fills existing products.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS completeness SMALLINT;
+
+CREATE INDEX IF NOT EXISTS idx_products_completeness ON products(completeness);
@@ -0,0 +1,5 @@
+-- Promotional extras taken from feeds (Heureka GIFT, EXTENDED_WARRANTY and
+-- EXTRA_MESSAGE, or the fields field_mapping points at them)
+ALTER TABLE products ADD COLUMN IF NOT EXISTS gift_text TEXT;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS warranty_months SMALLINT;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS extra_message TEXT;
@@ -0,0 +1,3 @@
+-- Per-item outcome breakdown of an import run: items rejected as invalid, items
+-- lost to database errors after retries, and items whose search sync failed
+ALTER TABLE feed_history ADD COLUMN IF NOT EXISTS outcomes JSONB;
@@ -0,0 +1,15 @@
+-- Prices are read and written as exact NUMERIC(12,2) amounts; widen the offer and
+-- vendor price columns created as DECIMAL(10,2) where they exist
+DO $$
+DECLARE
+    col RECORD;
+BEGIN
+    FOR col IN
+        SELECT table_name, column_name FROM information_schema.columns
+        WHERE (table_name, column_name) IN (('offers','shipping_price'), ('vendors','shipping_price'),
+                                            ('product_offers','price'), ('product_offers','shipping_price'))
+          AND data_type = 'numeric' AND (numeric_precision <> 12 OR numeric_scale <> 2)
+    LOOP
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN %I TYPE NUMERIC(12,2)', col.table_name, col.column_name);
+    END LOOP;
+END $$;
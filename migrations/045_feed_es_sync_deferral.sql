@@ -0,0 +1,3 @@
+-- Set while a feed's Elasticsearch sync is held back by an import blackout window;
+-- the import scheduler syncs the feed and clears it once the window ends
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS es_sync_deferred_at TIMESTAMP;
@@ -0,0 +1,17 @@
+-- Notification outbox: rendered HTML alternative, language, retry schedule and a
+-- dead-letter state for messages that exhausted their delivery attempts
+ALTER TABLE notification_events ADD COLUMN IF NOT EXISTS lang VARCHAR(5) NOT NULL DEFAULT 'sk';
+ALTER TABLE notification_events ADD COLUMN IF NOT EXISTS html_body TEXT;
+ALTER TABLE notification_events ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP DEFAULT NOW();
+ALTER TABLE notification_events ADD COLUMN IF NOT EXISTS dead_at TIMESTAMP;
+
+UPDATE notification_events SET status = 'dead', dead_at = COALESCE(dead_at, created_at) WHERE status = 'failed';
+
+DROP INDEX IF EXISTS idx_notification_events_status;
+CREATE INDEX IF NOT EXISTS idx_notification_events_due ON notification_events(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_notification_events_created ON notification_events(created_at DESC);
+
+-- Language each subscriber receives their e-mails in
+ALTER TABLE price_alerts ADD COLUMN IF NOT EXISTS lang VARCHAR(5) NOT NULL DEFAULT 'sk';
+ALTER TABLE stock_alerts ADD COLUMN IF NOT EXISTS lang VARCHAR(5) NOT NULL DEFAULT 'sk';
+ALTER TABLE product_questions ADD COLUMN IF NOT EXISTS lang VARCHAR(5) NOT NULL DEFAULT 'sk';
@@ -0,0 +1,7 @@
+-- Queries clients may send by SHA-256 hash instead of text (Apollo automatic
+-- persisted queries); admins register them up front when GRAPHQL_PERSISTED_ONLY is set
+CREATE TABLE IF NOT EXISTS graphql_persisted_queries (
+    hash CHAR(64) PRIMARY KEY,
+    query TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
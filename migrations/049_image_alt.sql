@@ -0,0 +1,19 @@
+-- Alt text of gallery images. Imports fill it from the image_alt template; alt_edited
+-- marks alts set by an admin, which re-imports keep
+ALTER TABLE product_media ADD COLUMN IF NOT EXISTS alt VARCHAR(255);
+ALTER TABLE product_media ADD COLUMN IF NOT EXISTS alt_edited BOOLEAN NOT NULL DEFAULT false;
+
+INSERT INTO settings (key, value) VALUES ('image_alt', '{"template": "{title} – obrázok {n}"}')
+ON CONFLICT (key) DO NOTHING;
+
+-- Backfill images imported before alt text existed with the default template
+UPDATE product_media m SET alt = LEFT(p.title || ' – obrázok ' || n.n, 255)
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY product_id ORDER BY position, created_at) AS n
+    FROM product_media WHERE type = 'image'
+) n, products p
+WHERE m.id = n.id AND p.id = m.product_id AND COALESCE(m.alt, '') = '';
+
+UPDATE product_images i SET alt = LEFT(p.title || ' – obrázok ' || (COALESCE(i.position, 0) + 1), 255)
+FROM products p
+WHERE p.id = i.product_id AND COALESCE(i.alt, '') = '';
@@ -0,0 +1,18 @@
+-- Restore points of the catalog (categories, products and their images, attributes,
+-- media and offers). The data lives in a gzip file under SNAPSHOT_DIR; this table
+-- tracks the files and the outcome of the last restore
+CREATE TABLE IF NOT EXISTS catalog_snapshots (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    file VARCHAR(500) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'creating' CHECK (status IN ('creating', 'ready', 'failed')),
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    counts JSONB NOT NULL DEFAULT '{}',
+    error TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    completed_at TIMESTAMP,
+    last_restored_at TIMESTAMP,
+    last_restore JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_catalog_snapshots_created ON catalog_snapshots(created_at DESC);
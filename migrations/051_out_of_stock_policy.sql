@@ -0,0 +1,5 @@
+-- How listings and search treat out-of-stock products of a category: hide them,
+-- demote them below products in stock, or show them as usual. inherit takes the
+-- parent's policy; a root that inherits shows them.
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS out_of_stock_policy VARCHAR(10) NOT NULL DEFAULT 'inherit'
+    CHECK (out_of_stock_policy IN ('inherit', 'hide', 'demote', 'show'));
@@ -0,0 +1,13 @@
+-- Short descriptions composed from attributes. A category's template such as
+-- "{Uhlopriečka}, {RAM}, {Úložisko}" names the attributes to list; subcategories
+-- without one use their parent's
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS short_description_template VARCHAR(500);
+
+-- short_description_generated marks text written from the template, which later runs
+-- may rewrite; short_description_locked keeps an admin's text from being replaced
+ALTER TABLE products ADD COLUMN IF NOT EXISTS short_description_generated BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS short_description_locked BOOLEAN NOT NULL DEFAULT false;
+
+-- Texts written in the admin before the lock existed
+UPDATE products SET short_description_locked = true
+WHERE source = 'admin' AND COALESCE(short_description, '') <> '';
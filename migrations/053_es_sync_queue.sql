@@ -0,0 +1,18 @@
+-- Pending Elasticsearch writes. Product changes are queued here and the sync worker
+-- indexes or removes them, retrying failures with backoff. After the last attempt an
+-- entry is dead-lettered (dead_at) until an admin requeues it. version changes on every
+-- enqueue, so a write that finishes after a newer change does not drop that change.
+CREATE TABLE IF NOT EXISTS es_sync_queue (
+    product_id UUID PRIMARY KEY,
+    op VARCHAR(10) NOT NULL DEFAULT 'index' CHECK (op IN ('index', 'delete')),
+    attempts INT NOT NULL DEFAULT 0,
+    version INT NOT NULL DEFAULT 1,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    dead_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_es_sync_queue_due ON es_sync_queue(next_attempt_at) WHERE dead_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_es_sync_queue_dead ON es_sync_queue(dead_at DESC) WHERE dead_at IS NOT NULL;
@@ -0,0 +1,5 @@
+-- A product merged into another stays as an inactive row so its page can answer 410
+-- with a redirect to the product that replaced it
+ALTER TABLE products ADD COLUMN IF NOT EXISTS merged_into_id UUID REFERENCES products(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_products_merged_into ON products(merged_into_id) WHERE merged_into_id IS NOT NULL;
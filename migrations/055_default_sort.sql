@@ -0,0 +1,7 @@
+-- Sort a listing uses when the request names none. A category without one takes its
+-- parent's; a root without one uses the listing_sort setting.
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS default_sort VARCHAR(20)
+    CHECK (default_sort IN ('newest', 'price_asc', 'price_desc', 'name_asc', 'popularity', 'discount', 'rating'));
+
+INSERT INTO settings (key, value) VALUES ('listing_sort', '{"default_sort": "newest"}')
+ON CONFLICT (key) DO NOTHING;
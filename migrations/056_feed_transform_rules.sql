@@ -0,0 +1,3 @@
+-- Ordered per-feed transform rules applied to mapped item fields before validation,
+-- e.g. {"field": "title", "operation": "replace", "args": ["(bazár)", ""]}
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS transform_rules JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,8 @@
+-- Active in-stock products per category, kept next to product_count so the storefront
+-- can grey out or hide categories with nothing to buy.
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS in_stock_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE categories SET (product_count, in_stock_count) = (
+    SELECT COUNT(*), COUNT(*) FILTER (WHERE COALESCE(stock_status,'instock') <> 'outofstock')
+    FROM products WHERE category_id = categories.id AND is_active = true
+);
@@ -0,0 +1,7 @@
+-- Live progress of a run, written periodically while it imports, so progress and the
+-- tail of the log survive a restart of the instance running it.
+ALTER TABLE feed_history ADD COLUMN IF NOT EXISTS phase VARCHAR(20);
+ALTER TABLE feed_history ADD COLUMN IF NOT EXISTS processed INTEGER DEFAULT 0;
+ALTER TABLE feed_history ADD COLUMN IF NOT EXISTS message TEXT;
+ALTER TABLE feed_history ADD COLUMN IF NOT EXISTS log_excerpt JSONB DEFAULT '[]';
+ALTER TABLE feed_history ADD COLUMN IF NOT EXISTS progress_at TIMESTAMP;
@@ -0,0 +1,8 @@
+-- Consecutive failed imports per feed, the automatic retry of a failed scheduled run
+-- and the flag raised once failures pass the alert threshold.
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS consecutive_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS needs_attention BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS retry_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS retry_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_feeds_retry_at ON feeds(retry_at) WHERE retry_at IS NOT NULL;
@@ -0,0 +1,5 @@
+-- Variants: products of one feed sharing the feed's ITEMGROUP_ID are options of one
+-- configurable product.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS item_group_id VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS idx_products_item_group ON products(feed_id, item_group_id) WHERE item_group_id IS NOT NULL;
@@ -0,0 +1,14 @@
+-- Settings are written through the settings service, which records who changed them
+ALTER TABLE settings ADD COLUMN IF NOT EXISTS updated_by VARCHAR(255);
+
+-- The single filter_settings row becomes the "filters" setting. The legacy table is
+-- left in place but no longer read.
+DO $$
+BEGIN
+    IF to_regclass('filter_settings') IS NOT NULL THEN
+        INSERT INTO settings (key, value, updated_at, updated_by)
+        SELECT 'filters', settings::jsonb, COALESCE(updated_at, NOW()), 'migration'
+        FROM filter_settings WHERE id = 1 AND settings IS NOT NULL
+        ON CONFLICT (key) DO NOTHING;
+    END IF;
+END $$;
@@ -0,0 +1,8 @@
+-- Cache validators of the last fully imported download. Imports send them back as
+-- If-None-Match / If-Modified-Since and stop with status not_modified on a 304.
+-- source_modified_at is when the source content last changed, source_checked_at when
+-- it was last requested.
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS source_etag VARCHAR(500);
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS source_last_modified VARCHAR(100);
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS source_modified_at TIMESTAMP;
+ALTER TABLE feeds ADD COLUMN IF NOT EXISTS source_checked_at TIMESTAMP;
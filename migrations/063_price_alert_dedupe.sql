@@ -0,0 +1,14 @@
+-- One open price alert per e-mail and product. A repeated subscription updates the
+-- open alert instead of adding another; older duplicates are unsubscribed, keeping
+-- a confirmed alert over an unconfirmed one and otherwise the newest.
+UPDATE price_alerts a SET unsubscribed_at = NOW()
+WHERE a.triggered_at IS NULL AND a.unsubscribed_at IS NULL AND EXISTS (
+    SELECT 1 FROM price_alerts b
+    WHERE b.product_id = a.product_id AND b.email = a.email AND b.id <> a.id
+      AND b.triggered_at IS NULL AND b.unsubscribed_at IS NULL
+      AND (COALESCE(b.is_confirmed, false), COALESCE(b.created_at, 'epoch'), b.id)
+        > (COALESCE(a.is_confirmed, false), COALESCE(a.created_at, 'epoch'), a.id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_price_alerts_open_email ON price_alerts(product_id, email)
+    WHERE triggered_at IS NULL AND unsubscribed_at IS NULL;
@@ -0,0 +1,4 @@
+-- Webhook deliveries share the notification outbox: recipient holds the URL and body
+-- the JSON payload, so they get the same retries and dead-letter handling as e-mails
+ALTER TABLE notification_events ADD COLUMN IF NOT EXISTS channel VARCHAR(10) NOT NULL DEFAULT 'email';
+ALTER TABLE notification_events ALTER COLUMN recipient TYPE TEXT;